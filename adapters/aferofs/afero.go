@@ -0,0 +1,309 @@
+package aferofs
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/spf13/afero"
+)
+
+var (
+	_ interface {
+		afero.Fs
+		afero.Lstater
+		afero.Linker
+		afero.LinkReader
+	} = &HackpadFs{}
+	_ afero.File = &aferoFile{}
+)
+
+// HackpadFs serves a hackpadfs FS as an afero.Fs.
+// Paths may be absolute or relative, and are converted to FS paths by cleaning them and removing leading slashes.
+// Errors report the path as it was passed in, and are compatible with os.IsNotExist() and similar checks.
+//
+// Optional afero interfaces, like afero.Lstater, are always implemented, and fall back like other afero.Fs wrappers if the FS does not support them.
+type HackpadFs struct {
+	fs hackpadfs.FS
+}
+
+// ToAfero returns an afero.Fs served by 'fs'
+func ToAfero(fs hackpadfs.FS) *HackpadFs {
+	return &HackpadFs{fs: fs}
+}
+
+// toFSPath converts an afero path to an FS path, like "/a/../b" to "b"
+func toFSPath(name string) string {
+	name = path.Clean(filepath.ToSlash(name))
+	name = strings.TrimLeft(name, "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// toAferoErr converts hackpadfs errors to afero errors, reporting 'name' as the path if the FS reported its FS path
+func toAferoErr(name string, err error) error {
+	var pathErr *hackpadfs.PathError
+	var linkErr *hackpadfs.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		errPath := pathErr.Path
+		switch {
+		case errPath == toFSPath(name):
+			errPath = name
+		case strings.HasPrefix(name, "/"):
+			errPath = "/" + errPath
+		}
+		return &os.PathError{Op: pathErr.Op, Path: errPath, Err: pathErr.Err}
+	case errors.As(err, &linkErr):
+		return &os.LinkError{Op: linkErr.Op, Old: linkErr.Old, New: linkErr.New, Err: linkErr.Err}
+	default:
+		return err
+	}
+}
+
+func toAferoLinkErr(oldname, newname string, err error) error {
+	var pathErr *hackpadfs.PathError
+	var linkErr *hackpadfs.LinkError
+	switch {
+	case errors.As(err, &linkErr):
+		return &os.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	case errors.As(err, &pathErr):
+		return &os.LinkError{Op: pathErr.Op, Old: oldname, New: newname, Err: pathErr.Err}
+	default:
+		return err
+	}
+}
+
+// Name implements afero.Fs
+func (fs *HackpadFs) Name() string {
+	return "HackpadFs"
+}
+
+// Create implements afero.Fs
+func (fs *HackpadFs) Create(name string) (afero.File, error) {
+	f, err := hackpadfs.Create(fs.fs, toFSPath(name))
+	if err != nil {
+		return nil, toAferoErr(name, err)
+	}
+	return &aferoFile{file: f, name: name}, nil
+}
+
+// Mkdir implements afero.Fs
+func (fs *HackpadFs) Mkdir(name string, perm os.FileMode) error {
+	if err := hackpadfs.Mkdir(fs.fs, toFSPath(name), perm); err != nil {
+		return toAferoErr(name, err)
+	}
+	return nil
+}
+
+// MkdirAll implements afero.Fs
+func (fs *HackpadFs) MkdirAll(path string, perm os.FileMode) error {
+	if err := hackpadfs.MkdirAll(fs.fs, toFSPath(path), perm); err != nil {
+		return toAferoErr(path, err)
+	}
+	return nil
+}
+
+// Open implements afero.Fs
+func (fs *HackpadFs) Open(name string) (afero.File, error) {
+	f, err := fs.fs.Open(toFSPath(name))
+	if err != nil {
+		return nil, toAferoErr(name, err)
+	}
+	return &aferoFile{file: f, name: name}, nil
+}
+
+// OpenFile implements afero.Fs
+func (fs *HackpadFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	f, err := hackpadfs.OpenFile(fs.fs, toFSPath(name), flag, perm)
+	if err != nil {
+		return nil, toAferoErr(name, err)
+	}
+	return &aferoFile{file: f, name: name}, nil
+}
+
+// Remove implements afero.Fs
+func (fs *HackpadFs) Remove(name string) error {
+	if err := hackpadfs.Remove(fs.fs, toFSPath(name)); err != nil {
+		return toAferoErr(name, err)
+	}
+	return nil
+}
+
+// RemoveAll implements afero.Fs
+func (fs *HackpadFs) RemoveAll(path string) error {
+	if err := hackpadfs.RemoveAll(fs.fs, toFSPath(path)); err != nil {
+		return toAferoErr(path, err)
+	}
+	return nil
+}
+
+// Rename implements afero.Fs
+func (fs *HackpadFs) Rename(oldname, newname string) error {
+	if err := hackpadfs.Rename(fs.fs, toFSPath(oldname), toFSPath(newname)); err != nil {
+		return toAferoLinkErr(oldname, newname, err)
+	}
+	return nil
+}
+
+// Stat implements afero.Fs
+func (fs *HackpadFs) Stat(name string) (os.FileInfo, error) {
+	info, err := hackpadfs.Stat(fs.fs, toFSPath(name))
+	if err != nil {
+		return nil, toAferoErr(name, err)
+	}
+	return info, nil
+}
+
+// LstatIfPossible implements afero.Lstater. Falls back to Stat if the FS does not support Lstat.
+func (fs *HackpadFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	info, err := hackpadfs.Lstat(fs.fs, toFSPath(name))
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		info, err := fs.Stat(name)
+		return info, false, err
+	}
+	if err != nil {
+		return nil, true, toAferoErr(name, err)
+	}
+	return info, true, nil
+}
+
+// Chmod implements afero.Fs
+func (fs *HackpadFs) Chmod(name string, mode os.FileMode) error {
+	if err := hackpadfs.Chmod(fs.fs, toFSPath(name), mode); err != nil {
+		return toAferoErr(name, err)
+	}
+	return nil
+}
+
+// Chown implements afero.Fs
+func (fs *HackpadFs) Chown(name string, uid, gid int) error {
+	if err := hackpadfs.Chown(fs.fs, toFSPath(name), uid, gid); err != nil {
+		return toAferoErr(name, err)
+	}
+	return nil
+}
+
+// Chtimes implements afero.Fs
+func (fs *HackpadFs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := hackpadfs.Chtimes(fs.fs, toFSPath(name), atime, mtime); err != nil {
+		return toAferoErr(name, err)
+	}
+	return nil
+}
+
+// SymlinkIfPossible implements afero.Linker. Fails with afero.ErrNoSymlink if the FS does not support symlinks.
+// The target 'oldname' is stored as-is.
+func (fs *HackpadFs) SymlinkIfPossible(oldname, newname string) error {
+	err := hackpadfs.Symlink(fs.fs, oldname, toFSPath(newname))
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: afero.ErrNoSymlink}
+	}
+	if err != nil {
+		return toAferoLinkErr(oldname, newname, err)
+	}
+	return nil
+}
+
+// ReadlinkIfPossible implements afero.LinkReader. Fails with afero.ErrNoReadlink if the FS does not support symlinks.
+func (fs *HackpadFs) ReadlinkIfPossible(name string) (string, error) {
+	target, err := hackpadfs.Readlink(fs.fs, toFSPath(name))
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: afero.ErrNoReadlink}
+	}
+	if err != nil {
+		return "", toAferoErr(name, err)
+	}
+	return target, nil
+}
+
+// aferoFile serves a hackpadfs File as an afero.File
+type aferoFile struct {
+	file hackpadfs.File
+	name string
+}
+
+func (f *aferoFile) Name() string {
+	return f.name
+}
+
+func (f *aferoFile) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	return n, f.wrapErr(err)
+}
+
+func (f *aferoFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := hackpadfs.ReadAtFile(f.file, p, off)
+	return n, f.wrapErr(err)
+}
+
+func (f *aferoFile) Write(p []byte) (int, error) {
+	n, err := hackpadfs.WriteFile(f.file, p)
+	return n, f.wrapErr(err)
+}
+
+func (f *aferoFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := hackpadfs.WriteAtFile(f.file, p, off)
+	return n, f.wrapErr(err)
+}
+
+func (f *aferoFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *aferoFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := hackpadfs.SeekFile(f.file, offset, whence)
+	return n, f.wrapErr(err)
+}
+
+func (f *aferoFile) Readdir(count int) ([]os.FileInfo, error) {
+	entries, err := hackpadfs.ReadDirFile(f.file, count)
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return infos, f.wrapErr(infoErr)
+		}
+		infos = append(infos, info)
+	}
+	return infos, f.wrapErr(err)
+}
+
+func (f *aferoFile) Readdirnames(n int) ([]string, error) {
+	entries, err := hackpadfs.ReadDirFile(f.file, n)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, f.wrapErr(err)
+}
+
+func (f *aferoFile) Stat() (os.FileInfo, error) {
+	info, err := f.file.Stat()
+	return info, f.wrapErr(err)
+}
+
+func (f *aferoFile) Sync() error {
+	return f.wrapErr(hackpadfs.SyncFile(f.file))
+}
+
+func (f *aferoFile) Truncate(size int64) error {
+	return f.wrapErr(hackpadfs.TruncateFile(f.file, size))
+}
+
+func (f *aferoFile) Close() error {
+	return f.wrapErr(f.file.Close())
+}
+
+func (f *aferoFile) wrapErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	return toAferoErr(f.name, err)
+}
@@ -0,0 +1,101 @@
+package aferofs
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"github.com/spf13/afero"
+)
+
+func TestToFSPath(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		expectFS string
+	}{
+		{name: "", expectFS: "."},
+		{name: ".", expectFS: "."},
+		{name: "/", expectFS: "."},
+		{name: "foo", expectFS: "foo"},
+		{name: "/foo/bar/", expectFS: "foo/bar"},
+		{name: "//foo//bar", expectFS: "foo/bar"},
+		{name: "/foo/../bar", expectFS: "bar"},
+		{name: "../foo", expectFS: "../foo"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expectFS, toFSPath(tc.name))
+		})
+	}
+}
+
+func TestToAferoMem(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	fs := ToAfero(memFS)
+	assert.Equal(t, "HackpadFs", fs.Name())
+
+	requireNoError(t, fs.MkdirAll("/foo/bar", 0700))
+	requireNoError(t, afero.WriteFile(fs, "/foo/bar/baz", []byte("baz"), 0600))
+	data, err := hackpadfs.ReadFile(memFS, "foo/bar/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(data))
+
+	var walked []string
+	requireNoError(t, afero.Walk(fs, "/", func(path string, info os.FileInfo, err error) error {
+		walked = append(walked, path)
+		return err
+	}))
+	sort.Strings(walked)
+	assert.Equal(t, []string{"/", "/foo", "/foo/bar", "/foo/bar/baz"}, walked)
+
+	_, err = fs.Stat("/foo/biff")
+	assert.Equal(t, true, os.IsNotExist(err))
+	assert.Equal(t, &os.PathError{Op: "stat", Path: "/foo/biff", Err: hackpadfs.ErrNotExist}, err)
+	err = fs.Rename("/foo/biff", "/foo/boo")
+	assert.Equal(t, true, os.IsNotExist(err))
+	assert.IsType(t, &os.LinkError{}, err)
+
+	requireNoError(t, fs.SymlinkIfPossible("bar/baz", "/foo/link"))
+	target, err := fs.ReadlinkIfPossible("/foo/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar/baz", target)
+	info, lstatCalled, err := fs.LstatIfPossible("/foo/link")
+	requireNoError(t, err)
+	assert.Equal(t, true, lstatCalled)
+	assert.Equal(t, hackpadfs.ModeSymlink, info.Mode().Type())
+
+	f, err := fs.Open("/foo/bar")
+	requireNoError(t, err)
+	assert.Equal(t, "/foo/bar", f.Name())
+	names, err := f.Readdirnames(-1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"baz"}, names)
+	assert.NoError(t, f.Close())
+}
+
+func TestToAferoMinimalFS(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(memFS, "foo", []byte("foo"), 0600))
+	fs := ToAfero(struct{ hackpadfs.FS }{memFS}) // hides optional interfaces
+
+	info, lstatCalled, err := fs.LstatIfPossible("foo")
+	requireNoError(t, err)
+	assert.Equal(t, false, lstatCalled)
+	assert.Equal(t, "foo", info.Name())
+	err = fs.SymlinkIfPossible("foo", "bar")
+	assert.Equal(t, &os.LinkError{Op: "symlink", Old: "foo", New: "bar", Err: afero.ErrNoSymlink}, err)
+	_, err = fs.ReadlinkIfPossible("foo")
+	assert.Equal(t, &os.PathError{Op: "readlink", Path: "foo", Err: afero.ErrNoReadlink}, err)
+
+	_, err = fs.Create("bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+}
@@ -0,0 +1,333 @@
+// Package aferofs adapts file systems between hackpadfs and afero, so projects can migrate incrementally or reuse afero-only backends.
+//
+// FromAfero serves an afero.Fs as a hackpadfs FS, and ToAfero serves a hackpadfs FS as an afero.Fs.
+package aferofs
+
+import (
+	"errors"
+	"io"
+	gofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/spf13/afero"
+	aferomem "github.com/spf13/afero/mem"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.CreateFS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.SymlinkFS
+		hackpadfs.ReadlinkFS
+	} = &FS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+	} = &file{}
+)
+
+// FS serves an afero.Fs as a hackpadfs FS.
+// Paths are validated, then passed to the afero.Fs unchanged, so an OS-backed afero.Fs should be rooted with afero.NewBasePathFs().
+//
+// Lstat, Symlink, and Readlink are supported if the afero.Fs implements afero.Lstater, afero.Linker, or afero.LinkReader respectively.
+// Otherwise, they fail with hackpadfs.ErrNotImplemented.
+type FS struct {
+	fs afero.Fs
+}
+
+// FromAfero returns an FS served by 'fs'
+func FromAfero(fs afero.Fs) *FS {
+	return &FS{fs: fs}
+}
+
+// fromAferoErr converts afero errors to hackpadfs errors, reporting 'name' as the path
+func fromAferoErr(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pathErr *hackpadfs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		op, name, err = pathErr.Op, errPath(name, pathErr.Path), pathErr.Err
+	case errors.As(err, &linkErr):
+		op, err = linkErr.Op, linkErr.Err
+	}
+	switch err {
+	case afero.ErrFileClosed, aferomem.ErrFileClosed:
+		err = hackpadfs.ErrClosed
+	case afero.ErrOutOfRange, aferomem.ErrOutOfRange:
+		err = hackpadfs.ErrInvalid
+	case afero.ErrTooLarge, aferomem.ErrTooLarge:
+		err = hackpadfs.ErrTooLarge
+	case afero.ErrNoSymlink, afero.ErrNoReadlink:
+		err = hackpadfs.ErrNotImplemented
+	}
+	return &hackpadfs.PathError{Op: op, Path: name, Err: err}
+}
+
+// errPath returns the part of 'name' reported by an afero error's path, like a parent directory.
+// Some afero.Fs report real paths, like afero.BasePathFs, so the path only needs to end with it.
+func errPath(name, aferoPath string) string {
+	aferoPath = filepath.ToSlash(aferoPath)
+	for p := name; p != "."; p = path.Dir(p) {
+		if aferoPath == p || strings.HasSuffix(aferoPath, "/"+p) {
+			return p
+		}
+	}
+	return name
+}
+
+func fromAferoLinkErr(op, oldname, newname string, err error) error {
+	if err == nil {
+		return nil
+	}
+	err = fromAferoErr(op, newname, err)
+	pathErr := err.(*hackpadfs.PathError)
+	return &hackpadfs.LinkError{Op: pathErr.Op, Old: oldname, New: newname, Err: pathErr.Err}
+}
+
+func invalidPathErr(op, name string) error {
+	return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("open", name)
+	}
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, fromAferoErr("open", name, err)
+	}
+	return &file{file: f, name: name}, nil
+}
+
+// Create implements hackpadfs.CreateFS
+func (fs *FS) Create(name string) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("open", name)
+	}
+	f, err := fs.fs.Create(name)
+	if err != nil {
+		return nil, fromAferoErr("open", name, err)
+	}
+	return &file{file: f, name: name}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("open", name)
+	}
+	f, err := fs.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, fromAferoErr("open", name, err)
+	}
+	return &file{file: f, name: name}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("mkdir", name)
+	}
+	return fromAferoErr("mkdir", name, fs.fs.Mkdir(name, perm))
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(path) {
+		return invalidPathErr("mkdir", path)
+	}
+	return fromAferoErr("mkdir", path, fs.fs.MkdirAll(path, perm))
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("remove", name)
+	}
+	return fromAferoErr("remove", name, fs.fs.Remove(name))
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("removeall", name)
+	}
+	return fromAferoErr("removeall", name, fs.fs.RemoveAll(name))
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	if !hackpadfs.ValidPath(oldname) || !hackpadfs.ValidPath(newname) {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	return fromAferoLinkErr("rename", oldname, newname, fs.fs.Rename(oldname, newname))
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("stat", name)
+	}
+	info, err := fs.fs.Stat(name)
+	return info, fromAferoErr("stat", name, err)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("lstat", name)
+	}
+	lstater, ok := fs.fs.(afero.Lstater)
+	if !ok {
+		return nil, &hackpadfs.PathError{Op: "lstat", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	info, lstatCalled, err := lstater.LstatIfPossible(name)
+	if !lstatCalled {
+		// wrappers like afero.BasePathFs implement Lstater even if the underlying afero.Fs does not
+		return nil, &hackpadfs.PathError{Op: "lstat", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	return info, fromAferoErr("lstat", name, err)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("chmod", name)
+	}
+	return fromAferoErr("chmod", name, fs.fs.Chmod(name, mode))
+}
+
+// Chown implements hackpadfs.ChownFS. A uid or gid of -1 leaves that value unchanged. Other negative IDs fail with hackpadfs.ErrInvalid.
+func (fs *FS) Chown(name string, uid, gid int) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("chown", name)
+	}
+	if uid < -1 || gid < -1 {
+		return &hackpadfs.PathError{Op: "chown", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	return fromAferoErr("chown", name, fs.fs.Chown(name, uid, gid))
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("chtimes", name)
+	}
+	return fromAferoErr("chtimes", name, fs.fs.Chtimes(name, atime, mtime))
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	if !hackpadfs.ValidPath(newname) {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	linker, ok := fs.fs.(afero.Linker)
+	if !ok {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: hackpadfs.ErrNotImplemented}
+	}
+	return fromAferoLinkErr("symlink", oldname, newname, linker.SymlinkIfPossible(oldname, newname))
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	if !hackpadfs.ValidPath(name) {
+		return "", invalidPathErr("readlink", name)
+	}
+	linkReader, ok := fs.fs.(afero.LinkReader)
+	if !ok {
+		return "", &hackpadfs.PathError{Op: "readlink", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	target, err := linkReader.ReadlinkIfPossible(name)
+	return target, fromAferoErr("readlink", name, err)
+}
+
+// file serves an afero.File as a hackpadfs File
+type file struct {
+	file afero.File
+	name string
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	return n, f.wrapErr("read", err)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.file.ReadAt(p, off)
+	return n, f.wrapErr("read", err)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	return n, f.wrapErr("write", err)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	n, err := f.file.WriteAt(p, off)
+	return n, f.wrapErr("write", err)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.file.Seek(offset, whence)
+	return n, f.wrapErr("seek", err)
+}
+
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	infos, err := f.file.Readdir(n)
+	entries := make([]hackpadfs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, gofs.FileInfoToDirEntry(info))
+	}
+	return entries, f.wrapErr("readdir", err)
+}
+
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	info, err := f.file.Stat()
+	return info, f.wrapErr("stat", err)
+}
+
+func (f *file) Sync() error {
+	return f.wrapErr("sync", f.file.Sync())
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.wrapErr("truncate", f.file.Truncate(size))
+}
+
+func (f *file) Close() error {
+	return f.wrapErr("close", f.file.Close())
+}
+
+// wrapErr converts afero errors to hackpadfs errors, except io.EOF
+func (f *file) wrapErr(op string, err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return fromAferoErr(op, f.name, err)
+}
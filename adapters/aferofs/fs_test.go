@@ -0,0 +1,97 @@
+package aferofs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"github.com/spf13/afero"
+)
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+func TestFromAferoMemMapFs(t *testing.T) {
+	t.Parallel()
+	fs := FromAfero(afero.NewMemMapFs())
+	requireNoError(t, fs.Mkdir("foo", 0700))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+
+	data, err := hackpadfs.ReadFile(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+	entries, err := hackpadfs.ReadDir(fs, "foo")
+	requireNoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "bar", entries[0].Name())
+	}
+
+	_, err = fs.Stat("foo/baz")
+	assert.Equal(t, &hackpadfs.PathError{Op: "open", Path: "foo/baz", Err: hackpadfs.ErrNotExist}, err)
+	_, err = fs.Open("/foo")
+	assert.Equal(t, &hackpadfs.PathError{Op: "open", Path: "/foo", Err: hackpadfs.ErrInvalid}, err)
+
+	// afero.MemMapFs does not support symlinks
+	_, err = fs.Lstat("foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+	err = fs.Symlink("bar", "foo/baz")
+	assert.Equal(t, &hackpadfs.LinkError{Op: "symlink", Old: "bar", New: "foo/baz", Err: hackpadfs.ErrNotImplemented}, err)
+	_, err = fs.Readlink("foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+
+	f, err := fs.Open("foo/bar")
+	requireNoError(t, err)
+	requireNoError(t, f.Close())
+	_, err = f.Read(make([]byte, 1))
+	assert.Equal(t, &hackpadfs.PathError{Op: "read", Path: "foo/bar", Err: hackpadfs.ErrClosed}, err)
+}
+
+func TestFromAferoOsFs(t *testing.T) {
+	t.Parallel()
+	oldmask := setUmask(0)
+	t.Cleanup(func() {
+		setUmask(oldmask)
+	})
+	options := fstest.FSOptions{
+		Name: "aferofs OsFs",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return FromAfero(afero.NewBasePathFs(afero.NewOsFs(), tb.TempDir()))
+		},
+		Constraints: fstest.Constraints{
+			AllowUnenforcedPermissions: true, // the root user is not denied at all
+		},
+		ShouldSkip: func(facets fstest.Facets) bool {
+			// afero.BasePathFs stores symlink targets as real paths, resolving relative targets against the base path instead of the link's directory
+			return strings.HasSuffix(facets.Name, "/fs.Readlink/read_symlink_target") ||
+				strings.HasSuffix(facets.Name, "/fs.Readlink/read_dangling_symlink_target") ||
+				strings.HasSuffix(facets.Name, "/fs.Symlink/relative_target_in_nested_directory") ||
+				strings.HasSuffix(facets.Name, "/fs.Symlink/relative_target_in_parent_directory")
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestToAfero(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "aferofs round trip",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := mem.NewFS()
+			requireNoError(tb, err)
+			return FromAfero(ToAfero(fs))
+		},
+		Constraints: fstest.Constraints{
+			AllowUnenforcedPermissions: true,
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
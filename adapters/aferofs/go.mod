@@ -0,0 +1,12 @@
+module github.com/hack-pad/hackpadfs/adapters/aferofs
+
+go 1.18
+
+require (
+	github.com/hack-pad/hackpadfs v0.1.1
+	github.com/spf13/afero v1.9.5
+)
+
+require golang.org/x/text v0.6.0 // indirect
+
+replace github.com/hack-pad/hackpadfs => ../../
@@ -0,0 +1,491 @@
+package billyfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/hack-pad/hackpadfs"
+)
+
+const (
+	// defaultDirMode is the mode of parent directories created automatically, like billy's osfs
+	defaultDirMode = 0755
+)
+
+var (
+	_ interface {
+		billy.Filesystem
+		billy.Change
+		billy.Capable
+	} = &Filesystem{}
+	_ interface {
+		billy.File
+		io.WriterAt
+	} = &billyFile{}
+)
+
+// Filesystem serves a hackpadfs FS as a billy.Filesystem, so go-git can clone or check out repositories into any FS.
+// Paths may be absolute or relative, and are converted to FS paths by cleaning them and removing leading slashes.
+// Paths which cross above the root fail with billy.ErrCrossedBoundary, like other billy.Filesystems.
+//
+// Like billy's osfs, creating files, renaming, and creating symlinks create any missing parent directories.
+// Errors report the path as it was passed in, and are compatible with os.IsNotExist() and similar checks.
+// Operations the FS does not implement fail with billy.ErrNotSupported.
+type Filesystem struct {
+	fs   hackpadfs.FS
+	root string
+}
+
+// ToBilly returns a billy.Filesystem served by 'fs'
+func ToBilly(fs hackpadfs.FS) *Filesystem {
+	return &Filesystem{fs: fs, root: "."}
+}
+
+// fsPath converts a billy path to an FS path below the root, like "/a/../b" to "b"
+func (fs *Filesystem) fsPath(name string) (string, error) {
+	name = path.Clean(filepath.ToSlash(name))
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", billy.ErrCrossedBoundary
+	}
+	return path.Join(fs.root, strings.TrimLeft(name, "/")), nil
+}
+
+// billyPath converts the FS path 'fsPath' reported by an error to a billy path, reporting 'name' if it's the same file
+func (fs *Filesystem) billyPath(name, fsPath string) string {
+	if p, err := fs.fsPath(name); err == nil && p == fsPath {
+		return name
+	}
+	if fs.root != "." {
+		fsPath = strings.TrimPrefix(fsPath, fs.root+"/")
+	}
+	if strings.HasPrefix(name, "/") {
+		return "/" + fsPath
+	}
+	return fsPath
+}
+
+// toBillyErr converts hackpadfs errors to billy errors, reporting 'name' as the path if the FS reported its FS path
+func (fs *Filesystem) toBillyErr(name string, err error) error {
+	var pathErr *hackpadfs.PathError
+	var linkErr *hackpadfs.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		return &os.PathError{Op: pathErr.Op, Path: fs.billyPath(name, pathErr.Path), Err: toBillyErrno(pathErr.Err)}
+	case errors.As(err, &linkErr):
+		return &os.LinkError{Op: linkErr.Op, Old: linkErr.Old, New: linkErr.New, Err: toBillyErrno(linkErr.Err)}
+	default:
+		return err
+	}
+}
+
+func toBillyLinkErr(oldname, newname string, err error) error {
+	var pathErr *hackpadfs.PathError
+	var linkErr *hackpadfs.LinkError
+	switch {
+	case errors.As(err, &linkErr):
+		return &os.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: toBillyErrno(linkErr.Err)}
+	case errors.As(err, &pathErr):
+		return &os.LinkError{Op: pathErr.Op, Old: oldname, New: newname, Err: toBillyErrno(pathErr.Err)}
+	default:
+		return err
+	}
+}
+
+func toBillyErrno(err error) error {
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return billy.ErrNotSupported
+	}
+	return err
+}
+
+// mkdirParent creates the parent directories of FS path 'p', if they do not exist
+func (fs *Filesystem) mkdirParent(name, p string) error {
+	dir := path.Dir(p)
+	if dir == "." {
+		return nil
+	}
+	if err := hackpadfs.MkdirAll(fs.fs, dir, defaultDirMode); err != nil {
+		return fs.toBillyErr(name, err)
+	}
+	return nil
+}
+
+// Create implements billy.Basic
+func (fs *Filesystem) Create(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Open implements billy.Basic
+func (fs *Filesystem) Open(filename string) (billy.File, error) {
+	return fs.OpenFile(filename, os.O_RDONLY, 0)
+}
+
+// OpenFile implements billy.Basic
+func (fs *Filesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	p, err := fs.fsPath(filename)
+	if err != nil {
+		return nil, err
+	}
+	if flag&os.O_CREATE != 0 {
+		if err := fs.mkdirParent(filename, p); err != nil {
+			return nil, err
+		}
+	}
+	f, err := hackpadfs.OpenFile(fs.fs, p, flag, perm)
+	if err != nil {
+		return nil, fs.toBillyErr(filename, err)
+	}
+	name := filepath.FromSlash(strings.TrimLeft(path.Clean(filepath.ToSlash(filename)), "/"))
+	return &billyFile{fs: fs, file: f, name: name, path: p}, nil
+}
+
+// Stat implements billy.Basic
+func (fs *Filesystem) Stat(filename string) (os.FileInfo, error) {
+	p, err := fs.fsPath(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := hackpadfs.Stat(fs.fs, p)
+	if err != nil {
+		return nil, fs.toBillyErr(filename, err)
+	}
+	return info, nil
+}
+
+// Rename implements billy.Basic
+func (fs *Filesystem) Rename(oldpath, newpath string) error {
+	oldP, err := fs.fsPath(oldpath)
+	if err != nil {
+		return err
+	}
+	newP, err := fs.fsPath(newpath)
+	if err != nil {
+		return err
+	}
+	if err := fs.mkdirParent(newpath, newP); err != nil {
+		return err
+	}
+	if err := hackpadfs.Rename(fs.fs, oldP, newP); err != nil {
+		return toBillyLinkErr(oldpath, newpath, err)
+	}
+	return nil
+}
+
+// Remove implements billy.Basic
+func (fs *Filesystem) Remove(filename string) error {
+	p, err := fs.fsPath(filename)
+	if err != nil {
+		return err
+	}
+	if err := hackpadfs.Remove(fs.fs, p); err != nil {
+		return fs.toBillyErr(filename, err)
+	}
+	return nil
+}
+
+// Join implements billy.Basic
+func (fs *Filesystem) Join(elem ...string) string {
+	return filepath.Join(elem...)
+}
+
+// TempFile implements billy.TempFile
+func (fs *Filesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return util.TempFile(fs, dir, prefix)
+}
+
+// ReadDir implements billy.Dir
+func (fs *Filesystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	p, err := fs.fsPath(dirname)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := hackpadfs.ReadDir(fs.fs, p)
+	if err != nil {
+		return nil, fs.toBillyErr(dirname, err)
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fs.toBillyErr(dirname, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// MkdirAll implements billy.Dir
+func (fs *Filesystem) MkdirAll(filename string, perm os.FileMode) error {
+	p, err := fs.fsPath(filename)
+	if err != nil {
+		return err
+	}
+	if err := hackpadfs.MkdirAll(fs.fs, p, perm); err != nil {
+		return fs.toBillyErr(filename, err)
+	}
+	return nil
+}
+
+// Lstat implements billy.Symlink. Falls back to Stat if the FS does not support Lstat.
+func (fs *Filesystem) Lstat(filename string) (os.FileInfo, error) {
+	p, err := fs.fsPath(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := hackpadfs.LstatOrStat(fs.fs, p)
+	if err != nil {
+		return nil, fs.toBillyErr(filename, err)
+	}
+	return info, nil
+}
+
+// Symlink implements billy.Symlink. The target is stored as-is.
+func (fs *Filesystem) Symlink(target, link string) error {
+	p, err := fs.fsPath(link)
+	if err != nil {
+		return err
+	}
+	if err := fs.mkdirParent(link, p); err != nil {
+		return err
+	}
+	if err := hackpadfs.Symlink(fs.fs, target, p); err != nil {
+		return toBillyLinkErr(target, link, err)
+	}
+	return nil
+}
+
+// Readlink implements billy.Symlink
+func (fs *Filesystem) Readlink(link string) (string, error) {
+	p, err := fs.fsPath(link)
+	if err != nil {
+		return "", err
+	}
+	target, err := hackpadfs.Readlink(fs.fs, p)
+	if err != nil {
+		return "", fs.toBillyErr(link, err)
+	}
+	return target, nil
+}
+
+// Chroot implements billy.Chroot
+func (fs *Filesystem) Chroot(dir string) (billy.Filesystem, error) {
+	p, err := fs.fsPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Filesystem{fs: fs.fs, root: p}, nil
+}
+
+// Root implements billy.Chroot
+func (fs *Filesystem) Root() string {
+	return path.Join("/", fs.root)
+}
+
+// Chmod implements billy.Change
+func (fs *Filesystem) Chmod(name string, mode os.FileMode) error {
+	p, err := fs.fsPath(name)
+	if err != nil {
+		return err
+	}
+	if err := hackpadfs.Chmod(fs.fs, p, mode); err != nil {
+		return fs.toBillyErr(name, err)
+	}
+	return nil
+}
+
+// Lchown implements billy.Change. Always fails with billy.ErrNotSupported, since FSs can only change a symlink target's owner.
+func (fs *Filesystem) Lchown(name string, uid, gid int) error {
+	return &os.PathError{Op: "lchown", Path: name, Err: billy.ErrNotSupported}
+}
+
+// Chown implements billy.Change
+func (fs *Filesystem) Chown(name string, uid, gid int) error {
+	p, err := fs.fsPath(name)
+	if err != nil {
+		return err
+	}
+	if err := hackpadfs.Chown(fs.fs, p, uid, gid); err != nil {
+		return fs.toBillyErr(name, err)
+	}
+	return nil
+}
+
+// Chtimes implements billy.Change
+func (fs *Filesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	p, err := fs.fsPath(name)
+	if err != nil {
+		return err
+	}
+	if err := hackpadfs.Chtimes(fs.fs, p, atime, mtime); err != nil {
+		return fs.toBillyErr(name, err)
+	}
+	return nil
+}
+
+// Capabilities implements billy.Capable. Files can only be locked if the FS is a hackpadfs.LockFS.
+func (fs *Filesystem) Capabilities() billy.Capability {
+	capabilities := billy.WriteCapability |
+		billy.ReadCapability |
+		billy.ReadAndWriteCapability |
+		billy.SeekCapability |
+		billy.TruncateCapability
+	if _, ok := fs.fs.(hackpadfs.LockFS); ok {
+		capabilities |= billy.LockCapability
+	}
+	return capabilities
+}
+
+// billyFile serves a hackpadfs File as a billy.File.
+// Once closed, all operations fail with os.ErrClosed like OS files, since some FSs' files still permit operations like Seek.
+type billyFile struct {
+	fs   *Filesystem
+	file hackpadfs.File
+	name string
+	path string
+
+	mu       sync.Mutex
+	closed   bool
+	unlocker hackpadfs.Unlocker
+}
+
+// Name returns the file's cleaned path from the root, like billy's memfs
+func (f *billyFile) Name() string {
+	return f.name
+}
+
+func (f *billyFile) Read(p []byte) (int, error) {
+	if err := f.checkClosed("read"); err != nil {
+		return 0, err
+	}
+	n, err := f.file.Read(p)
+	return n, f.wrapErr(err)
+}
+
+// ReadAt reads len(p) bytes at 'off'. Reads which end exactly at the end of the file do not return io.EOF, like OS files.
+func (f *billyFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.checkClosed("read"); err != nil {
+		return 0, err
+	}
+	n, err := hackpadfs.ReadAtFile(f.file, p, off)
+	if err == io.EOF && n == len(p) {
+		err = nil
+	}
+	return n, f.wrapErr(err)
+}
+
+func (f *billyFile) Write(p []byte) (int, error) {
+	if err := f.checkClosed("write"); err != nil {
+		return 0, err
+	}
+	n, err := hackpadfs.WriteFile(f.file, p)
+	return n, f.wrapErr(err)
+}
+
+func (f *billyFile) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.checkClosed("writeat"); err != nil {
+		return 0, err
+	}
+	n, err := hackpadfs.WriteAtFile(f.file, p, off)
+	return n, f.wrapErr(err)
+}
+
+func (f *billyFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.checkClosed("seek"); err != nil {
+		return 0, err
+	}
+	n, err := hackpadfs.SeekFile(f.file, offset, whence)
+	return n, f.wrapErr(err)
+}
+
+func (f *billyFile) Truncate(size int64) error {
+	if err := f.checkClosed("truncate"); err != nil {
+		return err
+	}
+	return f.wrapErr(hackpadfs.TruncateFile(f.file, size))
+}
+
+func (f *billyFile) Stat() (os.FileInfo, error) {
+	if err := f.checkClosed("stat"); err != nil {
+		return nil, err
+	}
+	info, err := f.file.Stat()
+	return info, f.wrapErr(err)
+}
+
+// Close releases the file's lock, like closing an OS file
+func (f *billyFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+	unlockErr := f.unlock()
+	err := f.wrapErr(f.file.Close())
+	if err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+// Lock acquires an exclusive lock if the FS supports locking, otherwise it does nothing, like billy's memfs.
+// Unlike billy's osfs, Lock does not wait for a conflicting lock to be released and fails with hackpadfs.ErrWouldBlock instead.
+func (f *billyFile) Lock() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "lock", Path: f.name, Err: os.ErrClosed}
+	}
+	if f.unlocker != nil {
+		return nil
+	}
+	unlocker, err := hackpadfs.Lock(f.fs.fs, f.path, hackpadfs.LockExclusive)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return nil
+	}
+	if err != nil {
+		return f.wrapErr(err)
+	}
+	f.unlocker = unlocker
+	return nil
+}
+
+func (f *billyFile) Unlock() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.unlock()
+}
+
+func (f *billyFile) unlock() error {
+	if f.unlocker == nil {
+		return nil
+	}
+	err := f.unlocker.Unlock()
+	f.unlocker = nil
+	return f.wrapErr(err)
+}
+
+func (f *billyFile) checkClosed(op string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	}
+	return nil
+}
+
+// wrapErr converts hackpadfs errors to billy errors, except io.EOF
+func (f *billyFile) wrapErr(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return f.fs.toBillyErr(f.name, err)
+}
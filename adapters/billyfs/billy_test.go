@@ -0,0 +1,92 @@
+package billyfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/test"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"gopkg.in/check.v1"
+)
+
+func TestToBillySuite(t *testing.T) {
+	t.Parallel()
+	check.TestingT(t)
+}
+
+// billySuite runs billy's own test suite, which every billy.Filesystem should pass
+type billySuite struct {
+	test.FilesystemSuite
+}
+
+var _ = check.Suite(&billySuite{})
+
+func (s *billySuite) SetUpTest(c *check.C) {
+	fs, err := mem.NewFS()
+	c.Assert(err, check.IsNil)
+	s.FilesystemSuite = test.NewFilesystemSuite(ToBilly(fs))
+}
+
+func TestToBillyMem(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	fs := ToBilly(memFS)
+
+	_, err = fs.Stat("/foo")
+	assert.Equal(t, true, os.IsNotExist(err))
+	assert.Equal(t, &os.PathError{Op: "stat", Path: "/foo", Err: hackpadfs.ErrNotExist}, err)
+	_, err = fs.Open("../foo")
+	assert.Equal(t, billy.ErrCrossedBoundary, err)
+	err = fs.Lchown("foo", 0, 0)
+	assert.Equal(t, &os.PathError{Op: "lchown", Path: "foo", Err: billy.ErrNotSupported}, err)
+
+	f, err := fs.Create("/foo/bar")
+	requireNoError(t, err)
+	assert.Equal(t, filepath.Join("foo", "bar"), f.Name())
+	requireNoError(t, f.Close())
+	assert.Equal(t, &os.PathError{Op: "close", Path: filepath.Join("foo", "bar"), Err: os.ErrClosed}, f.Close())
+
+	chroot, err := fs.Chroot("foo")
+	requireNoError(t, err)
+	assert.Equal(t, "/foo", chroot.Root())
+	info, err := chroot.Stat("bar")
+	requireNoError(t, err)
+	assert.Equal(t, "bar", info.Name())
+	_, err = chroot.Stat("../foo/bar")
+	assert.Equal(t, billy.ErrCrossedBoundary, err)
+}
+
+func TestToBillyLock(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	fs := ToBilly(memFS)
+	assert.Equal(t, billy.LockCapability, fs.Capabilities()&billy.LockCapability)
+	requireNoError(t, util.WriteFile(fs, "foo", []byte("foo"), 0600))
+
+	f1, err := fs.Open("foo")
+	requireNoError(t, err)
+	f2, err := fs.Open("foo")
+	requireNoError(t, err)
+	assert.NoError(t, f1.Lock())
+	assert.ErrorIs(t, hackpadfs.ErrWouldBlock, f2.Lock())
+	assert.NoError(t, f1.Close()) // releases the lock
+	assert.NoError(t, f2.Lock())
+	assert.NoError(t, f2.Unlock())
+	assert.NoError(t, f2.Close())
+
+	// locks are no-ops if the FS can't lock files
+	minimalFS := ToBilly(struct{ hackpadfs.FS }{memFS})
+	assert.Equal(t, billy.Capability(0), minimalFS.Capabilities()&billy.LockCapability)
+	f, err := minimalFS.Open("foo")
+	requireNoError(t, err)
+	assert.NoError(t, f.Lock())
+	assert.NoError(t, f.Unlock())
+	assert.NoError(t, f.Close())
+}
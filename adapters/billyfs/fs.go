@@ -0,0 +1,434 @@
+// Package billyfs adapts file systems between hackpadfs and go-billy, so go-git can clone or check out repositories into any FS.
+//
+// ToBilly serves a hackpadfs FS as a billy.Filesystem, and FromBilly serves a billy.Filesystem as a hackpadfs FS.
+package billyfs
+
+import (
+	"errors"
+	"io"
+	gofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.SymlinkFS
+		hackpadfs.ReadlinkFS
+	} = &FS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+	} = &file{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.DirReaderFile
+	} = &dirFile{}
+)
+
+// FS serves a billy.Filesystem as a hackpadfs FS. Use polyfill.New() to serve a billy.Basic.
+// Paths are validated, then passed to the billy.Filesystem unchanged.
+//
+// Unlike billy.Filesystems, creating files, renaming, and creating symlinks fail if the parent directory does not exist.
+// Chmod, Chown, and Chtimes are supported if the billy.Filesystem implements billy.Change.
+// Otherwise, they fail with hackpadfs.ErrNotImplemented.
+type FS struct {
+	fs billy.Filesystem
+}
+
+// FromBilly returns an FS served by 'fs'
+func FromBilly(fs billy.Filesystem) *FS {
+	return &FS{fs: fs}
+}
+
+// fromBillyErr converts billy errors to hackpadfs errors, reporting 'name' as the path
+func fromBillyErr(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pathErr *hackpadfs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.As(err, &pathErr):
+		op, name, err = pathErr.Op, errPath(name, pathErr.Path), pathErr.Err
+	case errors.As(err, &linkErr):
+		op, err = linkErr.Op, linkErr.Err
+	}
+	switch err {
+	case billy.ErrNotSupported:
+		err = hackpadfs.ErrNotImplemented
+	case billy.ErrReadOnly, billy.ErrCrossedBoundary:
+		err = hackpadfs.ErrPermission
+	case os.ErrClosed:
+		err = hackpadfs.ErrClosed
+	}
+	return &hackpadfs.PathError{Op: op, Path: name, Err: err}
+}
+
+// errPath returns the part of 'name' reported by a billy error's path, like a parent directory.
+// Some billy.Filesystems report real paths, like osfs, so the path only needs to end with it.
+func errPath(name, billyPath string) string {
+	billyPath = filepath.ToSlash(billyPath)
+	for p := name; p != "."; p = path.Dir(p) {
+		if billyPath == p || strings.HasSuffix(billyPath, "/"+p) {
+			return p
+		}
+	}
+	return name
+}
+
+func fromBillyLinkErr(op, oldname, newname string, err error) error {
+	if err == nil {
+		return nil
+	}
+	err = fromBillyErr(op, newname, err)
+	pathErr := err.(*hackpadfs.PathError)
+	return &hackpadfs.LinkError{Op: pathErr.Op, Old: oldname, New: newname, Err: pathErr.Err}
+}
+
+func invalidPathErr(op, name string) error {
+	return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+}
+
+// checkParent fails if the parent directory of 'name' does not exist, since billy.Filesystems create missing parents
+func (fs *FS) checkParent(op, name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	info, err := fs.fs.Stat(dir)
+	if err != nil {
+		err = fromBillyErr(op, name, err)
+		return &hackpadfs.PathError{Op: op, Path: name, Err: err.(*hackpadfs.PathError).Err}
+	}
+	if !info.IsDir() {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotDir}
+	}
+	return nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS. Directories are served by the billy.Filesystem's ReadDir, so they can't be opened for writing.
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("open", name)
+	}
+	info, err := fs.fs.Stat(name)
+	switch {
+	case err == nil && info.IsDir():
+		if flag&(hackpadfs.FlagWriteOnly|hackpadfs.FlagReadWrite) != 0 {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrIsDir}
+		}
+		if flag&(hackpadfs.FlagCreate|hackpadfs.FlagExclusive) == hackpadfs.FlagCreate|hackpadfs.FlagExclusive {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrExist}
+		}
+		return &dirFile{fs: fs, name: name}, nil
+	case os.IsNotExist(err) && flag&hackpadfs.FlagCreate != 0:
+		if err := fs.checkParent("open", name); err != nil {
+			return nil, err
+		}
+	}
+	f, err := fs.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, fromBillyErr("open", name, err)
+	}
+	return &file{fs: fs, file: f, name: name}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("mkdir", name)
+	}
+	_, err := fs.Lstat(name)
+	switch {
+	case err == nil:
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrExist}
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return fromBillyErr("mkdir", name, err)
+	}
+	if err := fs.checkParent("mkdir", name); err != nil {
+		return err
+	}
+	return fromBillyErr("mkdir", name, fs.fs.MkdirAll(name, perm))
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(path) {
+		return invalidPathErr("mkdir", path)
+	}
+	return fromBillyErr("mkdir", path, fs.fs.MkdirAll(path, perm))
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("remove", name)
+	}
+	return fromBillyErr("remove", name, fs.fs.Remove(name))
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("removeall", name)
+	}
+	return fromBillyErr("removeall", name, util.RemoveAll(fs.fs, name))
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	if !hackpadfs.ValidPath(oldname) || !hackpadfs.ValidPath(newname) {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	if err := fs.checkParent("rename", newname); err != nil {
+		return fromBillyLinkErr("rename", oldname, newname, err)
+	}
+	return fromBillyLinkErr("rename", oldname, newname, fs.fs.Rename(oldname, newname))
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("stat", name)
+	}
+	info, err := fs.fs.Stat(name)
+	return info, fromBillyErr("stat", name, err)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("lstat", name)
+	}
+	info, err := fs.fs.Lstat(name)
+	return info, fromBillyErr("lstat", name, err)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, invalidPathErr("readdir", name)
+	}
+	infos, err := fs.fs.ReadDir(name)
+	if err != nil {
+		return nil, fromBillyErr("readdir", name, err)
+	}
+	entries := make([]hackpadfs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, gofs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("chmod", name)
+	}
+	changer, ok := fs.fs.(billy.Change)
+	if !ok {
+		return &hackpadfs.PathError{Op: "chmod", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	return fromBillyErr("chmod", name, changer.Chmod(name, mode))
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("chown", name)
+	}
+	changer, ok := fs.fs.(billy.Change)
+	if !ok {
+		return &hackpadfs.PathError{Op: "chown", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	return fromBillyErr("chown", name, changer.Chown(name, uid, gid))
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !hackpadfs.ValidPath(name) {
+		return invalidPathErr("chtimes", name)
+	}
+	changer, ok := fs.fs.(billy.Change)
+	if !ok {
+		return &hackpadfs.PathError{Op: "chtimes", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	return fromBillyErr("chtimes", name, changer.Chtimes(name, atime, mtime))
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	if !hackpadfs.ValidPath(newname) {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	if err := fs.checkParent("symlink", newname); err != nil {
+		return fromBillyLinkErr("symlink", oldname, newname, err)
+	}
+	return fromBillyLinkErr("symlink", oldname, newname, fs.fs.Symlink(oldname, newname))
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	if !hackpadfs.ValidPath(name) {
+		return "", invalidPathErr("readlink", name)
+	}
+	target, err := fs.fs.Readlink(name)
+	return target, fromBillyErr("readlink", name, err)
+}
+
+// file serves a billy.File as a hackpadfs File.
+// WriteAt, Sync, and Stat are served by the billy.File if it implements them, like billy's osfs.
+type file struct {
+	fs   *FS
+	file billy.File
+	name string
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	return n, f.wrapErr("read", err)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.file.ReadAt(p, off)
+	return n, f.wrapErr("read", err)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	return n, f.wrapErr("write", err)
+}
+
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	writerAt, ok := f.file.(io.WriterAt)
+	if !ok {
+		return 0, &hackpadfs.PathError{Op: "writeat", Path: f.name, Err: hackpadfs.ErrNotImplemented}
+	}
+	n, err := writerAt.WriteAt(p, off)
+	return n, f.wrapErr("writeat", err)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.file.Seek(offset, whence)
+	return n, f.wrapErr("seek", err)
+}
+
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	statter, ok := f.file.(interface{ Stat() (os.FileInfo, error) })
+	if !ok {
+		return f.fs.Stat(f.name)
+	}
+	info, err := statter.Stat()
+	return info, f.wrapErr("stat", err)
+}
+
+func (f *file) Sync() error {
+	syncer, ok := f.file.(interface{ Sync() error })
+	if !ok {
+		return &hackpadfs.PathError{Op: "sync", Path: f.name, Err: hackpadfs.ErrNotImplemented}
+	}
+	return f.wrapErr("sync", syncer.Sync())
+}
+
+func (f *file) Truncate(size int64) error {
+	return f.wrapErr("truncate", f.file.Truncate(size))
+}
+
+func (f *file) Close() error {
+	return f.wrapErr("close", f.file.Close())
+}
+
+// wrapErr converts billy errors to hackpadfs errors, except io.EOF
+func (f *file) wrapErr(op string, err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+	return fromBillyErr(op, f.name, err)
+}
+
+// dirFile serves a directory, since billy.Files can only be read with the billy.Filesystem's ReadDir
+type dirFile struct {
+	fs   *FS
+	name string
+
+	mu      sync.Mutex
+	closed  bool
+	entries []hackpadfs.DirEntry
+	read    bool
+}
+
+func (d *dirFile) Read(p []byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.name, Err: hackpadfs.ErrIsDir}
+}
+
+// ReadDir reads the directory's entries on the first call, then returns them in pages of 'n'
+func (d *dirFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	if !d.read {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.read = true
+	}
+	if n > 0 && len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *dirFile) Stat() (hackpadfs.FileInfo, error) {
+	return d.fs.Stat(d.name)
+}
+
+func (d *dirFile) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return &hackpadfs.PathError{Op: "close", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
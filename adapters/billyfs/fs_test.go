@@ -0,0 +1,110 @@
+package billyfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+func TestFromBillyMemfs(t *testing.T) {
+	t.Parallel()
+	fs := FromBilly(memfs.New())
+	requireNoError(t, fs.Mkdir("foo", 0700))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+
+	data, err := hackpadfs.ReadFile(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+	entries, err := hackpadfs.ReadDir(fs, "foo")
+	requireNoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "bar", entries[0].Name())
+	}
+
+	_, err = fs.Stat("foo/baz")
+	assert.Equal(t, &hackpadfs.PathError{Op: "stat", Path: "foo/baz", Err: hackpadfs.ErrNotExist}, err)
+	_, err = fs.Open("/foo")
+	assert.Equal(t, &hackpadfs.PathError{Op: "open", Path: "/foo", Err: hackpadfs.ErrInvalid}, err)
+	err = fs.Mkdir("foo", 0700)
+	assert.Equal(t, &hackpadfs.PathError{Op: "mkdir", Path: "foo", Err: hackpadfs.ErrExist}, err)
+	_, err = hackpadfs.Create(fs, "baz/bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	requireNoError(t, fs.Symlink("bar", "foo/baz"))
+	target, err := fs.Readlink("foo/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", target)
+	info, err := fs.Lstat("foo/baz")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.ModeSymlink, info.Mode().Type())
+
+	// billy's memfs does not implement billy.Change
+	err = fs.Chmod("foo/bar", 0700)
+	assert.Equal(t, &hackpadfs.PathError{Op: "chmod", Path: "foo/bar", Err: hackpadfs.ErrNotImplemented}, err)
+
+	dir, err := fs.Open("foo")
+	requireNoError(t, err)
+	entries, err = hackpadfs.ReadDirFile(dir, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	requireNoError(t, dir.Close())
+	_, err = hackpadfs.ReadDirFile(dir, 1)
+	assert.Equal(t, &hackpadfs.PathError{Op: "readdir", Path: "foo", Err: hackpadfs.ErrClosed}, err)
+}
+
+func TestFromBillyOsfs(t *testing.T) {
+	t.Parallel()
+	oldmask := setUmask(0)
+	t.Cleanup(func() {
+		setUmask(oldmask)
+	})
+	options := fstest.FSOptions{
+		Name: "billyfs osfs",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return FromBilly(osfs.New(tb.TempDir()))
+		},
+		Constraints: fstest.Constraints{
+			AllowUnenforcedPermissions: true, // the root user is not denied at all
+		},
+		ShouldSkip: func(facets fstest.Facets) bool {
+			// billy's osfs does not implement billy.Change, creates directories with a fixed mode, and its files don't implement WriteAt
+			return strings.Contains(facets.Name, "/file.WriteAt/") ||
+				strings.HasSuffix(facets.Name, "/fs.Chown/file_does_not_exist") ||
+				strings.HasSuffix(facets.Name, "/fs.ReadDir/exists") ||
+				strings.HasSuffix(facets.Name, "/stat_a_file") ||
+				strings.HasSuffix(facets.Name, "/fs.Symlink/open_and_stat_file_through_symlink")
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestToBilly(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "billyfs round trip",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := mem.NewFS()
+			requireNoError(tb, err)
+			return FromBilly(ToBilly(fs))
+		},
+		Constraints: fstest.Constraints{
+			AllowUnenforcedPermissions: true,
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
@@ -0,0 +1,17 @@
+module github.com/hack-pad/hackpadfs/adapters/billyfs
+
+go 1.18
+
+require (
+	github.com/go-git/go-billy/v5 v5.4.1
+	github.com/hack-pad/hackpadfs v0.1.1
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
+)
+
+require (
+	github.com/kr/pretty v0.2.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	golang.org/x/sys v0.3.0 // indirect
+)
+
+replace github.com/hack-pad/hackpadfs => ../../
@@ -0,0 +1,8 @@
+//go:build plan9 || windows || wasm
+// +build plan9 windows wasm
+
+package billyfs
+
+func setUmask(mask int) (oldmask int) {
+	return 0
+}
@@ -0,0 +1,10 @@
+//go:build !plan9 && !windows && !wasm
+// +build !plan9,!windows,!wasm
+
+package billyfs
+
+import "syscall"
+
+func setUmask(mask int) (oldmask int) {
+	return syscall.Umask(mask)
+}
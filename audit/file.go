@@ -0,0 +1,91 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+// file records every mutation of an open file
+type file struct {
+	hackpadfs.File
+	name string
+	fs   *FS
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	return f.File.Read(p)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	n, err := hackpadfs.WriteFile(f.File, p)
+	return n, f.fs.record(Record{Op: "write", Path: f.name, Size: int64(n), Hash: dataHash(p[:n])}, err)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	n, err := hackpadfs.WriteAtFile(f.File, p, off)
+	return n, f.fs.record(Record{Op: "writeat", Path: f.name, Size: int64(n), Hash: dataHash(p[:n])}, err)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	err := hackpadfs.TruncateFile(f.File, size)
+	return f.fs.record(Record{Op: "truncate", Path: f.name, Size: size}, err)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	err := hackpadfs.ChmodFile(f.File, mode)
+	return f.fs.record(Record{Op: "chmod", Path: f.name}, err)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	err := hackpadfs.ChownFile(f.File, uid, gid)
+	return f.fs.record(Record{Op: "chown", Path: f.name}, err)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	err := hackpadfs.ChtimesFile(f.File, atime, mtime)
+	return f.fs.record(Record{Op: "chtimes", Path: f.name}, err)
+}
@@ -0,0 +1,255 @@
+// Package audit contains a file system wrapper which records every mutation in a tamper-evident journal.
+package audit
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+)
+
+// Options configures an FS
+type Options struct {
+	// Sink receives a record of every mutation. Required.
+	Sink Sink
+	// Principal is the default principal of each record. See FS.WithPrincipal().
+	Principal string
+	// Key, if set, signs each record's digest with HMAC-SHA-256. Without a key, anyone able to rewrite the records can also recompute the digests.
+	Key []byte
+	// Resume continues the chain after this record, like the last record written before a restart.
+	Resume *Record
+}
+
+// FS wraps a file system to record every mutation to Options.Sink, including writes to open files.
+// Reads are not recorded.
+//
+// Records are appended after each mutation finishes, including failed attempts.
+// If the sink fails, the mutation has still happened, but its error is returned so callers know the journal is incomplete.
+type FS struct {
+	fs        hackpadfs.FS
+	principal string
+	chain     *chain
+}
+
+// NewFS returns an FS which records mutations of 'fs' as configured by 'options'
+func NewFS(fs hackpadfs.FS, options Options) (*FS, error) {
+	if options.Sink == nil {
+		return nil, errors.New("audit sink is required")
+	}
+	c := &chain{
+		sink: options.Sink,
+		key:  options.Key,
+		now:  time.Now,
+	}
+	if options.Resume != nil {
+		c.seq = options.Resume.Seq
+		c.prevDigest = options.Resume.Digest
+	}
+	return &FS{
+		fs:        fs,
+		principal: options.Principal,
+		chain:     c,
+	}, nil
+}
+
+// WithPrincipal returns a view of the FS which records mutations as 'principal', like the user behind a request.
+// All views share the same chain of records.
+func (fs *FS) WithPrincipal(principal string) *FS {
+	return &FS{
+		fs:        fs.fs,
+		principal: principal,
+		chain:     fs.chain,
+	}
+}
+
+// record appends 'record' for an operation which returned 'err', then returns the error for the operation
+func (fs *FS) record(record Record, err error) error {
+	record.Principal = fs.principal
+	if err != nil {
+		record.Err = err.Error()
+	}
+	sinkErr := fs.chain.append(record)
+	if err != nil || sinkErr == nil {
+		return err
+	}
+	if record.NewPath != "" {
+		return &hackpadfs.LinkError{Op: record.Op, Old: record.Path, New: record.NewPath, Err: sinkErr}
+	}
+	return &hackpadfs.PathError{Op: record.Op, Path: record.Path, Err: sinkErr}
+}
+
+// flagString formats OpenFile flags like "O_RDWR|O_CREATE"
+func flagString(flag int) string {
+	var names []string
+	switch {
+	case flag&hackpadfs.FlagReadWrite != 0:
+		names = append(names, "O_RDWR")
+	case flag&hackpadfs.FlagWriteOnly != 0:
+		names = append(names, "O_WRONLY")
+	default:
+		names = append(names, "O_RDONLY")
+	}
+	for _, f := range []struct {
+		flag int
+		name string
+	}{
+		{hackpadfs.FlagAppend, "O_APPEND"},
+		{hackpadfs.FlagCreate, "O_CREATE"},
+		{hackpadfs.FlagExclusive, "O_EXCL"},
+		{hackpadfs.FlagSync, "O_SYNC"},
+		{hackpadfs.FlagTruncate, "O_TRUNC"},
+	} {
+		if flag&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+//
+// Records opens which may create or truncate the file.
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	f, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	if flag&(hackpadfs.FlagCreate|hackpadfs.FlagTruncate) != 0 {
+		err = fs.record(Record{Op: "openfile", Path: name, Flag: flagString(flag)}, err)
+	}
+	if err != nil {
+		if f != nil {
+			_ = f.Close()
+		}
+		return nil, err
+	}
+	return &file{File: f, name: name, fs: fs}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	err := hackpadfs.Mkdir(fs.fs, name, perm)
+	return fs.record(Record{Op: "mkdir", Path: name}, err)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	err := hackpadfs.MkdirAll(fs.fs, path, perm)
+	return fs.record(Record{Op: "mkdirall", Path: path}, err)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	err := hackpadfs.Remove(fs.fs, name)
+	return fs.record(Record{Op: "remove", Path: name}, err)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	err := hackpadfs.RemoveAll(fs.fs, path)
+	return fs.record(Record{Op: "removeall", Path: path}, err)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	err := hackpadfs.Rename(fs.fs, oldname, newname)
+	return fs.record(Record{Op: "rename", Path: oldname, NewPath: newname}, err)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.fs, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Lstat(fs.fs, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	err := hackpadfs.Chmod(fs.fs, name, mode)
+	return fs.record(Record{Op: "chmod", Path: name}, err)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	err := hackpadfs.Chown(fs.fs, name, uid, gid)
+	return fs.record(Record{Op: "chown", Path: name}, err)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	err := hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+	return fs.record(Record{Op: "chtimes", Path: name}, err)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.fs, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	return hackpadfs.ReadFile(fs.fs, name)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	err := hackpadfs.Symlink(fs.fs, oldname, newname)
+	return fs.record(Record{Op: "symlink", Path: oldname, NewPath: newname}, err)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	err := hackpadfs.Link(fs.fs, oldname, newname)
+	return fs.record(Record{Op: "link", Path: oldname, NewPath: newname}, err)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.Statfs(fs.fs, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	return hackpadfs.Watch(fs.fs, name)
+}
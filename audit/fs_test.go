@@ -0,0 +1,244 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+// sliceSink collects records in memory
+type sliceSink struct {
+	records []Record
+	err     error
+}
+
+func (s *sliceSink) Append(record Record) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func newFS(tb testing.TB, options Options) *FS {
+	tb.Helper()
+	memFS, err := mem.NewFS()
+	requireNoError(tb, err)
+	fs, err := NewFS(memFS, options)
+	requireNoError(tb, err)
+	fs.chain.now = func() time.Time {
+		return time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "audit",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			var journal bytes.Buffer
+			fs := newFS(tb, Options{Sink: NewJSONSink(&journal)})
+			return fs, func() hackpadfs.FS {
+				return fs
+			}
+		}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestNewFS(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	_, err = NewFS(memFS, Options{})
+	assert.Error(t, err)
+}
+
+type quickRecord struct {
+	Principal, Op, Path, NewPath, Flag string
+	Size                               int64
+	Failed                             bool
+}
+
+func quickRecords(records []Record) []quickRecord {
+	var quick []quickRecord
+	for _, r := range records {
+		quick = append(quick, quickRecord{
+			Principal: r.Principal,
+			Op:        r.Op,
+			Path:      r.Path,
+			NewPath:   r.NewPath,
+			Flag:      r.Flag,
+			Size:      r.Size,
+			Failed:    r.Err != "",
+		})
+	}
+	return quick
+}
+
+func TestRecords(t *testing.T) {
+	t.Parallel()
+	sink := &sliceSink{}
+	fs := newFS(t, Options{Sink: sink, Principal: "system"})
+	alice := fs.WithPrincipal("alice")
+
+	requireNoError(t, fs.Mkdir("dir", 0700))
+	f, err := hackpadfs.Create(alice, "dir/foo")
+	requireNoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("hello"))
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.TruncateFile(f, 2))
+	requireNoError(t, f.Close())
+	_, err = hackpadfs.ReadFile(alice, "dir/foo")
+	requireNoError(t, err)
+	requireNoError(t, alice.Rename("dir/foo", "dir/bar"))
+	err = alice.Remove("dir/foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	requireNoError(t, fs.RemoveAll("dir"))
+
+	assert.Equal(t, []quickRecord{
+		{Principal: "system", Op: "mkdir", Path: "dir"},
+		{Principal: "alice", Op: "openfile", Path: "dir/foo", Flag: "O_RDWR|O_CREATE|O_TRUNC"},
+		{Principal: "alice", Op: "write", Path: "dir/foo", Size: 5},
+		{Principal: "alice", Op: "truncate", Path: "dir/foo", Size: 2},
+		{Principal: "alice", Op: "rename", Path: "dir/foo", NewPath: "dir/bar"},
+		{Principal: "alice", Op: "remove", Path: "dir/foo", Failed: true},
+		{Principal: "system", Op: "removeall", Path: "dir"},
+	}, quickRecords(sink.records))
+	const helloHash = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	assert.Equal(t, helloHash, sink.records[2].Hash)
+	for i, record := range sink.records {
+		assert.Equal(t, uint64(i+1), record.Seq)
+	}
+	assert.NoError(t, Verify(sink.records, nil))
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	newRecords := func(t *testing.T, key []byte) []Record {
+		t.Helper()
+		var journal bytes.Buffer
+		fs := newFS(t, Options{Sink: NewJSONSink(&journal), Key: key})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+		requireNoError(t, fs.Rename("foo", "bar"))
+		requireNoError(t, fs.Remove("bar"))
+		records, err := ReadJSON(&journal)
+		requireNoError(t, err)
+		requireNoError(t, Verify(records, key))
+		return records
+	}
+
+	for _, tc := range []struct {
+		description string
+		tamper      func(records []Record) []Record
+	}{
+		{
+			description: "changed path",
+			tamper: func(records []Record) []Record {
+				records[1].NewPath = "baz"
+				return records
+			},
+		},
+		{
+			description: "removed record",
+			tamper: func(records []Record) []Record {
+				return append(records[:1], records[2:]...)
+			},
+		},
+		{
+			description: "reordered records",
+			tamper: func(records []Record) []Record {
+				records[1], records[2] = records[2], records[1]
+				return records
+			},
+		},
+		{
+			description: "removed first record",
+			tamper: func(records []Record) []Record {
+				records = records[1:]
+				for i := range records {
+					records[i].Seq--
+				}
+				return records
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			records := tc.tamper(newRecords(t, nil))
+			err := Verify(records, nil)
+			assert.ErrorIs(t, ErrTampered, err)
+		})
+	}
+
+	t.Run("key", func(t *testing.T) {
+		t.Parallel()
+		key := []byte("secret")
+		records := newRecords(t, key)
+		assert.ErrorIs(t, ErrTampered, Verify(records, nil))
+		assert.ErrorIs(t, ErrTampered, Verify(records, []byte("wrong")))
+
+		// rewriting a record without the key is detected
+		records[2].Path = "other"
+		digest, err := recordDigest(records[2], nil)
+		requireNoError(t, err)
+		records[2].Digest = digest
+		assert.ErrorIs(t, ErrTampered, Verify(records, key))
+	})
+
+	t.Run("suffix", func(t *testing.T) {
+		t.Parallel()
+		records := newRecords(t, nil)
+		assert.NoError(t, Verify(records[1:], nil))
+	})
+}
+
+func TestResume(t *testing.T) {
+	t.Parallel()
+	sink := &sliceSink{}
+	fs := newFS(t, Options{Sink: sink})
+	requireNoError(t, fs.Mkdir("foo", 0700))
+
+	last := sink.records[len(sink.records)-1]
+	resumed, err := NewFS(fs.fs, Options{Sink: sink, Resume: &last})
+	requireNoError(t, err)
+	requireNoError(t, resumed.Mkdir("bar", 0700))
+	assert.Equal(t, 2, len(sink.records))
+	assert.NoError(t, Verify(sink.records, nil))
+}
+
+func TestSinkError(t *testing.T) {
+	t.Parallel()
+	sinkErr := errors.New("sink failed")
+	sink := &sliceSink{err: sinkErr}
+	fs := newFS(t, Options{Sink: sink})
+
+	err := fs.Mkdir("foo", 0700)
+	assert.ErrorIs(t, sinkErr, err)
+	_, err = fs.Stat("foo")
+	assert.NoError(t, err)
+	err = fs.Rename("foo", "bar")
+	var linkErr *hackpadfs.LinkError
+	assert.Equal(t, true, errors.As(err, &linkErr))
+
+	sink.err = nil
+	requireNoError(t, fs.Mkdir("baz", 0700))
+	assert.Equal(t, uint64(1), sink.records[0].Seq)
+}
@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrTampered is returned by Verify when records were changed, removed, or reordered
+var ErrTampered = errors.New("audit records tampered")
+
+// Record describes one mutation of an FS. Records are chained by their digests, so changes to earlier records are detectable with Verify.
+type Record struct {
+	// Seq is the record's sequence number, starting at 1
+	Seq uint64 `json:"seq"`
+	// Time is when the mutation finished, in UTC
+	Time time.Time `json:"time"`
+	// Principal is who made the mutation. See FS.WithPrincipal().
+	Principal string `json:"principal,omitempty"`
+	// Op is the operation name, like "write" or "rename"
+	Op string `json:"op"`
+	// Path is the path of the mutated file, or the old path for renames and links
+	Path string `json:"path"`
+	// NewPath is the new path for renames and links
+	NewPath string `json:"new_path,omitempty"`
+	// Flag is the OpenFile flags, like "O_RDWR|O_CREATE"
+	Flag string `json:"flag,omitempty"`
+	// Size is the number of bytes written, or the new size for truncates
+	Size int64 `json:"size,omitempty"`
+	// Hash is the hex SHA-256 of the bytes written
+	Hash string `json:"hash,omitempty"`
+	// Err is the error message if the mutation failed
+	Err string `json:"error,omitempty"`
+	// PrevDigest is the previous record's Digest, or empty for the first record
+	PrevDigest string `json:"prev_digest"`
+	// Digest is the hex SHA-256 of this record, or its HMAC-SHA-256 if Options.Key is set
+	Digest string `json:"digest"`
+}
+
+// Sink receives audit records in sequence
+type Sink interface {
+	Append(record Record) error
+}
+
+// JSONSink writes each record as a line of JSON
+type JSONSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONSink returns a Sink which writes JSON lines to 'w'. Read them back with ReadJSON.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{w: w}
+}
+
+// Append implements Sink
+func (s *JSONSink) Append(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// ReadJSON reads records written by a JSONSink
+func ReadJSON(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Verify checks each record's Digest and that 'records' form an unbroken chain, using the same 'key' as Options.Key.
+// If the first record has Seq 1, it must also be the start of the chain. Returns an ErrTampered error describing the first bad record.
+func Verify(records []Record, key []byte) error {
+	for i, record := range records {
+		if i > 0 {
+			prev := records[i-1]
+			if record.Seq != prev.Seq+1 {
+				return fmt.Errorf("%w: record %d follows record %d", ErrTampered, record.Seq, prev.Seq)
+			}
+			if record.PrevDigest != prev.Digest {
+				return fmt.Errorf("%w: record %d: previous digest mismatch", ErrTampered, record.Seq)
+			}
+		} else if record.Seq == 1 && record.PrevDigest != "" {
+			return fmt.Errorf("%w: record 1: first record has a previous digest", ErrTampered)
+		}
+		digest, err := recordDigest(record, key)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(digest), []byte(record.Digest)) {
+			return fmt.Errorf("%w: record %d: digest mismatch", ErrTampered, record.Seq)
+		}
+	}
+	return nil
+}
+
+// recordDigest returns the digest of 'record', ignoring its Digest field
+func recordDigest(record Record, key []byte) (string, error) {
+	record.Digest = ""
+	record.Time = record.Time.UTC()
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	var h hash.Hash
+	if key != nil {
+		h = hmac.New(sha256.New, key)
+	} else {
+		h = sha256.New()
+	}
+	_, _ = h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dataHash returns the hex SHA-256 of 'data'
+func dataHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// chain appends records to a Sink, linking each to the last
+type chain struct {
+	mu         sync.Mutex
+	sink       Sink
+	key        []byte
+	now        func() time.Time
+	seq        uint64
+	prevDigest string
+}
+
+// append fills in the sequence, time, and digests of 'record', then sends it to the sink
+func (c *chain) append(record Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	record.Seq = c.seq + 1
+	record.Time = c.now().UTC()
+	record.PrevDigest = c.prevDigest
+	digest, err := recordDigest(record, c.key)
+	if err != nil {
+		return err
+	}
+	record.Digest = digest
+	if err := c.sink.Append(record); err != nil {
+		return err
+	}
+	c.seq = record.Seq
+	c.prevDigest = record.Digest
+	return nil
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type dir struct {
-	fs     *ReadOnlyFS
-	name   string
-	offset int
+	fs      *ReadOnlyFS
+	name    string
+	entries []hackpadfs.DirEntry // nil until first ReadDir
+	offset  int
 }
 
 func (d *dir) Read(_ []byte) (n int, err error) {
@@ -25,18 +26,24 @@ func (d *dir) Stat() (hackpadfs.FileInfo, error) {
 }
 
 func (d *dir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
-	entries, err := hackpadfs.ReadDir(d.fs.sourceFS, d.name)
-	if err != nil {
-		return nil, err
+	if d.entries == nil {
+		entries, err := hackpadfs.ReadDir(d.fs.sourceFS, d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = append(make([]hackpadfs.DirEntry, 0, len(entries)), entries...)
 	}
-	if n > 0 && d.offset == len(entries) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
 		return nil, io.EOF
 	}
-	if n <= 0 || d.offset+n > len(entries) {
-		d.offset = n
-	} else {
-		entries = entries[d.offset : d.offset+n]
-		d.offset += n
+	if n > len(remaining) {
+		n = len(remaining)
 	}
-	return entries, nil
+	d.offset += n
+	return remaining[:n], nil
 }
@@ -1,4 +1,4 @@
-// Package cache contains a read-only cache file system.
+// Package cache contains file systems which cache a slow source file system in a faster one.
 package cache
 
 import (
@@ -0,0 +1,704 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/pathlock"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+	} = &ReadThroughFS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.SeekerFile
+	} = &cachedFile{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &writeThroughFile{}
+)
+
+// ReadThroughFS caches a slow source FS in a fast cache FS, like a mem.FS in front of an indexeddb.FS.
+// Regular files are copied into the cache when first opened, then read from the cache until they expire, are evicted, or are invalidated.
+// Stat results are cached the same way. Directories, symlinks, and all other operations always use the source.
+//
+// Writes pass through to the source and invalidate the cached copies of the written paths.
+// Changes made to the source without going through ReadThroughFS, including writes through other symlinks or hard links to a file, are not detected.
+// Set a TTL or call Invalidate to pick them up.
+type ReadThroughFS struct {
+	sourceFS hackpadfs.FS
+	cacheFS  hackpadfs.OpenFileFS
+	options  ReadThroughOptions
+	now      func() time.Time
+	pathlock pathlock.Mutex
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	lru     *list.List // entries with cached data, front is most recently used
+	size    int64      // total bytes of cached data
+	// generation increments on every invalidation, so data fetched from the source concurrently is not cached
+	generation uint64
+	nextID     uint64
+}
+
+// ReadThroughOptions contain options for creating a ReadThroughFS
+type ReadThroughOptions struct {
+	// TTL is how long cached data and Stat results are used before they are fetched from the source again. Defaults to no expiry.
+	TTL time.Duration
+	// MaxBytes limits the total size of cached file data. The least recently opened files are evicted to make room for new ones, and larger files are never cached. Defaults to no limit.
+	MaxBytes int64
+	// RetainData returns true if the data of file 'name' should be cached. Defaults to caching all files.
+	RetainData func(name string, info hackpadfs.FileInfo) bool
+}
+
+// entry is a cached Stat result, and optionally the file's data
+type entry struct {
+	name     string
+	info     hackpadfs.FileInfo
+	cachedAt time.Time
+
+	dataName string // name of the cached data in the cache FS, empty if not cached
+	size     int64
+	elem     *list.Element
+	open     int  // number of open files reading dataName
+	removed  bool // removed from the cache, dataName is removed when the last open file is closed
+
+	// write-back state, only used by WriteBackFS
+	writers int    // number of open files writing dataName
+	dirty   bool   // dataName has changes not yet flushed to the source
+	version uint64 // incremented on every change to dataName
+	flushMu sync.Mutex
+}
+
+// pinned returns true if 'e' must stay cached until it is flushed and closed, instead of being evicted, expired, or invalidated
+func (e *entry) pinned() bool {
+	return e.dirty || e.writers > 0
+}
+
+// fileInfo is a snapshot of a hackpadfs.FileInfo. Some FileInfo implementations reflect later changes to their file.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    hackpadfs.FileMode
+	modTime time.Time
+	sys     interface{}
+}
+
+func newFileInfo(info hackpadfs.FileInfo) *fileInfo {
+	return &fileInfo{
+		name:    info.Name(),
+		size:    info.Size(),
+		mode:    info.Mode(),
+		modTime: info.ModTime(),
+		sys:     info.Sys(),
+	}
+}
+
+func (f *fileInfo) Name() string             { return f.name }
+func (f *fileInfo) Size() int64              { return f.size }
+func (f *fileInfo) Mode() hackpadfs.FileMode { return f.mode }
+func (f *fileInfo) ModTime() time.Time       { return f.modTime }
+func (f *fileInfo) IsDir() bool              { return f.mode.IsDir() }
+func (f *fileInfo) Sys() interface{}         { return f.sys }
+
+// NewReadThroughFS creates a new ReadThroughFS with the given 'source' of data, a writable 'cache' FS, and any additional options.
+// The cache FS should be empty and dedicated to this ReadThroughFS. Cached data is stored under generated names, not the source's file names.
+func NewReadThroughFS(source hackpadfs.FS, cache hackpadfs.OpenFileFS, options ReadThroughOptions) (*ReadThroughFS, error) {
+	if options.TTL < 0 {
+		return nil, fmt.Errorf("cache TTL must not be negative, got %s: %w", options.TTL, hackpadfs.ErrInvalid)
+	}
+	if options.MaxBytes < 0 {
+		return nil, fmt.Errorf("cache max bytes must not be negative, got %d: %w", options.MaxBytes, hackpadfs.ErrInvalid)
+	}
+	if options.RetainData == nil {
+		options.RetainData = func(string, hackpadfs.FileInfo) bool { return true }
+	}
+	return &ReadThroughFS{
+		sourceFS: source,
+		cacheFS:  cache,
+		options:  options,
+		now:      time.Now,
+		entries:  make(map[string]*entry),
+		lru:      list.New(),
+	}, nil
+}
+
+// Invalidate removes 'name' and any paths inside it from the cache, so they are fetched from the source again.
+// Call Invalidate when the source changes outside of this ReadThroughFS, for example from a hackpadfs.Watcher.
+func (fs *ReadThroughFS) Invalidate(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.generation++
+	if name == "." {
+		fs.removeAllLocked()
+		return
+	}
+	prefix := name + "/"
+	for entryName, e := range fs.entries {
+		if (entryName == name || strings.HasPrefix(entryName, prefix)) && !e.pinned() {
+			fs.removeLocked(e)
+		}
+	}
+}
+
+// InvalidateAll removes everything from the cache
+func (fs *ReadThroughFS) InvalidateAll() {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.generation++
+	fs.removeAllLocked()
+}
+
+// invalidateFile removes only 'name' from the cache, for changes which can't affect paths inside it
+func (fs *ReadThroughFS) invalidateFile(name string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.generation++
+	if e, ok := fs.entries[name]; ok && !e.pinned() {
+		fs.removeLocked(e)
+	}
+}
+
+func (fs *ReadThroughFS) removeAllLocked() {
+	for _, e := range fs.entries {
+		if !e.pinned() {
+			fs.removeLocked(e)
+		}
+	}
+}
+
+func (fs *ReadThroughFS) removeLocked(e *entry) {
+	delete(fs.entries, e.name)
+	e.removed = true
+	if e.elem != nil {
+		fs.lru.Remove(e.elem)
+		e.elem = nil
+		fs.size -= e.size
+	}
+	if e.open == 0 {
+		fs.removeData(e)
+	}
+}
+
+func (fs *ReadThroughFS) removeData(e *entry) {
+	if e.dataName != "" {
+		_ = hackpadfs.Remove(fs.cacheFS, e.dataName)
+	}
+}
+
+// lookupLocked returns the unexpired entry for 'name', or nil
+func (fs *ReadThroughFS) lookupLocked(name string) *entry {
+	e, ok := fs.entries[name]
+	if !ok {
+		return nil
+	}
+	if fs.options.TTL > 0 && !e.pinned() && fs.now().Sub(e.cachedAt) >= fs.options.TTL {
+		fs.removeLocked(e)
+		return nil
+	}
+	return e
+}
+
+func (fs *ReadThroughFS) currentGeneration() uint64 {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.generation
+}
+
+// storeInfo caches 'info', unless 'name' is already cached or was invalidated since 'generation'
+func (fs *ReadThroughFS) storeInfo(name string, info hackpadfs.FileInfo, generation uint64) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if generation != fs.generation || fs.lookupLocked(name) != nil {
+		return
+	}
+	fs.entries[name] = &entry{name: name, info: newFileInfo(info), cachedAt: fs.now()}
+}
+
+// storeData caches the data copied to 'dataName' and returns its entry, or nil if 'name' was invalidated since 'generation'
+func (fs *ReadThroughFS) storeData(name string, info hackpadfs.FileInfo, dataName string, size int64, generation uint64) *entry {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	e := &entry{name: name, info: newFileInfo(info), cachedAt: fs.now(), dataName: dataName, size: size}
+	if generation != fs.generation {
+		fs.removeData(e)
+		return nil
+	}
+	if old, ok := fs.entries[name]; ok {
+		fs.removeLocked(old)
+	}
+	fs.entries[name] = e
+	e.elem = fs.lru.PushFront(e)
+	fs.size += size
+	for elem := fs.lru.Back(); elem != nil && fs.options.MaxBytes > 0 && fs.size > fs.options.MaxBytes; {
+		prev := elem.Prev()
+		if evicted := elem.Value.(*entry); !evicted.pinned() {
+			fs.removeLocked(evicted)
+		}
+		elem = prev
+	}
+	if e.removed {
+		return nil
+	}
+	return e
+}
+
+// openCached opens the cached data for 'name'. Returns nil if the data is not cached.
+func (fs *ReadThroughFS) openCached(name string) hackpadfs.File {
+	fs.mu.Lock()
+	e := fs.lookupLocked(name)
+	if e == nil || e.dataName == "" {
+		fs.mu.Unlock()
+		return nil
+	}
+	fs.lru.MoveToFront(e.elem)
+	e.open++
+	fs.mu.Unlock()
+
+	f, err := fs.cacheFS.OpenFile(e.dataName, hackpadfs.FlagReadOnly, 0)
+	if err != nil {
+		// the cache is only an optimization, so drop the broken data and fall back to the source
+		fs.release(e)
+		fs.invalidateFile(name)
+		return nil
+	}
+	return &cachedFile{fs: fs, name: name, entry: e, file: f}
+}
+
+// release closes an open file's reference to the cached data in 'e'
+func (fs *ReadThroughFS) release(e *entry) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	e.open--
+	if e.open == 0 && e.removed {
+		fs.removeData(e)
+	}
+}
+
+func (fs *ReadThroughFS) entryInfo(e *entry) hackpadfs.FileInfo {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return e.info
+}
+
+func (fs *ReadThroughFS) isRemoved(e *entry) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return e.removed
+}
+
+// copyData copies all of 'r' into a new file in the cache FS and returns its name and size
+func (fs *ReadThroughFS) copyData(r io.Reader) (string, int64, error) {
+	fs.mu.Lock()
+	fs.nextID++
+	dataName := strconv.FormatUint(fs.nextID, 10)
+	fs.mu.Unlock()
+
+	destFile, err := fs.cacheFS.OpenFile(dataName, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0600)
+	if err != nil {
+		return "", 0, err
+	}
+	destFileWriter, ok := destFile.(io.Writer)
+	if !ok {
+		_ = destFile.Close()
+		_ = hackpadfs.Remove(fs.cacheFS, dataName)
+		return "", 0, &hackpadfs.PathError{Op: "open", Path: dataName, Err: hackpadfs.ErrPermission}
+	}
+	size, err := io.Copy(destFileWriter, r)
+	if closeErr := destFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = hackpadfs.Remove(fs.cacheFS, dataName)
+		return "", 0, err
+	}
+	return dataName, size, nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *ReadThroughFS) Open(name string) (hackpadfs.File, error) {
+	fs.pathlock.Lock(name)
+	defer fs.pathlock.Unlock(name)
+	if f := fs.openCached(name); f != nil {
+		return f, nil
+	}
+
+	generation := fs.currentGeneration()
+	f, err := fs.sourceFS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() ||
+		(fs.options.MaxBytes > 0 && info.Size() > fs.options.MaxBytes) ||
+		!fs.options.RetainData(name, info) {
+		fs.storeInfo(name, info, generation)
+		return f, nil
+	}
+
+	dataName, size, err := fs.copyData(f)
+	_ = f.Close()
+	if err == nil {
+		if e := fs.storeData(name, info, dataName, size, generation); e != nil {
+			if f := fs.openCached(name); f != nil {
+				return f, nil
+			}
+		}
+	}
+	// caching failed or was invalidated, so read from the source instead
+	return fs.sourceFS.Open(name)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS. Files opened for writing are opened on the source, and invalidate their cached data on every change.
+func (fs *ReadThroughFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if flag == hackpadfs.FlagReadOnly {
+		return fs.Open(name)
+	}
+	fs.invalidateFile(name)
+	f, err := hackpadfs.OpenFile(fs.sourceFS, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &writeThroughFile{File: f, fs: fs, name: name}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *ReadThroughFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	defer fs.invalidateFile(name)
+	return hackpadfs.Mkdir(fs.sourceFS, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *ReadThroughFS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	defer fs.Invalidate(path)
+	return hackpadfs.MkdirAll(fs.sourceFS, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *ReadThroughFS) Remove(name string) error {
+	defer fs.invalidateFile(name)
+	return hackpadfs.Remove(fs.sourceFS, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *ReadThroughFS) RemoveAll(path string) error {
+	defer fs.Invalidate(path)
+	return hackpadfs.RemoveAll(fs.sourceFS, path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *ReadThroughFS) Rename(oldname, newname string) error {
+	defer fs.Invalidate(newname)
+	defer fs.Invalidate(oldname)
+	return hackpadfs.Rename(fs.sourceFS, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *ReadThroughFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	fs.mu.Lock()
+	var info hackpadfs.FileInfo
+	if e := fs.lookupLocked(name); e != nil {
+		info = e.info
+	}
+	fs.mu.Unlock()
+	if info != nil {
+		return info, nil
+	}
+	generation := fs.currentGeneration()
+	info, err := hackpadfs.Stat(fs.sourceFS, name)
+	if err != nil {
+		return nil, err
+	}
+	fs.storeInfo(name, info, generation)
+	return info, nil
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *ReadThroughFS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Lstat(fs.sourceFS, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *ReadThroughFS) Chmod(name string, mode hackpadfs.FileMode) error {
+	defer fs.invalidateFile(name)
+	return hackpadfs.Chmod(fs.sourceFS, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *ReadThroughFS) Chown(name string, uid, gid int) error {
+	defer fs.invalidateFile(name)
+	return hackpadfs.Chown(fs.sourceFS, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *ReadThroughFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	defer fs.invalidateFile(name)
+	return hackpadfs.Chtimes(fs.sourceFS, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *ReadThroughFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.sourceFS, name)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *ReadThroughFS) Symlink(oldname, newname string) error {
+	defer fs.invalidateFile(newname)
+	return hackpadfs.Symlink(fs.sourceFS, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *ReadThroughFS) Link(oldname, newname string) error {
+	defer fs.invalidateFile(newname)
+	return hackpadfs.Link(fs.sourceFS, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *ReadThroughFS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(fs.sourceFS, name)
+}
+
+// cachedFile reads a file's cached data, and reports the source file's info.
+// Once the cached data is invalidated, reads continue from the same offset in the source file, so changes are visible to open files.
+type cachedFile struct {
+	fs    *ReadThroughFS
+	name  string
+	entry *entry
+
+	mu     sync.Mutex
+	file   hackpadfs.File // the cached data, or the source file if 'source' is set
+	source bool
+	kept   bool // the source file was removed or renamed, so keep reading the cached data
+	closed bool
+}
+
+// current returns the file to read, first switching to the source file if the cached data was invalidated. Must hold f.mu.
+func (f *cachedFile) current() (hackpadfs.File, error) {
+	if f.source || f.kept || f.closed || !f.fs.isRemoved(f.entry) {
+		return f.file, nil
+	}
+	offset, err := hackpadfs.SeekFile(f.file, 0, io.SeekCurrent)
+	if err != nil {
+		return nil, f.pathErr(err)
+	}
+	sourceFile, err := f.fs.sourceFS.Open(f.name)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		// like an open file on most systems, keep reading the old contents
+		f.kept = true
+		return f.file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := hackpadfs.SeekFile(sourceFile, offset, io.SeekStart); err != nil {
+		_ = sourceFile.Close()
+		return nil, err
+	}
+	_ = f.file.Close()
+	f.fs.release(f.entry)
+	f.file, f.source = sourceFile, true
+	return f.file, nil
+}
+
+// pathErr reports errors from the cached data with the source file's name
+func (f *cachedFile) pathErr(err error) error {
+	var pathErr *hackpadfs.PathError
+	if !f.source && errors.As(err, &pathErr) {
+		return &hackpadfs.PathError{Op: pathErr.Op, Path: f.name, Err: pathErr.Err}
+	}
+	return err
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *cachedFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.current()
+	if err != nil {
+		return 0, err
+	}
+	n, err := file.Read(p)
+	return n, f.pathErr(err)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *cachedFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := hackpadfs.WriteFile(f.file, p)
+	return n, f.pathErr(err)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *cachedFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.current()
+	if err != nil {
+		return 0, err
+	}
+	n, err := hackpadfs.ReadAtFile(file, p, off)
+	return n, f.pathErr(err)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *cachedFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := hackpadfs.WriteAtFile(f.file, p, off)
+	return n, f.pathErr(err)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *cachedFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.current()
+	if err != nil {
+		return 0, err
+	}
+	n, err := hackpadfs.SeekFile(file, offset, whence)
+	return n, f.pathErr(err)
+}
+
+// Stat implements hackpadfs.File
+func (f *cachedFile) Stat() (hackpadfs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.current()
+	if err != nil {
+		return nil, err
+	}
+	if f.source || f.closed {
+		info, err := file.Stat()
+		return info, f.pathErr(err)
+	}
+	return f.fs.entryInfo(f.entry), nil
+}
+
+// Close implements hackpadfs.File
+func (f *cachedFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.file.Close()
+	if !f.source && !f.closed {
+		f.fs.release(f.entry)
+	}
+	f.closed = true
+	return f.pathErr(err)
+}
+
+// writeThroughFile is a source file opened for writing, which invalidates its cached data after every change
+type writeThroughFile struct {
+	hackpadfs.File
+	fs   *ReadThroughFS
+	name string
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *writeThroughFile) Read(p []byte) (int, error) {
+	return f.File.Read(p)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *writeThroughFile) Write(p []byte) (int, error) {
+	defer f.fs.invalidateFile(f.name)
+	return hackpadfs.WriteFile(f.File, p)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *writeThroughFile) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *writeThroughFile) WriteAt(p []byte, off int64) (int, error) {
+	defer f.fs.invalidateFile(f.name)
+	return hackpadfs.WriteAtFile(f.File, p, off)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *writeThroughFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *writeThroughFile) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *writeThroughFile) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *writeThroughFile) Truncate(size int64) error {
+	defer f.fs.invalidateFile(f.name)
+	return hackpadfs.TruncateFile(f.File, size)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *writeThroughFile) Chmod(mode hackpadfs.FileMode) error {
+	defer f.fs.invalidateFile(f.name)
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *writeThroughFile) Chown(uid, gid int) error {
+	defer f.fs.invalidateFile(f.name)
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *writeThroughFile) Chtimes(atime time.Time, mtime time.Time) error {
+	defer f.fs.invalidateFile(f.name)
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
+
+// Close implements hackpadfs.File
+func (f *writeThroughFile) Close() error {
+	defer f.fs.invalidateFile(f.name)
+	return f.File.Close()
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestReadThroughFS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		options ReadThroughOptions
+	}{
+		{"read-through cache", ReadThroughOptions{}},
+		{"read-through cache with limits", ReadThroughOptions{TTL: time.Minute, MaxBytes: 64}},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				fs, _, _ := newReadThroughFS(tb, tc.options)
+				return fs
+			},
+			Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func newReadThroughFS(tb testing.TB, options ReadThroughOptions) (fs *ReadThroughFS, source, cache *mem.FS) {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	cache, err = mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err = NewReadThroughFS(source, cache, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs, source, cache
+}
+
+func assertReadFile(tb testing.TB, fs hackpadfs.FS, name, expected string) {
+	tb.Helper()
+	contents, err := hackpadfs.ReadFile(fs, name)
+	assert.NoError(tb, err)
+	assert.Equal(tb, expected, string(contents))
+}
+
+func TestNewReadThroughFSInvalidOptions(t *testing.T) {
+	t.Parallel()
+	source, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = NewReadThroughFS(source, source, ReadThroughOptions{TTL: -1})
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, err = NewReadThroughFS(source, source, ReadThroughOptions{MaxBytes: -1})
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestReadThroughFSCachesData(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newReadThroughFS(t, ReadThroughOptions{})
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "foo", []byte("hello"), 0600))
+	assertReadFile(t, fs, "foo", "hello")
+
+	// changes made directly to the source are not seen until invalidated
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "foo", []byte("hello world"), 0600))
+	assertReadFile(t, fs, "foo", "hello")
+	info, err := hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size())
+
+	fs.Invalidate("foo")
+	assertReadFile(t, fs, "foo", "hello world")
+}
+
+func TestReadThroughFSInvalidate(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newReadThroughFS(t, ReadThroughOptions{})
+	assert.NoError(t, hackpadfs.MkdirAll(source, "dir/sub", 0700))
+	for _, name := range []string{"dir/sub/foo", "dir2", "other"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(source, name, []byte("hello"), 0600))
+		assertReadFile(t, fs, name, "hello")
+		assert.NoError(t, hackpadfs.WriteFullFile(source, name, []byte("world"), 0600))
+	}
+
+	fs.Invalidate("dir")
+	assertReadFile(t, fs, "dir/sub/foo", "world")
+	assertReadFile(t, fs, "dir2", "hello")
+	assertReadFile(t, fs, "other", "hello")
+
+	fs.InvalidateAll()
+	assertReadFile(t, fs, "dir2", "world")
+	assertReadFile(t, fs, "other", "world")
+}
+
+func TestReadThroughFSWritesInvalidate(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newReadThroughFS(t, ReadThroughOptions{})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("hello"), 0600))
+	assertReadFile(t, fs, "foo", "hello")
+
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte(" world"))
+	assert.NoError(t, err)
+	// visible before Close
+	assertReadFile(t, fs, "foo", "hello world")
+	assert.NoError(t, f.Close())
+
+	assert.NoError(t, hackpadfs.Rename(fs, "foo", "bar"))
+	_, err = hackpadfs.Stat(fs, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	assertReadFile(t, fs, "bar", "hello world")
+	assertReadFile(t, source, "bar", "hello world")
+}
+
+func TestReadThroughFSTTL(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newReadThroughFS(t, ReadThroughOptions{TTL: time.Minute})
+	now := time.Now()
+	fs.now = func() time.Time { return now }
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "foo", []byte("hello"), 0600))
+	assertReadFile(t, fs, "foo", "hello")
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "foo", []byte("world"), 0600))
+
+	now = now.Add(time.Minute - 1)
+	assertReadFile(t, fs, "foo", "hello")
+	now = now.Add(1)
+	assertReadFile(t, fs, "foo", "world")
+}
+
+func TestReadThroughFSMaxBytes(t *testing.T) {
+	t.Parallel()
+	fs, source, cache := newReadThroughFS(t, ReadThroughOptions{MaxBytes: 10})
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "a", []byte("aaaa"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "b", []byte("bbbb"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "c", []byte("cccc"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "big", []byte("too big to cache"), 0600))
+	assertReadFile(t, fs, "a", "aaaa")
+	assertReadFile(t, fs, "b", "bbbb")
+	assertReadFile(t, fs, "a", "aaaa") // 'b' is now least recently used
+	assertReadFile(t, fs, "c", "cccc")
+	assertReadFile(t, fs, "big", "too big to cache")
+	assert.Equal(t, int64(8), fs.size)
+
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(source, name, []byte("new"), 0600))
+	}
+	assertReadFile(t, fs, "a", "aaaa")
+	assertReadFile(t, fs, "b", "new")
+
+	entries, err := hackpadfs.ReadDir(cache, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+}
+
+func TestReadThroughFSOpenFileReadsSourceAfterInvalidate(t *testing.T) {
+	t.Parallel()
+	fs, source, cache := newReadThroughFS(t, ReadThroughOptions{})
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "foo", []byte("hello world"), 0600))
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	buf := make([]byte, 6)
+	_, err = f.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello ", string(buf))
+
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "foo", []byte("hello there"), 0600))
+	fs.Invalidate("foo")
+	rest, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "there", string(rest))
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), info.Size())
+
+	entries, err := hackpadfs.ReadDir(cache, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+	assert.NoError(t, f.Close())
+}
+
+func TestReadThroughFSRetainData(t *testing.T) {
+	t.Parallel()
+	fs, source, cache := newReadThroughFS(t, ReadThroughOptions{
+		RetainData: func(name string, info hackpadfs.FileInfo) bool {
+			return name != "skip"
+		},
+	})
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "skip", []byte("hello"), 0600))
+	assertReadFile(t, fs, "skip", "hello")
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "skip", []byte("world"), 0600))
+	assertReadFile(t, fs, "skip", "world")
+
+	entries, err := hackpadfs.ReadDir(cache, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
@@ -0,0 +1,683 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+	} = &WriteBackFS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &writeBackFile{}
+)
+
+// WriteBackFS caches a slow source FS in a fast cache FS like ReadThroughFS, and also holds file writes in the cache until they are flushed to the source.
+// Bursty writers then only wait on the cache FS.
+// Written files are flushed on Sync, on Close if FlushOnClose is set, every FlushInterval, and by Flush.
+//
+// Only file contents are written back. Creating, removing, and renaming files and directories, and all other changes, apply to the source immediately.
+// Files with unflushed writes are never evicted, expired, or invalidated, so local writes win over changes made directly to the source.
+// Opening or statting an uncached symlink flushes all files first, since it may point to one with unflushed writes. Symlinks in parent directories are not checked.
+// Call Close before exiting to flush all remaining writes.
+type WriteBackFS struct {
+	readThrough *ReadThroughFS
+	options     WriteBackOptions
+
+	// flushLock is held for reading by flushes, and for writing by removes and renames, so files are never flushed to stale names
+	flushLock sync.RWMutex
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WriteBackOptions contain options for creating a WriteBackFS
+type WriteBackOptions struct {
+	ReadThroughOptions
+	// FlushOnClose flushes a file's writes when it is closed. Close then waits on the source, and returns any flush error.
+	FlushOnClose bool
+	// FlushInterval flushes all written files in the background on this interval. Failed flushes are retried on the next interval. Defaults to no background flushes.
+	FlushInterval time.Duration
+}
+
+// NewWriteBackFS creates a new WriteBackFS with the given 'source' of data, a writable 'cache' FS, and any additional options.
+// The cache FS should be empty and dedicated to this WriteBackFS. Cached data is stored under generated names, not the source's file names.
+func NewWriteBackFS(source hackpadfs.FS, cache hackpadfs.OpenFileFS, options WriteBackOptions) (*WriteBackFS, error) {
+	if options.FlushInterval < 0 {
+		return nil, fmt.Errorf("cache flush interval must not be negative, got %s: %w", options.FlushInterval, hackpadfs.ErrInvalid)
+	}
+	readThrough, err := NewReadThroughFS(source, cache, options.ReadThroughOptions)
+	if err != nil {
+		return nil, err
+	}
+	fs := &WriteBackFS{
+		readThrough: readThrough,
+		options:     options,
+	}
+	if options.FlushInterval > 0 {
+		fs.stop = make(chan struct{})
+		fs.done = make(chan struct{})
+		go fs.flushEvery(options.FlushInterval)
+	}
+	return fs, nil
+}
+
+func (fs *WriteBackFS) flushEvery(interval time.Duration) {
+	defer close(fs.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fs.stop:
+			return
+		case <-ticker.C:
+			_ = fs.Flush()
+		}
+	}
+}
+
+// Close stops background flushes, then flushes all remaining writes. Files and the FS may still be used after Close, but are only flushed on Sync, Close, and Flush.
+func (fs *WriteBackFS) Close() error {
+	fs.closeOnce.Do(func() {
+		if fs.stop != nil {
+			close(fs.stop)
+			<-fs.done
+		}
+	})
+	return fs.Flush()
+}
+
+// Flush writes all unflushed file contents to the source. Returns the first error encountered, after attempting to flush every file.
+func (fs *WriteBackFS) Flush() error {
+	var firstErr error
+	for _, e := range fs.dirtyEntries() {
+		if err := fs.flush(e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Invalidate removes 'name' and any paths inside it from the cache, except files with unflushed writes. See ReadThroughFS.Invalidate.
+func (fs *WriteBackFS) Invalidate(name string) {
+	fs.readThrough.Invalidate(name)
+}
+
+// InvalidateAll removes everything from the cache, except files with unflushed writes
+func (fs *WriteBackFS) InvalidateAll() {
+	fs.readThrough.InvalidateAll()
+}
+
+func (fs *WriteBackFS) dirtyEntries() []*entry {
+	rt := fs.readThrough
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var entries []*entry
+	for _, e := range rt.entries {
+		if e.dirty {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// pinnedEntry returns the entry for 'name' if it has unflushed writes or open writers, otherwise nil
+func (fs *WriteBackFS) pinnedEntry(name string) *entry {
+	rt := fs.readThrough
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if e, ok := rt.entries[name]; ok && e.pinned() {
+		return e
+	}
+	return nil
+}
+
+// flush writes the contents of 'e' to the source, if it has unflushed writes
+func (fs *WriteBackFS) flush(e *entry) error {
+	fs.flushLock.RLock()
+	defer fs.flushLock.RUnlock()
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+
+	rt := fs.readThrough
+	rt.mu.Lock()
+	if !e.dirty || e.removed {
+		rt.mu.Unlock()
+		return nil
+	}
+	name, dataName, version, perm := e.name, e.dataName, e.version, e.info.Mode().Perm()
+	rt.mu.Unlock()
+
+	data, err := hackpadfs.ReadFile(rt.cacheFS, dataName)
+	if err != nil {
+		return withPath(err, name)
+	}
+	f, err := hackpadfs.OpenFile(rt.sourceFS, name, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, perm)
+	if err != nil {
+		return err
+	}
+	_, err = hackpadfs.WriteFile(f, data)
+	if err == nil {
+		err = hackpadfs.SyncFile(f)
+		if errors.Is(err, hackpadfs.ErrNotImplemented) {
+			err = nil
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	info, statErr := hackpadfs.Stat(rt.sourceFS, name)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if e.version == version {
+		e.dirty = false
+		if statErr == nil {
+			e.info = newFileInfo(info)
+		}
+	}
+	return nil
+}
+
+// flushName flushes 'name' if it has unflushed writes
+func (fs *WriteBackFS) flushName(name string) error {
+	if e := fs.pinnedEntry(name); e != nil {
+		return fs.flush(e)
+	}
+	return nil
+}
+
+// refreshInfo updates cached info for 'name' after a metadata change on the source
+func (fs *WriteBackFS) refreshInfo(name string) {
+	rt := fs.readThrough
+	e := fs.pinnedEntry(name)
+	if e == nil {
+		rt.invalidateFile(name)
+		return
+	}
+	info, err := hackpadfs.Stat(rt.sourceFS, name)
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if err == nil && !e.removed {
+		updated := *newFileInfo(info)
+		updated.size = e.info.Size()
+		if e.dirty {
+			updated.modTime = e.info.ModTime()
+		}
+		e.info = &updated
+	}
+}
+
+// writableEntry returns a cached entry for writing 'name', reserved for one open writer. Returns nil if the file can't be cached.
+func (fs *WriteBackFS) writableEntry(name string, flag int, info hackpadfs.FileInfo) *entry {
+	rt := fs.readThrough
+	rt.mu.Lock()
+	if e := rt.lookupLocked(name); e != nil && e.dataName != "" {
+		e.open++
+		e.writers++
+		rt.mu.Unlock()
+		return e
+	}
+	rt.mu.Unlock()
+
+	if (rt.options.MaxBytes > 0 && info.Size() > rt.options.MaxBytes) || !rt.options.RetainData(name, info) {
+		return nil
+	}
+	generation := rt.currentGeneration()
+	var contents io.Reader = strings.NewReader("")
+	if flag&hackpadfs.FlagTruncate == 0 {
+		sourceFile, err := rt.sourceFS.Open(name)
+		if err != nil {
+			return nil
+		}
+		defer func() { _ = sourceFile.Close() }()
+		contents = sourceFile
+	}
+	dataName, size, err := rt.copyData(contents)
+	if err != nil {
+		return nil
+	}
+	e := rt.storeData(name, info, dataName, size, generation)
+	if e == nil {
+		return nil
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if e.removed {
+		return nil
+	}
+	e.open++
+	e.writers++
+	return e
+}
+
+// releaseWriter closes an open writer's reference to the cached data in 'e'
+func (fs *WriteBackFS) releaseWriter(e *entry) {
+	rt := fs.readThrough
+	rt.mu.Lock()
+	e.writers--
+	rt.mu.Unlock()
+	rt.release(e)
+}
+
+// changed marks 'e' as having unflushed writes, now 'size' bytes long
+func (fs *WriteBackFS) changed(e *entry, size int64) {
+	rt := fs.readThrough
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	e.version++
+	e.dirty = true
+	updated := *e.info.(*fileInfo)
+	updated.size = size
+	updated.modTime = rt.now()
+	e.info = &updated
+	if e.elem != nil {
+		rt.size += size - e.size
+	}
+	e.size = size
+}
+
+// flushBeforeSymlink flushes all files if 'name' is an uncached symlink, since it may point to a file with unflushed writes
+func (fs *WriteBackFS) flushBeforeSymlink(name string) error {
+	rt := fs.readThrough
+	rt.mu.Lock()
+	check := rt.lookupLocked(name) == nil && fs.hasDirtyLocked()
+	rt.mu.Unlock()
+	if !check {
+		return nil
+	}
+	info, err := hackpadfs.Lstat(rt.sourceFS, name)
+	if err != nil || info.Mode().Type() != hackpadfs.ModeSymlink {
+		return nil
+	}
+	return fs.Flush()
+}
+
+func (fs *WriteBackFS) hasDirtyLocked() bool {
+	for _, e := range fs.readThrough.entries {
+		if e.dirty {
+			return true
+		}
+	}
+	return false
+}
+
+// Open implements hackpadfs.FS
+func (fs *WriteBackFS) Open(name string) (hackpadfs.File, error) {
+	if err := fs.flushBeforeSymlink(name); err != nil {
+		return nil, err
+	}
+	return fs.readThrough.Open(name)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS. Regular files opened for writing are created or truncated on the source immediately, then written in the cache.
+func (fs *WriteBackFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if flag == hackpadfs.FlagReadOnly {
+		return fs.Open(name)
+	}
+	if err := fs.flushBeforeSymlink(name); err != nil {
+		return nil, err
+	}
+	rt := fs.readThrough
+	rt.pathlock.Lock(name)
+	defer rt.pathlock.Unlock(name)
+
+	// open the source first to check 'flag' is allowed, and apply any create or truncate flags
+	sourceFile, err := hackpadfs.OpenFile(rt.sourceFS, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	info, err := sourceFile.Stat()
+	if err != nil {
+		_ = sourceFile.Close()
+		return nil, err
+	}
+	var e *entry
+	if info.Mode().IsRegular() {
+		e = fs.writableEntry(name, flag, info)
+	}
+	if e == nil {
+		rt.invalidateFile(name)
+		return &writeThroughFile{File: sourceFile, fs: rt, name: name}, nil
+	}
+	_ = sourceFile.Close()
+
+	dataFlag := flag & (hackpadfs.FlagWriteOnly | hackpadfs.FlagReadWrite | hackpadfs.FlagAppend | hackpadfs.FlagTruncate)
+	f, err := rt.cacheFS.OpenFile(e.dataName, dataFlag, 0)
+	if err != nil {
+		fs.releaseWriter(e)
+		return nil, withPath(err, name)
+	}
+	file := &writeBackFile{fs: fs, name: name, entry: e, file: f}
+	if flag&hackpadfs.FlagTruncate != 0 {
+		file.changed()
+	}
+	return file, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *WriteBackFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.readThrough.Mkdir(name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *WriteBackFS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.readThrough.MkdirAll(path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS. Discards any unflushed writes to 'name'.
+func (fs *WriteBackFS) Remove(name string) error {
+	fs.flushLock.Lock()
+	defer fs.flushLock.Unlock()
+	err := hackpadfs.Remove(fs.readThrough.sourceFS, name)
+	if err == nil {
+		fs.discard(name)
+	}
+	return err
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS. Discards any unflushed writes inside 'path'.
+func (fs *WriteBackFS) RemoveAll(path string) error {
+	fs.flushLock.Lock()
+	defer fs.flushLock.Unlock()
+	err := hackpadfs.RemoveAll(fs.readThrough.sourceFS, path)
+	if err == nil {
+		fs.discard(path)
+	}
+	return err
+}
+
+// discard removes 'name' and any paths inside it from the cache, including unflushed writes
+func (fs *WriteBackFS) discard(name string) {
+	rt := fs.readThrough
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.generation++
+	prefix := name + "/"
+	for entryName, e := range rt.entries {
+		if name == "." || entryName == name || strings.HasPrefix(entryName, prefix) {
+			rt.removeLocked(e)
+		}
+	}
+}
+
+// Rename implements hackpadfs.RenameFS. Unflushed writes move with their files, and are flushed to the new names.
+func (fs *WriteBackFS) Rename(oldname, newname string) error {
+	fs.flushLock.Lock()
+	defer fs.flushLock.Unlock()
+	rt := fs.readThrough
+	err := hackpadfs.Rename(rt.sourceFS, oldname, newname)
+	if err != nil || oldname == newname {
+		return err
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.generation++
+	var moved []*entry
+	oldPrefix, newPrefix := oldname+"/", newname+"/"
+	for entryName, e := range rt.entries {
+		switch {
+		case entryName == newname || strings.HasPrefix(entryName, newPrefix):
+			rt.removeLocked(e)
+		case entryName == oldname || strings.HasPrefix(entryName, oldPrefix):
+			if e.pinned() {
+				moved = append(moved, e)
+				delete(rt.entries, entryName)
+			} else {
+				rt.removeLocked(e)
+			}
+		}
+	}
+	for _, e := range moved {
+		e.name = newname + strings.TrimPrefix(e.name, oldname)
+		updated := *e.info.(*fileInfo)
+		updated.name = path.Base(e.name)
+		e.info = &updated
+		rt.entries[e.name] = e
+	}
+	return nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *WriteBackFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if err := fs.flushBeforeSymlink(name); err != nil {
+		return nil, err
+	}
+	return fs.readThrough.Stat(name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *WriteBackFS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.Lstat(fs.readThrough.sourceFS, name)
+	if err != nil || !info.Mode().IsRegular() {
+		return info, err
+	}
+	if e := fs.pinnedEntry(name); e != nil {
+		return fs.readThrough.entryInfo(e), nil
+	}
+	return info, nil
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *WriteBackFS) Chmod(name string, mode hackpadfs.FileMode) error {
+	defer fs.refreshInfo(name)
+	return hackpadfs.Chmod(fs.readThrough.sourceFS, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *WriteBackFS) Chown(name string, uid, gid int) error {
+	defer fs.refreshInfo(name)
+	return hackpadfs.Chown(fs.readThrough.sourceFS, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS. Flushes 'name' first, so the new times are kept.
+func (fs *WriteBackFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := fs.flushName(name); err != nil {
+		return err
+	}
+	defer fs.refreshInfo(name)
+	return hackpadfs.Chtimes(fs.readThrough.sourceFS, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *WriteBackFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDir(fs.readThrough.sourceFS, name)
+	for i, dirEntry := range entries {
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+		if e := fs.pinnedEntry(path.Join(name, dirEntry.Name())); e != nil {
+			entries[i] = &dirEntryInfo{fileInfo: fs.readThrough.entryInfo(e).(*fileInfo)}
+		}
+	}
+	return entries, err
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *WriteBackFS) Symlink(oldname, newname string) error {
+	return fs.readThrough.Symlink(oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS. Flushes 'oldname' first, so the new link has its current contents.
+func (fs *WriteBackFS) Link(oldname, newname string) error {
+	if err := fs.flushName(oldname); err != nil {
+		return err
+	}
+	return fs.readThrough.Link(oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *WriteBackFS) Readlink(name string) (string, error) {
+	return fs.readThrough.Readlink(name)
+}
+
+// dirEntryInfo is a hackpadfs.DirEntry for a file with unflushed writes
+type dirEntryInfo struct {
+	*fileInfo
+}
+
+func (d *dirEntryInfo) Type() hackpadfs.FileMode          { return d.mode.Type() }
+func (d *dirEntryInfo) Info() (hackpadfs.FileInfo, error) { return d.fileInfo, nil }
+
+// renameErr reports errors from cached data with the source file's name
+func withPath(err error, name string) error {
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		return &hackpadfs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+// writeBackFile writes a file's cached data, which is flushed to the source later
+type writeBackFile struct {
+	fs    *WriteBackFS
+	name  string
+	entry *entry
+	file  hackpadfs.File
+	once  sync.Once
+}
+
+// changed marks the file as having unflushed writes
+func (f *writeBackFile) changed() {
+	info, err := f.file.Stat()
+	if err != nil {
+		return
+	}
+	f.fs.changed(f.entry, info.Size())
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *writeBackFile) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	return n, withPath(err, f.name)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *writeBackFile) Write(p []byte) (int, error) {
+	n, err := hackpadfs.WriteFile(f.file, p)
+	if n > 0 {
+		f.changed()
+	}
+	return n, withPath(err, f.name)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *writeBackFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := hackpadfs.ReadAtFile(f.file, p, off)
+	return n, withPath(err, f.name)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *writeBackFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := hackpadfs.WriteAtFile(f.file, p, off)
+	if n > 0 {
+		f.changed()
+	}
+	return n, withPath(err, f.name)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *writeBackFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDirFile(f.file, n)
+	return entries, withPath(err, f.name)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *writeBackFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := hackpadfs.SeekFile(f.file, offset, whence)
+	return n, withPath(err, f.name)
+}
+
+// Sync implements hackpadfs.SyncerFile. Flushes the file's writes to the source.
+func (f *writeBackFile) Sync() error {
+	return f.fs.flush(f.entry)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *writeBackFile) Truncate(size int64) error {
+	err := hackpadfs.TruncateFile(f.file, size)
+	if err == nil {
+		f.changed()
+	}
+	return withPath(err, f.name)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *writeBackFile) Chmod(mode hackpadfs.FileMode) error {
+	return f.fs.Chmod(f.currentName(), mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *writeBackFile) Chown(uid, gid int) error {
+	return f.fs.Chown(f.currentName(), uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *writeBackFile) Chtimes(atime time.Time, mtime time.Time) error {
+	return f.fs.Chtimes(f.currentName(), atime, mtime)
+}
+
+// currentName returns the file's name, including any renames since it was opened
+func (f *writeBackFile) currentName() string {
+	rt := f.fs.readThrough
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return f.entry.name
+}
+
+// Stat implements hackpadfs.File
+func (f *writeBackFile) Stat() (hackpadfs.FileInfo, error) {
+	return f.fs.readThrough.entryInfo(f.entry), nil
+}
+
+// Close implements hackpadfs.File. Flushes the file's writes if FlushOnClose is set.
+func (f *writeBackFile) Close() error {
+	err := withPath(f.file.Close(), f.name)
+	closed := false
+	f.once.Do(func() {
+		closed = true
+		f.fs.releaseWriter(f.entry)
+	})
+	if err == nil && closed && f.fs.options.FlushOnClose {
+		err = f.fs.flush(f.entry)
+	}
+	return err
+}
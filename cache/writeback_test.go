@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestWriteBackFS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		options WriteBackOptions
+	}{
+		{"write-back cache", WriteBackOptions{}},
+		{"write-back cache flush on close", WriteBackOptions{FlushOnClose: true}},
+		{"write-back cache with limits", WriteBackOptions{
+			ReadThroughOptions: ReadThroughOptions{TTL: time.Minute, MaxBytes: 64},
+			FlushInterval:      time.Millisecond,
+		}},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				fs, _, _ := newWriteBackFS(tb, tc.options)
+				return fs
+			},
+			Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func newWriteBackFS(tb testing.TB, options WriteBackOptions) (fs *WriteBackFS, source, cache *mem.FS) {
+	tb.Helper()
+	source, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	cache, err = mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err = NewWriteBackFS(source, cache, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	tb.Cleanup(func() {
+		assert.NoError(tb, fs.Close())
+	})
+	return fs, source, cache
+}
+
+func TestNewWriteBackFSInvalidOptions(t *testing.T) {
+	t.Parallel()
+	source, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = NewWriteBackFS(source, source, WriteBackOptions{FlushInterval: -1})
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	_, err = NewWriteBackFS(source, source, WriteBackOptions{ReadThroughOptions: ReadThroughOptions{TTL: -1}})
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func writeOpen(tb testing.TB, fs hackpadfs.FS, name, contents string) hackpadfs.File {
+	tb.Helper()
+	f, err := hackpadfs.OpenFile(fs, name, hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0600)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	_, err = hackpadfs.WriteFile(f, []byte(contents))
+	assert.NoError(tb, err)
+	return f
+}
+
+func TestWriteBackFSFlush(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newWriteBackFS(t, WriteBackOptions{})
+	f := writeOpen(t, fs, "foo", "hello")
+	assert.NoError(t, f.Close())
+
+	// created right away, but written back later
+	assertReadFile(t, source, "foo", "")
+	assertReadFile(t, fs, "foo", "hello")
+	info, err := hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size())
+	info, err = hackpadfs.Lstat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size())
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		info, err := entries[0].Info()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), info.Size())
+	}
+
+	assert.NoError(t, fs.Flush())
+	assertReadFile(t, source, "foo", "hello")
+}
+
+func TestWriteBackFSSync(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newWriteBackFS(t, WriteBackOptions{})
+	f := writeOpen(t, fs, "foo", "hello")
+	assertReadFile(t, source, "foo", "")
+	assert.NoError(t, hackpadfs.SyncFile(f))
+	assertReadFile(t, source, "foo", "hello")
+
+	_, err := hackpadfs.WriteFile(f, []byte(" world"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assertReadFile(t, source, "foo", "hello")
+	assert.NoError(t, fs.Close())
+	assertReadFile(t, source, "foo", "hello world")
+}
+
+func TestWriteBackFSFlushOnClose(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newWriteBackFS(t, WriteBackOptions{FlushOnClose: true})
+	f := writeOpen(t, fs, "foo", "hello")
+	assertReadFile(t, source, "foo", "")
+	assert.NoError(t, f.Close())
+	assertReadFile(t, source, "foo", "hello")
+}
+
+func TestWriteBackFSFlushInterval(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newWriteBackFS(t, WriteBackOptions{FlushInterval: time.Millisecond})
+	f := writeOpen(t, fs, "foo", "hello")
+	defer func() { assert.NoError(t, f.Close()) }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		contents, err := hackpadfs.ReadFile(source, "foo")
+		assert.NoError(t, err)
+		if string(contents) == "hello" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for background flush")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBackFSRename(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newWriteBackFS(t, WriteBackOptions{})
+	assert.NoError(t, hackpadfs.Mkdir(fs, "dir", 0700))
+	f := writeOpen(t, fs, "dir/foo", "hello")
+	assert.NoError(t, hackpadfs.Rename(fs, "dir", "dir2"))
+	_, err := hackpadfs.WriteFile(f, []byte(" world"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	assert.NoError(t, fs.Flush())
+	assertReadFile(t, source, "dir2/foo", "hello world")
+	_, err = hackpadfs.Stat(source, "dir/foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestWriteBackFSRemoveDiscardsWrites(t *testing.T) {
+	t.Parallel()
+	fs, source, cache := newWriteBackFS(t, WriteBackOptions{})
+	f := writeOpen(t, fs, "foo", "hello")
+	assert.NoError(t, f.Close())
+	assert.NoError(t, hackpadfs.Remove(fs, "foo"))
+
+	assert.NoError(t, fs.Flush())
+	_, err := hackpadfs.Stat(source, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	entries, err := hackpadfs.ReadDir(cache, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
+
+func TestWriteBackFSKeepsUnflushedWrites(t *testing.T) {
+	t.Parallel()
+	fs, source, _ := newWriteBackFS(t, WriteBackOptions{
+		ReadThroughOptions: ReadThroughOptions{MaxBytes: 4},
+	})
+	f := writeOpen(t, fs, "foo", "hello")
+	assert.NoError(t, f.Close())
+	assert.NoError(t, hackpadfs.WriteFullFile(source, "bar", []byte("bar"), 0600))
+	assertReadFile(t, fs, "bar", "bar")
+
+	// neither evicted past MaxBytes, nor invalidated
+	fs.InvalidateAll()
+	assertReadFile(t, fs, "foo", "hello")
+	assert.NoError(t, fs.Flush())
+	assertReadFile(t, source, "foo", "hello")
+
+	// once flushed, evicted like any other file
+	assertReadFile(t, fs, "bar", "bar")
+	assert.Equal(t, int64(3), fs.readThrough.size)
+}
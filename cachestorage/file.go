@@ -0,0 +1,106 @@
+//go:build wasm
+// +build wasm
+
+package cachestorage
+
+import (
+	"io"
+	"path"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    hackpadfs.FileMode
+	modTime time.Time
+}
+
+func (f *fileInfo) Name() string                      { return f.name }
+func (f *fileInfo) Size() int64                       { return f.size }
+func (f *fileInfo) Mode() hackpadfs.FileMode          { return f.mode }
+func (f *fileInfo) ModTime() time.Time                { return f.modTime }
+func (f *fileInfo) IsDir() bool                       { return f.mode.IsDir() }
+func (f *fileInfo) Sys() interface{}                  { return nil }
+func (f *fileInfo) Type() hackpadfs.FileMode          { return f.mode.Type() }
+func (f *fileInfo) Info() (hackpadfs.FileInfo, error) { return f, nil }
+
+type file struct {
+	info   *fileInfo
+	reader *io.SectionReader
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return f.reader.ReadAt(p, off)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *file) Close() error {
+	return nil
+}
+
+type dir struct {
+	fs     *FS
+	name   string
+	names  []string
+	offset int
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.name, Err: hackpadfs.ErrIsDir}
+}
+
+func (d *dir) Stat() (hackpadfs.FileInfo, error) {
+	return d.fs.Stat(d.name)
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	names := d.names[d.offset:]
+	if n > 0 {
+		if len(names) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(names) {
+			names = names[:n]
+		}
+	}
+	d.offset += len(names)
+	entries := make([]hackpadfs.DirEntry, 0, len(names))
+	for _, name := range names {
+		mode := hackpadfs.FileMode(0444)
+		if _, isDir := d.fs.dirs[path.Join(d.name, name)]; isDir {
+			mode = hackpadfs.ModeDir | 0555
+		}
+		entries = append(entries, &dirEntry{fs: d.fs, path: path.Join(d.name, name), mode: mode})
+	}
+	return entries, nil
+}
+
+// dirEntry fetches its file info on the first call to Info(), to avoid fetching every response in a directory
+type dirEntry struct {
+	fs   *FS
+	path string
+	mode hackpadfs.FileMode
+}
+
+func (e *dirEntry) Name() string                      { return path.Base(e.path) }
+func (e *dirEntry) IsDir() bool                       { return e.mode.IsDir() }
+func (e *dirEntry) Type() hackpadfs.FileMode          { return e.mode.Type() }
+func (e *dirEntry) Info() (hackpadfs.FileInfo, error) { return e.fs.Stat(e.path) }
@@ -0,0 +1,284 @@
+//go:build wasm
+// +build wasm
+
+// Package cachestorage contains a read-only file system for the browser's Cache Storage, like a service worker's precached assets.
+package cachestorage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/jspromise"
+	"github.com/hack-pad/hackpadfs/jsfile"
+	"github.com/hack-pad/safejs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.StatFS
+		hackpadfs.ReadDirFS
+	} = &FS{}
+)
+
+// FS is a read-only file system of the responses in a Cache.
+// Each cached request URL beneath the base URL is a file. Directories are inferred from the file paths.
+//
+// The list of files is read once, in NewFS(). Responses are read on demand, when a file is read.
+type FS struct {
+	cache safejs.Value        // Cache
+	files map[string]string   // file path -> request URL
+	dirs  map[string][]string // dir path -> sorted entry names
+}
+
+// Options provides configuration options for a new FS.
+type Options struct {
+	// BaseURL is the URL of the root directory. Cached URLs outside BaseURL are ignored. Defaults to the current origin, i.e. location.origin + "/".
+	BaseURL string
+	// CacheStorage is the CacheStorage to open the cache from. Defaults to the global 'caches'.
+	CacheStorage js.Value
+}
+
+// NewFS returns a new FS for the cache named 'cacheName'.
+// Returns an error wrapping hackpadfs.ErrNotExist if the cache does not exist, or hackpadfs.ErrNotImplemented if the Cache API is not supported.
+func NewFS(ctx context.Context, cacheName string, options Options) (*FS, error) {
+	caches := safejs.Safe(options.CacheStorage)
+	if !options.CacheStorage.Truthy() {
+		var err error
+		caches, err = safejs.Global().Get("caches")
+		if err != nil {
+			return nil, err
+		}
+		if caches.IsUndefined() {
+			return nil, &hackpadfs.PathError{Op: "open", Path: cacheName, Err: hackpadfs.ErrNotImplemented}
+		}
+	}
+	baseURL, err := parseBaseURL(options.BaseURL)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: cacheName, Err: err}
+	}
+
+	jsHasCache, err := jspromise.Call(ctx, caches, "has", cacheName)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: cacheName, Err: err}
+	}
+	hasCache, err := jsHasCache.Bool()
+	if err != nil {
+		return nil, err
+	}
+	if !hasCache {
+		return nil, &hackpadfs.PathError{Op: "open", Path: cacheName, Err: hackpadfs.ErrNotExist}
+	}
+	cache, err := jspromise.Call(ctx, caches, "open", cacheName)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: cacheName, Err: err}
+	}
+	requests, err := jspromise.Call(ctx, cache, "keys")
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: cacheName, Err: err}
+	}
+	fs := &FS{
+		cache: cache,
+		files: make(map[string]string),
+		dirs:  map[string][]string{".": nil},
+	}
+	length, err := requests.Length()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < length; i++ {
+		request, err := requests.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		jsURL, err := request.Get("url")
+		if err != nil {
+			return nil, err
+		}
+		requestURL, err := jsURL.String()
+		if err != nil {
+			return nil, err
+		}
+		if filePath, ok := relativePath(baseURL, requestURL); ok {
+			fs.addFile(filePath, requestURL)
+		}
+	}
+	for _, names := range fs.dirs {
+		sort.Strings(names)
+	}
+	return fs, nil
+}
+
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	if baseURL == "" {
+		location, err := safejs.Global().Get("location")
+		if err != nil {
+			return nil, err
+		}
+		if location.IsUndefined() {
+			return nil, hackpadfs.ErrInvalid
+		}
+		jsOrigin, err := location.Get("origin")
+		if err != nil {
+			return nil, err
+		}
+		origin, err := jsOrigin.String()
+		if err != nil {
+			return nil, err
+		}
+		baseURL = origin + "/"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
+}
+
+// relativePath returns the FS path of 'requestURL' inside 'baseURL'
+func relativePath(baseURL *url.URL, requestURL string) (string, bool) {
+	u, err := url.Parse(requestURL)
+	if err != nil || u.Scheme != baseURL.Scheme || u.Host != baseURL.Host || u.RawQuery != "" {
+		return "", false
+	}
+	if !strings.HasPrefix(u.Path, baseURL.Path) {
+		return "", false
+	}
+	filePath := strings.TrimPrefix(u.Path, baseURL.Path)
+	if filePath == "" || strings.HasSuffix(filePath, "/") || !hackpadfs.ValidPath(filePath) {
+		return "", false // only files with valid names can be opened
+	}
+	return filePath, true
+}
+
+func (fs *FS) addFile(filePath, requestURL string) {
+	if _, exists := fs.files[filePath]; exists {
+		return
+	}
+	if _, isDir := fs.dirs[filePath]; isDir {
+		return // can't be both a file and a directory
+	}
+	fs.files[filePath] = requestURL
+	for p := filePath; p != "."; p = path.Dir(p) {
+		dir := path.Dir(p)
+		_, dirExists := fs.dirs[dir]
+		fs.dirs[dir] = append(fs.dirs[dir], path.Base(p))
+		if dirExists {
+			return
+		}
+		if _, isFile := fs.files[dir]; isFile {
+			delete(fs.files, dir) // a directory takes precedence over a file of the same name
+		}
+	}
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if names, isDir := fs.dirs[name]; isDir {
+		return &dir{fs: fs, name: name, names: names}, nil
+	}
+	info, body, err := fs.fetch("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{info: info, reader: io.NewSectionReader(body, 0, info.size)}, nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if _, isDir := fs.dirs[name]; isDir {
+		return &fileInfo{name: path.Base(name), mode: hackpadfs.ModeDir | 0555}, nil
+	}
+	info, _, err := fs.fetch("stat", name)
+	return info, err
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	names, isDir := fs.dirs[name]
+	if !isDir {
+		if _, isFile := fs.files[name]; isFile {
+			return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+		}
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	return (&dir{fs: fs, name: name, names: names}).ReadDir(-1)
+}
+
+// fetch returns the file info and body for the response at 'name'. The body is read on demand.
+func (fs *FS) fetch(op, name string) (*fileInfo, *jsfile.File, error) {
+	requestURL, ok := fs.files[name]
+	if !ok {
+		return nil, nil, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	ctx := context.Background()
+	response, err := jspromise.Call(ctx, fs.cache, "match", requestURL)
+	if err != nil {
+		return nil, nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	if response.IsUndefined() {
+		// removed from the cache since NewFS()
+		return nil, nil, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	jsBody, err := jspromise.Call(ctx, response, "blob")
+	if err != nil {
+		return nil, nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	body, err := jsfile.New(safejs.Unsafe(jsBody))
+	if err != nil {
+		return nil, nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	bodyInfo, err := body.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	info := &fileInfo{
+		name:    path.Base(name),
+		size:    bodyInfo.Size(),
+		mode:    0444,
+		modTime: responseModTime(response),
+	}
+	return info, body, nil
+}
+
+// responseModTime returns the time from the Last-Modified header, or the Date header if not set. Returns a zero time if neither is set.
+func responseModTime(response safejs.Value) time.Time {
+	headers, err := response.Get("headers")
+	if err != nil {
+		return time.Time{}
+	}
+	for _, header := range []string{"Last-Modified", "Date"} {
+		value, err := headers.Call("get", header)
+		if err != nil || value.IsNull() {
+			continue
+		}
+		str, err := value.String()
+		if err != nil {
+			continue
+		}
+		if modTime, err := http.ParseTime(str); err == nil {
+			return modTime
+		}
+	}
+	return time.Time{}
+}
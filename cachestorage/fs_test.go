@@ -0,0 +1,109 @@
+//go:build wasm
+// +build wasm
+
+package cachestorage
+
+import (
+	"context"
+	"io"
+	"syscall/js"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+const testBaseURL = "https://example.com/app/"
+
+// newTestCacheStorage returns a minimal CacheStorage with one cache named "test", holding a response for each URL in 'files'
+func newTestCacheStorage(files map[string]string) js.Value {
+	entries := make(map[string]any, len(files))
+	for u, body := range files {
+		entries[u] = body
+	}
+	makeCacheStorage := js.Global().Get("Function").New("entries", `
+		const cache = {
+			keys: async () => Object.keys(entries).map(url => new Request(url)),
+			match: async request => {
+				const url = typeof request === "string" ? request : request.url;
+				if (!(url in entries)) {
+					return undefined;
+				}
+				return new Response(entries[url], {headers: {"Last-Modified": "Wed, 21 Oct 2015 07:28:00 GMT"}});
+			},
+		};
+		return {
+			has: async name => name === "test",
+			open: async () => cache,
+		};
+	`)
+	return makeCacheStorage.Invoke(entries)
+}
+
+func makeFS(tb testing.TB, files map[string]string) *FS {
+	tb.Helper()
+	fs, err := NewFS(context.Background(), "test", Options{
+		BaseURL:      testBaseURL,
+		CacheStorage: newTestCacheStorage(files),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, map[string]string{
+		testBaseURL + "index.html":         "<html></html>",
+		testBaseURL + "assets/app.js":      "console.log('hi')",
+		testBaseURL + "assets/css/app.css": "body {}",
+		testBaseURL + "search?q=ignored":   "ignored",
+		"https://example.com/other.html":   "ignored",
+		"https://cdn.example.com/app/x.js": "ignored",
+	})
+	assert.NoError(t, fstest.TestFS(fs, "index.html", "assets/app.js", "assets/css/app.css"))
+
+	info, err := fs.Stat("assets/app.js")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("console.log('hi')")), info.Size())
+	assert.Equal(t, hackpadfs.FileMode(0444), info.Mode())
+	assert.Equal(t, time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC), info.ModTime().UTC())
+
+	entries, err := fs.ReadDir(".")
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"assets", "index.html"}, names)
+
+	_, err = fs.Open("other.html")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestReadAt(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, map[string]string{
+		testBaseURL + "foo": "hello world",
+	})
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, f.Close()) }()
+
+	buf := make([]byte, 5)
+	n, err := f.(io.ReaderAt).ReadAt(buf, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+}
+
+func TestMissingCache(t *testing.T) {
+	t.Parallel()
+	_, err := NewFS(context.Background(), "missing", Options{
+		BaseURL:      testBaseURL,
+		CacheStorage: newTestCacheStorage(nil),
+	})
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
@@ -0,0 +1,473 @@
+// Package chroot contains a file system wrapper which confines all operations to a directory, even through symlinks.
+package chroot
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const maxSymlinkHops = 40 // same limit as Linux's MAXSYMLINKS
+
+var errTooManyLinks = syscall.ELOOP
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.SubFS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+)
+
+// FS confines a file system to a root directory, like chroot(2). Suitable for serving untrusted paths.
+//
+// Unlike hackpadfs.Sub(), every symlink is resolved and checked before use, and operations are passed to the wrapped FS with the resolved path.
+// Symlinks which resolve outside the root fail with hackpadfs.ErrPermission. Absolute symlink targets are relative to the root.
+// Resolving costs one Lstat per path element. If the wrapped FS doesn't implement Lstat, it can't report symlinks, so paths are only confined lexically.
+//
+// Errors report paths relative to the root, so they don't reveal the root's location.
+// Paths are resolved before each operation, so the wrapped FS must not be modified concurrently by anyone able to create symlinks outside the FS.
+type FS struct {
+	fs   hackpadfs.FS
+	root string
+}
+
+// NewFS returns an FS confined to directory 'dir' of 'fs'. Symlinks in 'dir' are resolved once, by NewFS.
+func NewFS(fs hackpadfs.FS, dir string) (*FS, error) {
+	unconfined := &FS{fs: fs, root: "."}
+	root, err := unconfined.resolve("chroot", dir, true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := hackpadfs.Stat(fs, root)
+	if err != nil {
+		return nil, unconfined.withPath(err, dir, root)
+	}
+	if !info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "chroot", Path: dir, Err: hackpadfs.ErrNotDir}
+	}
+	return &FS{fs: fs, root: root}, nil
+}
+
+// resolve returns the wrapped FS's path for 'name', after following symlinks.
+// Symlinks in parent directories are always followed. The final path element is only followed if 'followLast' is set.
+//
+// Symlink targets are resolved one element at a time, so ".." in a target refers to the parent of the directory reached so far, like os.Readlink() targets.
+func (fs *FS) resolve(op, name string, followLast bool) (string, error) {
+	if !hackpadfs.ValidPath(name) {
+		return "", &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	resolved, remaining := ".", name // paths relative to fs.root. 'remaining' may contain ".." after following a symlink.
+	for hops := 0; remaining != ""; {
+		elem, rest, _ := cut(remaining, "/")
+		remaining = rest
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if resolved == "." {
+				return "", &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrPermission}
+			}
+			resolved = path.Dir(resolved)
+			continue
+		}
+		next := path.Join(resolved, elem)
+		if !followLast && isLast(rest) {
+			return fs.rootPath(path.Join(next, rest)), nil
+		}
+		info, err := hackpadfs.Lstat(fs.fs, fs.rootPath(next))
+		switch {
+		case errors.Is(err, hackpadfs.ErrNotImplemented):
+			return fs.rootPath(name), nil
+		case errors.Is(err, hackpadfs.ErrNotExist):
+			// nothing below a missing file can be a symlink
+			return fs.rootPath(path.Join(next, rest)), nil
+		case err != nil:
+			return "", fs.withPath(err, name, "")
+		case info.Mode()&hackpadfs.ModeSymlink == 0:
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", &hackpadfs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+		}
+		target, err := hackpadfs.Readlink(fs.fs, fs.rootPath(next))
+		if err != nil {
+			return "", fs.withPath(err, name, "")
+		}
+		if path.IsAbs(target) {
+			resolved = "."
+		}
+		remaining = target + "/" + rest
+	}
+	return fs.rootPath(resolved), nil
+}
+
+// isLast returns true if no path elements remain in 'rest'
+func isLast(rest string) bool {
+	return strings.Trim(rest, "/.") == "" && !strings.Contains(rest, "..")
+}
+
+// rootPath returns the wrapped FS's path for 'name', relative to fs.root
+func (fs *FS) rootPath(name string) string {
+	return path.Join(fs.root, name)
+}
+
+// relPath returns 'p' relative to fs.root. Returns false if 'p' is outside fs.root.
+func (fs *FS) relPath(p string) (string, bool) {
+	switch {
+	case fs.root == ".":
+		return p, true
+	case p == fs.root:
+		return ".", true
+	case strings.HasPrefix(p, fs.root+"/"):
+		return strings.TrimPrefix(p, fs.root+"/"), true
+	default:
+		return "", false
+	}
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// withPath replaces the wrapped FS's path in 'err', so errors don't reveal the root directory.
+// Errors for 'resolved' report 'name', other paths are reported relative to the root.
+func (fs *FS) withPath(err error, name, resolved string) error {
+	var pathErr *hackpadfs.PathError
+	if !errors.As(err, &pathErr) {
+		return err
+	}
+	errPath, ok := fs.relPath(pathErr.Path)
+	if pathErr.Path == resolved || !ok {
+		errPath = name
+	}
+	return &hackpadfs.PathError{Op: pathErr.Op, Path: errPath, Err: pathErr.Err}
+}
+
+// withLinkPaths replaces the wrapped FS's paths in 'err' with 'oldname' and 'newname', for operations on two paths
+func (fs *FS) withLinkPaths(err error, oldname, oldPath, newname, newPath string) error {
+	var linkErr *hackpadfs.LinkError
+	if errors.As(err, &linkErr) {
+		return &hackpadfs.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) && pathErr.Path == newPath && newPath != oldPath {
+		return fs.withPath(err, newname, newPath)
+	}
+	return fs.withPath(err, oldname, oldPath)
+}
+
+// Sub implements hackpadfs.SubFS. The returned FS is confined to 'dir'.
+func (fs *FS) Sub(dir string) (hackpadfs.FS, error) {
+	root, err := fs.resolve("sub", dir, true)
+	if err != nil {
+		return nil, err
+	}
+	return &FS{fs: fs.fs, root: root}, nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	p, err := fs.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fs.fs.Open(p)
+	return f, fs.withPath(err, name, p)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	p, err := fs.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := hackpadfs.OpenFile(fs.fs, p, flag, perm)
+	return f, fs.withPath(err, name, p)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	p, err := fs.resolve("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+	return fs.withPath(hackpadfs.Mkdir(fs.fs, p, perm), name, p)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	p, err := fs.resolve("mkdirall", path, true)
+	if err != nil {
+		return err
+	}
+	return fs.withPath(hackpadfs.MkdirAll(fs.fs, p, perm), path, p)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	p, err := fs.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if p == fs.root {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrPermission}
+	}
+	return fs.withPath(hackpadfs.Remove(fs.fs, p), name, p)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	p, err := fs.resolve("removeall", path, false)
+	if err != nil {
+		return err
+	}
+	if p == fs.root {
+		return &hackpadfs.PathError{Op: "removeall", Path: path, Err: hackpadfs.ErrPermission}
+	}
+	return fs.withPath(hackpadfs.RemoveAll(fs.fs, p), path, p)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	oldPath, newPath, err := fs.resolveLink("rename", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return fs.withLinkPaths(hackpadfs.Rename(fs.fs, oldPath, newPath), oldname, oldPath, newname, newPath)
+}
+
+func (fs *FS) resolveLink(op, oldname, newname string) (oldPath, newPath string, err error) {
+	oldPath, err = fs.resolve(op, oldname, false)
+	if err == nil {
+		newPath, err = fs.resolve(op, newname, false)
+	}
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		err = &hackpadfs.LinkError{Op: op, Old: oldname, New: newname, Err: pathErr.Err}
+	}
+	return oldPath, newPath, err
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	p, err := fs.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info, err := hackpadfs.Stat(fs.fs, p)
+	if err != nil {
+		return nil, fs.withPath(err, name, p)
+	}
+	if base := path.Base(name); info.Name() != base && name != "." {
+		info = &namedFileInfo{FileInfo: info, name: base}
+	}
+	return info, nil
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	p, err := fs.resolve("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info, err := hackpadfs.Lstat(fs.fs, p)
+	return info, fs.withPath(err, name, p)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	p, err := fs.resolve("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	return fs.withPath(hackpadfs.Chmod(fs.fs, p, mode), name, p)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	p, err := fs.resolve("chown", name, true)
+	if err != nil {
+		return err
+	}
+	return fs.withPath(hackpadfs.Chown(fs.fs, p, uid, gid), name, p)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	p, err := fs.resolve("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	return fs.withPath(hackpadfs.Chtimes(fs.fs, p, atime, mtime), name, p)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	p, err := fs.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := hackpadfs.ReadDir(fs.fs, p)
+	return entries, fs.withPath(err, name, p)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	p, err := fs.resolve("readfile", name, true)
+	if err != nil {
+		return nil, err
+	}
+	b, err := hackpadfs.ReadFile(fs.fs, p)
+	return b, fs.withPath(err, name, p)
+}
+
+// Symlink implements hackpadfs.SymlinkFS. Targets are stored unchanged, and only checked when followed.
+func (fs *FS) Symlink(oldname, newname string) error {
+	p, err := fs.resolve("symlink", newname, false)
+	if err != nil {
+		var pathErr *hackpadfs.PathError
+		if errors.As(err, &pathErr) {
+			err = &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: pathErr.Err}
+		}
+		return err
+	}
+	return fs.withLinkPaths(hackpadfs.Symlink(fs.fs, oldname, p), oldname, "", newname, p)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	oldPath, newPath, err := fs.resolveLink("link", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return fs.withLinkPaths(hackpadfs.Link(fs.fs, oldPath, newPath), oldname, oldPath, newname, newPath)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	p, err := fs.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	target, err := hackpadfs.Readlink(fs.fs, p)
+	return target, fs.withPath(err, name, p)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	p, err := fs.resolve("lock", name, true)
+	if err != nil {
+		return nil, err
+	}
+	unlocker, err := hackpadfs.Lock(fs.fs, p, mode)
+	return unlocker, fs.withPath(err, name, p)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	p, err := fs.resolve("statfs", name, true)
+	if err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	usage, err := hackpadfs.Statfs(fs.fs, p)
+	return usage, fs.withPath(err, name, p)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	p, err := fs.resolve("watch", name, true)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := hackpadfs.Watch(fs.fs, p)
+	if err != nil {
+		return nil, fs.withPath(err, name, p)
+	}
+	if fs.root == "." {
+		return watcher, nil
+	}
+	return newRootWatcher(watcher, fs), nil
+}
+
+// rootWatcher reports event names relative to the root directory
+type rootWatcher struct {
+	watcher   hackpadfs.Watcher
+	events    chan hackpadfs.WatchEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newRootWatcher(watcher hackpadfs.Watcher, fs *FS) *rootWatcher {
+	w := &rootWatcher{
+		watcher: watcher,
+		events:  make(chan hackpadfs.WatchEvent),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(w.events)
+		for event := range watcher.Events() {
+			name, ok := fs.relPath(event.Name)
+			if !ok {
+				continue
+			}
+			event.Name = name
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+func (w *rootWatcher) Events() <-chan hackpadfs.WatchEvent {
+	return w.events
+}
+
+func (w *rootWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	return w.watcher.Close()
+}
+
+// namedFileInfo reports the name of a symlink, instead of its target
+type namedFileInfo struct {
+	hackpadfs.FileInfo
+	name string
+}
+
+func (i *namedFileInfo) Name() string {
+	return i.name
+}
@@ -0,0 +1,218 @@
+package chroot_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/chroot"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+const rootDir = "chroot-root"
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+// setupRoot moves everything in 'fs' into rootDir, then returns an FS confined to it
+func setupRoot(tb testing.TB, fs *mem.FS) *chroot.FS {
+	tb.Helper()
+	requireNoError(tb, fs.Mkdir(rootDir, 0700))
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	requireNoError(tb, err)
+	for _, entry := range entries {
+		if entry.Name() != rootDir {
+			requireNoError(tb, fs.Rename(entry.Name(), path.Join(rootDir, entry.Name())))
+		}
+	}
+	chrootFS, err := chroot.NewFS(fs, rootDir)
+	requireNoError(tb, err)
+	return chrootFS
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "chroot",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			fs, err := mem.NewFS()
+			requireNoError(tb, err)
+			return fs, func() hackpadfs.FS {
+				return setupRoot(tb, fs)
+			}
+		}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	options.Constraints.AllowErrPathPrefix = true
+	fstest.File(t, options)
+}
+
+func newFS(t *testing.T) (*mem.FS, *chroot.FS) {
+	t.Helper()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "secret", []byte("secret"), 0600))
+	requireNoError(t, hackpadfs.MkdirAll(fs, path.Join(rootDir, "dir"), 0700))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, path.Join(rootDir, "dir", "file"), []byte("file"), 0600))
+	chrootFS, err := chroot.NewFS(fs, rootDir)
+	requireNoError(t, err)
+	return fs, chrootFS
+}
+
+func TestNewFS(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "file", nil, 0600))
+
+	_, err = chroot.NewFS(fs, "missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = chroot.NewFS(fs, "file")
+	assert.ErrorIs(t, hackpadfs.ErrNotDir, err)
+	_, err = chroot.NewFS(fs, "../dir")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestEscapes(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		target      string
+		link        string
+		name        string
+	}{
+		{description: "relative target", target: "../secret", link: "link", name: "link"},
+		{description: "relative target in subdirectory", target: "../../secret", link: "dir/link", name: "dir/link"},
+		{description: "parent directory", target: "..", link: "link", name: "link/secret"},
+		{description: "target above FS root", target: "../../../secret", link: "link", name: "link"},
+		{description: "parent of symlinked directory", target: "dir/self/../..", link: "link", name: "link/secret"},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			fs, chrootFS := newFS(t)
+			requireNoError(t, chrootFS.Symlink(".", "dir/self"))
+			requireNoError(t, chrootFS.Symlink(tc.target, tc.link))
+
+			_, err := chrootFS.Open(tc.name)
+			assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+			_, err = chrootFS.Stat(tc.name)
+			assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+			_, err = chrootFS.OpenFile(tc.name, hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate, 0)
+			assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+			assert.ErrorIs(t, hackpadfs.ErrPermission, chrootFS.Chmod(tc.name, 0777))
+
+			b, err := hackpadfs.ReadFile(fs, "secret")
+			assert.NoError(t, err)
+			assert.Equal(t, "secret", string(b))
+		})
+	}
+
+	t.Run("through parent symlink", func(t *testing.T) {
+		t.Parallel()
+		_, chrootFS := newFS(t)
+		requireNoError(t, chrootFS.Symlink("..", "dir/up"))
+		requireNoError(t, chrootFS.Symlink("dir/up/..", "out"))
+		_, err := chrootFS.Lstat("out/secret")
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = chrootFS.Rename("dir/file", "out/file")
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		_, err = chrootFS.Lstat("out")
+		assert.NoError(t, err)
+	})
+
+	t.Run("remove root", func(t *testing.T) {
+		t.Parallel()
+		_, chrootFS := newFS(t)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, chrootFS.RemoveAll("."))
+		_, err := chrootFS.Stat("dir/file")
+		assert.NoError(t, err)
+	})
+}
+
+func TestSymlinksInsideRoot(t *testing.T) {
+	t.Parallel()
+	_, chrootFS := newFS(t)
+	requireNoError(t, chrootFS.Symlink("/dir", "dir/abs"))
+	requireNoError(t, chrootFS.Symlink("../dir/file", "dir/rel"))
+	requireNoError(t, chrootFS.Symlink("loop", "loop"))
+
+	b, err := chrootFS.ReadFile("dir/abs/file")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", string(b))
+	b, err = chrootFS.ReadFile("dir/rel")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", string(b))
+	_, err = chrootFS.Stat("loop")
+	assert.Error(t, err)
+	_, err = chrootFS.Lstat("loop")
+	assert.NoError(t, err)
+}
+
+func TestErrorPaths(t *testing.T) {
+	t.Parallel()
+	_, chrootFS := newFS(t)
+	requireNoError(t, chrootFS.Symlink("dir", "link"))
+	_, err := chrootFS.Stat("link/missing")
+	assert.Equal(t, &hackpadfs.PathError{Op: "stat", Path: "link/missing", Err: hackpadfs.ErrNotExist}, err)
+}
+
+func TestSub(t *testing.T) {
+	t.Parallel()
+	_, chrootFS := newFS(t)
+	requireNoError(t, chrootFS.Symlink("dir", "link"))
+	requireNoError(t, hackpadfs.WriteFullFile(chrootFS, "top", nil, 0600))
+	requireNoError(t, chrootFS.Symlink("../top", "dir/top"))
+
+	subFS, err := hackpadfs.Sub(chrootFS, "link")
+	requireNoError(t, err)
+	b, err := hackpadfs.ReadFile(subFS, "file")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", string(b))
+	_, err = hackpadfs.ReadFile(subFS, "top")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+}
+
+type watchFS struct {
+	*mem.FS
+	events chan hackpadfs.WatchEvent
+}
+
+func (fs *watchFS) Watch(name string) (hackpadfs.Watcher, error) {
+	return fs, nil
+}
+
+func (fs *watchFS) Events() <-chan hackpadfs.WatchEvent {
+	return fs.events
+}
+
+func (fs *watchFS) Close() error {
+	close(fs.events)
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	fs, _ := newFS(t)
+	wFS := &watchFS{FS: fs, events: make(chan hackpadfs.WatchEvent, 3)}
+	chrootFS, err := chroot.NewFS(wFS, rootDir)
+	requireNoError(t, err)
+	watcher, err := chrootFS.Watch(".")
+	requireNoError(t, err)
+
+	wFS.events <- hackpadfs.WatchEvent{Name: "secret", Op: hackpadfs.WatchWrite}
+	wFS.events <- hackpadfs.WatchEvent{Name: rootDir, Op: hackpadfs.WatchWrite}
+	wFS.events <- hackpadfs.WatchEvent{Name: path.Join(rootDir, "dir/file"), Op: hackpadfs.WatchRemove}
+	assert.Equal(t, hackpadfs.WatchEvent{Name: ".", Op: hackpadfs.WatchWrite}, <-watcher.Events())
+	assert.Equal(t, hackpadfs.WatchEvent{Name: "dir/file", Op: hackpadfs.WatchRemove}, <-watcher.Events())
+	assert.NoError(t, watcher.Close())
+	_, open := <-watcher.Events()
+	assert.Equal(t, false, open)
+}
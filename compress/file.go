@@ -0,0 +1,432 @@
+package compress
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+var errNegativeOffset = errors.New("negative offset")
+
+// file compresses and decompresses an open file's contents in blocks
+type file struct {
+	file hackpadfs.File
+	name string
+	flag int
+	fs   *FS
+
+	mu      sync.Mutex
+	offset  int64
+	loaded  bool
+	regular bool
+	stored  layout // blocks currently stored in the file
+	size    int64  // uncompressed size, including unstored changes
+	// dirty holds changed blocks until they are stored by flush. Blocks from firstDirty onward are rewritten, since their offsets may shift.
+	dirty      map[int64][]byte
+	firstDirty int64
+	// cached holds the most recently decompressed stored block, to speed up sequential reads
+	cachedIndex int64
+	cached      []byte
+}
+
+func newFile(fs *FS, f hackpadfs.File, name string, flag int) *file {
+	return &file{
+		file:        f,
+		name:        name,
+		flag:        flag,
+		fs:          fs,
+		dirty:       make(map[int64][]byte),
+		firstDirty:  -1,
+		cachedIndex: -1,
+	}
+}
+
+func (f *file) corruptErr(op string) error {
+	return &hackpadfs.PathError{Op: op, Path: f.name, Err: ErrCorrupt}
+}
+
+// load reads the stored layout, if it hasn't been read yet
+func (f *file) load(op string) error {
+	if f.loaded {
+		return nil
+	}
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	f.regular = info.Mode().IsRegular()
+	if f.regular {
+		f.stored, err = readLayout(f.file, info.Size(), f.fs.blockSize)
+		if errors.Is(err, ErrCorrupt) {
+			return f.corruptErr(op)
+		}
+		if err != nil {
+			return err
+		}
+		f.size = f.stored.size
+	}
+	f.loaded = true
+	return nil
+}
+
+// storedBlock decompresses stored block 'index'
+func (f *file) storedBlock(op string, index int64) ([]byte, error) {
+	if index == f.cachedIndex {
+		return f.cached, nil
+	}
+	compressed := make([]byte, f.stored.offsets[index+1]-f.stored.offsets[index])
+	if err := readFullAt(f.file, compressed, f.stored.offsets[index]); err != nil {
+		if errors.Is(err, ErrCorrupt) {
+			return nil, f.corruptErr(op)
+		}
+		return nil, err
+	}
+	length := blockLen(index, f.stored.size, f.stored.blockSize)
+	block, err := f.fs.decoder.DecodeAll(compressed, make([]byte, 0, length))
+	if err != nil || int64(len(block)) != length {
+		return nil, f.corruptErr(op)
+	}
+	f.cachedIndex, f.cached = index, block
+	return block, nil
+}
+
+// block returns the current contents of block 'index', exactly as long as the file's current size allows.
+// The returned slice must not be modified, unless it is a dirty block.
+func (f *file) block(op string, index int64) ([]byte, error) {
+	length := blockLen(index, f.size, f.stored.blockSize)
+	block, isDirty := f.dirty[index]
+	if !isDirty && index < f.stored.blockCount() {
+		var err error
+		block, err = f.storedBlock(op, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case int64(len(block)) > length:
+		return block[:length], nil
+	case int64(len(block)) < length:
+		grown := make([]byte, length)
+		copy(grown, block)
+		return grown, nil
+	default:
+		return block, nil
+	}
+}
+
+func (f *file) markDirty(index int64) {
+	if f.firstDirty < 0 || index < f.firstDirty {
+		f.firstDirty = index
+	}
+}
+
+// resize changes the uncompressed size. Marks blocks from the smaller of the old and new ends as dirty, since their lengths change.
+func (f *file) resize(size int64) {
+	boundary := f.size
+	if size < boundary {
+		boundary = size
+	}
+	f.markDirty(boundary / f.stored.blockSize)
+	newCount := blockCount(size, f.stored.blockSize)
+	for index, block := range f.dirty {
+		switch {
+		case index >= newCount:
+			delete(f.dirty, index)
+		case int64(len(block)) > blockLen(index, size, f.stored.blockSize):
+			f.dirty[index] = block[:blockLen(index, size, f.stored.blockSize)]
+		}
+	}
+	f.size = size
+}
+
+// flush stores all dirty blocks, then rewrites the index and footer
+func (f *file) flush(op string) error {
+	if f.firstDirty < 0 {
+		return nil
+	}
+	newCount := blockCount(f.size, f.stored.blockSize)
+	first := f.firstDirty
+	if first > newCount {
+		first = newCount
+	}
+	if first > f.stored.blockCount() {
+		first = f.stored.blockCount()
+	}
+	start := f.stored.offsets[first]
+	newLayout := layout{
+		size:      f.size,
+		blockSize: f.stored.blockSize,
+		offsets:   append(make([]int64, 0, newCount+1), f.stored.offsets[:first+1]...),
+	}
+	var buf []byte
+	for index := first; index < newCount; index++ {
+		_, isDirty := f.dirty[index]
+		length := blockLen(index, f.size, f.stored.blockSize)
+		if !isDirty && index < f.stored.blockCount() && blockLen(index, f.stored.size, f.stored.blockSize) == length {
+			// unchanged, so move the compressed block as-is
+			compressed := make([]byte, f.stored.offsets[index+1]-f.stored.offsets[index])
+			if err := readFullAt(f.file, compressed, f.stored.offsets[index]); err != nil {
+				if errors.Is(err, ErrCorrupt) {
+					return f.corruptErr(op)
+				}
+				return err
+			}
+			buf = append(buf, compressed...)
+		} else {
+			block, err := f.block(op, index)
+			if err != nil {
+				return err
+			}
+			buf = f.fs.encoder.EncodeAll(block, buf)
+		}
+		newLayout.offsets = append(newLayout.offsets, start+int64(len(buf)))
+	}
+
+	if newCount > 0 {
+		buf = appendIndex(buf, newLayout)
+	}
+	if _, err := hackpadfs.WriteAtFile(f.file, buf, start); err != nil {
+		return err
+	}
+	if err := hackpadfs.TruncateFile(f.file, start+int64(len(buf))); err != nil {
+		return err
+	}
+	f.stored = newLayout
+	f.dirty = make(map[int64][]byte)
+	f.firstDirty = -1
+	f.cachedIndex, f.cached = -1, nil
+	return nil
+}
+
+func (f *file) readAt(op string, p []byte, off int64) (int, error) {
+	if err := f.load(op); err != nil {
+		return 0, err
+	}
+	if !f.regular {
+		return hackpadfs.ReadAtFile(f.file, p, off)
+	}
+	if off >= f.size {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && off+int64(n) < f.size {
+		pos := off + int64(n)
+		index := pos / f.stored.blockSize
+		block, err := f.block(op, index)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], block[pos-index*f.stored.blockSize:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *file) writeAt(op string, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: op, Path: f.name, Err: errNegativeOffset}
+	}
+	if err := f.load(op); err != nil {
+		return 0, err
+	}
+	if !f.regular || f.flag&(hackpadfs.FlagWriteOnly|hackpadfs.FlagReadWrite) == 0 {
+		// let the wrapped FS report why the write failed
+		return hackpadfs.WriteAtFile(f.file, p, off)
+	}
+	end := off + int64(len(p))
+	if end > f.size {
+		f.resize(end)
+	}
+	blockSize := f.stored.blockSize
+	for index := off / blockSize; index*blockSize < end; index++ {
+		block, err := f.block(op, index)
+		if err != nil {
+			return 0, err
+		}
+		if _, isDirty := f.dirty[index]; !isDirty {
+			block = append([]byte(nil), block...)
+		}
+		blockStart := index * blockSize
+		lo := off
+		if lo < blockStart {
+			lo = blockStart
+		}
+		copy(block[lo-blockStart:], p[lo-off:])
+		f.dirty[index] = block
+		f.markDirty(index)
+	}
+	// store changes immediately, so they are visible to other readers of the file
+	if err := f.flush(op); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt("read", p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: f.name, Err: errNegativeOffset}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAt("readat", p, off)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load("write"); err != nil {
+		return 0, err
+	}
+	if f.flag&hackpadfs.FlagAppend != 0 {
+		f.offset = f.size
+	}
+	n, err := f.writeAt("write", p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeAt("writeat", p, off)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		if err := f.load("seek"); err != nil {
+			return 0, err
+		}
+		offset += f.size
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	const op = "truncate"
+	if size < 0 {
+		return &hackpadfs.PathError{Op: op, Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.load(op); err != nil {
+		return err
+	}
+	if !f.regular || f.flag&(hackpadfs.FlagWriteOnly|hackpadfs.FlagReadWrite) == 0 {
+		// let the wrapped FS report why the truncate failed
+		return hackpadfs.TruncateFile(f.file, size)
+	}
+	if size != f.size {
+		f.resize(size)
+	}
+	return f.flush(op)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDirFile(f.file, n)
+	return f.fs.uncompressedEntries(f.name, entries), err
+}
+
+// Stat implements hackpadfs.File
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, err := f.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := f.load("stat"); err != nil {
+		return nil, err
+	}
+	if !f.regular {
+		return info, nil
+	}
+	return &fileInfo{FileInfo: info, size: f.size}, nil
+}
+
+// Sync implements hackpadfs.SyncerFile. Stores any writes left over from a failed write before syncing the wrapped file.
+func (f *file) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.flush("sync"); err != nil {
+		return err
+	}
+	return hackpadfs.SyncFile(f.file)
+}
+
+// Close implements hackpadfs.File. Stores any writes left over from a failed write before closing the wrapped file.
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	flushErr := f.flush("close")
+	err := f.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return err
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(f.file, mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	return hackpadfs.ChownFile(f.file, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return hackpadfs.ChtimesFile(f.file, atime, mtime)
+}
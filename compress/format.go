@@ -0,0 +1,116 @@
+package compress
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ErrCorrupt is returned when a compressed file's data or layout is invalid
+var ErrCorrupt = fmt.Errorf("%w: compressed data is corrupt", hackpadfs.ErrInvalid)
+
+// A compressed file is its compressed blocks, followed by an index of each block's compressed length, followed by a footer.
+// Empty files may also be completely empty, without an index or footer.
+const (
+	// footerMagic ends every compressed file, identifying the format version
+	footerMagic = "hpfszst1"
+	// footerSize is the size of the footer: uncompressed size (uint64), block size (uint32), block count (uint32), then footerMagic
+	footerSize = 8 + 4 + 4 + len(footerMagic)
+	// indexEntrySize is the size of each block's compressed length in the index
+	indexEntrySize = 4
+)
+
+// layout describes the blocks stored in a compressed file
+type layout struct {
+	size      int64   // uncompressed size
+	blockSize int64   // uncompressed size of every block, except the last
+	offsets   []int64 // offset of each block, plus the end of the last block
+}
+
+func (l layout) blockCount() int64 {
+	return int64(len(l.offsets) - 1)
+}
+
+// blockLen returns the uncompressed length of block 'index' for a file of 'size' bytes
+func blockLen(index, size, blockSize int64) int64 {
+	length := size - index*blockSize
+	if length > blockSize {
+		return blockSize
+	}
+	if length < 0 {
+		return 0
+	}
+	return length
+}
+
+func blockCount(size, blockSize int64) int64 {
+	return (size + blockSize - 1) / blockSize
+}
+
+// readLayout reads the index and footer of a compressed file of 'fileSize' bytes
+func readLayout(f hackpadfs.File, fileSize, defaultBlockSize int64) (layout, error) {
+	if fileSize == 0 {
+		return layout{blockSize: defaultBlockSize, offsets: []int64{0}}, nil
+	}
+	if fileSize < int64(footerSize) {
+		return layout{}, ErrCorrupt
+	}
+	footer := make([]byte, footerSize)
+	if err := readFullAt(f, footer, fileSize-int64(footerSize)); err != nil {
+		return layout{}, err
+	}
+	if string(footer[16:]) != footerMagic {
+		return layout{}, ErrCorrupt
+	}
+	size := int64(binary.BigEndian.Uint64(footer))
+	blockSize := int64(binary.BigEndian.Uint32(footer[8:]))
+	count := int64(binary.BigEndian.Uint32(footer[12:]))
+	indexOffset := fileSize - int64(footerSize) - count*indexEntrySize
+	if size < 0 || blockSize == 0 || indexOffset < 0 || count != blockCount(size, blockSize) {
+		return layout{}, ErrCorrupt
+	}
+
+	index := make([]byte, count*indexEntrySize)
+	if err := readFullAt(f, index, indexOffset); err != nil {
+		return layout{}, err
+	}
+	offsets := make([]int64, count+1)
+	for i := int64(0); i < count; i++ {
+		offsets[i+1] = offsets[i] + int64(binary.BigEndian.Uint32(index[i*indexEntrySize:]))
+	}
+	if offsets[count] != indexOffset {
+		return layout{}, ErrCorrupt
+	}
+	return layout{size: size, blockSize: blockSize, offsets: offsets}, nil
+}
+
+// appendIndex appends the index and footer for 'l' to 'b'
+func appendIndex(b []byte, l layout) []byte {
+	var buf [8]byte
+	for i := int64(0); i < l.blockCount(); i++ {
+		binary.BigEndian.PutUint32(buf[:4], uint32(l.offsets[i+1]-l.offsets[i]))
+		b = append(b, buf[:4]...)
+	}
+	binary.BigEndian.PutUint64(buf[:], uint64(l.size))
+	b = append(b, buf[:]...)
+	binary.BigEndian.PutUint32(buf[:4], uint32(l.blockSize))
+	b = append(b, buf[:4]...)
+	binary.BigEndian.PutUint32(buf[:4], uint32(l.blockCount()))
+	b = append(b, buf[:4]...)
+	return append(b, footerMagic...)
+}
+
+// readFullAt reads len(p) bytes at 'off', failing with ErrCorrupt if the file is too short
+func readFullAt(f hackpadfs.File, p []byte, off int64) error {
+	n, err := hackpadfs.ReadAtFile(f, p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return ErrCorrupt
+	}
+	return err
+}
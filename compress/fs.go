@@ -0,0 +1,272 @@
+// Package compress contains a file system wrapper which compresses file contents with zstd.
+package compress
+
+import (
+	"errors"
+	"io"
+	"path"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+)
+
+// defaultBlockSize is the default Options.BlockSize
+const defaultBlockSize = 128 * 1024
+
+// maxBlockSize is the largest Options.BlockSize, limiting memory used to decompress a block
+const maxBlockSize = 64 * 1024 * 1024
+
+// Options configures an FS
+type Options struct {
+	// BlockSize is the number of uncompressed bytes compressed together. Larger blocks compress better, but each read or write must decompress a whole block. Defaults to 128 KiB.
+	// Existing files keep the block size they were written with.
+	BlockSize int
+	// Level is the zstd compression level. Defaults to zstd.SpeedDefault.
+	Level zstd.EncoderLevel
+	// CompressedSize reports compressed sizes in FileInfo from Stat, Lstat, and ReadDir, instead of uncompressed sizes.
+	// Avoids reading every file's index to find its size, but callers relying on Size() to read a whole file must read until io.EOF instead.
+	// Open files always report uncompressed sizes.
+	CompressedSize bool
+}
+
+// FS wraps a file system to compress file contents in independent blocks, so large files can be read and written at random offsets.
+// File names, directories, and symlinks are unchanged.
+//
+// Each write recompresses only the blocks it changes, but rewrites all later blocks, so writes near the end of a file are cheapest.
+// Open files cache their file's block index, so a file should not be written through more than one open file at a time.
+// Files opened for writing are opened for reading too, since partial writes must decompress whole blocks.
+type FS struct {
+	fs             hackpadfs.FS
+	blockSize      int64
+	compressedSize bool
+	encoder        *zstd.Encoder
+	decoder        *zstd.Decoder
+}
+
+// NewFS returns an FS which compresses everything written to 'fs' with 'options'
+func NewFS(fs hackpadfs.FS, options Options) (*FS, error) {
+	if options.BlockSize < 0 || options.BlockSize > maxBlockSize {
+		return nil, errors.New("BlockSize must be between 0 and 64 MiB")
+	}
+	if options.BlockSize == 0 {
+		options.BlockSize = defaultBlockSize
+	}
+	if options.Level == 0 {
+		options.Level = zstd.SpeedDefault
+	}
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(options.Level), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxBlockSize))
+	if err != nil {
+		return nil, err
+	}
+	return &FS{
+		fs:             fs,
+		blockSize:      int64(options.BlockSize),
+		compressedSize: options.CompressedSize,
+		encoder:        encoder,
+		decoder:        decoder,
+	}, nil
+}
+
+// uncompressedInfo returns 'info' with its uncompressed size, unless Options.CompressedSize is set
+func (fs *FS) uncompressedInfo(info hackpadfs.FileInfo, name string) (hackpadfs.FileInfo, error) {
+	if fs.compressedSize || !info.Mode().IsRegular() {
+		return info, nil
+	}
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	l, err := readLayout(f, info.Size(), fs.blockSize)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return &fileInfo{FileInfo: info, size: l.size}, nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	innerFlag := flag &^ hackpadfs.FlagAppend // appends are positioned by file
+	if flag&hackpadfs.FlagWriteOnly != 0 {
+		innerFlag = innerFlag&^hackpadfs.FlagWriteOnly | hackpadfs.FlagReadWrite
+	}
+	f, err := hackpadfs.OpenFile(fs.fs, name, innerFlag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(fs, f, name, flag), nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return hackpadfs.Mkdir(fs.fs, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return hackpadfs.MkdirAll(fs.fs, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return hackpadfs.Remove(fs.fs, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	return hackpadfs.RemoveAll(fs.fs, path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	return hackpadfs.Rename(fs.fs, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.Stat(fs.fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.uncompressedInfo(info, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.Lstat(fs.fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.uncompressedInfo(info, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return hackpadfs.Chmod(fs.fs, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return hackpadfs.Chown(fs.fs, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDir(fs.fs, name)
+	return fs.uncompressedEntries(name, entries), err
+}
+
+func (fs *FS) uncompressedEntries(dir string, entries []hackpadfs.DirEntry) []hackpadfs.DirEntry {
+	if fs.compressedSize {
+		return entries
+	}
+	for i := range entries {
+		entries[i] = &dirEntry{DirEntry: entries[i], dir: dir, fs: fs}
+	}
+	return entries
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return io.ReadAll(f)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return hackpadfs.Symlink(fs.fs, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	return hackpadfs.Link(fs.fs, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.Statfs(fs.fs, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	return hackpadfs.Watch(fs.fs, name)
+}
+
+// fileInfo reports an uncompressed size
+type fileInfo struct {
+	hackpadfs.FileInfo
+	size int64
+}
+
+func (i *fileInfo) Size() int64 {
+	return i.size
+}
+
+// dirEntry reports an uncompressed size
+type dirEntry struct {
+	hackpadfs.DirEntry
+	dir string
+	fs  *FS
+}
+
+func (e *dirEntry) Info() (hackpadfs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.fs.uncompressedInfo(info, path.Join(e.dir, e.Name()))
+}
@@ -0,0 +1,191 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		options Options
+	}{
+		{"compress", Options{}},
+		{"compress small blocks", Options{BlockSize: 4}},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				memFS, err := mem.NewFS()
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				fs, err := NewFS(memFS, tc.options)
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				return fs
+			},
+			Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func newFS(t *testing.T, options Options) (*mem.FS, *FS) {
+	t.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := NewFS(memFS, options)
+	assert.NoError(t, err)
+	return memFS, fs
+}
+
+func TestNewFSInvalidOptions(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = NewFS(memFS, Options{BlockSize: -1})
+	assert.Error(t, err)
+	_, err = NewFS(memFS, Options{BlockSize: maxBlockSize + 1})
+	assert.Error(t, err)
+}
+
+func TestCompresses(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{BlockSize: 1024})
+	contents := bytes.Repeat([]byte("a highly compressible log line\n"), 1000)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "log", contents, 0600))
+
+	rawInfo, err := hackpadfs.Stat(memFS, "log")
+	assert.NoError(t, err)
+	if rawInfo.Size() >= int64(len(contents))/4 {
+		t.Errorf("Expected compressed size to be much smaller than %d bytes, got %d", len(contents), rawInfo.Size())
+	}
+	info, err := hackpadfs.Stat(fs, "log")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)), info.Size())
+	b, err := hackpadfs.ReadFile(fs, "log")
+	assert.NoError(t, err)
+	assert.Equal(t, true, bytes.Equal(contents, b))
+}
+
+func TestCompressedSize(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{CompressedSize: true})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", bytes.Repeat([]byte("a"), 1000), 0600))
+
+	rawInfo, err := hackpadfs.Stat(memFS, "foo")
+	assert.NoError(t, err)
+	info, err := hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, rawInfo.Size(), info.Size())
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		info, err := entries[0].Info()
+		assert.NoError(t, err)
+		assert.Equal(t, rawInfo.Size(), info.Size())
+	}
+
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	info, err = f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), info.Size())
+	assert.NoError(t, f.Close())
+}
+
+func TestRandomAccess(t *testing.T) {
+	t.Parallel()
+	_, fs := newFS(t, Options{BlockSize: 5})
+	expected := []byte("hello world, this spans several blocks")
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", expected, 0600))
+
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+	assert.NoError(t, err)
+	write := func(p string, off int64) {
+		t.Helper()
+		_, err := hackpadfs.WriteAtFile(f, []byte(p), off)
+		assert.NoError(t, err)
+		end := off + int64(len(p))
+		for int64(len(expected)) < end {
+			expected = append(expected, 0)
+		}
+		copy(expected[off:], p)
+	}
+	assertContents := func() {
+		t.Helper()
+		_, err := hackpadfs.SeekFile(f, 0, io.SeekStart)
+		assert.NoError(t, err)
+		b, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Equal(t, string(expected), string(b))
+	}
+	write("HELLO", 0)
+	write("xyz", 13)
+	assertContents()
+	assert.NoError(t, hackpadfs.SyncFile(f))
+	write("past the end", 50)
+	write("!", 4)
+	assertContents()
+
+	assert.NoError(t, hackpadfs.TruncateFile(f, 7))
+	expected = expected[:7]
+	assertContents()
+	assert.NoError(t, hackpadfs.TruncateFile(f, 12))
+	expected = append(expected, 0, 0, 0, 0, 0)
+	assertContents()
+	assert.NoError(t, f.Close())
+
+	b, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(b))
+}
+
+func TestAppendReusesBlocks(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{BlockSize: 4})
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagAppend, 0600)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("hello"))
+	assert.NoError(t, err)
+	before, err := hackpadfs.ReadFile(memFS, "foo")
+	assert.NoError(t, err)
+
+	_, err = hackpadfs.WriteFile(f, []byte(" world"))
+	assert.NoError(t, err)
+	after, err := hackpadfs.ReadFile(memFS, "foo")
+	assert.NoError(t, err)
+	// the first, full block is stored unchanged
+	raw, err := memFS.Open("foo")
+	assert.NoError(t, err)
+	l, err := readLayout(raw, int64(len(after)), 4)
+	assert.NoError(t, err)
+	assert.NoError(t, raw.Close())
+	assert.Equal(t, true, bytes.Equal(before[:l.offsets[1]], after[:l.offsets[1]]))
+	assert.NoError(t, f.Close())
+
+	b, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(b))
+}
+
+func TestCorruptDetected(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{})
+	assert.NoError(t, hackpadfs.WriteFullFile(memFS, "foo", []byte("not compressed by FS"), 0600))
+	_, err := hackpadfs.ReadFile(fs, "foo")
+	assert.ErrorIs(t, ErrCorrupt, err)
+	_, err = hackpadfs.Stat(fs, "foo")
+	assert.ErrorIs(t, ErrCorrupt, err)
+}
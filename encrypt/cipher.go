@@ -0,0 +1,158 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ErrDecrypt is returned when data fails authentication. The data is corrupt, was tampered with, or was encrypted with a different key.
+var ErrDecrypt = fmt.Errorf("%w: failed to decrypt, data is corrupt or the key is incorrect", hackpadfs.ErrInvalid)
+
+const (
+	// fileMagic starts every encrypted file, identifying the format version
+	fileMagic = "hpfsenc1"
+	// fileIDSize is the size of the random ID stored after fileMagic. Binds chunks to their file.
+	fileIDSize = 16
+	// headerSize is the size of the encrypted file header
+	headerSize = len(fileMagic) + fileIDSize
+	// chunkOverhead is the size added to each chunk by its nonce and authentication tag
+	chunkOverhead = 12 + 16
+)
+
+// ciphers contains the keys derived from Options.Key
+type ciphers struct {
+	content   cipher.AEAD
+	names     cipher.AEAD
+	nameNonce []byte // HMAC key deriving deterministic nonces for names
+}
+
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func newCiphers(key []byte) (*ciphers, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("invalid key size %d: must be 16, 24, or 32 bytes", len(key))
+	}
+	content, err := newGCM(deriveKey(key, "hackpadfs encrypt content"))
+	if err != nil {
+		return nil, err
+	}
+	names, err := newGCM(deriveKey(key, "hackpadfs encrypt names"))
+	if err != nil {
+		return nil, err
+	}
+	return &ciphers{
+		content:   content,
+		names:     names,
+		nameNonce: deriveKey(key, "hackpadfs encrypt name nonces"),
+	}, nil
+}
+
+// newFileID returns a new random file ID
+func newFileID() ([]byte, error) {
+	id := make([]byte, fileIDSize)
+	_, err := io.ReadFull(rand.Reader, id)
+	return id, err
+}
+
+// chunkAdditionalData binds a chunk to its file, position, and whether it ends the file, so chunks can't be swapped, reordered, or truncated
+func chunkAdditionalData(fileID []byte, index int64, final bool) []byte {
+	data := make([]byte, len(fileID)+8+1)
+	copy(data, fileID)
+	binary.BigEndian.PutUint64(data[len(fileID):], uint64(index))
+	if final {
+		data[len(data)-1] = 1
+	}
+	return data
+}
+
+// sealChunk encrypts a chunk with a random nonce, returning the nonce followed by the ciphertext
+func (c *ciphers) sealChunk(fileID []byte, index int64, final bool, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.content.NonceSize(), c.content.NonceSize()+len(plaintext)+c.content.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return c.content.Seal(nonce, nonce, plaintext, chunkAdditionalData(fileID, index, final)), nil
+}
+
+// openChunk decrypts a chunk sealed with sealChunk
+func (c *ciphers) openChunk(fileID []byte, index int64, final bool, chunk []byte) ([]byte, error) {
+	nonceSize := c.content.NonceSize()
+	if len(chunk) < nonceSize+c.content.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := c.content.Open(nil, chunk[:nonceSize], chunk[nonceSize:], chunkAdditionalData(fileID, index, final))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// encryptName deterministically encrypts a single path element, so the same name always maps to the same encrypted name.
+// The nonce is derived from the name, similar to SIV modes, so equal names are the only thing revealed.
+func (c *ciphers) encryptName(name string) string {
+	mac := hmac.New(sha256.New, c.nameNonce)
+	_, _ = mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:c.names.NonceSize()]
+	sealed := c.names.Seal(nonce, nonce, []byte(name), nil)
+	return base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// decryptName reverses encryptName
+func (c *ciphers) decryptName(encrypted string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encrypted)
+	nonceSize := c.names.NonceSize()
+	if err != nil || len(sealed) < nonceSize+c.names.Overhead() {
+		return "", ErrDecrypt
+	}
+	name, err := c.names.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+	mac := hmac.New(sha256.New, c.nameNonce)
+	_, _ = mac.Write(name)
+	if !bytes.Equal(mac.Sum(nil)[:nonceSize], sealed[:nonceSize]) {
+		return "", ErrDecrypt
+	}
+	return string(name), nil
+}
+
+// mapPath runs 'fn' on each element of 'p', except for "." and ".." elements
+func mapPath(p string, fn func(string) (string, error)) (string, error) {
+	elems := strings.Split(p, "/")
+	for i, elem := range elems {
+		if elem == "" || elem == "." || elem == ".." {
+			continue
+		}
+		var err error
+		elems[i], err = fn(elem)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(elems, "/"), nil
+}
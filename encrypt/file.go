@@ -0,0 +1,411 @@
+package encrypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+var errNegativeOffset = errors.New("negative offset")
+
+// file encrypts and decrypts an open file's contents in chunks.
+// An encrypted file is a header followed by its chunks. Every chunk holds FS.chunkSize bytes of plaintext, except the last.
+// Empty files may also have no header, which is written before the first chunk.
+type file struct {
+	file hackpadfs.File
+	name string
+	flag int
+	fs   *FS
+
+	mu     sync.Mutex
+	offset int64
+	fileID []byte // nil until read from or written to the header
+}
+
+func (f *file) decryptErr(op string) error {
+	return &hackpadfs.PathError{Op: op, Path: f.name, Err: ErrDecrypt}
+}
+
+// chunkOffset returns the offset of chunk 'index' in the encrypted file
+func (f *file) chunkOffset(index int64) int64 {
+	return int64(headerSize) + index*(f.fs.chunkSize+chunkOverhead)
+}
+
+// chunkCount returns the number of chunks holding 'size' bytes of plaintext
+func (f *file) chunkCount(size int64) int64 {
+	return (size + f.fs.chunkSize - 1) / f.fs.chunkSize
+}
+
+// stat returns the file's encrypted info and plaintext size
+func (f *file) stat(op string) (hackpadfs.FileInfo, int64, error) {
+	info, err := f.file.Stat()
+	if err != nil {
+		return nil, 0, f.fs.pathErr(err, f.name)
+	}
+	if !info.Mode().IsRegular() {
+		return info, info.Size(), nil
+	}
+	size, err := f.fs.plainSize(info.Size())
+	if err != nil {
+		return nil, 0, f.decryptErr(op)
+	}
+	return info, size, nil
+}
+
+// loadHeader reads the file ID from the header. If the file is empty and 'create' is set, writes a new header instead.
+func (f *file) loadHeader(op string, info hackpadfs.FileInfo, create bool) error {
+	if f.fileID != nil {
+		return nil
+	}
+	if info.Size() == 0 {
+		if !create {
+			return nil
+		}
+		fileID, err := newFileID()
+		if err != nil {
+			return &hackpadfs.PathError{Op: op, Path: f.name, Err: err}
+		}
+		header := append([]byte(fileMagic), fileID...)
+		if _, err := hackpadfs.WriteAtFile(f.file, header, 0); err != nil {
+			return f.fs.pathErr(err, f.name)
+		}
+		f.fileID = fileID
+		return nil
+	}
+	header := make([]byte, headerSize)
+	if _, err := hackpadfs.ReadAtFile(f.file, header, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return f.decryptErr(op)
+		}
+		return f.fs.pathErr(err, f.name)
+	}
+	if !bytes.Equal(header[:len(fileMagic)], []byte(fileMagic)) {
+		return f.decryptErr(op)
+	}
+	f.fileID = header[len(fileMagic):]
+	return nil
+}
+
+// readChunk decrypts chunk 'index' of a file with 'size' bytes of plaintext
+func (f *file) readChunk(op string, index, size int64) ([]byte, error) {
+	chunkLen := size - index*f.fs.chunkSize
+	if chunkLen > f.fs.chunkSize {
+		chunkLen = f.fs.chunkSize
+	}
+	sealed := make([]byte, chunkLen+chunkOverhead)
+	n, err := hackpadfs.ReadAtFile(f.file, sealed, f.chunkOffset(index))
+	if err != nil && !(errors.Is(err, io.EOF) && n == len(sealed)) {
+		if errors.Is(err, io.EOF) {
+			return nil, f.decryptErr(op)
+		}
+		return nil, f.fs.pathErr(err, f.name)
+	}
+	final := index == f.chunkCount(size)-1
+	plaintext, err := f.fs.ciphers.openChunk(f.fileID, index, final, sealed)
+	if err != nil {
+		return nil, f.decryptErr(op)
+	}
+	return plaintext, nil
+}
+
+// writeChunk encrypts and writes chunk 'index', which ends the file if 'final' is set
+func (f *file) writeChunk(op string, index int64, final bool, plaintext []byte) error {
+	sealed, err := f.fs.ciphers.sealChunk(f.fileID, index, final, plaintext)
+	if err != nil {
+		return &hackpadfs.PathError{Op: op, Path: f.name, Err: err}
+	}
+	_, err = hackpadfs.WriteAtFile(f.file, sealed, f.chunkOffset(index))
+	return f.fs.pathErr(err, f.name)
+}
+
+func (f *file) readAt(op string, p []byte, off int64) (int, error) {
+	info, size, err := f.stat(op)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		n, err := hackpadfs.ReadAtFile(f.file, p, off)
+		return n, f.fs.pathErr(err, f.name)
+	}
+	if off >= size {
+		return 0, io.EOF
+	}
+	if err := f.loadHeader(op, info, false); err != nil {
+		return 0, err
+	}
+	n := 0
+	for n < len(p) && off+int64(n) < size {
+		pos := off + int64(n)
+		index := pos / f.fs.chunkSize
+		chunk, err := f.readChunk(op, index, size)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], chunk[pos-index*f.fs.chunkSize:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// writeAt writes 'p' at 'off', filling any gap after the end of the file with zeros.
+// Only chunks overlapping the written range are re-encrypted, plus the previous last chunk if the file grows into a new chunk.
+func (f *file) writeAt(op string, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: op, Path: f.name, Err: errNegativeOffset}
+	}
+	info, size, err := f.stat(op)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		n, err := hackpadfs.WriteAtFile(f.file, p, off)
+		return n, f.fs.pathErr(err, f.name)
+	}
+	start := off
+	if size < start {
+		start = size
+	}
+	end := off + int64(len(p))
+	if end <= start {
+		return 0, nil
+	}
+	if err := f.loadHeader(op, info, true); err != nil {
+		return 0, err
+	}
+
+	newSize := size
+	if end > newSize {
+		newSize = end
+	}
+	oldChunks, newChunks := f.chunkCount(size), f.chunkCount(newSize)
+	first, last := start/f.fs.chunkSize, (end-1)/f.fs.chunkSize
+	if newChunks > oldChunks && oldChunks > 0 && first > oldChunks-1 {
+		first = oldChunks - 1 // no longer the final chunk
+	}
+	n := 0
+	for index := first; index <= last; index++ {
+		chunkStart := index * f.fs.chunkSize
+		chunkEnd := chunkStart + f.fs.chunkSize
+		if chunkEnd > newSize {
+			chunkEnd = newSize
+		}
+		chunk := make([]byte, chunkEnd-chunkStart)
+		if index < oldChunks {
+			oldChunk, err := f.readChunk(op, index, size)
+			if err != nil {
+				return n, err
+			}
+			copy(chunk, oldChunk)
+		}
+		lo, hi := off, end
+		if lo < chunkStart {
+			lo = chunkStart
+		}
+		if hi > chunkEnd {
+			hi = chunkEnd
+		}
+		if lo < hi {
+			copy(chunk[lo-chunkStart:], p[lo-off:hi-off])
+		}
+		if err := f.writeChunk(op, index, index == newChunks-1, chunk); err != nil {
+			return n, err
+		}
+		if hi > off {
+			n = int(hi - off)
+		}
+	}
+	return n, nil
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt("read", p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: f.name, Err: errNegativeOffset}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAt("readat", p, off)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.flag&hackpadfs.FlagAppend != 0 {
+		_, size, err := f.stat("write")
+		if err != nil {
+			return 0, err
+		}
+		f.offset = size
+	}
+	n, err := f.writeAt("write", p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeAt("writeat", p, off)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		_, size, err := f.stat("seek")
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	const op = "truncate"
+	if size < 0 {
+		return &hackpadfs.PathError{Op: op, Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info, oldSize, err := f.stat(op)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !info.Mode().IsRegular():
+		return f.fs.pathErr(hackpadfs.TruncateFile(f.file, size), f.name)
+	case size > oldSize:
+		_, err := f.writeAt(op, nil, size)
+		return err
+	case size == oldSize:
+		return nil
+	case size == 0:
+		// keep the header, so other open files can still read new chunks
+		newSize := int64(headerSize)
+		if info.Size() < newSize {
+			newSize = 0
+		}
+		return f.fs.pathErr(hackpadfs.TruncateFile(f.file, newSize), f.name)
+	}
+
+	if err := f.loadHeader(op, info, false); err != nil {
+		return err
+	}
+	index := (size - 1) / f.fs.chunkSize
+	chunk, err := f.readChunk(op, index, oldSize)
+	if err != nil {
+		return err
+	}
+	chunk = chunk[:size-index*f.fs.chunkSize]
+	if err := f.writeChunk(op, index, true, chunk); err != nil {
+		return err
+	}
+	err = hackpadfs.TruncateFile(f.file, f.chunkOffset(index)+int64(len(chunk))+chunkOverhead)
+	return f.fs.pathErr(err, f.name)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	for {
+		entries, err := hackpadfs.ReadDirFile(f.file, n)
+		if err != nil {
+			return f.fs.plainEntries(entries), f.fs.pathErr(err, f.name)
+		}
+		plainEntries := f.fs.plainEntries(entries)
+		if n > 0 && len(plainEntries) == 0 && len(entries) > 0 {
+			// every entry was skipped, so read the next page to avoid a false end of directory
+			continue
+		}
+		if n <= 0 {
+			sort.Slice(plainEntries, func(a, b int) bool {
+				return plainEntries[a].Name() < plainEntries[b].Name()
+			})
+		}
+		return plainEntries, nil
+	}
+}
+
+// Stat implements hackpadfs.File
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	info, size, err := f.stat("stat")
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: info, name: path.Base(f.name), size: size}, nil
+}
+
+// Close implements hackpadfs.File
+func (f *file) Close() error {
+	return f.fs.pathErr(f.file.Close(), f.name)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	return f.fs.pathErr(hackpadfs.SyncFile(f.file), f.name)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return f.fs.pathErr(hackpadfs.ChmodFile(f.file, mode), f.name)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	return f.fs.pathErr(hackpadfs.ChownFile(f.file, uid, gid), f.name)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return f.fs.pathErr(hackpadfs.ChtimesFile(f.file, atime, mtime), f.name)
+}
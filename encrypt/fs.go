@@ -0,0 +1,405 @@
+// Package encrypt contains a file system wrapper which encrypts file contents, and optionally file names, with AES-GCM.
+package encrypt
+
+import (
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+)
+
+// defaultChunkSize is the default Options.ChunkSize
+const defaultChunkSize = 64 * 1024
+
+// Options configures an FS
+type Options struct {
+	// Key is the AES key. Must be 16, 24, or 32 bytes, selecting AES-128, AES-192, or AES-256. Required.
+	Key []byte
+	// EncryptNames also encrypts file names and symlink targets. Equal names still encrypt to equal values, so paths can be found without a key-less index.
+	// Encrypted names are longer than the originals, which may exceed the wrapped FS's name length limit.
+	EncryptNames bool
+	// ChunkSize is the number of plaintext bytes encrypted together. Each chunk can be read or written independently, enabling random access. Defaults to 64 KiB.
+	ChunkSize int
+}
+
+// FS wraps a file system to encrypt file contents with authenticated encryption, so sensitive data can be stored on untrusted backends.
+// Directory structure, file sizes (to within a chunk), and modification times are not hidden.
+//
+// Each file is encrypted in independent chunks, each with a random nonce and bound to the file and its position.
+// Reads of data which fails authentication return ErrDecrypt.
+// When names are encrypted, directory entries which fail to decrypt are skipped.
+//
+// Files opened for writing are opened for reading too, since writes must re-encrypt whole chunks.
+// Concurrent writes to the same file from multiple open files must be coordinated by the caller.
+type FS struct {
+	fs        hackpadfs.FS
+	ciphers   *ciphers
+	chunkSize int64
+	names     bool
+}
+
+// NewFS returns an FS which encrypts everything written to 'fs' with 'options'
+func NewFS(fs hackpadfs.FS, options Options) (*FS, error) {
+	ciphers, err := newCiphers(options.Key)
+	if err != nil {
+		return nil, err
+	}
+	if options.ChunkSize < 0 {
+		return nil, errors.New("ChunkSize must not be negative")
+	}
+	if options.ChunkSize == 0 {
+		options.ChunkSize = defaultChunkSize
+	}
+	return &FS{
+		fs:        fs,
+		ciphers:   ciphers,
+		chunkSize: int64(options.ChunkSize),
+		names:     options.EncryptNames,
+	}, nil
+}
+
+// encryptPath returns the wrapped FS's path for 'name'
+func (fs *FS) encryptPath(name string) string {
+	if !fs.names {
+		return name
+	}
+	p, _ := mapPath(name, func(elem string) (string, error) {
+		return fs.ciphers.encryptName(elem), nil
+	})
+	return p
+}
+
+// decryptName returns the plaintext name of an element from the wrapped FS
+func (fs *FS) decryptName(name string) (string, error) {
+	if !fs.names {
+		return name, nil
+	}
+	return fs.ciphers.decryptName(name)
+}
+
+// decryptPath returns the plaintext path for 'encrypted', a path from the wrapped FS, or 'fallback' if it fails to decrypt
+func (fs *FS) decryptPath(encrypted, fallback string) string {
+	name, err := mapPath(encrypted, fs.decryptName)
+	if err != nil {
+		return fallback
+	}
+	return name
+}
+
+// pathErr decrypts paths in errors from the wrapped FS. Paths which fail to decrypt are replaced with 'name'.
+func (fs *FS) pathErr(err error, name string) error {
+	var pathErr *hackpadfs.PathError
+	if !fs.names || !errors.As(err, &pathErr) {
+		return err
+	}
+	return &hackpadfs.PathError{Op: pathErr.Op, Path: fs.decryptPath(pathErr.Path, name), Err: pathErr.Err}
+}
+
+// linkErr decrypts paths in errors from the wrapped FS. Paths which fail to decrypt are replaced with 'oldname' and 'newname'.
+func (fs *FS) linkErr(err error, oldname, newname string) error {
+	var linkErr *hackpadfs.LinkError
+	if fs.names && errors.As(err, &linkErr) {
+		return &hackpadfs.LinkError{Op: linkErr.Op, Old: fs.decryptPath(linkErr.Old, oldname), New: fs.decryptPath(linkErr.New, newname), Err: linkErr.Err}
+	}
+	return fs.pathErr(err, oldname)
+}
+
+// plainSize returns the plaintext size of an encrypted file with 'size' bytes
+func (fs *FS) plainSize(size int64) (int64, error) {
+	if size <= int64(headerSize) {
+		return 0, nil
+	}
+	body := size - int64(headerSize)
+	fullChunk := fs.chunkSize + chunkOverhead
+	chunks := (body + fullChunk - 1) / fullChunk
+	if body-(chunks-1)*fullChunk <= chunkOverhead {
+		return 0, ErrDecrypt
+	}
+	return body - chunks*chunkOverhead, nil
+}
+
+// plainInfo converts FileInfo from the wrapped FS into plaintext FileInfo named 'name'
+func (fs *FS) plainInfo(info hackpadfs.FileInfo, name string) (hackpadfs.FileInfo, error) {
+	size := info.Size()
+	if info.Mode().IsRegular() {
+		var err error
+		size, err = fs.plainSize(size)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &fileInfo{FileInfo: info, name: name, size: size}, nil
+}
+
+// plainEntries decrypts and sorts directory entries from the wrapped FS, skipping names which fail to decrypt
+func (fs *FS) plainEntries(entries []hackpadfs.DirEntry) []hackpadfs.DirEntry {
+	plainEntries := make([]hackpadfs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		name, err := fs.decryptName(entry.Name())
+		if err != nil {
+			continue
+		}
+		plainEntries = append(plainEntries, &dirEntry{DirEntry: entry, name: name, fs: fs})
+	}
+	if fs.names {
+		sort.Slice(plainEntries, func(a, b int) bool {
+			return plainEntries[a].Name() < plainEntries[b].Name()
+		})
+	}
+	return plainEntries
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	innerFlag := flag &^ hackpadfs.FlagAppend // appends are positioned by file
+	if flag&hackpadfs.FlagWriteOnly != 0 {
+		innerFlag = innerFlag&^hackpadfs.FlagWriteOnly | hackpadfs.FlagReadWrite
+	}
+	f, err := hackpadfs.OpenFile(fs.fs, fs.encryptPath(name), innerFlag, perm)
+	if err != nil {
+		return nil, fs.pathErr(err, name)
+	}
+	return &file{file: f, name: name, flag: flag, fs: fs}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.pathErr(hackpadfs.Mkdir(fs.fs, fs.encryptPath(name), perm), name)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.pathErr(hackpadfs.MkdirAll(fs.fs, fs.encryptPath(path), perm), path)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return fs.pathErr(hackpadfs.Remove(fs.fs, fs.encryptPath(name)), name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	return fs.pathErr(hackpadfs.RemoveAll(fs.fs, fs.encryptPath(path)), path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	err := hackpadfs.Rename(fs.fs, fs.encryptPath(oldname), fs.encryptPath(newname))
+	return fs.linkErr(err, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.Stat(fs.fs, fs.encryptPath(name))
+	if err != nil {
+		return nil, fs.pathErr(err, name)
+	}
+	info, err = fs.plainInfo(info, path.Base(name))
+	return info, fs.pathErr(err, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.Lstat(fs.fs, fs.encryptPath(name))
+	if err != nil {
+		return nil, fs.pathErr(err, name)
+	}
+	info, err = fs.plainInfo(info, path.Base(name))
+	return info, fs.pathErr(err, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.pathErr(hackpadfs.Chmod(fs.fs, fs.encryptPath(name), mode), name)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return fs.pathErr(hackpadfs.Chown(fs.fs, fs.encryptPath(name), uid, gid), name)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.pathErr(hackpadfs.Chtimes(fs.fs, fs.encryptPath(name), atime, mtime), name)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDir(fs.fs, fs.encryptPath(name))
+	if err != nil {
+		return nil, fs.pathErr(err, name)
+	}
+	return fs.plainEntries(entries), nil
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return io.ReadAll(f)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	err := hackpadfs.Symlink(fs.fs, fs.encryptPath(oldname), fs.encryptPath(newname))
+	return fs.linkErr(err, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	err := hackpadfs.Link(fs.fs, fs.encryptPath(oldname), fs.encryptPath(newname))
+	return fs.linkErr(err, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	target, err := hackpadfs.Readlink(fs.fs, fs.encryptPath(name))
+	if err != nil {
+		return "", fs.pathErr(err, name)
+	}
+	target, err = mapPath(target, fs.decryptName)
+	if err != nil {
+		return "", &hackpadfs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return target, nil
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	unlocker, err := hackpadfs.Lock(fs.fs, fs.encryptPath(name), mode)
+	return unlocker, fs.pathErr(err, name)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	usage, err := hackpadfs.Statfs(fs.fs, fs.encryptPath(name))
+	return usage, fs.pathErr(err, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	watcher, err := hackpadfs.Watch(fs.fs, fs.encryptPath(name))
+	if err != nil {
+		return nil, fs.pathErr(err, name)
+	}
+	if !fs.names {
+		return watcher, nil
+	}
+	return newWatcher(fs, watcher), nil
+}
+
+// fileInfo reports a plaintext name and size
+type fileInfo struct {
+	hackpadfs.FileInfo
+	name string
+	size int64
+}
+
+func (i *fileInfo) Name() string {
+	return i.name
+}
+
+func (i *fileInfo) Size() int64 {
+	return i.size
+}
+
+// dirEntry reports a plaintext name and size
+type dirEntry struct {
+	hackpadfs.DirEntry
+	name string
+	fs   *FS
+}
+
+func (e *dirEntry) Name() string {
+	return e.name
+}
+
+func (e *dirEntry) Info() (hackpadfs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.fs.plainInfo(info, e.name)
+}
+
+// watcher decrypts event names from the wrapped FS's watcher
+type watcher struct {
+	watcher   hackpadfs.Watcher
+	events    chan hackpadfs.WatchEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWatcher(fs *FS, w hackpadfs.Watcher) *watcher {
+	plainWatcher := &watcher{
+		watcher: w,
+		events:  make(chan hackpadfs.WatchEvent),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(plainWatcher.events)
+		for event := range w.Events() {
+			name, err := mapPath(event.Name, fs.decryptName)
+			if err != nil {
+				continue
+			}
+			event.Name = name
+			select {
+			case plainWatcher.events <- event:
+			case <-plainWatcher.done:
+				// drain until the wrapped watcher closes its channel
+			}
+		}
+	}()
+	return plainWatcher
+}
+
+// Events implements hackpadfs.Watcher
+func (w *watcher) Events() <-chan hackpadfs.WatchEvent {
+	return w.events
+}
+
+// Close implements hackpadfs.Watcher
+func (w *watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	return w.watcher.Close()
+}
@@ -0,0 +1,202 @@
+package encrypt
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+var testKey = bytes.Repeat([]byte{1}, 32)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		options Options
+	}{
+		{"encrypt", Options{Key: testKey}},
+		{"encrypt small chunks", Options{Key: testKey, ChunkSize: 4}},
+		{"encrypt names", Options{Key: testKey, ChunkSize: 4, EncryptNames: true}},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				memFS, err := mem.NewFS()
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				fs, err := NewFS(memFS, tc.options)
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				return fs
+			},
+			Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func newFS(t *testing.T, options Options) (*mem.FS, *FS) {
+	t.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	if options.Key == nil {
+		options.Key = testKey
+	}
+	fs, err := NewFS(memFS, options)
+	assert.NoError(t, err)
+	return memFS, fs
+}
+
+func TestNewFSInvalidOptions(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = NewFS(memFS, Options{Key: []byte("short")})
+	assert.Error(t, err)
+	_, err = NewFS(memFS, Options{Key: testKey, ChunkSize: -1})
+	assert.Error(t, err)
+}
+
+func TestEncryptsContents(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("secret contents"), 0600))
+
+	raw, err := hackpadfs.ReadFile(memFS, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, false, bytes.Contains(raw, []byte("secret")))
+	b, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret contents", string(b))
+
+	otherKeyFS, err := NewFS(memFS, Options{Key: bytes.Repeat([]byte{2}, 32)})
+	assert.NoError(t, err)
+	_, err = hackpadfs.ReadFile(otherKeyFS, "foo")
+	assert.ErrorIs(t, ErrDecrypt, err)
+}
+
+func TestEncryptsNames(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{EncryptNames: true})
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "secret/dir", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "secret/dir/file", []byte("hello"), 0600))
+	assert.NoError(t, hackpadfs.Symlink(fs, "secret/dir/file", "link"))
+	assert.NoError(t, hackpadfs.WriteFullFile(memFS, "not-encrypted", nil, 0600))
+
+	assert.NoError(t, hackpadfs.WalkDir(memFS, ".", func(name string, _ hackpadfs.DirEntry, err error) error {
+		assert.NoError(t, err)
+		assert.Equal(t, false, strings.Contains(name, "secret"))
+		return nil
+	}))
+	rawTarget, err := hackpadfs.Readlink(memFS, fs.encryptPath("link"))
+	assert.NoError(t, err)
+	assert.Equal(t, false, strings.Contains(rawTarget, "secret"))
+
+	target, err := hackpadfs.Readlink(fs, "link")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret/dir/file", target)
+	b, err := hackpadfs.ReadFile(fs, "link")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"link", "secret"}, names)
+
+	_, err = hackpadfs.Stat(fs, "secret/missing")
+	assert.Equal(t, &hackpadfs.PathError{Op: "stat", Path: "secret/missing", Err: hackpadfs.ErrNotExist}, err)
+}
+
+func TestRandomAccess(t *testing.T) {
+	t.Parallel()
+	_, fs := newFS(t, Options{ChunkSize: 5})
+	expected := []byte("hello world, this spans several chunks")
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", expected, 0600))
+
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, f.Close()) }()
+	write := func(p string, off int64) {
+		t.Helper()
+		_, err := hackpadfs.WriteAtFile(f, []byte(p), off)
+		assert.NoError(t, err)
+		end := off + int64(len(p))
+		for int64(len(expected)) < end {
+			expected = append(expected, 0)
+		}
+		copy(expected[off:], p)
+	}
+	write("HELLO", 0)
+	write("xyz", 13)
+	write("past the end", 50)
+	write("!", 4)
+
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(expected)), info.Size())
+	for _, off := range []int64{0, 3, 5, 12, 40, 55} {
+		buf := make([]byte, 9)
+		n, err := hackpadfs.ReadAtFile(f, buf, off)
+		if err != io.EOF {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, string(expected[off:off+int64(n)]), string(buf[:n]))
+	}
+
+	assert.NoError(t, hackpadfs.TruncateFile(f, 7))
+	b, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected[:7]), string(b))
+}
+
+func TestTamperDetected(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{ChunkSize: 4})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("hello world"), 0600))
+	raw, err := hackpadfs.ReadFile(memFS, "foo")
+	assert.NoError(t, err)
+
+	chunk := int64(4 + chunkOverhead)
+	for _, tc := range []struct {
+		name   string
+		modify func(raw []byte) []byte
+	}{
+		{"flip bit", func(raw []byte) []byte {
+			raw[len(raw)-1] ^= 1
+			return raw
+		}},
+		{"drop last chunk", func(raw []byte) []byte {
+			return raw[:int64(headerSize)+2*chunk]
+		}},
+		{"swap chunks", func(raw []byte) []byte {
+			first := append([]byte(nil), raw[headerSize:int64(headerSize)+chunk]...)
+			copy(raw[headerSize:], raw[int64(headerSize)+chunk:int64(headerSize)+2*chunk])
+			copy(raw[int64(headerSize)+chunk:], first)
+			return raw
+		}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			memFS, fs := newFS(t, Options{ChunkSize: 4})
+			modified := tc.modify(append([]byte(nil), raw...))
+			assert.NoError(t, hackpadfs.WriteFullFile(memFS, "foo", modified, 0600))
+			_, err := hackpadfs.ReadFile(fs, "foo")
+			assert.ErrorIs(t, ErrDecrypt, err)
+		})
+	}
+}
@@ -17,6 +17,10 @@ var (
 	ErrNotDir         = syscall.ENOTDIR
 	ErrNotEmpty       = syscall.ENOTEMPTY
 	ErrNotImplemented = syscall.ENOSYS
+	ErrWouldBlock     = syscall.EWOULDBLOCK
+	ErrTooLarge       = syscall.EFBIG
+	ErrNoSpace        = syscall.ENOSPC
+	ErrCrossDevice    = syscall.EXDEV
 
 	SkipDir = fs.SkipDir
 )
@@ -0,0 +1,9 @@
+//go:build !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package hackpadfs
+
+import "syscall"
+
+// ErrNoAttr is returned for extended attributes which are not set. Mirrors ENODATA, used by Linux in place of ENOATTR.
+var ErrNoAttr = syscall.ENODATA
@@ -0,0 +1,9 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package hackpadfs
+
+import "syscall"
+
+// ErrNoAttr is returned for extended attributes which are not set. Mirrors ENOATTR.
+var ErrNoAttr = syscall.ENOATTR
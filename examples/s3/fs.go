@@ -11,7 +11,7 @@ import (
 // FS is an S3-based file system, storing files and metadata in an object storage bucket.
 type FS struct {
 	kv    *keyvalue.FS
-	store *store
+	store *Store
 }
 
 // Options provides configuration options for a new FS.
@@ -21,15 +21,26 @@ type Options struct {
 	AccessKeyID     string
 	SecretAccessKey string
 	Insecure        bool
+
+	// PartSize is the size in bytes of each part in a multipart upload. Files at least this large are uploaded in parts.
+	// Defaults to 16 MiB. Must be at least 5 MiB, the minimum part size allowed by S3.
+	PartSize uint64
+	// UploadThreads is the number of parts uploaded concurrently in a multipart upload. Defaults to 4.
+	UploadThreads uint
+	// Journal records multi-object operations like Rename before running them, so operations interrupted by a crash are completed the next time the FS is opened.
+	// See keyvalue.FSOptions.
+	Journal bool
 }
 
 // NewFS returns a new FS.
 func NewFS(options Options) (*FS, error) {
-	store, err := newStore(options)
+	store, err := NewStore(options)
 	if err != nil {
 		return nil, err
 	}
-	kv, err := keyvalue.NewFS(store)
+	kv, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{
+		Journal: options.Journal,
+	})
 	return &FS{
 		kv:    kv,
 		store: store,
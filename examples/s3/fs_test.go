@@ -1,16 +1,21 @@
 package s3
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"sync/atomic"
 	"testing"
 	"time"
 	"unicode"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	minioServer "github.com/minio/minio/cmd"
@@ -85,21 +90,23 @@ func cleanTestName(tb testing.TB) string {
 var testNumber uint64
 
 func makeFS(tb testing.TB) *FS {
-	bucketName := fmt.Sprintf("%s-%d", cleanTestName(tb), atomic.AddUint64(&testNumber, 1))
+	return makeFSWithOptions(tb, Options{})
+}
 
-	ctx := context.Background()
-	err := minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
+func makeFSWithOptions(tb testing.TB, options Options) *FS {
+	fs, err := NewFS(makeBucket(tb, options))
 	if err != nil {
 		tb.Fatal(err)
 	}
+	return fs
+}
 
-	fs, err := NewFS(Options{
-		Endpoint:        testDBHost,
-		BucketName:      bucketName,
-		Insecure:        true,
-		AccessKeyID:     testDBAccessKeyID,
-		SecretAccessKey: testDBSecretKey,
-	})
+// makeBucket creates a new bucket, removed during test cleanup, and returns 'options' configured to use it
+func makeBucket(tb testing.TB, options Options) Options {
+	bucketName := fmt.Sprintf("%s-%d", cleanTestName(tb), atomic.AddUint64(&testNumber, 1))
+
+	ctx := context.Background()
+	err := minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
 	if err != nil {
 		tb.Fatal(err)
 	}
@@ -108,7 +115,13 @@ func makeFS(tb testing.TB) *FS {
 			ForceDelete: true,
 		}))
 	})
-	return fs
+
+	options.Endpoint = testDBHost
+	options.BucketName = bucketName
+	options.Insecure = true
+	options.AccessKeyID = testDBAccessKeyID
+	options.SecretAccessKey = testDBSecretKey
+	return options
 }
 
 func TestFS(t *testing.T) {
@@ -118,10 +131,107 @@ func TestFS(t *testing.T) {
 		TestFS: func(tb testing.TB) fstest.SetupFS {
 			return makeFS(tb)
 		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 		ShouldSkip: func(facets fstest.Facets) bool {
-			return facets.Name == "TestFS/s3_FS/fs.Rename/non-empty_directory" // FIXME: Somehow the new renamed parent dir does not exist post-move.
+			switch facets.Name {
+			case "TestFS/s3_FS/fs.Rename/open_file", // Open files read their contents lazily from the old object key.
+				"TestFS/s3_File/file.Truncate/visible_to_other_open_files": // Open files keep their own copy of the contents.
+				return true
+			default:
+				return false
+			}
 		},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
 }
+
+func TestNewStoreInvalidPartSize(t *testing.T) {
+	t.Parallel()
+	_, err := NewStore(Options{
+		Endpoint: testDBHost,
+		PartSize: minPartSize - 1,
+	})
+	assert.Error(t, err)
+}
+
+func TestMultipartUpload(t *testing.T) {
+	t.Parallel()
+	fs := makeFSWithOptions(t, Options{PartSize: minPartSize})
+
+	contents := bytes.Repeat([]byte("hackpadfs"), 2*minPartSize/len("hackpadfs")+1) // spans 3 parts
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", contents, 0600))
+
+	info, err := fs.Stat("foo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(len(contents)), info.Size())
+	}
+	buf, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, true, bytes.Equal(contents, buf))
+}
+
+var errCrash = errors.New("crash")
+
+// crashingStore fails every write once 'setsLeft' Sets succeed, like a process stopping in the middle of an operation
+type crashingStore struct {
+	store    *Store
+	setsLeft int64
+}
+
+func (s *crashingStore) Get(ctx context.Context, name string) (keyvalue.FileRecord, error) {
+	return s.store.Get(ctx, name)
+}
+
+func (s *crashingStore) Set(ctx context.Context, name string, record keyvalue.FileRecord) error {
+	if atomic.AddInt64(&s.setsLeft, -1) < 0 {
+		return errCrash
+	}
+	return s.store.Set(ctx, name, record)
+}
+
+func (s *crashingStore) JournalEntries(ctx context.Context) (map[string][]byte, error) {
+	return s.store.JournalEntries(ctx)
+}
+
+func (s *crashingStore) SetJournalEntry(ctx context.Context, id string, entry []byte) error {
+	if atomic.LoadInt64(&s.setsLeft) < 0 {
+		return errCrash
+	}
+	return s.store.SetJournalEntry(ctx, id, entry)
+}
+
+func TestJournalReplaysInterruptedRename(t *testing.T) {
+	t.Parallel()
+	options := makeBucket(t, Options{Journal: true})
+	store, err := NewStore(options)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	crashStore := &crashingStore{store: store, setsLeft: math.MaxInt64}
+	fs, err := keyvalue.NewFSWithOptions(crashStore, keyvalue.FSOptions{Journal: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+
+	atomic.StoreInt64(&crashStore.setsLeft, 1) // crash after writing "bar", before removing "foo"
+	err = fs.Rename("foo", "bar")
+	assert.ErrorIs(t, errCrash, err)
+	entries, err := store.JournalEntries(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+
+	reopenedFS, err := NewFS(options)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = reopenedFS.Stat("foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	contents, err := hackpadfs.ReadFile(reopenedFS, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(contents))
+	entries, err = store.JournalEntries(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+}
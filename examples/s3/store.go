@@ -9,6 +9,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
@@ -21,7 +22,10 @@ import (
 var (
 	_ interface {
 		keyvalue.Store
-	} = &store{}
+		keyvalue.AsyncStore
+		keyvalue.JournalStore
+		keyvalue.MultiGetStore
+	} = &Store{}
 )
 
 const (
@@ -31,18 +35,33 @@ const (
 	modTimeFormat      = time.RFC3339Nano
 
 	rootPath    = "files"
+	journalPath = "journal" // holds journal entries outside the file tree, see keyvalue.JournalStore
 	filePrefix  = "file-"
 	dirMetaName = "dir-meta"
 
 	octalSize = 8 // formats file mode, for convenient human-readable metadata
+
+	minPartSize     = 5 << 20  // S3 rejects multipart uploads with smaller parts, except for the last one
+	defaultPartSize = 16 << 20 // large enough to keep part counts low, small enough to upload in parallel
+
+	getManyConcurrency = 16 // limits parallel requests for a single GetMany
 )
 
-type store struct {
+// Store is a keyvalue.Store backed by S3-compatible object storage.
+// Each file record is stored as one object, with directories listed by object key prefix.
+type Store struct {
 	options Options
 	client  *minio.Client
 }
 
-func newStore(options Options) (*store, error) {
+// NewStore returns a new Store for the bucket described in 'options'. Wrap it with keyvalue.NewFS() to use it as a file system.
+func NewStore(options Options) (*Store, error) {
+	if options.PartSize == 0 {
+		options.PartSize = defaultPartSize
+	}
+	if options.PartSize < minPartSize {
+		return nil, fmt.Errorf("part size must be at least %d bytes: %d", minPartSize, options.PartSize)
+	}
 	client, err := minio.New(options.Endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(options.AccessKeyID, options.SecretAccessKey, ""),
 		Secure: !options.Insecure,
@@ -50,13 +69,13 @@ func newStore(options Options) (*store, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &store{
+	return &Store{
 		options: options,
 		client:  client,
 	}, nil
 }
 
-func (s *store) fileToObjectKey(p string, isDir bool) string {
+func (s *Store) fileToObjectKey(p string, isDir bool) string {
 	dir, file := path.Split(p)
 	if isDir {
 		return path.Join(rootPath, dir, file, dirMetaName)
@@ -64,7 +83,7 @@ func (s *store) fileToObjectKey(p string, isDir bool) string {
 	return path.Join(rootPath, dir, filePrefix+file)
 }
 
-func (s *store) objectKeyToFile(p string) string {
+func (s *Store) objectKeyToFile(p string) string {
 	p = strings.TrimPrefix(p, rootPath+"/")
 	if strings.HasSuffix(p, "/") {
 		p = path.Join(p, dirMetaName)
@@ -81,19 +100,20 @@ func (s *store) objectKeyToFile(p string) string {
 	}
 }
 
-func (s *store) wrapS3Err(err error) error {
+func (s *Store) wrapS3Err(err error) error {
 	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
 		return hackpadfs.ErrNotExist
 	}
 	return err
 }
 
-func (s *store) stat(ctx context.Context, key string) (minio.ObjectInfo, error) {
+func (s *Store) stat(ctx context.Context, key string) (minio.ObjectInfo, error) {
 	info, err := s.client.StatObject(ctx, s.options.BucketName, key, minio.StatObjectOptions{})
 	return info, s.wrapS3Err(err)
 }
 
-func (s *store) Get(ctx context.Context, name string) (keyvalue.FileRecord, error) {
+// Get implements keyvalue.Store
+func (s *Store) Get(ctx context.Context, name string) (keyvalue.FileRecord, error) {
 	key := s.fileToObjectKey(name, true)
 	info, err := s.stat(ctx, key)
 	if errors.Is(err, hackpadfs.ErrNotExist) {
@@ -135,7 +155,47 @@ func (s *store) Get(ctx context.Context, name string) (keyvalue.FileRecord, erro
 	return keyvalue.NewBaseFileRecord(info.Size, modTime, mode, nil, getData, getDirNames), nil
 }
 
-func (s *store) getDirNamesFunc(key string) func() ([]string, error) {
+// GetAsync implements keyvalue.AsyncStore. Lets FS transactions send several requests at once, instead of waiting for each round trip.
+func (s *Store) GetAsync(ctx context.Context, name string) *keyvalue.Future {
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		resolve(s.Get(ctx, name))
+	}()
+	return future
+}
+
+// SetAsync implements keyvalue.AsyncStore. Canceling 'ctx' aborts the upload, and the Future resolves once it stops.
+func (s *Store) SetAsync(ctx context.Context, name string, record keyvalue.FileRecord) *keyvalue.Future {
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		resolve(nil, s.Set(ctx, name, record))
+	}()
+	return future
+}
+
+// GetMany implements keyvalue.MultiGetStore. Objects are retrieved concurrently, since S3 has no batch stat request.
+// At most getManyConcurrency requests run at once, so large directories do not flood the server.
+func (s *Store) GetMany(ctx context.Context, names []string) ([]keyvalue.OpResult, error) {
+	results := make([]keyvalue.OpResult, len(names))
+	sem := make(chan struct{}, getManyConcurrency)
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			record, err := s.Get(ctx, names[i])
+			results[i] = keyvalue.OpResult{Op: keyvalue.OpID(i), Record: record, Err: err}
+		}(i)
+	}
+	wg.Wait()
+	return results, ctx.Err()
+}
+
+func (s *Store) getDirNamesFunc(key string) func() ([]string, error) {
 	prefix, _ := path.Split(path.Clean(key))
 	return func() ([]string, error) {
 		infoChan := s.client.ListObjects(context.Background(), s.options.BucketName, minio.ListObjectsOptions{
@@ -155,7 +215,7 @@ func (s *store) getDirNamesFunc(key string) func() ([]string, error) {
 	}
 }
 
-func (s *store) getDataFunc(key string) func() (blob.Blob, error) {
+func (s *Store) getDataFunc(key string) func() (blob.Blob, error) {
 	return func() (_ blob.Blob, returnedErr error) {
 		obj, err := s.client.GetObject(context.Background(), s.options.BucketName, key, minio.GetObjectOptions{})
 		if err != nil {
@@ -182,7 +242,8 @@ func (s *store) getDataFunc(key string) func() (blob.Blob, error) {
 	}
 }
 
-func (s *store) Set(ctx context.Context, name string, record keyvalue.FileRecord) (e error) {
+// Set implements keyvalue.Store
+func (s *Store) Set(ctx context.Context, name string, record keyvalue.FileRecord) (e error) {
 	if record == nil {
 		getRecord, err := s.Get(ctx, name)
 		if errors.Is(err, hackpadfs.ErrNotExist) {
@@ -191,10 +252,8 @@ func (s *store) Set(ctx context.Context, name string, record keyvalue.FileRecord
 		if err != nil {
 			return err
 		}
+		// directory contents are removed individually before the directory itself, so only remove this object
 		key := s.fileToObjectKey(name, getRecord.Mode().IsDir())
-		if getRecord.Mode().IsDir() {
-			key = path.Dir(key) // remove directory recursively
-		}
 		return s.client.RemoveObject(ctx, s.options.BucketName, key, minio.RemoveObjectOptions{})
 	}
 
@@ -210,18 +269,70 @@ func (s *store) Set(ctx context.Context, name string, record keyvalue.FileRecord
 			return hackpadfs.ErrIsDir
 		}
 	}
-	b, err := record.Data()
-	if err != nil {
-		return err
+	var data []byte
+	if !record.Mode().IsDir() {
+		b, err := record.Data()
+		if err != nil {
+			return err
+		}
+		data = b.Bytes()
 	}
-	data := b.Bytes()
-	length := b.Len()
 	opts := minio.PutObjectOptions{
 		UserMetadata: map[string]string{
 			modeMetadataKey:    strconv.FormatUint(uint64(record.Mode()), octalSize),
 			modTimeMetadataKey: record.ModTime().Format(modTimeFormat),
 		},
+		// objects of at least PartSize bytes are split into parts and sent with a multipart upload
+		PartSize:   s.options.PartSize,
+		NumThreads: s.options.UploadThreads,
 	}
-	_, err = s.client.PutObject(ctx, s.options.BucketName, key, bytes.NewReader(data), int64(length), opts)
+	_, err := s.client.PutObject(ctx, s.options.BucketName, key, bytes.NewReader(data), int64(len(data)), opts)
 	return err
 }
+
+// JournalEntries implements keyvalue.JournalStore
+func (s *Store) JournalEntries(ctx context.Context) (map[string][]byte, error) {
+	infoChan := s.client.ListObjects(ctx, s.options.BucketName, minio.ListObjectsOptions{
+		Prefix: journalPath + "/",
+	})
+	entries := make(map[string][]byte)
+	for info := range infoChan {
+		if info.Err != nil {
+			return nil, s.wrapS3Err(info.Err)
+		}
+		entry, err := s.getObject(ctx, info.Key)
+		if errors.Is(err, hackpadfs.ErrNotExist) {
+			continue // removed after listing, i.e. the operation completed
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries[path.Base(info.Key)] = entry
+	}
+	return entries, nil
+}
+
+// SetJournalEntry implements keyvalue.JournalStore
+func (s *Store) SetJournalEntry(ctx context.Context, id string, entry []byte) error {
+	key := path.Join(journalPath, id)
+	if entry == nil {
+		return s.client.RemoveObject(ctx, s.options.BucketName, key, minio.RemoveObjectOptions{})
+	}
+	_, err := s.client.PutObject(ctx, s.options.BucketName, key, bytes.NewReader(entry), int64(len(entry)), minio.PutObjectOptions{})
+	return err
+}
+
+func (s *Store) getObject(ctx context.Context, key string) (_ []byte, returnedErr error) {
+	obj, err := s.client.GetObject(ctx, s.options.BucketName, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.wrapS3Err(err)
+	}
+	defer func() {
+		err := obj.Close()
+		if returnedErr == nil {
+			returnedErr = err
+		}
+	}()
+	data, err := io.ReadAll(obj)
+	return data, s.wrapS3Err(err)
+}
@@ -0,0 +1,120 @@
+package fault
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// file injects faults into operations on an open file
+type file struct {
+	hackpadfs.File
+	fs   *FS
+	name string
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	if err := f.fs.injectPath("read", f.name); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	if err := f.fs.injectPath("write", f.name); err != nil {
+		return 0, err
+	}
+	return hackpadfs.WriteFile(f.File, p)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.fs.injectPath("readat", f.name); err != nil {
+		return 0, err
+	}
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.fs.injectPath("writeat", f.name); err != nil {
+		return 0, err
+	}
+	return hackpadfs.WriteAtFile(f.File, p, off)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	if err := f.fs.injectPath("readdir", f.name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if err := f.fs.injectPath("seek", f.name); err != nil {
+		return 0, err
+	}
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	if err := f.fs.injectPath("sync", f.name); err != nil {
+		return err
+	}
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	if err := f.fs.injectPath("truncate", f.name); err != nil {
+		return err
+	}
+	return hackpadfs.TruncateFile(f.File, size)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	if err := f.fs.injectPath("chmod", f.name); err != nil {
+		return err
+	}
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	if err := f.fs.injectPath("chown", f.name); err != nil {
+		return err
+	}
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	if err := f.fs.injectPath("chtimes", f.name); err != nil {
+		return err
+	}
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
+
+// Stat implements hackpadfs.File
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	if err := f.fs.injectPath("stat", f.name); err != nil {
+		return nil, err
+	}
+	return f.File.Stat()
+}
+
+// Close implements hackpadfs.File. The file is closed even if an error is injected.
+func (f *file) Close() error {
+	injectErr := f.fs.injectPath("close", f.name)
+	err := f.File.Close()
+	if injectErr != nil {
+		return injectErr
+	}
+	return err
+}
@@ -0,0 +1,370 @@
+// Package fault contains a file system wrapper which injects errors and latency, for testing error handling.
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+// Rule injects a fault into matching operations
+type Rule struct {
+	// Ops are the operations to match, like "open", "openfile", "write", or "rename". Matches all operations if empty.
+	// File operations use the same names as FS operations, like "chmod" for both FS.Chmod() and File.Chmod().
+	Ops []string
+	// Paths are path.Match() patterns to match. Matches all paths if empty.
+	// File operations match the path their file was opened with. Rename and Link match either path.
+	Paths []string
+	// Err is returned by matching operations, wrapped in a *hackpadfs.PathError or *hackpadfs.LinkError.
+	// For example, hackpadfs.ErrNoSpace, syscall.EIO, or context.DeadlineExceeded. If nil, matching operations are only delayed by Latency.
+	Err error
+	// Latency delays matching operations before they run or fail
+	Latency time.Duration
+	// Nth only injects into the Nth matching operation, counting from 1. Injects into every matching operation if zero.
+	Nth int
+	// Probability is the chance of injecting into each matching operation, from 0 to 1. Defaults to 1 if zero.
+	Probability float64
+}
+
+func (r Rule) validate() error {
+	for _, pattern := range r.Paths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+		}
+	}
+	if r.Latency < 0 || r.Nth < 0 || r.Probability < 0 || r.Probability > 1 {
+		return errors.New("Latency and Nth must not be negative, and Probability must be between 0 and 1")
+	}
+	return nil
+}
+
+func (r Rule) matches(op string, names []string) bool {
+	return matchesOp(r.Ops, op) && matchesPath(r.Paths, names)
+}
+
+func matchesOp(ops []string, op string) bool {
+	if len(ops) == 0 {
+		return true
+	}
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesPath(patterns, names []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if match, _ := path.Match(pattern, name); match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Options configures an FS
+type Options struct {
+	// Rules are checked in order for every operation. Latency from every injecting rule is added up, and the first injecting rule's Err is returned.
+	Rules []Rule
+	// Seed seeds the random source for Rule.Probability, so failures repeat across test runs
+	Seed int64
+}
+
+// FS wraps a file system to inject errors and latency into matching operations, including operations on open files.
+// Operations which fail are not run on the wrapped FS, except Close, which still closes the file.
+//
+// Useful for testing how applications handle full disks, I/O errors, and slow storage.
+type FS struct {
+	fs hackpadfs.FS
+
+	mu     sync.Mutex
+	rules  []Rule
+	counts []int // matching operations per rule
+	rand   *rand.Rand
+}
+
+// NewFS returns an FS which injects faults into 'fs' with 'options'
+func NewFS(fs hackpadfs.FS, options Options) (*FS, error) {
+	faultFS := &FS{
+		fs:   fs,
+		rand: rand.New(rand.NewSource(options.Seed)),
+	}
+	if err := faultFS.SetRules(options.Rules...); err != nil {
+		return nil, err
+	}
+	return faultFS, nil
+}
+
+// SetRules replaces the FS's rules with 'rules', and resets counts for Rule.Nth.
+// Useful for injecting faults after setting up a test.
+func (fs *FS) SetRules(rules ...Rule) error {
+	for _, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.rules = append([]Rule(nil), rules...)
+	fs.counts = make([]int, len(rules))
+	return nil
+}
+
+// inject waits for any injected latency, then returns the injected error for operation 'op' on paths 'names', if any
+func (fs *FS) inject(op string, names ...string) error {
+	var latency time.Duration
+	var err error
+	fs.mu.Lock()
+	for i, rule := range fs.rules {
+		if !rule.matches(op, names) {
+			continue
+		}
+		fs.counts[i]++
+		if rule.Nth != 0 && fs.counts[i] != rule.Nth {
+			continue
+		}
+		if rule.Probability != 0 && fs.rand.Float64() >= rule.Probability {
+			continue
+		}
+		latency += rule.Latency
+		if err == nil {
+			err = rule.Err
+		}
+	}
+	fs.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	return err
+}
+
+func (fs *FS) injectPath(op, name string) error {
+	if err := fs.inject(op, name); err != nil {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+func (fs *FS) injectLink(op, oldname, newname string) error {
+	if err := fs.inject(op, oldname, newname); err != nil {
+		return &hackpadfs.LinkError{Op: op, Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	if err := fs.injectPath("open", name); err != nil {
+		return nil, err
+	}
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fs: fs, name: name}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if err := fs.injectPath("openfile", name); err != nil {
+		return nil, err
+	}
+	f, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fs: fs, name: name}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if err := fs.injectPath("mkdir", name); err != nil {
+		return err
+	}
+	return hackpadfs.Mkdir(fs.fs, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if err := fs.injectPath("mkdirall", path); err != nil {
+		return err
+	}
+	return hackpadfs.MkdirAll(fs.fs, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	if err := fs.injectPath("remove", name); err != nil {
+		return err
+	}
+	return hackpadfs.Remove(fs.fs, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	if err := fs.injectPath("removeall", path); err != nil {
+		return err
+	}
+	return hackpadfs.RemoveAll(fs.fs, path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	if err := fs.injectLink("rename", oldname, newname); err != nil {
+		return err
+	}
+	return hackpadfs.Rename(fs.fs, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if err := fs.injectPath("stat", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.Stat(fs.fs, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	if err := fs.injectPath("lstat", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.Lstat(fs.fs, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if err := fs.injectPath("chmod", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chmod(fs.fs, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	if err := fs.injectPath("chown", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chown(fs.fs, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := fs.injectPath("chtimes", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	if err := fs.injectPath("readdir", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.ReadDir(fs.fs, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	if err := fs.injectPath("readfile", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.ReadFile(fs.fs, name)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	if err := fs.injectLink("symlink", oldname, newname); err != nil {
+		return err
+	}
+	return hackpadfs.Symlink(fs.fs, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	if err := fs.injectLink("link", oldname, newname); err != nil {
+		return err
+	}
+	return hackpadfs.Link(fs.fs, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	if err := fs.injectPath("readlink", name); err != nil {
+		return "", err
+	}
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	if err := fs.injectPath("lock", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	if err := fs.injectPath("statfs", name); err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	return hackpadfs.Statfs(fs.fs, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	if err := fs.injectPath("watch", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.Watch(fs.fs, name)
+}
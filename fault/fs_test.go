@@ -0,0 +1,166 @@
+package fault
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(tb testing.TB, options Options) *FS {
+	tb.Helper()
+	memFS, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	fs, err := NewFS(memFS, options)
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "fault",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			// rules which never inject, so every operation is matched and still passes through
+			return newFS(tb, Options{Rules: []Rule{
+				{Paths: []string{"*", "*/*"}},
+				{Err: hackpadfs.ErrNoSpace, Nth: 1 << 30},
+			}})
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestNewFSInvalidRules(t *testing.T) {
+	t.Parallel()
+	for _, rule := range []Rule{
+		{Paths: []string{"["}},
+		{Latency: -1},
+		{Nth: -1},
+		{Probability: -0.5},
+		{Probability: 1.5},
+	} {
+		_, err := NewFS(nil, Options{Rules: []Rule{rule}})
+		assert.Error(t, err)
+	}
+}
+
+func TestInjectErr(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{})
+	assert.NoError(t, hackpadfs.Mkdir(fs, "dir", 0700))
+	assert.NoError(t, fs.SetRules(Rule{
+		Ops:   []string{"write", "openfile"},
+		Paths: []string{"dir/*.log"},
+		Err:   hackpadfs.ErrNoSpace,
+	}))
+
+	err := hackpadfs.WriteFullFile(fs, "dir/app.log", []byte("hello"), 0600)
+	assert.Equal(t, &hackpadfs.PathError{Op: "openfile", Path: "dir/app.log", Err: hackpadfs.ErrNoSpace}, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/app.txt", []byte("hello"), 0600))
+	_, err = hackpadfs.Stat(fs, "dir/app.log")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	assert.NoError(t, fs.SetRules(Rule{Ops: []string{"write"}, Err: syscall.EIO}))
+	f, err := hackpadfs.OpenFile(fs, "dir/app.txt", hackpadfs.FlagWriteOnly, 0)
+	if assert.NoError(t, err) {
+		n, err := hackpadfs.WriteFile(f, []byte("world"))
+		assert.Equal(t, 0, n)
+		assert.ErrorIs(t, syscall.EIO, err)
+		assert.NoError(t, f.Close())
+	}
+	b, err := hackpadfs.ReadFile(fs, "dir/app.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+}
+
+func TestInjectLinkErr(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{Rules: []Rule{
+		{Ops: []string{"rename"}, Paths: []string{"dest"}, Err: hackpadfs.ErrPermission},
+	}})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "src", nil, 0600))
+	err := hackpadfs.Rename(fs, "src", "dest")
+	assert.Equal(t, &hackpadfs.LinkError{Op: "rename", Old: "src", New: "dest", Err: hackpadfs.ErrPermission}, err)
+	assert.NoError(t, hackpadfs.Rename(fs, "src", "other"))
+}
+
+func TestInjectNth(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{Rules: []Rule{
+		{Ops: []string{"mkdir"}, Nth: 2, Err: hackpadfs.ErrNoSpace},
+	}})
+	assert.NoError(t, hackpadfs.Mkdir(fs, "a", 0700))
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, hackpadfs.Mkdir(fs, "b", 0700))
+	assert.NoError(t, hackpadfs.Mkdir(fs, "c", 0700))
+
+	assert.NoError(t, fs.SetRules(Rule{Ops: []string{"mkdir"}, Nth: 1, Err: hackpadfs.ErrNoSpace}))
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, hackpadfs.Mkdir(fs, "d", 0700))
+	assert.NoError(t, hackpadfs.Mkdir(fs, "d", 0700))
+}
+
+func TestInjectProbability(t *testing.T) {
+	t.Parallel()
+	failures := func(seed int64) []bool {
+		fs := newFS(t, Options{
+			Seed:  seed,
+			Rules: []Rule{{Ops: []string{"stat"}, Probability: 0.5, Err: syscall.EIO}},
+		})
+		var failed []bool
+		for i := 0; i < 100; i++ {
+			_, err := fs.Stat(".")
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+	results := failures(1)
+	assert.Equal(t, results, failures(1))
+	var count int
+	for _, failed := range results {
+		if failed {
+			count++
+		}
+	}
+	if count < 25 || count > 75 {
+		t.Errorf("Expected about half of operations to fail, got %d of %d", count, len(results))
+	}
+}
+
+func TestInjectLatency(t *testing.T) {
+	t.Parallel()
+	const latency = 20 * time.Millisecond
+	fs := newFS(t, Options{Rules: []Rule{
+		{Ops: []string{"readdir"}, Latency: latency},
+		{Ops: []string{"readdir"}, Latency: latency, Err: context.DeadlineExceeded},
+	}})
+	start := time.Now()
+	_, err := hackpadfs.ReadDir(fs, ".")
+	assert.ErrorIs(t, context.DeadlineExceeded, err)
+	if elapsed := time.Since(start); elapsed < 2*latency {
+		t.Errorf("Expected latency of at least %s, got %s", 2*latency, elapsed)
+	}
+}
+
+func TestInjectClose(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{})
+	f, err := hackpadfs.Create(fs, "foo")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, fs.SetRules(Rule{Ops: []string{"close"}, Err: syscall.EIO}))
+	assert.ErrorIs(t, syscall.EIO, f.Close())
+	assert.NoError(t, fs.SetRules())
+	assert.ErrorIs(t, hackpadfs.ErrClosed, f.Close())
+}
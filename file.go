@@ -134,7 +134,7 @@ func ChownFile(file File, uid, gid int) error {
 	if err != nil {
 		return err
 	}
-	return &PathError{Op: "chmod", Path: info.Name(), Err: ErrNotImplemented}
+	return &PathError{Op: "chown", Path: info.Name(), Err: ErrNotImplemented}
 }
 
 // ChtimesFile runs file.Chtimes() is available, fails with a not implemented error otherwise.
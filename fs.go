@@ -5,6 +5,7 @@ import (
 	"errors"
 	gofs "io/fs"
 	gopath "path"
+	"strconv"
 	"time"
 )
 
@@ -116,6 +117,120 @@ type SymlinkFS interface {
 	Symlink(oldname, newname string) error
 }
 
+// LinkFS is an FS that can create hard links. Should match the behavior of os.Link().
+type LinkFS interface {
+	FS
+	Link(oldname, newname string) error
+}
+
+// ReadlinkFS is an FS that can read the target of symlinks. Should match the behavior of os.Readlink().
+type ReadlinkFS interface {
+	FS
+	Readlink(name string) (string, error)
+}
+
+// LockMode is the kind of advisory lock acquired on a LockFS
+type LockMode int
+
+// Lock modes
+const (
+	// LockShared permits other shared locks, but no exclusive locks
+	LockShared LockMode = iota
+	// LockExclusive permits no other locks
+	LockExclusive
+)
+
+// Unlocker releases an advisory lock
+type Unlocker interface {
+	Unlock() error
+}
+
+// LockFS is an FS that can acquire advisory locks on files, similar to flock(2).
+// Locks do not prevent any other operations, so cooperating writers must acquire a lock first.
+// Lock does not wait for conflicting locks to be released. If 'name' is locked by another owner in a conflicting mode, it fails with ErrWouldBlock.
+type LockFS interface {
+	FS
+	Lock(name string, mode LockMode) (Unlocker, error)
+}
+
+// StatfsFS is an FS that can report its storage usage, similar to statfs(2).
+// 'name' is any path inside the FS, since a MountFS may hold several FS's with different usage.
+type StatfsFS interface {
+	FS
+	Statfs(name string) (FSUsage, error)
+}
+
+// FSUsage describes a file system's storage usage. All sizes are in bytes.
+type FSUsage struct {
+	// Total is the storage capacity, or quota, of the file system
+	Total int64
+	// Used is the storage already in use. May include usage from outside the file system, like other data sharing the same quota.
+	Used int64
+	// Available is the storage available for new data
+	Available int64
+}
+
+// WatchFS is an FS that can watch files for changes, similar to inotify(7).
+// Watch reports changes to 'name' and, if 'name' is a directory, to everything beneath it.
+// Changes may be coalesced or reported more than once, so watchers should re-read files to learn their current state.
+type WatchFS interface {
+	FS
+	Watch(name string) (Watcher, error)
+}
+
+// Watcher receives changes from a WatchFS
+type Watcher interface {
+	// Events returns the channel of changes. The channel is closed after Close is called.
+	Events() <-chan WatchEvent
+	// Close stops watching for changes
+	Close() error
+}
+
+// WatchEvent is a change to the file or directory at path Name
+type WatchEvent struct {
+	Name string
+	Op   WatchOp
+}
+
+// WatchOp is the kind of change in a WatchEvent
+type WatchOp int
+
+// Watch ops
+const (
+	// WatchWrite is a file or directory's creation, or a change to its contents or metadata
+	WatchWrite WatchOp = iota + 1
+	// WatchRemove is a file or directory's removal
+	WatchRemove
+)
+
+func (o WatchOp) String() string {
+	switch o {
+	case WatchWrite:
+		return "write"
+	case WatchRemove:
+		return "remove"
+	default:
+		return "WatchOp(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// XattrFS is an FS that can store extended attributes on files and directories, similar to getxattr(2) and setxattr(2).
+// Attributes are name-value pairs stored alongside a file's contents. They follow symlinks and move with their file on Rename.
+type XattrFS interface {
+	FS
+	// GetXattr returns the value of attribute 'attr' on 'name'. Fails with ErrNoAttr if it is not set.
+	GetXattr(name, attr string) ([]byte, error)
+	// SetXattr assigns 'value' to attribute 'attr' on 'name'. Fails with ErrTooLarge if 'value' is longer than XattrSizeMax.
+	SetXattr(name, attr string, value []byte) error
+	// ListXattr returns the names of all attributes set on 'name', sorted
+	ListXattr(name string) ([]string, error)
+	// RemoveXattr removes attribute 'attr' from 'name'. Fails with ErrNoAttr if it is not set.
+	RemoveXattr(name, attr string) error
+}
+
+// XattrSizeMax is the maximum length of an extended attribute's value. Matches Linux's XATTR_SIZE_MAX.
+const XattrSizeMax = 64 * 1024
+
 // MountFS is an FS that meshes one or more FS's together.
 // Returns the FS for a file located at 'name' and its 'subPath' inside that FS.
 type MountFS interface {
@@ -251,7 +366,7 @@ func RemoveAll(fs FS, path string) error {
 }
 
 func removeAll(fs FS, path string) error {
-	info, err := Stat(fs, path)
+	info, err := LstatOrStat(fs, path)
 	if err != nil {
 		if errors.Is(err, ErrNotExist) {
 			err = nil
@@ -365,10 +480,18 @@ func Chown(fs FS, name string, uid, gid int) error {
 	}
 	file, err := fs.Open(name)
 	if err != nil {
+		if pathErr, ok := err.(*PathError); ok {
+			err = pathErr.Err
+		}
 		return &PathError{Op: "chown", Path: name, Err: err}
 	}
 	defer func() { _ = file.Close() }()
-	return ChownFile(file, uid, gid)
+	err = ChownFile(file, uid, gid)
+	if errors.Is(err, ErrNotImplemented) {
+		// report the full path, file.Stat() only includes the base name
+		err = &PathError{Op: "chown", Path: name, Err: ErrNotImplemented}
+	}
+	return err
 }
 
 // Chtimes attempts to call an optimized fs.Chtimes(), falls back to opening the file and running file.Chtimes().
@@ -442,5 +565,129 @@ func Symlink(fs FS, oldname, newname string) error {
 	if fs, ok := fs.(SymlinkFS); ok {
 		return fs.Symlink(oldname, newname)
 	}
+	if fs, ok := fs.(MountFS); ok {
+		// only newname is a path, oldname is the link's contents
+		mountFS, subPath := fs.Mount(newname)
+		err := Symlink(mountFS, oldname, subPath)
+		if linkErr, ok := err.(*LinkError); ok {
+			err = &LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+		}
+		return err
+	}
 	return &LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrNotImplemented}
 }
+
+// Link creates 'newname' as a hard link to 'oldname'. Fails with a not implemented error if it's not a LinkFS.
+func Link(fs FS, oldname, newname string) error {
+	if fs, ok := fs.(LinkFS); ok {
+		return fs.Link(oldname, newname)
+	}
+	return &LinkError{Op: "link", Old: oldname, New: newname, Err: ErrNotImplemented}
+}
+
+// Readlink returns the target of the symlink 'name'. Fails with a not implemented error if it's not a ReadlinkFS.
+func Readlink(fs FS, name string) (string, error) {
+	if fs, ok := fs.(ReadlinkFS); ok {
+		return fs.Readlink(name)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		target, err := Readlink(mountFS, subPath)
+		return target, stripErrPathPrefix(err, name, subPath)
+	}
+	return "", &PathError{Op: "readlink", Path: name, Err: ErrNotImplemented}
+}
+
+// Lock acquires an advisory lock on 'name'. Fails with a not implemented error if it's not a LockFS.
+func Lock(fs FS, name string, mode LockMode) (Unlocker, error) {
+	if fs, ok := fs.(LockFS); ok {
+		return fs.Lock(name, mode)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		unlocker, err := Lock(mountFS, subPath, mode)
+		return unlocker, stripErrPathPrefix(err, name, subPath)
+	}
+	return nil, &PathError{Op: "lock", Path: name, Err: ErrNotImplemented}
+}
+
+// Statfs returns the storage usage of the file system containing 'name'. Fails with a not implemented error if it's not a StatfsFS.
+func Statfs(fs FS, name string) (FSUsage, error) {
+	if fs, ok := fs.(StatfsFS); ok {
+		return fs.Statfs(name)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		usage, err := Statfs(mountFS, subPath)
+		return usage, stripErrPathPrefix(err, name, subPath)
+	}
+	return FSUsage{}, &PathError{Op: "statfs", Path: name, Err: ErrNotImplemented}
+}
+
+// Watch watches 'name' for changes with fs.Watch(). Fails with a not implemented error if it's not a WatchFS.
+func Watch(fs FS, name string) (Watcher, error) {
+	if fs, ok := fs.(WatchFS); ok {
+		return fs.Watch(name)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		watcher, err := Watch(mountFS, subPath)
+		if err != nil {
+			return nil, stripErrPathPrefix(err, name, subPath)
+		}
+		return newMountWatcher(watcher, name, subPath), nil
+	}
+	return nil, &PathError{Op: "watch", Path: name, Err: ErrNotImplemented}
+}
+
+// GetXattr returns the value of extended attribute 'attr' on 'name'. Fails with a not implemented error if it's not an XattrFS.
+func GetXattr(fs FS, name, attr string) ([]byte, error) {
+	if fs, ok := fs.(XattrFS); ok {
+		return fs.GetXattr(name, attr)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		value, err := GetXattr(mountFS, subPath, attr)
+		return value, stripErrPathPrefix(err, name, subPath)
+	}
+	return nil, &PathError{Op: "getxattr", Path: name, Err: ErrNotImplemented}
+}
+
+// SetXattr assigns 'value' to extended attribute 'attr' on 'name'. Fails with a not implemented error if it's not an XattrFS.
+func SetXattr(fs FS, name, attr string, value []byte) error {
+	if fs, ok := fs.(XattrFS); ok {
+		return fs.SetXattr(name, attr, value)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		err := SetXattr(mountFS, subPath, attr, value)
+		return stripErrPathPrefix(err, name, subPath)
+	}
+	return &PathError{Op: "setxattr", Path: name, Err: ErrNotImplemented}
+}
+
+// ListXattr returns the sorted names of all extended attributes on 'name'. Fails with a not implemented error if it's not an XattrFS.
+func ListXattr(fs FS, name string) ([]string, error) {
+	if fs, ok := fs.(XattrFS); ok {
+		return fs.ListXattr(name)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		attrs, err := ListXattr(mountFS, subPath)
+		return attrs, stripErrPathPrefix(err, name, subPath)
+	}
+	return nil, &PathError{Op: "listxattr", Path: name, Err: ErrNotImplemented}
+}
+
+// RemoveXattr removes extended attribute 'attr' from 'name'. Fails with a not implemented error if it's not an XattrFS.
+func RemoveXattr(fs FS, name, attr string) error {
+	if fs, ok := fs.(XattrFS); ok {
+		return fs.RemoveXattr(name, attr)
+	}
+	if fs, ok := fs.(MountFS); ok {
+		mountFS, subPath := fs.Mount(name)
+		err := RemoveXattr(mountFS, subPath, attr)
+		return stripErrPathPrefix(err, name, subPath)
+	}
+	return &PathError{Op: "removexattr", Path: name, Err: ErrNotImplemented}
+}
@@ -23,6 +23,7 @@ func TestFS(t *testing.T) {
 			requireNoError(tb, err)
 			return mounttest.NewFS(fs)
 		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
@@ -133,3 +134,32 @@ func TestRemoveAll(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Zero(t, dir)
 }
+
+type statfsFS struct {
+	hackpadfs.FS
+	usage hackpadfs.FSUsage
+}
+
+func (fs *statfsFS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return fs.usage, nil
+}
+
+func TestStatfs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not implemented", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSimplerFS(t)
+		_, err := hackpadfs.Statfs(fs, "foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+	})
+
+	t.Run("statfs", func(t *testing.T) {
+		t.Parallel()
+		usage := hackpadfs.FSUsage{Total: 100, Used: 40, Available: 60}
+		fs := &statfsFS{FS: makeSimplerFS(t), usage: usage}
+		result, err := hackpadfs.Statfs(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, usage, result)
+	})
+}
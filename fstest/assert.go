@@ -99,6 +99,9 @@ func (o FSOptions) assertSubsetQuickInfos(tb testing.TB, a, b []quickInfo) bool
 
 func (o FSOptions) assertEqualPathErr(tb testing.TB, expected *hackpadfs.PathError, actual error) {
 	tb.Helper()
+	if o.tryAssertMatchErr(tb, expected, actual) {
+		return
+	}
 	if !assert.IsType(tb, (*hackpadfs.PathError)(nil), actual) {
 		return
 	}
@@ -110,6 +113,9 @@ func (o FSOptions) assertEqualPathErr(tb testing.TB, expected *hackpadfs.PathErr
 
 func (o FSOptions) assertEqualLinkErr(tb testing.TB, expected *hackpadfs.LinkError, actual error) {
 	tb.Helper()
+	if o.tryAssertMatchErr(tb, expected, actual) {
+		return
+	}
 	if !assert.IsType(tb, (*hackpadfs.LinkError)(nil), actual) {
 		return
 	}
@@ -120,6 +126,18 @@ func (o FSOptions) assertEqualLinkErr(tb testing.TB, expected *hackpadfs.LinkErr
 	o.assertEqualErrField(tb, expected.Err, actualLinkErr.Err)
 }
 
+// tryAssertMatchErr asserts 'actual' matches 'expected' with Constraints.MatchErr. Returns false if MatchErr is not set, so strict checks should run instead.
+func (o FSOptions) tryAssertMatchErr(tb testing.TB, expected, actual error) bool {
+	tb.Helper()
+	if o.Constraints.MatchErr == nil {
+		return false
+	}
+	if !o.Constraints.MatchErr(expected, actual) {
+		tb.Errorf("Error does not match expected error:\nExpected: %v\nActual:   %v", expected, actual)
+	}
+	return true
+}
+
 func (o FSOptions) assertEqualErrPath(tb testing.TB, expected, actual string) {
 	tb.Helper()
 	if o.Constraints.AllowErrPathPrefix && expected != actual {
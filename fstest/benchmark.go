@@ -0,0 +1,331 @@
+package fstest
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// BenchmarkOptions contains optional settings for running fstest.Benchmark against your FS.
+type BenchmarkOptions struct {
+	// FileCounts are the numbers of files in a directory for multi-file benchmarks, like ReadDir and RemoveAll. Defaults to 10, 100, and 1000.
+	FileCounts []int
+	// FileSizes are the file sizes, in bytes, for Read and Write benchmarks. Defaults to 4 KiB, 64 KiB, and 1 MiB.
+	FileSizes []int
+	// BlockSize is the number of bytes in each Read or Write call. Defaults to 4 KiB.
+	BlockSize int
+}
+
+func setupBenchmarkOptions(options *BenchmarkOptions) {
+	const kiB = 1 << 10
+	if len(options.FileCounts) == 0 {
+		options.FileCounts = []int{10, 100, 1000}
+	}
+	if len(options.FileSizes) == 0 {
+		options.FileSizes = []int{4 * kiB, 64 * kiB, 1024 * kiB}
+	}
+	if options.BlockSize <= 0 {
+		options.BlockSize = 4 * kiB
+	}
+}
+
+// Benchmark runs file system benchmarks with a standard set of operations and sizes, so FSs can be compared with one another.
+// Operations the FS does not implement are skipped.
+func Benchmark(b *testing.B, options FSOptions, benchOptions BenchmarkOptions) TestData {
+	b.Helper()
+
+	err := setupOptions(&options)
+	if err != nil {
+		b.Fatal(err)
+		return TestData{}
+	}
+	setupBenchmarkOptions(&benchOptions)
+	runName := options.Name + "_Benchmark"
+	options.bRun(b, runName, func(b *testing.B) {
+		b.Helper()
+		runBenchmark(b, options, benchOptions)
+	})
+	return options.generateTestData(b, runName)
+}
+
+func (o FSOptions) bRun(b *testing.B, name string, bench func(b *testing.B)) {
+	b.Helper()
+	o.tbRun(b, name, func(tb testing.TB) {
+		bench(tb.(*testing.B))
+	})
+}
+
+type namedBenchmark struct {
+	name  string
+	bench func(b *testing.B, o FSOptions)
+}
+
+// benchmarks returns the standard set of benchmarks for the sizes and counts in 'bo'
+func benchmarks(bo BenchmarkOptions) []namedBenchmark {
+	list := []namedBenchmark{
+		{"Create", benchmarkCreate},
+	}
+	for _, size := range bo.FileSizes {
+		size := size
+		list = append(list,
+			namedBenchmark{fmt.Sprintf("Read/sequential/size=%d", size), func(b *testing.B, o FSOptions) {
+				benchmarkReadSequential(b, o, size, bo.BlockSize)
+			}},
+			namedBenchmark{fmt.Sprintf("Read/random/size=%d", size), func(b *testing.B, o FSOptions) {
+				benchmarkReadRandom(b, o, size, bo.BlockSize)
+			}},
+			namedBenchmark{fmt.Sprintf("Write/sequential/size=%d", size), func(b *testing.B, o FSOptions) {
+				benchmarkWriteSequential(b, o, size, bo.BlockSize)
+			}},
+			namedBenchmark{fmt.Sprintf("Write/random/size=%d", size), func(b *testing.B, o FSOptions) {
+				benchmarkWriteRandom(b, o, size, bo.BlockSize)
+			}},
+		)
+	}
+	list = append(list, namedBenchmark{"Stat", benchmarkStat})
+	for _, count := range bo.FileCounts {
+		count := count
+		list = append(list, namedBenchmark{fmt.Sprintf("ReadDir/files=%d", count), func(b *testing.B, o FSOptions) {
+			benchmarkReadDir(b, o, count)
+		}})
+	}
+	list = append(list, namedBenchmark{"Rename", benchmarkRename})
+	for _, count := range bo.FileCounts {
+		count := count
+		list = append(list, namedBenchmark{fmt.Sprintf("RemoveAll/files=%d", count), func(b *testing.B, o FSOptions) {
+			benchmarkRemoveAll(b, o, count)
+		}})
+	}
+	return list
+}
+
+func runBenchmark(b *testing.B, o FSOptions, bo BenchmarkOptions) {
+	for _, bm := range benchmarks(bo) {
+		bm := bm
+		o.bRun(b, bm.name, func(b *testing.B) {
+			bm.bench(b, o)
+		})
+	}
+}
+
+// benchmarkData returns deterministic, incompressible file contents
+func benchmarkData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	_, _ = rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// benchmarkBlockOffsets returns the offsets of each block in a file of 'size' bytes, in a deterministic random order
+func benchmarkBlockOffsets(size, blockSize int, seed int64) []int64 {
+	var offsets []int64
+	for off := 0; off+blockSize <= size; off += blockSize {
+		offsets = append(offsets, int64(off))
+	}
+	rand.New(rand.NewSource(seed)).Shuffle(len(offsets), func(a, b int) {
+		offsets[a], offsets[b] = offsets[b], offsets[a]
+	})
+	return offsets
+}
+
+// setupBenchmarkFile commits a file named 'name' containing 'data' and returns the FS under test
+func setupBenchmarkFile(b *testing.B, o FSOptions, name string, data []byte) hackpadfs.FS {
+	b.Helper()
+	setupFS, commit := o.Setup.FS(b)
+	f, err := hackpadfs.OpenFile(setupFS, name, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0666)
+	if !assert.NoError(b, err) {
+		b.FailNow()
+	}
+	_, err = hackpadfs.WriteFile(f, data)
+	assert.NoError(b, err)
+	assert.NoError(b, f.Close())
+	return commit()
+}
+
+func benchmarkCreate(b *testing.B, o FSOptions) {
+	_, commit := o.Setup.FS(b)
+	fs := commit()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := hackpadfs.Create(fs, fmt.Sprintf("file-%d", i))
+		skipNotImplemented(b, err)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = f.Close()
+	}
+}
+
+func benchmarkReadSequential(b *testing.B, o FSOptions, size, blockSize int) {
+	fs := setupBenchmarkFile(b, o, "foo", benchmarkData(size, o.Seed))
+	buf := make([]byte, blockSize)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := fs.Open("foo")
+		if err != nil {
+			b.Fatal(err)
+		}
+		total := 0
+		for {
+			n, err := f.Read(buf)
+			total += n
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		_ = f.Close()
+		if total != size {
+			b.Fatalf("Read %d bytes, expected %d", total, size)
+		}
+	}
+}
+
+func benchmarkReadRandom(b *testing.B, o FSOptions, size, blockSize int) {
+	fs := setupBenchmarkFile(b, o, "foo", benchmarkData(size, o.Seed))
+	offsets := benchmarkBlockOffsets(size, blockSize, o.Seed)
+	buf := make([]byte, blockSize)
+	f, err := fs.Open("foo")
+	if !assert.NoError(b, err) {
+		b.FailNow()
+	}
+	defer func() { _ = f.Close() }()
+	b.SetBytes(int64(len(offsets) * blockSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, off := range offsets {
+			_, err := hackpadfs.ReadAtFile(f, buf, off)
+			skipNotImplemented(b, err)
+			if err != nil && err != io.EOF {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchmarkWriteSequential(b *testing.B, o FSOptions, size, blockSize int) {
+	_, commit := o.Setup.FS(b)
+	fs := commit()
+	data := benchmarkData(size, o.Seed)
+	b.SetBytes(int64(size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0666)
+		skipNotImplemented(b, err)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for off := 0; off < size; off += blockSize {
+			end := off + blockSize
+			if end > size {
+				end = size
+			}
+			_, err := hackpadfs.WriteFile(f, data[off:end])
+			skipNotImplemented(b, err)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		_ = f.Close()
+	}
+}
+
+func benchmarkWriteRandom(b *testing.B, o FSOptions, size, blockSize int) {
+	data := benchmarkData(size, o.Seed)
+	fs := setupBenchmarkFile(b, o, "foo", data)
+	offsets := benchmarkBlockOffsets(size, blockSize, o.Seed)
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly, 0)
+	skipNotImplemented(b, err)
+	if !assert.NoError(b, err) {
+		b.FailNow()
+	}
+	defer func() { _ = f.Close() }()
+	b.SetBytes(int64(len(offsets) * blockSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, off := range offsets {
+			_, err := hackpadfs.WriteAtFile(f, data[off:off+int64(blockSize)], off)
+			skipNotImplemented(b, err)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func benchmarkStat(b *testing.B, o FSOptions) {
+	fs := setupBenchmarkFile(b, o, "foo", nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := hackpadfs.Stat(fs, "foo")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkReadDir(b *testing.B, o FSOptions, count int) {
+	setupFS, commit := o.Setup.FS(b)
+	assert.NoError(b, setupFS.Mkdir("dir", 0700))
+	for i := 0; i < count; i++ {
+		f, err := hackpadfs.Create(setupFS, fmt.Sprintf("dir/file-%d", i))
+		if !assert.NoError(b, err) {
+			b.FailNow()
+		}
+		assert.NoError(b, f.Close())
+	}
+	fs := commit()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entries, err := hackpadfs.ReadDir(fs, "dir")
+		if err != nil || len(entries) != count {
+			b.Fatal("unexpected ReadDir result:", len(entries), err)
+		}
+	}
+}
+
+func benchmarkRename(b *testing.B, o FSOptions) {
+	fs := setupBenchmarkFile(b, o, "file-0", nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := hackpadfs.Rename(fs, fmt.Sprintf("file-%d", i), fmt.Sprintf("file-%d", i+1))
+		skipNotImplemented(b, err)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkRemoveAll(b *testing.B, o FSOptions, count int) {
+	_, commit := o.Setup.FS(b)
+	fs := commit()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		err := hackpadfs.Mkdir(fs, "dir", 0700)
+		skipNotImplemented(b, err)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < count; j++ {
+			f, err := hackpadfs.Create(fs, fmt.Sprintf("dir/file-%d", j))
+			if err != nil {
+				b.Fatal(err)
+			}
+			_ = f.Close()
+		}
+		b.StartTimer()
+
+		err = hackpadfs.RemoveAll(fs, "dir")
+		skipNotImplemented(b, err)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package fstest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// BenchmarkComparison is the result of one benchmark run against both the FS under test and a baseline FS
+type BenchmarkComparison struct {
+	// Name is the benchmark's name, like "Read/sequential/size=4096"
+	Name string `json:"name"`
+	// NsPerOp is the FS under test's time per operation, in nanoseconds
+	NsPerOp int64 `json:"nsPerOp"`
+	// BaselineNsPerOp is the baseline FS's time per operation, in nanoseconds
+	BaselineNsPerOp int64 `json:"baselineNsPerOp"`
+	// Ratio is NsPerOp divided by BaselineNsPerOp. Values above 1 are slower than the baseline.
+	// Zero if either FS skipped or failed the benchmark.
+	Ratio float64 `json:"ratio"`
+}
+
+// BenchmarkReport compares the FS under test's benchmark results with a baseline FS's
+type BenchmarkReport struct {
+	Name         string                `json:"name"`
+	BaselineName string                `json:"baselineName"`
+	Comparisons  []BenchmarkComparison `json:"comparisons"`
+}
+
+// String formats the report as a table, one benchmark per line
+func (r BenchmarkReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-40s %15s %15s %8s\n", "benchmark", r.Name+" ns/op", r.BaselineName+" ns/op", "ratio")
+	for _, c := range r.Comparisons {
+		ratio := "-"
+		if c.Ratio != 0 {
+			ratio = fmt.Sprintf("%.2fx", c.Ratio)
+		}
+		fmt.Fprintf(&sb, "%-40s %15d %15d %8s\n", c.Name, c.NsPerOp, c.BaselineNsPerOp, ratio)
+	}
+	return sb.String()
+}
+
+// CompareBenchmark runs Benchmark's operations against both the FS in 'options' and the FS in 'baseline', like a mem.FS or an os.FS in a temporary directory, then logs and returns a relative performance report.
+// Useful in CI for spotting performance regressions in an FS, independent of the machine running it.
+//
+// Each benchmark runs with testing.Benchmark, so CompareBenchmark must be called from a test and not a benchmark. Run time is controlled with the -test.benchtime flag.
+// Skipped in -short mode.
+func CompareBenchmark(tb testing.TB, options, baseline FSOptions, benchOptions BenchmarkOptions) BenchmarkReport {
+	tb.Helper()
+	if testing.Short() {
+		tb.Skip("Skipping benchmark comparison in short mode")
+	}
+
+	err := setupOptions(&options)
+	if err == nil {
+		err = setupOptions(&baseline)
+	}
+	if err != nil {
+		tb.Fatal(err)
+		return BenchmarkReport{}
+	}
+	setupBenchmarkOptions(&benchOptions)
+
+	report := BenchmarkReport{
+		Name:         options.Name,
+		BaselineName: baseline.Name,
+	}
+	for _, bm := range benchmarks(benchOptions) {
+		comparison := BenchmarkComparison{
+			Name:            bm.name,
+			NsPerOp:         runComparedBenchmark(options, bm),
+			BaselineNsPerOp: runComparedBenchmark(baseline, bm),
+		}
+		if comparison.NsPerOp > 0 && comparison.BaselineNsPerOp > 0 {
+			comparison.Ratio = float64(comparison.NsPerOp) / float64(comparison.BaselineNsPerOp)
+		}
+		report.Comparisons = append(report.Comparisons, comparison)
+	}
+	tb.Logf("Benchmark comparison:\n%s", report)
+	return report
+}
+
+// runComparedBenchmark runs 'bm' against the FS in 'o' and returns its time per operation, or 0 if it was skipped or failed
+func runComparedBenchmark(o FSOptions, bm namedBenchmark) int64 {
+	facets := Facets{Name: o.Name + "_Benchmark/" + bm.name}
+	if o.ShouldSkip(facets) {
+		return 0
+	}
+	result := testing.Benchmark(func(b *testing.B) {
+		bm.bench(b, o)
+	})
+	if result.N == 0 {
+		return 0
+	}
+	return result.NsPerOp()
+}
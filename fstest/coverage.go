@@ -0,0 +1,285 @@
+package fstest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// InterfaceCoverage reports how a test run covered a hackpadfs interface.
+type InterfaceCoverage struct {
+	// Name is the interface's name in package hackpadfs, like "ChmodFS" or "SeekerFile"
+	Name string `json:"name"`
+	// Detected is true if the FS or its files implement this interface directly.
+	// Undetected interfaces may still be supported through hackpadfs's helper functions, like hackpadfs.Chmod() using hackpadfs.ChmodFile().
+	Detected bool `json:"detected"`
+	// Exercised is true if at least one test for this interface ran without being skipped
+	Exercised bool `json:"exercised"`
+	// NotImplemented is true if at least one test for this interface was skipped because the FS returned hackpadfs.ErrNotImplemented
+	NotImplemented bool `json:"notImplemented"`
+	// Constrained is true if at least one test for this interface was skipped by FSOptions.ShouldSkip or ran with reduced assertions from FSOptions.Constraints
+	Constrained bool `json:"constrained"`
+}
+
+// TestStatus is the outcome of a test
+type TestStatus string
+
+// Test outcomes
+const (
+	TestPassed  TestStatus = "pass"
+	TestFailed  TestStatus = "fail"
+	TestSkipped TestStatus = "skip"
+)
+
+// TestResult is the outcome of a single test
+type TestResult struct {
+	// Name is the full name of the test
+	Name   string     `json:"name"`
+	Status TestStatus `json:"status"`
+}
+
+type interfaceDetector struct {
+	name   string
+	detect func(v interface{}) bool
+}
+
+var fsInterfaces = []interfaceDetector{
+	{"FS", func(v interface{}) bool { _, ok := v.(hackpadfs.FS); return ok }},
+	{"ChmodFS", func(v interface{}) bool { _, ok := v.(hackpadfs.ChmodFS); return ok }},
+	{"ChownFS", func(v interface{}) bool { _, ok := v.(hackpadfs.ChownFS); return ok }},
+	{"ChtimesFS", func(v interface{}) bool { _, ok := v.(hackpadfs.ChtimesFS); return ok }},
+	{"CreateFS", func(v interface{}) bool { _, ok := v.(hackpadfs.CreateFS); return ok }},
+	{"LockFS", func(v interface{}) bool { _, ok := v.(hackpadfs.LockFS); return ok }},
+	{"LinkFS", func(v interface{}) bool { _, ok := v.(hackpadfs.LinkFS); return ok }},
+	{"LstatFS", func(v interface{}) bool { _, ok := v.(hackpadfs.LstatFS); return ok }},
+	{"MkdirAllFS", func(v interface{}) bool { _, ok := v.(hackpadfs.MkdirAllFS); return ok }},
+	{"MkdirFS", func(v interface{}) bool { _, ok := v.(hackpadfs.MkdirFS); return ok }},
+	{"OpenFileFS", func(v interface{}) bool { _, ok := v.(hackpadfs.OpenFileFS); return ok }},
+	{"ReadDirFS", func(v interface{}) bool { _, ok := v.(hackpadfs.ReadDirFS); return ok }},
+	{"ReadFileFS", func(v interface{}) bool { _, ok := v.(hackpadfs.ReadFileFS); return ok }},
+	{"ReadlinkFS", func(v interface{}) bool { _, ok := v.(hackpadfs.ReadlinkFS); return ok }},
+	{"RemoveAllFS", func(v interface{}) bool { _, ok := v.(hackpadfs.RemoveAllFS); return ok }},
+	{"RemoveFS", func(v interface{}) bool { _, ok := v.(hackpadfs.RemoveFS); return ok }},
+	{"RenameFS", func(v interface{}) bool { _, ok := v.(hackpadfs.RenameFS); return ok }},
+	{"StatFS", func(v interface{}) bool { _, ok := v.(hackpadfs.StatFS); return ok }},
+	{"SubFS", func(v interface{}) bool { _, ok := v.(hackpadfs.SubFS); return ok }},
+	{"SymlinkFS", func(v interface{}) bool { _, ok := v.(hackpadfs.SymlinkFS); return ok }},
+	{"WatchFS", func(v interface{}) bool { _, ok := v.(hackpadfs.WatchFS); return ok }},
+	{"WriteFileFS", func(v interface{}) bool { _, ok := v.(hackpadfs.WriteFileFS); return ok }},
+	{"XattrFS", func(v interface{}) bool { _, ok := v.(hackpadfs.XattrFS); return ok }},
+}
+
+var fileInterfaces = []interfaceDetector{
+	{"File", func(v interface{}) bool { _, ok := v.(hackpadfs.File); return ok }},
+	{"DirReaderFile", func(v interface{}) bool { _, ok := v.(hackpadfs.DirReaderFile); return ok }},
+	{"ReadWriterFile", func(v interface{}) bool { _, ok := v.(hackpadfs.ReadWriterFile); return ok }},
+	{"ReaderAtFile", func(v interface{}) bool { _, ok := v.(hackpadfs.ReaderAtFile); return ok }},
+	{"SeekerFile", func(v interface{}) bool { _, ok := v.(hackpadfs.SeekerFile); return ok }},
+	{"SyncerFile", func(v interface{}) bool { _, ok := v.(hackpadfs.SyncerFile); return ok }},
+	{"TruncaterFile", func(v interface{}) bool { _, ok := v.(hackpadfs.TruncaterFile); return ok }},
+	{"WriterAtFile", func(v interface{}) bool { _, ok := v.(hackpadfs.WriterAtFile); return ok }},
+}
+
+// subtaskInterfaces maps subtask names from runFS() and runFile() to the interface they test
+var subtaskInterfaces = map[string]string{
+	"base fs.Create":  "CreateFS",
+	"base fs.Mkdir":   "MkdirFS",
+	"base fs.Chmod":   "ChmodFS",
+	"base fs.Chtimes": "ChtimesFS",
+
+	"fs.Chmod":           "ChmodFS",
+	"fs.Chown":           "ChownFS",
+	"fs.Chtimes":         "ChtimesFS",
+	"fs.Create":          "CreateFS",
+	"fs.Mkdir":           "MkdirFS",
+	"fs.MkdirAll":        "MkdirAllFS",
+	"fs.Open":            "FS",
+	"fs.OpenFile":        "OpenFileFS",
+	"fs.ReadDir":         "ReadDirFS",
+	"fs.ReadFile":        "ReadFileFS",
+	"fs.Remove":          "RemoveFS",
+	"fs.RemoveAll":       "RemoveAllFS",
+	"fs.Rename":          "RenameFS",
+	"fs.Stat":            "StatFS",
+	"fs.WriteFile":       "WriteFileFS",
+	"fs.Symlink":         "SymlinkFS",
+	"fs.Link":            "LinkFS",
+	"fs.Readlink":        "ReadlinkFS",
+	"fs.Lstat":           "LstatFS",
+	"fs.WalkDir":         "ReadDirFS",
+	"fs.Sub":             "SubFS",
+	"fs.CaseSensitivity": "FS",
+	"fs.Context":         "FS",
+	"fs.Lock":            "LockFS",
+	"fs.Quota":           "FS",
+	"fs.Permissions":     "ChmodFS",
+	"fs.Watch":           "WatchFS",
+	"fs.Xattr":           "XattrFS",
+
+	"fs_concurrent.Create":         "CreateFS",
+	"fs_concurrent.OpenFileCreate": "OpenFileFS",
+	"fs_concurrent.Mkdir":          "MkdirFS",
+	"fs_concurrent.MkdirAll":       "MkdirAllFS",
+	"fs_concurrent.Remove":         "RemoveFS",
+
+	"base file.Close": "File",
+
+	"file.Read":           "File",
+	"file.ReadAt":         "ReaderAtFile",
+	"file.Seek":           "SeekerFile",
+	"file.Write":          "ReadWriterFile",
+	"file.WriteAt":        "WriterAtFile",
+	"file.ReadDir":        "DirReaderFile",
+	"file.Stat":           "File",
+	"file.Sync":           "SyncerFile",
+	"file.SyncDurability": "SyncerFile",
+	"file.Truncate":       "TruncaterFile",
+
+	"file_concurrent.Read":     "File",
+	"file_concurrent.Write":    "ReadWriterFile",
+	"file_concurrent.Stat":     "File",
+	"file_concurrent.Truncate": "TruncaterFile",
+}
+
+// notImplementedTests contains the names of tests skipped with skipNotImplemented(), until their results are recorded
+var notImplementedTests sync.Map // type: test name string -> struct{}
+
+type testResult struct {
+	iface          string
+	failed         bool
+	skipped        bool
+	notImplemented bool
+	shouldSkip     bool
+}
+
+// coverage records interface detection and test results for a test run
+type coverage struct {
+	mu       sync.Mutex
+	detected map[string]bool
+	results  map[string]testResult // keyed by test name
+}
+
+func newCoverage() *coverage {
+	return &coverage{
+		detected: make(map[string]bool),
+		results:  make(map[string]testResult),
+	}
+}
+
+func (c *coverage) detect(v interface{}, detectors []interfaceDetector) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range detectors {
+		c.detected[d.name] = d.detect(v)
+	}
+}
+
+func (c *coverage) record(name string, result testResult) {
+	c.mu.Lock()
+	c.results[name] = result
+	c.mu.Unlock()
+}
+
+// detectFS records which FS interfaces the FS under test implements
+func (o FSOptions) detectFS(tb testing.TB) {
+	tb.Helper()
+	_, commit := o.Setup.FS(tb)
+	o.coverage.detect(commit(), fsInterfaces)
+}
+
+// detectFile records which File interfaces the FS under test's files implement
+func (o FSOptions) detectFile(tb testing.TB) {
+	tb.Helper()
+	setupFS, commit := o.Setup.FS(tb)
+	f, err := hackpadfs.Create(setupFS, "foo")
+	if !assert.NoError(tb, err) {
+		return
+	}
+	assert.NoError(tb, f.Close())
+	fs := commit()
+	f, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+	if err != nil {
+		f, err = fs.Open("foo")
+	}
+	if !assert.NoError(tb, err) {
+		return
+	}
+	o.coverage.detect(f, fileInterfaces)
+	assert.NoError(tb, f.Close())
+}
+
+func (c *coverage) interfaces(constrained bool) []InterfaceCoverage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	names := make([]string, 0, len(c.results))
+	for name := range c.results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ifaces := make(map[string]*InterfaceCoverage)
+	getIface := func(name string) *InterfaceCoverage {
+		iface, ok := ifaces[name]
+		if !ok {
+			iface = &InterfaceCoverage{Name: name, Detected: c.detected[name]}
+			ifaces[name] = iface
+		}
+		return iface
+	}
+	for name, detected := range c.detected {
+		if detected {
+			getIface(name)
+		}
+	}
+	for i, name := range names {
+		result := c.results[name]
+		if result.iface == "" {
+			continue
+		}
+		iface := getIface(result.iface)
+		isLeaf := i+1 == len(names) || !strings.HasPrefix(names[i+1], name+"/")
+		switch {
+		case result.notImplemented:
+			iface.NotImplemented = true
+		case result.shouldSkip:
+			iface.Constrained = true
+		case isLeaf && !result.skipped:
+			iface.Exercised = true
+			iface.Constrained = iface.Constrained || constrained
+		}
+	}
+
+	coverage := make([]InterfaceCoverage, 0, len(ifaces))
+	for _, iface := range ifaces {
+		coverage = append(coverage, *iface)
+	}
+	sort.Slice(coverage, func(a, b int) bool {
+		return coverage[a].Name < coverage[b].Name
+	})
+	return coverage
+}
+
+func (c *coverage) testResults() []TestResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results := make([]TestResult, 0, len(c.results))
+	for name, result := range c.results {
+		status := TestPassed
+		switch {
+		case result.failed:
+			status = TestFailed
+		case result.skipped:
+			status = TestSkipped
+		}
+		results = append(results, TestResult{Name: name, Status: status})
+	}
+	sort.Slice(results, func(a, b int) bool {
+		return results[a].Name < results[b].Name
+	})
+	return results
+}
@@ -0,0 +1,301 @@
+package fstest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// DiffOptions contains settings for comparing an FS against a reference FS with Diff() or FuzzDiff().
+type DiffOptions struct {
+	// Reference returns a new, empty FS to compare against. Required.
+	// Its results are considered correct, so use a well-tested FS like an os.FS rooted in a temporary directory or a mem.FS.
+	Reference func(tb testing.TB) hackpadfs.FS
+	// Sequences is the number of random operation sequences Diff() runs. Defaults to 20.
+	Sequences int
+	// Ops is the number of operations in each sequence. Defaults to 100.
+	Ops int
+	// Seed is the random seed of the first sequence. Each subsequent sequence increments the seed. Defaults to FSOptions.Seed.
+	Seed int64
+}
+
+func setupDiffOptions(options *DiffOptions, seed int64) error {
+	if options.Reference == nil {
+		return errors.New("Reference FS func is required")
+	}
+	if options.Sequences <= 0 {
+		options.Sequences = 20
+	}
+	if options.Ops <= 0 {
+		options.Ops = 100
+	}
+	if options.Seed == 0 {
+		options.Seed = seed
+	}
+	return nil
+}
+
+// Diff applies random sequences of operations to both the FS under test and a reference FS, then fails on the first divergence in their results or errors.
+// Catches subtle differences in behavior the fixed FS() and File() tests miss.
+func Diff(tb testing.TB, options FSOptions, diffOptions DiffOptions) TestData {
+	tb.Helper()
+
+	err := setupOptions(&options)
+	if err == nil {
+		err = setupDiffOptions(&diffOptions, options.Seed)
+	}
+	if err != nil {
+		tb.Fatal(err)
+		return TestData{}
+	}
+	runName := options.Name + "_Diff"
+	options.tbRun(tb, runName, func(tb testing.TB) {
+		tb.Helper()
+		for i := 0; i < diffOptions.Sequences; i++ {
+			seed := diffOptions.Seed + int64(i)
+			options.tbRun(tb, fmt.Sprintf("seed=%d", seed), func(tb testing.TB) {
+				options.tbParallelGroup(tb)
+				runDiff(tb, options, diffOptions, seed)
+			})
+		}
+	})
+	return options.generateTestData(tb, runName)
+}
+
+// FuzzDiff runs Diff's random operation sequences with Go's native fuzzing, where each input is a sequence's random seed.
+// Call it from a FuzzXxx function and run 'go test -fuzz' to search for divergences beyond the seeded sequences.
+func FuzzDiff(f *testing.F, options FSOptions, diffOptions DiffOptions) {
+	f.Helper()
+
+	err := setupOptions(&options)
+	if err == nil {
+		err = setupDiffOptions(&diffOptions, options.Seed)
+	}
+	if err != nil {
+		f.Fatal(err)
+		return
+	}
+	for i := 0; i < diffOptions.Sequences; i++ {
+		f.Add(diffOptions.Seed + int64(i))
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		runDiff(t, options, diffOptions, seed)
+	})
+}
+
+func runDiff(tb testing.TB, o FSOptions, do DiffOptions, seed int64) {
+	tb.Helper()
+	_, commit := o.Setup.FS(tb)
+	fs := commit()
+	reference := do.Reference(tb)
+
+	rnd := rand.New(rand.NewSource(seed))
+	var history []string
+	for i := 0; i < do.Ops; i++ {
+		op := randomDiffOp(rnd)
+		history = append(history, op.String())
+		expected := op.run(reference, o.Constraints)
+		actual := op.run(fs, o.Constraints)
+		if expected != actual && strings.Contains(actual, errNotImplementedResult) {
+			notImplementedTests.Store(tb.Name(), struct{}{})
+			tb.Skipf("%s: %s", op, actual)
+		}
+		if expected != actual {
+			tb.Fatalf("Divergence at operation %d of seed %d: %s\nExpected: %s\nActual:   %s\nOperations:\n\t%s",
+				i, seed, op, expected, actual, strings.Join(history, "\n\t"))
+		}
+	}
+}
+
+// diffPaths are the paths random operations act upon. The small set makes conflicts between operations likely.
+var diffPaths = []string{
+	".",
+	"a",
+	"b",
+	"a/b",
+	"dir",
+	"dir/a",
+	"dir/b",
+	"dir/sub",
+	"dir/sub/a",
+}
+
+var diffModes = []hackpadfs.FileMode{0700, 0750, 0755, 0777}
+
+const errNotImplementedResult = "not implemented"
+
+// diffErrs are the errors compared between FSs. Other errors only need to match in their presence.
+var diffErrs = []struct {
+	name string
+	err  error
+}{
+	{errNotImplementedResult, hackpadfs.ErrNotImplemented},
+	{"not exist", hackpadfs.ErrNotExist},
+	{"exist", hackpadfs.ErrExist},
+	{"is dir", hackpadfs.ErrIsDir},
+	{"not dir", hackpadfs.ErrNotDir},
+	{"not empty", hackpadfs.ErrNotEmpty},
+	{"invalid", hackpadfs.ErrInvalid},
+	{"permission", hackpadfs.ErrPermission},
+}
+
+type diffOp struct {
+	kind    string
+	name    string
+	newName string // Rename only
+	data    []byte
+	mode    hackpadfs.FileMode
+	size    int64
+}
+
+func randomDiffOp(rnd *rand.Rand) diffOp {
+	kinds := []string{"create", "mkdir", "mkdirall", "remove", "removeall", "rename", "write", "append", "truncate", "chmod", "read", "stat", "readdir"}
+	op := diffOp{
+		kind: kinds[rnd.Intn(len(kinds))],
+		name: diffPaths[rnd.Intn(len(diffPaths))],
+	}
+	switch op.kind {
+	case "rename":
+		op.newName = diffPaths[rnd.Intn(len(diffPaths))]
+	case "write", "append":
+		op.data = make([]byte, rnd.Intn(16))
+		for i := range op.data {
+			op.data[i] = byte('a' + rnd.Intn(26))
+		}
+	case "truncate":
+		op.size = int64(rnd.Intn(16))
+	case "chmod":
+		op.mode = diffModes[rnd.Intn(len(diffModes))]
+	}
+	return op
+}
+
+func (op diffOp) String() string {
+	switch op.kind {
+	case "rename":
+		return fmt.Sprintf("%s(%q, %q)", op.kind, op.name, op.newName)
+	case "write", "append":
+		return fmt.Sprintf("%s(%q, %q)", op.kind, op.name, op.data)
+	case "truncate":
+		return fmt.Sprintf("%s(%q, %d)", op.kind, op.name, op.size)
+	case "chmod":
+		return fmt.Sprintf("%s(%q, %O)", op.kind, op.name, op.mode)
+	default:
+		return fmt.Sprintf("%s(%q)", op.kind, op.name)
+	}
+}
+
+// run applies the operation to 'fs' and returns a comparable description of the result
+func (op diffOp) run(fs hackpadfs.FS, constraints Constraints) string {
+	switch op.kind {
+	case "create":
+		f, err := hackpadfs.Create(fs, op.name)
+		if err == nil {
+			err = f.Close()
+		}
+		return diffResult(err)
+	case "mkdir":
+		return diffResult(hackpadfs.Mkdir(fs, op.name, 0755))
+	case "mkdirall":
+		return diffResult(hackpadfs.MkdirAll(fs, op.name, 0755))
+	case "remove":
+		if op.name == "." {
+			return diffResult(nil) // removing the root is undefined
+		}
+		return diffResult(hackpadfs.Remove(fs, op.name))
+	case "removeall":
+		if op.name == "." {
+			return diffResult(nil)
+		}
+		return diffResult(hackpadfs.RemoveAll(fs, op.name))
+	case "rename":
+		if op.name == "." || op.newName == "." {
+			return diffResult(nil) // renaming the root is undefined
+		}
+		if strings.HasPrefix(op.newName, op.name+"/") {
+			return diffResult(nil) // moving a directory inside itself is OS-specific
+		}
+		return diffResult(hackpadfs.Rename(fs, op.name, op.newName))
+	case "write":
+		return diffResult(hackpadfs.WriteFullFile(fs, op.name, op.data, 0666))
+	case "append":
+		f, err := hackpadfs.OpenFile(fs, op.name, hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+		if err != nil {
+			return diffResult(err)
+		}
+		_, err = hackpadfs.WriteFile(f, op.data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return diffResult(err)
+	case "truncate":
+		f, err := hackpadfs.OpenFile(fs, op.name, hackpadfs.FlagWriteOnly, 0)
+		if err != nil {
+			return diffResult(err)
+		}
+		err = hackpadfs.TruncateFile(f, op.size)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return diffResult(err)
+	case "chmod":
+		if op.name == "." {
+			return diffResult(nil) // the root's permissions may be outside the FS's control
+		}
+		return diffResult(hackpadfs.Chmod(fs, op.name, op.mode))
+	case "read":
+		data, err := hackpadfs.ReadFile(fs, op.name)
+		if err != nil {
+			return diffResult(err)
+		}
+		return fmt.Sprintf("ok %q", data)
+	case "stat":
+		info, err := hackpadfs.Stat(fs, op.name)
+		if err != nil {
+			return diffResult(err)
+		}
+		perm := info.Mode() & hackpadfs.ModePerm
+		if constraints.FileModeMask != 0 {
+			perm &= constraints.FileModeMask
+		}
+		if op.name == "." {
+			return "ok root" // the root's permissions may be outside the FS's control
+		}
+		if info.IsDir() {
+			return fmt.Sprintf("ok dir %O", perm) // directory sizes are system-dependent
+		}
+		return fmt.Sprintf("ok file %O size=%d", perm, info.Size())
+	case "readdir":
+		entries, err := hackpadfs.ReadDir(fs, op.name)
+		if err != nil {
+			return diffResult(err)
+		}
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+		return fmt.Sprintf("ok %v", names)
+	default:
+		panic("unknown operation: " + op.kind)
+	}
+}
+
+func diffResult(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	for _, e := range diffErrs {
+		if errors.Is(err, e.err) {
+			return "error: " + e.name
+		}
+	}
+	return "error"
+}
@@ -0,0 +1,38 @@
+package fstest
+
+import (
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ExportOptions writes TestData as JSON after tests complete, so CI pipelines and reports can be generated from actual test runs.
+type ExportOptions struct {
+	// FS to write results into, like an os.FS. Results are only exported if FS is set.
+	FS hackpadfs.FS
+	// Dir is the directory in FS to write results into. Defaults to FS's root.
+	// Each test run writes a file named after the run. For example, fstest.FS() with an FSOptions.Name of "mem" writes "mem_FS.json".
+	Dir string
+}
+
+func (e ExportOptions) export(tb testing.TB, runName string, data TestData) {
+	tb.Helper()
+	if e.FS == nil {
+		return
+	}
+	contents, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		tb.Error("Failed to export test data:", err)
+		return
+	}
+	dir := e.Dir
+	if dir == "" {
+		dir = "."
+	}
+	err = hackpadfs.WriteFullFile(e.FS, path.Join(dir, runName+".json"), contents, 0644)
+	if err != nil {
+		tb.Error("Failed to export test data:", err)
+	}
+}
@@ -139,7 +139,7 @@ func TestFileReadAt(tb testing.TB, o FSOptions) {
 		},
 	} {
 		o.tbRun(tb, tc.description, func(tb testing.TB) {
-			tbParallel(tb)
+			o.tbParallel(tb)
 			file, err := fs.Open("foo")
 			if !assert.NoError(tb, err) {
 				return
@@ -448,6 +448,123 @@ func TestFileWriteAt(tb testing.TB, o FSOptions) {
 		assert.Equal(tb, append(make([]byte, offset), []byte(fileContents)...), buf)
 		assert.NoError(tb, file.Close())
 	})
+
+	o.tbRun(tb, "large offset outside file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		file, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, file.Close())
+		}
+
+		fs := commit()
+		file, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() { assert.NoError(tb, file.Close()) })
+		const fileContents = "hello world"
+		const offset = 1 << 20
+		n, err := hackpadfs.WriteAtFile(file, []byte(fileContents), offset)
+		skipNotImplemented(tb, err)
+		assert.Equal(tb, len(fileContents), n)
+		assert.NoError(tb, err)
+
+		info, err := file.Stat()
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(offset+len(fileContents)), info.Size())
+
+		// reads from the hole return zeros
+		buf := make([]byte, 4096)
+		for _, holeOffset := range []int64{0, offset / 2, offset - int64(len(buf))} {
+			n, err = hackpadfs.ReadAtFile(file, buf, holeOffset)
+			skipNotImplemented(tb, err)
+			assert.NoError(tb, err)
+			assert.Equal(tb, len(buf), n)
+			assert.Equal(tb, make([]byte, len(buf)), buf)
+		}
+
+		// a read spanning the end of the hole returns the zeros and data that follow it
+		buf = make([]byte, 5+len(fileContents))
+		n, err = hackpadfs.ReadAtFile(file, buf, offset-5)
+		if err != io.EOF {
+			assert.NoError(tb, err)
+		}
+		assert.Equal(tb, len(buf), n)
+		assert.Equal(tb, append(make([]byte, 5), []byte(fileContents)...), buf)
+
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, append(make([]byte, offset), []byte(fileContents)...), data)
+	})
+
+	o.tbRun(tb, "offset between writes", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		file, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, file.Close())
+		}
+
+		fs := commit()
+		file, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		const offset = 64 << 10
+		_, err = hackpadfs.WriteAtFile(file, []byte("end"), 2*offset)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		_, err = hackpadfs.WriteAtFile(file, []byte("middle"), offset)
+		assert.NoError(tb, err)
+		_, err = hackpadfs.WriteAtFile(file, []byte("start"), 0)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		expected := make([]byte, 2*offset+len("end"))
+		copy(expected, "start")
+		copy(expected[offset:], "middle")
+		copy(expected[2*offset:], "end")
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, len(expected), len(data))
+		assert.Equal(tb, expected, data)
+		info, err := hackpadfs.Stat(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(len(expected)), info.Size())
+	})
+
+	o.tbRun(tb, "seek past end then write", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+
+		fs := commit()
+		file, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		const offset = 32 << 10
+		_, err = hackpadfs.SeekFile(file, offset, io.SeekStart)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		// seeking alone does not extend the file
+		info, err := file.Stat()
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(len("hello")), info.Size())
+
+		_, err = hackpadfs.WriteFile(file, []byte("world"))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		expected := make([]byte, offset+len("world"))
+		copy(expected, "hello")
+		copy(expected[offset:], "world")
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, expected, data)
+	})
 }
 
 func TestFileReadDir(tb testing.TB, o FSOptions) {
@@ -598,7 +715,7 @@ func TestFileReadDir(tb testing.TB, o FSOptions) {
 		tb.Cleanup(func() { assert.NoError(tb, file.Close()) })
 		entries, err := hackpadfs.ReadDirFile(file, 0)
 		skipNotImplemented(tb, err)
-		if assert.IsType(tb, &hackpadfs.PathError{}, err) {
+		if !o.tryAssertMatchErr(tb, &hackpadfs.PathError{Op: "readdir", Path: "foo", Err: hackpadfs.ErrNotDir}, err) && assert.IsType(tb, &hackpadfs.PathError{}, err) {
 			err := err.(*hackpadfs.PathError)
 			assert.ErrorIs(tb, hackpadfs.ErrNotDir, err)
 			assert.Contains(tb, []string{
@@ -610,6 +727,143 @@ func TestFileReadDir(tb testing.TB, o FSOptions) {
 		}
 		assert.Equal(tb, 0, len(entries))
 	})
+
+	pageNames := []string{"e", "b", "g", "a", "f", "c", "d"}
+	setupPageDir := func(tb testing.TB) hackpadfs.FS {
+		tb.Helper()
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0700))
+		for _, name := range pageNames {
+			f, err := hackpadfs.Create(setupFS, "dir/"+name)
+			if assert.NoError(tb, err) {
+				assert.NoError(tb, f.Close())
+			}
+		}
+		return commit()
+	}
+	// readPages reads 'file' in pages of 'n' entries until io.EOF, returning all entry names in read order
+	readPages := func(tb testing.TB, file hackpadfs.File, n int, betweenPages func()) []string {
+		tb.Helper()
+		var names []string
+		for page := 0; page <= len(pageNames)+1; page++ {
+			entries, err := hackpadfs.ReadDirFile(file, n)
+			skipNotImplemented(tb, err)
+			if err == io.EOF {
+				assert.Equal(tb, 0, len(entries))
+				return names
+			}
+			if !assert.NoError(tb, err) {
+				return names
+			}
+			if len(entries) == 0 || len(entries) > n {
+				tb.Errorf("ReadDir(%d) returned %d entries, expected 1 to %d", n, len(entries), n)
+			}
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			if page == 0 && betweenPages != nil {
+				betweenPages()
+			}
+		}
+		tb.Errorf("ReadDir(%d) did not return io.EOF after %d pages", n, len(pageNames)+1)
+		return names
+	}
+
+	o.tbRun(tb, "readdir pages", func(tb testing.TB) {
+		fs := setupPageDir(tb)
+		file, err := fs.Open("dir")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() { assert.NoError(tb, file.Close()) })
+
+		names := readPages(tb, file, 2, nil)
+		sort.Strings(names)
+		assert.Equal(tb, []string{"a", "b", "c", "d", "e", "f", "g"}, names)
+	})
+
+	o.tbRun(tb, "readdir pages end with EOF", func(tb testing.TB) {
+		fs := setupPageDir(tb)
+		file, err := fs.Open("dir")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() { assert.NoError(tb, file.Close()) })
+
+		entries, err := hackpadfs.ReadDirFile(file, len(pageNames))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, len(pageNames), len(entries))
+
+		for i := 0; i < 2; i++ {
+			entries, err = hackpadfs.ReadDirFile(file, 1)
+			assert.Equal(tb, io.EOF, err)
+			assert.Equal(tb, 0, len(entries))
+		}
+	})
+
+	o.tbRun(tb, "readdir all after pages", func(tb testing.TB) {
+		fs := setupPageDir(tb)
+		file, err := fs.Open("dir")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() { assert.NoError(tb, file.Close()) })
+
+		first, err := hackpadfs.ReadDirFile(file, 3)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		// ReadDir(n <= 0) returns the remaining entries and a nil error, even at the end of the directory
+		rest, err := hackpadfs.ReadDirFile(file, -1)
+		assert.NoError(tb, err)
+		names := make([]string, 0, len(first)+len(rest))
+		for _, entry := range append(first, rest...) {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		assert.Equal(tb, []string{"a", "b", "c", "d", "e", "f", "g"}, names)
+
+		rest, err = hackpadfs.ReadDirFile(file, 0)
+		assert.NoError(tb, err)
+		assert.Equal(tb, 0, len(rest))
+	})
+
+	o.tbRun(tb, "readdir full read is sorted", func(tb testing.TB) {
+		fs := setupPageDir(tb)
+		entries, err := hackpadfs.ReadDir(fs, "dir")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(tb, []string{"a", "b", "c", "d", "e", "f", "g"}, names)
+	})
+
+	o.tbRun(tb, "readdir pages with entry added", func(tb testing.TB) {
+		// Like POSIX readdir, an entry added between pages may or may not be returned.
+		// Every other entry must be returned exactly once.
+		fs := setupPageDir(tb)
+		file, err := fs.Open("dir")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() { assert.NoError(tb, file.Close()) })
+
+		names := readPages(tb, file, 2, func() {
+			f, err := hackpadfs.Create(fs, "dir/added")
+			skipNotImplemented(tb, err)
+			if assert.NoError(tb, err) {
+				assert.NoError(tb, f.Close())
+			}
+		})
+		sort.Strings(names)
+		expected := []string{"a", "b", "c", "d", "e", "f", "g"}
+		if len(names) > 0 && names[0] == "added" {
+			expected = append([]string{"added"}, expected...)
+		}
+		assert.Equal(tb, expected, names)
+	})
 }
 
 func TestFileStat(tb testing.TB, o FSOptions) {
@@ -712,4 +966,105 @@ func TestFileTruncate(tb testing.TB, o FSOptions) {
 			}
 		})
 	}
+
+	setupFile := func(tb testing.TB) hackpadfs.FS {
+		tb.Helper()
+		setupFS, commit := o.Setup.FS(tb)
+		file, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(file, []byte(fileContents))
+			assert.NoError(tb, err)
+			assert.NoError(tb, file.Close())
+		}
+		return commit()
+	}
+
+	o.tbRun(tb, "grow fills with zeros", func(tb testing.TB) {
+		fs := setupFile(tb)
+		file, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		err = hackpadfs.TruncateFile(file, int64(len(fileContents))+3)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents+"\x00\x00\x00", string(data))
+	})
+
+	o.tbRun(tb, "shrink then grow fills with zeros", func(tb testing.TB) {
+		fs := setupFile(tb)
+		file, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		err = hackpadfs.TruncateFile(file, 2)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		err = hackpadfs.TruncateFile(file, 5)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "he\x00\x00\x00", string(data))
+	})
+
+	o.tbRun(tb, "offset unchanged", func(tb testing.TB) {
+		fs := setupFile(tb)
+		file, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		buf := make([]byte, 8)
+		_, err = io.ReadFull(file, buf)
+		assert.NoError(tb, err)
+		err = hackpadfs.TruncateFile(file, 2)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		n, err := file.Read(buf)
+		assert.Equal(tb, 0, n)
+		assert.Equal(tb, io.EOF, err)
+		_, err = hackpadfs.WriteFile(file, []byte("!"))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "he\x00\x00\x00\x00\x00\x00!", string(data))
+	})
+
+	o.tbRun(tb, "visible to other open files", func(tb testing.TB) {
+		fs := setupFile(tb)
+		reader, err := fs.Open("foo")
+		if !assert.NoError(tb, err) {
+			return
+		}
+		file, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		err = hackpadfs.TruncateFile(file, 5)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, file.Close())
+
+		info, err := reader.Stat()
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, int64(5), info.Size())
+		}
+		data, err := io.ReadAll(reader)
+		assert.NoError(tb, err)
+		assert.Equal(tb, "hello", string(data))
+		assert.NoError(tb, reader.Close())
+	})
 }
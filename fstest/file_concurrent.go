@@ -2,6 +2,7 @@ package fstest
 
 import (
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/hack-pad/hackpadfs"
@@ -108,3 +109,35 @@ func TestConcurrentFileStat(tb testing.TB, o FSOptions) {
 		}
 	})
 }
+
+func TestConcurrentFileTruncate(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "readers see a prefix", func(tb testing.TB) {
+		const fileContents = "hello world"
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte(fileContents), 0666))
+		fs := commit()
+		concurrentTasks(0, func(i int) {
+			if i%2 == 0 {
+				f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly, 0)
+				skipNotImplemented(tb, err)
+				if assert.NoError(tb, err) {
+					err := hackpadfs.TruncateFile(f, 5) // only shrink, so readers never see zeros
+					skipNotImplemented(tb, err)
+					assert.NoError(tb, err)
+					assert.NoError(tb, f.Close())
+				}
+				return
+			}
+			f, err := fs.Open("foo")
+			if assert.NoError(tb, err) {
+				buf := make([]byte, len(fileContents))
+				n, err := f.Read(buf)
+				if err != io.EOF {
+					assert.NoError(tb, err)
+				}
+				assert.Prefix(tb, string(buf[:n]), fileContents)
+				assert.NoError(tb, f.Close())
+			}
+		})
+	})
+}
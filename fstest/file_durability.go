@@ -0,0 +1,102 @@
+package fstest
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// TestFileSyncDurability verifies data written and synced with File.Sync() is visible after reopening the FS with FSOptions.Reopen.
+// If Reopen is not set, synced data is read back through the same FS instead.
+func TestFileSyncDurability(tb testing.TB, o FSOptions) {
+	reopen := func(tb testing.TB, fs hackpadfs.FS) hackpadfs.FS {
+		tb.Helper()
+		if o.Reopen == nil {
+			return fs
+		}
+		return o.Reopen(tb, fs)
+	}
+	// openSynced opens 'name' for writing and returns it, after writing and syncing 'contents'
+	openSynced := func(tb testing.TB, fs hackpadfs.FS, name, contents string) hackpadfs.File {
+		tb.Helper()
+		f, err := hackpadfs.OpenFile(fs, name, hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0666)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() {
+			_ = f.Close()
+		})
+		_, err = hackpadfs.WriteFile(f, []byte(contents))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		err = hackpadfs.SyncFile(f)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		return f
+	}
+	assertContents := func(tb testing.TB, fs hackpadfs.FS, name, contents string) {
+		tb.Helper()
+		data, err := hackpadfs.ReadFile(fs, name)
+		assert.NoError(tb, err)
+		assert.Equal(tb, contents, string(data))
+	}
+
+	o.tbRun(tb, "sync then reopen", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		openSynced(tb, fs, "foo", "hello world")
+		// the file is still open, so only synced data is guaranteed to be stored
+		assertContents(tb, reopen(tb, fs), "foo", "hello world")
+	})
+
+	o.tbRun(tb, "sync overwrite then reopen", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		f := openSynced(tb, fs, "foo", "hello world")
+		_, err := hackpadfs.WriteAtFile(f, []byte("HELLO"), 0)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, hackpadfs.SyncFile(f))
+		assertContents(tb, reopen(tb, fs), "foo", "HELLO world")
+	})
+
+	o.tbRun(tb, "sync truncate then reopen", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		f := openSynced(tb, fs, "foo", "hello world")
+		err := hackpadfs.TruncateFile(f, 5)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, hackpadfs.SyncFile(f))
+		assertContents(tb, reopen(tb, fs), "foo", "hello")
+	})
+
+	o.tbRun(tb, "sync in new directory then reopen", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.Mkdir(fs, "dir", 0700)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		openSynced(tb, fs, "dir/foo", "hello")
+
+		reopened := reopen(tb, fs)
+		info, err := hackpadfs.Stat(reopened, "dir")
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, true, info.IsDir())
+		}
+		assertContents(tb, reopened, "dir/foo", "hello")
+	})
+
+	o.tbRun(tb, "close then reopen", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		f := openSynced(tb, fs, "foo", "hello")
+		_, err := hackpadfs.WriteFile(f, []byte(" world"))
+		assert.NoError(tb, err)
+		// Close() stores all written data, synced or not
+		assert.NoError(tb, f.Close())
+		assertContents(tb, reopen(tb, fs), "foo", "hello world")
+	})
+}
@@ -0,0 +1,117 @@
+package fstest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// largeFileSize is beyond both signed and unsigned 32-bit offsets
+const largeFileSize = 5 << 30 // 5 GiB
+
+// TestFileLarge verifies Seek, ReadAt, WriteAt, and Truncate on files larger than 4 GiB.
+// Only runs if FSOptions.LargeFiles is set.
+func TestFileLarge(tb testing.TB, o FSOptions) {
+	const (
+		fileContents = "hello world"
+		offset       = largeFileSize - int64(len(fileContents))
+	)
+
+	setupLargeFile := func(tb testing.TB) hackpadfs.File {
+		tb.Helper()
+		if !o.LargeFiles {
+			tb.Skip("FSOptions.LargeFiles is not set")
+		}
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		file, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite|hackpadfs.FlagCreate, 0666)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() {
+			_ = file.Close()
+		})
+		return file
+	}
+
+	o.tbRun(tb, "truncate", func(tb testing.TB) {
+		file := setupLargeFile(tb)
+		err := hackpadfs.TruncateFile(file, largeFileSize)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		info, err := file.Stat()
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(largeFileSize), info.Size())
+
+		err = hackpadfs.TruncateFile(file, 1<<31+1)
+		assert.NoError(tb, err)
+		info, err = file.Stat()
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(1<<31+1), info.Size())
+	})
+
+	o.tbRun(tb, "write at and read at", func(tb testing.TB) {
+		file := setupLargeFile(tb)
+		n, err := hackpadfs.WriteAtFile(file, []byte(fileContents), offset)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, len(fileContents), n)
+		info, err := file.Stat()
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(largeFileSize), info.Size())
+
+		buf := make([]byte, len(fileContents))
+		n, err = hackpadfs.ReadAtFile(file, buf, offset)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, len(fileContents), n)
+		assert.Equal(tb, fileContents, string(buf))
+
+		// the unwritten region before a large offset reads as zeros
+		n, err = hackpadfs.ReadAtFile(file, buf, 1<<32)
+		assert.NoError(tb, err)
+		assert.Equal(tb, len(buf), n)
+		assert.Equal(tb, make([]byte, len(buf)), buf)
+	})
+
+	o.tbRun(tb, "seek and read", func(tb testing.TB) {
+		file := setupLargeFile(tb)
+		_, err := hackpadfs.WriteAtFile(file, []byte(fileContents), offset)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		off, err := hackpadfs.SeekFile(file, offset, io.SeekStart)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, offset, off)
+		buf := make([]byte, len(fileContents))
+		n, err := io.ReadFull(file, buf)
+		assert.NoError(tb, err)
+		assert.Equal(tb, len(fileContents), n)
+		assert.Equal(tb, fileContents, string(buf))
+
+		off, err = hackpadfs.SeekFile(file, -int64(len(fileContents)), io.SeekEnd)
+		assert.NoError(tb, err)
+		assert.Equal(tb, offset, off)
+		off, err = hackpadfs.SeekFile(file, 1, io.SeekCurrent)
+		assert.NoError(tb, err)
+		assert.Equal(tb, offset+1, off)
+	})
+
+	o.tbRun(tb, "seek then write", func(tb testing.TB) {
+		file := setupLargeFile(tb)
+		_, err := hackpadfs.SeekFile(file, offset, io.SeekStart)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		n, err := hackpadfs.WriteFile(file, []byte(fileContents))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, len(fileContents), n)
+		info, err := file.Stat()
+		assert.NoError(tb, err)
+		assert.Equal(tb, int64(largeFileSize), info.Size())
+	})
+}
@@ -4,6 +4,7 @@ import (
 	// Avoid importing "os" package in fstest if we can, since not all environments may be able to support it.
 	// Not to mention it should compile a little faster. :)
 
+	"errors"
 	"fmt"
 	"io"
 	"testing"
@@ -503,6 +504,60 @@ func TestOpenFile(tb testing.TB, o FSOptions) {
 		}, fs)
 	})
 
+	o.tbRun(tb, "truncate then write", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello world"), 0666))
+
+		fs := commit()
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagTruncate|hackpadfs.FlagWriteOnly, 0700)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(f, []byte("hi"))
+			skipNotImplemented(tb, err)
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "hi", string(data))
+	})
+
+	o.tbRun(tb, "truncate on create existing file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello world"), 0600))
+
+		fs := commit()
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagCreate|hackpadfs.FlagTruncate|hackpadfs.FlagWriteOnly, 0666)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: 0600},
+		}, fs)
+	})
+
+	o.tbRun(tb, "truncate with open reader", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello world"), 0666))
+
+		fs := commit()
+		reader, err := fs.Open("foo")
+		if !assert.NoError(tb, err) {
+			return
+		}
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagTruncate|hackpadfs.FlagWriteOnly, 0)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		buf := make([]byte, 5)
+		n, err := reader.Read(buf)
+		assert.Equal(tb, 0, n)
+		assert.Equal(tb, io.EOF, err)
+		assert.NoError(tb, reader.Close())
+	})
+
 	o.tbRun(tb, "truncate on non-existent file", func(tb testing.TB) {
 		_, commit := o.Setup.FS(tb)
 		fs := commit()
@@ -847,6 +902,183 @@ func TestRename(tb testing.TB, o FSOptions) {
 			"baz/bar": {Mode: 0666, Size: int64(len(fileContents))},
 		}, fs)
 	})
+
+	o.tbRun(tb, "newpath is existing file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("foo contents"), 0600))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "bar", []byte("bar"), 0666))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		data, err := hackpadfs.ReadFile(fs, "bar")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "foo contents", string(data))
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"bar": {Mode: 0600, Size: int64(len("foo contents"))},
+		}, fs)
+	})
+
+	o.tbRun(tb, "non-empty directory onto empty directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo/bar", []byte("bar"), 0666))
+		assert.NoError(tb, setupFS.Mkdir("baz", 0700))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "baz")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "rename",
+			Old: "foo",
+			New: "baz",
+			Err: hackpadfs.ErrExist,
+		}, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo":     {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+			"foo/bar": {Mode: 0666, Size: 3},
+			"baz":     {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+		}, fs)
+	})
+
+	o.tbRun(tb, "file onto directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("foo"), 0666))
+		assert.NoError(tb, setupFS.Mkdir("bar", 0700))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "rename",
+			Old: "foo",
+			New: "bar",
+			Err: hackpadfs.ErrExist,
+		}, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: 0666, Size: 3},
+			"bar": {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+		}, fs)
+	})
+
+	o.tbRun(tb, "directory onto file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "bar", []byte("bar"), 0666))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "rename",
+			Old: "foo",
+			New: "bar",
+			Err: hackpadfs.ErrNotDir,
+		}, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+			"bar": {Mode: 0666, Size: 3},
+		}, fs)
+	})
+
+	o.tbRun(tb, "newpath parent does not exist", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("foo"), 0666))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "bar/baz")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "rename",
+			Old: "foo",
+			New: "bar/baz",
+			Err: hackpadfs.ErrNotExist,
+		}, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: 0666, Size: 3},
+		}, fs)
+	})
+
+	o.tbRun(tb, "directory into itself", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "foo", "foo/bar")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "rename",
+			Old: "foo",
+			New: "foo/bar",
+			Err: hackpadfs.ErrInvalid,
+		}, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+		}, fs)
+	})
+
+	o.tbRun(tb, "directory across parent directories", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("a", 0700))
+		assert.NoError(tb, setupFS.Mkdir("a/dir", 0700))
+		assert.NoError(tb, setupFS.Mkdir("a/dir/sub", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "a/dir/sub/foo", []byte("foo"), 0666))
+		assert.NoError(tb, setupFS.Mkdir("b", 0700))
+		assert.NoError(tb, setupFS.Mkdir("b/c", 0700))
+
+		fs := commit()
+		err := hackpadfs.Rename(fs, "a/dir", "b/c/dir")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		data, err := hackpadfs.ReadFile(fs, "b/c/dir/sub/foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "foo", string(data))
+		_, err = hackpadfs.Stat(fs, "a/dir/sub/foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"a":               {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+			"b":               {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+			"b/c":             {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+			"b/c/dir":         {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+			"b/c/dir/sub":     {Mode: hackpadfs.ModeDir | 0700, IsDir: true},
+			"b/c/dir/sub/foo": {Mode: 0666, Size: 3},
+		}, fs)
+		entries, err := hackpadfs.ReadDir(fs, "a")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, 0, len(entries))
+	})
+
+	o.tbRun(tb, "open file", func(tb testing.TB) {
+		const fileContents = `hello world`
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte(fileContents), 0666))
+
+		fs := commit()
+		f, err := fs.Open("foo")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		err = hackpadfs.Rename(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		// the open file still reads its contents after the rename
+		buf := make([]byte, len(fileContents))
+		n, err := io.ReadFull(f, buf)
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents, string(buf[:n]))
+		assert.NoError(tb, f.Close())
+
+		data, err := hackpadfs.ReadFile(fs, "bar")
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents, string(data))
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
 }
 
 // Stat returns a FileInfo describing the named file. If there is an error, it will be of type *PathError.
@@ -863,7 +1095,7 @@ func testStat(tb testing.TB, o FSOptions, stater func(testing.TB, hackpadfs.FS,
 		_, commit := o.Setup.FS(tb)
 		fs := commit()
 		_, err := stater(tb, fs, "foo/../bar")
-		if assert.IsType(tb, &hackpadfs.PathError{}, err) {
+		if !o.tryAssertMatchErr(tb, &hackpadfs.PathError{Op: "stat", Path: "foo/../bar", Err: hackpadfs.ErrInvalid}, err) && assert.IsType(tb, &hackpadfs.PathError{}, err) {
 			err := err.(*hackpadfs.PathError)
 			assert.Equal(tb, "foo/../bar", err.Path)
 			assert.ErrorIs(tb, hackpadfs.ErrInvalid, err)
@@ -999,6 +1231,116 @@ func TestChmod(tb testing.TB, o FSOptions) {
 	})
 }
 
+// Chown changes the numeric uid and gid of the named file.
+// If the file is a symbolic link, it changes the uid and gid of the link's target.
+// A uid or gid of -1 means to not change that value.
+// If there is an error, it will be of type *PathError.
+//
+// FSs which don't store ownership must fail with hackpadfs.ErrNotImplemented.
+func TestChown(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "file does not exist", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.Chown(fs, "foo", -1, -1)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "chown",
+			Path: "foo",
+			Err:  hackpadfs.ErrNotExist,
+		}, err)
+	})
+
+	o.tbRun(tb, "not implemented", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0755))
+		f, err := hackpadfs.Create(setupFS, "foo/bar")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		err = hackpadfs.Chown(fs, "foo/bar", -1, -1)
+		if !errors.Is(err, hackpadfs.ErrNotImplemented) {
+			return // FS implements Chown, which is verified by the other tests
+		}
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "chown",
+			Path: "foo/bar",
+			Err:  hackpadfs.ErrNotImplemented,
+		}, err)
+	})
+
+	o.tbRun(tb, "invalid IDs", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		for _, ids := range [][2]int{{-2, -1}, {-1, -2}} {
+			err := hackpadfs.Chown(fs, "foo", ids[0], ids[1])
+			skipNotImplemented(tb, err)
+			o.assertEqualPathErr(tb, &hackpadfs.PathError{
+				Op:   "chown",
+				Path: "foo",
+				Err:  hackpadfs.ErrInvalid,
+			}, err)
+		}
+	})
+
+	o.tbRun(tb, "change owner", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		uid, gid := -1, -1
+		if o.Owner != nil {
+			// only the current owner is guaranteed to be assignable without elevated permissions
+			info, err := hackpadfs.Stat(fs, "foo")
+			assert.NoError(tb, err)
+			uid, gid = o.Owner(info)
+		}
+		err = hackpadfs.Chown(fs, "foo", uid, gid)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		if o.Owner == nil {
+			return
+		}
+		info, err := hackpadfs.Stat(fs, "foo")
+		assert.NoError(tb, err)
+		actualUID, actualGID := o.Owner(info)
+		assert.Equal(tb, uid, actualUID)
+		assert.Equal(tb, gid, actualGID)
+	})
+
+	o.tbRun(tb, "keep owner", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		if o.Owner == nil {
+			tb.Skip("FSOptions.Owner is not set")
+		}
+		info, err := hackpadfs.Stat(fs, "foo")
+		assert.NoError(tb, err)
+		uid, gid := o.Owner(info)
+		err = hackpadfs.Chown(fs, "foo", -1, -1)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		info, err = hackpadfs.Stat(fs, "foo")
+		assert.NoError(tb, err)
+		actualUID, actualGID := o.Owner(info)
+		assert.Equal(tb, uid, actualUID)
+		assert.Equal(tb, gid, actualGID)
+	})
+}
+
 // Chtimes changes the access and modification times of the named file, similar to the Unix utime() or utimes() functions.
 //
 // The underlying filesystem may truncate or round the values to a less precise time unit. If there is an error, it will be of type *PathError.
@@ -1149,7 +1491,7 @@ func TestReadDir(tb testing.TB, o FSOptions) {
 		fs := commit()
 		_, err = hackpadfs.ReadDir(fs, "foo")
 		skipNotImplemented(tb, err)
-		if assert.IsType(tb, &hackpadfs.PathError{}, err) {
+		if !o.tryAssertMatchErr(tb, &hackpadfs.PathError{Op: "readdir", Path: "foo", Err: hackpadfs.ErrNotDir}, err) && assert.IsType(tb, &hackpadfs.PathError{}, err) {
 			err := err.(*hackpadfs.PathError)
 			assert.ErrorIs(tb, hackpadfs.ErrNotDir, err)
 			assert.Contains(tb, []string{
@@ -1162,47 +1504,524 @@ func TestReadDir(tb testing.TB, o FSOptions) {
 	})
 }
 
-// TODO Symlink
+// Symlink creates newname as a symbolic link to oldname.
+// If there is an error, it will be of type *LinkError.
+//
+// Relative targets are resolved against the directory containing the link.
+func TestSymlink(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "create symlink", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
 
-func TestWriteFile(tb testing.TB, o FSOptions) {
-	o.tbRun(tb, "not exists", func(tb testing.TB) {
-		_, commit := o.Setup.FS(tb)
 		fs := commit()
-		err := hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0765)
+		err = hackpadfs.Symlink(fs, "foo", "bar")
 		skipNotImplemented(tb, err)
 		assert.NoError(tb, err)
-
-		o.tryAssertEqualFS(tb, map[string]fsEntry{
-			"foo": {Size: 3, Mode: 0765},
-		}, fs)
-		contents, err := hackpadfs.ReadFile(fs, "foo")
-		assert.NoError(tb, err)
-		assert.Equal(tb, "bar", string(contents))
+		info, err := hackpadfs.Lstat(fs, "bar")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, "bar", info.Name())
+			assert.Equal(tb, hackpadfs.ModeSymlink, info.Mode().Type())
+		}
 	})
 
-	o.tbRun(tb, "file exists", func(tb testing.TB) {
+	o.tbRun(tb, "newname exists", func(tb testing.TB) {
 		setupFS, commit := o.Setup.FS(tb)
-		const (
-			contents = "bar"
-			perm     = 0666
-		)
-		f, err := setupFS.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, perm)
-		if assert.NoError(tb, err) {
-			_, err := hackpadfs.WriteFile(f, []byte(contents))
-			assert.NoError(tb, err)
-			assert.NoError(tb, f.Close())
+		for _, name := range []string{"foo", "bar"} {
+			f, err := hackpadfs.Create(setupFS, name)
+			if assert.NoError(tb, err) {
+				assert.NoError(tb, f.Close())
+			}
 		}
 
 		fs := commit()
-		const (
-			newContents = "baz"
-			newPerm     = 0765
-		)
-		err = hackpadfs.WriteFullFile(fs, "foo", []byte(newContents), newPerm)
+		err := hackpadfs.Symlink(fs, "foo", "bar")
 		skipNotImplemented(tb, err)
-		assert.NoError(tb, err)
-
-		o.tryAssertEqualFS(tb, map[string]fsEntry{
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "symlink",
+			Old: "foo",
+			New: "bar",
+			Err: hackpadfs.ErrExist,
+		}, err)
+	})
+
+	o.tbRun(tb, "parent directory does not exist", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.Symlink(fs, "foo", "bar/baz")
+		skipNotImplemented(tb, err)
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	o.tbRun(tb, "dangling symlink", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		info, err := hackpadfs.Lstat(fs, "bar")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, hackpadfs.ModeSymlink, info.Mode().Type())
+		}
+		_, err = hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		_, err = fs.Open("bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	o.tbRun(tb, "symlink loop", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupSymlink(tb, setupFS, "foo", "bar")
+		setupSymlink(tb, setupFS, "bar", "foo")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		info, err := hackpadfs.Lstat(fs, "foo")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, hackpadfs.ModeSymlink, info.Mode().Type())
+		}
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.Error(tb, err)
+		_, err = fs.Open("foo")
+		assert.Error(tb, err)
+	})
+
+	o.tbRun(tb, "open and stat file through symlink", func(tb testing.TB) {
+		const fileContents = "hello world"
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(f, []byte(fileContents))
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+		assert.NoError(tb, hackpadfs.Chmod(setupFS, "foo", 0755))
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		info, err := hackpadfs.Stat(fs, "bar")
+		assert.NoError(tb, err)
+		o.assertEqualQuickInfo(tb, quickInfo{
+			Name: "bar",
+			Mode: 0755,
+			Size: int64(len(fileContents)),
+		}, asQuickInfo(info))
+		b, err := hackpadfs.ReadFile(fs, "bar")
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileContents, string(b))
+	})
+
+	o.tbRun(tb, "open directory through symlink", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0755))
+		f, err := hackpadfs.Create(setupFS, "foo/baz")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		entries, err := hackpadfs.ReadDir(fs, "bar")
+		assert.NoError(tb, err)
+		o.assertEqualQuickInfos(tb, []quickInfo{
+			{Name: "baz", Mode: 0666},
+		}, asQuickDirInfos(tb, entries))
+		info, err := hackpadfs.Stat(fs, "bar/baz")
+		assert.NoError(tb, err)
+		o.assertEqualQuickInfo(tb, quickInfo{
+			Name: "baz",
+			Mode: 0666,
+		}, asQuickInfo(info))
+	})
+
+	o.tbRun(tb, "create file through symlinked directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0755))
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		f, err := hackpadfs.Create(fs, "bar/baz")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		info, err := hackpadfs.Stat(fs, "foo/baz")
+		assert.NoError(tb, err)
+		o.assertEqualQuickInfo(tb, quickInfo{
+			Name: "baz",
+			Mode: 0666,
+		}, asQuickInfo(info))
+	})
+
+	o.tbRun(tb, "remove symlink", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		err = hackpadfs.Remove(fs, "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		_, err = hackpadfs.LstatOrStat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.NoError(tb, err)
+	})
+
+	o.tbRun(tb, "remove all through symlinked directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0755))
+		f, err := hackpadfs.Create(setupFS, "foo/baz")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		err = hackpadfs.RemoveAll(fs, "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		_, err = hackpadfs.LstatOrStat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		_, err = hackpadfs.Stat(fs, "foo/baz")
+		assert.NoError(tb, err)
+	})
+
+	o.tbRun(tb, "rename symlink", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		err = hackpadfs.Rename(fs, "bar", "baz")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		_, err = hackpadfs.LstatOrStat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		info, err := hackpadfs.Lstat(fs, "baz")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, hackpadfs.ModeSymlink, info.Mode().Type())
+		}
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.NoError(tb, err)
+	})
+
+	o.tbRun(tb, "relative target in nested directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.Mkdir(setupFS, "foo", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo/bar", []byte("baz"), 0666))
+
+		fs := commit()
+		err := hackpadfs.Symlink(fs, "bar", "foo/link")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		target, err := hackpadfs.Readlink(fs, "foo/link")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, "bar", target)
+		contents, err := hackpadfs.ReadFile(fs, "foo/link")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "baz", string(contents))
+	})
+
+	o.tbRun(tb, "relative target in parent directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.MkdirAll(setupFS, "foo/bar", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo/baz", []byte("baz"), 0666))
+
+		fs := commit()
+		err := hackpadfs.Symlink(fs, "../baz", "foo/bar/link")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		contents, err := hackpadfs.ReadFile(fs, "foo/bar/link")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "baz", string(contents))
+	})
+}
+
+func setupSymlink(tb testing.TB, fs hackpadfs.FS, oldname, newname string) {
+	tb.Helper()
+	err := hackpadfs.Symlink(fs, oldname, newname)
+	skipNotImplemented(tb, err)
+	assert.NoError(tb, err)
+}
+
+// requireLstat skips FSs which can't inspect symlinks, like those which can't reproduce symlinks from their setup FS
+func requireLstat(tb testing.TB, fs hackpadfs.FS) {
+	tb.Helper()
+	_, err := hackpadfs.Lstat(fs, ".")
+	skipNotImplemented(tb, err)
+}
+
+// Link creates newname as a hard link to the oldname file.
+// If there is an error, it will be of type *LinkError.
+func TestLink(tb testing.TB, o FSOptions) {
+	createFile := func(tb testing.TB, fs hackpadfs.FS, name, contents string) {
+		tb.Helper()
+		f, err := hackpadfs.Create(fs, name)
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(f, []byte(contents))
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+	}
+
+	o.tbRun(tb, "oldname does not exist", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.Link(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "link",
+			Old: "foo",
+			New: "bar",
+			Err: hackpadfs.ErrNotExist,
+		}, err)
+	})
+
+	o.tbRun(tb, "newname exists", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		createFile(tb, setupFS, "foo", "foo")
+		createFile(tb, setupFS, "bar", "bar")
+
+		fs := commit()
+		err := hackpadfs.Link(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		o.assertEqualLinkErr(tb, &hackpadfs.LinkError{
+			Op:  "link",
+			Old: "foo",
+			New: "bar",
+			Err: hackpadfs.ErrExist,
+		}, err)
+	})
+
+	o.tbRun(tb, "link a directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0755))
+
+		fs := commit()
+		err := hackpadfs.Link(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.IsType(tb, &hackpadfs.LinkError{}, err)
+		_, err = hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	o.tbRun(tb, "names share contents", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		createFile(tb, setupFS, "foo", "hello")
+
+		fs := commit()
+		err := hackpadfs.Link(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		b, err := hackpadfs.ReadFile(fs, "bar")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "hello", string(b))
+
+		f, err := hackpadfs.OpenFile(fs, "bar", hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate, 0)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(f, []byte("world"))
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+		b, err = hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "world", string(b))
+	})
+
+	o.tbRun(tb, "remove one name", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		createFile(tb, setupFS, "foo", "hello")
+
+		fs := commit()
+		err := hackpadfs.Link(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, hackpadfs.Remove(fs, "foo"))
+
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		b, err := hackpadfs.ReadFile(fs, "bar")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "hello", string(b))
+	})
+
+	o.tbRun(tb, "rename one name", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		createFile(tb, setupFS, "foo", "hello")
+
+		fs := commit()
+		err := hackpadfs.Link(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		err = hackpadfs.Rename(fs, "bar", "baz")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		_, err = hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		f, err := hackpadfs.OpenFile(fs, "baz", hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate, 0)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(f, []byte("world"))
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+		b, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "world", string(b))
+	})
+}
+
+// Readlink returns the destination of the named symbolic link.
+// If there is an error, it will be of type *PathError.
+func TestReadlink(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "file does not exist", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		_, err := hackpadfs.Readlink(fs, "foo")
+		skipNotImplemented(tb, err)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "readlink",
+			Path: "foo",
+			Err:  hackpadfs.ErrNotExist,
+		}, err)
+	})
+
+	o.tbRun(tb, "not a symlink", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		_, err = hackpadfs.Readlink(fs, "foo")
+		skipNotImplemented(tb, err)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "readlink",
+			Path: "foo",
+			Err:  hackpadfs.ErrInvalid,
+		}, err)
+	})
+
+	o.tbRun(tb, "read symlink target", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		f, err := hackpadfs.Create(setupFS, "foo")
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		target, err := hackpadfs.Readlink(fs, "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, "foo", target)
+	})
+
+	o.tbRun(tb, "read dangling symlink target", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		target, err := hackpadfs.Readlink(fs, "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, "foo", target)
+	})
+}
+
+// Lstat returns a FileInfo describing the named file.
+// If the file is a symbolic link, the returned FileInfo describes the symbolic link. Lstat makes no attempt to follow the link.
+// If there is an error, it will be of type *PathError.
+func TestLstat(tb testing.TB, o FSOptions) {
+	testStat(tb, o, func(tb testing.TB, fs hackpadfs.FS, path string) (hackpadfs.FileInfo, error) {
+		info, err := hackpadfs.Lstat(fs, path)
+		skipNotImplemented(tb, err)
+		return info, err
+	})
+
+	o.tbRun(tb, "lstat a symlink", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0755))
+		setupSymlink(tb, setupFS, "foo", "bar")
+
+		fs := commit()
+		requireLstat(tb, fs)
+		info, err := hackpadfs.Lstat(fs, "bar")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, "bar", info.Name())
+			assert.Equal(tb, hackpadfs.ModeSymlink, info.Mode().Type())
+			assert.Equal(tb, false, info.IsDir())
+		}
+	})
+}
+
+func TestWriteFile(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "not exists", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0765)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Size: 3, Mode: 0765},
+		}, fs)
+		contents, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "bar", string(contents))
+	})
+
+	o.tbRun(tb, "file exists", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		const (
+			contents = "bar"
+			perm     = 0666
+		)
+		f, err := setupFS.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate, perm)
+		if assert.NoError(tb, err) {
+			_, err := hackpadfs.WriteFile(f, []byte(contents))
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		const (
+			newContents = "baz"
+			newPerm     = 0765
+		)
+		err = hackpadfs.WriteFullFile(fs, "foo", []byte(newContents), newPerm)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
 			"foo": {Size: 3, Mode: perm}, // mode shouldn't change
 		}, fs)
 		buf, err := hackpadfs.ReadFile(fs, "foo")
@@ -1229,3 +2048,366 @@ func TestWriteFile(tb testing.TB, o FSOptions) {
 		}, fs)
 	})
 }
+
+// setupWalkTree creates a small tree for WalkDir and Sub tests:
+//
+//	walk/
+//	walk/a
+//	walk/b/
+//	walk/b/c
+//	walk/d/
+//	walk/d/e
+//	walk/f
+func setupWalkTree(tb testing.TB, setupFS SetupFS) {
+	tb.Helper()
+	for _, name := range []string{"walk", "walk/b", "walk/d"} {
+		assert.NoError(tb, setupFS.Mkdir(name, 0755))
+	}
+	for _, name := range []string{"walk/a", "walk/b/c", "walk/d/e", "walk/f"} {
+		f, err := hackpadfs.Create(setupFS, name)
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(f, []byte(name))
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+	}
+}
+
+// WalkDir walks the file tree rooted at root, calling fn for each file or directory in the tree, including root.
+// Files are walked in lexical order. Returning SkipDir from fn skips the directory, or the remaining files in a file's directory.
+func TestWalkDir(tb testing.TB, o FSOptions) {
+	type walkEntry struct {
+		Path  string
+		IsDir bool
+	}
+	walk := func(tb testing.TB, fs hackpadfs.FS, root string, visit func(path string) error) ([]walkEntry, error) {
+		tb.Helper()
+		var entries []walkEntry
+		err := hackpadfs.WalkDir(fs, root, func(path string, d hackpadfs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			entries = append(entries, walkEntry{Path: path, IsDir: d.IsDir()})
+			if visit != nil {
+				return visit(path)
+			}
+			return nil
+		})
+		skipNotImplemented(tb, err)
+		return entries, err
+	}
+
+	o.tbRun(tb, "lexical order", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		entries, err := walk(tb, fs, "walk", nil)
+		assert.NoError(tb, err)
+		assert.Equal(tb, []walkEntry{
+			{Path: "walk", IsDir: true},
+			{Path: "walk/a"},
+			{Path: "walk/b", IsDir: true},
+			{Path: "walk/b/c"},
+			{Path: "walk/d", IsDir: true},
+			{Path: "walk/d/e"},
+			{Path: "walk/f"},
+		}, entries)
+	})
+
+	o.tbRun(tb, "sub directory root", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		entries, err := walk(tb, fs, "walk/b", nil)
+		assert.NoError(tb, err)
+		assert.Equal(tb, []walkEntry{
+			{Path: "walk/b", IsDir: true},
+			{Path: "walk/b/c"},
+		}, entries)
+	})
+
+	o.tbRun(tb, "file root", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		entries, err := walk(tb, fs, "walk/a", nil)
+		assert.NoError(tb, err)
+		assert.Equal(tb, []walkEntry{
+			{Path: "walk/a"},
+		}, entries)
+	})
+
+	o.tbRun(tb, "root does not exist", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		entries, err := walk(tb, fs, "foo", nil)
+		assert.Equal(tb, 0, len(entries))
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err) // op varies by the FS's Stat support
+	})
+
+	o.tbRun(tb, "skip dir", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		entries, err := walk(tb, fs, "walk", func(path string) error {
+			if path == "walk/b" {
+				return hackpadfs.SkipDir
+			}
+			return nil
+		})
+		assert.NoError(tb, err)
+		assert.Equal(tb, []walkEntry{
+			{Path: "walk", IsDir: true},
+			{Path: "walk/a"},
+			{Path: "walk/b", IsDir: true},
+			{Path: "walk/d", IsDir: true},
+			{Path: "walk/d/e"},
+			{Path: "walk/f"},
+		}, entries)
+	})
+
+	o.tbRun(tb, "skip dir on file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		entries, err := walk(tb, fs, "walk", func(path string) error {
+			if path == "walk/b/c" {
+				return hackpadfs.SkipDir // skips remaining files in walk/b
+			}
+			return nil
+		})
+		assert.NoError(tb, err)
+		assert.Equal(tb, []walkEntry{
+			{Path: "walk", IsDir: true},
+			{Path: "walk/a"},
+			{Path: "walk/b", IsDir: true},
+			{Path: "walk/b/c"},
+			{Path: "walk/d", IsDir: true},
+			{Path: "walk/d/e"},
+			{Path: "walk/f"},
+		}, entries)
+	})
+
+	o.tbRun(tb, "stop on error", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		walkErr := errors.New("some error")
+		entries, err := walk(tb, fs, "walk", func(path string) error {
+			if path == "walk/b" {
+				return walkErr
+			}
+			return nil
+		})
+		assert.Equal(tb, walkErr, err)
+		assert.Equal(tb, []walkEntry{
+			{Path: "walk", IsDir: true},
+			{Path: "walk/a"},
+			{Path: "walk/b", IsDir: true},
+		}, entries)
+	})
+}
+
+// Sub returns an FS corresponding to the subtree rooted at dir.
+// Paths in the sub FS, including those in errors, are relative to dir.
+func TestSub(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "invalid dir", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		_, err := hackpadfs.Sub(fs, "../dir")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "sub",
+			Path: "../dir",
+			Err:  hackpadfs.ErrInvalid,
+		}, err)
+	})
+
+	o.tbRun(tb, "read files", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		subFS, err := hackpadfs.Sub(fs, "walk")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		data, err := hackpadfs.ReadFile(subFS, "a")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "walk/a", string(data))
+		info, err := hackpadfs.Stat(subFS, "b")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, true, info.IsDir())
+			assert.Equal(tb, "b", info.Name())
+		}
+	})
+
+	o.tbRun(tb, "read dir", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		subFS, err := hackpadfs.Sub(fs, "walk")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		entries, err := hackpadfs.ReadDir(subFS, ".")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(tb, []string{"a", "b", "d", "f"}, names)
+	})
+
+	o.tbRun(tb, "nested sub", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		subFS, err := hackpadfs.Sub(fs, "walk")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		subFS, err = hackpadfs.Sub(subFS, "b")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		data, err := hackpadfs.ReadFile(subFS, "c")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "walk/b/c", string(data))
+	})
+
+	o.tbRun(tb, "error paths are relative", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		subFS, err := hackpadfs.Sub(fs, "walk")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		_, err = subFS.Open("foo")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "foo",
+			Err:  hackpadfs.ErrNotExist,
+		}, err)
+		_, err = subFS.Open("../walk")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "../walk",
+			Err:  hackpadfs.ErrInvalid,
+		}, err)
+	})
+
+	o.tbRun(tb, "walk sub", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		setupWalkTree(tb, setupFS)
+
+		fs := commit()
+		subFS, err := hackpadfs.Sub(fs, "walk")
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		var paths []string
+		err = hackpadfs.WalkDir(subFS, ".", func(path string, _ hackpadfs.DirEntry, err error) error {
+			paths = append(paths, path)
+			return err
+		})
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.Equal(tb, []string{".", "a", "b", "b/c", "d", "d/e", "f"}, paths)
+	})
+}
+
+// TestCaseSensitivity verifies paths differing only by letter case refer to different files, or the same file if Constraints.CaseInsensitive is set.
+// In either case, names keep the case they were created with.
+func TestCaseSensitivity(tb testing.TB, o FSOptions) {
+	o.tbRun(tb, "open other case", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "A", []byte("hello"), 0666))
+
+		fs := commit()
+		data, err := hackpadfs.ReadFile(fs, "a")
+		if o.Constraints.CaseInsensitive {
+			assert.NoError(tb, err)
+			assert.Equal(tb, "hello", string(data))
+		} else {
+			o.assertEqualPathErr(tb, &hackpadfs.PathError{
+				Op:   "open",
+				Path: "a",
+				Err:  hackpadfs.ErrNotExist,
+			}, err)
+		}
+	})
+
+	o.tbRun(tb, "create other case", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+
+		fs := commit()
+		f, err := hackpadfs.OpenFile(fs, "FOO", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, 0666)
+		skipNotImplemented(tb, err)
+		if o.Constraints.CaseInsensitive {
+			o.assertEqualPathErr(tb, &hackpadfs.PathError{
+				Op:   "open",
+				Path: "FOO",
+				Err:  hackpadfs.ErrExist,
+			}, err)
+			return
+		}
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, f.Close())
+		}
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"foo": {Mode: 0666, Size: 5},
+			"FOO": {Mode: 0666},
+		}, fs)
+	})
+
+	o.tbRun(tb, "mkdir other case", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0755))
+
+		fs := commit()
+		err := hackpadfs.Mkdir(fs, "DIR", 0755)
+		skipNotImplemented(tb, err)
+		if o.Constraints.CaseInsensitive {
+			o.assertEqualPathErr(tb, &hackpadfs.PathError{
+				Op:   "mkdir",
+				Path: "DIR",
+				Err:  hackpadfs.ErrExist,
+			}, err)
+			return
+		}
+		assert.NoError(tb, err)
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"dir": {Mode: hackpadfs.ModeDir | 0755, IsDir: true},
+			"DIR": {Mode: hackpadfs.ModeDir | 0755, IsDir: true},
+		}, fs)
+	})
+
+	o.tbRun(tb, "preserve case", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("Dir", 0755))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "Dir/Foo", nil, 0666))
+
+		fs := commit()
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"Dir":     {Mode: hackpadfs.ModeDir | 0755, IsDir: true},
+			"Dir/Foo": {Mode: 0666},
+		}, fs)
+		info, err := hackpadfs.Stat(fs, "Dir/Foo")
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, "Foo", info.Name())
+		}
+	})
+}
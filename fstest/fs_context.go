@@ -0,0 +1,168 @@
+package fstest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// contextCancelWait is the longest an operation may continue after its context is canceled
+const contextCancelWait = 5 * time.Second
+
+// TestContext verifies operations on an FS from FSOptions.WithContext fail with context.Canceled once their context is canceled.
+// Operations canceled part way through must return promptly, and either complete or leave the tree as it was before the operation.
+// Written files may instead contain a prefix of the written data.
+func TestContext(tb testing.TB, o FSOptions) {
+	if o.WithContext == nil {
+		tb.Skip("FSOptions.WithContext is not set")
+	}
+
+	assertCanceled := func(tb testing.TB, err error) {
+		tb.Helper()
+		if !errors.Is(err, context.Canceled) {
+			tb.Errorf("Expected context.Canceled error, got: %v", err)
+		}
+	}
+
+	o.tbRun(tb, "canceled before operation", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0755))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+
+		fs := commit()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ctxFS := o.WithContext(fs, ctx)
+
+		_, err := hackpadfs.Stat(ctxFS, "foo")
+		assertCanceled(tb, err)
+		_, err = hackpadfs.ReadFile(ctxFS, "foo")
+		assertCanceled(tb, err)
+		_, err = hackpadfs.ReadDir(ctxFS, "dir")
+		assertCanceled(tb, err)
+		err = hackpadfs.Mkdir(ctxFS, "bar", 0755)
+		skipNotImplemented(tb, err)
+		assertCanceled(tb, err)
+		err = hackpadfs.WriteFullFile(ctxFS, "baz", []byte("baz"), 0666)
+		skipNotImplemented(tb, err)
+		assertCanceled(tb, err)
+
+		o.tryAssertEqualFS(tb, map[string]fsEntry{
+			"dir": {Mode: hackpadfs.ModeDir | 0755, IsDir: true},
+			"foo": {Mode: 0666, Size: 5},
+		}, fs)
+		_, err = hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		_, err = hackpadfs.Stat(fs, "baz")
+		assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+	})
+
+	o.tbRun(tb, "canceled between writes", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctxFS := o.WithContext(fs, ctx)
+
+		f, err := hackpadfs.Create(ctxFS, "foo")
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		_, err = hackpadfs.WriteFile(f, []byte("hello"))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		cancel()
+		_, err = hackpadfs.WriteFile(f, []byte(" world"))
+		assertCanceled(tb, err)
+		_ = f.Close() // may fail with context.Canceled
+
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Prefix(tb, string(data), "hello")
+	})
+
+	o.tbRun(tb, "cancel large write", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctxFS := o.WithContext(fs, ctx)
+
+		data := bytes.Repeat([]byte("hello world "), 1<<20)
+		err := runCanceled(tb, cancel, func() error {
+			return hackpadfs.WriteFullFile(ctxFS, "foo", data, 0666)
+		})
+		skipNotImplemented(tb, err)
+		if err != nil {
+			assertCanceled(tb, err)
+		}
+
+		contents, err := hackpadfs.ReadFile(fs, "foo")
+		if errors.Is(err, hackpadfs.ErrNotExist) {
+			return // canceled before the file was created
+		}
+		assert.NoError(tb, err)
+		if !bytes.HasPrefix(data, contents) {
+			tb.Errorf("File contents are not a prefix of the written data. Read %d bytes.", len(contents))
+		}
+	})
+
+	o.tbRun(tb, "cancel large read dir", func(tb testing.TB) {
+		const fileCount = 1000
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0755))
+		for i := 0; i < fileCount; i++ {
+			f, err := hackpadfs.Create(setupFS, fmt.Sprintf("dir/file-%d", i))
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			assert.NoError(tb, f.Close())
+		}
+
+		fs := commit()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ctxFS := o.WithContext(fs, ctx)
+
+		var entries []hackpadfs.DirEntry
+		err := runCanceled(tb, cancel, func() error {
+			var err error
+			entries, err = hackpadfs.ReadDir(ctxFS, "dir")
+			return err
+		})
+		if err != nil {
+			assertCanceled(tb, err)
+		} else {
+			assert.Equal(tb, fileCount, len(entries))
+		}
+
+		entries, err = hackpadfs.ReadDir(fs, "dir")
+		assert.NoError(tb, err)
+		assert.Equal(tb, fileCount, len(entries))
+	})
+}
+
+// runCanceled runs 'op' and cancels it part way through. Fails if 'op' does not return promptly after cancel() is called.
+func runCanceled(tb testing.TB, cancel context.CancelFunc, op func() error) error {
+	tb.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- op()
+	}()
+	time.Sleep(time.Millisecond) // let 'op' start
+	cancel()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(contextCancelWait):
+		tb.Fatalf("Operation did not return within %s of cancel", contextCancelWait)
+		return nil
+	}
+}
@@ -0,0 +1,159 @@
+package fstest
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// Lock acquires an advisory lock on 'name', similar to flock(2). Conflicting locks fail immediately with ErrWouldBlock.
+// Each Lock call is a separate owner, so conflicts apply between locks acquired from the same FS.
+// Skipped if the FS does not implement LockFS.
+func TestLock(tb testing.TB, o FSOptions) {
+	setupLockFile := func(tb testing.TB) hackpadfs.FS {
+		tb.Helper()
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+		return commit()
+	}
+	lock := func(tb testing.TB, fs hackpadfs.FS, name string, mode hackpadfs.LockMode) hackpadfs.Unlocker {
+		tb.Helper()
+		unlocker, err := hackpadfs.Lock(fs, name, mode)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		return unlocker
+	}
+
+	{
+		// skip the whole group cleanly if locks are not supported
+		fs := setupLockFile(tb)
+		unlocker, err := hackpadfs.Lock(fs, "foo", hackpadfs.LockExclusive)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, unlocker.Unlock())
+		}
+	}
+
+	o.tbRun(tb, "file does not exist", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		_, err := hackpadfs.Lock(fs, "foo", hackpadfs.LockExclusive)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "lock",
+			Path: "foo",
+			Err:  hackpadfs.ErrNotExist,
+		}, err)
+	})
+
+	o.tbRun(tb, "exclusive conflicts with exclusive", func(tb testing.TB) {
+		fs := setupLockFile(tb)
+		exclusive := lock(tb, fs, "foo", hackpadfs.LockExclusive)
+		_, err := hackpadfs.Lock(fs, "foo", hackpadfs.LockExclusive)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "lock",
+			Path: "foo",
+			Err:  hackpadfs.ErrWouldBlock,
+		}, err)
+		assert.NoError(tb, exclusive.Unlock())
+	})
+
+	o.tbRun(tb, "exclusive conflicts with shared", func(tb testing.TB) {
+		fs := setupLockFile(tb)
+		exclusive := lock(tb, fs, "foo", hackpadfs.LockExclusive)
+		_, err := hackpadfs.Lock(fs, "foo", hackpadfs.LockShared)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "lock",
+			Path: "foo",
+			Err:  hackpadfs.ErrWouldBlock,
+		}, err)
+		assert.NoError(tb, exclusive.Unlock())
+	})
+
+	o.tbRun(tb, "shared permits shared", func(tb testing.TB) {
+		fs := setupLockFile(tb)
+		shared1 := lock(tb, fs, "foo", hackpadfs.LockShared)
+		shared2 := lock(tb, fs, "foo", hackpadfs.LockShared)
+		_, err := hackpadfs.Lock(fs, "foo", hackpadfs.LockExclusive)
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "lock",
+			Path: "foo",
+			Err:  hackpadfs.ErrWouldBlock,
+		}, err)
+		assert.NoError(tb, shared1.Unlock())
+		_, err = hackpadfs.Lock(fs, "foo", hackpadfs.LockExclusive)
+		assert.ErrorIs(tb, hackpadfs.ErrWouldBlock, err) // still held by shared2
+		assert.NoError(tb, shared2.Unlock())
+	})
+
+	o.tbRun(tb, "unlock releases", func(tb testing.TB) {
+		fs := setupLockFile(tb)
+		exclusive := lock(tb, fs, "foo", hackpadfs.LockExclusive)
+		assert.NoError(tb, exclusive.Unlock())
+		exclusive = lock(tb, fs, "foo", hackpadfs.LockExclusive)
+		assert.NoError(tb, exclusive.Unlock())
+		shared := lock(tb, fs, "foo", hackpadfs.LockShared)
+		assert.NoError(tb, shared.Unlock())
+	})
+
+	o.tbRun(tb, "unlock twice", func(tb testing.TB) {
+		fs := setupLockFile(tb)
+		exclusive := lock(tb, fs, "foo", hackpadfs.LockExclusive)
+		assert.NoError(tb, exclusive.Unlock())
+		assert.ErrorIs(tb, hackpadfs.ErrClosed, exclusive.Unlock())
+	})
+
+	o.tbRun(tb, "different files", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", nil, 0666))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "bar", nil, 0666))
+		fs := commit()
+		foo := lock(tb, fs, "foo", hackpadfs.LockExclusive)
+		bar := lock(tb, fs, "bar", hackpadfs.LockExclusive)
+		assert.NoError(tb, foo.Unlock())
+		assert.NoError(tb, bar.Unlock())
+	})
+
+	o.tbRun(tb, "locks are advisory", func(tb testing.TB) {
+		fs := setupLockFile(tb)
+		exclusive := lock(tb, fs, "foo", hackpadfs.LockExclusive)
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate, 0)
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			_, err = hackpadfs.WriteFile(f, []byte("hi"))
+			skipNotImplemented(tb, err)
+			assert.NoError(tb, err)
+			assert.NoError(tb, f.Close())
+		}
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "hi", string(data))
+		assert.NoError(tb, exclusive.Unlock())
+	})
+
+	o.tbRun(tb, "concurrent exclusive", func(tb testing.TB) {
+		fs := setupLockFile(tb)
+		var mu sync.Mutex
+		var unlockers []hackpadfs.Unlocker
+		var acquired int64
+		concurrentTasks(0, func(int) {
+			unlocker, err := hackpadfs.Lock(fs, "foo", hackpadfs.LockExclusive)
+			if err != nil {
+				assert.ErrorIs(tb, hackpadfs.ErrWouldBlock, err)
+				return
+			}
+			atomic.AddInt64(&acquired, 1)
+			mu.Lock()
+			unlockers = append(unlockers, unlocker)
+			mu.Unlock()
+		})
+		assert.Equal(tb, int64(1), atomic.LoadInt64(&acquired))
+		for _, unlocker := range unlockers {
+			assert.NoError(tb, unlocker.Unlock())
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package fstest
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// TestPermissions verifies operations denied by a file or directory's permission bits fail with ErrPermission.
+// If Constraints.AllowUnenforcedPermissions is set, the operations may succeed instead.
+func TestPermissions(tb testing.TB, o FSOptions) {
+	// chmod changes the mode of 'name' and restores it during cleanup, so temporary directories can be removed
+	chmod := func(tb testing.TB, fs hackpadfs.FS, name string, mode hackpadfs.FileMode) {
+		tb.Helper()
+		err := hackpadfs.Chmod(fs, name, mode)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() {
+			_ = hackpadfs.Chmod(fs, name, 0755)
+		})
+	}
+	assertPermissionErr := func(tb testing.TB, expected *hackpadfs.PathError, err error) {
+		tb.Helper()
+		if o.Constraints.AllowUnenforcedPermissions && err == nil {
+			return
+		}
+		o.assertEqualPathErr(tb, expected, err)
+	}
+	closeFile := func(tb testing.TB, f hackpadfs.File, err error) {
+		tb.Helper()
+		if err == nil {
+			assert.NoError(tb, f.Close())
+		}
+	}
+
+	o.tbRun(tb, "open unreadable file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+		fs := commit()
+		chmod(tb, fs, "foo", 0)
+
+		f, err := fs.Open("foo")
+		assertPermissionErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "foo",
+			Err:  hackpadfs.ErrPermission,
+		}, err)
+		closeFile(tb, f, err)
+	})
+
+	o.tbRun(tb, "write read-only file", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+		fs := commit()
+		chmod(tb, fs, "foo", 0444)
+
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly, 0)
+		skipNotImplemented(tb, err)
+		assertPermissionErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "foo",
+			Err:  hackpadfs.ErrPermission,
+		}, err)
+		closeFile(tb, f, err)
+		if !o.Constraints.AllowUnenforcedPermissions {
+			data, err := hackpadfs.ReadFile(fs, "foo")
+			assert.NoError(tb, err)
+			assert.Equal(tb, "hello", string(data))
+		}
+	})
+
+	o.tbRun(tb, "read dir of unreadable dir", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0755))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "dir/foo", nil, 0666))
+		fs := commit()
+		chmod(tb, fs, "dir", 0)
+
+		_, err := hackpadfs.ReadDir(fs, "dir")
+		skipNotImplemented(tb, err)
+		assertPermissionErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "dir",
+			Err:  hackpadfs.ErrPermission,
+		}, err)
+	})
+
+	o.tbRun(tb, "open file in unsearchable dir", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0755))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "dir/foo", nil, 0666))
+		fs := commit()
+		chmod(tb, fs, "dir", 0)
+
+		f, err := fs.Open("dir/foo")
+		assertPermissionErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "dir/foo",
+			Err:  hackpadfs.ErrPermission,
+		}, err)
+		closeFile(tb, f, err)
+	})
+
+	o.tbRun(tb, "create in read-only dir", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0755))
+		fs := commit()
+		chmod(tb, fs, "dir", 0555)
+
+		f, err := hackpadfs.Create(fs, "dir/foo")
+		skipNotImplemented(tb, err)
+		assertPermissionErr(tb, &hackpadfs.PathError{
+			Op:   "open",
+			Path: "dir/foo",
+			Err:  hackpadfs.ErrPermission,
+		}, err)
+		closeFile(tb, f, err)
+		if !o.Constraints.AllowUnenforcedPermissions {
+			_, err := hackpadfs.Stat(fs, "dir/foo")
+			assert.ErrorIs(tb, hackpadfs.ErrNotExist, err)
+		}
+	})
+}
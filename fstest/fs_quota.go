@@ -0,0 +1,115 @@
+package fstest
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// QuotaOptions describes an FS's size limit, to verify writes beyond it fail cleanly
+type QuotaOptions struct {
+	// Size is the most bytes of file contents the FS can hold. Quota tests only run if this is set.
+	Size int64
+	// Err is the class of error returned by writes beyond Size. Defaults to hackpadfs.ErrNoSpace.
+	// For example, set to hackpadfs.ErrTooLarge for a per-file limit like mount.MountOptions.MaxFileSize.
+	Err error
+}
+
+// TestQuota verifies writes beyond FSOptions.Quota's size fail with Quota.Err and don't corrupt existing files.
+// Failed writes may keep a prefix of the written data.
+func TestQuota(tb testing.TB, o FSOptions) {
+	if o.Quota.Size <= 0 {
+		tb.Skip("FSOptions.Quota.Size is not set")
+	}
+	tooLarge := bytes.Repeat([]byte("a"), int(o.Quota.Size)+1)
+
+	assertQuotaErr := func(tb testing.TB, err error) {
+		tb.Helper()
+		if !errors.Is(err, o.Quota.Err) {
+			tb.Errorf("Expected error %v, got: %v", o.Quota.Err, err)
+		}
+	}
+	assertPrefix := func(tb testing.TB, fs hackpadfs.FS, name string, data []byte) {
+		tb.Helper()
+		contents, err := hackpadfs.ReadFile(fs, name)
+		if errors.Is(err, hackpadfs.ErrNotExist) {
+			return
+		}
+		assert.NoError(tb, err)
+		if !bytes.HasPrefix(data, contents) {
+			tb.Errorf("File %q contents are not a prefix of the written data. Read %d bytes.", name, len(contents))
+		}
+		if int64(len(contents)) > o.Quota.Size {
+			tb.Errorf("File %q is larger than the quota: %d > %d", name, len(contents), o.Quota.Size)
+		}
+	}
+
+	o.tbRun(tb, "write beyond quota", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.WriteFullFile(fs, "foo", tooLarge, 0666)
+		skipNotImplemented(tb, err)
+		assertQuotaErr(tb, err)
+		assertPrefix(tb, fs, "foo", tooLarge)
+	})
+
+	o.tbRun(tb, "existing files unchanged", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+
+		fs := commit()
+		err := hackpadfs.WriteFullFile(fs, "bar", tooLarge, 0666)
+		skipNotImplemented(tb, err)
+		assertQuotaErr(tb, err)
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "hello", string(data))
+		assertPrefix(tb, fs, "bar", tooLarge)
+	})
+
+	o.tbRun(tb, "truncate beyond quota", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+
+		fs := commit()
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			return
+		}
+		err = hackpadfs.TruncateFile(f, o.Quota.Size+1)
+		skipNotImplemented(tb, err)
+		assertQuotaErr(tb, err)
+		assert.NoError(tb, f.Close())
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, "hello", string(data))
+	})
+
+	o.tbRun(tb, "write up to quota", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.WriteFullFile(fs, "foo", tooLarge[1:], 0666)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		data, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, true, bytes.Equal(tooLarge[1:], data))
+	})
+
+	o.tbRun(tb, "remove frees space", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		err := hackpadfs.WriteFullFile(fs, "foo", tooLarge[1:], 0666)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		err = hackpadfs.Remove(fs, "foo")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		err = hackpadfs.WriteFullFile(fs, "bar", tooLarge[1:], 0666)
+		assert.NoError(tb, err)
+	})
+}
@@ -0,0 +1,162 @@
+package fstest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// watchEventWait is the longest a change may take to reach a watcher
+const watchEventWait = 5 * time.Second
+
+// TestWatch verifies changes to a watched directory are delivered to its Watcher with the changed file's path.
+// Changes may be coalesced or reported more than once, so only the presence of each expected event is checked.
+// Skipped if the FS does not implement WatchFS.
+func TestWatch(tb testing.TB, o FSOptions) {
+	setupWatchDir := func(tb testing.TB) hackpadfs.FS {
+		tb.Helper()
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("dir", 0755))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "dir/foo", []byte("hello"), 0666))
+		return commit()
+	}
+	watch := func(tb testing.TB, fs hackpadfs.FS, name string) hackpadfs.Watcher {
+		tb.Helper()
+		watcher, err := hackpadfs.Watch(fs, name)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		tb.Cleanup(func() {
+			assert.NoError(tb, watcher.Close())
+		})
+		return watcher
+	}
+
+	{
+		// skip the whole group cleanly if watches are not supported
+		_, commit := o.Setup.FS(tb)
+		watcher, err := hackpadfs.Watch(commit(), ".")
+		skipNotImplemented(tb, err)
+		if assert.NoError(tb, err) {
+			assert.NoError(tb, watcher.Close())
+		}
+	}
+
+	o.tbRun(tb, "create", func(tb testing.TB) {
+		fs := setupWatchDir(tb)
+		watcher := watch(tb, fs, "dir")
+		assert.NoError(tb, hackpadfs.WriteFullFile(fs, "dir/bar", []byte("bar"), 0666))
+		receiveWatchEvents(tb, watcher, hackpadfs.WatchEvent{Name: "dir/bar", Op: hackpadfs.WatchWrite})
+	})
+
+	o.tbRun(tb, "write", func(tb testing.TB) {
+		fs := setupWatchDir(tb)
+		watcher := watch(tb, fs, "dir")
+		f, err := hackpadfs.OpenFile(fs, "dir/foo", hackpadfs.FlagWriteOnly, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		_, err = hackpadfs.WriteFile(f, []byte("world"))
+		assert.NoError(tb, err)
+		assert.NoError(tb, f.Close())
+		receiveWatchEvents(tb, watcher, hackpadfs.WatchEvent{Name: "dir/foo", Op: hackpadfs.WatchWrite})
+	})
+
+	o.tbRun(tb, "remove", func(tb testing.TB) {
+		fs := setupWatchDir(tb)
+		watcher := watch(tb, fs, "dir")
+		err := hackpadfs.Remove(fs, "dir/foo")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		receiveWatchEvents(tb, watcher, hackpadfs.WatchEvent{Name: "dir/foo", Op: hackpadfs.WatchRemove})
+	})
+
+	o.tbRun(tb, "rename", func(tb testing.TB) {
+		fs := setupWatchDir(tb)
+		watcher := watch(tb, fs, "dir")
+		err := hackpadfs.Rename(fs, "dir/foo", "dir/bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		receiveWatchEvents(tb, watcher,
+			hackpadfs.WatchEvent{Name: "dir/foo", Op: hackpadfs.WatchRemove},
+			hackpadfs.WatchEvent{Name: "dir/bar", Op: hackpadfs.WatchWrite},
+		)
+	})
+
+	o.tbRun(tb, "ignores other paths", func(tb testing.TB) {
+		fs := setupWatchDir(tb)
+		watcher := watch(tb, fs, "dir")
+		assert.NoError(tb, hackpadfs.WriteFullFile(fs, "other", []byte("other"), 0666))
+		assert.NoError(tb, hackpadfs.WriteFullFile(fs, "dir/bar", []byte("bar"), 0666))
+		// changes are delivered in order, so a change to 'other' would arrive before 'dir/bar'
+		events := receiveWatchEvents(tb, watcher, hackpadfs.WatchEvent{Name: "dir/bar", Op: hackpadfs.WatchWrite})
+		for _, event := range events {
+			if event.Name == "other" {
+				tb.Errorf("Received event for unwatched path: %v", event)
+			}
+		}
+	})
+
+	o.tbRun(tb, "burst of changes", func(tb testing.TB) {
+		fs := setupWatchDir(tb)
+		watcher := watch(tb, fs, "dir")
+		const fileCount = 50
+		var expected []hackpadfs.WatchEvent
+		for i := 0; i < fileCount; i++ {
+			name := fmt.Sprintf("dir/file-%d", i)
+			assert.NoError(tb, hackpadfs.WriteFullFile(fs, name, []byte("hello"), 0666))
+			expected = append(expected, hackpadfs.WatchEvent{Name: name, Op: hackpadfs.WatchWrite})
+		}
+		receiveWatchEvents(tb, watcher, expected...)
+	})
+
+	o.tbRun(tb, "close", func(tb testing.TB) {
+		fs := setupWatchDir(tb)
+		watcher, err := hackpadfs.Watch(fs, "dir")
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		assert.NoError(tb, watcher.Close())
+		timeout := time.After(watchEventWait)
+		for {
+			select {
+			case _, ok := <-watcher.Events():
+				if !ok {
+					return
+				}
+			case <-timeout:
+				tb.Fatal("Timed out waiting for Events() channel to close")
+			}
+		}
+	})
+}
+
+// receiveWatchEvents receives from 'watcher' until every event in 'expected' arrives, then returns all received events
+func receiveWatchEvents(tb testing.TB, watcher hackpadfs.Watcher, expected ...hackpadfs.WatchEvent) []hackpadfs.WatchEvent {
+	tb.Helper()
+	remaining := make(map[hackpadfs.WatchEvent]bool, len(expected))
+	for _, event := range expected {
+		remaining[event] = true
+	}
+	var events []hackpadfs.WatchEvent
+	timeout := time.After(watchEventWait)
+	for len(remaining) > 0 {
+		select {
+		case event, ok := <-watcher.Events():
+			if !ok {
+				tb.Fatalf("Events() channel closed before receiving %v. Received: %v", remaining, events)
+			}
+			events = append(events, event)
+			delete(remaining, event)
+		case <-timeout:
+			tb.Fatalf("Timed out waiting for %d events. Received: %v", len(remaining), events)
+		}
+	}
+	return events
+}
@@ -0,0 +1,238 @@
+package fstest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// TestXattr verifies extended attributes can be set, read, listed, and removed, and that they stay with their file through writes and renames.
+// Skipped if the FS does not implement XattrFS.
+func TestXattr(tb testing.TB, o FSOptions) {
+	setupXattrFile := func(tb testing.TB) hackpadfs.FS {
+		tb.Helper()
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", []byte("hello"), 0666))
+		return commit()
+	}
+	setXattr := func(tb testing.TB, fs hackpadfs.FS, name, attr, value string) {
+		tb.Helper()
+		err := hackpadfs.SetXattr(fs, name, attr, []byte(value))
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+	}
+	assertXattr := func(tb testing.TB, fs hackpadfs.FS, name, attr, expectValue string) {
+		tb.Helper()
+		value, err := hackpadfs.GetXattr(fs, name, attr)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, expectValue, string(value))
+		}
+	}
+	assertXattrNames := func(tb testing.TB, fs hackpadfs.FS, name string, expectNames []string) {
+		tb.Helper()
+		names, err := hackpadfs.ListXattr(fs, name)
+		if assert.NoError(tb, err) {
+			assert.Equal(tb, len(expectNames), len(names))
+			if len(names) > 0 {
+				assert.Equal(tb, expectNames, names)
+			}
+		}
+	}
+
+	{
+		// skip the whole group cleanly if extended attributes are not supported
+		fs := setupXattrFile(tb)
+		_, err := hackpadfs.ListXattr(fs, "foo")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+	}
+
+	o.tbRun(tb, "set and get", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		setXattr(tb, fs, "foo", "user.foo", "bar")
+		assertXattr(tb, fs, "foo", "user.foo", "bar")
+		setXattr(tb, fs, "foo", "user.foo", "baz")
+		assertXattr(tb, fs, "foo", "user.foo", "baz")
+	})
+
+	o.tbRun(tb, "empty value", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		setXattr(tb, fs, "foo", "user.foo", "")
+		assertXattr(tb, fs, "foo", "user.foo", "")
+		assertXattrNames(tb, fs, "foo", []string{"user.foo"})
+	})
+
+	o.tbRun(tb, "values are copies", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		value := []byte("bar")
+		err := hackpadfs.SetXattr(fs, "foo", "user.foo", value)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		value[0] = 'c'
+		got, err := hackpadfs.GetXattr(fs, "foo", "user.foo")
+		if assert.NoError(tb, err) {
+			got[0] = 'f'
+		}
+		assertXattr(tb, fs, "foo", "user.foo", "bar")
+	})
+
+	o.tbRun(tb, "get unset attribute", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		_, err := hackpadfs.GetXattr(fs, "foo", "user.foo")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "getxattr",
+			Path: "foo",
+			Err:  hackpadfs.ErrNoAttr,
+		}, err)
+	})
+
+	o.tbRun(tb, "list", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		assertXattrNames(tb, fs, "foo", nil)
+		setXattr(tb, fs, "foo", "user.b", "b")
+		setXattr(tb, fs, "foo", "user.a", "a")
+		assertXattrNames(tb, fs, "foo", []string{"user.a", "user.b"})
+	})
+
+	o.tbRun(tb, "remove", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		setXattr(tb, fs, "foo", "user.foo", "bar")
+		setXattr(tb, fs, "foo", "user.baz", "biff")
+		assert.NoError(tb, hackpadfs.RemoveXattr(fs, "foo", "user.foo"))
+		_, err := hackpadfs.GetXattr(fs, "foo", "user.foo")
+		assert.ErrorIs(tb, hackpadfs.ErrNoAttr, err)
+		assertXattrNames(tb, fs, "foo", []string{"user.baz"})
+
+		err = hackpadfs.RemoveXattr(fs, "foo", "user.foo")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "removexattr",
+			Path: "foo",
+			Err:  hackpadfs.ErrNoAttr,
+		}, err)
+	})
+
+	o.tbRun(tb, "size limit", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		maxValue := bytes.Repeat([]byte("a"), hackpadfs.XattrSizeMax)
+		err := hackpadfs.SetXattr(fs, "foo", "user.foo", maxValue)
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		value, err := hackpadfs.GetXattr(fs, "foo", "user.foo")
+		assert.NoError(tb, err)
+		assert.Equal(tb, hackpadfs.XattrSizeMax, len(value))
+
+		err = hackpadfs.SetXattr(fs, "foo", "user.bar", append(maxValue, 'a'))
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{
+			Op:   "setxattr",
+			Path: "foo",
+			Err:  hackpadfs.ErrTooLarge,
+		}, err)
+		assertXattrNames(tb, fs, "foo", []string{"user.foo"})
+	})
+
+	o.tbRun(tb, "file does not exist", func(tb testing.TB) {
+		_, commit := o.Setup.FS(tb)
+		fs := commit()
+		_, err := hackpadfs.GetXattr(fs, "foo", "user.foo")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{Op: "getxattr", Path: "foo", Err: hackpadfs.ErrNotExist}, err)
+		err = hackpadfs.SetXattr(fs, "foo", "user.foo", []byte("bar"))
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{Op: "setxattr", Path: "foo", Err: hackpadfs.ErrNotExist}, err)
+		_, err = hackpadfs.ListXattr(fs, "foo")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{Op: "listxattr", Path: "foo", Err: hackpadfs.ErrNotExist}, err)
+		err = hackpadfs.RemoveXattr(fs, "foo", "user.foo")
+		o.assertEqualPathErr(tb, &hackpadfs.PathError{Op: "removexattr", Path: "foo", Err: hackpadfs.ErrNotExist}, err)
+	})
+
+	o.tbRun(tb, "directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		fs := commit()
+		setXattr(tb, fs, "foo", "user.foo", "bar")
+		assertXattr(tb, fs, "foo", "user.foo", "bar")
+	})
+
+	o.tbRun(tb, "files are independent", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", nil, 0666))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "bar", nil, 0666))
+		fs := commit()
+		setXattr(tb, fs, "foo", "user.foo", "foo")
+		assertXattrNames(tb, fs, "bar", nil)
+	})
+
+	o.tbRun(tb, "write keeps attributes", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		setXattr(tb, fs, "foo", "user.foo", "bar")
+		f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate, 0)
+		skipNotImplemented(tb, err)
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		_, err = hackpadfs.WriteFile(f, []byte("world"))
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, f.Close())
+		assertXattr(tb, fs, "foo", "user.foo", "bar")
+	})
+
+	o.tbRun(tb, "remove drops attributes", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		setXattr(tb, fs, "foo", "user.foo", "bar")
+		err := hackpadfs.Remove(fs, "foo")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assert.NoError(tb, hackpadfs.WriteFullFile(fs, "foo", nil, 0666))
+		assertXattrNames(tb, fs, "foo", nil)
+	})
+
+	o.tbRun(tb, "rename file", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		setXattr(tb, fs, "foo", "user.foo", "bar")
+		err := hackpadfs.Rename(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertXattr(tb, fs, "bar", "user.foo", "bar")
+		assertXattrNames(tb, fs, "bar", []string{"user.foo"})
+	})
+
+	o.tbRun(tb, "rename replaces destination attributes", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo", nil, 0666))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "bar", nil, 0666))
+		fs := commit()
+		setXattr(tb, fs, "foo", "user.foo", "foo")
+		setXattr(tb, fs, "bar", "user.bar", "bar")
+		err := hackpadfs.Rename(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertXattrNames(tb, fs, "bar", []string{"user.foo"})
+	})
+
+	o.tbRun(tb, "rename directory", func(tb testing.TB) {
+		setupFS, commit := o.Setup.FS(tb)
+		assert.NoError(tb, setupFS.Mkdir("foo", 0700))
+		assert.NoError(tb, hackpadfs.WriteFullFile(setupFS, "foo/bar", nil, 0666))
+		fs := commit()
+		setXattr(tb, fs, "foo", "user.foo", "foo")
+		setXattr(tb, fs, "foo/bar", "user.bar", "bar")
+		err := hackpadfs.Rename(fs, "foo", "baz")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		assertXattr(tb, fs, "baz", "user.foo", "foo")
+		assertXattr(tb, fs, "baz/bar", "user.bar", "bar")
+	})
+
+	o.tbRun(tb, "symlinks are followed", func(tb testing.TB) {
+		fs := setupXattrFile(tb)
+		err := hackpadfs.Symlink(fs, "foo", "bar")
+		skipNotImplemented(tb, err)
+		assert.NoError(tb, err)
+		setXattr(tb, fs, "bar", "user.foo", "bar")
+		assertXattr(tb, fs, "foo", "user.foo", "bar")
+		assertXattr(tb, fs, "bar", "user.foo", "bar")
+	})
+}
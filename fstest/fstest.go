@@ -2,9 +2,12 @@
 package fstest
 
 import (
+	"context"
 	"errors"
+	"sort"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/hack-pad/hackpadfs"
 )
@@ -31,6 +34,47 @@ type FSOptions struct {
 	// NOTE: This MUST NOT be used lightly. Any custom constraints severely impairs the quality of a standardized file system.
 	Constraints Constraints
 
+	// Owner returns the user and group IDs stored in a file's FileInfo. Optional.
+	// Set for FSs which store file ownership, usually in FileInfo.Sys(), to verify Chown() changes are visible in Stat() results.
+	Owner func(info hackpadfs.FileInfo) (uid, gid int)
+
+	// WithContext returns a copy of 'fs' whose operations run with 'ctx'. Optional.
+	// Set for FSs which support cancellation, like keyvalue.FS.WithContext(), to verify operations stop once 'ctx' is canceled.
+	WithContext func(fs hackpadfs.FS, ctx context.Context) hackpadfs.FS
+
+	// Reopen returns a new FS for the same underlying storage as 'fs', like another process opening the same directory or database. Optional.
+	// Set for persistent FSs to verify data synced with File.Sync() is visible after reopening.
+	Reopen func(tb testing.TB, fs hackpadfs.FS) hackpadfs.FS
+
+	// Quota enables tests for FSs with a size limit. Optional.
+	Quota QuotaOptions
+
+	// FSSubtests are custom tests run by FS() alongside the standard tests, like checks for backend-specific behavior. Optional.
+	// They run in parallel with the standard tests, and their results are included in TestData.
+	FSSubtests []Subtest
+	// FileSubtests are custom tests run by File() alongside the standard tests. Optional.
+	FileSubtests []Subtest
+
+	// Timeout fails each standard test group and subtest, like fs.Chmod's tests, if it runs longer than this duration. Optional.
+	// Useful for slow backends, like network FSs, which may otherwise hang indefinitely. The failure includes the stack of the stalled operation.
+	Timeout time.Duration
+
+	// Parallelism limits the number of standard test groups, like fs.Chmod's tests, running at once. Optional.
+	// Set for FSs which fail under heavy concurrent use, like those with a single connection or a rate-limited network store.
+	// When set, subtests within each group run sequentially. Set to 1 to run every test sequentially. Defaults to no limit beyond 'go test -parallel'.
+	Parallelism int
+
+	// Export writes the results of each test run to a JSON file. Optional.
+	Export ExportOptions
+
+	// Seed is the random seed for randomized tests and file contents, like fs.Properties's operations. Defaults to 1.
+	// The seed is logged when tests fail, so set it to the logged value to reproduce a failure exactly.
+	Seed int64
+
+	// LargeFiles enables tests on files larger than 4 GiB, to verify offsets beyond 32 bits. Optional.
+	// These tests are slow or memory-intensive for FSs without sparse file support, so are skipped by default.
+	LargeFiles bool
+
 	// ShouldSkip determines if the current test with features defined by 'facets' should be skipped.
 	// ShouldSkip() is intended for handling undefined behavior in existing systems outside one's control.
 	//
@@ -38,6 +82,18 @@ type FSOptions struct {
 	ShouldSkip func(facets Facets) bool
 
 	skippedTests *sync.Map // type: Facets -> struct{}
+	coverage     *coverage
+	slots        chan struct{} // limits running test groups to Parallelism, if set
+	iface        string        // name of the hackpadfs interface under test, if any
+}
+
+// Subtest is a custom test run alongside fstest's standard tests.
+type Subtest struct {
+	// Name of the subtest. Required.
+	Name string
+	// Test runs the subtest. Required.
+	// Prepare the FS under test with options.Setup and run nested tests with options.Run() to use the same machinery as the standard tests.
+	Test func(tb testing.TB, options FSOptions)
 }
 
 // SetupFS is an FS that supports the baseline interfaces for creating files/directories and changing their metadata.
@@ -68,20 +124,48 @@ func (fn TestSetupFunc) FS(tb testing.TB) (SetupFS, func() hackpadfs.FS) {
 // Constraints limits tests to a reduced set of assertions due to non-standard behavior. Avoid setting any of these.
 type Constraints struct {
 	// FileModeMask disables mode checks on the specified bits. Defaults to checking all bits (0).
-	FileModeMask hackpadfs.FileMode
+	FileModeMask hackpadfs.FileMode `json:"fileModeMask"`
 	// AllowErrPathPrefix enables more flexible FS path checks on error values by allowing an undefined path prefix.
-	AllowErrPathPrefix bool
+	AllowErrPathPrefix bool `json:"allowErrPathPrefix"`
+	// MatchErr replaces the strict checks on error values with a custom comparison. Returns true if 'actual' is acceptable for 'expected'.
+	// For example, MatchErrorIs accepts annotated or wrapped errors, which suits network FSs.
+	MatchErr func(expected, actual error) bool `json:"-"`
+	// AllowUnenforcedPermissions permits operations to succeed on files and directories whose permission bits deny them.
+	// Useful for FSs which store permission bits without enforcing them, like mem.FS, or processes running as a privileged user.
+	AllowUnenforcedPermissions bool `json:"allowUnenforcedPermissions"`
+	// CaseInsensitive declares the FS treats paths differing only by letter case as the same file, like macOS and Windows file systems.
+	// Case-sensitive FSs are expected by default.
+	CaseInsensitive bool `json:"caseInsensitive"`
+}
+
+func (c Constraints) isZero() bool {
+	return c.FileModeMask == 0 && !c.AllowErrPathPrefix && c.MatchErr == nil && !c.AllowUnenforcedPermissions && !c.CaseInsensitive
+}
+
+// MatchErrorIs is a Constraints.MatchErr func which accepts any error matching the expected error's class with errors.Is().
+// For example, an expected *hackpadfs.PathError with an Err of hackpadfs.ErrNotExist matches fmt.Errorf("request failed: %w", hackpadfs.ErrNotExist).
+func MatchErrorIs(expected, actual error) bool {
+	var pathErr *hackpadfs.PathError
+	var linkErr *hackpadfs.LinkError
+	switch {
+	case errors.As(expected, &pathErr):
+		expected = pathErr.Err
+	case errors.As(expected, &linkErr):
+		expected = linkErr.Err
+	}
+	return errors.Is(actual, expected)
 }
 
 // Facets contains details for the current test.
 // Used in FSOptions.ShouldSkip() to inspect and skip tests that should not apply to this FS.
 type Facets struct {
 	// Name is the full name of the current test
-	Name string
+	Name string `json:"name"`
 }
 
 func setupOptions(options *FSOptions) error {
 	options.skippedTests = new(sync.Map)
+	options.coverage = newCoverage()
 	if options.Name == "" {
 		return errors.New("FS test name is required")
 	}
@@ -95,6 +179,25 @@ func setupOptions(options *FSOptions) error {
 			return fs, func() hackpadfs.FS { return fs }
 		})
 	}
+	for _, subtests := range [][]Subtest{options.FSSubtests, options.FileSubtests} {
+		for _, subtest := range subtests {
+			if subtest.Name == "" || subtest.Test == nil {
+				return errors.New("subtest name and Test func are required")
+			}
+		}
+	}
+	if options.Parallelism < 0 {
+		return errors.New("Parallelism must not be negative")
+	}
+	if options.Parallelism > 1 {
+		options.slots = make(chan struct{}, options.Parallelism)
+	}
+	if options.Seed == 0 {
+		options.Seed = 1
+	}
+	if options.Quota.Err == nil {
+		options.Quota.Err = hackpadfs.ErrNoSpace
+	}
 	if options.ShouldSkip == nil {
 		options.ShouldSkip = func(_ Facets) bool {
 			return false
@@ -103,6 +206,13 @@ func setupOptions(options *FSOptions) error {
 	return nil
 }
 
+// Run runs 'subtest' as a nested test named 'name', applying ShouldSkip and recording the result in TestData.
+// Intended for use in custom Subtests.
+func (o FSOptions) Run(tb testing.TB, name string, subtest func(tb testing.TB)) {
+	tb.Helper()
+	o.tbRun(tb, name, subtest)
+}
+
 func (o FSOptions) tbRun(tb testing.TB, name string, subtest func(tb testing.TB)) {
 	tb.Helper()
 	switch tb := tb.(type) {
@@ -127,13 +237,22 @@ func (o FSOptions) tbRunInner(tb testing.TB, _ string, subtest func(tb testing.T
 		Name: tb.Name(),
 	}
 
+	shouldSkip := o.ShouldSkip(facets)
 	defer func() {
+		_, notImplemented := notImplementedTests.LoadAndDelete(facets.Name)
 		if tb.Skipped() {
 			o.skippedTests.Store(facets, struct{}{})
 		}
+		o.coverage.record(facets.Name, testResult{
+			iface:          o.iface,
+			failed:         tb.Failed(),
+			skipped:        tb.Skipped(),
+			notImplemented: notImplemented,
+			shouldSkip:     shouldSkip,
+		})
 	}()
 
-	if o.ShouldSkip(facets) {
+	if shouldSkip {
 		tb.Skipf("FSOption.ShouldSkip: %#v", facets)
 	}
 	subtest(tb)
@@ -141,21 +260,44 @@ func (o FSOptions) tbRunInner(tb testing.TB, _ string, subtest func(tb testing.T
 
 // TestData reports metadata from test runs.
 type TestData struct {
-	// Skips includes details for every skipped test.
+	// Skips includes details for every skipped test, sorted by name.
 	// Useful for verifying compliance with fstest's standard checks. For instance, os.FS checks (almost) none are skipped.
-	Skips []Facets
+	Skips []Facets `json:"skips"`
+	// Interfaces reports how tests covered each hackpadfs interface, sorted by name.
+	// Useful for publishing a capability matrix for an FS and catching accidental regressions in the interfaces it implements.
+	Interfaces []InterfaceCoverage `json:"interfaces"`
+	// Constraints are the FSOptions.Constraints the tests ran with
+	Constraints Constraints `json:"constraints"`
+	// Results contains the outcome of every test, sorted by name
+	Results []TestResult `json:"results"`
+	// Seed is the FSOptions.Seed the tests ran with
+	Seed int64 `json:"seed"`
 }
 
-func (o FSOptions) generateTestData() TestData {
-	var data TestData
+func (o FSOptions) generateTestData(tb testing.TB, runName string) TestData {
+	tb.Helper()
+	data := TestData{
+		Interfaces:  o.coverage.interfaces(!o.Constraints.isZero()),
+		Constraints: o.Constraints,
+		Results:     o.coverage.testResults(),
+		Seed:        o.Seed,
+	}
+	if tb.Failed() {
+		tb.Logf("%s ran with random seed %d. Set FSOptions.Seed to reproduce.", runName, o.Seed)
+	}
 	o.skippedTests.Range(func(key, _ interface{}) bool {
 		data.Skips = append(data.Skips, key.(Facets))
 		return true
 	})
+	sort.Slice(data.Skips, func(a, b int) bool {
+		return data.Skips[a].Name < data.Skips[b].Name
+	})
+	o.Export.export(tb, runName, data)
 	return data
 }
 
 // FS runs file system tests. All FS interfaces from hackpadfs.*FS are tested.
+// Returns after all tests complete, so the FS's tests run in parallel with each other but not with the caller.
 func FS(tb testing.TB, options FSOptions) TestData {
 	tb.Helper()
 
@@ -164,15 +306,17 @@ func FS(tb testing.TB, options FSOptions) TestData {
 		tb.Fatal(err)
 		return TestData{}
 	}
-	options.tbRun(tb, options.Name+"_FS", func(tb testing.TB) {
-		tbParallel(tb)
+	runName := options.Name + "_FS"
+	options.tbRun(tb, runName, func(tb testing.TB) {
 		tb.Helper()
+		options.detectFS(tb)
 		runFS(tb, options)
 	})
-	return options.generateTestData()
+	return options.generateTestData(tb, runName)
 }
 
 // File runs file tests. All File interfaces from hackpadfs.*File are tested.
+// Returns after all tests complete, so the FS's tests run in parallel with each other but not with the caller.
 func File(tb testing.TB, options FSOptions) TestData {
 	tb.Helper()
 
@@ -181,12 +325,13 @@ func File(tb testing.TB, options FSOptions) TestData {
 		tb.Fatal(err)
 		return TestData{}
 	}
-	options.tbRun(tb, options.Name+"_File", func(tb testing.TB) {
-		tbParallel(tb)
+	runName := options.Name + "_File"
+	options.tbRun(tb, runName, func(tb testing.TB) {
 		tb.Helper()
+		options.detectFile(tb)
 		runFile(tb, options)
 	})
-	return options.generateTestData()
+	return options.generateTestData(tb, runName)
 }
 
 func tbParallel(tb testing.TB) {
@@ -195,6 +340,29 @@ func tbParallel(tb testing.TB) {
 	}
 }
 
+// tbParallelGroup runs a test group in parallel, waiting for a free slot if Parallelism is set
+func (o FSOptions) tbParallelGroup(tb testing.TB) {
+	switch {
+	case o.Parallelism == 1:
+		return
+	case o.slots != nil:
+		tbParallel(tb)
+		o.slots <- struct{}{}
+		tb.Cleanup(func() {
+			<-o.slots
+		})
+	default:
+		tbParallel(tb)
+	}
+}
+
+// tbParallel runs a test within a group in parallel, unless Parallelism is set
+func (o FSOptions) tbParallel(tb testing.TB) {
+	if o.Parallelism == 0 {
+		tbParallel(tb)
+	}
+}
+
 type tbSubtaskRunner struct {
 	tb      testing.TB
 	options FSOptions
@@ -207,10 +375,14 @@ func newSubtaskRunner(tb testing.TB, options FSOptions) *tbSubtaskRunner {
 type subtaskFunc func(tb testing.TB, options FSOptions)
 
 func (r *tbSubtaskRunner) Run(name string, subtask subtaskFunc) {
-	r.options.tbRun(r.tb, name, func(tb testing.TB) {
-		tbParallel(tb)
+	options := r.options
+	options.iface = subtaskInterfaces[name]
+	options.tbRun(r.tb, name, func(tb testing.TB) {
+		options.tbParallelGroup(tb)
 		tb.Helper()
-		subtask(tb, r.options)
+		runWithTimeout(tb, options.Timeout, func() {
+			subtask(tb, options)
+		})
 	})
 }
 
@@ -222,6 +394,7 @@ func runFS(tb testing.TB, options FSOptions) {
 	runner.Run("base fs.Chtimes", TestBaseChtimes)
 
 	runner.Run("fs.Chmod", TestChmod)
+	runner.Run("fs.Chown", TestChown)
 	runner.Run("fs.Chtimes", TestChtimes)
 	runner.Run("fs.Create", TestCreate)
 	runner.Run("fs.Mkdir", TestMkdir)
@@ -235,13 +408,34 @@ func runFS(tb testing.TB, options FSOptions) {
 	runner.Run("fs.Rename", TestRename)
 	runner.Run("fs.Stat", TestStat)
 	runner.Run("fs.WriteFile", TestWriteFile)
-	// TODO Symlink
+	runner.Run("fs.Symlink", TestSymlink)
+	runner.Run("fs.Link", TestLink)
+	runner.Run("fs.Readlink", TestReadlink)
+	runner.Run("fs.Lstat", TestLstat)
+	runner.Run("fs.WalkDir", TestWalkDir)
+	runner.Run("fs.Sub", TestSub)
+	runner.Run("fs.CaseSensitivity", TestCaseSensitivity)
+	runner.Run("fs.Lock", TestLock)
+	runner.Run("fs.Permissions", TestPermissions)
+	runner.Run("fs.Watch", TestWatch)
+	if options.Quota.Size > 0 {
+		runner.Run("fs.Quota", TestQuota)
+	}
+	runner.Run("fs.Xattr", TestXattr)
+	if options.WithContext != nil {
+		runner.Run("fs.Context", TestContext)
+	}
+	runner.Run("fs.Properties", TestProperties)
 
 	runner.Run("fs_concurrent.Create", TestConcurrentCreate)
 	runner.Run("fs_concurrent.OpenFileCreate", TestConcurrentOpenFileCreate)
 	runner.Run("fs_concurrent.Mkdir", TestConcurrentMkdir)
 	runner.Run("fs_concurrent.MkdirAll", TestConcurrentMkdirAll)
 	runner.Run("fs_concurrent.Remove", TestConcurrentRemove)
+
+	for _, subtest := range options.FSSubtests {
+		runner.Run(subtest.Name, subtest.Test)
+	}
 }
 
 func runFile(tb testing.TB, options FSOptions) {
@@ -256,16 +450,24 @@ func runFile(tb testing.TB, options FSOptions) {
 	runner.Run("file.ReadDir", TestFileReadDir)
 	runner.Run("file.Stat", TestFileStat)
 	runner.Run("file.Sync", TestFileSync)
+	runner.Run("file.SyncDurability", TestFileSyncDurability)
 	runner.Run("file.Truncate", TestFileTruncate)
+	runner.Run("file.Large", TestFileLarge)
 
 	runner.Run("file_concurrent.Read", TestConcurrentFileRead)
 	runner.Run("file_concurrent.Write", TestConcurrentFileWrite)
 	runner.Run("file_concurrent.Stat", TestConcurrentFileStat)
+	runner.Run("file_concurrent.Truncate", TestConcurrentFileTruncate)
+
+	for _, subtest := range options.FileSubtests {
+		runner.Run(subtest.Name, subtest.Test)
+	}
 }
 
 func skipNotImplemented(tb testing.TB, err error) {
 	tb.Helper()
 	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		notImplementedTests.Store(tb.Name(), struct{}{})
 		tb.Skip(err)
 	}
 }
@@ -0,0 +1,47 @@
+package fstest
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestConstraintsIsZero(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		constraints Constraints
+		expectZero  bool
+	}{
+		{
+			description: "zero",
+			expectZero:  true,
+		},
+		{
+			description: "file mode mask",
+			constraints: Constraints{FileModeMask: 0700},
+		},
+		{
+			description: "allow err path prefix",
+			constraints: Constraints{AllowErrPathPrefix: true},
+		},
+		{
+			description: "match err",
+			constraints: Constraints{MatchErr: MatchErrorIs},
+		},
+		{
+			description: "allow unenforced permissions",
+			constraints: Constraints{AllowUnenforcedPermissions: true},
+		},
+		{
+			description: "case insensitive",
+			constraints: Constraints{CaseInsensitive: true},
+		},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expectZero, tc.constraints.isZero())
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package fstest
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// GoldenSkipsOptions locates a golden file of expected skipped tests for AssertGoldenSkips
+type GoldenSkipsOptions struct {
+	// FS contains the golden file, like an os.FS or an embed.FS. Required. Only written to if Update is set.
+	FS hackpadfs.FS
+	// Path is the golden file's path in FS. Required.
+	// The file contains a JSON list of skipped tests, in the same format as TestData.Skips.
+	Path string
+	// Update writes the current skips to the golden file instead of comparing them.
+	// Set temporarily to create the file, or to record skips removed by improvements to the FS.
+	Update bool
+}
+
+// AssertGoldenSkips fails if 'data' skipped any tests missing from the golden file in 'options', so FS maintainers can ratchet conformance forward without inspecting skips by hand.
+// Tests in the golden file which no longer skip are logged, as a prompt to update the golden file.
+func AssertGoldenSkips(tb testing.TB, data TestData, options GoldenSkipsOptions) {
+	tb.Helper()
+	if options.FS == nil || options.Path == "" {
+		tb.Fatal("GoldenSkipsOptions FS and Path are required")
+		return
+	}
+
+	if options.Update {
+		contents, err := json.MarshalIndent(data.Skips, "", "  ")
+		if err == nil {
+			err = hackpadfs.WriteFullFile(options.FS, options.Path, append(contents, '\n'), 0644)
+		}
+		if err != nil {
+			tb.Error("Failed to update golden skips:", err)
+		}
+		return
+	}
+
+	contents, err := hackpadfs.ReadFile(options.FS, options.Path)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		tb.Errorf("Golden skips file %q does not exist. Set GoldenSkipsOptions.Update to create it.", options.Path)
+		return
+	}
+	if err != nil {
+		tb.Error("Failed to read golden skips:", err)
+		return
+	}
+	var golden []Facets
+	if err := json.Unmarshal(contents, &golden); err != nil {
+		tb.Errorf("Failed to parse golden skips file %q: %v", options.Path, err)
+		return
+	}
+
+	added, removed := DiffSkips(golden, data.Skips)
+	for _, skip := range added {
+		tb.Errorf("New skipped test not in golden skips file %q: %s", options.Path, skip.Name)
+	}
+	if len(removed) > 0 {
+		names := make([]string, 0, len(removed))
+		for _, skip := range removed {
+			names = append(names, skip.Name)
+		}
+		tb.Logf("Tests in golden skips file %q no longer skip. Set GoldenSkipsOptions.Update to remove them: %v", options.Path, names)
+	}
+}
+
+// DiffSkips compares skipped tests with a golden list of skips.
+// Returns skips in 'actual' missing from 'golden', and skips in 'golden' missing from 'actual'.
+func DiffSkips(golden, actual []Facets) (added, removed []Facets) {
+	goldenNames := make(map[string]bool, len(golden))
+	for _, skip := range golden {
+		goldenNames[skip.Name] = true
+	}
+	actualNames := make(map[string]bool, len(actual))
+	for _, skip := range actual {
+		actualNames[skip.Name] = true
+		if !goldenNames[skip.Name] {
+			added = append(added, skip)
+		}
+	}
+	for _, skip := range golden {
+		if !actualNames[skip.Name] {
+			removed = append(removed, skip)
+		}
+	}
+	return added, removed
+}
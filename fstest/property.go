@@ -0,0 +1,335 @@
+package fstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+const (
+	propertySequences = 10 // seeds FSOptions.Seed through FSOptions.Seed + propertySequences - 1
+	propertyTreeSize  = 15
+	propertyOps       = 50
+	propertyRoot      = "prop"
+	propertyMissing   = "missing" // never created, used to generate paths with a missing parent
+)
+
+var propertyNames = []string{"a", "b", "c", "d", "e"}
+
+// TestProperties builds seeded, random directory trees, then applies random sequences of operations.
+// After every operation, checks the error class and that Stat, ReadFile, and ReadDir agree with the expected tree.
+//
+// Failures report the seed and operations applied, and each seed runs as its own subtest so it can be reproduced with 'go test -run'.
+func TestProperties(tb testing.TB, o FSOptions) {
+	for i := int64(0); i < propertySequences; i++ {
+		seed := o.Seed + i
+		o.tbRun(tb, fmt.Sprintf("seed=%d", seed), func(tb testing.TB) {
+			runProperties(tb, o, seed)
+		})
+	}
+}
+
+type propertyEntry struct {
+	dir  bool
+	data []byte
+}
+
+// propertyModel is the expected state of the tree under test
+type propertyModel struct {
+	tb      testing.TB
+	rnd     *rand.Rand
+	seed    int64
+	entries map[string]*propertyEntry
+	history []string
+}
+
+func runProperties(tb testing.TB, o FSOptions, seed int64) {
+	m := &propertyModel{
+		tb:      tb,
+		rnd:     rand.New(rand.NewSource(seed)),
+		seed:    seed,
+		entries: map[string]*propertyEntry{propertyRoot: {dir: true}},
+	}
+
+	setupFS, commit := o.Setup.FS(tb)
+	if !assert.NoError(tb, setupFS.Mkdir(propertyRoot, 0755)) {
+		tb.FailNow()
+	}
+	m.setupTree(setupFS)
+	fs := commit()
+	m.verifyTree(fs)
+
+	for i := 0; i < propertyOps; i++ {
+		m.step(fs)
+	}
+	m.verifyTree(fs)
+}
+
+func (m *propertyModel) setupTree(setupFS SetupFS) {
+	for i := 0; i < propertyTreeSize; i++ {
+		name := m.randomPath(false)
+		if _, exists := m.entries[name]; exists {
+			continue
+		}
+		m.history = append(m.history, fmt.Sprintf("setup %q", name))
+		if m.rnd.Intn(3) == 0 {
+			if !assert.NoError(m.tb, setupFS.Mkdir(name, 0755)) {
+				m.tb.FailNow()
+			}
+			m.entries[name] = &propertyEntry{dir: true}
+			continue
+		}
+		data := m.randomData()
+		f, err := setupFS.OpenFile(name, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0666)
+		if !assert.NoError(m.tb, err) {
+			m.tb.FailNow()
+		}
+		_, err = hackpadfs.WriteFile(f, data)
+		assert.NoError(m.tb, err)
+		assert.NoError(m.tb, f.Close())
+		m.entries[name] = &propertyEntry{data: data}
+	}
+}
+
+// randomPath returns a path inside an existing directory. If allowMissing is set, the parent directory may not exist.
+func (m *propertyModel) randomPath(allowMissing bool) string {
+	var dirs []string
+	for name, entry := range m.entries {
+		if entry.dir {
+			dirs = append(dirs, name)
+		}
+	}
+	sort.Strings(dirs) // map order is random, sort for reproducible results
+	dir := dirs[m.rnd.Intn(len(dirs))]
+	if allowMissing && m.rnd.Intn(10) == 0 {
+		dir = path.Join(dir, propertyMissing)
+	}
+	return path.Join(dir, propertyNames[m.rnd.Intn(len(propertyNames))])
+}
+
+// randomEntry returns an existing path, other than the root. Returns an empty string if there are none.
+func (m *propertyModel) randomEntry() string {
+	var names []string
+	for name := range m.entries {
+		if name != propertyRoot {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+	return names[m.rnd.Intn(len(names))]
+}
+
+func (m *propertyModel) randomData() []byte {
+	data := make([]byte, m.rnd.Intn(32))
+	for i := range data {
+		data[i] = byte('a' + m.rnd.Intn(26))
+	}
+	return data
+}
+
+func (m *propertyModel) children(dir string) []string {
+	var names []string
+	for name := range m.entries {
+		if name != propertyRoot && path.Dir(name) == dir {
+			names = append(names, path.Base(name))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *propertyModel) parentMissing(name string) bool {
+	_, exists := m.entries[path.Dir(name)]
+	return !exists
+}
+
+func (m *propertyModel) removeAll(name string) {
+	for entryName := range m.entries {
+		if entryName == name || strings.HasPrefix(entryName, name+"/") {
+			delete(m.entries, entryName)
+		}
+	}
+}
+
+func (m *propertyModel) rename(oldname, newname string) {
+	for entryName, entry := range m.entries {
+		if entryName == oldname || strings.HasPrefix(entryName, oldname+"/") {
+			delete(m.entries, entryName)
+			m.entries[newname+strings.TrimPrefix(entryName, oldname)] = entry
+		}
+	}
+}
+
+func (m *propertyModel) fail(format string, args ...interface{}) {
+	m.tb.Helper()
+	m.tb.Fatalf("Seed %d: %s\nOperations:\n\t%s", m.seed, fmt.Sprintf(format, args...), strings.Join(m.history, "\n\t"))
+}
+
+// checkErr verifies 'err' is in the class of 'expected', or is nil if 'expected' is nil
+func (m *propertyModel) checkErr(op string, expected, err error) {
+	m.tb.Helper()
+	if expected == nil && err == nil {
+		return
+	}
+	if expected != nil && errors.Is(err, expected) {
+		return
+	}
+	m.fail("%s: expected error %v, got: %v", op, expected, err)
+}
+
+// step applies a random operation to 'fs' and the model, then verifies the affected paths
+func (m *propertyModel) step(fs hackpadfs.FS) {
+	m.tb.Helper()
+	switch m.rnd.Intn(8) {
+	case 0:
+		name, data := m.randomPath(true), m.randomData()
+		op := fmt.Sprintf("write %q %q", name, data)
+		m.history = append(m.history, op)
+		err := hackpadfs.WriteFullFile(fs, name, data, 0666)
+		skipNotImplemented(m.tb, err)
+		entry, exists := m.entries[name]
+		switch {
+		case m.parentMissing(name):
+			m.checkErr(op, hackpadfs.ErrNotExist, err)
+		case exists && entry.dir:
+			m.checkErr(op, hackpadfs.ErrIsDir, err)
+		default:
+			m.checkErr(op, nil, err)
+			m.entries[name] = &propertyEntry{data: data}
+		}
+		m.verify(fs, name)
+	case 1:
+		name := m.randomPath(true)
+		op := fmt.Sprintf("mkdir %q", name)
+		m.history = append(m.history, op)
+		err := hackpadfs.Mkdir(fs, name, 0755)
+		skipNotImplemented(m.tb, err)
+		_, exists := m.entries[name]
+		switch {
+		case m.parentMissing(name):
+			m.checkErr(op, hackpadfs.ErrNotExist, err)
+		case exists:
+			m.checkErr(op, hackpadfs.ErrExist, err)
+		default:
+			m.checkErr(op, nil, err)
+			m.entries[name] = &propertyEntry{dir: true}
+		}
+		m.verify(fs, name)
+	case 2:
+		name := m.randomPath(true)
+		op := fmt.Sprintf("remove %q", name)
+		m.history = append(m.history, op)
+		err := hackpadfs.Remove(fs, name)
+		skipNotImplemented(m.tb, err)
+		entry, exists := m.entries[name]
+		switch {
+		case !exists:
+			m.checkErr(op, hackpadfs.ErrNotExist, err)
+		case entry.dir && len(m.children(name)) > 0:
+			m.checkErr(op, hackpadfs.ErrNotEmpty, err)
+		default:
+			m.checkErr(op, nil, err)
+			delete(m.entries, name)
+		}
+		m.verify(fs, name)
+	case 3:
+		name := m.randomPath(true)
+		op := fmt.Sprintf("removeall %q", name)
+		m.history = append(m.history, op)
+		err := hackpadfs.RemoveAll(fs, name)
+		skipNotImplemented(m.tb, err)
+		m.checkErr(op, nil, err)
+		m.removeAll(name)
+		m.verify(fs, name)
+	case 4:
+		oldname, newname := m.randomEntry(), m.randomPath(false)
+		oldEntry, newEntry := m.entries[oldname], m.entries[newname]
+		switch {
+		case oldname == "" || oldname == newname || strings.HasPrefix(newname, oldname+"/"):
+			return // renaming to the same path or into itself is system-dependent
+		case newEntry != nil && (oldEntry.dir || newEntry.dir):
+			return // replacing directories is system-dependent
+		}
+		op := fmt.Sprintf("rename %q %q", oldname, newname)
+		m.history = append(m.history, op)
+		err := hackpadfs.Rename(fs, oldname, newname)
+		skipNotImplemented(m.tb, err)
+		m.checkErr(op, nil, err)
+		m.rename(oldname, newname)
+		m.verify(fs, oldname)
+		m.verify(fs, newname)
+	default:
+		name := m.randomPath(true)
+		m.history = append(m.history, fmt.Sprintf("verify %q", name))
+		m.verify(fs, name)
+	}
+}
+
+// verify checks Stat, ReadFile, and ReadDir results for 'name' and its parent directory match the model
+func (m *propertyModel) verify(fs hackpadfs.FS, name string) {
+	m.tb.Helper()
+	m.verifyEntry(fs, name)
+	if dir := path.Dir(name); !m.parentMissing(name) {
+		m.verifyEntry(fs, dir)
+	}
+}
+
+func (m *propertyModel) verifyEntry(fs hackpadfs.FS, name string) {
+	m.tb.Helper()
+	entry, exists := m.entries[name]
+	info, err := hackpadfs.Stat(fs, name)
+	if !exists {
+		m.checkErr(fmt.Sprintf("stat %q", name), hackpadfs.ErrNotExist, err)
+		return
+	}
+	m.checkErr(fmt.Sprintf("stat %q", name), nil, err)
+	if info.IsDir() != entry.dir {
+		m.fail("stat %q: expected IsDir() to be %t", name, entry.dir)
+	}
+	if entry.dir {
+		entries, err := hackpadfs.ReadDir(fs, name)
+		m.checkErr(fmt.Sprintf("readdir %q", name), nil, err)
+		var names []string
+		for _, dirEntry := range entries {
+			names = append(names, dirEntry.Name())
+			if child := m.entries[path.Join(name, dirEntry.Name())]; child != nil && child.dir != dirEntry.IsDir() {
+				m.fail("readdir %q: expected %q IsDir() to be %t", name, dirEntry.Name(), child.dir)
+			}
+		}
+		if expected := m.children(name); strings.Join(expected, "/") != strings.Join(names, "/") {
+			m.fail("readdir %q: expected entries %q, got %q", name, expected, names)
+		}
+		return
+	}
+	if info.Size() != int64(len(entry.data)) {
+		m.fail("stat %q: expected size %d, got %d", name, len(entry.data), info.Size())
+	}
+	data, err := hackpadfs.ReadFile(fs, name)
+	m.checkErr(fmt.Sprintf("readfile %q", name), nil, err)
+	if !bytes.Equal(entry.data, data) {
+		m.fail("readfile %q: expected %q, got %q", name, entry.data, data)
+	}
+}
+
+func (m *propertyModel) verifyTree(fs hackpadfs.FS) {
+	m.tb.Helper()
+	names := make([]string, 0, len(m.entries))
+	for name := range m.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.verifyEntry(fs, name)
+	}
+}
@@ -0,0 +1,81 @@
+package fstest
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runWithTimeout runs 'fn' and fails 'tb' if it doesn't complete within 'timeout'. Runs without a timeout if 'timeout' is 0.
+func runWithTimeout(tb testing.TB, timeout time.Duration, fn func()) {
+	tb.Helper()
+	if timeout <= 0 {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	goroutineIDs := make(chan string, 1)
+	go func() {
+		defer close(done)
+		goroutineIDs <- goroutineID()
+		fn()
+	}()
+	id := <-goroutineIDs
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		tb.Fatalf("Timed out after %s. Stalled operation:\n%s", timeout, stalledStack(id))
+	}
+}
+
+// goroutineID returns the current goroutine's ID, as printed in stack traces
+func goroutineID() string {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	return goroutineIDFromStack(string(buf))
+}
+
+// stalledStack returns the stack trace of the goroutine with the given ID.
+// If it's waiting on a subtest, returns the stack of the innermost subtest instead.
+func stalledStack(id string) string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+	stacks := strings.Split(string(buf), "\n\n")
+	stack := findStack(stacks, "goroutine "+id+" ", "")
+	if stack == "" {
+		return "stack trace not found for goroutine " + id
+	}
+	for {
+		// subtests run in new goroutines, which report their creator like "created by testing.(*T).Run in goroutine 123"
+		subtestStack := findStack(stacks, "", "created by testing.(*T).Run in goroutine "+id+"\n")
+		if subtestStack == "" {
+			return stack
+		}
+		stack = subtestStack
+		id = goroutineIDFromStack(stack)
+	}
+}
+
+// findStack returns the first stack starting with 'prefix' and containing 'substr'
+func findStack(stacks []string, prefix, substr string) string {
+	for _, stack := range stacks {
+		if strings.HasPrefix(stack, prefix) && strings.Contains(stack+"\n", substr) {
+			return stack
+		}
+	}
+	return ""
+}
+
+func goroutineIDFromStack(stack string) string {
+	// stack starts with a header like "goroutine 123 [running]:"
+	fields := strings.Fields(stack)
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
@@ -0,0 +1,40 @@
+package fuse
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// toErrno converts FS errors into errnos for the OS
+func toErrno(err error) syscall.Errno {
+	if err == nil {
+		return 0
+	}
+	var errno syscall.Errno
+	switch {
+	case errors.As(err, &errno):
+		return errno
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return syscall.ENOENT
+	case errors.Is(err, hackpadfs.ErrExist):
+		return syscall.EEXIST
+	case errors.Is(err, hackpadfs.ErrPermission):
+		return syscall.EACCES
+	case errors.Is(err, hackpadfs.ErrClosed):
+		return syscall.EBADF
+	default:
+		return syscall.EIO
+	}
+}
+
+// toOpenErrno converts FS errors from opening files. The OS treats ENOSYS as lacking open or create support for the rest of the mount,
+// but the FS may only lack support for some flags, like writing.
+func toOpenErrno(err error) syscall.Errno {
+	errno := toErrno(err)
+	if errno == syscall.ENOSYS {
+		return syscall.ENOTSUP
+	}
+	return errno
+}
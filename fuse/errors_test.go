@@ -0,0 +1,42 @@
+package fuse
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestToErrno(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		err       error
+		expectErr syscall.Errno
+	}{
+		{err: nil, expectErr: 0},
+		{err: &hackpadfs.PathError{Op: "open", Path: "foo", Err: hackpadfs.ErrNotExist}, expectErr: syscall.ENOENT},
+		{err: &hackpadfs.PathError{Op: "mkdir", Path: "foo", Err: hackpadfs.ErrExist}, expectErr: syscall.EEXIST},
+		{err: &hackpadfs.PathError{Op: "open", Path: "foo", Err: hackpadfs.ErrPermission}, expectErr: syscall.EACCES},
+		{err: &hackpadfs.PathError{Op: "read", Path: "foo", Err: hackpadfs.ErrClosed}, expectErr: syscall.EBADF},
+		{err: &hackpadfs.PathError{Op: "remove", Path: "foo", Err: hackpadfs.ErrNotEmpty}, expectErr: syscall.ENOTEMPTY},
+		{err: &hackpadfs.LinkError{Op: "rename", Old: "foo", New: "bar", Err: hackpadfs.ErrIsDir}, expectErr: syscall.EISDIR},
+		{err: &hackpadfs.PathError{Op: "chmod", Path: "foo", Err: hackpadfs.ErrNotImplemented}, expectErr: syscall.ENOSYS},
+		{err: fmt.Errorf("wrapped: %w", syscall.ENOSPC), expectErr: syscall.ENOSPC},
+		{err: errors.New("some error"), expectErr: syscall.EIO},
+	} {
+		tc := tc
+		t.Run(fmt.Sprint(tc.err), func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expectErr, toErrno(tc.err))
+		})
+	}
+}
+
+func TestToOpenErrno(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, syscall.ENOTSUP, toOpenErrno(&hackpadfs.PathError{Op: "open", Path: "foo", Err: hackpadfs.ErrNotImplemented}))
+	assert.Equal(t, syscall.ENOENT, toOpenErrno(&hackpadfs.PathError{Op: "open", Path: "foo", Err: hackpadfs.ErrNotExist}))
+}
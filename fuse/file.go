@@ -0,0 +1,98 @@
+package fuse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+
+	"github.com/hack-pad/hackpadfs"
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	gofuse "github.com/hanwen/go-fuse/v2/fuse"
+)
+
+var (
+	_ interface {
+		gofs.FileReader
+		gofs.FileWriter
+		gofs.FileFlusher
+		gofs.FileFsyncer
+		gofs.FileReleaser
+		gofs.FileGetattrer
+	} = &handle{}
+)
+
+// handle serves an open FS file. Files without ReadAt or WriteAt fall back to Seek, so requests are serialized.
+type handle struct {
+	mu   sync.Mutex
+	file hackpadfs.File
+}
+
+func (h *handle) Read(ctx context.Context, dest []byte, off int64) (gofuse.ReadResult, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := hackpadfs.ReadAtFile(h.file, dest, off)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		_, err = hackpadfs.SeekFile(h.file, off, io.SeekStart)
+		if err == nil {
+			n, err = io.ReadFull(h.file, dest)
+			if err == io.ErrUnexpectedEOF {
+				err = nil
+			}
+		}
+	}
+	if err != nil && err != io.EOF {
+		return nil, toErrno(err)
+	}
+	return gofuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *handle) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n, err := hackpadfs.WriteAtFile(h.file, data, off)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		_, err = hackpadfs.SeekFile(h.file, off, io.SeekStart)
+		if err == nil {
+			n, err = hackpadfs.WriteFile(h.file, data)
+		}
+	}
+	return uint32(n), toErrno(err)
+}
+
+// Flush runs on every close of a file descriptor, so written data is visible to the FS once closed
+func (h *handle) Flush(ctx context.Context) syscall.Errno {
+	return h.sync()
+}
+
+func (h *handle) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	return h.sync()
+}
+
+func (h *handle) sync() syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	err := hackpadfs.SyncFile(h.file)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		err = nil
+	}
+	return toErrno(err)
+}
+
+func (h *handle) Release(ctx context.Context) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return toErrno(h.file.Close())
+}
+
+func (h *handle) Getattr(ctx context.Context, out *gofuse.AttrOut) syscall.Errno {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	info, err := h.file.Stat()
+	if err != nil {
+		return toErrno(err)
+	}
+	setAttr(&out.Attr, info)
+	return 0
+}
@@ -0,0 +1,12 @@
+module github.com/hack-pad/hackpadfs/fuse
+
+go 1.18
+
+require (
+	github.com/hack-pad/hackpadfs v0.1.1
+	github.com/hanwen/go-fuse/v2 v2.9.0
+)
+
+require golang.org/x/sys v0.28.0 // indirect
+
+replace github.com/hack-pad/hackpadfs => ../
@@ -0,0 +1,82 @@
+// Package fuse serves any FS to the host OS with FUSE, so it can be browsed with normal OS tools and used by non-Go programs.
+//
+// Mounting requires FUSE support from the OS, like the fuse kernel module and fusermount on Linux, or macFUSE on macOS.
+package fuse
+
+import (
+	"os"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	gofuse "github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const defaultName = "hackpadfs"
+
+// Options provides configuration options for Mount.
+type Options struct {
+	// Name is the mount's source, shown in mount tables. Defaults to "hackpadfs".
+	Name string
+	// ReadOnly mounts the FS read-only
+	ReadOnly bool
+	// AllowOther lets users other than the mounting user access the mount. Non-root users must enable user_allow_other in /etc/fuse.conf.
+	AllowOther bool
+	// CacheTimeout is how long the OS may cache file attributes and directory entries.
+	// Defaults to 0, so changes made directly to the FS are visible immediately.
+	CacheTimeout time.Duration
+	// Debug logs every FUSE request and response
+	Debug bool
+}
+
+// Server serves an FS at a mount point
+type Server struct {
+	server *gofuse.Server
+}
+
+// Mount serves 'fs' at the directory 'mountPoint', returning once the mount is ready.
+// The FS is served until Unmount is called, or it is unmounted by the OS, like with 'fusermount -u' or 'umount'.
+//
+// Modes, sizes, and modified times are served from the FS, and files are owned by the mounting user.
+// File permissions are checked by the OS, but the FS may enforce its own permissions as well.
+func Mount(mountPoint string, fs hackpadfs.FS, options Options) (*Server, error) {
+	if options.Name == "" {
+		options.Name = defaultName
+	}
+	// let the OS check permissions, like other file systems
+	mountOptions := []string{"default_permissions"}
+	if options.ReadOnly {
+		mountOptions = append(mountOptions, "ro")
+	}
+	timeout := options.CacheTimeout
+	server, err := gofs.Mount(mountPoint, &node{fs: fs}, &gofs.Options{
+		MountOptions: gofuse.MountOptions{
+			FsName:     options.Name,
+			Name:       defaultName,
+			AllowOther: options.AllowOther,
+			Debug:      options.Debug,
+			Options:    mountOptions,
+			// try mount(2) first, which works without fusermount when running as root
+			DirectMount: true,
+		},
+		EntryTimeout:    &timeout,
+		AttrTimeout:     &timeout,
+		NegativeTimeout: &timeout,
+		UID:             uint32(os.Getuid()),
+		GID:             uint32(os.Getgid()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{server: server}, nil
+}
+
+// Wait blocks until the FS is unmounted
+func (s *Server) Wait() {
+	s.server.Wait()
+}
+
+// Unmount unmounts the FS and stops serving it. Fails if the mount is busy, like when it's a process's working directory.
+func (s *Server) Unmount() error {
+	return s.server.Unmount()
+}
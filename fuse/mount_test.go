@@ -0,0 +1,154 @@
+package fuse
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	osfs "github.com/hack-pad/hackpadfs/os"
+)
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+func mount(tb testing.TB, fs hackpadfs.FS, options Options) string {
+	tb.Helper()
+	dir := tb.TempDir()
+	server, err := Mount(dir, fs, options)
+	if err != nil {
+		tb.Skip("Mounting with FUSE is not supported:", err)
+	}
+	tb.Cleanup(func() {
+		assert.NoError(tb, server.Unmount())
+	})
+	return dir
+}
+
+func TestMount(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, fs.Mkdir("foo", 0700))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("bar"), 0600))
+	dir := mount(t, fs, Options{})
+
+	t.Run("read from FS", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(dir, "foo", "bar"))
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", string(data))
+
+		info, err := os.Stat(filepath.Join(dir, "foo"))
+		requireNoError(t, err)
+		assert.Equal(t, os.ModeDir|0700, info.Mode())
+	})
+
+	t.Run("write to FS", func(t *testing.T) {
+		requireNoError(t, os.Mkdir(filepath.Join(dir, "baz"), 0750))
+		requireNoError(t, os.WriteFile(filepath.Join(dir, "baz", "biff"), []byte("hello world"), 0640))
+		f, err := os.OpenFile(filepath.Join(dir, "baz", "biff"), os.O_WRONLY|os.O_APPEND, 0)
+		requireNoError(t, err)
+		_, err = f.Write([]byte("!"))
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+
+		data, err := hackpadfs.ReadFile(fs, "baz/biff")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello world!", string(data))
+		info, err := fs.Stat("baz/biff")
+		requireNoError(t, err)
+		assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+
+		requireNoError(t, os.Truncate(filepath.Join(dir, "baz", "biff"), 5))
+		data, err = hackpadfs.ReadFile(fs, "baz/biff")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+	})
+
+	t.Run("read dir", func(t *testing.T) {
+		requireNoError(t, fs.Mkdir("dir", 0700))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dir/a", nil, 0600))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dir/b", nil, 0600))
+		entries, err := os.ReadDir(filepath.Join(dir, "dir"))
+		requireNoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		sort.Strings(names)
+		assert.Equal(t, []string{"a", "b"}, names)
+	})
+
+	t.Run("rename and remove", func(t *testing.T) {
+		requireNoError(t, os.WriteFile(filepath.Join(dir, "old"), []byte("old"), 0600))
+		requireNoError(t, os.Rename(filepath.Join(dir, "old"), filepath.Join(dir, "new")))
+		_, err := fs.Stat("old")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		data, err := hackpadfs.ReadFile(fs, "new")
+		assert.NoError(t, err)
+		assert.Equal(t, "old", string(data))
+
+		requireNoError(t, os.Remove(filepath.Join(dir, "new")))
+		_, err = fs.Stat("new")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		_, err = os.Stat(filepath.Join(dir, "new"))
+		assert.ErrorIs(t, os.ErrNotExist, err)
+	})
+}
+
+func TestMountReadOnly(t *testing.T) {
+	t.Parallel()
+	fs, err := mem.NewFS()
+	requireNoError(t, err)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	dir := mount(t, fs, Options{ReadOnly: true})
+
+	data, err := os.ReadFile(filepath.Join(dir, "foo"))
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(data))
+	err = os.WriteFile(filepath.Join(dir, "foo"), []byte("bar"), 0600)
+	assert.Error(t, err)
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	// the OS applies the umask to new files before the FS sees them
+	oldmask := syscall.Umask(0)
+	t.Cleanup(func() {
+		syscall.Umask(oldmask)
+	})
+	options := fstest.FSOptions{
+		Name: "fuse",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := mem.NewFS()
+			requireNoError(tb, err)
+			dir := mount(tb, fs, Options{})
+			mountFS, err := osfs.NewFS().Sub(strings.TrimPrefix(dir, "/"))
+			requireNoError(tb, err)
+			return mountFS.(*osfs.FS)
+		},
+		Constraints: fstest.Constraints{
+			AllowUnenforcedPermissions: true,
+		},
+		ShouldSkip: func(facets fstest.Facets) bool {
+			// Linux reports links to FSs without Link support as EPERM, not ENOSYS
+			return strings.HasSuffix(facets.Name, "/fs.Link/names_share_contents") ||
+				strings.HasSuffix(facets.Name, "/fs.Link/remove_one_name") ||
+				strings.HasSuffix(facets.Name, "/fs.Link/rename_one_name") ||
+				// os.FS reports ReadDir on a file as an "open" error
+				strings.HasSuffix(facets.Name, "/fs.ReadDir/file_is_not_a_dir")
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
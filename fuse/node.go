@@ -0,0 +1,319 @@
+package fuse
+
+import (
+	"context"
+	"errors"
+	"path"
+	"syscall"
+
+	"github.com/hack-pad/hackpadfs"
+	gofs "github.com/hanwen/go-fuse/v2/fs"
+	gofuse "github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const (
+	blockSize = 4096
+	// renameNoReplace is renameat2()'s RENAME_NOREPLACE flag
+	renameNoReplace = 0x1
+)
+
+var (
+	_ interface {
+		gofs.InodeEmbedder
+		gofs.NodeLookuper
+		gofs.NodeGetattrer
+		gofs.NodeSetattrer
+		gofs.NodeReaddirer
+		gofs.NodeOpener
+		gofs.NodeCreater
+		gofs.NodeMkdirer
+		gofs.NodeUnlinker
+		gofs.NodeRmdirer
+		gofs.NodeRenamer
+		gofs.NodeReadlinker
+		gofs.NodeSymlinker
+		gofs.NodeLinker
+		gofs.NodeStatfser
+	} = &node{}
+)
+
+// node serves a file or directory, identified by its path from the mount's root
+type node struct {
+	gofs.Inode
+	fs hackpadfs.FS
+}
+
+func (n *node) path(name string) string {
+	p := path.Join(n.Path(nil), name)
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
+// newChild returns the existing child 'name' if it has the same type, otherwise a new child
+func (n *node) newChild(ctx context.Context, name string, out *gofuse.EntryOut) (*gofs.Inode, syscall.Errno) {
+	info, err := hackpadfs.LstatOrStat(n.fs, n.path(name))
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	setAttr(&out.Attr, info)
+	stable := gofs.StableAttr{Mode: out.Attr.Mode & syscall.S_IFMT}
+	if child := n.GetChild(name); child != nil && child.StableAttr().Mode == stable.Mode {
+		return child, 0
+	}
+	return n.NewInode(ctx, &node{fs: n.fs}, stable), 0
+}
+
+func (n *node) Lookup(ctx context.Context, name string, out *gofuse.EntryOut) (*gofs.Inode, syscall.Errno) {
+	return n.newChild(ctx, name, out)
+}
+
+func (n *node) Getattr(ctx context.Context, f gofs.FileHandle, out *gofuse.AttrOut) syscall.Errno {
+	// prefer the FS's info, since some FSs' open files report info from when they were opened
+	info, err := hackpadfs.LstatOrStat(n.fs, n.path(""))
+	if h, ok := f.(*handle); ok && errors.Is(err, hackpadfs.ErrNotExist) {
+		// removed files are still served while open
+		return h.Getattr(ctx, out)
+	}
+	if err != nil {
+		return toErrno(err)
+	}
+	setAttr(&out.Attr, info)
+	return 0
+}
+
+func (n *node) Setattr(ctx context.Context, f gofs.FileHandle, in *gofuse.SetAttrIn, out *gofuse.AttrOut) syscall.Errno {
+	p := n.path("")
+	if mode, ok := in.GetMode(); ok {
+		if err := hackpadfs.Chmod(n.fs, p, fromMode(mode)); err != nil {
+			return toErrno(err)
+		}
+	}
+	uid, uidOK := in.GetUID()
+	gid, gidOK := in.GetGID()
+	if uidOK || gidOK {
+		newUID, newGID := -1, -1
+		if uidOK {
+			newUID = int(uid)
+		}
+		if gidOK {
+			newGID = int(gid)
+		}
+		if err := hackpadfs.Chown(n.fs, p, newUID, newGID); err != nil {
+			return toErrno(err)
+		}
+	}
+	if size, ok := in.GetSize(); ok {
+		if errno := n.truncate(f, int64(size)); errno != 0 {
+			return errno
+		}
+	}
+	atime, atimeOK := in.GetATime()
+	mtime, mtimeOK := in.GetMTime()
+	if atimeOK || mtimeOK {
+		if !mtimeOK {
+			info, err := hackpadfs.LstatOrStat(n.fs, p)
+			if err != nil {
+				return toErrno(err)
+			}
+			mtime = info.ModTime()
+		}
+		if !atimeOK {
+			// access times aren't served, so keep them in step with modified times
+			atime = mtime
+		}
+		if err := hackpadfs.Chtimes(n.fs, p, atime, mtime); err != nil {
+			return toErrno(err)
+		}
+	}
+	return n.Getattr(ctx, f, out)
+}
+
+func (n *node) truncate(f gofs.FileHandle, size int64) syscall.Errno {
+	if h, ok := f.(*handle); ok {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		return toErrno(hackpadfs.TruncateFile(h.file, size))
+	}
+	file, err := hackpadfs.OpenFile(n.fs, n.path(""), hackpadfs.FlagWriteOnly, 0)
+	if err != nil {
+		return toErrno(err)
+	}
+	err = hackpadfs.TruncateFile(file, size)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return toErrno(err)
+}
+
+func (n *node) Readdir(ctx context.Context) (gofs.DirStream, syscall.Errno) {
+	dirEntries, err := hackpadfs.ReadDir(n.fs, n.path(""))
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	entries := make([]gofuse.DirEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		entries = append(entries, gofuse.DirEntry{
+			Name: entry.Name(),
+			Mode: toMode(entry.Type()),
+		})
+	}
+	return gofs.NewListDirStream(entries), 0
+}
+
+func (n *node) Open(ctx context.Context, flags uint32) (gofs.FileHandle, uint32, syscall.Errno) {
+	file, err := hackpadfs.OpenFile(n.fs, n.path(""), openFlags(flags), 0)
+	if err != nil {
+		return nil, 0, toOpenErrno(err)
+	}
+	return &handle{file: file}, gofuse.FOPEN_DIRECT_IO, 0
+}
+
+func (n *node) Create(ctx context.Context, name string, flags uint32, mode uint32, out *gofuse.EntryOut) (*gofs.Inode, gofs.FileHandle, uint32, syscall.Errno) {
+	flag := openFlags(flags) | hackpadfs.FlagCreate | int(flags)&hackpadfs.FlagExclusive
+	file, err := hackpadfs.OpenFile(n.fs, n.path(name), flag, fromMode(mode))
+	if err != nil {
+		return nil, nil, 0, toOpenErrno(err)
+	}
+	child, errno := n.newChild(ctx, name, out)
+	if errno != 0 {
+		_ = file.Close()
+		return nil, nil, 0, errno
+	}
+	return child, &handle{file: file}, gofuse.FOPEN_DIRECT_IO, 0
+}
+
+func (n *node) Mkdir(ctx context.Context, name string, mode uint32, out *gofuse.EntryOut) (*gofs.Inode, syscall.Errno) {
+	if err := hackpadfs.Mkdir(n.fs, n.path(name), fromMode(mode)); err != nil {
+		return nil, toErrno(err)
+	}
+	return n.newChild(ctx, name, out)
+}
+
+func (n *node) Unlink(ctx context.Context, name string) syscall.Errno {
+	return toErrno(hackpadfs.Remove(n.fs, n.path(name)))
+}
+
+func (n *node) Rmdir(ctx context.Context, name string) syscall.Errno {
+	return toErrno(hackpadfs.Remove(n.fs, n.path(name)))
+}
+
+func (n *node) Rename(ctx context.Context, name string, newParent gofs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	if flags&gofs.RENAME_EXCHANGE != 0 {
+		return syscall.ENOTSUP
+	}
+	newPath := path.Join(newParent.EmbeddedInode().Path(nil), newName)
+	if flags&renameNoReplace != 0 {
+		_, err := hackpadfs.LstatOrStat(n.fs, newPath)
+		switch {
+		case err == nil:
+			return syscall.EEXIST
+		case !errors.Is(err, hackpadfs.ErrNotExist):
+			return toErrno(err)
+		}
+	}
+	return toErrno(hackpadfs.Rename(n.fs, n.path(name), newPath))
+}
+
+func (n *node) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	target, err := hackpadfs.Readlink(n.fs, n.path(""))
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	return []byte(target), 0
+}
+
+func (n *node) Symlink(ctx context.Context, target, name string, out *gofuse.EntryOut) (*gofs.Inode, syscall.Errno) {
+	if err := hackpadfs.Symlink(n.fs, target, n.path(name)); err != nil {
+		return nil, toErrno(err)
+	}
+	return n.newChild(ctx, name, out)
+}
+
+func (n *node) Link(ctx context.Context, target gofs.InodeEmbedder, name string, out *gofuse.EntryOut) (*gofs.Inode, syscall.Errno) {
+	targetPath := target.EmbeddedInode().Path(nil)
+	if err := hackpadfs.Link(n.fs, targetPath, n.path(name)); err != nil {
+		return nil, toErrno(err)
+	}
+	return n.newChild(ctx, name, out)
+}
+
+func (n *node) Statfs(ctx context.Context, out *gofuse.StatfsOut) syscall.Errno {
+	usage, err := hackpadfs.Statfs(n.fs, n.path(""))
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		// serve an empty usage, like the default
+		return 0
+	}
+	if err != nil {
+		return toErrno(err)
+	}
+	out.Bsize = blockSize
+	out.Frsize = blockSize
+	out.NameLen = 255
+	out.Blocks = uint64(usage.Total / blockSize)
+	out.Bfree = uint64((usage.Total - usage.Used) / blockSize)
+	out.Bavail = uint64(usage.Available / blockSize)
+	return 0
+}
+
+// openFlags converts open(2) flags into FS flags. Appends are already positioned at the end of the file by the OS.
+func openFlags(flags uint32) int {
+	return int(flags) & (syscall.O_ACCMODE | hackpadfs.FlagTruncate | hackpadfs.FlagSync)
+}
+
+func setAttr(attr *gofuse.Attr, info hackpadfs.FileInfo) {
+	attr.Mode = toMode(info.Mode())
+	attr.Size = uint64(info.Size())
+	attr.Blocks = (attr.Size + 511) / 512
+	attr.Blksize = blockSize
+	attr.Nlink = 1
+	modTime := info.ModTime()
+	attr.SetTimes(&modTime, &modTime, &modTime)
+}
+
+// toMode converts a FileMode into a stat(2) mode
+func toMode(mode hackpadfs.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	switch {
+	case mode&hackpadfs.ModeDir != 0:
+		m |= syscall.S_IFDIR
+	case mode&hackpadfs.ModeSymlink != 0:
+		m |= syscall.S_IFLNK
+	case mode&hackpadfs.ModeNamedPipe != 0:
+		m |= syscall.S_IFIFO
+	case mode&hackpadfs.ModeSocket != 0:
+		m |= syscall.S_IFSOCK
+	case mode&hackpadfs.ModeCharDevice != 0:
+		m |= syscall.S_IFCHR
+	case mode&hackpadfs.ModeDevice != 0:
+		m |= syscall.S_IFBLK
+	default:
+		m |= syscall.S_IFREG
+	}
+	if mode&hackpadfs.ModeSetuid != 0 {
+		m |= syscall.S_ISUID
+	}
+	if mode&hackpadfs.ModeSetgid != 0 {
+		m |= syscall.S_ISGID
+	}
+	if mode&hackpadfs.ModeSticky != 0 {
+		m |= syscall.S_ISVTX
+	}
+	return m
+}
+
+// fromMode converts a stat(2) mode's permissions into a FileMode. File types are set by the operation instead, like Mkdir.
+func fromMode(m uint32) hackpadfs.FileMode {
+	mode := hackpadfs.FileMode(m & 0777)
+	if m&syscall.S_ISUID != 0 {
+		mode |= hackpadfs.ModeSetuid
+	}
+	if m&syscall.S_ISGID != 0 {
+		mode |= hackpadfs.ModeSetgid
+	}
+	if m&syscall.S_ISVTX != 0 {
+		mode |= hackpadfs.ModeSticky
+	}
+	return mode
+}
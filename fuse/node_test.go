@@ -0,0 +1,43 @@
+package fuse
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestModes(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		mode       hackpadfs.FileMode
+		expectMode uint32
+	}{
+		{mode: 0644, expectMode: syscall.S_IFREG | 0644},
+		{mode: hackpadfs.ModeDir | 0755, expectMode: syscall.S_IFDIR | 0755},
+		{mode: hackpadfs.ModeSymlink | 0777, expectMode: syscall.S_IFLNK | 0777},
+		{mode: hackpadfs.ModeNamedPipe | 0600, expectMode: syscall.S_IFIFO | 0600},
+		{mode: hackpadfs.ModeSocket | 0600, expectMode: syscall.S_IFSOCK | 0600},
+		{mode: hackpadfs.ModeDevice | hackpadfs.ModeCharDevice | 0600, expectMode: syscall.S_IFCHR | 0600},
+		{mode: hackpadfs.ModeDevice | 0600, expectMode: syscall.S_IFBLK | 0600},
+		{mode: hackpadfs.ModeSetuid | hackpadfs.ModeSetgid | 0755, expectMode: syscall.S_IFREG | syscall.S_ISUID | syscall.S_ISGID | 0755},
+		{mode: hackpadfs.ModeDir | hackpadfs.ModeSticky | 0777, expectMode: syscall.S_IFDIR | syscall.S_ISVTX | 0777},
+	} {
+		tc := tc
+		t.Run(tc.mode.String(), func(t *testing.T) {
+			t.Parallel()
+			mode := toMode(tc.mode)
+			assert.Equal(t, tc.expectMode, mode)
+			assert.Equal(t, tc.mode&(hackpadfs.ModePerm|hackpadfs.ModeSetuid|hackpadfs.ModeSetgid|hackpadfs.ModeSticky), fromMode(mode))
+		})
+	}
+}
+
+func TestOpenFlags(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, hackpadfs.FlagReadOnly, openFlags(syscall.O_RDONLY|syscall.O_CLOEXEC))
+	assert.Equal(t, hackpadfs.FlagWriteOnly|hackpadfs.FlagTruncate, openFlags(syscall.O_WRONLY|syscall.O_TRUNC))
+	// the OS positions appends, so WriteAt isn't rejected by FSs like os.FS
+	assert.Equal(t, hackpadfs.FlagReadWrite, openFlags(syscall.O_RDWR|syscall.O_APPEND))
+}
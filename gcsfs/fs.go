@@ -0,0 +1,487 @@
+// Package gcsfs contains an FS backed by Google Cloud Storage.
+//
+// Files are stored as plain objects and directories as name prefixes, so existing buckets can be read as-is.
+package gcsfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/hack-pad/hackpadfs"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+	} = &FS{}
+)
+
+const (
+	modeMetadataKey    = "mode"
+	modTimeMetadataKey = "modtime"
+	modTimeFormat      = time.RFC3339Nano
+
+	octalSize = 8 // formats file mode, for convenient human-readable metadata
+
+	defaultFileMode = 0644
+	defaultDirMode  = 0755
+	dirContentType  = "application/x-directory"
+
+	defaultChunkSize    = 16 << 20 // the storage client's default, buffered in memory for each open upload
+	defaultListPageSize = 1000     // the most objects GCS returns in one list request
+)
+
+// Options provides configuration options for a new FS.
+type Options struct {
+	BucketName string
+	// ClientOptions configure the storage client, like credentials or an endpoint. Defaults to Application Default Credentials.
+	ClientOptions []option.ClientOption
+
+	// Prefix is prepended to all object names, rooting the FS at a directory inside the bucket. Defaults to the bucket root.
+	Prefix string
+	// ChunkSize is the size in bytes of each request in a resumable upload. Files at least this large are uploaded in chunks.
+	// Defaults to 16 MiB. GCS rounds it up to a multiple of 256 KiB.
+	ChunkSize int
+	// ListPageSize is the maximum number of objects requested in each list call. Defaults to 1000.
+	ListPageSize int
+}
+
+// FS is a file system backed by a Google Cloud Storage bucket.
+//
+// Files are objects named by their path. Directories are name prefixes ending in "/", with an empty "marker" object to store their metadata.
+// Directories without a marker, like those created by other GCS clients, exist as long as they contain any objects.
+// File modes and modified times set with Chmod and Chtimes are stored in object metadata.
+//
+// Opening a file write-only with FlagTruncate or FlagAppend, or creating a new one, streams writes to a resumable upload, completed on Close.
+// Other writable files are read into memory and uploaded on Sync or Close.
+//
+// Rename copies objects on the server with generation preconditions, so it fails rather than losing data if either file changes during the copy.
+// It is still not atomic: a renamed directory's contents are all copied before any are removed.
+type FS struct {
+	options Options
+	client  *storage.Client
+	bucket  *storage.BucketHandle
+}
+
+// NewFS returns a new FS for the bucket described in 'options'
+func NewFS(options Options) (*FS, error) {
+	if options.ChunkSize == 0 {
+		options.ChunkSize = defaultChunkSize
+	}
+	if options.ChunkSize < 0 {
+		return nil, fmt.Errorf("chunk size must be positive: %d", options.ChunkSize)
+	}
+	if options.ListPageSize == 0 {
+		options.ListPageSize = defaultListPageSize
+	}
+	if options.ListPageSize < 0 {
+		return nil, fmt.Errorf("list page size must be positive: %d", options.ListPageSize)
+	}
+	options.Prefix = strings.Trim(options.Prefix, "/")
+	if options.Prefix != "" {
+		options.Prefix += "/"
+	}
+	client, err := storage.NewClient(context.Background(), options.ClientOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return &FS{
+		options: options,
+		client:  client,
+		bucket:  client.Bucket(options.BucketName),
+	}, nil
+}
+
+// Close closes the underlying storage client
+func (fs *FS) Close() error {
+	return fs.client.Close()
+}
+
+// key returns the object name for file 'name'
+func (fs *FS) key(name string) string {
+	return fs.options.Prefix + name
+}
+
+// dirKey returns the name prefix of directory 'name', which is also the name of its marker object
+func (fs *FS) dirKey(name string) string {
+	if name == "." {
+		return fs.options.Prefix
+	}
+	return fs.options.Prefix + name + "/"
+}
+
+func wrapErr(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) || errors.Is(err, storage.ErrBucketNotExist) {
+		return hackpadfs.ErrNotExist
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case http.StatusNotFound:
+			return hackpadfs.ErrNotExist
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return hackpadfs.ErrPermission
+		}
+	}
+	return err
+}
+
+// isPreconditionFailed returns true if 'err' is from a request whose generation conditions no longer hold
+func isPreconditionFailed(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed
+}
+
+// stat returns info for 'name', checking for a file, then a directory marker, then any objects inside a directory prefix
+func (fs *FS) stat(name string) (*fileInfo, error) {
+	if name == "." {
+		return &fileInfo{name: name, mode: hackpadfs.ModeDir | defaultDirMode}, nil
+	}
+	ctx := context.Background()
+	attrs, err := fs.bucket.Object(fs.key(name)).Attrs(ctx)
+	if err == nil {
+		return newFileInfo(name, attrs, false)
+	}
+	if err := wrapErr(err); !errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, err
+	}
+
+	attrs, err = fs.bucket.Object(fs.dirKey(name)).Attrs(ctx)
+	if err == nil {
+		return newFileInfo(name, attrs, true)
+	}
+	if err := wrapErr(err); !errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, err
+	}
+
+	it := fs.bucket.Objects(ctx, &storage.Query{Prefix: fs.dirKey(name), Delimiter: "/"})
+	it.PageInfo().MaxSize = 1
+	_, err = it.Next()
+	if err == iterator.Done {
+		return nil, hackpadfs.ErrNotExist
+	}
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return &fileInfo{name: path.Base(name), mode: hackpadfs.ModeDir | defaultDirMode}, nil
+}
+
+// checkParentDir returns an error if the parent of 'name' is not an existing directory
+func (fs *FS) checkParentDir(name string) error {
+	parent := path.Dir(name)
+	if parent == "." {
+		return nil
+	}
+	info, err := fs.stat(parent)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return hackpadfs.ErrNotDir
+	}
+	return nil
+}
+
+func metadata(mode hackpadfs.FileMode, modTime time.Time) map[string]string {
+	m := map[string]string{
+		modeMetadataKey: strconv.FormatUint(uint64(mode), octalSize),
+	}
+	if !modTime.IsZero() {
+		m[modTimeMetadataKey] = modTime.UTC().Format(modTimeFormat)
+	}
+	return m
+}
+
+// newWriter returns a writer uploading file 'name', which must be closed to complete the upload
+func (fs *FS) newWriter(ctx context.Context, name string, mode hackpadfs.FileMode, conds *storage.Conditions) *storage.Writer {
+	obj := fs.bucket.Object(fs.key(name))
+	if conds != nil {
+		obj = obj.If(*conds)
+	}
+	w := obj.NewWriter(ctx)
+	w.ChunkSize = fs.options.ChunkSize
+	w.Metadata = metadata(mode.Perm(), time.Time{})
+	return w
+}
+
+// putObject uploads file 'name' from 'r', if the optional 'conds' hold
+func (fs *FS) putObject(name string, r io.Reader, mode hackpadfs.FileMode, conds *storage.Conditions) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := fs.newWriter(ctx, name, mode, conds)
+	if _, err := io.Copy(w, r); err != nil {
+		cancel() // abandons the upload
+		_ = w.Close()
+		return wrapErr(err)
+	}
+	return wrapErr(w.Close())
+}
+
+// putDir writes directory 'name's marker object
+func (fs *FS) putDir(name string, mode hackpadfs.FileMode, modTime time.Time) error {
+	w := fs.bucket.Object(fs.dirKey(name)).NewWriter(context.Background())
+	w.ContentType = dirContentType
+	w.Metadata = metadata(hackpadfs.ModeDir|mode.Perm(), modTime)
+	return wrapErr(w.Close())
+}
+
+// copyObject copies 'src' to 'dst' on the server if 'src' still matches 'generation' and 'dst' hasn't been created since it was checked
+func (fs *FS) copyObject(src, dst string, generation int64, dstConds storage.Conditions) error {
+	srcObj := fs.bucket.Object(src).If(storage.Conditions{GenerationMatch: generation})
+	dstObj := fs.bucket.Object(dst).If(dstConds)
+	_, err := dstObj.CopierFrom(srcObj).Run(context.Background())
+	return wrapErr(err)
+}
+
+// dstConditions returns preconditions for replacing 'info', or for creating a new object if 'info' is nil
+func dstConditions(info *fileInfo) storage.Conditions {
+	if info == nil {
+		return storage.Conditions{DoesNotExist: true}
+	}
+	return storage.Conditions{GenerationMatch: info.generation}
+}
+
+// listPage lists one page of objects and prefixes starting with 'prefix', returning the token for the next page or "" on the last page
+func (fs *FS) listPage(prefix, token, delimiter string) ([]*storage.ObjectAttrs, string, error) {
+	it := fs.bucket.Objects(context.Background(), &storage.Query{Prefix: prefix, Delimiter: delimiter})
+	var objects []*storage.ObjectAttrs
+	next, err := iterator.NewPager(it, fs.options.ListPageSize, token).NextPage(&objects)
+	return objects, next, wrapErr(err)
+}
+
+// walkPrefix calls fn with each page of objects with names starting with 'prefix'
+func (fs *FS) walkPrefix(prefix string, fn func(objects []*storage.ObjectAttrs) error) error {
+	token := ""
+	for {
+		objects, next, err := fs.listPage(prefix, token, "")
+		if err != nil {
+			return err
+		}
+		if err := fn(objects); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		token = next
+	}
+}
+
+// removeObject removes 'key' if it still matches 'generation', or regardless of generation if it's 0
+func (fs *FS) removeObject(key string, generation int64) error {
+	obj := fs.bucket.Object(key)
+	if generation != 0 {
+		obj = obj.If(storage.Conditions{GenerationMatch: generation})
+	}
+	return wrapErr(obj.Delete(context.Background()))
+}
+
+// removePrefix removes all objects with names starting with 'prefix'
+func (fs *FS) removePrefix(prefix string) error {
+	return fs.walkPrefix(prefix, func(objects []*storage.ObjectAttrs) error {
+		for _, object := range objects {
+			err := fs.removeObject(object.Name, 0)
+			if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	_, err := fs.stat(name)
+	switch {
+	case err == nil:
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrExist}
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if err := fs.checkParentDir(name); err != nil {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if err := fs.putDir(name, perm, time.Time{}); err != nil {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "mkdirall", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	// find the missing directories, deepest first
+	var missing []string
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		info, err := fs.stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return &hackpadfs.PathError{Op: "mkdir", Path: dir, Err: hackpadfs.ErrNotDir}
+			}
+			break
+		}
+		if !errors.Is(err, hackpadfs.ErrNotExist) {
+			return &hackpadfs.PathError{Op: "mkdir", Path: dir, Err: err}
+		}
+		missing = append(missing, dir)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := fs.putDir(missing[i], perm, time.Time{}); err != nil {
+			return &hackpadfs.PathError{Op: "mkdir", Path: missing[i], Err: err}
+		}
+	}
+	return nil
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	if !hackpadfs.ValidPath(name) || name == "." {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	key := fs.key(name)
+	if info.IsDir() {
+		key = fs.dirKey(name)
+		// list past the directory's own marker, if any
+		it := fs.bucket.Objects(context.Background(), &storage.Query{Prefix: key, Delimiter: "/"})
+		it.PageInfo().MaxSize = 2
+		for i := 0; i < 2; i++ {
+			object, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return &hackpadfs.PathError{Op: "remove", Path: name, Err: wrapErr(err)}
+			}
+			if object.Name != key {
+				return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
+			}
+		}
+		if info.generation == 0 {
+			// an implicit directory disappears with its last object
+			return nil
+		}
+	}
+	if err := fs.removeObject(key, info.generation); err != nil {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if !hackpadfs.ValidPath(name) || name == "." {
+		return &hackpadfs.PathError{Op: "removeall", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return nil
+	case err != nil:
+		return &hackpadfs.PathError{Op: "removeall", Path: name, Err: err}
+	case info.IsDir():
+		err = fs.removePrefix(fs.dirKey(name))
+	default:
+		err = fs.removeObject(fs.key(name), 0)
+		if errors.Is(err, hackpadfs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "chmod", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err == nil {
+		err = fs.setMetadata(name, info, mode, info.modTime)
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "chtimes", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err == nil {
+		err = fs.setMetadata(name, info, info.mode, mtime)
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	return nil
+}
+
+// setMetadata updates the stored mode and modified time of 'name'
+func (fs *FS) setMetadata(name string, info *fileInfo, mode hackpadfs.FileMode, modTime time.Time) error {
+	if name == "." {
+		// the root has no marker to store metadata in
+		return hackpadfs.ErrNotImplemented
+	}
+	if info.IsDir() && info.generation == 0 {
+		// create a marker for the implicit directory
+		return fs.putDir(name, mode, modTime)
+	}
+	key, mode := fs.key(name), mode.Perm()
+	if info.IsDir() {
+		key, mode = fs.dirKey(name), hackpadfs.ModeDir|mode
+	}
+	// unlike S3, GCS updates metadata in place
+	obj := fs.bucket.Object(key).If(storage.Conditions{MetagenerationMatch: info.metageneration})
+	_, err := obj.Update(context.Background(), storage.ObjectAttrsToUpdate{
+		Metadata: metadata(mode, modTime),
+	})
+	return wrapErr(err)
+}
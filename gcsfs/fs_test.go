@@ -0,0 +1,241 @@
+package gcsfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"google.golang.org/api/option"
+)
+
+const testBucketName = "bucket"
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+func makeFS(tb testing.TB, server *fakeServer, options Options) *FS {
+	tb.Helper()
+	options.BucketName = testBucketName
+	options.ClientOptions = []option.ClientOption{
+		option.WithEndpoint(server.URL + "/storage/v1/"),
+		option.WithoutAuthentication(),
+	}
+	fs, err := NewFS(options)
+	requireNoError(tb, err)
+	tb.Cleanup(func() {
+		assert.NoError(tb, fs.Close())
+	})
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		options Options
+	}{
+		{name: "gcsfs", options: Options{}},
+		{name: "gcsfs prefix", options: Options{Prefix: "/root/dir/"}},
+		{name: "gcsfs small pages", options: Options{ListPageSize: 1}},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				return makeFS(tb, newFakeServer(tb), tc.options)
+			},
+			Constraints: fstest.Constraints{
+				AllowUnenforcedPermissions: true,
+			},
+			ShouldSkip: func(facets fstest.Facets) bool {
+				// writable files are uploaded on Sync or Close, and open files read the current object at their path
+				return strings.HasSuffix(facets.Name, "/file.Truncate/visible_to_other_open_files") ||
+					strings.HasSuffix(facets.Name, "/file.WriteAt/large_offset_outside_file") ||
+					strings.HasSuffix(facets.Name, "/fs.Rename/open_file")
+			},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func TestNewFS(t *testing.T) {
+	t.Parallel()
+	server := newFakeServer(t)
+	_, err := NewFS(Options{ChunkSize: -1})
+	assert.Error(t, err)
+	_, err = NewFS(Options{ListPageSize: -1})
+	assert.Error(t, err)
+	fs := makeFS(t, server, Options{Prefix: "/a/b/"})
+	assert.Equal(t, "a/b/c", fs.key("c"))
+	assert.Equal(t, "a/b/c/", fs.dirKey("c"))
+	assert.Equal(t, "a/b/", fs.dirKey("."))
+}
+
+func TestStreamingUpload(t *testing.T) {
+	t.Parallel()
+	const chunkSize = 256 << 10 // the smallest chunk size GCS allows
+	fs := makeFS(t, newFakeServer(t), Options{ChunkSize: chunkSize})
+
+	part := bytes.Repeat([]byte("hackpadfs"), chunkSize/len("hackpadfs"))
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0600)
+	requireNoError(t, err)
+	for i := 0; i < 3; i++ { // spans 3 chunks
+		_, err := hackpadfs.WriteFile(f, part)
+		requireNoError(t, err)
+	}
+	requireNoError(t, f.Close())
+
+	info, err := fs.Stat("foo")
+	requireNoError(t, err)
+	assert.Equal(t, int64(3*len(part)), info.Size())
+	assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+
+	f, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+	requireNoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("bar"))
+	requireNoError(t, err)
+	requireNoError(t, f.Close())
+
+	data, err := hackpadfs.ReadFile(fs, "foo")
+	requireNoError(t, err)
+	assert.Equal(t, 3*len(part)+len("bar"), len(data))
+	assert.Equal(t, true, bytes.Equal(part, data[2*len(part):3*len(part)]))
+	assert.Equal(t, "bar", string(data[3*len(part):]))
+}
+
+func TestReadAt(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, newFakeServer(t), Options{})
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("hello world"), 0600))
+
+	f, err := fs.Open("foo")
+	requireNoError(t, err)
+	buf := make([]byte, 5)
+	n, err := hackpadfs.ReadAtFile(f, buf, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+	n, err = hackpadfs.ReadAtFile(f, buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+	_, err = hackpadfs.ReadAtFile(f, buf, 11)
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, f.Close())
+}
+
+func TestImplicitDirectories(t *testing.T) {
+	t.Parallel()
+	server := newFakeServer(t)
+	fs := makeFS(t, server, Options{ListPageSize: 2})
+	ctx := context.Background()
+	for _, key := range []string{"a/b/c", "a/d", "a/e/", "a/f", "a/g", "i"} {
+		w := fs.bucket.Object(key).NewWriter(ctx)
+		_, err := w.Write([]byte(key))
+		requireNoError(t, err)
+		requireNoError(t, w.Close())
+	}
+
+	info, err := fs.Stat("a/b")
+	requireNoError(t, err)
+	assert.Equal(t, true, info.IsDir())
+	assert.Equal(t, hackpadfs.ModeDir|defaultDirMode, info.Mode())
+	info, err = fs.Stat("a/d")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(defaultFileMode), info.Mode())
+	assert.Equal(t, int64(len("a/d")), info.Size())
+
+	dir, err := fs.Open("a")
+	requireNoError(t, err)
+	var names []string
+	for {
+		entries, err := hackpadfs.ReadDirFile(dir, 2)
+		if err == io.EOF {
+			break
+		}
+		requireNoError(t, err)
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+	}
+	requireNoError(t, dir.Close())
+	assert.Equal(t, []string{"b/", "d", "e/", "f", "g"}, names)
+
+	requireNoError(t, fs.Chmod("a/b", 0700))
+	info, err = fs.Stat("a/b")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+
+	assert.ErrorIs(t, hackpadfs.ErrNotEmpty, fs.Remove("a/b"))
+	requireNoError(t, fs.Remove("a/b/c"))
+	requireNoError(t, fs.Remove("a/b"))
+	_, err = fs.Stat("a/b")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestRenameDirectory(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, newFakeServer(t), Options{ListPageSize: 1})
+	requireNoError(t, hackpadfs.MkdirAll(fs, "a/b", 0700))
+	for _, name := range []string{"a/foo", "a/b/bar", "a/b/baz"} {
+		requireNoError(t, hackpadfs.WriteFullFile(fs, name, []byte(name), 0600))
+	}
+	requireNoError(t, fs.Chtimes("a/foo", time.Time{}, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)))
+
+	requireNoError(t, fs.Rename("a", "c"))
+	_, err := fs.Stat("a")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	for _, name := range []string{"foo", "b/bar", "b/baz"} {
+		data, err := hackpadfs.ReadFile(fs, "c/"+name)
+		assert.NoError(t, err)
+		assert.Equal(t, "a/"+name, string(data))
+	}
+	info, err := fs.Stat("c/foo")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), info.ModTime())
+	info, err = fs.Stat("c/b")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+}
+
+func TestPreconditions(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, newFakeServer(t), Options{})
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "bar", []byte("bar"), 0600))
+	fooInfo, err := fs.stat("foo")
+	requireNoError(t, err)
+	barInfo, err := fs.stat("bar")
+	requireNoError(t, err)
+
+	// 'bar' changes after it was checked, so the rename's copy must not replace it
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "bar", []byte("baz"), 0600))
+	err = fs.copyObject(fs.key("foo"), fs.key("bar"), fooInfo.generation, dstConditions(barInfo))
+	assert.Equal(t, true, isPreconditionFailed(err))
+	data, err := hackpadfs.ReadFile(fs, "bar")
+	requireNoError(t, err)
+	assert.Equal(t, "baz", string(data))
+
+	// an exclusive create loses to a file created after it was checked
+	ctx := context.Background()
+	w := fs.bucket.Object(fs.key("new")).If(storage.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "new", []byte("new"), 0600))
+	_, err = w.Write([]byte("lost"))
+	requireNoError(t, err)
+	assert.Equal(t, true, isPreconditionFailed(w.Close()))
+}
@@ -0,0 +1,109 @@
+package gcsfs
+
+import (
+	"path"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/hack-pad/hackpadfs"
+)
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    hackpadfs.FileMode
+	modTime time.Time
+
+	// generation and metageneration are 0 for implicit directories, which have no object
+	generation     int64
+	metageneration int64
+}
+
+// newFileInfo returns info for object 'attrs', using modes and modified times stored in its metadata
+func newFileInfo(name string, attrs *storage.ObjectAttrs, isDir bool) (*fileInfo, error) {
+	mode := hackpadfs.FileMode(defaultFileMode)
+	if isDir {
+		mode = defaultDirMode
+	}
+	if modeStr, ok := attrs.Metadata[modeMetadataKey]; ok {
+		modeInt, err := strconv.ParseUint(modeStr, octalSize, 32)
+		if err != nil {
+			return nil, err
+		}
+		mode = hackpadfs.FileMode(modeInt).Perm()
+	}
+	size := attrs.Size
+	if isDir {
+		mode |= hackpadfs.ModeDir
+		size = 0
+	}
+
+	modTime := attrs.Updated
+	if modTimeStr, ok := attrs.Metadata[modTimeMetadataKey]; ok {
+		var err error
+		modTime, err = time.Parse(modTimeFormat, modTimeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &fileInfo{
+		name:    path.Base(name),
+		size:    size,
+		mode:    mode,
+		modTime: modTime,
+
+		generation:     attrs.Generation,
+		metageneration: attrs.Metageneration,
+	}, nil
+}
+
+func (f *fileInfo) Name() string {
+	return f.name
+}
+
+func (f *fileInfo) Size() int64 {
+	return f.size
+}
+
+func (f *fileInfo) Mode() hackpadfs.FileMode {
+	return f.mode
+}
+
+func (f *fileInfo) ModTime() time.Time {
+	return f.modTime
+}
+
+func (f *fileInfo) IsDir() bool {
+	return f.mode.IsDir()
+}
+
+func (f *fileInfo) Sys() interface{} {
+	return nil
+}
+
+// dirEntry is a listed directory entry. Its info is only fetched when requested, since listings don't include metadata.
+type dirEntry struct {
+	fs    *FS
+	path  string
+	isDir bool
+}
+
+func (d *dirEntry) Name() string {
+	return path.Base(d.path)
+}
+
+func (d *dirEntry) IsDir() bool {
+	return d.isDir
+}
+
+func (d *dirEntry) Type() hackpadfs.FileMode {
+	if d.isDir {
+		return hackpadfs.ModeDir
+	}
+	return 0
+}
+
+func (d *dirEntry) Info() (hackpadfs.FileInfo, error) {
+	return d.fs.Stat(d.path)
+}
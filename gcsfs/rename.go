@@ -0,0 +1,89 @@
+package gcsfs
+
+import (
+	"errors"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	if !hackpadfs.ValidPath(oldname) || !hackpadfs.ValidPath(newname) || oldname == "." || newname == "." {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	if err := fs.rename(oldname, newname); err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+func (fs *FS) rename(oldname, newname string) error {
+	oldInfo, err := fs.stat(oldname)
+	if err != nil {
+		return err
+	}
+	if oldname == newname {
+		if oldInfo.IsDir() {
+			return hackpadfs.ErrExist
+		}
+		return nil
+	}
+	if oldInfo.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return hackpadfs.ErrInvalid
+	}
+
+	newInfo, err := fs.stat(newname)
+	switch {
+	case err == nil:
+		if newInfo.IsDir() {
+			return hackpadfs.ErrExist
+		}
+		if oldInfo.IsDir() {
+			return hackpadfs.ErrNotDir
+		}
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		if err := fs.checkParentDir(newname); err != nil {
+			return err
+		}
+		newInfo = nil
+	default:
+		return err
+	}
+
+	if !oldInfo.IsDir() {
+		oldKey := fs.key(oldname)
+		if err := fs.copyObject(oldKey, fs.key(newname), oldInfo.generation, dstConditions(newInfo)); err != nil {
+			return err
+		}
+		return fs.removeObject(oldKey, oldInfo.generation)
+	}
+	return fs.renameDir(oldname, newname)
+}
+
+// renameDir copies every object inside directory 'oldname' to 'newname', then removes the originals
+func (fs *FS) renameDir(oldname, newname string) error {
+	oldPrefix, newPrefix := fs.dirKey(oldname), fs.dirKey(newname)
+	generations := make(map[string]int64)
+	err := fs.walkPrefix(oldPrefix, func(objects []*storage.ObjectAttrs) error {
+		for _, object := range objects {
+			newKey := newPrefix + strings.TrimPrefix(object.Name, oldPrefix)
+			if err := fs.copyObject(object.Name, newKey, object.Generation, dstConditions(nil)); err != nil {
+				return err
+			}
+			generations[object.Name] = object.Generation
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// only remove the copied generations, leaving anything written since in place
+	for key, generation := range generations {
+		if err := fs.removeObject(key, generation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
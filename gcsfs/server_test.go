@@ -0,0 +1,408 @@
+package gcsfs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	raw "google.golang.org/api/storage/v1"
+)
+
+// fakeServer is an in-memory stand-in for the parts of the GCS JSON and XML APIs used by FS
+type fakeServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	objects    map[string]*fakeObject // keyed by bucket + "/" + name
+	uploads    map[string]*fakeUpload
+	generation int64
+}
+
+type fakeObject struct {
+	raw.Object
+	data []byte
+}
+
+type fakeUpload struct {
+	object *raw.Object
+	query  url.Values
+	data   []byte
+}
+
+func newFakeServer(tb testing.TB) *fakeServer {
+	tb.Helper()
+	s := &fakeServer{
+		objects: make(map[string]*fakeObject),
+		uploads: make(map[string]*fakeUpload),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+type fakeError struct {
+	code    int
+	message string
+}
+
+func (s *fakeServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp, err := s.route(w, r)
+	if err != nil {
+		writeJSON(w, err.code, map[string]interface{}{
+			"error": map[string]interface{}{"code": err.code, "message": err.message},
+		})
+		return
+	}
+	if resp != nil {
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// route serves 'r', returning a value to respond with as JSON, or nil if it already responded
+func (s *fakeServer) route(w http.ResponseWriter, r *http.Request) (interface{}, *fakeError) {
+	var segments []string
+	for _, segment := range strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, &fakeError{http.StatusBadRequest, err.Error()}
+		}
+		segments = append(segments, unescaped)
+	}
+	query := r.URL.Query()
+	switch {
+	case len(segments) == 5 && segments[0] == "storage" && segments[4] == "o" && r.Method == http.MethodGet:
+		return s.list(segments[3], query)
+	case len(segments) == 6 && segments[0] == "storage" && segments[4] == "o":
+		bucket, name := segments[3], segments[5]
+		switch r.Method {
+		case http.MethodGet:
+			object, err := s.get(bucket, name, query)
+			if err != nil {
+				return nil, err
+			}
+			return &object.Object, nil
+		case http.MethodDelete:
+			if _, err := s.get(bucket, name, query); err != nil {
+				return nil, err
+			}
+			delete(s.objects, bucket+"/"+name)
+			w.WriteHeader(http.StatusNoContent)
+			return nil, nil
+		case http.MethodPatch:
+			return s.patch(bucket, name, query, r.Body)
+		}
+	case len(segments) == 11 && segments[0] == "storage" && segments[6] == "rewriteTo" && r.Method == http.MethodPost:
+		return s.rewrite(segments[3], segments[5], segments[8], segments[10], query, r.Body)
+	case len(segments) == 6 && segments[0] == "upload" && segments[5] == "o":
+		switch {
+		case r.Method == http.MethodPost && query.Get("upload_id") != "":
+			return s.uploadChunk(w, r, query.Get("upload_id"))
+		case r.Method == http.MethodPost && query.Get("uploadType") == "multipart":
+			return s.uploadMultipart(segments[4], query, r)
+		case r.Method == http.MethodPost && query.Get("uploadType") == "resumable":
+			return s.startUpload(w, r, segments[4], query)
+		}
+	case len(segments) >= 2 && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		return nil, s.read(w, r, segments[0], strings.Join(segments[1:], "/"))
+	}
+	return nil, &fakeError{http.StatusNotImplemented, fmt.Sprintf("unsupported request: %s %s", r.Method, r.URL)}
+}
+
+// checkConditions returns an error if 'object' doesn't match the generation conditions in 'query'. A nil object has generation 0.
+func checkConditions(object *fakeObject, query url.Values, generationParam, metagenerationParam string) *fakeError {
+	var generation, metageneration int64
+	if object != nil {
+		generation, metageneration = object.Generation, object.Metageneration
+	}
+	if want := query.Get(generationParam); want != "" && want != strconv.FormatInt(generation, 10) {
+		return &fakeError{http.StatusPreconditionFailed, "generation condition not met"}
+	}
+	if want := query.Get(metagenerationParam); want != "" && want != strconv.FormatInt(metageneration, 10) {
+		return &fakeError{http.StatusPreconditionFailed, "metageneration condition not met"}
+	}
+	return nil
+}
+
+func (s *fakeServer) get(bucket, name string, query url.Values) (*fakeObject, *fakeError) {
+	object, ok := s.objects[bucket+"/"+name]
+	if !ok {
+		return nil, &fakeError{http.StatusNotFound, "no such object: " + name}
+	}
+	return object, checkConditions(object, query, "ifGenerationMatch", "ifMetagenerationMatch")
+}
+
+// put stores a new generation of an object, if the conditions in 'query' hold for the current one
+func (s *fakeServer) put(bucket string, attrs *raw.Object, data []byte, query url.Values) (*raw.Object, *fakeError) {
+	if attrs.Name == "" {
+		attrs.Name = query.Get("name")
+	}
+	key := bucket + "/" + attrs.Name
+	if err := checkConditions(s.objects[key], query, "ifGenerationMatch", "ifMetagenerationMatch"); err != nil {
+		return nil, err
+	}
+	s.generation++
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	object := &fakeObject{
+		Object: raw.Object{
+			Kind:           "storage#object",
+			Bucket:         bucket,
+			Name:           attrs.Name,
+			ContentType:    attrs.ContentType,
+			Metadata:       attrs.Metadata,
+			Size:           uint64(len(data)),
+			Generation:     s.generation,
+			Metageneration: 1,
+			TimeCreated:    now,
+			Updated:        now,
+		},
+		data: data,
+	}
+	s.objects[key] = object
+	return &object.Object, nil
+}
+
+func (s *fakeServer) list(bucket string, query url.Values) (interface{}, *fakeError) {
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxResults := 1000
+	if max := query.Get("maxResults"); max != "" {
+		maxResults, _ = strconv.Atoi(max)
+	}
+	// tokens hold the last listed name or prefix, since objects may be removed between pages
+	after, afterPrefix := "", false
+	if token := query.Get("pageToken"); token != "" {
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, &fakeError{http.StatusBadRequest, err.Error()}
+		}
+		after, afterPrefix = string(decoded[1:]), decoded[0] == 'p'
+	}
+
+	var names []string
+	for key := range s.objects {
+		if name := strings.TrimPrefix(key, bucket+"/"); name != key && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := &raw.Objects{Kind: "storage#objects"}
+	count, last := 0, ""
+	for _, name := range names {
+		if after != "" && (name <= after || (afterPrefix && strings.HasPrefix(name, after))) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				if count == maxResults {
+					result.NextPageToken = base64.StdEncoding.EncodeToString([]byte(last))
+					break
+				}
+				commonPrefix := name[:len(prefix)+i+len(delimiter)]
+				result.Prefixes = append(result.Prefixes, commonPrefix)
+				// skip the rest of the names inside this prefix
+				after, afterPrefix = commonPrefix, true
+				last = "p" + commonPrefix
+				count++
+				continue
+			}
+		}
+		if count == maxResults {
+			result.NextPageToken = base64.StdEncoding.EncodeToString([]byte(last))
+			break
+		}
+		object := s.objects[bucket+"/"+name].Object
+		result.Items = append(result.Items, &object)
+		last = "o" + name
+		count++
+	}
+	return result, nil
+}
+
+func (s *fakeServer) patch(bucket, name string, query url.Values, body io.Reader) (interface{}, *fakeError) {
+	object, err := s.get(bucket, name, query)
+	if err != nil {
+		return nil, err
+	}
+	var attrs raw.Object
+	if err := json.NewDecoder(body).Decode(&attrs); err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	if object.Metadata == nil {
+		object.Metadata = make(map[string]string)
+	}
+	for key, value := range attrs.Metadata {
+		if value == "" {
+			delete(object.Metadata, key)
+		} else {
+			object.Metadata[key] = value
+		}
+	}
+	object.Metageneration++
+	object.Updated = time.Now().UTC().Format(time.RFC3339Nano)
+	return &object.Object, nil
+}
+
+func (s *fakeServer) rewrite(srcBucket, srcName, dstBucket, dstName string, query url.Values, body io.Reader) (interface{}, *fakeError) {
+	src, ok := s.objects[srcBucket+"/"+srcName]
+	if !ok {
+		return nil, &fakeError{http.StatusNotFound, "no such object: " + srcName}
+	}
+	if err := checkConditions(src, query, "ifSourceGenerationMatch", "ifSourceMetagenerationMatch"); err != nil {
+		return nil, err
+	}
+	var attrs raw.Object
+	if err := json.NewDecoder(body).Decode(&attrs); err != nil && err != io.EOF {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	attrs.Name = dstName
+	if attrs.Metadata == nil {
+		attrs.Metadata = src.Metadata
+	}
+	if attrs.ContentType == "" {
+		attrs.ContentType = src.ContentType
+	}
+	object, err := s.put(dstBucket, &attrs, src.data, query)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(src.data))
+	return &raw.RewriteResponse{
+		Kind:                "storage#rewriteResponse",
+		Done:                true,
+		ObjectSize:          size,
+		TotalBytesRewritten: size,
+		Resource:            object,
+	}, nil
+}
+
+func (s *fakeServer) uploadMultipart(bucket string, query url.Values, r *http.Request) (interface{}, *fakeError) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	parts := multipart.NewReader(r.Body, params["boundary"])
+	metadataPart, err := parts.NextPart()
+	if err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	var attrs raw.Object
+	if err := json.NewDecoder(metadataPart).Decode(&attrs); err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	mediaPart, err := parts.NextPart()
+	if err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	data, err := io.ReadAll(mediaPart)
+	if err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	return s.put(bucket, &attrs, data, query)
+}
+
+func (s *fakeServer) startUpload(w http.ResponseWriter, r *http.Request, bucket string, query url.Values) (interface{}, *fakeError) {
+	var attrs raw.Object
+	if err := json.NewDecoder(r.Body).Decode(&attrs); err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	attrs.Bucket = bucket
+	uploadID := strconv.Itoa(len(s.uploads) + 1)
+	s.uploads[uploadID] = &fakeUpload{object: &attrs, query: query}
+	w.Header().Set("Location", fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=resumable&upload_id=%s", s.URL, url.PathEscape(bucket), uploadID))
+	w.WriteHeader(http.StatusOK)
+	return nil, nil
+}
+
+func (s *fakeServer) uploadChunk(w http.ResponseWriter, r *http.Request, uploadID string) (interface{}, *fakeError) {
+	upload, ok := s.uploads[uploadID]
+	if !ok {
+		return nil, &fakeError{http.StatusNotFound, "no such upload: " + uploadID}
+	}
+	chunk, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, &fakeError{http.StatusBadRequest, err.Error()}
+	}
+	// Content-Range is one of "bytes start-end/total", "bytes start-end/*", or "bytes */total"
+	contentRange := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+	byteRange, total, _ := strings.Cut(contentRange, "/")
+	if start, _, ok := strings.Cut(byteRange, "-"); ok && start != strconv.Itoa(len(upload.data)) {
+		return nil, &fakeError{http.StatusBadRequest, "unexpected chunk range: " + contentRange}
+	}
+	upload.data = append(upload.data, chunk...)
+	if total != "*" && total == strconv.Itoa(len(upload.data)) {
+		delete(s.uploads, uploadID)
+		return s.put(upload.object.Bucket, upload.object, upload.data, upload.query)
+	}
+	// the client asks for 308 Resume Incomplete responses to be sent as 200s
+	w.Header().Set("X-Http-Status-Code-Override", "308")
+	if len(upload.data) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(upload.data)-1))
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil, nil
+}
+
+// read serves object contents like the XML API, with optional byte ranges
+func (s *fakeServer) read(w http.ResponseWriter, r *http.Request, bucket, name string) *fakeError {
+	object, ok := s.objects[bucket+"/"+name]
+	if !ok {
+		return &fakeError{http.StatusNotFound, "no such object: " + name}
+	}
+	if generation := r.URL.Query().Get("generation"); generation != "" && generation != strconv.FormatInt(object.Generation, 10) {
+		return &fakeError{http.StatusNotFound, "no such generation: " + generation}
+	}
+	header := w.Header()
+	header.Set("X-Goog-Generation", strconv.FormatInt(object.Generation, 10))
+	header.Set("X-Goog-Metageneration", strconv.FormatInt(object.Metageneration, 10))
+	if updated, err := time.Parse(time.RFC3339Nano, object.Updated); err == nil {
+		header.Set("Last-Modified", updated.Format(http.TimeFormat))
+	}
+
+	data, status := object.data, http.StatusOK
+	if byteRange := r.Header.Get("Range"); byteRange != "" {
+		startStr, endStr, _ := strings.Cut(strings.TrimPrefix(byteRange, "bytes="), "-")
+		start, err := strconv.Atoi(startStr)
+		if err != nil || start >= len(data) {
+			return &fakeError{http.StatusRequestedRangeNotSatisfiable, "invalid range: " + byteRange}
+		}
+		end := len(data) - 1
+		if endStr != "" {
+			end, err = strconv.Atoi(endStr)
+			if err != nil {
+				return &fakeError{http.StatusBadRequest, "invalid range: " + byteRange}
+			}
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+		}
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data, status = data[start:end+1], http.StatusPartialContent
+	}
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_, _ = io.Copy(w, bytes.NewReader(data))
+	}
+	return nil
+}
@@ -5,4 +5,6 @@ go 1.18
 require (
 	github.com/hack-pad/go-indexeddb v0.3.2
 	github.com/hack-pad/safejs v0.1.0
+	github.com/klauspost/compress v1.15.15
+	golang.org/x/text v0.6.0
 )
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+// file captures its prior version before each change
+type file struct {
+	hackpadfs.File
+	fs   *FS
+	name string
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	return f.File.Read(p)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (n int, err error) {
+	err = f.fs.change(func() error {
+		n, err = hackpadfs.WriteFile(f.File, p)
+		return err
+	}, f.name)
+	return n, err
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (n int, err error) {
+	err = f.fs.change(func() error {
+		n, err = hackpadfs.WriteAtFile(f.File, p, off)
+		return err
+	}, f.name)
+	return n, err
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	return f.fs.change(func() error {
+		return hackpadfs.TruncateFile(f.File, size)
+	}, f.name)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return f.fs.change(func() error {
+		return hackpadfs.ChmodFile(f.File, mode)
+	}, f.name)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	return f.fs.change(func() error {
+		return hackpadfs.ChownFile(f.File, uid, gid)
+	}, f.name)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return f.fs.change(func() error {
+		return hackpadfs.ChtimesFile(f.File, atime, mtime)
+	}, f.name)
+}
@@ -0,0 +1,234 @@
+// Package history contains a file system wrapper which keeps prior versions of files, so they can be read as of an earlier snapshot.
+package history
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+)
+
+// Options configures an FS
+type Options struct {
+	// MaxSnapshots is the number of snapshots to keep. The oldest snapshots are removed when a new snapshot is taken. Unlimited if zero.
+	MaxSnapshots int
+	// MaxAge is the age of the oldest snapshot to keep. Older snapshots are removed when a new snapshot is taken. Unlimited if zero.
+	MaxAge time.Duration
+}
+
+// FS wraps a file system to keep prior versions of files, which can be read as of any retained snapshot with At().
+//
+// Call Snapshot() to mark a point in time, like before each undoable user action.
+// The first change to a path after each snapshot copies the path's prior state into a separate 'versions' FS, so unchanged files cost nothing to keep.
+// Changes include writes, removes, renames, and metadata changes. Removing or renaming a directory keeps versions of everything inside it.
+//
+// Versions are tracked by path, so changes written through a symlink or hard link are only kept for the path used to write them.
+// The index of versions is held in memory, so history lasts as long as the FS. The wrapped FS should only be modified through the FS.
+type FS struct {
+	fs       hackpadfs.FS
+	versions hackpadfs.FS
+	options  Options
+	now      func() time.Time
+
+	snapshotLock sync.RWMutex // held for writing to take a snapshot, held for reading to capture and then make a change
+	mu           sync.Mutex   // guards snapshots and versions files
+	snapshots    []*snapshot  // oldest first
+	nextID       SnapshotID
+	nextData     uint64
+}
+
+// NewFS returns an FS which keeps prior versions of 'fs' files in 'versions', limited by 'options'.
+// The versions FS should be empty, and is only modified by the FS.
+func NewFS(fs, versions hackpadfs.FS, options Options) (*FS, error) {
+	if options.MaxSnapshots < 0 || options.MaxAge < 0 {
+		return nil, errors.New("MaxSnapshots and MaxAge must not be negative")
+	}
+	return &FS{
+		fs:       fs,
+		versions: versions,
+		options:  options,
+		now:      time.Now,
+		nextID:   1,
+	}, nil
+}
+
+// change captures the prior state of 'names', then runs 'fn' to change them
+func (fs *FS) change(fn func() error, names ...string) error {
+	fs.snapshotLock.RLock()
+	defer fs.snapshotLock.RUnlock()
+	if err := fs.capture(false, names...); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// changeAll is like change, but also captures the contents of directories
+func (fs *FS) changeAll(fn func() error, names ...string) error {
+	fs.snapshotLock.RLock()
+	defer fs.snapshotLock.RUnlock()
+	if err := fs.capture(true, names...); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	var f hackpadfs.File
+	open := func() error {
+		var err error
+		f, err = hackpadfs.OpenFile(fs.fs, name, flag, perm)
+		return err
+	}
+	var err error
+	if flag&(hackpadfs.FlagCreate|hackpadfs.FlagTruncate) != 0 {
+		err = fs.change(open, name)
+	} else {
+		err = open()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fs: fs, name: name}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.change(func() error {
+		return hackpadfs.Mkdir(fs.fs, name, perm)
+	}, name)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.change(func() error {
+		return hackpadfs.MkdirAll(fs.fs, path, perm)
+	}, parentDirs(path)...)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return fs.change(func() error {
+		return hackpadfs.Remove(fs.fs, name)
+	}, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	return fs.changeAll(func() error {
+		return hackpadfs.RemoveAll(fs.fs, path)
+	}, path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	return fs.changeAll(func() error {
+		return hackpadfs.Rename(fs.fs, oldname, newname)
+	}, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.fs, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Lstat(fs.fs, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.change(func() error {
+		return hackpadfs.Chmod(fs.fs, name, mode)
+	}, name)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return fs.change(func() error {
+		return hackpadfs.Chown(fs.fs, name, uid, gid)
+	}, name)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.change(func() error {
+		return hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+	}, name)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.fs, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	return hackpadfs.ReadFile(fs.fs, name)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return fs.change(func() error {
+		return hackpadfs.Symlink(fs.fs, oldname, newname)
+	}, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	return fs.change(func() error {
+		return hackpadfs.Link(fs.fs, oldname, newname)
+	}, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.Statfs(fs.fs, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	return hackpadfs.Watch(fs.fs, name)
+}
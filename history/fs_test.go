@@ -0,0 +1,281 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+func newMemFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	requireNoError(tb, err)
+	return fs
+}
+
+func newFS(tb testing.TB, options Options) *FS {
+	tb.Helper()
+	fs, err := NewFS(newMemFS(tb), newMemFS(tb), options)
+	requireNoError(tb, err)
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	for _, snapshot := range []bool{false, true} {
+		snapshot := snapshot
+		name := "history"
+		if snapshot {
+			name = "history with snapshot"
+		}
+		options := fstest.FSOptions{
+			Name: name,
+			Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+				fs := newFS(tb, Options{})
+				return fs, func() hackpadfs.FS {
+					if snapshot {
+						_, err := fs.Snapshot()
+						requireNoError(tb, err)
+					}
+					return fs
+				}
+			}),
+			Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func TestSnapshotFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "history snapshot",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			fs := newFS(tb, Options{})
+			return fs, func() hackpadfs.FS {
+				id, err := fs.Snapshot()
+				requireNoError(tb, err)
+				snapshotFS, err := fs.At(id)
+				requireNoError(tb, err)
+				// changes after the snapshot shouldn't be visible
+				requireNoError(tb, hackpadfs.RemoveAll(fs, "."))
+				return snapshotFS
+			}
+		}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestNewFS(t *testing.T) {
+	t.Parallel()
+	_, err := NewFS(newMemFS(t), newMemFS(t), Options{MaxSnapshots: -1})
+	assert.Error(t, err)
+	_, err = NewFS(newMemFS(t), newMemFS(t), Options{MaxAge: -time.Second})
+	assert.Error(t, err)
+}
+
+func TestAt(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file contents", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("v1"), 0600))
+		id1, err := fs.Snapshot()
+		requireNoError(t, err)
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("v2"), 0600))
+		id2, err := fs.Snapshot()
+		requireNoError(t, err)
+		f, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+		requireNoError(t, err)
+		_, err = hackpadfs.WriteFile(f, []byte("+v3"))
+		requireNoError(t, err)
+		requireNoError(t, f.Close())
+
+		at1, err := fs.At(id1)
+		requireNoError(t, err)
+		contents, err := hackpadfs.ReadFile(at1, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", string(contents))
+		info, err := hackpadfs.Stat(at1, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), info.Size())
+
+		at2, err := fs.At(id2)
+		requireNoError(t, err)
+		contents, err = hackpadfs.ReadFile(at2, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", string(contents))
+
+		contents, err = hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2+v3", string(contents))
+	})
+
+	t.Run("unchanged file", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+		id, err := fs.Snapshot()
+		requireNoError(t, err)
+
+		at, err := fs.At(id)
+		requireNoError(t, err)
+		contents, err := hackpadfs.ReadFile(at, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", string(contents))
+		entries, err := hackpadfs.ReadDir(fs.versions, ".")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("removed and created files", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.MkdirAll(fs, "dir/sub", 0700))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dir/sub/old", []byte("old"), 0600))
+		id, err := fs.Snapshot()
+		requireNoError(t, err)
+		requireNoError(t, hackpadfs.RemoveAll(fs, "dir"))
+		requireNoError(t, hackpadfs.MkdirAll(fs, "dir", 0700))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dir/new", []byte("new"), 0600))
+
+		at, err := fs.At(id)
+		requireNoError(t, err)
+		contents, err := hackpadfs.ReadFile(at, "dir/sub/old")
+		assert.NoError(t, err)
+		assert.Equal(t, "old", string(contents))
+		_, err = hackpadfs.Stat(at, "dir/new")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+		entries, err := hackpadfs.ReadDir(at, "dir")
+		assert.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"sub"}, names)
+	})
+
+	t.Run("renamed directory", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.Mkdir(fs, "src", 0700))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "src/file", []byte("file"), 0600))
+		id, err := fs.Snapshot()
+		requireNoError(t, err)
+		requireNoError(t, hackpadfs.Rename(fs, "src", "dest"))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dest/file", []byte("changed"), 0600))
+
+		at, err := fs.At(id)
+		requireNoError(t, err)
+		contents, err := hackpadfs.ReadFile(at, "src/file")
+		assert.NoError(t, err)
+		assert.Equal(t, "file", string(contents))
+		_, err = hackpadfs.Stat(at, "dest")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("symlink", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "a", []byte("a"), 0600))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "b", []byte("b"), 0600))
+		requireNoError(t, hackpadfs.Symlink(fs, "a", "link"))
+		id, err := fs.Snapshot()
+		requireNoError(t, err)
+		requireNoError(t, hackpadfs.Remove(fs, "link"))
+		requireNoError(t, hackpadfs.Symlink(fs, "b", "link"))
+
+		at, err := fs.At(id)
+		requireNoError(t, err)
+		target, err := hackpadfs.Readlink(at, "link")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", target)
+		contents, err := hackpadfs.ReadFile(at, "link")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", string(contents))
+	})
+
+	t.Run("missing snapshot", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{})
+		_, err := fs.At(1)
+		assert.ErrorIs(t, ErrNoSnapshot, err)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+}
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+
+	t.Run("max snapshots", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{MaxSnapshots: 2})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("v1"), 0600))
+		id1, err := fs.Snapshot()
+		requireNoError(t, err)
+		at1, err := fs.At(id1)
+		requireNoError(t, err)
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("v2"), 0600))
+		id2, err := fs.Snapshot()
+		requireNoError(t, err)
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("v3"), 0600))
+		id3, err := fs.Snapshot()
+		requireNoError(t, err)
+
+		infos := fs.Snapshots()
+		assert.Equal(t, 2, len(infos))
+		assert.Equal(t, id2, infos[0].ID)
+		assert.Equal(t, id3, infos[1].ID)
+		_, err = fs.At(id1)
+		assert.ErrorIs(t, ErrNoSnapshot, err)
+		_, err = hackpadfs.ReadFile(at1, "foo")
+		assert.ErrorIs(t, ErrNoSnapshot, err)
+		_, err = hackpadfs.Stat(fs.versions, "1")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+		at2, err := fs.At(id2)
+		requireNoError(t, err)
+		contents, err := hackpadfs.ReadFile(at2, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", string(contents))
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, Options{MaxAge: time.Hour})
+		now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		fs.now = func() time.Time { return now }
+		id1, err := fs.Snapshot()
+		requireNoError(t, err)
+		now = now.Add(30 * time.Minute)
+		id2, err := fs.Snapshot()
+		requireNoError(t, err)
+		now = now.Add(2 * time.Hour)
+		id3, err := fs.Snapshot()
+		requireNoError(t, err)
+
+		assert.Equal(t, []SnapshotInfo{
+			{ID: id3, Time: now},
+		}, fs.Snapshots())
+		_, err = fs.At(id1)
+		assert.ErrorIs(t, ErrNoSnapshot, err)
+		_, err = fs.At(id2)
+		assert.ErrorIs(t, ErrNoSnapshot, err)
+	})
+}
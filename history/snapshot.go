@@ -0,0 +1,285 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ErrNoSnapshot is returned when reading from a snapshot which was never taken, or was removed by Options.MaxSnapshots or Options.MaxAge
+var ErrNoSnapshot = fmt.Errorf("%w: snapshot not found", hackpadfs.ErrNotExist)
+
+// SnapshotID identifies a snapshot. Each snapshot's ID is greater than the last.
+type SnapshotID uint64
+
+// SnapshotInfo describes a retained snapshot
+type SnapshotInfo struct {
+	ID   SnapshotID
+	Time time.Time
+}
+
+type snapshot struct {
+	id       SnapshotID
+	time     time.Time
+	records  map[string]*record         // prior states of paths changed after this snapshot, until the next snapshot
+	children map[string]map[string]bool // names in 'records', by parent directory
+	hasData  bool                       // true if the versions FS has a directory for this snapshot
+}
+
+func (s *snapshot) dataDir() string {
+	return strconv.FormatUint(uint64(s.id), 10)
+}
+
+func (s *snapshot) add(name string, r *record) {
+	s.records[name] = r
+	if name == "." {
+		return
+	}
+	dir := path.Dir(name)
+	if s.children[dir] == nil {
+		s.children[dir] = make(map[string]bool)
+	}
+	s.children[dir][path.Base(name)] = true
+}
+
+// record is the state of a path when a snapshot was taken
+type record struct {
+	exists bool
+	info   *fileInfo
+	target string // symlink target
+	data   string // versions FS path of a regular file's contents
+}
+
+// Snapshot records the current state of the FS, returning an ID to read it with At().
+// Removes any snapshots past Options.MaxSnapshots or Options.MaxAge.
+func (fs *FS) Snapshot() (SnapshotID, error) {
+	fs.snapshotLock.Lock()
+	defer fs.snapshotLock.Unlock()
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	s := &snapshot{
+		id:       fs.nextID,
+		time:     fs.now(),
+		records:  make(map[string]*record),
+		children: make(map[string]map[string]bool),
+	}
+	fs.nextID++
+	fs.snapshots = append(fs.snapshots, s)
+	return s.id, fs.pruneLocked()
+}
+
+// Snapshots returns the retained snapshots, oldest first
+func (fs *FS) Snapshots() []SnapshotInfo {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	infos := make([]SnapshotInfo, 0, len(fs.snapshots))
+	for _, s := range fs.snapshots {
+		infos = append(infos, SnapshotInfo{ID: s.id, Time: s.time})
+	}
+	return infos
+}
+
+// pruneLocked removes the oldest snapshots past Options.MaxSnapshots or Options.MaxAge. Always keeps the newest snapshot.
+func (fs *FS) pruneLocked() error {
+	now := fs.now()
+	var err error
+	for len(fs.snapshots) > 1 {
+		oldest := fs.snapshots[0]
+		tooMany := fs.options.MaxSnapshots > 0 && len(fs.snapshots) > fs.options.MaxSnapshots
+		tooOld := fs.options.MaxAge > 0 && now.Sub(oldest.time) > fs.options.MaxAge
+		if !tooMany && !tooOld {
+			break
+		}
+		fs.snapshots = fs.snapshots[1:]
+		if oldest.hasData {
+			if removeErr := hackpadfs.RemoveAll(fs.versions, oldest.dataDir()); removeErr != nil && err == nil {
+				err = removeErr
+			}
+		}
+	}
+	return err
+}
+
+func (fs *FS) findSnapshotLocked(id SnapshotID) *snapshot {
+	for _, s := range fs.snapshots {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// capture records the current state of 'names' in the latest snapshot, if not already recorded since then.
+// If 'all' is set, also records everything inside directories.
+// Callers must hold snapshotLock for reading until they finish changing 'names'.
+func (fs *FS) capture(all bool, names ...string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if len(fs.snapshots) == 0 {
+		return nil
+	}
+	latest := fs.snapshots[len(fs.snapshots)-1]
+	for _, name := range names {
+		if err := fs.captureLocked(latest, name, all); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *FS) captureLocked(s *snapshot, name string, all bool) error {
+	if !hackpadfs.ValidPath(name) {
+		// invalid paths fail the change
+		return nil
+	}
+	if _, captured := s.records[name]; !captured {
+		r, err := fs.readRecordLocked(s, name)
+		if err != nil {
+			return err
+		}
+		s.add(name, r)
+	}
+	if !all {
+		return nil
+	}
+	info, err := hackpadfs.LstatOrStat(fs.fs, name)
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+	entries, err := hackpadfs.ReadDir(fs.fs, name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := fs.captureLocked(s, path.Join(name, entry.Name()), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRecordLocked returns the current state of 'name', copying file contents into the versions FS
+func (fs *FS) readRecordLocked(s *snapshot, name string) (*record, error) {
+	info, err := hackpadfs.LstatOrStat(fs.fs, name)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist) || errors.Is(err, hackpadfs.ErrNotDir):
+		return &record{}, nil
+	case err != nil:
+		return nil, err
+	}
+	r := &record{
+		exists: true,
+		info:   newFileInfo(info, path.Base(name)),
+	}
+	switch {
+	case info.Mode()&hackpadfs.ModeSymlink != 0:
+		r.target, err = hackpadfs.Readlink(fs.fs, name)
+	case info.Mode().IsRegular():
+		r.data, err = fs.copyDataLocked(s, name)
+	}
+	return r, err
+}
+
+func (fs *FS) copyDataLocked(s *snapshot, name string) (string, error) {
+	if !s.hasData {
+		if err := hackpadfs.MkdirAll(fs.versions, s.dataDir(), 0700); err != nil {
+			return "", err
+		}
+		s.hasData = true
+	}
+	dataName := path.Join(s.dataDir(), strconv.FormatUint(fs.nextData, 10))
+	fs.nextData++
+
+	src, err := fs.fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = src.Close() }()
+	dest, err := hackpadfs.OpenFile(fs.versions, dataName, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0600)
+	if err != nil {
+		return "", err
+	}
+	destWriter, ok := dest.(io.Writer)
+	if !ok {
+		_ = dest.Close()
+		return "", &hackpadfs.PathError{Op: "write", Path: dataName, Err: hackpadfs.ErrNotImplemented}
+	}
+	_, err = io.Copy(destWriter, src)
+	closeErr := dest.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return dataName, err
+}
+
+// recordAtLocked returns the state of 'name' as of snapshot 'id', or nil if 'name' is unchanged since then
+func (fs *FS) recordAtLocked(id SnapshotID, name string) *record {
+	for _, s := range fs.snapshots {
+		if s.id < id {
+			continue
+		}
+		if r, ok := s.records[name]; ok {
+			return r
+		}
+	}
+	return nil
+}
+
+// parentDirs returns 'name' and each of its parent directories, top-most first
+func parentDirs(name string) []string {
+	var dirs []string
+	for i := range name {
+		if name[i] == '/' {
+			dirs = append(dirs, name[:i])
+		}
+	}
+	return append(dirs, strings.TrimSuffix(name, "/"))
+}
+
+// fileInfo is a snapshot of a file's info
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    hackpadfs.FileMode
+	modTime time.Time
+}
+
+func newFileInfo(info hackpadfs.FileInfo, name string) *fileInfo {
+	return &fileInfo{
+		name:    name,
+		size:    info.Size(),
+		mode:    info.Mode(),
+		modTime: info.ModTime(),
+	}
+}
+
+func (i *fileInfo) Name() string {
+	return i.name
+}
+
+func (i *fileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *fileInfo) Mode() hackpadfs.FileMode {
+	return i.mode
+}
+
+func (i *fileInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.mode.IsDir()
+}
+
+func (i *fileInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,402 @@
+package history
+
+import (
+	"errors"
+	"io"
+	gofs "io/fs"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const maxSymlinkHops = 40 // same limit as Linux's MAXSYMLINKS
+
+var errTooManyLinks = syscall.ELOOP
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.ReadlinkFS
+	} = &SnapshotFS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReaderAtFile
+		hackpadfs.SeekerFile
+	} = &readFile{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.DirReaderFile
+	} = &dir{}
+)
+
+// SnapshotFS is a read-only view of an FS as of a snapshot. See FS.At().
+type SnapshotFS struct {
+	fs *FS
+	id SnapshotID
+}
+
+// At returns a read-only view of the FS as of snapshot 'id'.
+// Reads fail with ErrNoSnapshot once the snapshot is removed.
+//
+// Symlinks are resolved as of the snapshot. Files unchanged since the snapshot are read from the FS, so already open files may see later changes.
+func (fs *FS) At(id SnapshotID) (*SnapshotFS, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.findSnapshotLocked(id) == nil {
+		return nil, &hackpadfs.PathError{Op: "at", Path: ".", Err: ErrNoSnapshot}
+	}
+	return &SnapshotFS{fs: fs, id: id}, nil
+}
+
+// ID returns the snapshot's ID
+func (s *SnapshotFS) ID() SnapshotID {
+	return s.id
+}
+
+// state is the state of a path in a snapshot
+type state struct {
+	name   string // resolved path
+	info   hackpadfs.FileInfo
+	record *record // nil if unchanged since the snapshot
+}
+
+// lookup returns the state of 'name' as of the snapshot, following symlinks in parent directories.
+// If 'followLast' is set, also follows symlinks in the final path element.
+func (s *SnapshotFS) lookup(op, name string, followLast bool) (state, error) {
+	if !hackpadfs.ValidPath(name) {
+		return state{}, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	st, err := s.lookupPath(".")
+	if err != nil {
+		return state{}, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	var remaining []string
+	if name != "." {
+		remaining = strings.Split(name, "/")
+	}
+	dir := "."
+	for hops := 0; len(remaining) > 0; {
+		elem := remaining[0]
+		remaining = remaining[1:]
+		switch elem {
+		case ".":
+			continue
+		case "..":
+			dir = path.Dir(dir)
+			continue
+		}
+		st, err = s.lookupPath(path.Join(dir, elem))
+		if err != nil {
+			return state{}, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+		}
+		isLast := len(remaining) == 0
+		if st.info.Mode()&hackpadfs.ModeSymlink != 0 && (!isLast || followLast) {
+			hops++
+			if hops > maxSymlinkHops {
+				return state{}, &hackpadfs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+			}
+			target, err := s.readlink(st)
+			if err != nil {
+				return state{}, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+			}
+			if path.IsAbs(target) {
+				dir = "."
+			}
+			remaining = append(strings.Split(strings.Trim(target, "/"), "/"), remaining...)
+			continue
+		}
+		if !isLast && !st.info.IsDir() {
+			return state{}, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotDir}
+		}
+		dir = st.name
+	}
+	if dir != st.name {
+		// ended on "." or ".." elements
+		st, err = s.lookupPath(dir)
+		if err != nil {
+			return state{}, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+		}
+	}
+	return st, nil
+}
+
+// lookupPath returns the state of 'name' as of the snapshot, without following any symlinks. 'name' must be a resolved path.
+func (s *SnapshotFS) lookupPath(name string) (state, error) {
+	s.fs.mu.Lock()
+	if s.fs.findSnapshotLocked(s.id) == nil {
+		s.fs.mu.Unlock()
+		return state{}, ErrNoSnapshot
+	}
+	r := s.fs.recordAtLocked(s.id, name)
+	s.fs.mu.Unlock()
+
+	switch {
+	case r == nil:
+		info, err := hackpadfs.LstatOrStat(s.fs.fs, name)
+		if err != nil {
+			var pathErr *hackpadfs.PathError
+			if errors.As(err, &pathErr) {
+				err = pathErr.Err
+			}
+			return state{}, err
+		}
+		return state{name: name, info: info}, nil
+	case !r.exists:
+		return state{}, hackpadfs.ErrNotExist
+	default:
+		return state{name: name, info: r.info, record: r}, nil
+	}
+}
+
+func (s *SnapshotFS) readlink(st state) (string, error) {
+	if st.record != nil {
+		return st.record.target, nil
+	}
+	target, err := hackpadfs.Readlink(s.fs.fs, st.name)
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return target, err
+}
+
+// Open implements hackpadfs.FS
+func (s *SnapshotFS) Open(name string) (hackpadfs.File, error) {
+	st, err := s.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case st.info.IsDir():
+		return &dir{fs: s, name: st.name, info: st.info}, nil
+	case st.record == nil:
+		f, err := s.fs.fs.Open(st.name)
+		if err != nil {
+			return nil, err
+		}
+		return &readFile{file: f, name: name, info: st.info}, nil
+	}
+	f, err := s.fs.versions.Open(st.record.data)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: errors.Unwrap(err)}
+	}
+	return &readFile{file: f, name: name, info: st.info}, nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (s *SnapshotFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	st, err := s.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if path.Base(name) != st.info.Name() && name != "." {
+		return &namedFileInfo{FileInfo: st.info, name: path.Base(name)}, nil
+	}
+	return st.info, nil
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (s *SnapshotFS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	st, err := s.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return st.info, nil
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (s *SnapshotFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	st, err := s.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !st.info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+	entries, err := s.readDir(st.name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+func (s *SnapshotFS) readDir(dir string) ([]hackpadfs.DirEntry, error) {
+	names := make(map[string]bool)
+	if info, err := hackpadfs.LstatOrStat(s.fs.fs, dir); err == nil && info.IsDir() {
+		entries, err := hackpadfs.ReadDir(s.fs.fs, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			names[entry.Name()] = true
+		}
+	}
+	s.fs.mu.Lock()
+	for _, snapshot := range s.fs.snapshots {
+		if snapshot.id >= s.id {
+			for name := range snapshot.children[dir] {
+				names[name] = true
+			}
+		}
+	}
+	s.fs.mu.Unlock()
+
+	var entries []hackpadfs.DirEntry
+	for name := range names {
+		st, err := s.lookupPath(path.Join(dir, name))
+		switch {
+		case errors.Is(err, hackpadfs.ErrNotExist) && !errors.Is(err, ErrNoSnapshot):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		entries = append(entries, gofs.FileInfoToDirEntry(st.info))
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+	return entries, nil
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (s *SnapshotFS) ReadFile(name string) ([]byte, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return io.ReadAll(f)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (s *SnapshotFS) Readlink(name string) (string, error) {
+	st, err := s.lookup("readlink", name, false)
+	switch {
+	case err != nil:
+		return "", err
+	case st.info.Mode()&hackpadfs.ModeSymlink == 0:
+		return "", &hackpadfs.PathError{Op: "readlink", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	target, err := s.readlink(st)
+	if err != nil {
+		return "", &hackpadfs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return target, nil
+}
+
+// readFile reads a file's contents from a snapshot, hiding any ways to change it
+type readFile struct {
+	file hackpadfs.File
+	name string
+	info hackpadfs.FileInfo
+}
+
+// withName replaces the versions FS path in errors with the file's name
+func (f *readFile) withName(err error) error {
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		return &hackpadfs.PathError{Op: pathErr.Op, Path: f.name, Err: pathErr.Err}
+	}
+	return err
+}
+
+func (f *readFile) Stat() (hackpadfs.FileInfo, error) {
+	if _, err := f.file.Stat(); err != nil {
+		return nil, f.withName(err)
+	}
+	return f.info, nil
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	n, err := f.file.Read(p)
+	return n, f.withName(err)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *readFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := hackpadfs.ReadAtFile(f.file, p, off)
+	return n, f.withName(err)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := hackpadfs.SeekFile(f.file, offset, whence)
+	return n, f.withName(err)
+}
+
+func (f *readFile) Close() error {
+	return f.withName(f.file.Close())
+}
+
+// dir lists a directory's entries in a snapshot
+type dir struct {
+	fs      *SnapshotFS
+	name    string
+	info    hackpadfs.FileInfo
+	entries []hackpadfs.DirEntry // nil until first ReadDir
+	offset  int
+	closed  bool
+}
+
+func (d *dir) Stat() (hackpadfs.FileInfo, error) {
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	return d.info, nil
+}
+
+func (d *dir) Read(p []byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.name, Err: hackpadfs.ErrIsDir}
+}
+
+func (d *dir) Close() error {
+	if d.closed {
+		return &hackpadfs.PathError{Op: "close", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (d *dir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	if d.entries == nil {
+		entries, err := d.fs.readDir(d.name)
+		if err != nil {
+			return nil, &hackpadfs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries = append(make([]hackpadfs.DirEntry, 0, len(entries)), entries...)
+	}
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
+
+// namedFileInfo reports the name of a symlink, instead of its target
+type namedFileInfo struct {
+	hackpadfs.FileInfo
+	name string
+}
+
+func (i *namedFileInfo) Name() string {
+	return i.name
+}
@@ -0,0 +1,222 @@
+package httpfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReaderAtFile
+		hackpadfs.SeekerFile
+	} = &file{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.DirReaderFile
+	} = &dir{}
+)
+
+// file reads a file with ranged GETs, keeping the last block read
+type file struct {
+	fs   *FS
+	name string
+	info *fileInfo
+
+	mu       sync.Mutex
+	offset   int64
+	block    []byte
+	blockOff int64
+	closed   bool
+}
+
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	return f.info, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt("read", p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	return f.readAt("readat", p, off)
+}
+
+func (f *file) readAt(op string, p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: op, Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n := 0
+	for n < len(p) {
+		blockOff := off + int64(n)
+		if blockOff < f.blockOff || blockOff >= f.blockOff+int64(len(f.block)) {
+			if f.info.size >= 0 && blockOff >= f.info.size {
+				return n, io.EOF
+			}
+			if err := f.fetch(blockOff, len(p)-n); err != nil {
+				return n, &hackpadfs.PathError{Op: op, Path: f.name, Err: err}
+			}
+			if len(f.block) == 0 {
+				return n, io.EOF
+			}
+		}
+		n += copy(p[n:], f.block[blockOff-f.blockOff:])
+	}
+	return n, nil
+}
+
+// fetch replaces the current block with at least 'size' bytes starting at 'off', or up to the end of the file
+func (f *file) fetch(off int64, size int) error {
+	if size < f.fs.options.BlockSize {
+		size = f.fs.options.BlockSize
+	}
+	if f.info.size >= 0 && off+int64(size) > f.info.size {
+		size = int(f.info.size - off)
+	}
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(size)-1))
+	resp, err := f.fs.do(http.MethodGet, f.fs.url(f.name), header)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch resp.StatusCode {
+	case http.StatusRequestedRangeNotSatisfiable:
+		f.block, f.blockOff = nil, off
+		return nil
+	case http.StatusPartialContent:
+	default:
+		// the server ignored the range, so skip to the offset
+		if _, err := io.CopyN(io.Discard, resp.Body, off); err != nil {
+			if err == io.EOF {
+				f.block, f.blockOff = nil, off
+				return nil
+			}
+			return err
+		}
+	}
+	block := make([]byte, size)
+	n, err := io.ReadFull(resp.Body, block)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	f.block, f.blockOff = block[:n], off
+	return err
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	newOffset := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		newOffset += f.offset
+	case io.SeekEnd:
+		newOffset += f.info.size
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if newOffset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.offset = newOffset
+	return newOffset, nil
+}
+
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &hackpadfs.PathError{Op: "close", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	f.closed = true
+	f.block = nil
+	return nil
+}
+
+// dir lists a directory's entries when first read
+type dir struct {
+	fs      *FS
+	name    string
+	info    *fileInfo
+	entries []hackpadfs.DirEntry // nil until first ReadDir
+	offset  int
+	closed  bool
+}
+
+func (d *dir) Stat() (hackpadfs.FileInfo, error) {
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	return d.info, nil
+}
+
+func (d *dir) Read(p []byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.name, Err: hackpadfs.ErrIsDir}
+}
+
+func (d *dir) Close() error {
+	if d.closed {
+		return &hackpadfs.PathError{Op: "close", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (d *dir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	if d.entries == nil {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = append(make([]hackpadfs.DirEntry, 0, len(entries)), entries...)
+	}
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
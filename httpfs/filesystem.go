@@ -0,0 +1,97 @@
+package httpfs
+
+import (
+	"errors"
+	"io"
+	gofs "io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// NewFileSystem returns an http.FileSystem of 'fs', for use with http.FileServer.
+// Unlike http.FS, open files seek with ReadAt when they can't Seek, so byte ranges keep working.
+// Prefer Handler to serve ETags as well.
+func NewFileSystem(fs hackpadfs.FS) http.FileSystem {
+	return &fileSystem{fs: fs}
+}
+
+type fileSystem struct {
+	fs hackpadfs.FS
+}
+
+func (fs *fileSystem) Open(name string) (http.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &httpFile{File: f, name: name}, nil
+}
+
+// httpFile adapts a File to an http.File
+type httpFile struct {
+	hackpadfs.File
+	name   string
+	offset int64 // read offset for files without Seek
+}
+
+func (f *httpFile) Read(p []byte) (int, error) {
+	readerAt, isReaderAt := f.File.(io.ReaderAt)
+	if _, isSeeker := f.File.(io.Seeker); isSeeker || !isReaderAt {
+		return f.File.Read(p)
+	}
+	n, err := readerAt.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *httpFile) Seek(offset int64, whence int) (int64, error) {
+	n, err := hackpadfs.SeekFile(f.File, offset, whence)
+	if !errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return n, err
+	}
+	if _, ok := f.File.(io.ReaderAt); !ok {
+		return n, err
+	}
+	newOffset := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		newOffset += f.offset
+	case io.SeekEnd:
+		info, err := f.File.Stat()
+		if err != nil {
+			return 0, err
+		}
+		newOffset += info.Size()
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if newOffset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.offset = newOffset
+	return newOffset, nil
+}
+
+func (f *httpFile) Readdir(count int) ([]gofs.FileInfo, error) {
+	entries, err := hackpadfs.ReadDirFile(f.File, count)
+	infos := make([]gofs.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			return infos, infoErr
+		}
+		infos = append(infos, info)
+	}
+	return infos, err
+}
@@ -0,0 +1,272 @@
+// Package httpfs contains a read-only FS for files served over HTTP(S), like asset bundles hosted on a CDN,
+// and a Handler to serve any FS over HTTP.
+package httpfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const (
+	defaultBlockSize = 64 * 1024
+	fileMode         = 0444
+	dirMode          = hackpadfs.ModeDir | 0555
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.StatFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+	} = &FS{}
+)
+
+// Options configures an FS
+type Options struct {
+	// Client sends every request. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Manifest is the URL of a JSON Manifest listing every file, resolved relative to the base URL.
+	// If set, the manifest supplies Stat and ReadDir without any more requests.
+	Manifest string
+	// ParseIndex enables ReadDir by parsing links from HTML directory index pages, like those served by http.FileServer or nginx's autoindex.
+	// Ignored if Manifest is set.
+	ParseIndex bool
+	// BlockSize is the minimum number of bytes requested by each ranged GET. Defaults to 64 KiB.
+	BlockSize int
+}
+
+// FS is a read-only FS of files served under a base URL.
+//
+// Open only requests a file's info. Reads then issue ranged GETs for just the blocks they need, so large files can be read in part cheaply.
+// Stat uses HEAD requests. Paths which redirect to a trailing slash are directories.
+// ReadDir requires a manifest or parsing directory index pages, see Options.
+type FS struct {
+	base    *url.URL
+	options Options
+
+	manifestMu sync.Mutex // guards loading the manifest
+	manifest   *manifestIndex
+}
+
+// NewFS returns an FS which reads files under 'baseURL' as configured by 'options'
+func NewFS(baseURL string, options Options) (*FS, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme: %q", base.Scheme)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	base.RawPath = ""
+	base.RawQuery = ""
+	base.Fragment = ""
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	if options.BlockSize < 0 {
+		return nil, errors.New("BlockSize must not be negative")
+	}
+	if options.BlockSize == 0 {
+		options.BlockSize = defaultBlockSize
+	}
+	return &FS{
+		base:    base,
+		options: options,
+	}, nil
+}
+
+// url returns the URL of 'name'
+func (fs *FS) url(name string) *url.URL {
+	u := *fs.base
+	if name != "." {
+		u.Path += name
+	}
+	return &u
+}
+
+// do sends a request for 'u', returning an error for any unsuccessful status
+func (fs *FS) do(method string, u *url.URL, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := fs.options.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	return nil, statusErr(resp)
+}
+
+func statusErr(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return hackpadfs.ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return hackpadfs.ErrPermission
+	default:
+		return fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	info, err := fs.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &dir{fs: fs, name: name, info: info}, nil
+	}
+	return &file{fs: fs, name: name, info: info}, nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return fs.stat("stat", name)
+}
+
+func (fs *FS) stat(op, name string) (*fileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if fs.options.Manifest != "" {
+		index, err := fs.loadManifest()
+		if err != nil {
+			return nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+		}
+		info, err := index.stat(name)
+		if err != nil {
+			return nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+		}
+		return info, nil
+	}
+	if name == "." {
+		return &fileInfo{name: name, size: 0, mode: dirMode}, nil
+	}
+
+	resp, err := fs.do(http.MethodHead, fs.url(name), nil)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	_ = resp.Body.Close()
+	info := &fileInfo{
+		name:    path.Base(name),
+		size:    resp.ContentLength,
+		mode:    fileMode,
+		modTime: lastModified(resp),
+	}
+	if strings.HasSuffix(resp.Request.URL.Path, "/") {
+		// redirected to a directory
+		info.size = 0
+		info.mode = dirMode
+	}
+	return info, nil
+}
+
+func lastModified(resp *http.Response) time.Time {
+	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+	return modTime
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	info, err := fs.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+	var entries []hackpadfs.DirEntry
+	switch {
+	case fs.options.Manifest != "":
+		index, err := fs.loadManifest()
+		if err == nil {
+			entries = index.readDir(name)
+		}
+	case fs.options.ParseIndex:
+		entries, err = fs.readIndex(name)
+	default:
+		err = hackpadfs.ErrNotImplemented
+	}
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	info, err := fs.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "read", Path: name, Err: hackpadfs.ErrIsDir}
+	}
+	resp, err := fs.do(http.MethodGet, fs.url(name), nil)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "read", Path: name, Err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "read", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// fileInfo describes a file or directory
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    hackpadfs.FileMode
+	modTime time.Time
+}
+
+func (i *fileInfo) Name() string {
+	return i.name
+}
+
+func (i *fileInfo) Size() int64 {
+	return i.size
+}
+
+func (i *fileInfo) Mode() hackpadfs.FileMode {
+	return i.mode
+}
+
+func (i *fileInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i *fileInfo) IsDir() bool {
+	return i.mode.IsDir()
+}
+
+func (i *fileInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,216 @@
+package httpfs
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+// serve serves 'fs' under "/files/" with 'handler', and its manifest at "/manifest.json"
+func serve(tb testing.TB, fs hackpadfs.FS, handler http.Handler) *httptest.Server {
+	tb.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/files/", http.StripPrefix("/files/", handler))
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		manifest, err := NewManifest(fs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(manifest)
+	})
+	server := httptest.NewServer(mux)
+	tb.Cleanup(server.Close)
+	return server
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	// HTTP doesn't serve file modes, so only manifests report the modes these tests expect
+	skipModes := func(facets fstest.Facets) bool {
+		return strings.HasSuffix(facets.Name, "/fs.ReadDir/exists") ||
+			strings.HasSuffix(facets.Name, "/file_concurrent.Stat")
+	}
+	for _, tc := range []struct {
+		name       string
+		options    Options
+		shouldSkip func(fstest.Facets) bool
+		handler    func(fs hackpadfs.FS) http.Handler
+	}{
+		{name: "httpfs index", options: Options{ParseIndex: true}, shouldSkip: skipModes},
+		{name: "httpfs manifest", options: Options{Manifest: "../manifest.json"}},
+		{name: "httpfs small blocks", options: Options{ParseIndex: true, BlockSize: 2}, shouldSkip: skipModes},
+		{
+			name:       "httpfs handler",
+			options:    Options{ParseIndex: true},
+			shouldSkip: skipModes,
+			handler: func(fs hackpadfs.FS) http.Handler {
+				return NewHandler(fs, HandlerOptions{ListDirectories: true, HashContents: true})
+			},
+		},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+				memFS, err := mem.NewFS()
+				requireNoError(tb, err)
+				return memFS, func() hackpadfs.FS {
+					handler := http.FileServer(http.FS(memFS))
+					if tc.handler != nil {
+						handler = tc.handler(memFS)
+					}
+					server := serve(tb, memFS, handler)
+					fs, err := NewFS(server.URL+"/files/", tc.options)
+					requireNoError(tb, err)
+					return fs
+				}
+			}),
+			Constraints: fstest.Constraints{
+				AllowUnenforcedPermissions: true,
+				MatchErr:                   fstest.MatchErrorIs,
+			},
+			ShouldSkip: tc.shouldSkip,
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func TestNewFS(t *testing.T) {
+	t.Parallel()
+	_, err := NewFS("file:///tmp", Options{})
+	assert.Error(t, err)
+	_, err = NewFS("http://example.com", Options{BlockSize: -1})
+	assert.Error(t, err)
+	fs, err := NewFS("https://example.com/assets?query#fragment", Options{})
+	requireNoError(t, err)
+	assert.Equal(t, "https://example.com/assets/dir/a%20b%3F", fs.url("dir/a b?").String())
+}
+
+func TestRangeRequests(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	contents := strings.Repeat("0123456789", 1000)
+	requireNoError(t, hackpadfs.WriteFullFile(memFS, "big", []byte(contents), 0600))
+
+	var mu sync.Mutex
+	var methods, ranges []string
+	fileServer := http.FileServer(http.FS(memFS))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		fileServer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	fs, err := NewFS(server.URL, Options{BlockSize: 100})
+	requireNoError(t, err)
+	f, err := fs.Open("big")
+	requireNoError(t, err)
+	assert.Equal(t, []string{http.MethodHead}, methods)
+
+	buf := make([]byte, 10)
+	n, err := hackpadfs.ReadAtFile(f, buf, 5000)
+	assert.NoError(t, err)
+	assert.Equal(t, contents[5000:5010], string(buf[:n]))
+	n, err = hackpadfs.ReadAtFile(f, buf, 5010)
+	assert.NoError(t, err)
+	assert.Equal(t, contents[5010:5020], string(buf[:n]))
+	n, err = hackpadfs.ReadAtFile(f, buf, 9995)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, contents[9995:], string(buf[:n]))
+	assert.NoError(t, f.Close())
+
+	assert.Equal(t, []string{http.MethodHead, http.MethodGet, http.MethodGet}, methods)
+	assert.Equal(t, []string{"", "bytes=5000-5099", "bytes=9995-9999"}, ranges)
+}
+
+func TestIgnoredRange(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello world")
+	}))
+	t.Cleanup(server.Close)
+
+	fs, err := NewFS(server.URL, Options{})
+	requireNoError(t, err)
+	f, err := fs.Open("foo")
+	requireNoError(t, err)
+	buf := make([]byte, 5)
+	n, err := hackpadfs.ReadAtFile(f, buf, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+	assert.NoError(t, f.Close())
+}
+
+func TestStatusErrors(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/private":
+			w.WriteHeader(http.StatusForbidden)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	fs, err := NewFS(server.URL, Options{})
+	requireNoError(t, err)
+	_, err = fs.Stat("private")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	_, err = fs.Stat("missing")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = fs.Stat("broken")
+	assert.Error(t, err)
+	_, err = fs.ReadDir(".")
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+}
+
+func TestIndexEntryName(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		href  string
+		name  string
+		isDir bool
+		ok    bool
+	}{
+		{href: "foo", name: "foo", ok: true},
+		{href: "foo/", name: "foo", isDir: true, ok: true},
+		{href: "./a:b", name: "a:b", ok: true},
+		{href: "a%20b", name: "a b", ok: true},
+		{href: "../"},
+		{href: "/"},
+		{href: "/abs"},
+		{href: "?C=N;O=D"},
+		{href: "https://example.com/foo"},
+		{href: "foo/bar"},
+	} {
+		name, isDir, ok := indexEntryName(tc.href)
+		assert.Equal(t, tc.name, name)
+		assert.Equal(t, tc.isDir, isDir)
+		assert.Equal(t, tc.ok, ok)
+	}
+}
@@ -0,0 +1,254 @@
+package httpfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const indexName = "index.html"
+
+// HashFS is an FS which can return a hash of a file's contents without reading them, like a stored checksum.
+// Handler uses it for ETags.
+type HashFS interface {
+	hackpadfs.FS
+	Hash(name string) ([]byte, error)
+}
+
+// HandlerOptions configures a Handler
+type HandlerOptions struct {
+	// ListDirectories serves an HTML index of directories without an index.html file. Otherwise they return 403 Forbidden.
+	ListDirectories bool
+	// HashContents computes ETags from a SHA-256 hash of each file's contents, if the FS doesn't implement HashFS.
+	// Hashes are kept until a file's size or modified time changes.
+	HashContents bool
+}
+
+// Handler serves files from an FS over HTTP, including Last-Modified headers, byte ranges, and ETags.
+// Byte ranges are read with ReadAt when files support it, so only the requested bytes are read.
+// Directories serve their index.html file, if any.
+type Handler struct {
+	fs      hackpadfs.FS
+	options HandlerOptions
+
+	hashesMu sync.Mutex
+	hashes   map[string]cachedHash
+}
+
+type cachedHash struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// NewHandler returns a Handler which serves files from 'fs'
+func NewHandler(fs hackpadfs.FS, options HandlerOptions) *Handler {
+	return &Handler{
+		fs:      fs,
+		options: options,
+		hashes:  make(map[string]cachedHash),
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	urlPath := r.URL.Path
+	if !strings.HasPrefix(urlPath, "/") {
+		urlPath = "/" + urlPath
+	}
+	name := strings.TrimPrefix(path.Clean(urlPath), "/")
+	if name == "" {
+		name = "."
+	}
+
+	info, err := hackpadfs.Stat(h.fs, name)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	if info.IsDir() {
+		if !strings.HasSuffix(urlPath, "/") {
+			redirect(w, r, path.Base(urlPath)+"/")
+			return
+		}
+		indexInfo, err := hackpadfs.Stat(h.fs, path.Join(name, indexName))
+		switch {
+		case err == nil && !indexInfo.IsDir():
+			name, info = path.Join(name, indexName), indexInfo
+		case h.options.ListDirectories:
+			h.serveDir(w, r, name)
+			return
+		default:
+			serveError(w, hackpadfs.ErrPermission)
+			return
+		}
+	} else if strings.HasSuffix(urlPath, "/") {
+		redirect(w, r, "../"+path.Base(urlPath))
+		return
+	}
+	h.serveFile(w, r, name, info)
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, target string) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist), errors.Is(err, hackpadfs.ErrNotDir), errors.Is(err, hackpadfs.ErrInvalid):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case errors.Is(err, hackpadfs.ErrPermission):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, name string, info hackpadfs.FileInfo) {
+	f, err := h.fs.Open(name)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	etag, err := h.etag(name, info)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	if etag != "" {
+		w.Header().Set("Etag", etag)
+	}
+	content, ok := readSeeker(f, info.Size())
+	if !ok {
+		// can't seek, so serve the whole file without ranges
+		if checkNotModified(w, r, info.ModTime(), etag) {
+			return
+		}
+		if !info.ModTime().IsZero() {
+			w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+		if r.Method != http.MethodHead {
+			_, _ = io.Copy(w, f)
+		}
+		return
+	}
+	http.ServeContent(w, r, path.Base(name), info.ModTime(), content)
+}
+
+// checkNotModified writes a 304 Not Modified response if the request's conditions match, returning true if it did
+func checkNotModified(w http.ResponseWriter, r *http.Request, modTime time.Time, etag string) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if etag == "" || !etagMatches(match, etag) {
+			return false
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err != nil || modTime.IsZero() || modTime.Truncate(time.Second).After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// readSeeker returns a ReadSeeker of 'f', preferring ReadAt so ranges don't disturb the file offset
+func readSeeker(f hackpadfs.File, size int64) (io.ReadSeeker, bool) {
+	if readerAt, ok := f.(io.ReaderAt); ok {
+		return io.NewSectionReader(readerAt, 0, size), true
+	}
+	if seeker, ok := f.(io.ReadSeeker); ok {
+		return seeker, true
+	}
+	return nil, false
+}
+
+// etag returns the quoted ETag of 'name', or an empty string if none is available
+func (h *Handler) etag(name string, info hackpadfs.FileInfo) (string, error) {
+	if hashFS, ok := h.fs.(HashFS); ok {
+		hash, err := hashFS.Hash(name)
+		if err != nil && !errors.Is(err, hackpadfs.ErrNotImplemented) {
+			return "", err
+		}
+		if err == nil {
+			return `"` + hex.EncodeToString(hash) + `"`, nil
+		}
+	}
+	if !h.options.HashContents {
+		return "", nil
+	}
+
+	h.hashesMu.Lock()
+	cached, ok := h.hashes[name]
+	h.hashesMu.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.etag, nil
+	}
+	f, err := h.fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)) + `"`
+	h.hashesMu.Lock()
+	h.hashes[name] = cachedHash{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	h.hashesMu.Unlock()
+	return etag, nil
+}
+
+func (h *Handler) serveDir(w http.ResponseWriter, r *http.Request, name string) {
+	entries, err := hackpadfs.ReadDir(h.fs, name)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodHead {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+	b.WriteString("</pre>\n")
+	_, _ = io.WriteString(w, b.String())
+}
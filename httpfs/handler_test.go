@@ -0,0 +1,191 @@
+package httpfs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+var modTime = time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newHandlerFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	requireNoError(tb, err)
+	requireNoError(tb, hackpadfs.MkdirAll(fs, "dir/site", 0700))
+	requireNoError(tb, hackpadfs.WriteFullFile(fs, "dir/foo.txt", []byte("hello world"), 0600))
+	requireNoError(tb, hackpadfs.WriteFullFile(fs, "dir/site/index.html", []byte("<p>index</p>"), 0600))
+	requireNoError(tb, hackpadfs.Chtimes(fs, "dir/foo.txt", modTime, modTime))
+	return fs
+}
+
+func doRequest(tb testing.TB, handler http.Handler, method, target string, header http.Header) *http.Response {
+	tb.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder.Result()
+}
+
+func readBody(tb testing.TB, resp *http.Response) string {
+	tb.Helper()
+	body, err := io.ReadAll(resp.Body)
+	requireNoError(tb, err)
+	requireNoError(tb, resp.Body.Close())
+	return string(body)
+}
+
+// hashFS reports a fixed hash for every file
+type hashFS struct {
+	*mem.FS
+}
+
+func (fs *hashFS) Hash(name string) ([]byte, error) {
+	return []byte{0xab, 0xcd}, nil
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file", func(t *testing.T) {
+		t.Parallel()
+		handler := NewHandler(newHandlerFS(t), HandlerOptions{})
+		resp := doRequest(t, handler, http.MethodGet, "/dir/foo.txt", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "hello world", readBody(t, resp))
+		assert.Equal(t, modTime.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+		assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, "", resp.Header.Get("Etag"))
+
+		resp = doRequest(t, handler, http.MethodGet, "/dir/foo.txt", http.Header{
+			"If-Modified-Since": {modTime.Format(http.TimeFormat)},
+		})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		t.Parallel()
+		handler := NewHandler(newHandlerFS(t), HandlerOptions{})
+		resp := doRequest(t, handler, http.MethodGet, "/dir/foo.txt", http.Header{
+			"Range": {"bytes=6-"},
+		})
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "world", readBody(t, resp))
+		assert.Equal(t, "bytes 6-10/11", resp.Header.Get("Content-Range"))
+	})
+
+	t.Run("hash contents etag", func(t *testing.T) {
+		t.Parallel()
+		fs := newHandlerFS(t)
+		handler := NewHandler(fs, HandlerOptions{HashContents: true})
+		resp := doRequest(t, handler, http.MethodGet, "/dir/foo.txt", nil)
+		const helloWorldETag = `"b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"`
+		assert.Equal(t, helloWorldETag, resp.Header.Get("Etag"))
+
+		resp = doRequest(t, handler, http.MethodGet, "/dir/foo.txt", http.Header{
+			"If-None-Match": {helloWorldETag},
+		})
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dir/foo.txt", []byte("changed"), 0600))
+		resp = doRequest(t, handler, http.MethodGet, "/dir/foo.txt", http.Header{
+			"If-None-Match": {helloWorldETag},
+		})
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "changed", readBody(t, resp))
+	})
+
+	t.Run("hash FS etag", func(t *testing.T) {
+		t.Parallel()
+		handler := NewHandler(&hashFS{FS: newHandlerFS(t)}, HandlerOptions{})
+		resp := doRequest(t, handler, http.MethodGet, "/dir/foo.txt", nil)
+		assert.Equal(t, `"abcd"`, resp.Header.Get("Etag"))
+	})
+
+	t.Run("directories", func(t *testing.T) {
+		t.Parallel()
+		fs := newHandlerFS(t)
+		handler := NewHandler(fs, HandlerOptions{})
+		resp := doRequest(t, handler, http.MethodGet, "/dir", nil)
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "dir/", resp.Header.Get("Location"))
+		resp = doRequest(t, handler, http.MethodGet, "/dir/", nil)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		resp = doRequest(t, handler, http.MethodGet, "/dir/site/", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "<p>index</p>", readBody(t, resp))
+		resp = doRequest(t, handler, http.MethodGet, "/dir/foo.txt/", nil)
+		assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		assert.Equal(t, "../foo.txt", resp.Header.Get("Location"))
+
+		handler = NewHandler(fs, HandlerOptions{ListDirectories: true})
+		resp = doRequest(t, handler, http.MethodGet, "/dir/", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body := readBody(t, resp)
+		assert.Equal(t, true, strings.Contains(body, `<a href="foo.txt">foo.txt</a>`))
+		assert.Equal(t, true, strings.Contains(body, `<a href="site/">site/</a>`))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		handler := NewHandler(newHandlerFS(t), HandlerOptions{})
+		resp := doRequest(t, handler, http.MethodGet, "/missing", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = doRequest(t, handler, http.MethodGet, "/dir/foo.txt/bar", nil)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = doRequest(t, handler, http.MethodPut, "/dir/foo.txt", nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+}
+
+// readerAtFS only opens files which support ReadAt, but not Seek
+type readerAtFS struct {
+	*mem.FS
+}
+
+type readerAtFile struct {
+	hackpadfs.File
+}
+
+func (f *readerAtFile) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+func (f *readerAtFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+func (fs *readerAtFS) Open(name string) (hackpadfs.File, error) {
+	f, err := fs.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &readerAtFile{File: f}, nil
+}
+
+func TestFileSystem(t *testing.T) {
+	t.Parallel()
+	fs := &readerAtFS{FS: newHandlerFS(t)}
+	handler := http.FileServer(NewFileSystem(fs))
+
+	resp := doRequest(t, handler, http.MethodGet, "/dir/foo.txt", http.Header{
+		"Range": {"bytes=0-4"},
+	})
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "hello", readBody(t, resp))
+	assert.Equal(t, modTime.Format(http.TimeFormat), resp.Header.Get("Last-Modified"))
+
+	resp = doRequest(t, handler, http.MethodGet, "/dir/", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, strings.Contains(readBody(t, resp), `<a href="foo.txt">foo.txt</a>`))
+}
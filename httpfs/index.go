@@ -0,0 +1,100 @@
+package httpfs
+
+import (
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// maxIndexSize is the largest directory index page parsed
+const maxIndexSize = 16 << 20
+
+var hrefPattern = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*("[^"]*"|'[^']*')`)
+
+// readIndex lists 'dir' by parsing the links in its directory index page
+func (fs *FS) readIndex(dir string) ([]hackpadfs.DirEntry, error) {
+	u := fs.url(dir)
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	resp, err := fs.do(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxIndexSize))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var entries []hackpadfs.DirEntry
+	for _, match := range hrefPattern.FindAllSubmatch(page, -1) {
+		href := html.UnescapeString(string(match[1][1 : len(match[1])-1]))
+		name, isDir, ok := indexEntryName(href)
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		entries = append(entries, &dirEntry{
+			fs:    fs,
+			name:  name,
+			path:  path.Join(dir, name),
+			isDir: isDir,
+		})
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+	return entries, nil
+}
+
+// indexEntryName returns the entry name of a link in a directory index, and true if the link is a directory entry.
+// Links to parent directories, other sites, or sorting options aren't entries.
+func indexEntryName(href string) (name string, isDir, ok bool) {
+	u, err := url.Parse(href)
+	if err != nil || u.IsAbs() || u.Host != "" || u.RawQuery != "" || u.Path == "" || path.IsAbs(u.Path) {
+		return "", false, false
+	}
+	name = strings.TrimPrefix(u.Path, "./")
+	isDir = strings.HasSuffix(name, "/")
+	name = strings.TrimSuffix(name, "/")
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", false, false
+	}
+	return name, isDir, true
+}
+
+// dirEntry is an entry of a directory index, which requests its info when needed
+type dirEntry struct {
+	fs    *FS
+	name  string
+	path  string
+	isDir bool
+}
+
+func (e *dirEntry) Name() string {
+	return e.name
+}
+
+func (e *dirEntry) IsDir() bool {
+	return e.isDir
+}
+
+func (e *dirEntry) Type() hackpadfs.FileMode {
+	if e.isDir {
+		return hackpadfs.ModeDir
+	}
+	return 0
+}
+
+func (e *dirEntry) Info() (hackpadfs.FileInfo, error) {
+	return e.fs.Stat(e.path)
+}
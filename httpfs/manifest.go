@@ -0,0 +1,151 @@
+package httpfs
+
+import (
+	"encoding/json"
+	gofs "io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// Manifest lists the files served under a base URL. See Options.Manifest.
+type Manifest struct {
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile describes a file or directory in a Manifest. Parent directories are implied by file paths, so only empty directories need their own entries.
+type ManifestFile struct {
+	// Path is the file's path, relative to the base URL
+	Path string `json:"path"`
+	// Size is the file's size in bytes
+	Size int64 `json:"size,omitempty"`
+	// Mode is the file's mode. Defaults to 0444 for files.
+	Mode hackpadfs.FileMode `json:"mode,omitempty"`
+	// ModTime is the file's modified time
+	ModTime time.Time `json:"modTime,omitempty"`
+}
+
+// NewManifest returns a Manifest of every file and directory in 'fs', like files about to be uploaded to a CDN.
+// Symlinks are listed as the files they point to.
+func NewManifest(fs hackpadfs.FS) (*Manifest, error) {
+	manifest := &Manifest{}
+	err := gofs.WalkDir(fs, ".", func(name string, entry gofs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := hackpadfs.Stat(fs, name)
+		if err != nil {
+			return err
+		}
+		file := ManifestFile{
+			Path:    name,
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+		if !info.IsDir() {
+			file.Size = info.Size()
+		}
+		manifest.Files = append(manifest.Files, file)
+		return nil
+	})
+	return manifest, err
+}
+
+// manifestIndex looks up files listed in a Manifest
+type manifestIndex struct {
+	infos    map[string]*fileInfo
+	children map[string][]string
+}
+
+func newManifestIndex(manifest *Manifest) (*manifestIndex, error) {
+	index := &manifestIndex{
+		infos: map[string]*fileInfo{
+			".": {name: ".", mode: dirMode},
+		},
+		children: make(map[string][]string),
+	}
+	for _, file := range manifest.Files {
+		if !hackpadfs.ValidPath(file.Path) || file.Path == "." {
+			return nil, &hackpadfs.PathError{Op: "manifest", Path: file.Path, Err: hackpadfs.ErrInvalid}
+		}
+		mode := file.Mode
+		if mode == 0 {
+			mode = fileMode
+		}
+		index.add(file.Path, &fileInfo{
+			name:    path.Base(file.Path),
+			size:    file.Size,
+			mode:    mode,
+			modTime: file.ModTime,
+		})
+		for dir := path.Dir(file.Path); dir != "."; dir = path.Dir(dir) {
+			if _, exists := index.infos[dir]; exists {
+				break
+			}
+			index.add(dir, &fileInfo{name: path.Base(dir), mode: dirMode})
+		}
+	}
+	for _, names := range index.children {
+		sort.Strings(names)
+	}
+	return index, nil
+}
+
+func (m *manifestIndex) add(name string, info *fileInfo) {
+	if existing, exists := m.infos[name]; exists {
+		*existing = *info
+		return
+	}
+	m.infos[name] = info
+	dir := path.Dir(name)
+	m.children[dir] = append(m.children[dir], path.Base(name))
+}
+
+func (m *manifestIndex) stat(name string) (*fileInfo, error) {
+	info, exists := m.infos[name]
+	if !exists {
+		return nil, hackpadfs.ErrNotExist
+	}
+	return info, nil
+}
+
+func (m *manifestIndex) readDir(dir string) []hackpadfs.DirEntry {
+	names := m.children[dir]
+	entries := make([]hackpadfs.DirEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, gofs.FileInfoToDirEntry(m.infos[path.Join(dir, name)]))
+	}
+	return entries
+}
+
+// loadManifest fetches and indexes the manifest, if not already loaded
+func (fs *FS) loadManifest() (*manifestIndex, error) {
+	fs.manifestMu.Lock()
+	defer fs.manifestMu.Unlock()
+	if fs.manifest != nil {
+		return fs.manifest, nil
+	}
+	ref, err := url.Parse(fs.options.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fs.do(http.MethodGet, fs.base.ResolveReference(ref), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var manifest Manifest
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	index, err := newManifestIndex(&manifest)
+	if err != nil {
+		return nil, err
+	}
+	fs.manifest = index
+	return index, nil
+}
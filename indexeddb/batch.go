@@ -0,0 +1,223 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// batcher coalesces write-only transactions committed close together into a single IndexedDB transaction
+type batcher struct {
+	store  *store
+	window time.Duration
+
+	mu      sync.Mutex
+	pending []*batchedCommit
+	timer   *time.Timer
+}
+
+type batchedSet struct {
+	path     string
+	record   keyvalue.FileRecord
+	contents blob.Blob
+}
+
+// batchedCommit is one transaction's Sets, waiting for its batch to commit
+type batchedCommit struct {
+	sets    []batchedSet
+	done    chan struct{}
+	results []keyvalue.OpResult
+	err     error
+
+	enqueued time.Time
+	started  time.Time // when the batch started running
+}
+
+func newBatcher(s *store, window time.Duration) *batcher {
+	return &batcher{store: s, window: window}
+}
+
+// commit adds 'sets' to the next batch and waits for it to commit
+func (b *batcher) commit(ctx context.Context, sets []batchedSet) ([]keyvalue.OpResult, error) {
+	commit := &batchedCommit{sets: sets, done: make(chan struct{}), enqueued: time.Now()}
+	b.mu.Lock()
+	b.pending = append(b.pending, commit)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-commit.done:
+		if b.store.reportsOperations() {
+			b.store.reportOperation(Operation{
+				Op:       OpBatch,
+				Ops:      len(sets),
+				Duration: time.Since(commit.enqueued),
+				Queue:    commit.started.Sub(commit.enqueued),
+				Err:      getFirstCommitError(commit.results, commit.err),
+			})
+		}
+		return commit.results, commit.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	commits := b.pending
+	b.pending = nil
+	b.timer = nil
+	b.mu.Unlock()
+	started := time.Now()
+	for _, commit := range commits {
+		commit.started = started
+	}
+
+	err := b.run(commits)
+	if err == nil {
+		return
+	}
+	// A failed op, like a missing parent directory, aborts the whole IndexedDB transaction.
+	// Retry each commit on its own, so one bad write doesn't fail the others.
+	for _, commit := range commits {
+		_ = b.run([]*batchedCommit{commit})
+	}
+}
+
+// run commits all of 'commits' in one transaction. If successful, or if there's only one commit, their results are set and they're marked done.
+func (b *batcher) run(commits []*batchedCommit) error {
+	results, err := b.runSets(commits)
+	if err != nil && len(commits) > 1 {
+		return err
+	}
+	offset := 0
+	for _, commit := range commits {
+		commit.err = err
+		if len(results) >= offset+len(commit.sets) {
+			commit.results = make([]keyvalue.OpResult, len(commit.sets))
+			for i, result := range results[offset : offset+len(commit.sets)] {
+				result.Op = keyvalue.OpID(i)
+				commit.results[i] = result
+			}
+		}
+		offset += len(commit.sets)
+		close(commit.done)
+	}
+	return nil
+}
+
+func (b *batcher) runSets(commits []*batchedCommit) ([]keyvalue.OpResult, error) {
+	txn, err := b.store.newTransaction(keyvalue.TransactionOptions{Mode: keyvalue.TransactionReadWrite})
+	if err != nil {
+		return nil, err
+	}
+	for _, commit := range commits {
+		for _, set := range commit.sets {
+			txn.Set(set.path, set.record, set.contents)
+		}
+	}
+	results, err := txn.Commit(context.Background())
+	if err == nil {
+		err = getFirstCommitError(results, nil)
+	}
+	return results, err
+}
+
+// batchedTransaction queues Sets for the store's batcher.
+// If the transaction needs results before committing, like for a Get or handler, it switches to a regular transaction instead.
+type batchedTransaction struct {
+	store   *store
+	options keyvalue.TransactionOptions
+	sets    []batchedSet
+	txn     *transaction
+	txnErr  error
+}
+
+func newBatchedTransaction(s *store, options keyvalue.TransactionOptions) *batchedTransaction {
+	return &batchedTransaction{store: s, options: options}
+}
+
+// unbatched returns a regular transaction, replaying any queued Sets into it
+func (t *batchedTransaction) unbatched() (*transaction, error) {
+	if t.txn != nil || t.txnErr != nil {
+		return t.txn, t.txnErr
+	}
+	t.txn, t.txnErr = t.store.newTransaction(t.options)
+	if t.txnErr == nil {
+		for _, set := range t.sets {
+			t.txn.Set(set.path, set.record, set.contents)
+		}
+		t.sets = nil
+	}
+	return t.txn, t.txnErr
+}
+
+func (t *batchedTransaction) Get(path string) keyvalue.OpID {
+	txn, err := t.unbatched()
+	if err != nil {
+		return t.failedOp()
+	}
+	return txn.Get(path)
+}
+
+func (t *batchedTransaction) GetHandler(path string, handler keyvalue.OpHandler) keyvalue.OpID {
+	txn, err := t.unbatched()
+	if err != nil {
+		return t.failedOp()
+	}
+	return txn.GetHandler(path, handler)
+}
+
+func (t *batchedTransaction) Set(path string, src keyvalue.FileRecord, contents blob.Blob) keyvalue.OpID {
+	if t.txn != nil || t.txnErr != nil {
+		txn, err := t.unbatched()
+		if err != nil {
+			return t.failedOp()
+		}
+		return txn.Set(path, src, contents)
+	}
+	t.sets = append(t.sets, batchedSet{path: path, record: src, contents: contents})
+	return keyvalue.OpID(len(t.sets) - 1)
+}
+
+func (t *batchedTransaction) SetHandler(path string, src keyvalue.FileRecord, contents blob.Blob, handler keyvalue.OpHandler) keyvalue.OpID {
+	txn, err := t.unbatched()
+	if err != nil {
+		return t.failedOp()
+	}
+	return txn.SetHandler(path, src, contents, handler)
+}
+
+// failedOp returns an op ID after failing to create a regular transaction. Commit returns the error.
+func (t *batchedTransaction) failedOp() keyvalue.OpID {
+	return keyvalue.OpID(-1)
+}
+
+func (t *batchedTransaction) Commit(ctx context.Context) ([]keyvalue.OpResult, error) {
+	if t.txn != nil || t.txnErr != nil {
+		if t.txnErr != nil {
+			return nil, t.txnErr
+		}
+		return t.txn.Commit(ctx)
+	}
+	if len(t.sets) == 0 {
+		return nil, nil
+	}
+	return t.store.batcher.commit(ctx, t.sets)
+}
+
+func (t *batchedTransaction) Abort() error {
+	if t.txn != nil {
+		return t.txn.Abort()
+	}
+	t.sets = nil
+	return nil
+}
@@ -6,6 +6,7 @@ package indexeddb
 
 import (
 	"context"
+	"syscall/js"
 	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
@@ -15,62 +16,132 @@ import (
 )
 
 const (
-	fsVersion = 1
-
 	contentsStore = "contents"
 	infoStore     = "info"
+	metaStore     = "meta"
+	locksStore    = "locks"
 	parentKey     = "Parent"
+
+	schemaVersionKey = "schemaVersion"
 )
 
 // FS is a browser-based file system, storing files and metadata inside IndexedDB.
 type FS struct {
-	kv *keyvalue.FS
-	db *idb.Database
+	kv      *keyvalue.FS
+	db      *idb.Database // nil if running in a worker
+	store   *store        // nil if running in a worker
+	worker  *workerStore  // nil unless running in a worker
+	watches *watchHub
 }
 
 // Options provides configuration options for a new FS.
 type Options struct {
-	Factory               *idb.Factory
+	// Factory opens the database. Defaults to the global indexedDB factory.
+	Factory *idb.Factory
+	// TransactionDurability is the durability hint for all transactions.
+	// idb.DurabilityRelaxed greatly improves write throughput in some browsers, but recent writes may be lost on power failure.
+	// idb.DurabilityStrict waits for writes to reach persistent storage. Defaults to the browser's default durability.
+	//
+	// Writes to files opened with hackpadfs.FlagSync always use strict durability.
 	TransactionDurability idb.TransactionDurability
+	// Migrations upgrades databases created with older record layouts when they are opened. See keyvalue.MigrationRegistry.
+	Migrations *keyvalue.MigrationRegistry
+	// BatchWindow enables write batching when set. Write-only transactions committed within BatchWindow of each other are coalesced into one IndexedDB transaction.
+	// Each write still waits for its batch to commit, so batching improves throughput for concurrent writes at the cost of latency. A few milliseconds is typical.
+	// Writes to files opened with hackpadfs.FlagSync are never batched.
+	BatchWindow time.Duration
+	// Worker runs all IndexedDB operations in the given Web Worker, keeping the calling thread responsive during large reads and writes.
+	// The worker must call ServeWorker(). Worker may also be a MessagePort connected to a ServeWorker() call.
+	// File contents are moved between threads as transferable buffers where possible.
+	// Other options, except RequestPersistence, are ignored and must be passed to ServeWorker() instead.
+	Worker js.Value
+	// OnUpgrade is called while opening a database with an older schema version, after upgrading the schema to the current version.
+	// Runs inside the "upgradeneeded" event, so it may add the application's own object stores and indexes. oldVersion is 0 for a new database.
+	OnUpgrade UpgradeFunc
+	// RequestPersistence requests persistent storage with navigator.storage.persist() when opening the FS.
+	// Browsers may evict data from non-persistent storage when the device runs low on space. Browsers may also deny or prompt the user for persistence. Check the result with FS.Persisted().
+	RequestPersistence bool
+	// OnStoragePressure is called from a new goroutine when estimated usage reaches StoragePressureThreshold of the quota, or when a write exceeds the quota.
+	// Usage is checked after writes, at most once a minute. Use it to warn users before data loss, for example if storage is not persistent.
+	OnStoragePressure StoragePressureFunc
+	// StoragePressureThreshold is the fraction of the quota that triggers OnStoragePressure. Defaults to 0.9.
+	StoragePressureThreshold float64
+	// OnOperation is called after each storage operation with its timing, to help diagnose slow operations. See NewLatencyHistogram() to aggregate timings.
+	OnOperation OperationFunc
+	// SlowOperationThreshold is the minimum duration of operations passed to OnSlowOperation. Disabled if zero.
+	SlowOperationThreshold time.Duration
+	// OnSlowOperation is called after each storage operation taking at least SlowOperationThreshold. Nothing is logged by default.
+	// For example, log slow operations with func(op indexeddb.Operation) { log.Println(op) }
+	OnSlowOperation OperationFunc
+	// PrefetchWindow enables read prefetching for files up to this many bytes. Disabled if zero.
+	// Contents are stored as one record per file, so when a file's metadata is read (e.g. on Open) its contents are read in a background transaction.
+	// Sequential reads then overlap IndexedDB latency with the caller's work. Files that are only stat'ed cost an extra read.
+	PrefetchWindow int64
 }
 
+// UpgradeFunc is called when a database's schema is upgraded. See Options.OnUpgrade.
+type UpgradeFunc func(db *idb.Database, oldVersion, newVersion uint) error
+
 // NewFS returns a new FS.
+// Upgrades the database's schema if it was created by an older version of this package. Returns an error wrapping ErrSchemaTooNew if it was created by a newer version.
 func NewFS(ctx context.Context, name string, options Options) (*FS, error) {
+	if options.Worker.Truthy() {
+		if options.RequestPersistence {
+			_, _ = callStorageBool(ctx, "persist")
+		}
+		return newWorkerFS(ctx, name, options.Worker)
+	}
 	if options.Factory == nil {
 		options.Factory = idb.Global()
 	}
-	openRequest, err := options.Factory.Open(ctx, name, fsVersion, func(db *idb.Database, oldVersion, newVersion uint) error {
-		_, err := db.CreateObjectStore(contentsStore, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-		infos, err := db.CreateObjectStore(infoStore, idb.ObjectStoreOptions{})
-		if err != nil {
-			return err
-		}
-		jsParentKey, err := safejs.ValueOf(parentKey)
-		if err != nil {
-			return err
-		}
-		_, err = infos.CreateIndex(parentKey, safejs.Unsafe(jsParentKey), idb.IndexOptions{})
-		return err
+	openRequest, err := options.Factory.Open(ctx, name, schemaVersion, func(db *idb.Database, oldVersion, newVersion uint) error {
+		return upgradeSchema(db, oldVersion, newVersion, options.OnUpgrade)
 	})
 	if err != nil {
-		return nil, err
+		return nil, wrapOpenErr(err)
 	}
 	db, err := openRequest.Await(ctx)
+	if err != nil {
+		return nil, wrapOpenErr(err)
+	}
+	if options.RequestPersistence {
+		// The result is only a hint. Check it later with Persisted().
+		_, _ = callStorageBool(ctx, "persist")
+	}
+	store := newStore(db, options)
+	store.watches = newWatchHub(name)
+	kv, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{
+		Migrations: options.Migrations,
+	})
+	return &FS{
+		kv:      kv,
+		db:      db,
+		store:   store,
+		watches: store.watches,
+	}, err
+}
+
+func newWorkerFS(ctx context.Context, name string, worker js.Value) (*FS, error) {
+	workerStore, err := newWorkerStore(name, safejs.Safe(worker))
 	if err != nil {
 		return nil, err
 	}
-	kv, err := keyvalue.NewFS(newStore(db, options))
+	if err := workerStore.awaitReady(ctx); err != nil {
+		return nil, err
+	}
+	kv, err := keyvalue.NewFS(workerStore)
 	return &FS{
-		kv: kv,
-		db: db,
+		kv:      kv,
+		worker:  workerStore,
+		watches: newWatchHub(name), // changes made by the worker are broadcast back to this FS
 	}, err
 }
 
 // Clear dangerously destroys all data inside this FS. Use with caution.
 func (fs *FS) Clear(ctx context.Context) error {
+	if fs.worker != nil {
+		return fs.worker.clear(ctx)
+	}
 	stores := []string{contentsStore, infoStore}
 	txn, err := fs.db.Transaction(idb.TransactionReadWrite, stores[0], stores[1:]...)
 	if err != nil {
@@ -94,9 +165,26 @@ func (fs *FS) Clear(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	fs.watches.notify([]hackpadfs.WatchEvent{{Name: rootPath, Op: hackpadfs.WatchRemove}})
 	return fs.Mkdir(".", 0666)
 }
 
+// GC detects records orphaned by interrupted operations and removes them, unless options.DryRun is set. See keyvalue.FS.GC() for details.
+func (fs *FS) GC(ctx context.Context, options keyvalue.GCOptions) (keyvalue.GCResult, error) {
+	return fs.kv.GC(ctx, options)
+}
+
+// Watch implements hackpadfs.WatchFS
+//
+// Changes are shared between all FS's using the same database, including in other tabs.
+// An event for the root directory "." is delivered to every watcher, and means any file may have changed. For example, after a call to Clear() or when another connection upgrades or deletes the database.
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "watch", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	return fs.watches.watch(name), nil
+}
+
 // Open implements hackpadfs.FS
 func (fs *FS) Open(name string) (hackpadfs.File, error) {
 	return fs.kv.Open(name)
@@ -122,6 +210,11 @@ func (fs *FS) Remove(name string) error {
 	return fs.kv.Remove(name)
 }
 
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	return fs.kv.RemoveAll(name)
+}
+
 // Rename implements hackpadfs.RenameFS
 func (fs *FS) Rename(oldname, newname string) error {
 	return fs.kv.Rename(oldname, newname)
@@ -141,3 +234,39 @@ func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
 func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
 	return fs.kv.Chtimes(name, atime, mtime)
 }
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return fs.kv.Symlink(oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return fs.kv.Readlink(name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return fs.kv.Lstat(name)
+}
+
+// Lock implements hackpadfs.LockFS
+//
+// Locks are shared with every FS using the same database, including FS instances in other tabs or workers.
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return fs.kv.Lock(name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+//
+// Usage is estimated by the browser with navigator.storage.estimate(), and is shared by all storage for this origin, not only this FS.
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	if !hackpadfs.ValidPath(name) {
+		return hackpadfs.FSUsage{}, &hackpadfs.PathError{Op: "statfs", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	usage, err := estimateStorage(context.Background())
+	if err != nil {
+		return hackpadfs.FSUsage{}, &hackpadfs.PathError{Op: "statfs", Path: name, Err: err}
+	}
+	return usage, nil
+}
@@ -6,14 +6,19 @@ package indexeddb
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
+	"sync"
+	"syscall/js"
 	"testing"
+	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
 )
 
 const (
@@ -21,13 +26,17 @@ const (
 )
 
 func makeFS(tb testing.TB) *FS {
+	return makeFSWithOptions(tb, Options{})
+}
+
+func makeFSWithOptions(tb testing.TB, options Options) *FS {
 	n, err := rand.Int(rand.Reader, big.NewInt(1000))
 	assert.NoError(tb, err)
 	name := fmt.Sprintf("%s%s/%d", testDBPrefix, tb.Name(), n.Int64())
 
 	factory := idb.Global()
 
-	fs, err := NewFS(context.Background(), name, Options{})
+	fs, err := NewFS(context.Background(), name, options)
 	if err != nil {
 		tb.Fatal(err)
 	}
@@ -47,11 +56,78 @@ func TestFS(t *testing.T) {
 		TestFS: func(tb testing.TB) fstest.SetupFS {
 			return makeFS(tb)
 		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestFSBatched(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "indexeddb batched",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return makeFSWithOptions(tb, Options{BatchWindow: time.Millisecond})
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestFSPrefetch(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "indexeddb prefetch",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return makeFSWithOptions(tb, Options{PrefetchWindow: 1 << 20})
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
 }
 
+func TestBatchedConcurrentWrites(t *testing.T) {
+	t.Parallel()
+	fs := makeFSWithOptions(t, Options{BatchWindow: 10 * time.Millisecond})
+
+	const fileCount = 20
+	var wg sync.WaitGroup
+	errs := make([]error, fileCount)
+	for i := 0; i < fileCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = hackpadfs.WriteFullFile(fs, fmt.Sprintf("file%d", i), []byte(fmt.Sprint(i)), 0600)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	for i := 0; i < fileCount; i++ {
+		contents, err := hackpadfs.ReadFile(fs, fmt.Sprintf("file%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprint(i), string(contents))
+	}
+
+	// a bad write in the same batch must not fail the others
+	wg.Add(2)
+	var goodErr, badErr error
+	go func() {
+		defer wg.Done()
+		goodErr = hackpadfs.WriteFullFile(fs, "good", []byte("good"), 0600)
+	}()
+	go func() {
+		defer wg.Done()
+		badErr = hackpadfs.WriteFullFile(fs, "missing/bad", []byte("bad"), 0600)
+	}()
+	wg.Wait()
+	assert.NoError(t, goodErr)
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, badErr)
+}
+
 func logFS(tb testing.TB, fs hackpadfs.FS) {
 	if !tb.Failed() {
 		return
@@ -103,3 +179,126 @@ func TestClear(t *testing.T) {
 		assert.Equal(t, 0, len(dirEntries))
 	}
 }
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+	var migrated int
+	registry := keyvalue.NewMigrationRegistry()
+	assert.NoError(t, registry.Register(keyvalue.Migration{
+		Version: 1,
+		Migrate: func(context.Context, keyvalue.Store) error {
+			migrated++
+			return nil
+		},
+	}))
+	openFS := func(name string, options Options) *FS {
+		t.Helper()
+		name = testDBPrefix + t.Name() + "/" + name
+		fs, err := NewFS(context.Background(), name, options)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		t.Cleanup(func() {
+			req, err := idb.Global().DeleteDatabase(name)
+			assert.NoError(t, err)
+			assert.NoError(t, req.Await(context.Background()))
+		})
+		return fs
+	}
+
+	openFS("new", Options{Migrations: registry})
+	assert.Equal(t, 0, migrated)
+
+	oldFS := openFS("old", Options{})
+	assert.NoError(t, hackpadfs.WriteFullFile(oldFS, "foo", []byte("foo"), 0600))
+	openFS("old", Options{Migrations: registry})
+	assert.Equal(t, 1, migrated)
+	openFS("old", Options{Migrations: registry})
+	assert.Equal(t, 1, migrated)
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+	fs1 := makeFS(t)
+	name, err := fs1.db.Name()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fs2, err := NewFS(context.Background(), name, Options{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs1, "foo", nil, 0600))
+
+	lock, err := fs1.Lock("foo", hackpadfs.LockExclusive)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = fs2.Lock("foo", hackpadfs.LockShared)
+	assert.ErrorIs(t, hackpadfs.ErrWouldBlock, err)
+	assert.NoError(t, lock.Unlock())
+
+	lock, err = fs2.Lock("foo", hackpadfs.LockShared)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, lock.Unlock())
+}
+
+func TestStatfs(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	usage, err := hackpadfs.Statfs(fs, ".")
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		t.Skip("Storage estimates are not supported:", err)
+	}
+	assert.NoError(t, err)
+	assert.NotEqual(t, int64(0), usage.Total)
+	assert.Equal(t, usage.Total, usage.Used+usage.Available)
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+
+	watcher, err := hackpadfs.Watch(fs, "dir")
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, watcher.Close()) }()
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "other", []byte("ignored"), 0600))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/foo", []byte("bar"), 0600))
+	assert.NoError(t, fs.Remove("dir/foo"))
+
+	var events []hackpadfs.WatchEvent
+	timeout := time.After(5 * time.Second)
+	for len(events) < 2 {
+		select {
+		case event := <-watcher.Events():
+			events = append(events, event)
+		case <-timeout:
+			t.Fatal("Timed out waiting for events. Received:", events)
+		}
+	}
+	assert.Equal(t, hackpadfs.WatchEvent{Name: "dir/foo", Op: hackpadfs.WatchWrite}, events[0])
+	assert.Equal(t, hackpadfs.WatchEvent{Name: "dir/foo", Op: hackpadfs.WatchRemove}, events[len(events)-1])
+}
+
+func TestWorkerFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "indexeddb worker",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			channel := js.Global().Get("MessageChannel").New()
+			ctx, cancel := context.WithCancel(context.Background())
+			tb.Cleanup(cancel)
+			go func() {
+				_ = ServeWorker(ctx, channel.Get("port2"), Options{})
+			}()
+			return makeFSWithOptions(tb, Options{Worker: channel.Get("port1")})
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
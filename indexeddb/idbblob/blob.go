@@ -7,6 +7,7 @@ package idbblob
 import (
 	"errors"
 	"fmt"
+	"io"
 	"sync/atomic"
 	"syscall/js"
 
@@ -33,6 +34,8 @@ var (
 		blob.SetBlob
 		blob.GrowBlob
 		blob.TruncateBlob
+		blob.ReaderAtBlob
+		blob.WriterAtBlob
 	} = &Blob{}
 )
 
@@ -44,6 +47,7 @@ type Blob struct {
 }
 
 // New creates a Blob wrapping the given JS Uint8Array buffer.
+// The buffer is not copied: reads copy only the requested ranges into Go, and the whole buffer is copied at most once, on the first call to Bytes().
 func New(unsafeBuf js.Value) (*Blob, error) {
 	buf := safejs.Safe(unsafeBuf)
 	truthy, err := buf.Truthy()
@@ -97,16 +101,56 @@ func fromBlob(b blob.Blob) (*Blob, error) {
 		value := safejs.Safe(b.JSValue())
 		return newBlob(value)
 	}
-	buf := b.Bytes()
-	jsBuf, err := uint8Array.New(len(buf))
+	jsBuf, err := copyToJS(b)
 	if err != nil {
 		return nil, err
 	}
-	_, err = safejs.CopyBytesToJS(jsBuf, buf)
+	return newBlob(jsBuf)
+}
+
+// ToJS returns a JS Uint8Array with the contents of 'b', for handing to JS APIs.
+// If 'b' is already backed by a JS value, like a Blob, that value is returned without copying.
+// Otherwise, the contents are copied directly into a new Uint8Array.
+func ToJS(b blob.Blob) (js.Value, error) {
+	if b, ok := b.(jswrapper.Wrapper); ok {
+		return b.JSValue(), nil
+	}
+	jsBuf, err := copyToJS(b)
+	return safejs.Unsafe(jsBuf), err
+}
+
+// copyChunkSize is the maximum number of bytes copied to JS at once
+const copyChunkSize = 64 << 10
+
+// copyToJS copies 'b' into a new Uint8Array.
+// Copies in chunks through blob.NewReaderAt(), so large blobs are not first copied into one large Go slice.
+func copyToJS(b blob.Blob) (safejs.Value, error) {
+	length := b.Len()
+	jsBuf, err := uint8Array.New(length)
 	if err != nil {
-		return nil, err
+		return safejs.Value{}, err
+	}
+	if length <= copyChunkSize {
+		_, err := safejs.CopyBytesToJS(jsBuf, b.Bytes())
+		return jsBuf, err
+	}
+	reader := blob.NewReaderAt(b)
+	chunk := blob.DefaultPool.Get(copyChunkSize)
+	defer blob.DefaultPool.Put(chunk)
+	for offset := 0; offset < length; offset += copyChunkSize {
+		n, err := reader.ReadAt(chunk, int64(offset))
+		if err != nil && !errors.Is(err, io.EOF) {
+			return safejs.Value{}, err
+		}
+		subarray, err := jsBuf.Call("subarray", offset, offset+n)
+		if err != nil {
+			return safejs.Value{}, err
+		}
+		if _, err := safejs.CopyBytesToJS(subarray, chunk[:n]); err != nil {
+			return safejs.Value{}, err
+		}
 	}
-	return newBlob(jsBuf)
+	return jsBuf, nil
 }
 
 func (b *Blob) currentBytes() *blob.Bytes {
@@ -210,7 +254,8 @@ func (b *Blob) Slice(start, end int64) (blob.Blob, error) {
 	return newBlob, nil
 }
 
-// Set implements blob.SetBlob
+// Set implements blob.SetBlob.
+// JS-backed sources are copied entirely within JS. Other sources are copied into JS once, rather than through an intermediate Uint8Array.
 func (b *Blob) Set(src blob.Blob, destStart int64) (n int, err error) {
 	if destStart < 0 {
 		return 0, errors.New("negative offset")
@@ -220,15 +265,35 @@ func (b *Blob) Set(src blob.Blob, destStart int64) (n int, err error) {
 	}
 
 	bValue := safejs.Safe(b.JSValue())
-	srcValue := safejs.Safe(FromBlob(src).JSValue())
-	_, err = bValue.Call("set", srcValue, destStart)
+	if wrapper, ok := src.(jswrapper.Wrapper); ok {
+		_, err = bValue.Call("set", safejs.Safe(wrapper.JSValue()), destStart)
+		if err != nil {
+			return 0, err
+		}
+		n = src.Len()
+		if buf := b.currentBytes(); buf != nil {
+			_, err := buf.Set(src, destStart)
+			if err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
+	}
+
+	srcBytes := src.Bytes()
+	if destStart+int64(len(srcBytes)) > int64(b.Len()) {
+		return 0, fmt.Errorf("Source too large for offset %d: %d", destStart, len(srcBytes))
+	}
+	subarray, err := bValue.Call("subarray", destStart, destStart+int64(len(srcBytes)))
+	if err != nil {
+		return 0, err
+	}
+	n, err = safejs.CopyBytesToJS(subarray, srcBytes)
 	if err != nil {
 		return 0, err
 	}
-	n = src.Len()
-
 	if buf := b.currentBytes(); buf != nil {
-		_, err := buf.Set(src, destStart)
+		_, err := buf.WriteAt(srcBytes, destStart)
 		if err != nil {
 			return 0, err
 		}
@@ -236,6 +301,67 @@ func (b *Blob) Set(src blob.Blob, destStart int64) (n int, err error) {
 	return n, nil
 }
 
+// ReadAt implements blob.ReaderAtBlob. Only copies the requested range out of JS.
+func (b *Blob) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if buf := b.currentBytes(); buf != nil {
+		return buf.ReadAt(p, off)
+	}
+	length := atomic.LoadInt64(&b.length)
+	if off >= length {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > length {
+		end = length
+	}
+	value := safejs.Safe(b.JSValue())
+	subarray, err := value.Call("subarray", off, end)
+	if err != nil {
+		return 0, err
+	}
+	n, err = safejs.CopyBytesToGo(p, subarray)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteAt implements blob.WriterAtBlob. Only copies 'p' into JS, without an intermediate Blob.
+func (b *Blob) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	length := atomic.LoadInt64(&b.length)
+	if off > length {
+		return 0, io.ErrShortWrite
+	}
+	end := off + int64(len(p))
+	if end > length {
+		end = length
+	}
+	value := safejs.Safe(b.JSValue())
+	subarray, err := value.Call("subarray", off, end)
+	if err != nil {
+		return 0, err
+	}
+	n, err = safejs.CopyBytesToJS(subarray, p[:end-off])
+	if err != nil {
+		return 0, err
+	}
+	if buf := b.currentBytes(); buf != nil {
+		if _, err := buf.WriteAt(p[:n], off); err != nil {
+			return 0, err
+		}
+	}
+	if n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
 // Grow implements blob.GrowBlob
 func (b *Blob) Grow(off int64) error {
 	newLength := atomic.LoadInt64(&b.length) + off
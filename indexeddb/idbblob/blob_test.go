@@ -0,0 +1,82 @@
+//go:build wasm
+// +build wasm
+
+package idbblob
+
+import (
+	"bytes"
+	"io"
+	"syscall/js"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func TestToJS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		size        int
+	}{
+		{description: "small", size: 10},
+		{description: "multiple chunks", size: copyChunkSize*2 + 10},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			contents := bytes.Repeat([]byte("abc"), tc.size/3)
+			value, err := ToJS(blob.NewBytes(contents))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			buf := make([]byte, value.Length())
+			js.CopyBytesToGo(buf, value)
+			assert.Equal(t, contents, buf)
+
+			b, err := New(value)
+			assert.NoError(t, err)
+			sameValue, err := ToJS(b)
+			assert.NoError(t, err)
+			assert.Equal(t, true, value.Equal(sameValue))
+		})
+	}
+}
+
+func TestLazyRead(t *testing.T) {
+	t.Parallel()
+	value := js.Global().Get("Uint8Array").New(5)
+	js.CopyBytesToJS(value, []byte("hello"))
+	b, err := New(value)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	buf := make([]byte, 10)
+	n, err := b.ReadAt(buf, 1)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "ello", string(buf[:n]))
+	assert.Equal(t, (*blob.Bytes)(nil), b.currentBytes())
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+	b, err := NewLength(10)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, make([]byte, 10), b.Bytes()) // populate the Go-side cache
+	n, err := b.Set(blob.NewBytes([]byte("hello")), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	jsSrc := FromBlob(blob.NewBytes([]byte("!!")))
+	_, err = b.Set(jsSrc, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, "\x00\x00hello\x00!!", string(b.Bytes()))
+
+	buf := make([]byte, 10)
+	js.CopyBytesToGo(buf, b.JSValue())
+	assert.Equal(t, "\x00\x00hello\x00!!", string(buf))
+
+	_, err = b.Set(blob.NewBytes([]byte("too long")), 5)
+	assert.Error(t, err)
+}
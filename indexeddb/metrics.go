@@ -0,0 +1,148 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Operation kinds reported in Operation.Op
+const (
+	OpTransaction = "transaction"
+	OpBatch       = "batch"
+	OpRead        = "read"
+	OpReadDir     = "readdir"
+)
+
+// Operation describes a completed storage operation. See Options.OnOperation.
+//
+// Time spent in Go, like encoding and decoding records, is roughly Duration minus IndexedDB, Copy, and Queue.
+type Operation struct {
+	// Op is the kind of operation, like OpTransaction or OpRead
+	Op string
+	// Path is the file path for single-file operations, like OpRead. Empty for transactions, which may include many files.
+	Path string
+	// Ops is the number of get and set operations in a transaction or batch
+	Ops int
+	// Duration is the total time from the operation's start until it completed
+	Duration time.Duration
+	// IndexedDB is the time spent waiting on IndexedDB, including time waiting for other transactions on the same files to finish
+	IndexedDB time.Duration
+	// Copy is the time spent copying file contents from Go into JavaScript
+	Copy time.Duration
+	// Queue is the time spent waiting for a batch to start. See Options.BatchWindow.
+	Queue time.Duration
+	// Err is the operation's error, if it failed
+	Err error
+}
+
+// String formats 'op' for logs
+func (op Operation) String() string {
+	name := op.Op
+	if op.Path != "" {
+		name += " " + op.Path
+	}
+	return fmt.Sprintf("indexeddb: %s took %s (IndexedDB: %s, copy: %s, queue: %s, ops: %d, err: %v)", name, op.Duration, op.IndexedDB, op.Copy, op.Queue, op.Ops, op.Err)
+}
+
+// OperationFunc is called after each storage operation. See Options.OnOperation and Options.OnSlowOperation.
+type OperationFunc func(Operation)
+
+// reportOperation sends 'op' to Options.OnOperation, and to Options.OnSlowOperation if it's slower than Options.SlowOperationThreshold
+func (s *store) reportOperation(op Operation) {
+	if s.options.OnOperation != nil {
+		s.options.OnOperation(op)
+	}
+	if s.reportsSlowOperations() && op.Duration >= s.options.SlowOperationThreshold {
+		s.options.OnSlowOperation(op)
+	}
+}
+
+func (s *store) reportsSlowOperations() bool {
+	return s.options.OnSlowOperation != nil && s.options.SlowOperationThreshold > 0
+}
+
+func (s *store) reportsOperations() bool {
+	return s.options.OnOperation != nil || s.reportsSlowOperations()
+}
+
+// histogramBounds are the upper bounds of LatencyHistogram buckets. Durations beyond the last bound are counted in a final, unbounded bucket.
+var histogramBounds = func() []time.Duration {
+	var bounds []time.Duration
+	for bound := 250 * time.Microsecond; bound <= 16*time.Second; bound *= 2 {
+		bounds = append(bounds, bound)
+	}
+	return bounds
+}()
+
+// LatencyHistogram aggregates Operation durations by Op, in exponentially sized buckets.
+// Pass its Observe method to Options.OnOperation.
+type LatencyHistogram struct {
+	mu  sync.Mutex
+	ops map[string]*HistogramSnapshot
+}
+
+// HistogramSnapshot is a copy of a LatencyHistogram's counts for one kind of Operation
+type HistogramSnapshot struct {
+	// Count is the total number of operations
+	Count int
+	// Errors is the number of failed operations
+	Errors int
+	// Sum is the total duration of all operations
+	Sum time.Duration
+	// Buckets count operations by duration, in ascending order
+	Buckets []HistogramBucket
+}
+
+// HistogramBucket counts operations with durations less than or equal to UpperBound, and greater than the previous bucket's UpperBound.
+// The last bucket has an UpperBound of -1 and counts all longer operations.
+type HistogramBucket struct {
+	UpperBound time.Duration
+	Count      int
+}
+
+// NewLatencyHistogram returns an empty LatencyHistogram
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{ops: make(map[string]*HistogramSnapshot)}
+}
+
+// Observe adds 'op' to the histogram. Implements OperationFunc.
+func (h *LatencyHistogram) Observe(op Operation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshot, ok := h.ops[op.Op]
+	if !ok {
+		snapshot = &HistogramSnapshot{Buckets: make([]HistogramBucket, len(histogramBounds)+1)}
+		for i, bound := range histogramBounds {
+			snapshot.Buckets[i].UpperBound = bound
+		}
+		snapshot.Buckets[len(histogramBounds)].UpperBound = -1
+		h.ops[op.Op] = snapshot
+	}
+	snapshot.Count++
+	snapshot.Sum += op.Duration
+	if op.Err != nil {
+		snapshot.Errors++
+	}
+	bucket := sort.Search(len(histogramBounds), func(i int) bool {
+		return op.Duration <= histogramBounds[i]
+	})
+	snapshot.Buckets[bucket].Count++
+}
+
+// Snapshot returns a copy of the current counts, keyed by Operation.Op
+func (h *LatencyHistogram) Snapshot() map[string]HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snapshots := make(map[string]HistogramSnapshot, len(h.ops))
+	for op, snapshot := range h.ops {
+		snapshotCopy := *snapshot
+		snapshotCopy.Buckets = append([]HistogramBucket(nil), snapshot.Buckets...)
+		snapshots[op] = snapshotCopy
+	}
+	return snapshots
+}
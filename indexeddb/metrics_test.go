@@ -0,0 +1,89 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	t.Parallel()
+	h := NewLatencyHistogram()
+	h.Observe(Operation{Op: OpRead, Duration: 100 * time.Microsecond})
+	h.Observe(Operation{Op: OpRead, Duration: 300 * time.Microsecond})
+	h.Observe(Operation{Op: OpRead, Duration: time.Minute, Err: errors.New("some error")})
+	h.Observe(Operation{Op: OpTransaction, Duration: time.Millisecond})
+
+	snapshot := h.Snapshot()
+	assert.Equal(t, 2, len(snapshot))
+	read := snapshot[OpRead]
+	assert.Equal(t, 3, read.Count)
+	assert.Equal(t, 1, read.Errors)
+	assert.Equal(t, time.Minute+400*time.Microsecond, read.Sum)
+	assert.Equal(t, HistogramBucket{UpperBound: 250 * time.Microsecond, Count: 1}, read.Buckets[0])
+	assert.Equal(t, HistogramBucket{UpperBound: 500 * time.Microsecond, Count: 1}, read.Buckets[1])
+	assert.Equal(t, HistogramBucket{UpperBound: -1, Count: 1}, read.Buckets[len(read.Buckets)-1])
+
+	// snapshots are copies
+	read.Buckets[0].Count = 100
+	assert.Equal(t, 1, h.Snapshot()[OpRead].Buckets[0].Count)
+}
+
+func TestOnOperation(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var ops []Operation
+	fs := makeFSWithOptions(t, Options{
+		OnOperation: func(op Operation) {
+			mu.Lock()
+			ops = append(ops, op)
+			mu.Unlock()
+		},
+	})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0600))
+	_, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var sawRead, sawTransaction bool
+	for _, op := range ops {
+		assert.NoError(t, op.Err)
+		if op.Op == OpRead && op.Path == "foo" {
+			sawRead = true
+		}
+		if op.Op == OpTransaction && op.Ops > 0 {
+			sawTransaction = true
+		}
+	}
+	assert.Equal(t, true, sawRead)
+	assert.Equal(t, true, sawTransaction)
+}
+
+func TestOnSlowOperation(t *testing.T) {
+	t.Parallel()
+	var slowOps []Operation
+	s := &store{options: Options{
+		SlowOperationThreshold: time.Second,
+		OnSlowOperation: func(op Operation) {
+			slowOps = append(slowOps, op)
+		},
+	}}
+	assert.Equal(t, true, s.reportsOperations())
+	fastOp := Operation{Op: OpRead, Path: "foo", Duration: time.Millisecond}
+	slowOp := Operation{Op: OpRead, Path: "bar", Duration: 2 * time.Second}
+	s.reportOperation(fastOp)
+	s.reportOperation(slowOp)
+	assert.Equal(t, []Operation{slowOp}, slowOps)
+	assert.Equal(t, "indexeddb: read bar took 2s (IndexedDB: 0s, copy: 0s, queue: 0s, ops: 0, err: <nil>)", slowOp.String())
+
+	silent := &store{options: Options{SlowOperationThreshold: time.Second}}
+	assert.Equal(t, false, silent.reportsOperations())
+}
@@ -0,0 +1,86 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/safejs"
+)
+
+// schemaVersion is the database's current schema version. Must equal the version of the last migration.
+const schemaVersion = 3
+
+// migration upgrades the database schema to 'version' from the version before it
+type migration struct {
+	version uint
+	upgrade func(db *idb.Database) error
+}
+
+// migrations upgrade the database schema one version at a time, inside the "upgradeneeded" event.
+//
+// To change the schema, append a migration and increment schemaVersion.
+// Never change an existing migration, since existing databases have already run it.
+var migrations = []migration{
+	{version: 1, upgrade: createStores},
+	{version: 2, upgrade: createMetaStore},
+	{version: 3, upgrade: createLocksStore},
+}
+
+// ErrSchemaTooNew is returned when opening a database created by a newer version of this package
+var ErrSchemaTooNew = errors.New("database schema version is newer than supported")
+
+// upgradeSchema runs all migrations after 'oldVersion', up to and including 'newVersion'
+func upgradeSchema(db *idb.Database, oldVersion, newVersion uint, onUpgrade UpgradeFunc) error {
+	for _, m := range migrations {
+		if m.version <= oldVersion || m.version > newVersion {
+			continue
+		}
+		if err := m.upgrade(db); err != nil {
+			return fmt.Errorf("failed to upgrade database schema to version %d: %w", m.version, err)
+		}
+	}
+	if onUpgrade != nil {
+		return onUpgrade(db, oldVersion, newVersion)
+	}
+	return nil
+}
+
+func wrapOpenErr(err error) error {
+	if errors.Is(err, idb.NewDOMException("VersionError")) {
+		return fmt.Errorf("%w: %d", ErrSchemaTooNew, schemaVersion)
+	}
+	return err
+}
+
+func createStores(db *idb.Database) error {
+	_, err := db.CreateObjectStore(contentsStore, idb.ObjectStoreOptions{})
+	if err != nil {
+		return err
+	}
+	infos, err := db.CreateObjectStore(infoStore, idb.ObjectStoreOptions{})
+	if err != nil {
+		return err
+	}
+	jsParentKey, err := safejs.ValueOf(parentKey)
+	if err != nil {
+		return err
+	}
+	_, err = infos.CreateIndex(parentKey, safejs.Unsafe(jsParentKey), idb.IndexOptions{})
+	return err
+}
+
+// createMetaStore creates the store holding the keyvalue schema version, see Options.Migrations
+func createMetaStore(db *idb.Database) error {
+	_, err := db.CreateObjectStore(metaStore, idb.ObjectStoreOptions{})
+	return err
+}
+
+// createLocksStore creates the store holding lock records shared by every FS using this database, see FS.Lock()
+func createLocksStore(db *idb.Database) error {
+	_, err := db.CreateObjectStore(locksStore, idb.ObjectStoreOptions{})
+	return err
+}
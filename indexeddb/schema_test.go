@@ -0,0 +1,54 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestMigrationVersions(t *testing.T) {
+	t.Parallel()
+	for i, m := range migrations {
+		assert.Equal(t, uint(i+1), m.version)
+	}
+	assert.Equal(t, uint(schemaVersion), migrations[len(migrations)-1].version)
+}
+
+func TestOnUpgrade(t *testing.T) {
+	t.Parallel()
+	name := fmt.Sprintf("%s%s", testDBPrefix, t.Name())
+	var calls []uint
+	fs, err := NewFS(context.Background(), name, Options{
+		OnUpgrade: func(db *idb.Database, oldVersion, newVersion uint) error {
+			calls = append(calls, oldVersion, newVersion)
+			_, err := db.CreateObjectStore("app", idb.ObjectStoreOptions{})
+			return err
+		},
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, fs.db.Close())
+		req, err := idb.Global().DeleteDatabase(name)
+		assert.NoError(t, err)
+		assert.NoError(t, req.Await(context.Background()))
+	})
+	assert.Equal(t, []uint{0, schemaVersion}, calls)
+	storeNames, err := fs.db.ObjectStoreNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app", contentsStore, infoStore, locksStore, metaStore}, storeNames)
+
+	failErr := errors.New("some error")
+	_, err = NewFS(context.Background(), name+"-fail", Options{
+		OnUpgrade: func(*idb.Database, uint, uint) error {
+			return failErr
+		},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/go-indexeddb/idb"
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/jspromise"
+	"github.com/hack-pad/safejs"
+)
+
+const (
+	defaultStoragePressureThreshold = 0.9
+	defaultStoragePressureInterval  = time.Minute
+)
+
+// navigatorStorage returns the StorageManager, navigator.storage. Returns hackpadfs.ErrNotImplemented if it's not supported.
+func navigatorStorage() (safejs.Value, error) {
+	navigator, err := safejs.Global().Get("navigator")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if navigator.IsUndefined() {
+		return safejs.Value{}, hackpadfs.ErrNotImplemented
+	}
+	storage, err := navigator.Get("storage")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if storage.IsUndefined() {
+		return safejs.Value{}, hackpadfs.ErrNotImplemented
+	}
+	return storage, nil
+}
+
+func estimateStorage(ctx context.Context) (hackpadfs.FSUsage, error) {
+	storage, err := navigatorStorage()
+	if err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	estimate, err := jspromise.Call(ctx, storage, "estimate")
+	if err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	quota, err := getFloat(estimate, "quota")
+	if err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	used, err := getFloat(estimate, "usage")
+	if err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	usage := hackpadfs.FSUsage{
+		Total: int64(quota),
+		Used:  int64(used),
+	}
+	if usage.Total > usage.Used {
+		usage.Available = usage.Total - usage.Used
+	}
+	return usage, nil
+}
+
+func getFloat(value safejs.Value, property string) (float64, error) {
+	jsValue, err := value.Get(property)
+	if err != nil {
+		return 0, err
+	}
+	return jsValue.Float()
+}
+
+// callStorageBool calls navigator.storage's method 'm', which resolves to a boolean
+func callStorageBool(ctx context.Context, m string) (bool, error) {
+	storage, err := navigatorStorage()
+	if err != nil {
+		return false, err
+	}
+	result, err := jspromise.Call(ctx, storage, m)
+	if err != nil {
+		return false, err
+	}
+	return result.Bool()
+}
+
+// Persisted returns true if the browser has granted persistent storage, so it won't evict this FS's data under storage pressure.
+// See Options.RequestPersistence.
+func (fs *FS) Persisted(ctx context.Context) (bool, error) {
+	return callStorageBool(ctx, "persisted")
+}
+
+// pressureMonitor calls a StoragePressureFunc when storage usage crosses a threshold.
+// Usage is estimated after writes, at most once per interval, or immediately if a write exceeds the quota.
+type pressureMonitor struct {
+	onPressure StoragePressureFunc
+	threshold  float64
+	interval   time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	checking  bool
+}
+
+func newPressureMonitor(options Options) *pressureMonitor {
+	if options.OnStoragePressure == nil {
+		return nil
+	}
+	threshold := options.StoragePressureThreshold
+	if threshold <= 0 {
+		threshold = defaultStoragePressureThreshold
+	}
+	return &pressureMonitor{
+		onPressure: options.OnStoragePressure,
+		threshold:  threshold,
+		interval:   defaultStoragePressureInterval,
+	}
+}
+
+// committed checks storage pressure after a write transaction completes with 'err'
+func (p *pressureMonitor) committed(err error) {
+	quotaExceeded := errors.Is(err, idb.NewDOMException("QuotaExceededError"))
+	p.mu.Lock()
+	if p.checking || (!quotaExceeded && time.Since(p.lastCheck) < p.interval) {
+		p.mu.Unlock()
+		return
+	}
+	p.checking = true
+	p.lastCheck = time.Now()
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			p.mu.Lock()
+			p.checking = false
+			p.mu.Unlock()
+		}()
+		usage, err := estimateStorage(context.Background())
+		if err != nil {
+			return
+		}
+		if quotaExceeded || float64(usage.Used) >= p.threshold*float64(usage.Total) {
+			persisted, _ := callStorageBool(context.Background(), "persisted")
+			p.onPressure(StoragePressure{Usage: usage, QuotaExceeded: quotaExceeded, Persisted: persisted})
+		}
+	}()
+}
+
+// StoragePressure describes storage usage when nearing the quota. See Options.OnStoragePressure.
+type StoragePressure struct {
+	// Usage is the estimated storage usage for this origin
+	Usage hackpadfs.FSUsage
+	// QuotaExceeded is true if a write failed for exceeding the quota
+	QuotaExceeded bool
+	// Persisted is true if storage is persistent. If false, the browser may evict all of this origin's data.
+	Persisted bool
+}
+
+// StoragePressureFunc is called when storage usage is high. See Options.OnStoragePressure.
+type StoragePressureFunc func(StoragePressure)
@@ -0,0 +1,47 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestPersisted(t *testing.T) {
+	t.Parallel()
+	fs := makeFSWithOptions(t, Options{RequestPersistence: true})
+	_, err := fs.Persisted(context.Background())
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		t.Skip("Persistent storage is not supported:", err)
+	}
+	assert.NoError(t, err)
+}
+
+func TestStoragePressure(t *testing.T) {
+	t.Parallel()
+	if _, err := navigatorStorage(); err != nil {
+		t.Skip("Storage estimates are not supported:", err)
+	}
+	pressures := make(chan StoragePressure, 1)
+	fs := makeFSWithOptions(t, Options{
+		OnStoragePressure: func(pressure StoragePressure) {
+			pressures <- pressure
+		},
+		StoragePressureThreshold: 1e-15, // any usage is high usage
+	})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("bar"), 0600))
+
+	select {
+	case pressure := <-pressures:
+		assert.Equal(t, false, pressure.QuotaExceeded)
+		assert.NotEqual(t, int64(0), pressure.Usage.Used)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for storage pressure")
+	}
+}
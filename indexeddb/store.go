@@ -5,8 +5,10 @@ package indexeddb
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
@@ -21,16 +23,26 @@ var (
 	_ interface {
 		keyvalue.Store
 		keyvalue.TransactionStore
+		keyvalue.KeysStore
+		keyvalue.SchemaStore
+		keyvalue.LockStore
 	} = &store{}
 )
 
 type store struct {
-	db      *idb.Database
-	options Options
+	db       *idb.Database
+	options  Options
+	batcher  *batcher
+	watches  *watchHub        // nil if changes are not delivered to watchers
+	pressure *pressureMonitor // nil if not monitoring storage pressure
 }
 
 func newStore(db *idb.Database, options Options) *store {
-	return &store{db: db, options: options}
+	s := &store{db: db, options: options, pressure: newPressureMonitor(options)}
+	if options.BatchWindow > 0 {
+		s.batcher = newBatcher(s, options.BatchWindow)
+	}
+	return s
 }
 
 func (s *store) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
@@ -121,6 +133,8 @@ func (g *getFileRequest) parseResult(result safejs.Value, err error) (keyvalue.F
 	var getDirNames func() ([]string, error)
 	if mode.IsDir() {
 		getDirNames = g.store.getDirNames(g.path)
+	} else if g.store.prefetches(int64(initialSize)) {
+		getData = g.store.prefetchFileData(g.path)
 	} else {
 		getData = g.store.getFileData(g.path)
 	}
@@ -128,7 +142,14 @@ func (g *getFileRequest) parseResult(result safejs.Value, err error) (keyvalue.F
 }
 
 func (s *store) getFileData(path string) func() (blob.Blob, error) {
-	return func() (blob.Blob, error) {
+	return func() (data blob.Blob, err error) {
+		start := time.Now()
+		var awaitDuration time.Duration
+		if s.reportsOperations() {
+			defer func() {
+				s.reportOperation(Operation{Op: OpRead, Path: path, Ops: 1, Duration: time.Since(start), IndexedDB: awaitDuration, Err: err})
+			}()
+		}
 		txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
 			Mode:       idb.TransactionReadOnly,
 			Durability: s.options.TransactionDurability,
@@ -148,7 +169,9 @@ func (s *store) getFileData(path string) func() (blob.Blob, error) {
 		if err != nil {
 			return nil, err
 		}
+		awaitStart := time.Now()
 		value, err := req.Await(context.Background())
+		awaitDuration = time.Since(awaitStart)
 		if value.IsUndefined() {
 			return nil, hackpadfs.ErrNotExist
 		}
@@ -159,8 +182,49 @@ func (s *store) getFileData(path string) func() (blob.Blob, error) {
 	}
 }
 
+func (s *store) prefetches(size int64) bool {
+	return s.options.PrefetchWindow > 0 && size <= s.options.PrefetchWindow
+}
+
+// prefetchFileData starts reading path's contents in a background transaction.
+// The first call to the returned func waits for the prefetched contents, later calls read them again like getFileData.
+func (s *store) prefetchFileData(path string) func() (blob.Blob, error) {
+	getData := s.getFileData(path)
+	var (
+		data blob.Blob
+		err  error
+	)
+	done := make(chan struct{})
+	go func() {
+		data, err = getData()
+		close(done)
+	}()
+
+	var mu sync.Mutex
+	used := false
+	return func() (blob.Blob, error) {
+		mu.Lock()
+		prefetched := !used
+		used = true
+		mu.Unlock()
+		if !prefetched {
+			// Blobs are mutable, so only the first caller may own the prefetched one.
+			return getData()
+		}
+		<-done
+		return data, err
+	}
+}
+
 func (s *store) getDirNames(name string) func() ([]string, error) {
 	return func() (_ []string, err error) {
+		start := time.Now()
+		var awaitDuration time.Duration
+		if s.reportsOperations() {
+			defer func() {
+				s.reportOperation(Operation{Op: OpReadDir, Path: name, Ops: 1, Duration: time.Since(start), IndexedDB: awaitDuration, Err: err})
+			}()
+		}
 		txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
 			Mode:       idb.TransactionReadOnly,
 			Durability: s.options.TransactionDurability,
@@ -189,7 +253,9 @@ func (s *store) getDirNames(name string) func() ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
+		awaitStart := time.Now()
 		jsKeys, err := keysReq.Await(context.Background())
+		awaitDuration = time.Since(awaitStart)
 		var keys []string
 		if err == nil {
 			for _, jsKey := range jsKeys {
@@ -200,6 +266,216 @@ func (s *store) getDirNames(name string) func() ([]string, error) {
 	}
 }
 
+// Keys implements keyvalue.KeysStore
+func (s *store) Keys(ctx context.Context) ([]string, error) {
+	stores := []string{infoStore, contentsStore}
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadOnly,
+		Durability: s.options.TransactionDurability,
+	}, stores[0], stores[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	var requests []*idb.ArrayRequest
+	for _, name := range stores {
+		objectStore, err := txn.ObjectStore(name)
+		if err != nil {
+			return nil, err
+		}
+		req, err := objectStore.GetAllKeys()
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	// content records without a matching info record are orphans too, so include keys from both stores
+	seenKeys := make(map[string]bool)
+	var keys []string
+	for _, req := range requests {
+		jsKeys, err := req.Await(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, jsKey := range jsKeys {
+			key := jsKey.String()
+			if !seenKeys[key] {
+				seenKeys[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// SchemaVersion implements keyvalue.SchemaStore
+func (s *store) SchemaVersion(ctx context.Context) (int, error) {
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadOnly,
+		Durability: s.options.TransactionDurability,
+	}, metaStore)
+	if err != nil {
+		return 0, err
+	}
+	meta, err := txn.ObjectStore(metaStore)
+	if err != nil {
+		return 0, err
+	}
+	jsKey, err := safejs.ValueOf(schemaVersionKey)
+	if err != nil {
+		return 0, err
+	}
+	req, err := meta.Get(safejs.Unsafe(jsKey))
+	if err != nil {
+		return 0, err
+	}
+	value, err := req.Await(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if value.IsUndefined() {
+		return 0, nil
+	}
+	return safejs.Safe(value).Int()
+}
+
+// SetSchemaVersion implements keyvalue.SchemaStore
+func (s *store) SetSchemaVersion(ctx context.Context, version int) error {
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadWrite,
+		Durability: s.options.TransactionDurability,
+	}, metaStore)
+	if err != nil {
+		return err
+	}
+	meta, err := txn.ObjectStore(metaStore)
+	if err != nil {
+		return err
+	}
+	jsKey, err := safejs.ValueOf(schemaVersionKey)
+	if err != nil {
+		return err
+	}
+	jsVersion, err := safejs.ValueOf(version)
+	if err != nil {
+		return err
+	}
+	_, err = meta.PutKey(safejs.Unsafe(jsKey), safejs.Unsafe(jsVersion))
+	if err != nil {
+		return err
+	}
+	err = txn.Commit()
+	if err != nil {
+		return err
+	}
+	return txn.Await(ctx)
+}
+
+// GetLock implements keyvalue.LockStore
+func (s *store) GetLock(ctx context.Context, path string) (keyvalue.LockRecord, error) {
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadOnly,
+		Durability: s.options.TransactionDurability,
+	}, locksStore)
+	if err != nil {
+		return keyvalue.LockRecord{}, err
+	}
+	locks, err := txn.ObjectStore(locksStore)
+	if err != nil {
+		return keyvalue.LockRecord{}, err
+	}
+	jsKey, err := safejs.ValueOf(path)
+	if err != nil {
+		return keyvalue.LockRecord{}, err
+	}
+	req, err := locks.Get(safejs.Unsafe(jsKey))
+	if err != nil {
+		return keyvalue.LockRecord{}, err
+	}
+	value, err := req.Await(ctx)
+	if err != nil {
+		return keyvalue.LockRecord{}, err
+	}
+	return parseLockRecord(safejs.Safe(value))
+}
+
+// CompareAndSwapLock implements keyvalue.LockStore
+//
+// The lock record is read and replaced in one read-write transaction, which IndexedDB runs atomically, even across tabs.
+func (s *store) CompareAndSwapLock(ctx context.Context, path string, revision uint64, record keyvalue.LockRecord) (bool, error) {
+	record.Revision = revision + 1
+	encodedRecord, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
+		Mode:       idb.TransactionReadWrite,
+		Durability: s.options.TransactionDurability,
+	}, locksStore)
+	if err != nil {
+		return false, err
+	}
+	locks, err := txn.ObjectStore(locksStore)
+	if err != nil {
+		return false, err
+	}
+	jsKey, err := safejs.ValueOf(path)
+	if err != nil {
+		return false, err
+	}
+	jsRecord, err := safejs.ValueOf(string(encodedRecord))
+	if err != nil {
+		return false, err
+	}
+	req, err := locks.Get(safejs.Unsafe(jsKey))
+	if err != nil {
+		return false, err
+	}
+	var swapped bool
+	var swapErr error
+	// put from the Get's success handler, while the transaction is still active
+	err = req.Listen(ctx, func() {
+		value, err := req.Result()
+		if err != nil {
+			swapErr = err
+			return
+		}
+		current, err := parseLockRecord(safejs.Safe(value))
+		if err != nil {
+			swapErr = err
+			return
+		}
+		if current.Revision != revision {
+			return
+		}
+		_, swapErr = locks.PutKey(safejs.Unsafe(jsKey), safejs.Unsafe(jsRecord))
+		swapped = swapErr == nil
+	}, func() {
+		swapErr = req.Err()
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := txn.Await(ctx); err != nil {
+		return false, err
+	}
+	return swapped, swapErr
+}
+
+// parseLockRecord decodes a lock record, which is stored as JSON. Missing records are returned as a zero LockRecord.
+func parseLockRecord(value safejs.Value) (keyvalue.LockRecord, error) {
+	var record keyvalue.LockRecord
+	if value.IsUndefined() {
+		return record, nil
+	}
+	encodedRecord, err := value.String()
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal([]byte(encodedRecord), &record)
+	return record, err
+}
+
 func getMode(fileRecord safejs.Value) (hackpadfs.FileMode, error) {
 	mode, err := fileRecord.Get("Mode")
 	if err != nil {
@@ -215,7 +491,7 @@ var errAborted = idb.NewDOMException("AbortError")
 
 func (s *store) Set(ctx context.Context, name string, record keyvalue.FileRecord) error {
 	var data blob.Blob
-	if record != nil && record.Mode().IsRegular() { // i.e. "should not delete" AND "is a regular file"
+	if record != nil && !record.Mode().IsDir() { // i.e. "should not delete" AND "is a regular file or symlink"
 		var err error
 		data, err = record.Data()
 		if err != nil {
@@ -250,7 +526,11 @@ func setFileContents(contents *idb.ObjectStore, name string, data blob.Blob) err
 	if err != nil {
 		return err
 	}
-	_, err = contents.PutKey(safejs.Unsafe(jsName), idbblob.FromBlob(data).JSValue())
+	jsData, err := idbblob.ToJS(data)
+	if err != nil {
+		return err
+	}
+	_, err = contents.PutKey(safejs.Unsafe(jsName), jsData)
 	return err
 }
 
@@ -340,16 +620,27 @@ func requireParentDirectoryExists(ctx context.Context, infos *idb.ObjectStore, n
 }
 
 func (s *store) Transaction(options keyvalue.TransactionOptions) (keyvalue.Transaction, error) {
+	if s.batcher != nil && options.Mode == keyvalue.TransactionReadWrite && !options.Durable {
+		return newBatchedTransaction(s, options), nil
+	}
+	return s.newTransaction(options)
+}
+
+func (s *store) newTransaction(options keyvalue.TransactionOptions) (*transaction, error) {
 	mode := idb.TransactionReadOnly
 	stores := []string{infoStore}
 	if options.Mode == keyvalue.TransactionReadWrite {
 		mode = idb.TransactionReadWrite
 		stores = append(stores, contentsStore)
 	}
+	durability := s.options.TransactionDurability
+	if options.Durable {
+		durability = idb.DurabilityStrict
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	txn, err := s.db.TransactionWithOptions(idb.TransactionOptions{
 		Mode:       mode,
-		Durability: s.options.TransactionDurability,
+		Durability: durability,
 	}, stores[0], stores[1:]...)
 	return &transaction{
 		ctx:     ctx,
@@ -357,5 +648,6 @@ func (s *store) Transaction(options keyvalue.TransactionOptions) (keyvalue.Trans
 		store:   s,
 		txn:     txn,
 		results: make(map[keyvalue.OpID]keyvalue.OpResult),
+		start:   time.Now(),
 	}, err
 }
@@ -99,3 +99,34 @@ func TestStoreGetParentIsFile(t *testing.T) {
 	err := store.Set(ctx, "foo/bar", barRecord)
 	assert.ErrorIs(t, hackpadfs.ErrNotDir, err)
 }
+
+func TestStorePrefetch(t *testing.T) {
+	t.Parallel()
+	store := newStore(makeFS(t).db, Options{PrefetchWindow: 4})
+
+	ctx := context.Background()
+	small, _ := testFile("baz")
+	assert.NoError(t, store.Set(ctx, "small", small))
+	large, _ := testFile("bazbaz")
+	assert.NoError(t, store.Set(ctx, "large", large))
+
+	assert.Equal(t, true, store.prefetches(3))
+	assert.Equal(t, false, store.prefetches(6))
+
+	record, err := store.Get(ctx, "small")
+	assert.NoError(t, err)
+	data, err := record.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("baz"), data.Bytes())
+	_, err = data.(blob.SetBlob).Set(blob.NewBytes([]byte("x")), 0)
+	assert.NoError(t, err)
+	data, err = record.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("baz"), data.Bytes())
+
+	record, err = store.Get(ctx, "large")
+	assert.NoError(t, err)
+	data, err = record.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bazbaz"), data.Bytes())
+}
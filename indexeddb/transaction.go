@@ -8,6 +8,7 @@ import (
 	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/hack-pad/go-indexeddb/idb"
 	"github.com/hack-pad/hackpadfs"
@@ -23,7 +24,27 @@ type transaction struct {
 	nextOp         keyvalue.OpID
 	results        map[keyvalue.OpID]keyvalue.OpResult
 	pendingResults []func()
+	changes        []change
 	resultsMu      sync.Mutex
+
+	start     time.Time
+	copyNanos int64 // time spent copying file contents to JS
+}
+
+// change is a Set op's change to a file, delivered to watchers if the op succeeds
+type change struct {
+	op    keyvalue.OpID
+	event hackpadfs.WatchEvent
+}
+
+func (t *transaction) addChange(op keyvalue.OpID, name string, record keyvalue.FileRecord) {
+	event := hackpadfs.WatchEvent{Name: name, Op: hackpadfs.WatchWrite}
+	if record == nil {
+		event.Op = hackpadfs.WatchRemove
+	}
+	t.resultsMu.Lock()
+	t.changes = append(t.changes, change{op: op, event: event})
+	t.resultsMu.Unlock()
 }
 
 func (t *transaction) newOp() keyvalue.OpID {
@@ -140,12 +161,15 @@ func (t *transaction) set(op keyvalue.OpID, name string, record keyvalue.FileRec
 		if err != nil {
 			return nil, err
 		}
+		t.addChange(op, name, nil)
 		return req.Request, nil
 	}
 
 	if data != nil {
 		// set file contents
+		copyStart := time.Now()
 		err := setFileContents(contents, name, data)
+		atomic.AddInt64(&t.copyNanos, int64(time.Since(copyStart)))
 		if err != nil {
 			return nil, err
 		}
@@ -158,6 +182,7 @@ func (t *transaction) set(op keyvalue.OpID, name string, record keyvalue.FileRec
 	}
 
 	t.setPendingValidateErr(op, parentExistsReq)
+	t.addChange(op, name, record)
 	return req, nil
 }
 
@@ -185,7 +210,9 @@ func (t *transaction) SetHandler(name string, record keyvalue.FileRecord, data b
 }
 
 func (t *transaction) Commit(ctx context.Context) ([]keyvalue.OpResult, error) {
+	awaitStart := time.Now()
 	awaitErr := t.txn.Await(ctx)
+	awaitDuration := time.Since(awaitStart)
 	t.abort()
 	for _, fn := range t.pendingResults {
 		fn()
@@ -199,9 +226,43 @@ func (t *transaction) Commit(ctx context.Context) ([]keyvalue.OpResult, error) {
 	sort.Slice(results, func(a, b int) bool {
 		return results[a].Op < results[b].Op
 	})
+	if awaitErr == nil && t.store.watches != nil {
+		t.store.watches.notify(t.committedChanges())
+	}
+	if t.store.pressure != nil && t.hasChanges() {
+		t.store.pressure.committed(awaitErr)
+	}
+	if t.store.reportsOperations() {
+		t.store.reportOperation(Operation{
+			Op:        OpTransaction,
+			Ops:       len(results),
+			Duration:  time.Since(t.start),
+			IndexedDB: awaitDuration,
+			Copy:      time.Duration(atomic.LoadInt64(&t.copyNanos)),
+			Err:       getFirstCommitError(results, awaitErr),
+		})
+	}
 	return results, awaitErr
 }
 
+func (t *transaction) hasChanges() bool {
+	t.resultsMu.Lock()
+	defer t.resultsMu.Unlock()
+	return len(t.changes) > 0
+}
+
+func (t *transaction) committedChanges() []hackpadfs.WatchEvent {
+	t.resultsMu.Lock()
+	defer t.resultsMu.Unlock()
+	var events []hackpadfs.WatchEvent
+	for _, c := range t.changes {
+		if t.results[c.op].Err == nil {
+			events = append(events, c.event)
+		}
+	}
+	return events
+}
+
 func (t *transaction) Abort() error {
 	return t.txn.Abort()
 }
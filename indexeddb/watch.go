@@ -0,0 +1,257 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/safejs"
+)
+
+const broadcastChannelPrefix = "hackpadfs-indexeddb:"
+
+// watchHub delivers changes to this FS's watchers, and shares them with other FS's using the same database, like in other tabs.
+// Changes are shared over a BroadcastChannel named after the database.
+type watchHub struct {
+	dbName  string
+	channel safejs.Value // BroadcastChannel, undefined if unsupported
+
+	mu        sync.Mutex
+	watchers  map[*watcher]struct{}
+	listening bool
+}
+
+func newWatchHub(dbName string) *watchHub {
+	h := &watchHub{
+		dbName:   dbName,
+		channel:  safejs.Undefined(),
+		watchers: make(map[*watcher]struct{}),
+	}
+	broadcastChannel, err := safejs.Global().Get("BroadcastChannel")
+	if err == nil && broadcastChannel.Type() == safejs.TypeFunction {
+		channel, err := broadcastChannel.New(broadcastChannelPrefix + dbName)
+		if err == nil {
+			h.channel = channel
+		}
+	}
+	return h
+}
+
+// notify delivers 'events' to local watchers and broadcasts them to other FS's
+func (h *watchHub) notify(events []hackpadfs.WatchEvent) {
+	if len(events) == 0 {
+		return
+	}
+	h.deliver(events)
+	if h.channel.IsUndefined() {
+		return
+	}
+	message := make([]any, 0, len(events))
+	for _, event := range events {
+		message = append(message, map[string]any{
+			"name": event.Name,
+			"op":   int(event.Op),
+		})
+	}
+	jsMessage, err := safejs.ValueOf(message)
+	if err == nil {
+		_, _ = h.channel.Call("postMessage", jsMessage)
+	}
+}
+
+func (h *watchHub) deliver(events []hackpadfs.WatchEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for w := range h.watchers {
+		for _, event := range events {
+			if w.matches(event.Name) {
+				w.push(event)
+			}
+		}
+	}
+}
+
+func (h *watchHub) watch(name string) *watcher {
+	w := newWatcher(h, name)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.watchers[w] = struct{}{}
+	if !h.listening {
+		h.listening = true
+		h.listen()
+	}
+	return w
+}
+
+func (h *watchHub) unwatch(w *watcher) {
+	h.mu.Lock()
+	delete(h.watchers, w)
+	h.mu.Unlock()
+}
+
+// listen starts receiving changes from other FS's. Must be called with h.mu held.
+func (h *watchHub) listen() {
+	if !h.channel.IsUndefined() {
+		onMessage, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) any {
+			if len(args) > 0 {
+				h.receive(args[0])
+			}
+			return nil
+		})
+		if err == nil {
+			_ = h.channel.Set("onmessage", onMessage)
+		}
+	}
+	h.listenVersionChange()
+}
+
+func (h *watchHub) receive(messageEvent safejs.Value) {
+	data, err := messageEvent.Get("data")
+	if err != nil {
+		return
+	}
+	length, err := data.Length()
+	if err != nil {
+		return
+	}
+	events := make([]hackpadfs.WatchEvent, 0, length)
+	for i := 0; i < length; i++ {
+		jsEvent, err := data.Index(i)
+		if err != nil {
+			return
+		}
+		jsName, err := jsEvent.Get("name")
+		if err != nil {
+			return
+		}
+		name, err := jsName.String()
+		if err != nil {
+			return
+		}
+		jsOp, err := jsEvent.Get("op")
+		if err != nil {
+			return
+		}
+		op, err := jsOp.Int()
+		if err != nil {
+			return
+		}
+		events = append(events, hackpadfs.WatchEvent{Name: name, Op: hackpadfs.WatchOp(op)})
+	}
+	h.deliver(events)
+}
+
+// listenVersionChange opens a separate connection to the database, which receives "versionchange" events when another connection upgrades or deletes the database.
+// Any file may have changed, so a change to the root directory is delivered to all watchers.
+// Must be called with h.mu held.
+func (h *watchHub) listenVersionChange() {
+	indexedDB, err := safejs.Global().Get("indexedDB")
+	if err != nil || indexedDB.IsUndefined() {
+		return
+	}
+	openRequest, err := indexedDB.Call("open", h.dbName)
+	if err != nil {
+		return
+	}
+	onSuccess, err := safejs.FuncOf(func(safejs.Value, []safejs.Value) any {
+		db, err := openRequest.Get("result")
+		if err != nil {
+			return nil
+		}
+		onVersionChange, err := safejs.FuncOf(func(safejs.Value, []safejs.Value) any {
+			_, _ = db.Call("close") // don't block the other connection
+			h.deliver([]hackpadfs.WatchEvent{{Name: rootPath, Op: hackpadfs.WatchWrite}})
+			return nil
+		})
+		if err == nil {
+			_ = db.Set("onversionchange", onVersionChange)
+		}
+		return nil
+	})
+	if err == nil {
+		_ = openRequest.Set("onsuccess", onSuccess)
+	}
+}
+
+// watcher queues events for one call to Watch(). Events are queued without limit, so delivery never blocks on a slow reader.
+type watcher struct {
+	hub    *watchHub
+	name   string
+	events chan hackpadfs.WatchEvent
+
+	mu     sync.Mutex
+	queue  []hackpadfs.WatchEvent
+	signal chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+func newWatcher(hub *watchHub, name string) *watcher {
+	w := &watcher{
+		hub:    hub,
+		name:   name,
+		events: make(chan hackpadfs.WatchEvent),
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *watcher) matches(name string) bool {
+	return w.name == rootPath || name == rootPath || name == w.name || strings.HasPrefix(name, w.name+"/")
+}
+
+func (w *watcher) push(event hackpadfs.WatchEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.queue = append(w.queue, event)
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run() {
+	defer close(w.events)
+	for {
+		select {
+		case <-w.signal:
+		case <-w.done:
+			return
+		}
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, event := range queue {
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *watcher) Events() <-chan hackpadfs.WatchEvent {
+	return w.events
+}
+
+func (w *watcher) Close() error {
+	w.mu.Lock()
+	wasClosed := w.closed
+	w.closed = true
+	w.mu.Unlock()
+	if !wasClosed {
+		close(w.done)
+		w.hub.unwatch(w)
+	}
+	return nil
+}
@@ -0,0 +1,580 @@
+//go:build wasm
+// +build wasm
+
+package indexeddb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
+	"github.com/hack-pad/hackpadfs/internal/jswrapper"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/safejs"
+)
+
+// Worker request ops
+const (
+	workerOpPing     = "ping"
+	workerOpGet      = "get"
+	workerOpData     = "data"
+	workerOpDirNames = "dirnames"
+	workerOpSet      = "set"
+	workerOpKeys     = "keys"
+	workerOpClear    = "clear"
+)
+
+// workerErrors are sent between the worker and FS by index, so errors.Is() checks still succeed
+var workerErrors = []error{
+	hackpadfs.ErrInvalid,
+	hackpadfs.ErrPermission,
+	hackpadfs.ErrExist,
+	hackpadfs.ErrNotExist,
+	hackpadfs.ErrClosed,
+	hackpadfs.ErrIsDir,
+	hackpadfs.ErrNotDir,
+	hackpadfs.ErrNotEmpty,
+	hackpadfs.ErrNotImplemented,
+	hackpadfs.ErrWouldBlock,
+}
+
+// workerError is an error returned by ServeWorker
+type workerError struct {
+	message string
+	err     error // one of workerErrors, or nil
+}
+
+func (e *workerError) Error() string {
+	return e.message
+}
+
+func (e *workerError) Unwrap() error {
+	return e.err
+}
+
+var (
+	_ keyvalue.Store      = &workerStore{}
+	_ keyvalue.KeysStore  = &workerStore{}
+	_ keyvalue.AsyncStore = &workerStore{}
+)
+
+// workerStore is a keyvalue.Store which runs all operations inside a Web Worker, sending requests with postMessage. See ServeWorker().
+type workerStore struct {
+	dbName string
+	worker safejs.Value
+	nextID uint64
+
+	mu      sync.Mutex
+	pending map[uint64]chan safejs.Value
+}
+
+func newWorkerStore(dbName string, worker safejs.Value) (*workerStore, error) {
+	s := &workerStore{
+		dbName:  dbName,
+		worker:  worker,
+		pending: make(map[uint64]chan safejs.Value),
+	}
+	onMessage, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) any {
+		if len(args) > 0 {
+			s.receive(args[0])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, listenMessages(worker, onMessage)
+}
+
+// listenMessages calls 'onMessage' for each message received by 'target', a Worker, worker global scope, or MessagePort
+func listenMessages(target safejs.Value, onMessage safejs.Func) error {
+	if _, err := target.Call("addEventListener", "message", onMessage); err != nil {
+		return err
+	}
+	start, err := target.Get("start")
+	if err != nil {
+		return err
+	}
+	if start.Type() == safejs.TypeFunction {
+		// MessagePorts only dispatch messages to event listeners after calling start()
+		_, err = target.Call("start")
+	}
+	return err
+}
+
+// workerPingInterval is how often to ping the worker until it starts serving requests
+const workerPingInterval = 100 * time.Millisecond
+
+// awaitReady waits for the worker to call ServeWorker(). Messages sent before then are dropped, so ping until the worker responds.
+func (s *workerStore) awaitReady(ctx context.Context) error {
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, workerPingInterval)
+		_, err := s.call(pingCtx, map[string]any{"op": workerOpPing}, nil)
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+	}
+}
+
+func (s *workerStore) receive(messageEvent safejs.Value) {
+	response, err := messageEvent.Get("data")
+	if err != nil {
+		return
+	}
+	jsID, err := response.Get("id")
+	if err != nil {
+		return
+	}
+	id, err := jsID.Int()
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	responses, ok := s.pending[uint64(id)]
+	delete(s.pending, uint64(id))
+	s.mu.Unlock()
+	if ok {
+		responses <- response
+	}
+}
+
+// call sends 'request' to the worker and waits for its response. Buffers in 'transfer' are moved to the worker, instead of copied.
+func (s *workerStore) call(ctx context.Context, request map[string]any, transfer []any) (safejs.Value, error) {
+	id := atomic.AddUint64(&s.nextID, 1)
+	request["id"] = id
+	request["db"] = s.dbName
+	responses := make(chan safejs.Value, 1)
+	s.mu.Lock()
+	s.pending[id] = responses
+	s.mu.Unlock()
+
+	jsRequest, err := safejs.ValueOf(request)
+	if err == nil {
+		_, err = s.worker.Call("postMessage", jsRequest, transfer)
+	}
+	if err != nil {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+		return safejs.Value{}, err
+	}
+
+	select {
+	case response := <-responses:
+		return response, responseErr(response)
+	case <-ctx.Done():
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+		return safejs.Value{}, ctx.Err()
+	}
+}
+
+func responseErr(response safejs.Value) error {
+	jsErr, err := response.Get("err")
+	if err != nil {
+		return err
+	}
+	if jsErr.IsUndefined() {
+		return nil
+	}
+	jsMessage, err := jsErr.Get("message")
+	if err != nil {
+		return err
+	}
+	message, err := jsMessage.String()
+	if err != nil {
+		return err
+	}
+	jsKind, err := jsErr.Get("kind")
+	if err != nil {
+		return err
+	}
+	kind, err := jsKind.Int()
+	if err != nil {
+		return err
+	}
+	var wrappedErr error
+	if kind >= 0 && kind < len(workerErrors) {
+		wrappedErr = workerErrors[kind]
+	}
+	return &workerError{message: message, err: wrappedErr}
+}
+
+func (s *workerStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	response, err := s.call(ctx, map[string]any{"op": workerOpGet, "path": path}, nil)
+	if err != nil {
+		return nil, err
+	}
+	jsRecord, err := response.Get("record")
+	if err != nil {
+		return nil, err
+	}
+	size, modTime, mode, err := parseWorkerRecord(jsRecord)
+	if err != nil {
+		return nil, err
+	}
+	var getData func() (blob.Blob, error)
+	var getDirNames func() ([]string, error)
+	if mode.IsDir() {
+		getDirNames = func() ([]string, error) {
+			return s.dirNames(ctx, path)
+		}
+	} else {
+		getData = func() (blob.Blob, error) {
+			return s.data(ctx, path)
+		}
+	}
+	return keyvalue.NewBaseFileRecord(size, modTime, mode, nil, getData, getDirNames), nil
+}
+
+func (s *workerStore) data(ctx context.Context, path string) (blob.Blob, error) {
+	response, err := s.call(ctx, map[string]any{"op": workerOpData, "path": path}, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := response.Get("data")
+	if err != nil {
+		return nil, err
+	}
+	return idbblob.New(safejs.Unsafe(data))
+}
+
+func (s *workerStore) dirNames(ctx context.Context, path string) ([]string, error) {
+	response, err := s.call(ctx, map[string]any{"op": workerOpDirNames, "path": path}, nil)
+	if err != nil {
+		return nil, err
+	}
+	jsNames, err := response.Get("names")
+	if err != nil {
+		return nil, err
+	}
+	return parseStrings(jsNames)
+}
+
+func (s *workerStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	request := map[string]any{"op": workerOpSet, "path": path}
+	var transfer []any
+	if src != nil {
+		request["record"] = formatWorkerRecord(src)
+		if !src.Mode().IsDir() {
+			data, err := src.Data()
+			if err != nil {
+				return err
+			}
+			if data != nil {
+				jsData, err := idbblob.ToJS(data)
+				if err != nil {
+					return err
+				}
+				request["data"] = jsData
+				if _, isJS := data.(jswrapper.Wrapper); !isJS {
+					// jsData is a new copy, so move it to the worker instead of copying again
+					transfer = append(transfer, jsData.Get("buffer"))
+				}
+			}
+		}
+	}
+	_, err := s.call(ctx, request, transfer)
+	return err
+}
+
+// GetAsync implements keyvalue.AsyncStore. Requests are sent without waiting for earlier ones, so the worker can run them concurrently.
+func (s *workerStore) GetAsync(ctx context.Context, path string) *keyvalue.Future {
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		resolve(s.Get(ctx, path))
+	}()
+	return future
+}
+
+// SetAsync implements keyvalue.AsyncStore.
+// The worker can not stop a write once it is sent, so canceling 'ctx' only skips unsent writes. Sent writes resolve once the worker responds.
+func (s *workerStore) SetAsync(ctx context.Context, path string, src keyvalue.FileRecord) *keyvalue.Future {
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		if err := ctx.Err(); err != nil {
+			resolve(nil, err)
+			return
+		}
+		resolve(nil, s.Set(context.Background(), path, src))
+	}()
+	return future
+}
+
+func (s *workerStore) Keys(ctx context.Context) ([]string, error) {
+	response, err := s.call(ctx, map[string]any{"op": workerOpKeys}, nil)
+	if err != nil {
+		return nil, err
+	}
+	jsKeys, err := response.Get("names")
+	if err != nil {
+		return nil, err
+	}
+	return parseStrings(jsKeys)
+}
+
+func (s *workerStore) clear(ctx context.Context) error {
+	_, err := s.call(ctx, map[string]any{"op": workerOpClear}, nil)
+	return err
+}
+
+func formatWorkerRecord(record keyvalue.FileRecord) map[string]any {
+	return map[string]any{
+		"size":    record.Size(),
+		"modTime": record.ModTime().UnixNano(),
+		"mode":    uint32(record.Mode()),
+	}
+}
+
+func parseWorkerRecord(jsRecord safejs.Value) (size int64, modTime time.Time, mode hackpadfs.FileMode, err error) {
+	jsSize, err := jsRecord.Get("size")
+	if err != nil {
+		return
+	}
+	jsModTime, err := jsRecord.Get("modTime")
+	if err != nil {
+		return
+	}
+	jsMode, err := jsRecord.Get("mode")
+	if err != nil {
+		return
+	}
+	intSize, err := jsSize.Int()
+	if err != nil {
+		return
+	}
+	intModTime, err := jsModTime.Int()
+	if err != nil {
+		return
+	}
+	intMode, err := jsMode.Int()
+	if err != nil {
+		return
+	}
+	return int64(intSize), time.Unix(0, int64(intModTime)), hackpadfs.FileMode(intMode), nil
+}
+
+func parseStrings(jsStrings safejs.Value) ([]string, error) {
+	length, err := jsStrings.Length()
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, 0, length)
+	for i := 0; i < length; i++ {
+		jsStr, err := jsStrings.Index(i)
+		if err != nil {
+			return nil, err
+		}
+		str, err := jsStr.String()
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+// ServeWorker serves requests from FS's created with Options.Worker. Call it inside the Web Worker, passing the worker's global scope (i.e. 'self').
+// 'scope' may also be one end of a MessageChannel, where the other end is passed to Options.Worker.
+// Opens each requested database once with 'options', so Options.Worker is ignored. Blocks until 'ctx' is canceled.
+//
+// For example, a worker's main function may run:
+//
+//	err := indexeddb.ServeWorker(ctx, js.Global(), indexeddb.Options{})
+func ServeWorker(ctx context.Context, scope js.Value, options Options) error {
+	options.Worker = js.Undefined()
+	server := &workerServer{
+		scope:   safejs.Safe(scope),
+		options: options,
+		stores:  make(map[string]*FS),
+	}
+	onMessage, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) any {
+		if len(args) > 0 {
+			request, err := args[0].Get("data")
+			if err == nil {
+				go server.handle(ctx, request)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	defer onMessage.Release()
+	if err := listenMessages(server.scope, onMessage); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	_, err = server.scope.Call("removeEventListener", "message", onMessage)
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+type workerServer struct {
+	scope   safejs.Value
+	options Options
+
+	mu     sync.Mutex
+	stores map[string]*FS
+}
+
+func (w *workerServer) fs(ctx context.Context, name string) (*FS, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if fs, ok := w.stores[name]; ok {
+		return fs, nil
+	}
+	fs, err := NewFS(ctx, name, w.options)
+	if err != nil {
+		return nil, err
+	}
+	w.stores[name] = fs
+	return fs, nil
+}
+
+func (w *workerServer) handle(ctx context.Context, request safejs.Value) {
+	response := make(map[string]any)
+	transfer, err := w.serve(ctx, request, response)
+	if jsID, idErr := request.Get("id"); idErr == nil {
+		response["id"] = safejs.Unsafe(jsID)
+	}
+	if err != nil {
+		kind := -1
+		for i, target := range workerErrors {
+			if errors.Is(err, target) {
+				kind = i
+				break
+			}
+		}
+		response["err"] = map[string]any{"message": err.Error(), "kind": kind}
+		transfer = nil
+	}
+	jsResponse, err := safejs.ValueOf(response)
+	if err != nil {
+		return
+	}
+	_, _ = w.scope.Call("postMessage", jsResponse, transfer)
+}
+
+// serve runs 'request' and fills in 'response'. Returns buffers to move to the FS.
+func (w *workerServer) serve(ctx context.Context, request safejs.Value, response map[string]any) ([]any, error) {
+	dbName, err := getString(request, "db")
+	if err != nil {
+		return nil, err
+	}
+	op, err := getString(request, "op")
+	if err != nil {
+		return nil, err
+	}
+	if op == workerOpPing {
+		return nil, nil
+	}
+	fs, err := w.fs(ctx, dbName)
+	if err != nil {
+		return nil, err
+	}
+	if op == workerOpClear {
+		return nil, fs.Clear(ctx)
+	}
+	if op == workerOpKeys {
+		keys, err := fs.store.Keys(ctx)
+		response["names"] = stringsToAny(keys)
+		return nil, err
+	}
+
+	path, err := getString(request, "path")
+	if err != nil {
+		return nil, err
+	}
+	if op == workerOpSet {
+		return nil, w.serveSet(ctx, fs, path, request)
+	}
+
+	record, err := fs.store.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case workerOpGet:
+		response["record"] = formatWorkerRecord(record)
+		return nil, nil
+	case workerOpData:
+		data, err := record.Data()
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			data = blob.NewBytes(nil)
+		}
+		jsData, err := idbblob.ToJS(data)
+		if err != nil {
+			return nil, err
+		}
+		response["data"] = jsData
+		// this worker's copy of the data is not used again, so always move it
+		return []any{jsData.Get("buffer")}, nil
+	case workerOpDirNames:
+		names, err := record.ReadDirNames()
+		response["names"] = stringsToAny(names)
+		return nil, err
+	default:
+		return nil, &hackpadfs.PathError{Op: op, Path: path, Err: hackpadfs.ErrNotImplemented}
+	}
+}
+
+func (w *workerServer) serveSet(ctx context.Context, fs *FS, path string, request safejs.Value) error {
+	jsRecord, err := request.Get("record")
+	if err != nil {
+		return err
+	}
+	if jsRecord.IsUndefined() {
+		return fs.store.Set(ctx, path, nil)
+	}
+	size, modTime, mode, err := parseWorkerRecord(jsRecord)
+	if err != nil {
+		return err
+	}
+	var getData func() (blob.Blob, error)
+	if !mode.IsDir() {
+		jsData, err := request.Get("data")
+		if err != nil {
+			return err
+		}
+		getData = func() (blob.Blob, error) {
+			if jsData.IsUndefined() {
+				return blob.NewBytes(nil), nil
+			}
+			return idbblob.New(safejs.Unsafe(jsData))
+		}
+	}
+	return fs.store.Set(ctx, path, keyvalue.NewBaseFileRecord(size, modTime, mode, nil, getData, nil))
+}
+
+func getString(value safejs.Value, property string) (string, error) {
+	jsValue, err := value.Get(property)
+	if err != nil {
+		return "", err
+	}
+	return jsValue.String()
+}
+
+func stringsToAny(strs []string) []any {
+	values := make([]any, len(strs))
+	for i, str := range strs {
+		values[i] = str
+	}
+	return values
+}
@@ -0,0 +1,90 @@
+//go:build wasm
+// +build wasm
+
+// Package jspromise waits on JavaScript Promises.
+package jspromise
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hack-pad/safejs"
+)
+
+// RejectedError is returned when a Promise rejects. Value is the rejection reason, usually a JavaScript Error.
+type RejectedError struct {
+	Value safejs.Value
+}
+
+func (e *RejectedError) Error() string {
+	message, err := e.Value.Call("toString")
+	if err != nil {
+		return "promise rejected"
+	}
+	str, err := message.String()
+	if err != nil {
+		return "promise rejected"
+	}
+	return fmt.Sprintf("promise rejected: %s", str)
+}
+
+// Await blocks until 'promise' settles, then returns its result.
+// If 'ctx' is canceled first, returns the context's error and leaves the promise running.
+func Await(ctx context.Context, promise safejs.Value) (safejs.Value, error) {
+	type result struct {
+		value safejs.Value
+		err   error
+	}
+	results := make(chan result, 1)
+	firstArg := func(args []safejs.Value) safejs.Value {
+		if len(args) == 0 {
+			return safejs.Undefined()
+		}
+		return args[0]
+	}
+	resolve, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) any {
+		results <- result{value: firstArg(args)}
+		return nil
+	})
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	reject, err := safejs.FuncOf(func(_ safejs.Value, args []safejs.Value) any {
+		results <- result{err: &RejectedError{Value: firstArg(args)}}
+		return nil
+	})
+	if err != nil {
+		resolve.Release()
+		return safejs.Value{}, err
+	}
+	release := func() {
+		resolve.Release()
+		reject.Release()
+	}
+
+	if _, err := promise.Call("then", resolve, reject); err != nil {
+		release()
+		return safejs.Value{}, err
+	}
+	select {
+	case r := <-results:
+		release()
+		return r.value, r.err
+	case <-ctx.Done():
+		// the callbacks must outlive the promise, so release them once it settles
+		go func() {
+			<-results
+			release()
+		}()
+		return safejs.Value{}, ctx.Err()
+	}
+}
+
+// Call calls method 'm' on 'value' and awaits the returned promise
+func Call(ctx context.Context, value safejs.Value, m string, args ...any) (safejs.Value, error) {
+	promise, err := value.Call(m, args...)
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	return Await(ctx, promise)
+}
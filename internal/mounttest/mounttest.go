@@ -24,58 +24,71 @@ func NewFS(fs *mount.FS) interface {
 	hackpadfs.ReadFileFS
 	hackpadfs.MountFS
 } {
-	return &allMountFS{fs}
+	return &allMountFS{mountOnlyFS{fs}}
+}
+
+// mountOnlyFS hides mount.FS's own capability methods, so hackpadfs's helpers must use their MountFS fallbacks
+type mountOnlyFS struct {
+	fs *mount.FS
+}
+
+func (fs mountOnlyFS) Open(name string) (hackpadfs.File, error) {
+	return fs.fs.Open(name)
+}
+
+func (fs mountOnlyFS) Mount(name string) (hackpadfs.FS, string) {
+	return fs.fs.Mount(name)
 }
 
 // allMountFS wraps a mount.FS with the usual functions implemented by a mounted file system, so fstest won't skip the capability-based tests
 type allMountFS struct {
-	*mount.FS
+	mountOnlyFS
 }
 
 func (fs *allMountFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
-	return hackpadfs.OpenFile(fs.FS, name, flag, perm)
+	return hackpadfs.OpenFile(fs.mountOnlyFS, name, flag, perm)
 }
 
 func (fs *allMountFS) Create(name string) (hackpadfs.File, error) {
-	return hackpadfs.Create(fs.FS, name)
+	return hackpadfs.Create(fs.mountOnlyFS, name)
 }
 
 func (fs *allMountFS) Mkdir(name string, perm hackpadfs.FileMode) error {
-	return hackpadfs.Mkdir(fs.FS, name, perm)
+	return hackpadfs.Mkdir(fs.mountOnlyFS, name, perm)
 }
 
 func (fs *allMountFS) MkdirAll(path string, perm hackpadfs.FileMode) error {
-	return hackpadfs.MkdirAll(fs.FS, path, perm)
+	return hackpadfs.MkdirAll(fs.mountOnlyFS, path, perm)
 }
 
 func (fs *allMountFS) Remove(name string) error {
-	return hackpadfs.Remove(fs.FS, name)
+	return hackpadfs.Remove(fs.mountOnlyFS, name)
 }
 
 func (fs *allMountFS) Stat(name string) (hackpadfs.FileInfo, error) {
-	return hackpadfs.Stat(fs.FS, name)
+	return hackpadfs.Stat(fs.mountOnlyFS, name)
 }
 
 func (fs *allMountFS) Lstat(name string) (hackpadfs.FileInfo, error) {
-	return hackpadfs.Lstat(fs.FS, name)
+	return hackpadfs.Lstat(fs.mountOnlyFS, name)
 }
 
 func (fs *allMountFS) Chmod(name string, mode hackpadfs.FileMode) error {
-	return hackpadfs.Chmod(fs.FS, name, mode)
+	return hackpadfs.Chmod(fs.mountOnlyFS, name, mode)
 }
 
 func (fs *allMountFS) Chown(name string, uid, gid int) error {
-	return hackpadfs.Chown(fs.FS, name, uid, gid)
+	return hackpadfs.Chown(fs.mountOnlyFS, name, uid, gid)
 }
 
 func (fs *allMountFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
-	return hackpadfs.Chtimes(fs.FS, name, atime, mtime)
+	return hackpadfs.Chtimes(fs.mountOnlyFS, name, atime, mtime)
 }
 
 func (fs *allMountFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
-	return hackpadfs.ReadDir(fs.FS, name)
+	return hackpadfs.ReadDir(fs.mountOnlyFS, name)
 }
 
 func (fs *allMountFS) ReadFile(name string) ([]byte, error) {
-	return hackpadfs.ReadFile(fs.FS, name)
+	return hackpadfs.ReadFile(fs.mountOnlyFS, name)
 }
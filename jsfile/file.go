@@ -0,0 +1,222 @@
+//go:build wasm
+// +build wasm
+
+// Package jsfile adapts JavaScript File and Blob objects into hackpadfs Files.
+//
+// For example, a File from an <input type="file"> element or a drag-and-drop event can be read like any other hackpadfs.File.
+// Contents are read on demand with Blob.slice(), so large files are not copied into memory all at once.
+package jsfile
+
+import (
+	"context"
+	"io"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/jspromise"
+	"github.com/hack-pad/safejs"
+)
+
+// readBufferSize is the minimum number of bytes fetched by each call to Read
+const readBufferSize = 64 << 10
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReaderAtFile
+		hackpadfs.SeekerFile
+	} = &File{}
+)
+
+// File is a read-only hackpadfs.File reading from a JavaScript File or Blob
+type File struct {
+	blob safejs.Value
+	info fileInfo
+
+	mu     sync.Mutex
+	offset int64
+	buf    []byte // buffered contents at 'offset'
+	closed bool
+}
+
+// New returns a File reading from 'blob', a JavaScript File or Blob.
+// A Blob has no name or modification time, so its file info's Name() is "blob" and ModTime() is zero.
+func New(blob js.Value) (*File, error) {
+	b := safejs.Safe(blob)
+	jsSize, err := b.Get("size")
+	if err != nil {
+		return nil, err
+	}
+	if jsSize.Type() != safejs.TypeNumber {
+		return nil, &hackpadfs.PathError{Op: "open", Path: "blob", Err: hackpadfs.ErrInvalid}
+	}
+	size, err := jsSize.Float()
+	if err != nil {
+		return nil, err
+	}
+	info := fileInfo{name: "blob", size: int64(size)}
+	jsName, err := b.Get("name")
+	if err != nil {
+		return nil, err
+	}
+	if jsName.Type() == safejs.TypeString {
+		info.name, err = jsName.String()
+		if err != nil {
+			return nil, err
+		}
+	}
+	jsLastModified, err := b.Get("lastModified")
+	if err != nil {
+		return nil, err
+	}
+	if jsLastModified.Type() == safejs.TypeNumber {
+		lastModified, err := jsLastModified.Float()
+		if err != nil {
+			return nil, err
+		}
+		info.modTime = time.UnixMilli(int64(lastModified))
+	}
+	return &File{blob: b, info: info}, nil
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if f.isClosed() {
+		return 0, &hackpadfs.PathError{Op: "read", Path: f.info.name, Err: hackpadfs.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "read", Path: f.info.name, Err: hackpadfs.ErrInvalid}
+	}
+	n, err := f.readAt(p, off)
+	if err != nil {
+		return n, &hackpadfs.PathError{Op: "read", Path: f.info.name, Err: err}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// readAt reads up to len(p) bytes at 'off' with one call to Blob.slice()
+func (f *File) readAt(p []byte, off int64) (int, error) {
+	if off >= f.info.size || len(p) == 0 {
+		return 0, nil
+	}
+	end := off + int64(len(p))
+	if end > f.info.size {
+		end = f.info.size
+	}
+	slice, err := f.blob.Call("slice", off, end)
+	if err != nil {
+		return 0, err
+	}
+	buf, err := jspromise.Call(context.Background(), slice, "arrayBuffer")
+	if err != nil {
+		return 0, err
+	}
+	uint8Array, err := safejs.Global().Get("Uint8Array")
+	if err != nil {
+		return 0, err
+	}
+	data, err := uint8Array.New(buf)
+	if err != nil {
+		return 0, err
+	}
+	return safejs.CopyBytesToGo(p, data)
+}
+
+func (f *File) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "read", Path: f.info.name, Err: hackpadfs.ErrClosed}
+	}
+	if len(f.buf) == 0 && len(p) > 0 {
+		if f.offset >= f.info.size {
+			return 0, io.EOF
+		}
+		bufSize := len(p)
+		if bufSize < readBufferSize {
+			bufSize = readBufferSize
+		}
+		buf := make([]byte, bufSize)
+		n, err := f.readAt(buf, f.offset)
+		if err != nil {
+			return 0, &hackpadfs.PathError{Op: "read", Path: f.info.name, Err: err}
+		}
+		f.buf = buf[:n]
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	f.offset += int64(n)
+	return n, nil
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.info.name, Err: hackpadfs.ErrClosed}
+	}
+	newOffset := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		newOffset += f.offset
+	case io.SeekEnd:
+		newOffset += f.info.size
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.info.name, Err: hackpadfs.ErrInvalid}
+	}
+	if newOffset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.info.name, Err: hackpadfs.ErrInvalid}
+	}
+	if newOffset != f.offset {
+		f.buf = nil
+	}
+	f.offset = newOffset
+	return newOffset, nil
+}
+
+// Stat implements hackpadfs.File
+func (f *File) Stat() (hackpadfs.FileInfo, error) {
+	if f.isClosed() {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: f.info.name, Err: hackpadfs.ErrClosed}
+	}
+	info := f.info
+	return &info, nil
+}
+
+// Close implements hackpadfs.File
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &hackpadfs.PathError{Op: "close", Path: f.info.name, Err: hackpadfs.ErrClosed}
+	}
+	f.closed = true
+	f.buf = nil
+	return nil
+}
+
+func (f *File) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (f *fileInfo) Name() string             { return f.name }
+func (f *fileInfo) Size() int64              { return f.size }
+func (f *fileInfo) Mode() hackpadfs.FileMode { return 0444 }
+func (f *fileInfo) ModTime() time.Time       { return f.modTime }
+func (f *fileInfo) IsDir() bool              { return false }
+func (f *fileInfo) Sys() interface{}         { return nil }
@@ -0,0 +1,105 @@
+//go:build wasm
+// +build wasm
+
+package jsfile
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"syscall/js"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func newJSFile(contents, name string, lastModified time.Time) js.Value {
+	return js.Global().Get("File").New([]any{contents}, name, map[string]any{
+		"lastModified": lastModified.UnixMilli(),
+	})
+}
+
+func TestFileStat(t *testing.T) {
+	t.Parallel()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	f, err := New(newJSFile("hello world", "foo.txt", modTime))
+	assert.NoError(t, err)
+
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, "foo.txt", info.Name())
+	assert.Equal(t, int64(len("hello world")), info.Size())
+	assert.Equal(t, hackpadfs.FileMode(0444), info.Mode())
+	assert.Equal(t, modTime, info.ModTime().UTC())
+}
+
+func TestBlob(t *testing.T) {
+	t.Parallel()
+	f, err := New(js.Global().Get("Blob").New([]any{"hello"}))
+	assert.NoError(t, err)
+	info, err := f.Stat()
+	assert.NoError(t, err)
+	assert.Equal(t, "blob", info.Name())
+	assert.Equal(t, true, info.ModTime().IsZero())
+
+	contents, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(contents))
+}
+
+func TestNotBlob(t *testing.T) {
+	t.Parallel()
+	_, err := New(js.ValueOf(map[string]any{}))
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestFileReadAt(t *testing.T) {
+	t.Parallel()
+	f, err := New(newJSFile("hello world", "foo.txt", time.Now()))
+	assert.NoError(t, err)
+
+	buf := make([]byte, 5)
+	n, err := f.ReadAt(buf, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+
+	n, err = f.ReadAt(buf, 8)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "rld", string(buf[:n]))
+}
+
+func TestFileReadSeek(t *testing.T) {
+	t.Parallel()
+	contents := strings.Repeat("abcdefghij", readBufferSize/5) // spans multiple buffered reads
+	f, err := New(newJSFile(contents, "foo.txt", time.Now()))
+	assert.NoError(t, err)
+
+	all, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, contents, string(all))
+
+	offset, err := f.Seek(-3, io.SeekEnd)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(contents)-3), offset)
+	buf := make([]byte, 10)
+	n, err := f.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hij", string(buf[:n]))
+	_, err = f.Read(buf)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = f.Seek(-1, io.SeekStart)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
+
+func TestFileClose(t *testing.T) {
+	t.Parallel()
+	f, err := New(newJSFile("hello", "foo.txt", time.Now()))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	_, err = f.Read(make([]byte, 1))
+	assert.Equal(t, true, errors.Is(err, hackpadfs.ErrClosed))
+	assert.ErrorIs(t, hackpadfs.ErrClosed, f.Close())
+}
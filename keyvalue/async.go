@@ -0,0 +1,50 @@
+package keyvalue
+
+import (
+	"context"
+)
+
+// AsyncStore is a Store that can start operations without waiting for them to complete.
+// Transactions on an AsyncStore which does not implement TransactionStore issue their operations concurrently and wait for all of them during Commit(),
+// so network-backed stores can pipeline requests and cancel them with the commit's context.
+//
+// Operations on the same path are still started in the order they were issued. Commit() does not return until every operation's Future resolves,
+// so a Future must resolve once its operation stops, including after 'ctx' is canceled. Synchronous stores need not implement AsyncStore.
+type AsyncStore interface {
+	Store
+	// GetAsync starts retrieving the file record for 'path'. The Future resolves with the same values as Get().
+	GetAsync(ctx context.Context, path string) *Future
+	// SetAsync starts assigning 'src' to 'path'. The Future resolves with a nil record and the same error as Set().
+	SetAsync(ctx context.Context, path string, src FileRecord) *Future
+}
+
+// Future is the eventual result of an asynchronous Store operation.
+type Future struct {
+	done   chan struct{}
+	record FileRecord
+	err    error
+}
+
+// NewFuture returns a pending Future and a 'resolve' func to complete it. 'resolve' must be called exactly once.
+func NewFuture() (future *Future, resolve func(FileRecord, error)) {
+	future = &Future{done: make(chan struct{})}
+	return future, func(record FileRecord, err error) {
+		future.record, future.err = record, err
+		close(future.done)
+	}
+}
+
+// Done returns a channel which is closed once the operation completes.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Await blocks until the operation completes or 'ctx' is canceled, then returns the result.
+func (f *Future) Await(ctx context.Context) (FileRecord, error) {
+	select {
+	case <-f.done:
+		return f.record, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
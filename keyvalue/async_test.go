@@ -0,0 +1,169 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+type mapAsyncStore struct {
+	*mapStore
+	asyncOps int64
+}
+
+func (s *mapAsyncStore) GetAsync(ctx context.Context, path string) *keyvalue.Future {
+	atomic.AddInt64(&s.asyncOps, 1)
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		resolve(s.Get(ctx, path))
+	}()
+	return future
+}
+
+func (s *mapAsyncStore) SetAsync(ctx context.Context, path string, src keyvalue.FileRecord) *keyvalue.Future {
+	atomic.AddInt64(&s.asyncOps, 1)
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		resolve(nil, s.Set(ctx, path, src))
+	}()
+	return future
+}
+
+// slowAsyncStore is an AsyncStore whose writes ignore cancellation and only finish once released
+type slowAsyncStore struct {
+	*mapStore
+	started  chan struct{}
+	release  chan struct{}
+	finished int32
+}
+
+func (s *slowAsyncStore) SetAsync(_ context.Context, path string, src keyvalue.FileRecord) *keyvalue.Future {
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		close(s.started)
+		<-s.release
+		err := s.Set(context.Background(), path, src)
+		atomic.StoreInt32(&s.finished, 1)
+		resolve(nil, err)
+	}()
+	return future
+}
+
+func (s *slowAsyncStore) GetAsync(ctx context.Context, path string) *keyvalue.Future {
+	future, resolve := keyvalue.NewFuture()
+	go func() {
+		resolve(s.Get(ctx, path))
+	}()
+	return future
+}
+
+func TestAsyncStoreCanceledCommit(t *testing.T) {
+	t.Parallel()
+	store := &slowAsyncStore{
+		mapStore: newMapStore(),
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	txn, err := keyvalue.TransactionOrSerial(store, keyvalue.TransactionOptions{Mode: keyvalue.TransactionReadWrite})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	txn.Set("foo", keyvalue.NewBaseFileRecord(0, time.Now(), 0600, nil, func() (blob.Blob, error) {
+		return blob.NewBytes(nil), nil
+	}, nil), nil)
+	<-store.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(store.release)
+	}()
+	_, err = txn.Commit(ctx)
+	assert.Equal(t, true, errors.Is(err, context.Canceled))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&store.finished))
+}
+
+func TestAsyncStore(t *testing.T) {
+	t.Parallel()
+	store := &mapAsyncStore{mapStore: newMapStore()}
+	fs, err := keyvalue.NewFS(store)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/baz", []byte("baz"), 0600))
+	contents, err := hackpadfs.ReadFile(fs, "foo/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(contents))
+	assert.NoError(t, fs.Rename("foo/baz", "foo/bar/baz"))
+	dirNames, err := hackpadfs.ReadDir(fs, "foo/bar")
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(dirNames)) {
+		assert.Equal(t, "baz", dirNames[0].Name())
+	}
+	assert.NotZero(t, atomic.LoadInt64(&store.asyncOps))
+}
+
+func TestAsyncStoreFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "keyvalue async",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := keyvalue.NewFS(&mapAsyncStore{mapStore: newMapStore()})
+			if err != nil {
+				tb.Fatal(err)
+			}
+			return fs
+		},
+		WithContext: func(fs hackpadfs.FS, ctx context.Context) hackpadfs.FS {
+			return fs.(*keyvalue.FS).WithContext(ctx)
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		ShouldSkip:  skipMapStoreSnapshots,
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestFutureAwait(t *testing.T) {
+	t.Parallel()
+	future, resolve := keyvalue.NewFuture()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := future.Await(ctx)
+	assert.Equal(t, true, errors.Is(err, context.Canceled))
+
+	someErr := errors.New("some error")
+	resolve(nil, someErr)
+	<-future.Done()
+	_, err = future.Await(context.Background())
+	assert.Equal(t, someErr, err)
+}
+
+func TestFSWithContext(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(&mapAsyncStore{mapStore: newMapStore()})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctxFS := fs.WithContext(ctx)
+	_, err = ctxFS.Stat("foo")
+	assert.NoError(t, err)
+	cancel()
+	_, err = ctxFS.Stat("foo")
+	assert.Equal(t, true, errors.Is(err, context.Canceled))
+
+	_, err = fs.Stat("foo")
+	assert.NoError(t, err)
+}
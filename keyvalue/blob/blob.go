@@ -1,4 +1,8 @@
 // Package blob defines a common data interchange type for keyvalue FS's.
+//
+// Blobs may share memory. View() returns a Blob aliasing the original's data, so mutating either one mutates both,
+// while Slice() and Bytes() return copies. Aliasing may end when a Blob is grown, since growing can reallocate its data.
+// To hand out data which callers must not modify, wrap it with NewReadOnly().
 package blob
 
 // Blob is a binary blob of data that can support platform-optimized mutations for better performance.
@@ -11,7 +15,7 @@ type Blob interface {
 }
 
 // ViewBlob is a Blob that can return a view into the same underlying data.
-// Mutating the returned Blob also mutates the original.
+// Mutating the returned Blob also mutates the original, and vice versa. Views of a ReadOnly blob are also read-only.
 type ViewBlob interface {
 	Blob
 	View(start, end int64) (Blob, error)
@@ -23,6 +27,13 @@ type SliceBlob interface {
 	Slice(start, end int64) (Blob, error)
 }
 
+// CloneBlob is a Blob that can return a copy of itself without copying its data up front.
+// The clone and the original share data until one of them is mutated. Views of the original keep aliasing the original.
+type CloneBlob interface {
+	Blob
+	Clone() Blob
+}
+
 // SetBlob is a Blob that can copy 'src' into itself starting at the given offset into this Blob.
 // Use View() on 'src' to control the maximum that is copied into this Blob.
 type SetBlob interface {
@@ -58,6 +69,14 @@ func Slice(b Blob, start, end int64) (Blob, error) {
 	return NewBytes(b.Bytes()).Slice(start, end)
 }
 
+// Clone attempts to call an optimized blob.Clone(), falls back to copying into Bytes.
+func Clone(b Blob) Blob {
+	if b, ok := b.(CloneBlob); ok {
+		return b.Clone()
+	}
+	return NewBytes(b.Bytes())
+}
+
 // Set attempts to call an optimized blob.Set(), falls back to copying into Bytes and running Bytes.Set().
 func Set(dest Blob, src Blob, offset int64) (n int, err error) {
 	if dest, ok := dest.(SetBlob); ok {
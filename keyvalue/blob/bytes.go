@@ -1,8 +1,10 @@
 package blob
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 	"sync/atomic"
 )
@@ -13,26 +15,45 @@ var (
 		Blob
 		ViewBlob
 		SliceBlob
+		CloneBlob
 		SetBlob
 		GrowBlob
 		TruncateBlob
+		ReaderAtBlob
+		WriterAtBlob
+		DigestBlob
 	} = &Bytes{}
 )
 
 // Bytes is a Blob that wraps a byte slice.
 type Bytes struct {
-	bytes  []byte
+	buf    *bytesBuffer // buffer is shared with views
+	offset int64
 	length int64
-	mu     *sync.Mutex // mutex can be shared when the byte slice is shared
+	mu     *sync.Mutex // mutex is shared along with the buffer
+
+	version       *uint64 // incremented on every mutation, shared along with the mutex
+	digest        [sha256.Size]byte
+	digestVersion uint64
+	digestValid   bool
+}
+
+// bytesBuffer is the byte slice behind a Bytes and all of its views.
+// Copying the slice replaces it for every view at once, so views never stop aliasing their original.
+type bytesBuffer struct {
+	bytes  []byte
+	shared bool // true if bytes is shared with a Clone and must be copied before writing
 }
 
 // NewBytes returns a Blob that wraps the given byte slice.
 // Mutations to this Blob are reflected in the original slice.
 func NewBytes(buf []byte) *Bytes {
 	return &Bytes{
-		bytes:  buf,
+		buf:    &bytesBuffer{bytes: buf},
 		length: int64(len(buf)),
 		mu:     new(sync.Mutex),
+
+		version: new(uint64),
 	}
 }
 
@@ -48,7 +69,7 @@ func (b *Bytes) Bytes() []byte {
 	if err != nil {
 		panic(err)
 	}
-	return newB.(*Bytes).bytes
+	return newB.(*Bytes).buf.bytes
 }
 
 // Len implements Blob.
@@ -56,6 +77,11 @@ func (b *Bytes) Len() int {
 	return int(atomic.LoadInt64(&b.length))
 }
 
+// data returns the slice of the buffer covered by this blob. Must be called with b.mu held.
+func (b *Bytes) data() []byte {
+	return b.buf.bytes[b.offset : b.offset+b.length]
+}
+
 // View implements Blob.
 func (b *Bytes) View(start, end int64) (Blob, error) {
 	if start < 0 || start > int64(b.Len()) {
@@ -64,11 +90,35 @@ func (b *Bytes) View(start, end int64) (Blob, error) {
 	if end < 0 || end > int64(b.Len()) {
 		return nil, fmt.Errorf("End index out of bounds: %d", end)
 	}
+	return &Bytes{
+		buf:    b.buf,
+		offset: b.offset + start,
+		length: end - start,
+		mu:     b.mu,
+
+		version: b.version,
+	}, nil
+}
+
+// Clone implements CloneBlob. The byte slice is copied on the next write to either blob.
+func (b *Bytes) Clone() Blob {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	newB := NewBytes(b.bytes[start:end])
-	newB.mu = b.mu
-	return newB, nil
+	b.buf.shared = true
+	data := b.data()
+	newB := NewBytes(data[:len(data):len(data)])
+	newB.buf.shared = true
+	newB.digest, newB.digestValid = b.digest, b.digestValid && b.digestVersion == *b.version
+	return newB
+}
+
+// unshare copies the buffer if it is shared with a Clone. Must be called with b.mu held.
+func (b *Bytes) unshare() {
+	if !b.buf.shared {
+		return
+	}
+	b.buf.bytes = append(make([]byte, 0, len(b.buf.bytes)), b.buf.bytes...)
+	b.buf.shared = false
 }
 
 // Slice implements Blob.
@@ -81,7 +131,7 @@ func (b *Bytes) Slice(start, end int64) (Blob, error) {
 	}
 	buf := make([]byte, end-start)
 	b.mu.Lock()
-	copy(buf, b.bytes)
+	copy(buf, b.data()[start:end])
 	b.mu.Unlock()
 	return NewBytes(buf), nil
 }
@@ -95,16 +145,61 @@ func (b *Bytes) Set(src Blob, destStart int64) (n int, err error) {
 		return 0, fmt.Errorf("Offset out of bounds: %d", destStart)
 	}
 	b.mu.Lock()
-	n = copy(b.bytes[destStart:], src.Bytes())
+	b.unshare()
+	n = copy(b.data()[destStart:], src.Bytes())
+	*b.version++
 	b.mu.Unlock()
 	return n, nil
 }
 
+// ReadAt implements ReaderAtBlob.
+func (b *Bytes) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	b.mu.Lock()
+	if data := b.data(); off < int64(len(data)) {
+		n = copy(p, data[off:])
+	}
+	b.mu.Unlock()
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteAt implements WriterAtBlob.
+func (b *Bytes) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	b.mu.Lock()
+	b.unshare()
+	if data := b.data(); off <= int64(len(data)) {
+		n = copy(data[off:], p)
+	}
+	*b.version++
+	b.mu.Unlock()
+	if n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
 // Grow implements Blob.
 func (b *Bytes) Grow(offset int64) error {
 	b.mu.Lock()
-	b.bytes = append(b.bytes, make([]byte, offset)...)
-	atomic.StoreInt64(&b.length, int64(len(b.bytes)))
+	b.unshare()
+	end := b.offset + b.length
+	if missing := end + offset - int64(len(b.buf.bytes)); missing > 0 {
+		b.buf.bytes = append(b.buf.bytes, make([]byte, missing)...)
+	}
+	grown := b.buf.bytes[end : end+offset]
+	for i := range grown {
+		grown[i] = 0 // clear any bytes left behind by Truncate
+	}
+	atomic.StoreInt64(&b.length, b.length+offset)
+	*b.version++
 	b.mu.Unlock()
 	return nil
 }
@@ -116,9 +211,20 @@ func (b *Bytes) Truncate(size int64) error {
 	}
 	b.mu.Lock()
 	if int64(b.Len()) >= size {
-		b.bytes = b.bytes[:size]
-		atomic.StoreInt64(&b.length, int64(len(b.bytes)))
+		atomic.StoreInt64(&b.length, size)
+		*b.version++
 	}
 	b.mu.Unlock()
 	return nil
 }
+
+// Digest implements DigestBlob. The digest is cached until this Blob, or another View of the same data, is mutated.
+func (b *Bytes) Digest() ([sha256.Size]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.digestValid || b.digestVersion != *b.version {
+		b.digest = sha256.Sum256(b.data())
+		b.digestVersion, b.digestValid = *b.version, true
+	}
+	return b.digest, nil
+}
@@ -0,0 +1,63 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestBytesClone(t *testing.T) {
+	t.Parallel()
+	original := []byte("hello world")
+	b := NewBytes(original)
+	view, err := b.View(0, 5)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	clone := b.Clone()
+	_, err = Set(b, NewBytes([]byte("HELLO")), 0)
+	assert.NoError(t, err)
+	_, err = Set(view, NewBytes([]byte("J")), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, b.Grow(1))
+
+	assert.Equal(t, "hello world", string(clone.Bytes()))
+	assert.Equal(t, "hello world", string(original))
+	assert.Equal(t, "JELLO world\x00", string(b.Bytes()))
+	assert.Equal(t, "JELLO", string(view.Bytes()))
+
+	_, err = Set(clone, NewBytes([]byte("J")), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "Jello world", string(clone.Bytes()))
+	assert.Equal(t, "hello world", string(original))
+}
+
+func TestBytesTruncateGrow(t *testing.T) {
+	t.Parallel()
+	b := NewBytes([]byte("hello world"))
+	view, err := b.View(6, 11)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, b.Truncate(5))
+	assert.NoError(t, b.Grow(2))
+	assert.Equal(t, "hello\x00\x00", string(b.Bytes()))
+	assert.Equal(t, "\x00orld", string(view.Bytes()))
+}
+
+func TestCompressedClone(t *testing.T) {
+	t.Parallel()
+	c, err := NewCompressed(NewBytes([]byte("hello world")), 4)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	clone := c.Clone()
+	_, err = c.WriteAt([]byte("HELLO"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Truncate(8))
+	assert.NoError(t, c.Grow(2))
+
+	assert.Equal(t, "hello world", string(clone.Bytes()))
+	assert.Equal(t, "HELLO wo\x00\x00", string(c.Bytes()))
+}
@@ -0,0 +1,332 @@
+package blob
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	// ensure Compressed conforms to these interfaces:
+	_ interface {
+		Blob
+		ViewBlob
+		SliceBlob
+		CloneBlob
+		SetBlob
+		GrowBlob
+		TruncateBlob
+		ReaderAtBlob
+		WriterAtBlob
+	} = &Compressed{}
+)
+
+// DefaultCompressedBlockSize is the number of uncompressed bytes in each block of a Compressed blob, unless otherwise specified
+const DefaultCompressedBlockSize = 64 << 10
+
+var (
+	flateWriters = sync.Pool{
+		New: func() interface{} {
+			w, err := flate.NewWriter(nil, flate.BestSpeed)
+			if err != nil {
+				panic(err)
+			}
+			return w
+		},
+	}
+	flateReaders = sync.Pool{
+		New: func() interface{} {
+			return flate.NewReader(nil)
+		},
+	}
+)
+
+// Compressed is a Blob which holds its data compressed in fixed-size blocks.
+// Reads and writes only decompress the blocks they touch, so large files are never fully decompressed unless Bytes() is called.
+// Blocks of all zeros are not stored at all, making Grow() cheap.
+type Compressed struct {
+	mu        sync.Mutex
+	blockSize int
+	blocks    [][]byte // compressed blocks. A nil block contains all zeros.
+	length    int64
+
+	// the most recently used block is kept decompressed, which speeds up sequential reads and writes
+	cacheIndex int
+	cacheData  []byte
+}
+
+// NewCompressed returns a new Compressed blob containing a copy of 'b', split into blocks of 'blockSize' uncompressed bytes.
+// If 'blockSize' is not positive, uses DefaultCompressedBlockSize.
+func NewCompressed(b Blob, blockSize int) (*Compressed, error) {
+	if blockSize <= 0 {
+		blockSize = DefaultCompressedBlockSize
+	}
+	c := &Compressed{
+		blockSize:  blockSize,
+		length:     int64(b.Len()),
+		cacheIndex: -1,
+	}
+	c.blocks = make([][]byte, c.blockCount(c.length))
+	reader := NewReaderAt(b)
+	buf := make([]byte, blockSize)
+	for i := range c.blocks {
+		n, err := reader.ReadAt(buf[:c.blockLen(i)], int64(i)*int64(blockSize))
+		if err != nil && !(errors.Is(err, io.EOF) && n == c.blockLen(i)) {
+			return nil, err
+		}
+		c.blocks[i], err = compressBlock(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Compressed) blockCount(length int64) int {
+	return int((length + int64(c.blockSize) - 1) / int64(c.blockSize))
+}
+
+// blockLen returns the number of uncompressed bytes in block 'index'
+func (c *Compressed) blockLen(index int) int {
+	start := int64(index) * int64(c.blockSize)
+	if remaining := c.length - start; remaining < int64(c.blockSize) {
+		return int(remaining)
+	}
+	return c.blockSize
+}
+
+func compressBlock(data []byte) ([]byte, error) {
+	if isZero(data) {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	w := flateWriters.Get().(*flate.Writer)
+	defer flateWriters.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func isZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// block returns the decompressed contents of block 'index'. The returned slice must not be modified.
+func (c *Compressed) block(index int) ([]byte, error) {
+	if index == c.cacheIndex {
+		return c.cacheData, nil
+	}
+	data := make([]byte, c.blockLen(index))
+	if compressed := c.blocks[index]; compressed != nil {
+		r := flateReaders.Get().(io.ReadCloser)
+		defer flateReaders.Put(r)
+		if err := r.(flate.Resetter).Reset(bytes.NewReader(compressed), nil); err != nil {
+			return nil, err
+		}
+		// blocks may hold fewer bytes than blockLen after a Grow(), the remainder is zeros
+		if _, err := io.ReadFull(r, data); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	}
+	c.cacheIndex, c.cacheData = index, data
+	return data, nil
+}
+
+func (c *Compressed) setBlock(index int, data []byte) error {
+	compressed, err := compressBlock(data)
+	if err != nil {
+		return err
+	}
+	c.blocks[index] = compressed
+	c.cacheIndex, c.cacheData = index, data
+	return nil
+}
+
+// CompressedLen returns the number of bytes used to store this blob's compressed data
+func (c *Compressed) CompressedLen() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	total := 0
+	for _, block := range c.blocks {
+		total += len(block)
+	}
+	return total
+}
+
+// Bytes implements Blob. Decompresses the entire blob.
+func (c *Compressed) Bytes() []byte {
+	buf := make([]byte, c.Len())
+	_, err := c.ReadAt(buf, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		panic(err)
+	}
+	return buf
+}
+
+// Len implements Blob.
+func (c *Compressed) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.length)
+}
+
+// ReadAt implements ReaderAtBlob. Only decompresses blocks overlapping the read.
+func (c *Compressed) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n < len(p) && off+int64(n) < c.length {
+		pos := off + int64(n)
+		index := int(pos / int64(c.blockSize))
+		data, err := c.block(index)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[pos-int64(index)*int64(c.blockSize):])
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteAt implements WriterAtBlob. Only decompresses and recompresses blocks overlapping the write.
+func (c *Compressed) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n < len(p) && off+int64(n) < c.length {
+		pos := off + int64(n)
+		index := int(pos / int64(c.blockSize))
+		data, err := c.block(index)
+		if err != nil {
+			return n, err
+		}
+		data = append([]byte(nil), data...)
+		copied := copy(data[pos-int64(index)*int64(c.blockSize):], p[n:])
+		if err := c.setBlock(index, data); err != nil {
+			return n, err
+		}
+		n += copied
+	}
+	if n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// View implements ViewBlob. The view reads and writes through to this blob.
+func (c *Compressed) View(start, end int64) (Blob, error) {
+	length := int64(c.Len())
+	if start < 0 || start > length {
+		return nil, fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > length {
+		return nil, fmt.Errorf("End index out of bounds: %d", end)
+	}
+	return &rangeView{parent: c, start: start, end: end}, nil
+}
+
+// Slice implements SliceBlob. Only decompresses blocks overlapping the slice.
+func (c *Compressed) Slice(start, end int64) (Blob, error) {
+	length := int64(c.Len())
+	if start < 0 || start > length {
+		return nil, fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > length {
+		return nil, fmt.Errorf("End index out of bounds: %d", end)
+	}
+	buf := make([]byte, end-start)
+	_, err := c.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return NewBytes(buf), nil
+}
+
+// Clone implements CloneBlob. Compressed blocks are replaced rather than modified in place, so the clone shares them without copying.
+func (c *Compressed) Clone() Blob {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &Compressed{
+		blockSize:  c.blockSize,
+		blocks:     append([][]byte(nil), c.blocks...),
+		length:     c.length,
+		cacheIndex: -1,
+	}
+}
+
+// Set implements SetBlob.
+func (c *Compressed) Set(src Blob, destStart int64) (n int, err error) {
+	if destStart < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if destStart > int64(c.Len()) {
+		return 0, fmt.Errorf("Offset out of bounds: %d", destStart)
+	}
+	n, err = c.WriteAt(src.Bytes(), destStart)
+	if errors.Is(err, io.ErrShortWrite) {
+		err = nil // like Bytes.Set, copy as much as fits
+	}
+	return n, err
+}
+
+// Grow implements GrowBlob. New blocks are all zeros, so they are not allocated.
+func (c *Compressed) Grow(offset int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lastIndex := c.blockCount(c.length) - 1
+	c.length += offset
+	for len(c.blocks) < c.blockCount(c.length) {
+		c.blocks = append(c.blocks, nil)
+	}
+	if lastIndex == c.cacheIndex {
+		// the previously last block may have grown, so drop the shorter cached copy
+		c.cacheIndex, c.cacheData = -1, nil
+	}
+	return nil
+}
+
+// Truncate implements TruncateBlob.
+func (c *Compressed) Truncate(size int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size < 0 || size >= c.length {
+		return nil
+	}
+	lastIndex := c.blockCount(size) - 1
+	if lastIndex >= 0 {
+		// clear the truncated part of the new last block, so a later Grow() reveals zeros
+		data, err := c.block(lastIndex)
+		if err != nil {
+			return err
+		}
+		c.length = size
+		if err := c.setBlock(lastIndex, append([]byte(nil), data[:c.blockLen(lastIndex)]...)); err != nil {
+			return err
+		}
+	}
+	c.length = size
+	c.blocks = c.blocks[:lastIndex+1]
+	if c.cacheIndex > lastIndex {
+		c.cacheIndex, c.cacheData = -1, nil
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package blob
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestCompressed(t *testing.T) {
+	t.Parallel()
+	original := bytes.Repeat([]byte("hello world "), 100)
+	c, err := NewCompressed(NewBytes(original), 64)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, len(original), c.Len())
+	assert.Equal(t, string(original), string(c.Bytes()))
+
+	slice, err := c.Slice(100, 111)
+	assert.NoError(t, err)
+	assert.Equal(t, string(original[100:111]), string(slice.Bytes()))
+
+	view, err := c.View(60, 70)
+	assert.NoError(t, err)
+	_, err = Set(view, NewBytes([]byte("0123456789!")), 0)
+	assert.NoError(t, err)
+	expected := append([]byte(nil), original...)
+	copy(expected[60:70], "0123456789")
+	assert.Equal(t, string(expected), string(c.Bytes()))
+	assert.Equal(t, "0123456789", string(view.Bytes()))
+}
+
+func TestCompressedGrowTruncate(t *testing.T) {
+	t.Parallel()
+	c, err := NewCompressed(NewBytes([]byte("hello world")), 4)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, c.Truncate(6))
+	assert.Equal(t, "hello ", string(c.Bytes()))
+	assert.NoError(t, c.Grow(1000))
+	assert.Equal(t, 1006, c.Len())
+	assert.Equal(t, "hello \x00\x00", string(c.Bytes()[:8]))
+	assert.Equal(t, true, isZero(c.Bytes()[6:]))
+
+	n, err := c.WriteAt([]byte("end"), 1003)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	buf := make([]byte, 10)
+	n, err = c.ReadAt(buf, 1000)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "\x00\x00\x00end", string(buf[:n]))
+}
+
+func TestCompressedSaves(t *testing.T) {
+	t.Parallel()
+	original := bytes.Repeat([]byte("compressible "), 10000)
+	c, err := NewCompressed(NewBytes(original), 0)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if c.CompressedLen() >= len(original)/10 {
+		t.Errorf("Expected at least 10x compression, got %d -> %d bytes", len(original), c.CompressedLen())
+	}
+
+	zeros, err := NewCompressed(NewBytesLength(0), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, zeros.Grow(1<<30))
+	assert.Equal(t, 0, zeros.CompressedLen())
+}
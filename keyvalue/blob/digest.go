@@ -0,0 +1,28 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"io"
+)
+
+// DigestBlob is a Blob that can compute a SHA-256 digest of its contents, typically caching it until the next mutation.
+type DigestBlob interface {
+	Blob
+	Digest() ([sha256.Size]byte, error)
+}
+
+// Digest returns the SHA-256 digest of 'b'. Attempts to use an optimized b.Digest() if available, otherwise streams the contents through the hash.
+func Digest(b Blob) ([sha256.Size]byte, error) {
+	if b, ok := b.(DigestBlob); ok {
+		return b.Digest()
+	}
+	var digest [sha256.Size]byte
+	hash := sha256.New()
+	buf := DefaultPool.Get(32 << 10)
+	defer DefaultPool.Put(buf)
+	if _, err := io.CopyBuffer(hash, NewReader(b), buf); err != nil {
+		return digest, err
+	}
+	copy(digest[:], hash.Sum(nil))
+	return digest, nil
+}
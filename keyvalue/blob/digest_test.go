@@ -0,0 +1,25 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestDigest(t *testing.T) {
+	t.Parallel()
+	b := NewBytes([]byte("hello world"))
+	digest, err := Digest(b)
+	assert.NoError(t, err)
+	fallbackDigest, err := Digest(plainBlob{NewBytes([]byte("hello world"))})
+	assert.NoError(t, err)
+	assert.Equal(t, digest, fallbackDigest)
+
+	view, err := b.View(0, 5)
+	assert.NoError(t, err)
+	_, err = Set(view, NewBytes([]byte("HELLO")), 0)
+	assert.NoError(t, err)
+	newDigest, err := Digest(b)
+	assert.NoError(t, err)
+	assert.NotEqual(t, digest, newDigest)
+}
@@ -0,0 +1,244 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+// Package mmapblob contains a blob.Blob backed by a memory-mapped file.
+package mmapblob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+var (
+	_ interface {
+		blob.Blob
+		blob.ViewBlob
+		blob.SliceBlob
+		blob.SetBlob
+		blob.GrowBlob
+		blob.TruncateBlob
+		blob.ReaderAtBlob
+		blob.WriterAtBlob
+		io.Closer
+	} = &Blob{}
+)
+
+// Blob is a blob.Blob backed by a memory-mapped region of a file.
+// Reads are served directly from the mapping, so large values are never copied into the Go heap.
+//
+// The mapping is private: mutations are copy-on-write and never written back to the file.
+// Growing a Blob moves its data onto the Go heap, since a mapping can not be extended in place.
+//
+// Close unmaps the region. Afterward, the Blob and all of its Views fail with fs.ErrClosed, or panic if the method can not return an error.
+type Blob struct {
+	region *region
+	bytes  []byte
+}
+
+// region is a memory mapping shared by a Blob and all of its views
+type region struct {
+	mu     sync.Mutex
+	mapped []byte
+	closed bool
+}
+
+// Open maps the whole file at 'path' into a new Blob.
+// Later writes to the file by other processes may or may not be visible through the Blob, depending on the platform.
+func Open(path string) (*Blob, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return New(file, 0, int(info.Size()))
+}
+
+// New maps 'length' bytes of 'file' starting at 'offset' into a new Blob. 'offset' must be a multiple of the page size.
+// The mapping remains valid after 'file' is closed.
+func New(file *os.File, offset int64, length int) (*Blob, error) {
+	if offset < 0 || length < 0 {
+		return nil, fmt.Errorf("invalid mapping range: offset %d, length %d", offset, length)
+	}
+	if length == 0 {
+		// zero-length mappings are invalid, so use an empty region instead
+		return &Blob{region: &region{}}, nil
+	}
+	mapped, err := syscall.Mmap(int(file.Fd()), offset, length, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: file.Name(), Err: err}
+	}
+	return &Blob{
+		region: &region{mapped: mapped},
+		bytes:  mapped,
+	}, nil
+}
+
+// Close unmaps the Blob's memory region, invalidating the Blob and all of its Views.
+func (b *Blob) Close() error {
+	b.region.mu.Lock()
+	defer b.region.mu.Unlock()
+	if b.region.closed {
+		return fs.ErrClosed
+	}
+	b.region.closed = true
+	b.bytes = nil
+	if b.region.mapped == nil {
+		return nil
+	}
+	err := syscall.Munmap(b.region.mapped)
+	b.region.mapped = nil
+	return err
+}
+
+// lock locks the Blob's region, failing if it has been closed
+func (b *Blob) lock() error {
+	b.region.mu.Lock()
+	if b.region.closed {
+		b.region.mu.Unlock()
+		return fs.ErrClosed
+	}
+	return nil
+}
+
+func (b *Blob) unlock() {
+	b.region.mu.Unlock()
+}
+
+// Bytes implements blob.Blob. Returns a copy of the mapped bytes.
+func (b *Blob) Bytes() []byte {
+	if err := b.lock(); err != nil {
+		panic(err)
+	}
+	defer b.unlock()
+	return append([]byte(nil), b.bytes...)
+}
+
+// Len implements blob.Blob
+func (b *Blob) Len() int {
+	if err := b.lock(); err != nil {
+		return 0
+	}
+	defer b.unlock()
+	return len(b.bytes)
+}
+
+func (b *Blob) checkRange(start, end int64) error {
+	if start < 0 || start > int64(len(b.bytes)) {
+		return fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > int64(len(b.bytes)) {
+		return fmt.Errorf("End index out of bounds: %d", end)
+	}
+	return nil
+}
+
+// View implements blob.ViewBlob. The view shares the mapping, so it is invalidated when the original Blob is closed.
+func (b *Blob) View(start, end int64) (blob.Blob, error) {
+	if err := b.lock(); err != nil {
+		return nil, err
+	}
+	defer b.unlock()
+	if err := b.checkRange(start, end); err != nil {
+		return nil, err
+	}
+	return &Blob{region: b.region, bytes: b.bytes[start:end:end]}, nil
+}
+
+// Slice implements blob.SliceBlob. The returned copy remains valid after Close.
+func (b *Blob) Slice(start, end int64) (blob.Blob, error) {
+	if err := b.lock(); err != nil {
+		return nil, err
+	}
+	defer b.unlock()
+	if err := b.checkRange(start, end); err != nil {
+		return nil, err
+	}
+	return blob.NewBytes(append([]byte(nil), b.bytes[start:end]...)), nil
+}
+
+// Set implements blob.SetBlob
+func (b *Blob) Set(src blob.Blob, destStart int64) (n int, err error) {
+	if destStart < 0 {
+		return 0, errors.New("negative offset")
+	}
+	srcBytes := src.Bytes() // copy before locking, in case 'src' shares this region
+	if err := b.lock(); err != nil {
+		return 0, err
+	}
+	defer b.unlock()
+	if destStart > int64(len(b.bytes)) {
+		return 0, fmt.Errorf("Offset out of bounds: %d", destStart)
+	}
+	return copy(b.bytes[destStart:], srcBytes), nil
+}
+
+// ReadAt implements blob.ReaderAtBlob
+func (b *Blob) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if err := b.lock(); err != nil {
+		return 0, err
+	}
+	defer b.unlock()
+	if off < int64(len(b.bytes)) {
+		n = copy(p, b.bytes[off:])
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteAt implements blob.WriterAtBlob
+func (b *Blob) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if err := b.lock(); err != nil {
+		return 0, err
+	}
+	defer b.unlock()
+	if off <= int64(len(b.bytes)) {
+		n = copy(b.bytes[off:], p)
+	}
+	if n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// Grow implements blob.GrowBlob. Copies the Blob's data onto the Go heap.
+func (b *Blob) Grow(offset int64) error {
+	if err := b.lock(); err != nil {
+		return err
+	}
+	defer b.unlock()
+	grown := make([]byte, int64(len(b.bytes))+offset)
+	copy(grown, b.bytes)
+	b.bytes = grown
+	return nil
+}
+
+// Truncate implements blob.TruncateBlob
+func (b *Blob) Truncate(size int64) error {
+	if err := b.lock(); err != nil {
+		return err
+	}
+	defer b.unlock()
+	if size < int64(len(b.bytes)) {
+		b.bytes = b.bytes[:size:size]
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package mmapblob
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func openTestBlob(t *testing.T, contents string) (*Blob, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "blob")
+	if !assert.NoError(t, os.WriteFile(path, []byte(contents), 0600)) {
+		t.FailNow()
+	}
+	b, err := Open(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return b, path
+}
+
+func TestBlob(t *testing.T) {
+	t.Parallel()
+	b, path := openTestBlob(t, "hello world")
+	assert.Equal(t, 11, b.Len())
+	assert.Equal(t, "hello world", string(b.Bytes()))
+
+	view, err := b.View(6, 11)
+	assert.NoError(t, err)
+	buf := make([]byte, 10)
+	n, err := blob.NewReaderAt(view).ReadAt(buf, 0)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "world", string(buf[:n]))
+
+	_, err = b.Set(blob.NewBytes([]byte("WORLD")), 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "WORLD", string(view.Bytes()))
+	fileContents, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(fileContents))
+
+	assert.NoError(t, b.Grow(1))
+	assert.NoError(t, b.Truncate(5))
+	assert.Equal(t, "hello", string(b.Bytes()))
+	assert.NoError(t, b.Close())
+}
+
+func TestBlobClose(t *testing.T) {
+	t.Parallel()
+	b, _ := openTestBlob(t, "hello world")
+	view, err := b.View(0, 5)
+	assert.NoError(t, err)
+	slice, err := b.Slice(0, 5)
+	assert.NoError(t, err)
+
+	assert.NoError(t, b.Close())
+	assert.ErrorIs(t, fs.ErrClosed, b.Close())
+	_, err = view.(*Blob).ReadAt(make([]byte, 1), 0)
+	assert.ErrorIs(t, fs.ErrClosed, err)
+	assert.Equal(t, 0, view.Len())
+	assert.Equal(t, "hello", string(slice.Bytes()))
+}
+
+func TestBlobEmpty(t *testing.T) {
+	t.Parallel()
+	b, _ := openTestBlob(t, "")
+	assert.Equal(t, 0, b.Len())
+	assert.Equal(t, 0, len(b.Bytes()))
+	assert.NoError(t, b.Close())
+}
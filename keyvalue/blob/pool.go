@@ -0,0 +1,123 @@
+package blob
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	minPoolTier = 9  // 512 B
+	maxPoolTier = 24 // 16 MiB
+)
+
+// Pool reuses byte buffers to reduce allocations in hot paths. It's safe for concurrent use.
+//
+// Buffers are grouped into power-of-two size tiers, so a buffer is reused for any request up to its capacity.
+// Requests larger than 16 MiB are not pooled.
+type Pool struct {
+	tiers [maxPoolTier - minPoolTier + 1]sync.Pool
+
+	gets     int64
+	misses   int64
+	puts     int64
+	discards int64
+}
+
+// PoolMetrics counts a Pool's activity since it was created
+type PoolMetrics struct {
+	// Gets is the number of buffers requested
+	Gets int64
+	// Misses is the number of requested buffers which were newly allocated, rather than reused
+	Misses int64
+	// Puts is the number of buffers returned to the pool
+	Puts int64
+	// Discards is the number of returned buffers which were dropped, since they did not fit a size tier
+	Discards int64
+}
+
+// DefaultPool is the Pool shared by this module's hot paths. Applications may also use it to share buffers with them.
+var DefaultPool = NewPool()
+
+// NewPool returns a new, empty Pool
+func NewPool() *Pool {
+	return &Pool{}
+}
+
+// tier returns the tier index for buffers with capacity 'size', rounding up to the next power of two.
+// Returns false if 'size' is too large to pool.
+func tier(size int) (int, bool) {
+	if size <= 1<<minPoolTier {
+		return 0, true
+	}
+	power := bits.Len(uint(size - 1))
+	if power > maxPoolTier {
+		return 0, false
+	}
+	return power - minPoolTier, true
+}
+
+// Get returns a buffer with 'length' bytes. The contents are undefined, since the buffer may be reused.
+// Return it with Put() once it's no longer used.
+func (p *Pool) Get(length int) []byte {
+	atomic.AddInt64(&p.gets, 1)
+	index, ok := tier(length)
+	if !ok {
+		atomic.AddInt64(&p.misses, 1)
+		return make([]byte, length)
+	}
+	if buf, ok := p.tiers[index].Get().(*[]byte); ok {
+		return (*buf)[:length]
+	}
+	atomic.AddInt64(&p.misses, 1)
+	return make([]byte, length, 1<<(index+minPoolTier))
+}
+
+// Put returns 'buf' to the pool for reuse. 'buf' must not be used afterward.
+// Buffers not allocated by Get() are accepted too, as long as their capacity is a power of two in a pooled size tier.
+func (p *Pool) Put(buf []byte) {
+	atomic.AddInt64(&p.puts, 1)
+	index, ok := tier(cap(buf))
+	if !ok || cap(buf) != 1<<(index+minPoolTier) {
+		atomic.AddInt64(&p.discards, 1)
+		return
+	}
+	buf = buf[:cap(buf)]
+	p.tiers[index].Put(&buf)
+}
+
+// NewBytes returns a Bytes blob of 'length' zero bytes, backed by a pooled buffer.
+// Return it with PutBytes() once neither it nor any of its Views are used.
+func (p *Pool) NewBytes(length int) *Bytes {
+	buf := p.Get(length)
+	for i := range buf {
+		buf[i] = 0
+	}
+	return NewBytes(buf)
+}
+
+// PutBytes returns the buffer backing 'b' to the pool. Neither 'b' nor any of its Views may be used afterward.
+// A buffer still shared with a Clone is discarded instead, since the Clone keeps reading it.
+func (p *Pool) PutBytes(b *Bytes) {
+	b.mu.Lock()
+	buf, shared := b.buf.bytes, b.buf.shared
+	b.buf.bytes, b.offset = nil, 0
+	atomic.StoreInt64(&b.length, 0)
+	b.mu.Unlock()
+	if shared {
+		atomic.AddInt64(&p.puts, 1)
+		atomic.AddInt64(&p.discards, 1)
+		return
+	}
+	p.Put(buf)
+}
+
+// Metrics returns a snapshot of this pool's activity
+func (p *Pool) Metrics() PoolMetrics {
+	return PoolMetrics{
+		Gets:     atomic.LoadInt64(&p.gets),
+		Misses:   atomic.LoadInt64(&p.misses),
+		Puts:     atomic.LoadInt64(&p.puts),
+		Discards: atomic.LoadInt64(&p.discards),
+	}
+}
@@ -0,0 +1,73 @@
+package blob
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestPoolTiers(t *testing.T) {
+	t.Parallel()
+	pool := NewPool()
+	for _, tc := range []struct {
+		length      int
+		expectedCap int
+	}{
+		{length: 0, expectedCap: 512},
+		{length: 512, expectedCap: 512},
+		{length: 513, expectedCap: 1024},
+		{length: 150 << 10, expectedCap: 256 << 10},
+		{length: 16 << 20, expectedCap: 16 << 20},
+		{length: 16<<20 + 1, expectedCap: 16<<20 + 1},
+	} {
+		buf := pool.Get(tc.length)
+		assert.Equal(t, tc.length, len(buf))
+		assert.Equal(t, tc.expectedCap, cap(buf))
+	}
+}
+
+func TestPoolMetrics(t *testing.T) {
+	t.Parallel()
+	pool := NewPool()
+	buf := pool.Get(1000)
+	pool.Put(buf)
+	pool.Put(make([]byte, 1000)) // not a tier size
+	pool.Put(make([]byte, 32<<20))
+	_ = pool.Get(1000) // may or may not reuse, depending on the garbage collector
+
+	metrics := pool.Metrics()
+	assert.Equal(t, int64(2), metrics.Gets)
+	assert.Equal(t, int64(3), metrics.Puts)
+	assert.Equal(t, int64(2), metrics.Discards)
+	if metrics.Misses < 1 || metrics.Misses > 2 {
+		t.Errorf("Expected 1 or 2 misses, got %d", metrics.Misses)
+	}
+}
+
+func TestPoolBytes(t *testing.T) {
+	t.Parallel()
+	pool := NewPool()
+	buf := pool.Get(10)
+	copy(buf, "dirty data")
+	pool.Put(buf)
+
+	b := pool.NewBytes(10)
+	assert.Equal(t, make([]byte, 10), b.Bytes())
+	pool.PutBytes(b)
+	assert.Equal(t, 0, b.Len())
+}
+
+func TestPoolBytesShared(t *testing.T) {
+	t.Parallel()
+	pool := NewPool()
+	b := pool.NewBytes(10)
+	_, err := b.WriteAt([]byte("hello"), 0)
+	assert.NoError(t, err)
+	clone := b.Clone()
+	pool.PutBytes(b)
+	assert.Equal(t, int64(1), pool.Metrics().Discards)
+
+	buf := pool.Get(10)
+	copy(buf, "dirty data")
+	assert.Equal(t, "hello\x00\x00\x00\x00\x00", string(clone.Bytes()))
+}
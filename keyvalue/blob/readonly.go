@@ -0,0 +1,96 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+)
+
+var (
+	// ensure ReadOnly conforms to these interfaces:
+	_ interface {
+		Blob
+		ViewBlob
+		SliceBlob
+		SetBlob
+		GrowBlob
+		TruncateBlob
+		ReaderAtBlob
+		WriterAtBlob
+		DigestBlob
+	} = &ReadOnly{}
+)
+
+// ErrReadOnly is returned when mutating a ReadOnly blob. Satisfies errors.Is(err, fs.ErrPermission).
+var ErrReadOnly = fmt.Errorf("%w: read-only blob", fs.ErrPermission)
+
+// ReadOnly is a Blob which rejects all mutations with ErrReadOnly.
+//
+// A ReadOnly blob shares data with the Blob it wraps, so stores can hand out their data without defensive copies.
+// However, the wrapped Blob may still be mutated directly, and those changes are visible through the ReadOnly blob.
+// Its Views are also read-only, while Slices are independent, mutable copies.
+type ReadOnly struct {
+	blob Blob
+}
+
+// NewReadOnly returns a read-only view of 'b'. If 'b' is already read-only, it is returned as-is.
+func NewReadOnly(b Blob) *ReadOnly {
+	if b, ok := b.(*ReadOnly); ok {
+		return b
+	}
+	return &ReadOnly{blob: b}
+}
+
+// Bytes implements Blob.
+func (r *ReadOnly) Bytes() []byte {
+	return r.blob.Bytes()
+}
+
+// Len implements Blob.
+func (r *ReadOnly) Len() int {
+	return r.blob.Len()
+}
+
+// View implements ViewBlob. The view is also read-only.
+func (r *ReadOnly) View(start, end int64) (Blob, error) {
+	view, err := View(r.blob, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return NewReadOnly(view), nil
+}
+
+// Slice implements SliceBlob. The slice is a mutable copy.
+func (r *ReadOnly) Slice(start, end int64) (Blob, error) {
+	return Slice(r.blob, start, end)
+}
+
+// ReadAt implements ReaderAtBlob.
+func (r *ReadOnly) ReadAt(p []byte, off int64) (n int, err error) {
+	return NewReaderAt(r.blob).ReadAt(p, off)
+}
+
+// Digest implements DigestBlob.
+func (r *ReadOnly) Digest() ([sha256.Size]byte, error) {
+	return Digest(r.blob)
+}
+
+// Set implements SetBlob. Always fails with ErrReadOnly.
+func (r *ReadOnly) Set(Blob, int64) (n int, err error) {
+	return 0, ErrReadOnly
+}
+
+// WriteAt implements WriterAtBlob. Always fails with ErrReadOnly.
+func (r *ReadOnly) WriteAt([]byte, int64) (n int, err error) {
+	return 0, ErrReadOnly
+}
+
+// Grow implements GrowBlob. Always fails with ErrReadOnly.
+func (r *ReadOnly) Grow(int64) error {
+	return ErrReadOnly
+}
+
+// Truncate implements TruncateBlob. Always fails with ErrReadOnly.
+func (r *ReadOnly) Truncate(int64) error {
+	return ErrReadOnly
+}
@@ -0,0 +1,38 @@
+package blob
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+	b := NewBytes([]byte("hello world"))
+	r := NewReadOnly(b)
+	assert.Equal(t, r, NewReadOnly(r))
+
+	_, err := Set(r, NewBytes([]byte("HELLO")), 0)
+	assert.ErrorIs(t, fs.ErrPermission, err)
+	assert.ErrorIs(t, ErrReadOnly, Grow(r, 1))
+	assert.ErrorIs(t, ErrReadOnly, Truncate(r, 1))
+	_, err = NewWriterAt(r).WriteAt([]byte("HELLO"), 0)
+	assert.ErrorIs(t, ErrReadOnly, err)
+	assert.Equal(t, "hello world", string(b.Bytes()))
+
+	view, err := View(r, 0, 5)
+	assert.NoError(t, err)
+	_, err = Set(view, NewBytes([]byte("HELLO")), 0)
+	assert.ErrorIs(t, ErrReadOnly, err)
+
+	slice, err := Slice(r, 0, 5)
+	assert.NoError(t, err)
+	_, err = Set(slice, NewBytes([]byte("HELLO")), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(r.Bytes()))
+
+	_, err = b.Set(NewBytes([]byte("HELLO")), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO world", string(r.Bytes()))
+}
@@ -0,0 +1,217 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+var (
+	// ensure Segmented conforms to these interfaces:
+	_ interface {
+		Blob
+		ViewBlob
+		SliceBlob
+		SetBlob
+		GrowBlob
+		TruncateBlob
+		ReaderAtBlob
+		WriterAtBlob
+	} = &Segmented{}
+)
+
+const (
+	minSegmentSize = 4 << 10
+	maxSegmentSize = 1 << 20
+)
+
+// Segmented is a Blob which holds its data in a list of segments, rather than one contiguous byte slice.
+// Grow() only allocates new segments and Truncate() only drops segments, so neither copies existing data.
+// This makes Segmented well suited to append-heavy workloads, where Bytes would repeatedly reallocate and copy.
+type Segmented struct {
+	mu       sync.Mutex
+	segments [][]byte
+	starts   []int64 // starts[i] is the offset of segments[i]
+	length   int64
+}
+
+// NewSegmented returns a new Segmented blob, initially containing a copy of 'buf'
+func NewSegmented(buf []byte) *Segmented {
+	s := &Segmented{}
+	if len(buf) > 0 {
+		s.segments = [][]byte{append([]byte(nil), buf...)}
+		s.starts = []int64{0}
+		s.length = int64(len(buf))
+	}
+	return s
+}
+
+// segmentIndex returns the index of the segment containing 'offset', which must be less than length
+func (s *Segmented) segmentIndex(offset int64) int {
+	return sort.Search(len(s.starts), func(i int) bool {
+		return s.starts[i] > offset
+	}) - 1
+}
+
+// Bytes implements Blob.
+func (s *Segmented) Bytes() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf := make([]byte, 0, s.length)
+	for _, segment := range s.segments {
+		buf = append(buf, segment...)
+	}
+	return buf
+}
+
+// Len implements Blob.
+func (s *Segmented) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.length)
+}
+
+// ReadAt implements ReaderAtBlob.
+func (s *Segmented) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if off < s.length {
+		for i := s.segmentIndex(off); i < len(s.segments) && n < len(p); i++ {
+			n += copy(p[n:], s.segments[i][off+int64(n)-s.starts[i]:])
+		}
+	}
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// WriteAt implements WriterAtBlob.
+func (s *Segmented) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if off < s.length {
+		for i := s.segmentIndex(off); i < len(s.segments) && n < len(p); i++ {
+			n += copy(s.segments[i][off+int64(n)-s.starts[i]:], p[n:])
+		}
+	}
+	if n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+// View implements ViewBlob. The view reads and writes through to this blob.
+func (s *Segmented) View(start, end int64) (Blob, error) {
+	length := int64(s.Len())
+	if start < 0 || start > length {
+		return nil, fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > length {
+		return nil, fmt.Errorf("End index out of bounds: %d", end)
+	}
+	return &rangeView{parent: s, start: start, end: end}, nil
+}
+
+// Slice implements SliceBlob.
+func (s *Segmented) Slice(start, end int64) (Blob, error) {
+	length := int64(s.Len())
+	if start < 0 || start > length {
+		return nil, fmt.Errorf("Start index out of bounds: %d", start)
+	}
+	if end < start || end > length {
+		return nil, fmt.Errorf("End index out of bounds: %d", end)
+	}
+	buf := make([]byte, end-start)
+	_, err := s.ReadAt(buf, start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return NewBytes(buf), nil
+}
+
+// Set implements SetBlob.
+func (s *Segmented) Set(src Blob, destStart int64) (n int, err error) {
+	if destStart < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if destStart > int64(s.Len()) {
+		return 0, fmt.Errorf("Offset out of bounds: %d", destStart)
+	}
+	n, err = s.WriteAt(src.Bytes(), destStart)
+	if errors.Is(err, io.ErrShortWrite) {
+		err = nil // like Bytes.Set, copy as much as fits
+	}
+	return n, err
+}
+
+// Grow implements GrowBlob. Fills any spare capacity in the last segment, then appends new segments sized in proportion to the blob.
+func (s *Segmented) Grow(offset int64) error {
+	if offset < 0 {
+		return errors.New("negative offset")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remaining := offset
+	if last := len(s.segments) - 1; last >= 0 {
+		segment := s.segments[last]
+		spare := int64(cap(segment) - len(segment))
+		if spare > remaining {
+			spare = remaining
+		}
+		grown := segment[:int64(len(segment))+spare]
+		for i := len(segment); i < len(grown); i++ {
+			grown[i] = 0 // capacity may hold data from before a Truncate
+		}
+		s.segments[last] = grown
+		remaining -= spare
+	}
+	for remaining > 0 {
+		size := s.length + offset - remaining // grow geometrically with the blob's size
+		if size < minSegmentSize {
+			size = minSegmentSize
+		}
+		if size > maxSegmentSize {
+			size = maxSegmentSize
+		}
+		length := size
+		if remaining < length {
+			length = remaining
+		}
+		s.starts = append(s.starts, s.length+offset-remaining)
+		s.segments = append(s.segments, make([]byte, length, size))
+		remaining -= length
+	}
+	s.length += offset
+	return nil
+}
+
+// Truncate implements TruncateBlob.
+func (s *Segmented) Truncate(size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size < 0 || size >= s.length {
+		return nil
+	}
+	if size == 0 {
+		s.segments, s.starts, s.length = nil, nil, 0
+		return nil
+	}
+	last := s.segmentIndex(size - 1)
+	s.segments[last] = s.segments[last][:size-s.starts[last]]
+	for i := last + 1; i < len(s.segments); i++ {
+		s.segments[i] = nil // release dropped segments
+	}
+	s.segments = s.segments[:last+1]
+	s.starts = s.starts[:last+1]
+	s.length = size
+	return nil
+}
@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestSegmented(t *testing.T) {
+	t.Parallel()
+	s := NewSegmented([]byte("hello"))
+	var expected []byte
+	expected = append(expected, "hello"...)
+	for i := 0; i < 1000; i++ {
+		chunk := bytes.Repeat([]byte{byte(i)}, 37)
+		offset := int64(s.Len())
+		assert.NoError(t, s.Grow(int64(len(chunk))))
+		n, err := s.WriteAt(chunk, offset)
+		assert.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+		expected = append(expected, chunk...)
+	}
+	assert.Equal(t, len(expected), s.Len())
+	assert.Equal(t, expected, s.Bytes())
+
+	buf := make([]byte, 10000)
+	n, err := s.ReadAt(buf, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, expected[3:3+n], buf)
+
+	slice, err := s.Slice(4090, 4110) // crosses a segment boundary
+	assert.NoError(t, err)
+	assert.Equal(t, expected[4090:4110], slice.Bytes())
+
+	view, err := s.View(4090, 4110)
+	assert.NoError(t, err)
+	_, err = Set(view, NewBytes(bytes.Repeat([]byte("x"), 20)), 0)
+	assert.NoError(t, err)
+	copy(expected[4090:4110], bytes.Repeat([]byte("x"), 20))
+	assert.Equal(t, expected, s.Bytes())
+}
+
+func TestSegmentedTruncate(t *testing.T) {
+	t.Parallel()
+	s := NewSegmented(nil)
+	assert.NoError(t, s.Grow(10000))
+	_, err := s.WriteAt(bytes.Repeat([]byte("a"), 10000), 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Truncate(5000))
+	assert.Equal(t, 5000, s.Len())
+	assert.NoError(t, s.Grow(10))
+	buf := make([]byte, 20)
+	n, err := s.ReadAt(buf, 4995)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "aaaaa\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", string(buf[:n]))
+
+	assert.NoError(t, s.Truncate(0))
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, 0, len(s.Bytes()))
+}
+
+func benchmarkAppend(b *testing.B, newBlob func() Blob) {
+	const (
+		chunkSize = 4 << 10
+		totalSize = 64 << 20
+	)
+	chunk := NewBytes(bytes.Repeat([]byte("a"), chunkSize))
+	b.SetBytes(totalSize)
+	for i := 0; i < b.N; i++ {
+		blob := newBlob()
+		for offset := int64(0); offset < totalSize; offset += chunkSize {
+			if err := Grow(blob, chunkSize); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := Set(blob, chunk, offset); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkAppendBytes(b *testing.B) {
+	benchmarkAppend(b, func() Blob { return NewBytes(nil) })
+}
+
+func BenchmarkAppendSegmented(b *testing.B) {
+	benchmarkAppend(b, func() Blob { return NewSegmented(nil) })
+}
@@ -0,0 +1,92 @@
+package blob
+
+import (
+	"errors"
+	"io"
+)
+
+// ReaderAtBlob is a Blob that can copy part of its data into 'p', without materializing all of it with Bytes().
+// ReadAt follows the io.ReaderAt contract.
+type ReaderAtBlob interface {
+	Blob
+	ReadAt(p []byte, off int64) (n int, err error)
+}
+
+// WriterAtBlob is a Blob that can copy 'p' into itself starting at 'off', without wrapping 'p' in another Blob.
+// Writes never grow the Blob: if 'p' extends past the end of the Blob, only the bytes that fit are written and io.ErrShortWrite is returned.
+type WriterAtBlob interface {
+	Blob
+	WriteAt(p []byte, off int64) (n int, err error)
+}
+
+// NewReaderAt returns an io.ReaderAt for 'b'. Uses an optimized b.ReadAt() if available, otherwise reads through a View().
+func NewReaderAt(b Blob) io.ReaderAt {
+	if b, ok := b.(ReaderAtBlob); ok {
+		return b
+	}
+	return blobReaderAt{b}
+}
+
+// NewReader returns an io.ReadSeeker which streams the contents of 'b' from the start.
+func NewReader(b Blob) io.ReadSeeker {
+	return io.NewSectionReader(NewReaderAt(b), 0, int64(b.Len()))
+}
+
+// NewWriterAt returns an io.WriterAt for 'b'. Uses an optimized b.WriteAt() if available, otherwise writes with Set().
+// Writes follow the rules of WriterAtBlob, so 'b' must first be grown to fit.
+func NewWriterAt(b Blob) io.WriterAt {
+	if b, ok := b.(WriterAtBlob); ok {
+		return b
+	}
+	return blobWriterAt{b}
+}
+
+type blobReaderAt struct {
+	Blob
+}
+
+func (b blobReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	length := int64(b.Len())
+	if off >= length {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > length {
+		end = length
+	}
+	view, err := View(b.Blob, off, end)
+	if err != nil {
+		return 0, err
+	}
+	n = copy(p, view.Bytes())
+	if n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+type blobWriterAt struct {
+	Blob
+}
+
+func (b blobWriterAt) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	length := int64(b.Len())
+	if off > length {
+		return 0, io.ErrShortWrite
+	}
+	end := off + int64(len(p))
+	if end > length {
+		end = length
+	}
+	n, err = Set(b.Blob, NewBytes(p[:end-off]), off)
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
@@ -0,0 +1,75 @@
+package blob
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+// plainBlob only implements Blob, to exercise fallbacks
+type plainBlob struct {
+	bytes *Bytes
+}
+
+func (b plainBlob) Bytes() []byte { return b.bytes.Bytes() }
+func (b plainBlob) Len() int      { return b.bytes.Len() }
+
+// setOnlyBlob is only mutable with Set()
+type setOnlyBlob struct {
+	plainBlob
+}
+
+func (b setOnlyBlob) Set(src Blob, offset int64) (int, error) { return b.bytes.Set(src, offset) }
+
+func TestNewReader(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		blob        Blob
+	}{
+		{description: "bytes", blob: NewBytes([]byte("hello world"))},
+		{description: "fallback", blob: plainBlob{NewBytes([]byte("hello world"))}},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			contents, err := io.ReadAll(NewReader(tc.blob))
+			assert.NoError(t, err)
+			assert.Equal(t, "hello world", string(contents))
+
+			buf := make([]byte, 8)
+			n, err := NewReaderAt(tc.blob).ReadAt(buf, 6)
+			assert.Equal(t, io.EOF, err)
+			assert.Equal(t, "world", string(buf[:n]))
+		})
+	}
+}
+
+func TestNewWriterAt(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		blob        Blob
+	}{
+		{description: "bytes", blob: NewBytes([]byte("hello world"))},
+		{description: "fallback", blob: setOnlyBlob{plainBlob{NewBytes([]byte("hello world"))}}},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			n, err := NewWriterAt(tc.blob).WriteAt([]byte("WORLD!"), 6)
+			assert.Equal(t, io.ErrShortWrite, err)
+			assert.Equal(t, 5, n)
+			assert.Equal(t, "hello WORLD", string(tc.blob.Bytes()))
+		})
+	}
+}
+
+func TestBytesSlice(t *testing.T) {
+	t.Parallel()
+	b := NewBytes([]byte("hello world"))
+	slice, err := b.Slice(6, 11)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(slice.Bytes()))
+}
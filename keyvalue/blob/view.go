@@ -0,0 +1,71 @@
+package blob
+
+import (
+	"errors"
+	"io"
+)
+
+// rangeView is a window into a Blob which is not backed by a single byte slice, like Compressed.
+// Reads and writes pass through to the parent.
+type rangeView struct {
+	parent interface {
+		ReaderAtBlob
+		WriterAtBlob
+	}
+	start, end int64
+}
+
+func (v *rangeView) Bytes() []byte {
+	buf := make([]byte, v.Len())
+	_, err := v.parent.ReadAt(buf, v.start)
+	if err != nil && !errors.Is(err, io.EOF) {
+		panic(err)
+	}
+	return buf
+}
+
+func (v *rangeView) Len() int {
+	return int(v.end - v.start)
+}
+
+func (v *rangeView) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= v.end-v.start {
+		return 0, io.EOF
+	}
+	if max := v.end - v.start - off; int64(len(p)) > max {
+		n, err = v.parent.ReadAt(p[:max], v.start+off)
+		if err == nil {
+			err = io.EOF
+		}
+		return n, err
+	}
+	return v.parent.ReadAt(p, v.start+off)
+}
+
+func (v *rangeView) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off > v.end-v.start {
+		return 0, io.ErrShortWrite
+	}
+	if max := v.end - v.start - off; int64(len(p)) > max {
+		n, err = v.parent.WriteAt(p[:max], v.start+off)
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return n, err
+	}
+	return v.parent.WriteAt(p, v.start+off)
+}
+
+func (v *rangeView) Set(src Blob, destStart int64) (n int, err error) {
+	n, err = v.WriteAt(src.Bytes(), destStart)
+	if errors.Is(err, io.ErrShortWrite) {
+		err = nil
+	}
+	return n, err
+}
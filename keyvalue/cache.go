@@ -0,0 +1,448 @@
+package keyvalue
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+var (
+	_ interface {
+		TransactionStore
+		KeysStore
+		SchemaStore
+		AsyncStore
+		LockStore
+		JournalStore
+		SnapshotStore
+		MoveStore
+		MultiGetStore
+		ExpiringStore
+		BlobStore
+		XattrStore
+	} = &CacheStore{}
+	_ storeDecorator = &CacheStore{}
+)
+
+// CacheStore is a Store decorator which keeps the most recently read records in memory.
+// Stat-heavy workloads, like WalkDir, can then skip round trips to slow stores such as IndexedDB.
+// Reads are served from the cache both directly and inside transactions.
+//
+// Writes always pass through to the underlying store and evict the written path.
+// CacheStore assumes it is the only writer to the underlying store, otherwise cached records may become stale.
+//
+// CacheStore implements every optional Store interface by forwarding to the underlying store, so FS features like GC() keep working when caching.
+// Methods the underlying store does not support return hackpadfs.ErrNotImplemented, and FS treats them as unsupported.
+type CacheStore struct {
+	store Store
+	size  int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is most recently used
+}
+
+type cacheEntry struct {
+	path   string
+	record FileRecord
+}
+
+// NewCacheStore returns a CacheStore wrapping 'store' which caches up to 'size' records.
+func NewCacheStore(store Store, size int) (*CacheStore, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("cache size must be positive, got %d: %w", size, hackpadfs.ErrInvalid)
+	}
+	return &CacheStore{
+		store:   store,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}, nil
+}
+
+// Get implements keyvalue.Store
+func (c *CacheStore) Get(ctx context.Context, path string) (FileRecord, error) {
+	if record, ok := c.load(path); ok {
+		return record, nil
+	}
+	record, err := c.store.Get(ctx, path)
+	if err == nil {
+		c.add(path, record)
+	}
+	return record, err
+}
+
+// Set implements keyvalue.Store
+func (c *CacheStore) Set(ctx context.Context, path string, src FileRecord) error {
+	c.Evict(path)
+	return c.store.Set(ctx, path, src)
+}
+
+// Transaction implements keyvalue.TransactionStore
+func (c *CacheStore) Transaction(options TransactionOptions) (Transaction, error) {
+	store, ok := c.store.(TransactionStore)
+	if !ok {
+		return newSerialTransaction(context.Background(), c), nil
+	}
+	txn, err := store.Transaction(options)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheTransaction{Transaction: txn, cache: c}, nil
+}
+
+// Keys implements keyvalue.KeysStore
+func (c *CacheStore) Keys(ctx context.Context) ([]string, error) {
+	store, ok := c.store.(KeysStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.Keys(ctx)
+}
+
+// GetMany implements keyvalue.MultiGetStore. Cached records are served directly, the rest are fetched in one request and cached.
+func (c *CacheStore) GetMany(ctx context.Context, paths []string) ([]OpResult, error) {
+	store, ok := c.store.(MultiGetStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	results := make([]OpResult, len(paths))
+	var missPaths []string
+	var missIndexes []int
+	for i, path := range paths {
+		results[i].Op = OpID(i)
+		if record, ok := c.load(path); ok {
+			results[i].Record = record
+		} else {
+			missPaths = append(missPaths, path)
+			missIndexes = append(missIndexes, i)
+		}
+	}
+	if len(missPaths) == 0 {
+		return results, nil
+	}
+	missResults, err := store.GetMany(ctx, missPaths)
+	if err != nil {
+		return nil, err
+	}
+	for j, result := range missResults {
+		i := missIndexes[j]
+		results[i].Record, results[i].Err = result.Record, result.Err
+		if result.Err == nil {
+			c.add(paths[i], result.Record)
+		}
+	}
+	return results, nil
+}
+
+// SchemaVersion implements keyvalue.SchemaStore
+func (c *CacheStore) SchemaVersion(ctx context.Context) (int, error) {
+	store, ok := c.store.(SchemaStore)
+	if !ok {
+		return 0, hackpadfs.ErrNotImplemented
+	}
+	return store.SchemaVersion(ctx)
+}
+
+// SetSchemaVersion implements keyvalue.SchemaStore
+func (c *CacheStore) SetSchemaVersion(ctx context.Context, version int) error {
+	store, ok := c.store.(SchemaStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.SetSchemaVersion(ctx, version)
+}
+
+// GetAsync implements keyvalue.AsyncStore
+func (c *CacheStore) GetAsync(ctx context.Context, path string) *Future {
+	future, resolve := NewFuture()
+	if record, ok := c.load(path); ok {
+		resolve(record, nil)
+		return future
+	}
+	store, ok := c.store.(AsyncStore)
+	if !ok {
+		resolve(nil, hackpadfs.ErrNotImplemented)
+		return future
+	}
+	storeFuture := store.GetAsync(ctx, path)
+	go func() {
+		record, err := storeFuture.Await(context.Background())
+		if err == nil {
+			c.add(path, record)
+		}
+		resolve(record, err)
+	}()
+	return future
+}
+
+// SetAsync implements keyvalue.AsyncStore
+func (c *CacheStore) SetAsync(ctx context.Context, path string, src FileRecord) *Future {
+	c.Evict(path)
+	store, ok := c.store.(AsyncStore)
+	if !ok {
+		future, resolve := NewFuture()
+		resolve(nil, hackpadfs.ErrNotImplemented)
+		return future
+	}
+	return store.SetAsync(ctx, path, src)
+}
+
+// GetLock implements keyvalue.LockStore
+func (c *CacheStore) GetLock(ctx context.Context, path string) (LockRecord, error) {
+	store, ok := c.store.(LockStore)
+	if !ok {
+		return LockRecord{}, hackpadfs.ErrNotImplemented
+	}
+	return store.GetLock(ctx, path)
+}
+
+// CompareAndSwapLock implements keyvalue.LockStore
+func (c *CacheStore) CompareAndSwapLock(ctx context.Context, path string, revision uint64, record LockRecord) (bool, error) {
+	store, ok := c.store.(LockStore)
+	if !ok {
+		return false, hackpadfs.ErrNotImplemented
+	}
+	return store.CompareAndSwapLock(ctx, path, revision, record)
+}
+
+// JournalEntries implements keyvalue.JournalStore
+func (c *CacheStore) JournalEntries(ctx context.Context) (map[string][]byte, error) {
+	store, ok := c.store.(JournalStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.JournalEntries(ctx)
+}
+
+// SetJournalEntry implements keyvalue.JournalStore
+func (c *CacheStore) SetJournalEntry(ctx context.Context, id string, entry []byte) error {
+	store, ok := c.store.(JournalStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.SetJournalEntry(ctx, id, entry)
+}
+
+// Snapshot implements keyvalue.SnapshotStore
+func (c *CacheStore) Snapshot(ctx context.Context) (Store, error) {
+	store, ok := c.store.(SnapshotStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.Snapshot(ctx)
+}
+
+// Move implements keyvalue.MoveStore
+func (c *CacheStore) Move(ctx context.Context, oldPath, newPath string) error {
+	store, ok := c.store.(MoveStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	c.Evict(oldPath)
+	c.Evict(newPath)
+	return store.Move(ctx, oldPath, newPath)
+}
+
+// SetExpiration implements keyvalue.ExpiringStore
+func (c *CacheStore) SetExpiration(ctx context.Context, path string, expiresAt time.Time) error {
+	store, ok := c.store.(ExpiringStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	c.Evict(path)
+	return store.SetExpiration(ctx, path, expiresAt)
+}
+
+// NewBlob implements keyvalue.BlobStore. Returns an empty Bytes if the underlying store does not implement BlobStore.
+func (c *CacheStore) NewBlob() blob.Blob {
+	store, ok := c.store.(BlobStore)
+	if !ok {
+		return blob.NewBytes(nil)
+	}
+	return store.NewBlob()
+}
+
+// GetXattrs implements keyvalue.XattrStore
+func (c *CacheStore) GetXattrs(ctx context.Context, path string) (map[string][]byte, error) {
+	store, ok := c.store.(XattrStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.GetXattrs(ctx, path)
+}
+
+// SetXattrs implements keyvalue.XattrStore
+func (c *CacheStore) SetXattrs(ctx context.Context, path string, attrs map[string][]byte) error {
+	store, ok := c.store.(XattrStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.SetXattrs(ctx, path, attrs)
+}
+
+func (c *CacheStore) decoratedStore() Store {
+	return c.store
+}
+
+// Len returns the number of cached records
+func (c *CacheStore) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Evict removes 'path' from the cache, if present
+func (c *CacheStore) Evict(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[path]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, path)
+	}
+}
+
+func (c *CacheStore) load(path string) (FileRecord, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[path]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).record, true
+}
+
+func (c *CacheStore) add(path string, record FileRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[path]; ok {
+		elem.Value.(*cacheEntry).record = record
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[path] = c.lru.PushFront(&cacheEntry{path: path, record: record})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).path)
+	}
+}
+
+// cacheTransaction serves reads from the cache and evicts each path written by the underlying store's Transaction.
+// Since cached reads never reach the underlying transaction, it tracks its own OpIDs and merges both kinds of results in Commit().
+type cacheTransaction struct {
+	Transaction
+	cache *CacheStore
+
+	mu      sync.Mutex
+	ops     []cacheOp
+	written map[string]bool
+}
+
+type cacheOp struct {
+	cached bool
+	result OpResult // set if cached
+	op     OpID     // the underlying transaction's OpID if not cached
+}
+
+func (t *cacheTransaction) newOp() OpID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ops = append(t.ops, cacheOp{})
+	return OpID(len(t.ops) - 1)
+}
+
+func (t *cacheTransaction) setOp(op OpID, cOp cacheOp) {
+	t.mu.Lock()
+	t.ops[op] = cOp
+	t.mu.Unlock()
+}
+
+func (t *cacheTransaction) Get(path string) OpID {
+	return t.GetHandler(path, OpHandlerFunc(func(_ Transaction, _ OpResult) error {
+		return nil
+	}))
+}
+
+func (t *cacheTransaction) GetHandler(path string, handler OpHandler) OpID {
+	op := t.newOp()
+	t.mu.Lock()
+	written := t.written[path]
+	t.mu.Unlock()
+	if record, ok := t.cache.load(path); ok && !written {
+		result := OpResult{Op: op, Record: record}
+		if err := handler.Handle(t, result); err != nil {
+			result.Err = err
+		}
+		t.setOp(op, cacheOp{cached: true, result: result})
+		return op
+	}
+	innerOp := t.Transaction.GetHandler(path, OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		t.mu.Lock()
+		written := t.written[path]
+		t.mu.Unlock()
+		if result.Err == nil && !written {
+			t.cache.add(path, result.Record)
+		}
+		result.Op = op
+		return handler.Handle(t, result)
+	}))
+	t.setOp(op, cacheOp{op: innerOp})
+	return op
+}
+
+func (t *cacheTransaction) Set(path string, src FileRecord, contents blob.Blob) OpID {
+	return t.SetHandler(path, src, contents, OpHandlerFunc(func(_ Transaction, _ OpResult) error {
+		return nil
+	}))
+}
+
+func (t *cacheTransaction) SetHandler(path string, src FileRecord, contents blob.Blob, handler OpHandler) OpID {
+	op := t.newOp()
+	t.mu.Lock()
+	if t.written == nil {
+		t.written = make(map[string]bool)
+	}
+	t.written[path] = true
+	t.mu.Unlock()
+	t.cache.Evict(path)
+	innerOp := t.Transaction.SetHandler(path, src, contents, OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		result.Op = op
+		return handler.Handle(t, result)
+	}))
+	t.setOp(op, cacheOp{op: innerOp})
+	return op
+}
+
+func (t *cacheTransaction) Commit(ctx context.Context) ([]OpResult, error) {
+	innerResults, err := t.Transaction.Commit(ctx)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for path := range t.written {
+		// evict again, in case a read in this transaction completed after the write was issued
+		t.cache.Evict(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	innerResultsByOp := make(map[OpID]OpResult, len(innerResults))
+	for _, result := range innerResults {
+		innerResultsByOp[result.Op] = result
+	}
+	results := make([]OpResult, len(t.ops))
+	for i, cOp := range t.ops {
+		if cOp.cached {
+			results[i] = cOp.result
+			continue
+		}
+		results[i] = innerResultsByOp[cOp.op]
+		results[i].Op = OpID(i)
+	}
+	return results, nil
+}
@@ -0,0 +1,184 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+type countingStore struct {
+	*mapStore
+	gets int64
+}
+
+func (s *countingStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	atomic.AddInt64(&s.gets, 1)
+	return s.mapStore.Get(ctx, path)
+}
+
+func TestNewCacheStoreInvalidSize(t *testing.T) {
+	t.Parallel()
+	_, err := keyvalue.NewCacheStore(newMapStore(), 0)
+	assert.Equal(t, true, errors.Is(err, hackpadfs.ErrInvalid))
+}
+
+func TestCacheStore(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{mapStore: newMapStore()}
+	cache, err := keyvalue.NewCacheStore(store, 2)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fs, err := keyvalue.NewFS(cache)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+
+	_, err = fs.Stat("foo")
+	assert.NoError(t, err)
+	gets := atomic.LoadInt64(&store.gets)
+	_, err = fs.Stat("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, gets, atomic.LoadInt64(&store.gets))
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo bar"), 0600))
+	info, err := fs.Stat("foo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(len("foo bar")), info.Size())
+	}
+
+	assert.NoError(t, fs.Remove("foo"))
+	_, err = fs.Stat("foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	for _, name := range []string{"a", "b", "c"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, name, nil, 0600))
+		_, err := fs.Stat(name)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, cache.Len())
+}
+
+type txnMapStore struct {
+	*mapStore
+}
+
+func (s txnMapStore) Transaction(options keyvalue.TransactionOptions) (keyvalue.Transaction, error) {
+	return keyvalue.TransactionOrSerial(s.mapStore, options)
+}
+
+type txnCountingStore struct {
+	*countingStore
+}
+
+func (s txnCountingStore) Transaction(options keyvalue.TransactionOptions) (keyvalue.Transaction, error) {
+	return keyvalue.TransactionOrSerial(s.countingStore, options)
+}
+
+func TestCacheStoreTransaction(t *testing.T) {
+	t.Parallel()
+	store := &countingStore{mapStore: newMapStore()}
+	cache, err := keyvalue.NewCacheStore(txnCountingStore{store}, 10)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fs, err := keyvalue.NewFS(cache)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	_, err = fs.Stat("foo")
+	assert.NoError(t, err)
+	gets := atomic.LoadInt64(&store.gets)
+	_, err = fs.Stat("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, gets, atomic.LoadInt64(&store.gets))
+
+	_, err = fs.Stat("bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = fs.Stat("bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	assert.Equal(t, gets+2, atomic.LoadInt64(&store.gets))
+
+	assert.NoError(t, fs.Chmod("foo", 0700))
+	info, err := fs.Stat("foo")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.FileMode(0700), info.Mode())
+	}
+}
+
+type mapBlobStore struct {
+	*mapStore
+	newBlobs int64
+}
+
+func (s *mapBlobStore) NewBlob() blob.Blob {
+	atomic.AddInt64(&s.newBlobs, 1)
+	return blob.NewBytes(nil)
+}
+
+func TestCacheStoreOptionalInterfaces(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("forwards to supported interfaces", func(t *testing.T) {
+		t.Parallel()
+		cache, err := keyvalue.NewCacheStore(mapKeysStore{newMapStore()}, 10)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		fs, err := keyvalue.NewFS(cache)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		keys, err := cache.Keys(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"."}, keys)
+		_, err = fs.GC(ctx, keyvalue.GCOptions{DryRun: true})
+		assert.NoError(t, err)
+	})
+
+	t.Run("forwards new blobs", func(t *testing.T) {
+		t.Parallel()
+		store := &mapBlobStore{mapStore: newMapStore()}
+		cache, err := keyvalue.NewCacheStore(store, 10)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		fs, err := keyvalue.NewFS(cache)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+		assert.Equal(t, true, atomic.LoadInt64(&store.newBlobs) > 0)
+	})
+
+	t.Run("unsupported interfaces are disabled", func(t *testing.T) {
+		t.Parallel()
+		cache, err := keyvalue.NewCacheStore(newMapStore(), 10)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		fs, err := keyvalue.NewFS(cache)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = cache.Keys(ctx)
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+		_, err = fs.GC(ctx, keyvalue.GCOptions{DryRun: true})
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+		_, err = fs.Snapshot(ctx)
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+		_, err = fs.GetXattr(".", "user.foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+		_, err = keyvalue.NewFSWithOptions(cache, keyvalue.FSOptions{Migrations: keyvalue.NewMigrationRegistry()})
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+	})
+}
@@ -0,0 +1,48 @@
+package keyvalue_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+type setCountingStore struct {
+	*mapStore
+	sets int64
+}
+
+func (s *setCountingStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	atomic.AddInt64(&s.sets, 1)
+	return s.mapStore.Set(ctx, path, src)
+}
+
+func TestSkipUnchangedWrites(t *testing.T) {
+	t.Parallel()
+	store := &setCountingStore{mapStore: newMapStore()}
+	fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{SkipUnchangedWrites: true})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("hello world"), 0600))
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadWrite, 0)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() { assert.NoError(t, f.Close()) }()
+
+	sets := atomic.LoadInt64(&store.sets)
+	_, err = hackpadfs.WriteAtFile(f, []byte("world"), 6)
+	assert.NoError(t, err)
+	assert.Equal(t, sets, atomic.LoadInt64(&store.sets))
+
+	_, err = hackpadfs.WriteAtFile(f, []byte("WORLD"), 6)
+	assert.NoError(t, err)
+	assert.Equal(t, sets+1, atomic.LoadInt64(&store.sets))
+	contents, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "hello WORLD", string(contents))
+}
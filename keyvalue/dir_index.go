@@ -0,0 +1,63 @@
+package keyvalue
+
+import (
+	"path"
+	"sort"
+	"sync"
+)
+
+// DirIndex tracks the child names of each directory, so Stores can implement FileRecord.ReadDirNames() in O(children) instead of scanning every key.
+// Stores without native prefix or parent queries should Add() and Remove() each path as its record is set or deleted.
+//
+// DirIndex is safe for concurrent use.
+type DirIndex struct {
+	mu       sync.RWMutex
+	children map[string]map[string]struct{}
+}
+
+// NewDirIndex returns a new, empty DirIndex.
+func NewDirIndex() *DirIndex {
+	return &DirIndex{
+		children: make(map[string]map[string]struct{}),
+	}
+}
+
+// Add records 'name' as an entry of its parent directory. The root directory "." is not an entry of any directory.
+func (d *DirIndex) Add(name string) {
+	if name == "." {
+		return
+	}
+	dir, base := path.Dir(name), path.Base(name)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names, ok := d.children[dir]
+	if !ok {
+		names = make(map[string]struct{})
+		d.children[dir] = names
+	}
+	names[base] = struct{}{}
+}
+
+// Remove removes 'name' from its parent directory's entries.
+func (d *DirIndex) Remove(name string) {
+	dir, base := path.Dir(name), path.Base(name)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := d.children[dir]
+	delete(names, base)
+	if len(names) == 0 {
+		delete(d.children, dir)
+	}
+}
+
+// Names returns the sorted entry names of directory 'dir'
+func (d *DirIndex) Names(dir string) []string {
+	d.mu.RLock()
+	names := make([]string, 0, len(d.children[dir]))
+	for name := range d.children[dir] {
+		names = append(names, name)
+	}
+	d.mu.RUnlock()
+	sort.Strings(names)
+	return names
+}
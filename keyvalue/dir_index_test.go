@@ -0,0 +1,29 @@
+package keyvalue
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestDirIndex(t *testing.T) {
+	t.Parallel()
+	index := NewDirIndex()
+	index.Add(".")
+	index.Add("foo")
+	index.Add("foo/baz")
+	index.Add("foo/bar")
+	index.Add("foo/bar/biff")
+	index.Add("foo/bar")
+
+	assert.Equal(t, []string{"foo"}, index.Names("."))
+	assert.Equal(t, []string{"bar", "baz"}, index.Names("foo"))
+	assert.Equal(t, []string{"biff"}, index.Names("foo/bar"))
+	assert.Equal(t, []string{}, index.Names("missing"))
+
+	index.Remove("foo/baz")
+	index.Remove("foo/bar/biff")
+	index.Remove("missing/file")
+	assert.Equal(t, []string{"bar"}, index.Names("foo"))
+	assert.Equal(t, []string{}, index.Names("foo/bar"))
+}
@@ -0,0 +1,158 @@
+package keyvalue
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// DurabilityMode controls when file content writes are flushed to the Store
+type DurabilityMode int
+
+// Durability modes
+const (
+	// DurabilityEveryOp flushes every write to the store before the write returns. This is the default.
+	DurabilityEveryOp DurabilityMode = iota
+	// DurabilityInterval flushes written files in batches, at most FSOptions.FlushInterval after the first unflushed write.
+	DurabilityInterval
+	// DurabilityOnSync only flushes written files during file.Sync(), file.Close(), or FS.Flush().
+	DurabilityOnSync
+)
+
+const defaultFlushInterval = 100 * time.Millisecond
+
+// flusher holds written files which have not yet been flushed to the store.
+// Reads of unflushed files are served from here, so every FS operation sees the latest writes regardless of durability.
+type flusher struct {
+	mode     DurabilityMode
+	interval time.Duration
+
+	mu          sync.Mutex
+	pending     map[string]pendingFile
+	lastVersion uint64
+	flushTimer  *time.Timer
+}
+
+// pendingFile is an unflushed file. 'version' changes on every write, so a store write can tell whether it saved the latest contents.
+type pendingFile struct {
+	file    *fileData
+	version uint64
+}
+
+func newFlusher(mode DurabilityMode, interval time.Duration) *flusher {
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	return &flusher{
+		mode:     mode,
+		interval: interval,
+		pending:  make(map[string]pendingFile),
+	}
+}
+
+// markDirty schedules 'file' to be flushed according to the durability mode
+func (f *flusher) markDirty(file *fileData) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastVersion++
+	f.pending[file.path] = pendingFile{file: file, version: f.lastVersion}
+	if f.mode == DurabilityInterval && f.flushTimer == nil {
+		f.flushTimer = time.AfterFunc(f.interval, func() {
+			f.mu.Lock()
+			f.flushTimer = nil
+			f.mu.Unlock()
+			_ = f.flushAll() // failed files remain pending, so they're retried and the error is returned by the next Sync, Close, or Flush
+		})
+	}
+}
+
+// get returns the unflushed file data for 'path', if any
+func (f *flusher) get(path string) (*fileData, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pending, ok := f.pending[path]
+	return pending.file, ok
+}
+
+// version returns the current version of unflushed writes to 'path', or 0 if there are none
+func (f *flusher) version(path string) uint64 {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pending[path].version
+}
+
+// forget drops 'path' from pending flushes if it hasn't been written since 'version'.
+// Called after 'path' is successfully written or deleted directly in the store.
+func (f *flusher) forget(path string, version uint64) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	if pending, ok := f.pending[path]; ok && pending.version == version {
+		delete(f.pending, path)
+	}
+	f.mu.Unlock()
+}
+
+// flush writes 'file' to the store if it has unflushed writes
+func (f *flusher) flush(file *fileData) error {
+	f.mu.Lock()
+	pending, ok := f.pending[file.path]
+	f.mu.Unlock()
+	if !ok || pending.file != file {
+		return nil
+	}
+	return file.save()
+}
+
+func (f *flusher) flushAll() error {
+	f.mu.Lock()
+	files := make([]*fileData, 0, len(f.pending))
+	for _, pending := range f.pending {
+		files = append(files, pending.file)
+	}
+	f.mu.Unlock()
+
+	var firstErr error
+	for _, file := range files {
+		if err := f.flush(file); err != nil && firstErr == nil {
+			firstErr = &hackpadfs.PathError{Op: "flush", Path: file.path, Err: err}
+		}
+	}
+	return firstErr
+}
+
+// Flush writes all unflushed file contents to the store. Only required for DurabilityInterval and DurabilityOnSync.
+func (fs *FS) Flush() error {
+	if fs.flusher == nil {
+		return nil
+	}
+	return fs.flusher.flushAll()
+}
+
+// saveContents saves the file after a content write, unless the durability mode defers it.
+// Files opened with FlagSync are always saved immediately, and ask the store for durable transactions.
+func (f *file) saveContents() error {
+	if f.flag&hackpadfs.FlagSync != 0 {
+		return f.fs.setFileWithOptions(f.path, f.fileData, TransactionOptions{Mode: TransactionReadWrite, Durable: true})
+	}
+	if f.fs.flusher == nil {
+		return f.save()
+	}
+	f.fs.flusher.markDirty(f.fileData)
+	return nil
+}
+
+func (f *file) Sync() error {
+	if f.fileData == nil {
+		return hackpadfs.ErrClosed
+	}
+	if f.fs.flusher == nil {
+		return nil
+	}
+	return f.fs.wrapperErr("sync", f.path, f.fs.flusher.flush(f.fileData))
+}
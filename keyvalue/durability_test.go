@@ -0,0 +1,294 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+func TestFSDurabilityOnSync(t *testing.T) {
+	t.Parallel()
+	newFS := func(tb testing.TB, store keyvalue.Store) *keyvalue.FS {
+		tb.Helper()
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{Durability: keyvalue.DurabilityOnSync})
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return fs
+	}
+	var stores sync.Map // type: *keyvalue.FS -> keyvalue.Store
+	options := fstest.FSOptions{
+		Name: "keyvalue on sync",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			store := newMapStore()
+			fs := newFS(tb, store)
+			stores.Store(fs, store)
+			return fs
+		},
+		Reopen: func(tb testing.TB, fs hackpadfs.FS) hackpadfs.FS {
+			store, _ := stores.Load(fs)
+			return newFS(tb, store.(keyvalue.Store))
+		},
+		FileSubtests: []fstest.Subtest{
+			{Name: "durability.Sync", Test: testSyncedContents},
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		ShouldSkip:  skipMapStoreSnapshots,
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+// testSyncedContents verifies synced writes are visible to new readers, while the writer is still open
+func testSyncedContents(tb testing.TB, o fstest.FSOptions) {
+	for description, contents := range map[string]string{
+		"empty":     "",
+		"non-empty": "foo",
+	} {
+		contents := contents
+		o.Run(tb, description, func(tb testing.TB) {
+			_, commit := o.Setup.FS(tb)
+			fs := commit()
+			f, err := hackpadfs.Create(fs, "foo")
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			defer func() { assert.NoError(tb, f.Close()) }()
+			_, err = hackpadfs.WriteFile(f, []byte(contents))
+			assert.NoError(tb, err)
+			assert.NoError(tb, hackpadfs.SyncFile(f))
+
+			data, err := hackpadfs.ReadFile(fs, "foo")
+			assert.NoError(tb, err)
+			assert.Equal(tb, contents, string(data))
+		})
+	}
+}
+
+func storedContents(t *testing.T, store keyvalue.Store, name string) string {
+	t.Helper()
+	record, err := store.Get(context.Background(), name)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	data, err := record.Data()
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	return string(data.Bytes())
+}
+
+func TestDurability(t *testing.T) {
+	t.Parallel()
+
+	t.Run("on sync", func(t *testing.T) {
+		t.Parallel()
+		store := newMapStore()
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{Durability: keyvalue.DurabilityOnSync})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		f, err := hackpadfs.Create(fs, "foo")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = hackpadfs.WriteFile(f, []byte("foo"))
+		assert.NoError(t, err)
+		assert.Equal(t, "", storedContents(t, store, "foo"))
+		contents, err := hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", string(contents))
+
+		assert.NoError(t, hackpadfs.SyncFile(f))
+		assert.Equal(t, "foo", storedContents(t, store, "foo"))
+
+		_, err = hackpadfs.WriteFile(f, []byte("bar"))
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", storedContents(t, store, "foo"))
+		assert.NoError(t, f.Close())
+		assert.Equal(t, "foobar", storedContents(t, store, "foo"))
+	})
+
+	t.Run("sync flag writes through", func(t *testing.T) {
+		t.Parallel()
+		store := newMapStore()
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{Durability: keyvalue.DurabilityOnSync})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		f, err := fs.OpenFile("foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagSync, 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = hackpadfs.WriteFile(f, []byte("foo"))
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", storedContents(t, store, "foo"))
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		t.Parallel()
+		store := newMapStore()
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{
+			Durability:    keyvalue.DurabilityInterval,
+			FlushInterval: 10 * time.Millisecond,
+		})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		f, err := hackpadfs.Create(fs, "foo")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = hackpadfs.WriteFile(f, []byte("foo"))
+		assert.NoError(t, err)
+		assert.Eventually(t, func(ctx context.Context) bool {
+			record, err := store.Get(ctx, "foo")
+			return err == nil && record.Size() == 3
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("flush", func(t *testing.T) {
+		t.Parallel()
+		store := newMapStore()
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{Durability: keyvalue.DurabilityOnSync})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		f, err := hackpadfs.Create(fs, "foo")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = hackpadfs.WriteFile(f, []byte("foo"))
+		assert.NoError(t, err)
+		assert.NoError(t, fs.Flush())
+		assert.Equal(t, "foo", storedContents(t, store, "foo"))
+	})
+}
+
+var errCommit = errors.New("commit failed")
+
+// failingCommitStore fails every read-write transaction commit while 'fail' is set
+type failingCommitStore struct {
+	*mapStore
+	fail int32
+}
+
+func (s *failingCommitStore) Transaction(options keyvalue.TransactionOptions) (keyvalue.Transaction, error) {
+	if options.Mode == keyvalue.TransactionReadWrite && atomic.LoadInt32(&s.fail) != 0 {
+		return failedTransaction{}, nil
+	}
+	return keyvalue.TransactionOrSerial(s.mapStore, options)
+}
+
+type failedTransaction struct{}
+
+func (failedTransaction) Get(string) keyvalue.OpID { return 0 }
+func (failedTransaction) GetHandler(string, keyvalue.OpHandler) keyvalue.OpID {
+	return 0
+}
+func (failedTransaction) Set(string, keyvalue.FileRecord, blob.Blob) keyvalue.OpID { return 0 }
+func (failedTransaction) SetHandler(string, keyvalue.FileRecord, blob.Blob, keyvalue.OpHandler) keyvalue.OpID {
+	return 0
+}
+func (failedTransaction) Commit(context.Context) ([]keyvalue.OpResult, error) { return nil, errCommit }
+func (failedTransaction) Abort() error                                        { return nil }
+
+func TestDurabilityFailedCommit(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		options     keyvalue.FSOptions
+		flush       func(t *testing.T, fs *keyvalue.FS, f hackpadfs.File)
+	}{
+		{
+			description: "on sync",
+			options:     keyvalue.FSOptions{Durability: keyvalue.DurabilityOnSync},
+			flush: func(t *testing.T, fs *keyvalue.FS, f hackpadfs.File) {
+				t.Helper()
+				assert.ErrorIs(t, errCommit, hackpadfs.SyncFile(f))
+			},
+		},
+		{
+			description: "interval",
+			options: keyvalue.FSOptions{
+				Durability:    keyvalue.DurabilityInterval,
+				FlushInterval: time.Millisecond,
+			},
+			flush: func(t *testing.T, fs *keyvalue.FS, f hackpadfs.File) {
+				t.Helper()
+				time.Sleep(10 * time.Millisecond) // let the flush timer fail
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			store := &failingCommitStore{mapStore: newMapStore()}
+			fs, err := keyvalue.NewFSWithOptions(store, tc.options)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			f, err := hackpadfs.Create(fs, "foo")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			atomic.StoreInt32(&store.fail, 1)
+			_, err = hackpadfs.WriteFile(f, []byte("foo"))
+			assert.NoError(t, err)
+			tc.flush(t, fs, f)
+
+			// failed writes remain pending: still visible, and retried on the next flush
+			contents, err := hackpadfs.ReadFile(fs, "foo")
+			assert.NoError(t, err)
+			assert.Equal(t, "foo", string(contents))
+			assert.Equal(t, "", storedContents(t, store, "foo"))
+
+			atomic.StoreInt32(&store.fail, 0)
+			assert.NoError(t, fs.Flush())
+			assert.Equal(t, "foo", storedContents(t, store, "foo"))
+			assert.NoError(t, f.Close())
+		})
+	}
+}
+
+type durableTxnStore struct {
+	*mapStore
+	durableTxns int64
+}
+
+func (s *durableTxnStore) Transaction(options keyvalue.TransactionOptions) (keyvalue.Transaction, error) {
+	if options.Durable {
+		atomic.AddInt64(&s.durableTxns, 1)
+	}
+	return keyvalue.TransactionOrSerial(s.mapStore, options)
+}
+
+func TestFlagSyncDurableTransactions(t *testing.T) {
+	t.Parallel()
+	store := &durableTxnStore{mapStore: newMapStore()}
+	fs, err := keyvalue.NewFS(store)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&store.durableTxns))
+
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagSync, 0)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = hackpadfs.WriteFile(f, []byte("bar"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.Equal(t, int64(1), atomic.LoadInt64(&store.durableTxns))
+}
@@ -0,0 +1,126 @@
+package keyvalue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ExpiringStore is a Store which can expire files at a point in time, after which they no longer exist.
+type ExpiringStore interface {
+	Store
+	// SetExpiration sets the time 'path' expires. A zero 'expiresAt' removes the expiration. Returns ErrNotExist if 'path' does not exist.
+	// Records returned from Get() must implement ExpiringFileRecord, and Set() must preserve an existing record's expiration.
+	SetExpiration(ctx context.Context, path string, expiresAt time.Time) error
+}
+
+// ExpiringFileRecord is a FileRecord which may expire
+type ExpiringFileRecord interface {
+	FileRecord
+	// ExpiresAt returns the time this file expires, or the zero time if it never expires.
+	ExpiresAt() time.Time
+}
+
+// runOnceExpiringFileRecord is a runOnceFileRecord which preserves ExpiringFileRecord
+type runOnceExpiringFileRecord struct {
+	*runOnceFileRecord
+	expiringRecord ExpiringFileRecord
+
+	expiresAt     time.Time
+	expiresAtOnce sync.Once
+}
+
+func (r *runOnceExpiringFileRecord) ExpiresAt() time.Time {
+	r.expiresAtOnce.Do(func() {
+		r.expiresAt = r.expiringRecord.ExpiresAt()
+	})
+	return r.expiresAt
+}
+
+// errExpired is returned in place of a file record which has expired, but may not have been purged yet
+var errExpired = fmt.Errorf("%w: expired", hackpadfs.ErrNotExist)
+
+func isExpired(record FileRecord, now time.Time) bool {
+	expiringRecord, ok := record.(ExpiringFileRecord)
+	if !ok {
+		return false
+	}
+	expiresAt := expiringRecord.ExpiresAt()
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+// purgeExpired removes the expired file at 'path'.
+// Purging is best-effort, since the file is already treated as missing. A failed purge is retried on the next access.
+func (fs *FS) purgeExpired(path string) {
+	_ = fs.setFile(path, nil)
+}
+
+// SetExpiration sets the time file 'name' expires, after which it no longer exists. A zero 'expiresAt' removes the expiration.
+// Expired files are purged lazily when next accessed, or all at once with PurgeExpired(). Directories can not expire.
+//
+// Requires the FS's Store to implement ExpiringStore, fails with a not implemented error otherwise.
+func (fs *FS) SetExpiration(name string, expiresAt time.Time) error {
+	expiringStore, ok := storeAs[ExpiringStore](fs.store.store)
+	if !ok {
+		return &hackpadfs.PathError{Op: "setexpiration", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	filePath, file, err := fs.resolve(name, true)
+	if err != nil {
+		return fs.wrapperErr("setexpiration", name, err)
+	}
+	if file.Mode().IsDir() {
+		return fs.wrapperErr("setexpiration", name, hackpadfs.ErrIsDir)
+	}
+	return fs.wrapperErr("setexpiration", name, expiringStore.SetExpiration(fs.store.ctx, filePath, expiresAt))
+}
+
+// PurgeExpired walks the file system from the root directory and removes all expired files.
+// Returns the removed paths, sorted by path.
+//
+// Expired files are already hidden from all FS operations, so purging only reclaims their storage.
+// Long-lived FS's with many short-lived files should call this periodically.
+func (fs *FS) PurgeExpired(ctx context.Context) ([]string, error) {
+	root, err := fs.getFile(".")
+	if err != nil {
+		return nil, fs.wrapperErr("purgeexpired", ".", err)
+	}
+	var purged []string
+	err = fs.purgeExpiredDir(ctx, ".", root, &purged)
+	sort.Strings(purged)
+	return purged, err
+}
+
+func (fs *FS) purgeExpiredDir(ctx context.Context, name string, dir *file, purged *[]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dirNames, err := dir.ReadDirNames()
+	if err != nil {
+		return fs.wrapperErr("purgeexpired", name, err)
+	}
+	paths := make([]string, len(dirNames))
+	for i, dirName := range dirNames {
+		paths[i] = path.Join(name, dirName)
+	}
+	files, errs := fs.getFiles(paths...) // expired files are purged by getFiles
+	for i, childPath := range paths {
+		switch {
+		case errors.Is(errs[i], errExpired):
+			*purged = append(*purged, childPath)
+		case errors.Is(errs[i], hackpadfs.ErrNotExist):
+		case errs[i] != nil:
+			return fs.wrapperErr("purgeexpired", childPath, errs[i])
+		case files[i].Mode().IsDir():
+			if err := fs.purgeExpiredDir(ctx, childPath, files[i], purged); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
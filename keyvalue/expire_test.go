@@ -0,0 +1,163 @@
+package keyvalue_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+type mapExpiringStore struct {
+	*mapStore
+	expirations map[string]time.Time
+}
+
+type expiringRecord struct {
+	keyvalue.FileRecord
+	expiresAt time.Time
+}
+
+func (r expiringRecord) ExpiresAt() time.Time { return r.expiresAt }
+
+func newMapExpiringStore() *mapExpiringStore {
+	return &mapExpiringStore{
+		mapStore:    newMapStore(),
+		expirations: make(map[string]time.Time),
+	}
+}
+
+func (s *mapExpiringStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	record, err := s.mapStore.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return expiringRecord{FileRecord: record, expiresAt: s.expirations[path]}, nil
+}
+
+func (s *mapExpiringStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	if src == nil {
+		s.mu.Lock()
+		delete(s.expirations, path)
+		s.mu.Unlock()
+	}
+	return s.mapStore.Set(ctx, path, src)
+}
+
+func (s *mapExpiringStore) SetExpiration(ctx context.Context, path string, expiresAt time.Time) error {
+	if _, err := s.mapStore.Get(ctx, path); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expirations[path] = expiresAt
+	return nil
+}
+
+func TestExpiration(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		newStore    func(*mapExpiringStore) (keyvalue.Store, error)
+	}{
+		{
+			description: "store",
+			newStore: func(store *mapExpiringStore) (keyvalue.Store, error) {
+				return store, nil
+			},
+		},
+		{
+			description: "observed store",
+			newStore: func(store *mapExpiringStore) (keyvalue.Store, error) {
+				return keyvalue.NewObservedStore(store, func(keyvalue.OpEvent) {}), nil
+			},
+		},
+		{
+			description: "cached store",
+			newStore: func(store *mapExpiringStore) (keyvalue.Store, error) {
+				return keyvalue.NewCacheStore(store, 10)
+			},
+		},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			store := newMapExpiringStore()
+			fsStore, err := tc.newStore(store)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			fs, err := keyvalue.NewFS(fsStore)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.NoError(t, fs.Mkdir("foo", 0700))
+			for _, name := range []string{"foo/expired", "foo/later", "foo/never"} {
+				assert.NoError(t, hackpadfs.WriteFullFile(fs, name, []byte(name), 0600))
+			}
+			assert.NoError(t, fs.SetExpiration("foo/expired", time.Now().Add(-time.Second)))
+			assert.NoError(t, fs.SetExpiration("foo/later", time.Now().Add(time.Hour)))
+			assert.ErrorIs(t, hackpadfs.ErrIsDir, fs.SetExpiration("foo", time.Now()))
+
+			_, err = fs.Stat("foo/expired")
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+			_, err = store.mapStore.Get(context.Background(), "foo/expired")
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+			entries, err := hackpadfs.ReadDir(fs, "foo")
+			assert.NoError(t, err)
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			assert.Equal(t, []string{"later", "never"}, names)
+
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/expired", []byte("new"), 0600))
+			contents, err := hackpadfs.ReadFile(fs, "foo/expired")
+			assert.NoError(t, err)
+			assert.Equal(t, "new", string(contents))
+		})
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	t.Parallel()
+	store := newMapExpiringStore()
+	fs, err := keyvalue.NewFS(store)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+	for _, name := range []string{"a", "foo/b", "foo/bar/c", "foo/bar/d"} {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, name, nil, 0600))
+	}
+	for _, name := range []string{"a", "foo/bar/c"} {
+		assert.NoError(t, fs.SetExpiration(name, time.Now().Add(-time.Second)))
+	}
+
+	purged, err := fs.PurgeExpired(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "foo/bar/c"}, purged)
+	for _, name := range purged {
+		_, err := store.mapStore.Get(context.Background(), name)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	}
+
+	purged, err = fs.PurgeExpired(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string(nil), purged)
+}
+
+func TestSetExpirationNotImplemented(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(newMapStore())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+	assert.ErrorIs(t, hackpadfs.ErrNotImplemented, fs.SetExpiration("foo", time.Now()))
+}
@@ -0,0 +1,6 @@
+package keyvalue
+
+// SetLockClock replaces the time source for locks taken with 'fs', so tests can control lease expiry
+func SetLockClock(fs *FS, c clock) {
+	fs.lockClock = c
+}
@@ -1,9 +1,11 @@
 package keyvalue
 
 import (
-	"context"
+	"crypto/sha256"
+	"errors"
 	"io"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
@@ -22,18 +24,23 @@ var (
 		hackpadfs.DirReaderFile
 		hackpadfs.ReadWriterFile
 		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
 		hackpadfs.TruncaterFile
 	} = &file{}
 )
 
 type file struct {
 	*fileData
+	name   string // name used to open this file, which differs from fileData.path if opened through a symlink
 	offset int64
 	flag   int
+
+	dirNames []string // nil until first ReadDir, so entries added or removed later don't shift the offset
 }
 
 type fileData struct {
 	runOnceFileRecord
+	overridesMu     sync.Mutex // unflushed fileData is shared by every open file for its path, see flusher
 	modeOverride    *hackpadfs.FileMode
 	modTimeOverride time.Time
 
@@ -42,66 +49,104 @@ type fileData struct {
 }
 
 func (f *fileData) Mode() hackpadfs.FileMode {
-	if f.modeOverride != nil {
-		return *f.modeOverride
+	f.overridesMu.Lock()
+	modeOverride := f.modeOverride
+	f.overridesMu.Unlock()
+	if modeOverride != nil {
+		return *modeOverride
 	}
 	return f.runOnceFileRecord.Mode()
 }
 
 func (f *fileData) ModTime() time.Time {
 	var zero time.Time
-	if f.modTimeOverride != zero {
-		return f.modTimeOverride
+	f.overridesMu.Lock()
+	modTimeOverride := f.modTimeOverride
+	f.overridesMu.Unlock()
+	if modTimeOverride != zero {
+		return modTimeOverride
 	}
 	return f.runOnceFileRecord.ModTime()
 }
 
+func (f *fileData) setMode(mode hackpadfs.FileMode) {
+	f.overridesMu.Lock()
+	f.modeOverride = &mode
+	f.overridesMu.Unlock()
+}
+
+func (f *fileData) setModTime(modTime time.Time) {
+	f.overridesMu.Lock()
+	f.modTimeOverride = modTime
+	f.overridesMu.Unlock()
+}
+
 // getFile returns a file for 'path' if it exists, os.ErrNotExist otherwise
 func (fs *FS) getFile(path string) (*file, error) {
 	if !hackpadfs.ValidPath(path) {
 		return nil, hackpadfs.ErrInvalid
 	}
+	if fs.flusher != nil {
+		if data, ok := fs.flusher.get(path); ok {
+			return &file{fileData: data}, nil
+		}
+	}
 	f := fileData{
 		path: path,
 		fs:   fs,
 	}
-	txn, err := fs.store.Transaction(TransactionOptions{
-		Mode: TransactionReadOnly,
-	})
-	if err != nil {
-		return nil, err
-	}
-	txn.Get(path)
-	results, err := txn.Commit(context.Background())
+	results, err := getFileRecords(fs.store, []string{path})
 	if err != nil {
 		return nil, err
 	}
 	f.runOnceFileRecord.record, err = results[0].Record, results[0].Err
+	if errors.Is(err, errExpired) {
+		fs.purgeExpired(path)
+	}
 	return &file{fileData: &f}, err
 }
 
 // setFile write the 'file' data to the store at 'path'. If 'file' is nil, the file is deleted.
 func (fs *FS) setFile(path string, file FileRecord) error {
+	return fs.setFileWithOptions(path, file, TransactionOptions{Mode: TransactionReadWrite})
+}
+
+func (fs *FS) setFileWithOptions(path string, file FileRecord, options TransactionOptions) error {
+	version := fs.flusher.version(path)
 	var contents blob.Blob
-	if file != nil && file.Mode().IsRegular() {
+	if file != nil && !file.Mode().IsDir() {
 		var err error
 		contents, err = file.Data()
 		if err != nil {
 			return err
 		}
 	}
-	txn, err := fs.store.Transaction(TransactionOptions{
-		Mode: TransactionReadWrite,
-	})
+	txn, err := fs.store.Transaction(options)
 	if err == nil {
 		err = fs.setFileTxn(txn, path, file, contents)
 	}
 	if err == nil {
-		_, err = txn.Commit(context.Background())
+		err = firstOpErr(txn.Commit(fs.store.ctx))
+	}
+	if err == nil {
+		fs.flusher.forget(path, version)
 	}
 	return err
 }
 
+// firstOpErr returns the Commit() error or, if the commit succeeded, the first failed operation's error
+func firstOpErr(ops []OpResult, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.Err != nil {
+			return op.Err
+		}
+	}
+	return nil
+}
+
 func (fs *FS) setFileTxn(txn Transaction, path string, file FileRecord, contents blob.Blob) error {
 	if !hackpadfs.ValidPath(path) {
 		return hackpadfs.ErrInvalid
@@ -109,7 +154,6 @@ func (fs *FS) setFileTxn(txn Transaction, path string, file FileRecord, contents
 	if contents == nil && file != nil && file.Mode().IsRegular() {
 		panic("Contents must not be nil for regular file")
 	}
-
 	txn.Set(path, file, contents)
 	return nil
 }
@@ -144,6 +188,10 @@ func (f fileInfo) Sys() interface{} {
 }
 
 func (fs *FS) newFile(path string, flag int, mode hackpadfs.FileMode) *file {
+	newBlob := func() blob.Blob { return blob.NewBytes(nil) }
+	if blobStore, ok := storeAs[BlobStore](fs.store.store); ok {
+		newBlob = blobStore.NewBlob
+	}
 	return &file{
 		flag: flag,
 		fileData: &fileData{
@@ -152,7 +200,7 @@ func (fs *FS) newFile(path string, flag int, mode hackpadfs.FileMode) *file {
 			runOnceFileRecord: runOnceFileRecord{
 				record: NewBaseFileRecord(0, time.Now(), mode, nil,
 					func() (blob.Blob, error) {
-						return blob.NewBytes(nil), nil
+						return newBlob(), nil
 					},
 					nil,
 				),
@@ -173,12 +221,16 @@ func (f *file) Close() error {
 	if f.fileData == nil {
 		return hackpadfs.ErrClosed
 	}
+	var err error
+	if f.fs.flusher != nil {
+		err = f.fs.wrapperErr("close", f.path, f.fs.flusher.flush(f.fileData))
+	}
 	f.fileData = nil
-	return nil
+	return err
 }
 
 func (f *file) updateModTime() {
-	f.modTimeOverride = time.Now()
+	f.setModTime(time.Now())
 }
 
 func (f *file) Read(p []byte) (n int, err error) {
@@ -194,9 +246,22 @@ func (f *file) ReadBlob(length int) (blob blob.Blob, n int, err error) {
 }
 
 func (f *file) ReadAt(p []byte, off int64) (n int, err error) {
-	blob, n, err := f.ReadBlobAt(len(p), off)
-	if blob != nil {
-		copy(p, blob.Bytes())
+	if off >= int64(f.Size()) {
+		return 0, io.EOF
+	}
+	max := int64(f.Size())
+	end := off + int64(len(p))
+	if end > max {
+		end = max
+	}
+	data, err := f.Data()
+	if err != nil {
+		return 0, err
+	}
+	// read directly into 'p', rather than copying a View's Bytes()
+	n, err = blob.NewReaderAt(data).ReadAt(p[:end-off], off)
+	if err == nil && end == max {
+		err = io.EOF
 	}
 	return n, err
 }
@@ -245,7 +310,8 @@ func (f *file) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (f *file) Write(p []byte) (n int, err error) {
-	n, err = f.WriteBlob(blob.NewBytes(p))
+	n, err = f.writeBytesAt("write", p, f.offset)
+	f.offset += int64(n)
 	return
 }
 
@@ -256,20 +322,35 @@ func (f *file) WriteBlob(p blob.Blob) (n int, err error) {
 }
 
 func (f *file) WriteAt(p []byte, off int64) (n int, err error) {
-	return f.WriteBlobAt(blob.NewBytes(p), off)
+	return f.writeBytesAt("writeat", p, off)
 }
 
 func (f *file) WriteBlobAt(p blob.Blob, off int64) (n int, err error) {
 	return f.writeBlobAt("writeat", p, off)
 }
 
+// writeBytesAt writes 'p' directly into the file's data, rather than wrapping it in a Blob which Set() would copy again
+func (f *file) writeBytesAt(op string, p []byte, off int64) (n int, err error) {
+	return f.write(op, len(p), off, func(data blob.Blob, off int64) (int, error) {
+		return blob.NewWriterAt(data).WriteAt(p, off)
+	})
+}
+
 func (f *file) writeBlobAt(op string, p blob.Blob, off int64) (n int, err error) {
+	return f.write(op, p.Len(), off, func(data blob.Blob, off int64) (int, error) {
+		return blob.Set(data, p, off)
+	})
+}
+
+// write grows the file's data to fit 'length' bytes at 'off', then runs 'set' to copy them in
+func (f *file) write(op string, length int, off int64, set func(data blob.Blob, off int64) (int, error)) (n int, err error) {
 	if f.flag&hackpadfs.FlagAppend != 0 {
 		off = int64(f.Size())
 	}
 
-	endIndex := off + int64(p.Len())
-	if int64(f.Size()) < endIndex {
+	endIndex := off + int64(length)
+	grows := int64(f.Size()) < endIndex
+	if grows {
 		data, err := f.Data()
 		if err != nil {
 			return 0, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
@@ -283,19 +364,40 @@ func (f *file) writeBlobAt(op string, p blob.Blob, off int64) (n int, err error)
 	if err != nil {
 		return 0, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
 	}
-	n, err = blob.Set(data, p, off)
+	var before [sha256.Size]byte
+	checkUnchanged := f.fs.skipUnchangedWrites && !grows
+	if checkUnchanged {
+		before, err = blob.Digest(data)
+		if err != nil {
+			return 0, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
+		}
+	}
+	n, err = set(data, off)
 	if err != nil {
 		return n, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
 	}
+	if checkUnchanged {
+		after, err := blob.Digest(data)
+		if err != nil {
+			return n, &hackpadfs.PathError{Op: op, Path: f.path, Err: err}
+		}
+		if after == before {
+			return n, nil
+		}
+	}
 	if n != 0 {
 		f.updateModTime()
 	}
-	err = f.save()
+	err = f.saveContents()
 	return
 }
 
 func (f *file) Stat() (hackpadfs.FileInfo, error) {
-	return fileInfo{Record: &f.runOnceFileRecord, Path: f.path}, nil
+	name := f.name
+	if name == "" {
+		name = f.path
+	}
+	return fileInfo{Record: &f.runOnceFileRecord, Path: name}, nil
 }
 
 func (f *file) Truncate(size int64) error {
@@ -328,29 +430,53 @@ func (f *file) Truncate(size int64) error {
 		}
 	}
 	f.updateModTime()
-	return f.save()
+	return f.saveContents()
 }
 
 func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
-	dirNames, err := f.ReadDirNames()
-	if err != nil {
-		return nil, &hackpadfs.PathError{Op: "readdir", Path: f.path, Err: err}
+	if f.dirNames == nil {
+		dirNames, err := f.ReadDirNames()
+		if err != nil {
+			return nil, &hackpadfs.PathError{Op: "readdir", Path: f.path, Err: err}
+		}
+		f.dirNames = append(make([]string, 0, len(dirNames)), dirNames...)
+	}
+	start := f.offset
+	if start > int64(len(f.dirNames)) {
+		start = int64(len(f.dirNames))
 	}
-	start, end := f.offset, f.offset+int64(n)
-	if n <= 0 {
-		start, end = 0, int64(len(dirNames))
-	} else if end > int64(len(dirNames)) {
-		end = int64(len(dirNames))
+	end := int64(len(f.dirNames))
+	if n > 0 {
+		if start == end {
+			return nil, io.EOF
+		}
+		if start+int64(n) < end {
+			end = start + int64(n)
+		}
 	}
 	offsetAdd := end - start
 
-	var entries []hackpadfs.DirEntry
-	for _, name := range dirNames[start:end] {
-		entry, err := newDirEntry(f.fs, f.path, name)
-		if err != nil {
-			return nil, err
+	names := f.dirNames[start:end]
+	if len(names) == 0 {
+		return nil, nil
+	}
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = path.Join(f.path, name)
+	}
+	files, errs := f.fs.getFiles(paths...) // fetch all entries in one batch
+	entries := make([]hackpadfs.DirEntry, 0, len(names))
+	for i, name := range names {
+		if errors.Is(errs[i], errExpired) || errors.Is(errs[i], hackpadfs.ErrNotExist) { // expired or removed since the first ReadDir
+			continue
+		}
+		if errs[i] != nil {
+			return nil, &hackpadfs.PathError{Op: "lstat", Path: paths[i], Err: errs[i]}
 		}
-		entries = append(entries, entry)
+		entries = append(entries, &dirEntry{
+			baseName: name,
+			info:     fileInfo{Record: files[i].fileData, Path: paths[i]},
+		})
 	}
 	f.offset += offsetAdd
 	return entries, nil
@@ -361,14 +487,6 @@ type dirEntry struct {
 	info     hackpadfs.FileInfo
 }
 
-func newDirEntry(fs hackpadfs.FS, basePath, name string) (*dirEntry, error) {
-	info, err := hackpadfs.Stat(fs, path.Join(basePath, name))
-	return &dirEntry{
-		baseName: name,
-		info:     info,
-	}, err
-}
-
 func (d *dirEntry) Name() string {
 	return d.baseName
 }
@@ -386,7 +504,6 @@ func (d *dirEntry) Info() (hackpadfs.FileInfo, error) {
 }
 
 func (f *file) Chmod(mode hackpadfs.FileMode) error {
-	newMode := (f.Mode() & ^chmodBits) | (mode & chmodBits)
-	f.modeOverride = &newMode
+	f.setMode((f.Mode() & ^chmodBits) | (mode & chmodBits))
 	return f.save()
 }
@@ -45,6 +45,10 @@ func (r *readOnlyFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
 	return r.file.ReadDir(n)
 }
 
+func (r *readOnlyFile) Sync() error {
+	return r.file.Sync()
+}
+
 func (r *readOnlyFile) Chmod(mode hackpadfs.FileMode) error {
 	return r.file.Chmod(mode)
 }
@@ -90,6 +94,10 @@ func (w *writeOnlyFile) Truncate(size int64) error {
 	return w.file.Truncate(size)
 }
 
+func (w *writeOnlyFile) Sync() error {
+	return w.file.Sync()
+}
+
 func (w *writeOnlyFile) Chmod(mode hackpadfs.FileMode) error {
 	return w.file.Chmod(mode)
 }
@@ -5,6 +5,8 @@ import (
 	"context"
 	"errors"
 	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
@@ -14,16 +16,89 @@ const chmodBits = hackpadfs.ModePerm | hackpadfs.ModeSetuid | hackpadfs.ModeSetg
 
 // FS wraps a Store as a file system.
 type FS struct {
-	store *transactionOnly
+	store     *transactionOnly
+	lockLease time.Duration
+	lockClock clock
+	journal   JournalStore
+	flusher   *flusher
+	xattrMu   *sync.Mutex
+
+	skipUnchangedWrites bool
+}
+
+// FSOptions contain optional settings for a new FS
+type FSOptions struct {
+	// Migrations upgrade the store's records to the latest schema version before the FS is returned.
+	// Requires the store to implement SchemaStore.
+	Migrations *MigrationRegistry
+	// LockLease is how long a Lock() is held without renewal, after which it expires. Defaults to 30 seconds.
+	// Held locks are renewed automatically, so expiry only occurs if the owner stops, e.g. a closed browser tab.
+	LockLease time.Duration
+	// Journal records the intent of multi-key operations, like Rename() and RemoveAll(), before running them.
+	// Any operations interrupted by a crash are completed when the store is next opened with Journal set.
+	// Requires the store to implement JournalStore.
+	Journal bool
+	// Durability controls when file content writes are flushed to the store. Defaults to DurabilityEveryOp.
+	// Other modes trade durability for throughput: unflushed writes are lost if the process stops.
+	// Metadata changes, like Mkdir() and Chmod(), are always written immediately.
+	Durability DurabilityMode
+	// FlushInterval is the maximum delay before flushing writes with DurabilityInterval. Defaults to 100 milliseconds.
+	FlushInterval time.Duration
+	// SkipUnchangedWrites compares content digests before and after each file write, and skips writing to the store if the contents are unchanged.
+	// Skipped writes also leave the modified time unchanged. Only worthwhile for stores where writes cost more than hashing, like remote stores.
+	SkipUnchangedWrites bool
 }
 
 // NewFS returns a new FS wrapping the given 'store'.
 func NewFS(store Store) (*FS, error) {
+	return NewFSWithOptions(store, FSOptions{})
+}
+
+// NewFSWithOptions returns a new FS wrapping the given 'store' and configured with 'options'.
+func NewFSWithOptions(store Store, options FSOptions) (*FS, error) {
+	if options.Migrations != nil {
+		schemaStore, ok := storeAs[SchemaStore](store)
+		if !ok {
+			return nil, &hackpadfs.PathError{Op: "migrate", Path: ".", Err: hackpadfs.ErrNotImplemented}
+		}
+		if err := options.Migrations.Migrate(context.Background(), schemaStore); err != nil {
+			return nil, err
+		}
+	}
+	if options.LockLease <= 0 {
+		options.LockLease = defaultLockLease
+	}
 	fs := &FS{
-		store: newFSTransactioner(store),
+		store:     newFSTransactioner(context.Background(), store),
+		lockLease: options.LockLease,
+		lockClock: systemClock{},
+		xattrMu:   new(sync.Mutex),
+
+		skipUnchangedWrites: options.SkipUnchangedWrites,
+	}
+	if options.Durability != DurabilityEveryOp {
+		fs.flusher = newFlusher(options.Durability, options.FlushInterval)
+	}
+	if options.Journal {
+		journalStore, ok := storeAs[JournalStore](store)
+		if !ok {
+			return nil, &hackpadfs.PathError{Op: "journal", Path: ".", Err: hackpadfs.ErrNotImplemented}
+		}
+		fs.journal = journalStore
+	}
+	err := ignoreErrExist(fs.Mkdir(".", 0666))
+	if err == nil && fs.journal != nil {
+		err = fs.replayJournal()
 	}
-	err := fs.Mkdir(".", 0666)
-	return fs, ignoreErrExist(err)
+	return fs, err
+}
+
+// WithContext returns a shallow copy of fs which runs all store operations with 'ctx', including operations on files it opens.
+// Once 'ctx' is canceled, pending and future operations fail with the context's error.
+func (fs *FS) WithContext(ctx context.Context) *FS {
+	fsCopy := *fs
+	fsCopy.store = newFSTransactioner(ctx, fs.store.store)
+	return &fsCopy
 }
 
 func ignoreErrExist(err error) error {
@@ -42,20 +117,23 @@ func (fs *FS) wrapperErr(op string, path string, err error) error {
 
 // Mkdir implements hackpadfs.MkdirFS
 func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
-	file := fs.newDir(name, perm)
-	_, err := fs.Stat(name)
+	if !hackpadfs.ValidPath(name) {
+		return fs.wrapperErr("mkdir", name, hackpadfs.ErrInvalid)
+	}
+	dirPath, _, err := fs.resolve(name, false)
 	switch {
 	case err == nil:
 		return fs.wrapperErr("mkdir", name, hackpadfs.ErrExist)
 	case !errors.Is(err, hackpadfs.ErrNotExist):
-		return err
+		return fs.wrapperErr("mkdir", name, err)
 	}
-	if name != "." {
-		_, err := fs.Stat(path.Dir(name))
+	if dirPath != "." {
+		_, err := fs.Stat(path.Dir(dirPath))
 		if err != nil {
 			return fs.wrapperErr("mkdir", name, err)
 		}
 	}
+	file := fs.newDir(dirPath, perm)
 	return fs.wrapperErr("mkdir", name, file.save())
 }
 
@@ -65,7 +143,19 @@ func (fs *FS) newDir(name string, perm hackpadfs.FileMode) *file {
 
 // MkdirAll implements hackpadfs.MkdirAllFS
 func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
-	missingDirs, err := fs.findMissingDirs(path)
+	if !hackpadfs.ValidPath(path) {
+		return fs.wrapperErr("mkdirall", path, hackpadfs.ErrInvalid)
+	}
+	dirPath, file, err := fs.resolve(path, true)
+	switch {
+	case err == nil && file.Mode().IsDir():
+		return nil
+	case err == nil:
+		return fs.wrapperErr("mkdir", path, hackpadfs.ErrNotDir)
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return fs.wrapperErr("mkdirall", path, err)
+	}
+	missingDirs, err := fs.findMissingDirs(dirPath)
 	if err != nil {
 		return err
 	}
@@ -113,7 +203,16 @@ func (fs *FS) getFiles(paths ...string) ([]*file, []error) {
 		return files, errs
 	}
 	for i := range paths {
+		if fs.flusher != nil {
+			if data, ok := fs.flusher.get(paths[i]); ok {
+				files[i], errs[i] = &file{fileData: data}, nil
+				continue
+			}
+		}
 		result, err := results[i].Record, results[i].Err
+		if errors.Is(err, errExpired) {
+			fs.purgeExpired(paths[i])
+		}
 		files[i], errs[i] = &file{
 			fileData: &fileData{
 				runOnceFileRecord: runOnceFileRecord{record: result},
@@ -125,17 +224,35 @@ func (fs *FS) getFiles(paths ...string) ([]*file, []error) {
 	return files, errs
 }
 
+// getFileRecords fetches the records for 'paths'. Expired records are returned as errExpired.
 func getFileRecords(store *transactionOnly, paths []string) ([]OpResult, error) {
-	txn, err := store.Transaction(TransactionOptions{
-		Mode: TransactionReadOnly,
-	})
+	var results []OpResult
+	var err error
+	if multiStore, ok := storeAs[MultiGetStore](store.store); ok {
+		results, err = multiStore.GetMany(store.ctx, paths)
+	} else {
+		var txn Transaction
+		txn, err = store.Transaction(TransactionOptions{
+			Mode: TransactionReadOnly,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			txn.Get(path)
+		}
+		results, err = txn.Commit(store.ctx)
+	}
 	if err != nil {
 		return nil, err
 	}
-	for _, path := range paths {
-		txn.Get(path)
+	now := time.Now()
+	for i := range results {
+		if results[i].Err == nil && isExpired(results[i].Record, now) {
+			results[i].Record, results[i].Err = nil, errExpired
+		}
 	}
-	return txn.Commit(context.Background())
+	return results, nil
 }
 
 // findMissingDirs returns all paths that must be created, in reverse order
@@ -190,11 +307,26 @@ func (fs *FS) Open(name string) (hackpadfs.File, error) {
 
 // OpenFile implements hackpadfs.OpenFileFS
 func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (afFile hackpadfs.File, retErr error) {
-	paths := []string{name}
+	filePath := name
+	paths := []string{filePath}
 	if flag&hackpadfs.FlagCreate != 0 {
-		paths = append(paths, path.Dir(name))
+		paths = append(paths, path.Dir(filePath))
 	}
 	files, errs := fs.getFiles(paths...)
+	if fs.mayNeedResolve(files[0], errs[0], files, errs) {
+		resolvedPath, _, err := fs.resolve(name, true)
+		if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+			return nil, fs.wrapperErr("open", name, err)
+		}
+		if resolvedPath != filePath {
+			filePath = resolvedPath
+			paths[0] = filePath
+			if len(paths) > 1 {
+				paths[1] = path.Dir(filePath)
+			}
+			files, errs = fs.getFiles(paths...)
+		}
+	}
 	storeFile, err := files[0], errs[0]
 	switch {
 	case err == nil:
@@ -209,7 +341,7 @@ func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (afFile h
 		if err != nil {
 			return nil, fs.wrapperErr("open", name, err)
 		}
-		storeFile = fs.newFile(name, flag, perm&hackpadfs.ModePerm)
+		storeFile = fs.newFile(filePath, flag, perm&hackpadfs.ModePerm)
 		if err := storeFile.save(); err != nil {
 			return nil, fs.wrapperErr("open", name, err)
 		}
@@ -217,6 +349,7 @@ func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (afFile h
 		return nil, fs.wrapperErr("open", name, err)
 	}
 
+	storeFile.name = name
 	var file hackpadfs.File = storeFile
 	switch {
 	case flag&hackpadfs.FlagWriteOnly != 0:
@@ -233,9 +366,24 @@ func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (afFile h
 	return file, nil
 }
 
+// mayNeedResolve returns true if opening the first of 'files' might require following a symlink
+func (fs *FS) mayNeedResolve(file *file, err error, files []*file, errs []error) bool {
+	switch {
+	case err == nil:
+		return isSymlink(file.Mode())
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return false
+	case len(files) > 1 && errs[1] == nil:
+		// parent directory exists and is not a symlink, so 'file' is missing
+		return !files[1].Mode().IsDir()
+	default:
+		return true
+	}
+}
+
 // Remove implements hackpadfs.RemoveFS
 func (fs *FS) Remove(name string) error {
-	file, err := fs.getFile(name)
+	name, file, err := fs.resolve(name, false)
 	if err != nil {
 		return fs.wrapperErr("remove", name, err)
 	}
@@ -254,87 +402,186 @@ func (fs *FS) Remove(name string) error {
 
 // Rename implements hackpadfs.RenameFS
 func (fs *FS) Rename(oldname, newname string) error {
-	oldFile, err := fs.getFile(oldname)
+	oldname, oldFile, err := fs.resolve(oldname, false)
 	if err != nil {
 		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotExist}
 	}
+	newname, newFile, err := fs.resolve(newname, false)
+	switch {
+	case err == nil:
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	case newname != ".":
+		parent, err := fs.getFile(path.Dir(newname))
+		switch {
+		case err != nil:
+			return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		case !parent.Mode().IsDir():
+			return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotDir}
+		}
+	}
 	oldInfo, err := oldFile.Stat()
 	if err != nil {
 		return err
 	}
+	entry := journalEntry{Op: journalOpRename, Path: oldname, NewPath: newname}
+	if oldname == newname && !oldInfo.IsDir() {
+		return nil
+	}
+	if newFile != nil && newFile.Mode().IsDir() {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrExist}
+	}
 	if !oldInfo.IsDir() {
-		if oldname == newname {
-			return nil
-		}
-		contents, err := oldFile.fileData.Data()
-		if err != nil {
-			return err
-		}
-		txn, err := fs.store.Transaction(TransactionOptions{Mode: TransactionReadWrite})
-		if err == nil {
-			err = fs.setFileTxn(txn, newname, oldFile.fileData, contents)
-		}
-		if err == nil {
-			err = fs.setFileTxn(txn, oldname, nil, nil)
-		}
-		if err != nil {
-			_ = txn.Abort()
-		} else {
-			_, err = txn.Commit(context.Background())
-		}
-		return err
+		return fs.journaled(entry, func() error {
+			return fs.renameFile(oldFile, oldname, newname)
+		})
 	}
 
-	_, err = fs.getFile(newname)
-	if !errors.Is(err, hackpadfs.ErrNotExist) {
-		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrExist}
+	switch {
+	case newFile != nil:
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotDir}
+	case strings.HasPrefix(newname, oldname+"/") || oldname == ".":
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	return fs.journaled(entry, func() error {
+		return fs.renameDir(oldFile, oldname, newname)
+	})
+}
+
+func (fs *FS) renameFile(oldFile *file, oldname, newname string) error {
+	if store, ok := storeAs[MoveStore](fs.store.store); ok {
+		return fs.moveFile(store, oldFile, oldname, newname)
+	}
+	oldVersion, newVersion := fs.flusher.version(oldname), fs.flusher.version(newname)
+	contents, err := oldFile.fileData.Data()
+	if err != nil {
+		return err
+	}
+	moveXattrs, err := fs.renameXattrs(oldname, newname)
+	if err != nil {
+		return err
+	}
+	txn, err := fs.store.Transaction(TransactionOptions{Mode: TransactionReadWrite})
+	if err == nil {
+		// remove oldname only once newname is written, so a failed write can't lose the file
+		txn.SetHandler(newname, oldFile.fileData, contents, OpHandlerFunc(func(txn Transaction, result OpResult) error {
+			if result.Err == nil {
+				txn.Set(oldname, nil, nil)
+			}
+			return nil
+		}))
+		err = firstOpErr(txn.Commit(fs.store.ctx))
 	}
+	if err == nil {
+		fs.flusher.forget(oldname, oldVersion)
+		fs.flusher.forget(newname, newVersion)
+		err = moveXattrs()
+	}
+	return err
+}
 
+// renameDir moves directory 'oldname' and its contents to 'newname'. If interrupted, running it again completes the move.
+func (fs *FS) renameDir(oldFile *file, oldname, newname string) error {
 	files, err := oldFile.ReadDirNames()
 	if err != nil {
 		return err
 	}
+	moveXattrs, err := fs.renameXattrs(oldname, newname)
+	if err != nil {
+		return err
+	}
 	err = fs.setFile(newname, oldFile.fileData)
+	if err == nil {
+		err = moveXattrs()
+	}
 	if err != nil {
 		return err
 	}
 	for _, name := range files {
-		err := fs.Rename(path.Join(oldname, name), path.Join(newname, name))
+		oldChildName, newChildName := path.Join(oldname, name), path.Join(newname, name)
+		child, err := fs.getFile(oldChildName)
+		switch {
+		case errors.Is(err, hackpadfs.ErrNotExist):
+			continue
+		case err != nil:
+			return err
+		case child.Mode().IsDir():
+			err = fs.renameDir(child, oldChildName, newChildName)
+		default:
+			err = fs.renameFile(child, oldChildName, newChildName)
+		}
 		if err != nil {
-			// TODO don't leave destination in corrupted state (missing file records for dir names)
 			return err
 		}
 	}
 	return fs.setFile(oldname, nil)
 }
 
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if !hackpadfs.ValidPath(name) {
+		return fs.wrapperErr("removeall", name, hackpadfs.ErrInvalid)
+	}
+	filePath, file, err := fs.resolve(name, false)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return nil
+	case err != nil:
+		return fs.wrapperErr("removeall", name, err)
+	}
+	err = fs.journaled(journalEntry{Op: journalOpRemoveAll, Path: filePath}, func() error {
+		return fs.removeAll(filePath, file)
+	})
+	return fs.wrapperErr("removeall", name, err)
+}
+
+// removeAll removes 'file' at 'name' and all of its contents. If interrupted, running it again completes the removal.
+func (fs *FS) removeAll(name string, file *file) error {
+	if file.Mode().IsDir() {
+		dirNames, err := file.ReadDirNames()
+		if err != nil {
+			return err
+		}
+		for _, dirName := range dirNames {
+			childName := path.Join(name, dirName)
+			child, err := fs.getFile(childName)
+			if err == nil {
+				err = fs.removeAll(childName, child)
+			}
+			if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return fs.setFile(name, nil)
+}
+
 // Stat implements hackpadfs.StatFS
 func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
-	file, err := fs.getFile(name)
+	_, file, err := fs.resolve(name, true)
 	if err != nil {
 		return nil, fs.wrapperErr("stat", name, err)
 	}
-	return file.info(), nil
+	return fileInfo{Record: file.fileData, Path: name}, nil
 }
 
 // Chmod implements hackpadfs.ChmodFS
 func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
-	file, err := fs.getFile(name)
+	_, file, err := fs.resolve(name, true)
 	if err != nil {
 		return fs.wrapperErr("chmod", name, err)
 	}
 
-	newMode := (file.Mode() & ^chmodBits) | (mode & chmodBits)
-	file.modeOverride = &newMode
+	file.setMode((file.Mode() & ^chmodBits) | (mode & chmodBits))
 	return file.save()
 }
 
 // Chtimes implements hackpadfs.ChtimesFS
 func (fs *FS) Chtimes(name string, _ time.Time, mtime time.Time) error {
-	file, err := fs.getFile(name)
+	_, file, err := fs.resolve(name, true)
 	if err != nil {
 		return fs.wrapperErr("chtimes", name, err)
 	}
-	file.modTimeOverride = mtime
+	file.setModTime(mtime)
 	return file.save()
 }
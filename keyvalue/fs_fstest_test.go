@@ -18,6 +18,7 @@ func TestFS(t *testing.T) {
 			}
 			return fs
 		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
@@ -1,13 +1,16 @@
 package keyvalue
 
+import "context"
+
 type transactionOnly struct {
 	store Store
+	ctx   context.Context
 }
 
-func newFSTransactioner(store Store) *transactionOnly {
-	return &transactionOnly{store}
+func newFSTransactioner(ctx context.Context, store Store) *transactionOnly {
+	return &transactionOnly{store: store, ctx: ctx}
 }
 
 func (t *transactionOnly) Transaction(options TransactionOptions) (Transaction, error) {
-	return TransactionOrSerial(t.store, options)
+	return transactionOrSerial(t.ctx, t.store, options)
 }
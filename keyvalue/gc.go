@@ -0,0 +1,109 @@
+package keyvalue
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// KeysStore is a Store that can list every path it holds, including paths unreachable from the root directory.
+type KeysStore interface {
+	Store
+	// Keys returns all paths in this Store, in any order.
+	Keys(ctx context.Context) ([]string, error)
+}
+
+// GCOptions contain options for garbage collecting an FS with GC()
+type GCOptions struct {
+	// DryRun only reports orphaned records and does not remove them.
+	DryRun bool
+}
+
+// GCResult reports the outcome of a GC() run
+type GCResult struct {
+	// Orphans are paths to records which are not reachable from the root directory, sorted by path.
+	// Unless GCOptions.DryRun was set, these records have been removed.
+	Orphans []string
+}
+
+// GC walks the file system from the root directory and detects orphaned records: records held by the store which can not be reached by any directory.
+// Orphans are typically left behind when a multi-key operation is interrupted, like a crash in the middle of Rename().
+// Orphans are removed unless options.DryRun is set.
+//
+// Requires the FS's Store to implement KeysStore, fails with a not implemented error otherwise.
+func (fs *FS) GC(ctx context.Context, options GCOptions) (GCResult, error) {
+	keysStore, ok := storeAs[KeysStore](fs.store.store)
+	if !ok {
+		return GCResult{}, &hackpadfs.PathError{Op: "gc", Path: ".", Err: hackpadfs.ErrNotImplemented}
+	}
+	keys, err := keysStore.Keys(ctx)
+	if err != nil {
+		return GCResult{}, fs.wrapperErr("gc", ".", err)
+	}
+	reachable := make(map[string]bool)
+	if err := fs.markReachable(ctx, ".", reachable); err != nil {
+		return GCResult{}, err
+	}
+
+	var result GCResult
+	for _, key := range keys {
+		if !reachable[key] {
+			result.Orphans = append(result.Orphans, key)
+		}
+	}
+	sort.Strings(result.Orphans)
+	if options.DryRun || len(result.Orphans) == 0 {
+		return result, nil
+	}
+
+	txn, err := fs.store.Transaction(TransactionOptions{Mode: TransactionReadWrite})
+	if err != nil {
+		return result, fs.wrapperErr("gc", ".", err)
+	}
+	for _, orphan := range result.Orphans {
+		txn.Set(orphan, nil, nil)
+	}
+	ops, err := txn.Commit(ctx)
+	if err == nil {
+		for i, op := range ops {
+			if op.Err != nil && !errors.Is(op.Err, hackpadfs.ErrNotExist) {
+				return result, fs.wrapperErr("gc", result.Orphans[i], op.Err)
+			}
+		}
+	}
+	return result, fs.wrapperErr("gc", ".", err)
+}
+
+// markReachable records 'name' and all of its descendants in 'reachable'
+func (fs *FS) markReachable(ctx context.Context, name string, reachable map[string]bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	file, err := fs.getFile(name)
+	if err != nil {
+		return fs.wrapperErr("gc", name, err)
+	}
+	reachable[name] = true
+	if !file.Mode().IsDir() {
+		return nil
+	}
+	dirNames, err := file.ReadDirNames()
+	if err != nil {
+		return fs.wrapperErr("gc", name, err)
+	}
+	for _, dirName := range dirNames {
+		childName := path.Join(name, dirName)
+		if reachable[childName] {
+			continue
+		}
+		err := fs.markReachable(ctx, childName, reachable)
+		if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+			// a dir entry may be missing its record, which is not an orphan and can be ignored
+			return err
+		}
+	}
+	return nil
+}
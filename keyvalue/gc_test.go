@@ -0,0 +1,94 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+type mapKeysStore struct {
+	*mapStore
+}
+
+func (s mapKeysStore) Keys(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.records {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func TestGC(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	setup := func(t *testing.T) (*keyvalue.FS, *mapStore) {
+		t.Helper()
+		store := newMapStore()
+		fs, err := keyvalue.NewFS(mapKeysStore{store})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/baz", []byte("baz"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "file", nil, 0600))
+
+		// simulate records left behind by an interrupted operation
+		orphanFile := keyvalue.NewBaseFileRecord(0, time.Now(), 0600, nil, func() (blob.Blob, error) {
+			return blob.NewBytes(nil), nil
+		}, nil)
+		assert.NoError(t, store.Set(ctx, "missing/orphan", orphanFile))
+		assert.NoError(t, store.Set(ctx, "removed/orphan", orphanFile))
+		assert.NoError(t, store.Set(ctx, "file/orphan", orphanFile))
+		return fs, store
+	}
+	expectOrphans := []string{"file/orphan", "missing/orphan", "removed/orphan"}
+
+	t.Run("dry run", func(t *testing.T) {
+		t.Parallel()
+		fs, store := setup(t)
+		result, err := fs.GC(ctx, keyvalue.GCOptions{DryRun: true})
+		assert.NoError(t, err)
+		assert.Equal(t, expectOrphans, result.Orphans)
+		for _, orphan := range expectOrphans {
+			_, err := store.Get(ctx, orphan)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("remove orphans", func(t *testing.T) {
+		t.Parallel()
+		fs, store := setup(t)
+		result, err := fs.GC(ctx, keyvalue.GCOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, expectOrphans, result.Orphans)
+		for _, orphan := range expectOrphans {
+			_, err := store.Get(ctx, orphan)
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		}
+
+		result, err = fs.GC(ctx, keyvalue.GCOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(result.Orphans))
+		contents, err := hackpadfs.ReadFile(fs, "foo/baz")
+		assert.NoError(t, err)
+		assert.Equal(t, "baz", string(contents))
+	})
+
+	t.Run("store does not list keys", func(t *testing.T) {
+		t.Parallel()
+		fs, err := keyvalue.NewFS(newMapStore())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = fs.GC(ctx, keyvalue.GCOptions{})
+		assert.Equal(t, true, errors.Is(err, hackpadfs.ErrNotImplemented))
+	})
+}
@@ -0,0 +1,137 @@
+package keyvalue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/fserrors"
+)
+
+// JournalStore is a Store that can hold write-ahead journal entries separately from file records.
+// Enables FSOptions.Journal, which gives multi-key operations crash consistency on stores without native transactions.
+type JournalStore interface {
+	Store
+	// JournalEntries returns all journal entries, keyed by their ID.
+	JournalEntries(ctx context.Context) (map[string][]byte, error)
+	// SetJournalEntry assigns 'entry' to the given 'id'. If 'entry' is nil, the entry is removed.
+	SetJournalEntry(ctx context.Context, id string, entry []byte) error
+}
+
+const (
+	journalOpRename    = "rename"
+	journalOpRemoveAll = "removeall"
+)
+
+type journalEntry struct {
+	Op      string `json:"op"`
+	Path    string `json:"path"`
+	NewPath string `json:"newPath,omitempty"`
+}
+
+// liveJournalEntries holds the IDs of journal entries for operations still running in this process.
+// Replays skip them, so opening another FS on the same store doesn't race with running operations.
+var liveJournalEntries sync.Map // type: string -> struct{}
+
+// journaled records 'entry' in the journal, runs 'fn', then removes the entry.
+// If 'fn' fails, the operation is rolled forward once more before the entry is removed, so a failed operation is never completed later by surprise.
+// Even so, the error from 'fn' is returned. If rolling forward fails too, the entry is kept for the next replayJournal().
+func (fs *FS) journaled(entry journalEntry, fn func() error) error {
+	if fs.journal == nil {
+		return fn()
+	}
+	randomID, err := newRandomID()
+	if err != nil {
+		return err
+	}
+	id := fmt.Sprintf("%020d-%s", time.Now().UnixNano(), randomID) // sorts entries by creation time
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	liveJournalEntries.Store(id, struct{}{})
+	defer liveJournalEntries.Delete(id)
+	if err := fs.journal.SetJournalEntry(fs.store.ctx, id, entryJSON); err != nil {
+		return err
+	}
+	err = fn()
+	if err != nil && fs.replayJournalEntry(entry) != nil {
+		return err
+	}
+	if removeErr := fs.journal.SetJournalEntry(fs.store.ctx, id, nil); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// replayJournal completes operations left in the journal by interrupted processes, in the order they were started.
+// Entries which fail to replay are kept for the next replay, and malformed entries are discarded, so neither prevents opening the FS.
+func (fs *FS) replayJournal() error {
+	err := fs.replayJournalEntries()
+	return fserrors.WithMessage(err, "replay journal")
+}
+
+func (fs *FS) replayJournalEntries() error {
+	entries, err := fs.journal.JournalEntries(fs.store.ctx)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		if _, live := liveJournalEntries.Load(id); !live {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		var entry journalEntry
+		if err := json.Unmarshal(entries[id], &entry); err == nil && entry.valid() {
+			if err := fs.replayJournalEntry(entry); err != nil {
+				continue // try again during the next replay
+			}
+		}
+		_ = fs.journal.SetJournalEntry(fs.store.ctx, id, nil) // if removal fails, the completed entry is harmlessly replayed next time
+	}
+	return nil
+}
+
+// valid returns true if 'e' is an operation replayJournalEntry can run
+func (e journalEntry) valid() bool {
+	switch e.Op {
+	case journalOpRename:
+		return hackpadfs.ValidPath(e.Path) && hackpadfs.ValidPath(e.NewPath)
+	case journalOpRemoveAll:
+		return hackpadfs.ValidPath(e.Path)
+	default:
+		return false
+	}
+}
+
+// replayJournalEntry rolls 'entry' forward. The operation may have partially or fully completed already.
+func (fs *FS) replayJournalEntry(entry journalEntry) error {
+	file, err := fs.getFile(entry.Path)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		// the source is gone, so the operation already completed
+		return nil
+	case err != nil:
+		return err
+	}
+
+	switch entry.Op {
+	case journalOpRename:
+		if file.Mode().IsDir() {
+			return fs.renameDir(file, entry.Path, entry.NewPath)
+		}
+		return fs.renameFile(file, entry.Path, entry.NewPath)
+	case journalOpRemoveAll:
+		return fs.removeAll(entry.Path, file)
+	default:
+		return fmt.Errorf("unknown journal operation %q: %w", entry.Op, hackpadfs.ErrInvalid)
+	}
+}
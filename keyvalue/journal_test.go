@@ -0,0 +1,221 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+type mapJournalStore struct {
+	*mapStore
+	journalMu sync.Mutex
+	journal   map[string][]byte
+	setsLeft  int64 // if positive, fails the Set after this many succeed
+	crash     bool  // if set, the failed Set crashes the store: all later Sets and journal changes fail
+	crashed   int32
+	stuck     int32 // if set, all Sets fail but journal changes succeed
+}
+
+func newMapJournalStore() *mapJournalStore {
+	return &mapJournalStore{
+		mapStore: newMapStore(),
+		journal:  make(map[string][]byte),
+	}
+}
+
+var errCrash = errors.New("crash")
+
+func (s *mapJournalStore) Set(ctx context.Context, path string, src keyvalue.FileRecord) error {
+	if atomic.LoadInt32(&s.crashed) != 0 || atomic.LoadInt32(&s.stuck) != 0 {
+		return errCrash
+	}
+	if atomic.LoadInt64(&s.setsLeft) > 0 && atomic.AddInt64(&s.setsLeft, -1) == 0 {
+		if s.crash {
+			atomic.StoreInt32(&s.crashed, 1)
+		}
+		return errCrash
+	}
+	return s.mapStore.Set(ctx, path, src)
+}
+
+func (s *mapJournalStore) JournalEntries(_ context.Context) (map[string][]byte, error) {
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	entries := make(map[string][]byte, len(s.journal))
+	for id, entry := range s.journal {
+		entries[id] = entry
+	}
+	return entries, nil
+}
+
+func (s *mapJournalStore) SetJournalEntry(_ context.Context, id string, entry []byte) error {
+	if atomic.LoadInt32(&s.crashed) != 0 {
+		return errCrash
+	}
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	if entry == nil {
+		delete(s.journal, id)
+	} else {
+		s.journal[id] = entry
+	}
+	return nil
+}
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+	open := func(t *testing.T, store keyvalue.Store) *keyvalue.FS {
+		t.Helper()
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{Journal: true})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return fs
+	}
+	setup := func(t *testing.T) (*keyvalue.FS, *mapJournalStore) {
+		t.Helper()
+		store := newMapJournalStore()
+		fs := open(t, store)
+		assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+		for _, name := range []string{"foo/1", "foo/2", "foo/bar/3", "foo/bar/4"} {
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, name, []byte(name), 0600))
+		}
+		return fs, store
+	}
+
+	t.Run("replay interrupted rename", func(t *testing.T) {
+		t.Parallel()
+		fs, store := setup(t)
+		store.crash = true
+		atomic.StoreInt64(&store.setsLeft, 4)
+		err := fs.Rename("foo", "baz")
+		assert.ErrorIs(t, errCrash, err)
+		entries, err := store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+
+		atomic.StoreInt32(&store.crashed, 0)
+		fs = open(t, store)
+		_, err = fs.Stat("foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		for _, name := range []string{"1", "2", "bar/3", "bar/4"} {
+			contents, err := hackpadfs.ReadFile(fs, "baz/"+name)
+			assert.NoError(t, err)
+			assert.Equal(t, "foo/"+name, string(contents))
+		}
+		entries, err = store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("replay interrupted remove all", func(t *testing.T) {
+		t.Parallel()
+		fs, store := setup(t)
+		store.crash = true
+		atomic.StoreInt64(&store.setsLeft, 2)
+		err := fs.RemoveAll("foo")
+		assert.ErrorIs(t, errCrash, err)
+
+		atomic.StoreInt32(&store.crashed, 0)
+		fs = open(t, store)
+		_, err = fs.Stat("foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		entries, err := store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("failed operation completes before returning", func(t *testing.T) {
+		t.Parallel()
+		fs, store := setup(t)
+		atomic.StoreInt64(&store.setsLeft, 4)
+		err := fs.Rename("foo", "baz")
+		assert.ErrorIs(t, errCrash, err)
+		_, err = fs.Stat("foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		for _, name := range []string{"1", "2", "bar/3", "bar/4"} {
+			contents, err := hackpadfs.ReadFile(fs, "baz/"+name)
+			assert.NoError(t, err)
+			assert.Equal(t, "foo/"+name, string(contents))
+		}
+		entries, err := store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("failed roll forward keeps the entry", func(t *testing.T) {
+		t.Parallel()
+		fs, store := setup(t)
+		atomic.StoreInt32(&store.stuck, 1)
+		err := fs.Rename("foo", "baz")
+		assert.ErrorIs(t, errCrash, err)
+		entries, err := store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+
+		atomic.StoreInt32(&store.stuck, 0)
+		fs = open(t, store)
+		_, err = fs.Stat("foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		contents, err := hackpadfs.ReadFile(fs, "baz/bar/4")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo/bar/4", string(contents))
+		entries, err = store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("bad entries do not prevent replay", func(t *testing.T) {
+		t.Parallel()
+		_, store := setup(t)
+		ctx := context.Background()
+		assert.NoError(t, store.SetJournalEntry(ctx, "1", []byte(`{`)))
+		assert.NoError(t, store.SetJournalEntry(ctx, "2", []byte(`{"op":"unknown","path":"foo"}`)))
+		assert.NoError(t, store.SetJournalEntry(ctx, "3", []byte(`{"op":"rename","path":"foo/1","newPath":"foo/one"}`)))
+
+		fs := open(t, store)
+		contents, err := hackpadfs.ReadFile(fs, "foo/one")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo/1", string(contents))
+		entries, err := store.JournalEntries(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("completed operations leave no entries", func(t *testing.T) {
+		t.Parallel()
+		fs, store := setup(t)
+		assert.NoError(t, fs.Rename("foo/1", "foo/one"))
+		assert.NoError(t, fs.Rename("foo", "baz"))
+		assert.NoError(t, fs.RemoveAll("baz/bar"))
+		entries, err := store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+	})
+
+	t.Run("store does not support journaling", func(t *testing.T) {
+		t.Parallel()
+		_, err := keyvalue.NewFSWithOptions(newMapStore(), keyvalue.FSOptions{Journal: true})
+		assert.Equal(t, true, errors.Is(err, hackpadfs.ErrNotImplemented))
+		_, err = keyvalue.NewFSWithOptions(keyvalue.NewObservedStore(newMapStore(), func(keyvalue.OpEvent) {}), keyvalue.FSOptions{Journal: true})
+		assert.Equal(t, true, errors.Is(err, hackpadfs.ErrNotImplemented))
+	})
+
+	t.Run("decorated store", func(t *testing.T) {
+		t.Parallel()
+		store := newMapJournalStore()
+		fs := open(t, keyvalue.NewObservedStore(store, func(keyvalue.OpEvent) {}))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+		atomic.StoreInt32(&store.stuck, 1)
+		assert.ErrorIs(t, errCrash, fs.Rename("foo", "bar"))
+		entries, err := store.JournalEntries(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+	})
+}
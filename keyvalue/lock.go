@@ -0,0 +1,222 @@
+package keyvalue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var _ hackpadfs.LockFS = &FS{}
+
+const (
+	defaultLockLease = 30 * time.Second
+	maxLockAttempts  = 10
+)
+
+// LockStore is a Store that can atomically update lock records, so FS instances sharing a store can coordinate writers with Lock().
+// Lock records are kept separately from file records and do not appear in the file system.
+type LockStore interface {
+	Store
+	// GetLock returns the lock record for 'path'. Returns a zero LockRecord if no lock record exists.
+	GetLock(ctx context.Context, path string) (LockRecord, error)
+	// CompareAndSwapLock atomically assigns 'record' to 'path' if the current lock record's revision equals 'revision'.
+	// If swapped, the stored record's revision must become revision+1. Returns false if the revision did not match.
+	CompareAndSwapLock(ctx context.Context, path string, revision uint64, record LockRecord) (bool, error)
+}
+
+// LockRecord is the state of an advisory lock held on a path
+type LockRecord struct {
+	// Revision increments every time the record changes. Set by the LockStore.
+	Revision uint64
+	// Exclusive is set if the lock is held in hackpadfs.LockExclusive mode
+	Exclusive bool
+	// Holders are the lock's current owners. Holders past their expiration no longer hold the lock.
+	Holders []LockHolder
+}
+
+// LockHolder is an owner of a lock, which holds it until Expires unless the lease is renewed
+type LockHolder struct {
+	Owner   string
+	Expires time.Time
+}
+
+func (r LockRecord) liveHolders(now time.Time) []LockHolder {
+	var holders []LockHolder
+	for _, holder := range r.Holders {
+		if holder.Expires.After(now) {
+			holders = append(holders, holder)
+		}
+	}
+	return holders
+}
+
+// Lock implements hackpadfs.LockFS
+//
+// Requires the FS's Store to implement LockStore, fails with a not implemented error otherwise.
+// Held locks are renewed in the background until unlocked. If this process stops, its locks expire after FSOptions.LockLease.
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	store, ok := storeAs[LockStore](fs.store.store)
+	if !ok {
+		return nil, &hackpadfs.PathError{Op: "lock", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	lockPath, _, err := fs.resolve(name, true)
+	if err != nil {
+		return nil, fs.wrapperErr("lock", name, err)
+	}
+	owner, err := newRandomID()
+	if err != nil {
+		return nil, fs.wrapperErr("lock", name, err)
+	}
+	lock := &fileLock{
+		ctx:   fs.store.ctx,
+		store: store,
+		clock: fs.lockClock,
+		path:  lockPath,
+		owner: owner,
+		lease: fs.lockLease,
+		stop:  make(chan struct{}),
+	}
+	err = lock.update(func(record LockRecord, holders []LockHolder) (LockRecord, error) {
+		if len(holders) > 0 && (mode == hackpadfs.LockExclusive || record.Exclusive) {
+			return record, hackpadfs.ErrWouldBlock
+		}
+		return LockRecord{
+			Exclusive: mode == hackpadfs.LockExclusive,
+			Holders:   append(holders, lock.holder()),
+		}, nil
+	})
+	if err != nil {
+		return nil, fs.wrapperErr("lock", name, err)
+	}
+	go lock.renew()
+	return lock, nil
+}
+
+func newRandomID() (string, error) {
+	var id [16]byte
+	_, err := rand.Read(id[:])
+	return hex.EncodeToString(id[:]), err
+}
+
+// clock is the time source for lock leases. Replaced in tests to control expiry.
+type clock interface {
+	Now() time.Time
+	// NewTicker returns a channel which receives the time every 'd', and a function to stop it
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
+}
+
+type fileLock struct {
+	ctx      context.Context
+	store    LockStore
+	clock    clock
+	path     string
+	owner    string
+	lease    time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (l *fileLock) holder() LockHolder {
+	return LockHolder{Owner: l.owner, Expires: l.clock.Now().Add(l.lease)}
+}
+
+// update applies 'fn' to the current lock record and its live holders, then swaps in the result. Retries if the record changed concurrently.
+func (l *fileLock) update(fn func(record LockRecord, holders []LockHolder) (LockRecord, error)) error {
+	for attempt := 0; attempt < maxLockAttempts; attempt++ {
+		record, err := l.store.GetLock(l.ctx, l.path)
+		if err != nil {
+			return err
+		}
+		newRecord, err := fn(record, record.liveHolders(l.clock.Now()))
+		if err != nil {
+			return err
+		}
+		swapped, err := l.store.CompareAndSwapLock(l.ctx, l.path, record.Revision, newRecord)
+		if err != nil || swapped {
+			return err
+		}
+	}
+	return hackpadfs.ErrWouldBlock
+}
+
+// withoutOwner returns 'holders' excluding this lock's owner, and whether it was found
+func (l *fileLock) withoutOwner(holders []LockHolder) ([]LockHolder, bool) {
+	var others []LockHolder
+	found := false
+	for _, holder := range holders {
+		if holder.Owner == l.owner {
+			found = true
+		} else {
+			others = append(others, holder)
+		}
+	}
+	return others, found
+}
+
+// renew extends this lock's lease until it is unlocked or lost to expiry.
+// Failed renewals are retried on the next tick, which leaves time for one retry before the lease expires.
+func (l *fileLock) renew() {
+	tick, stopTicker := l.clock.NewTicker(l.lease / 3)
+	defer stopTicker()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-l.ctx.Done():
+			return
+		case <-tick:
+		}
+		lost := false
+		// store errors may be temporary, so failed renewals are retried on the next tick
+		_ = l.update(func(record LockRecord, holders []LockHolder) (LockRecord, error) {
+			others, found := l.withoutOwner(holders)
+			if !found {
+				lost = true
+				return record, nil
+			}
+			record.Holders = append(others, l.holder())
+			return record, nil
+		})
+		if lost {
+			return
+		}
+	}
+}
+
+func (l *fileLock) Unlock() error {
+	alreadyUnlocked := true
+	l.stopOnce.Do(func() {
+		alreadyUnlocked = false
+		close(l.stop)
+	})
+	if alreadyUnlocked {
+		return &hackpadfs.PathError{Op: "unlock", Path: l.path, Err: hackpadfs.ErrClosed}
+	}
+	err := l.update(func(record LockRecord, holders []LockHolder) (LockRecord, error) {
+		others, _ := l.withoutOwner(holders)
+		if len(others) == 0 {
+			return LockRecord{}, nil
+		}
+		record.Holders = others
+		return record, nil
+	})
+	if err != nil {
+		return &hackpadfs.PathError{Op: "unlock", Path: l.path, Err: err}
+	}
+	return nil
+}
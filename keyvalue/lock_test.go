@@ -0,0 +1,233 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+type mapLockStore struct {
+	*mapStore
+	locksMu sync.Mutex
+	locks   map[string]keyvalue.LockRecord
+	fail    int32 // while set, all lock operations fail
+}
+
+func newMapLockStore() *mapLockStore {
+	return &mapLockStore{
+		mapStore: newMapStore(),
+		locks:    make(map[string]keyvalue.LockRecord),
+	}
+}
+
+var errLockStore = errors.New("lock store failed")
+
+func (s *mapLockStore) GetLock(_ context.Context, path string) (keyvalue.LockRecord, error) {
+	if atomic.LoadInt32(&s.fail) != 0 {
+		return keyvalue.LockRecord{}, errLockStore
+	}
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	return s.locks[path], nil
+}
+
+func (s *mapLockStore) CompareAndSwapLock(_ context.Context, path string, revision uint64, record keyvalue.LockRecord) (bool, error) {
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	if s.locks[path].Revision != revision {
+		return false, nil
+	}
+	record.Revision = revision + 1
+	s.locks[path] = record
+	return true, nil
+}
+
+// fakeClock only advances when told to. Every ticker shares one channel, which receives a tick on demand.
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	ticks chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{
+		now:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		ticks: make(chan time.Time),
+	}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(time.Duration) (<-chan time.Time, func()) {
+	return c.ticks, func() {}
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// tick waits for a ticker to receive the current time. Ticking twice ensures the first tick's work completed.
+func (c *fakeClock) tick(t *testing.T) {
+	t.Helper()
+	select {
+	case c.ticks <- c.Now():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for a ticker")
+	}
+}
+
+func makeLockFS(t *testing.T, store keyvalue.Store, lease time.Duration) *keyvalue.FS {
+	t.Helper()
+	fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{LockLease: lease})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return fs
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exclusive and shared", func(t *testing.T) {
+		t.Parallel()
+		store := newMapLockStore()
+		fs1 := makeLockFS(t, store, time.Minute)
+		fs2 := makeLockFS(t, store, time.Minute)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs1, "foo", nil, 0600))
+
+		shared1, err := fs1.Lock("foo", hackpadfs.LockShared)
+		assert.NoError(t, err)
+		shared2, err := fs2.Lock("foo", hackpadfs.LockShared)
+		assert.NoError(t, err)
+		_, err = fs2.Lock("foo", hackpadfs.LockExclusive)
+		assert.ErrorIs(t, hackpadfs.ErrWouldBlock, err)
+
+		assert.NoError(t, shared1.Unlock())
+		assert.NoError(t, shared2.Unlock())
+		assert.ErrorIs(t, hackpadfs.ErrClosed, shared2.Unlock())
+
+		exclusive, err := fs2.Lock("foo", hackpadfs.LockExclusive)
+		assert.NoError(t, err)
+		_, err = fs1.Lock("foo", hackpadfs.LockShared)
+		assert.ErrorIs(t, hackpadfs.ErrWouldBlock, err)
+		assert.NoError(t, exclusive.Unlock())
+		exclusive, err = fs1.Lock("foo", hackpadfs.LockExclusive)
+		assert.NoError(t, err)
+		assert.NoError(t, exclusive.Unlock())
+	})
+
+	t.Run("lease expires", func(t *testing.T) {
+		t.Parallel()
+		const lease = time.Minute
+		store := newMapLockStore()
+		clock := newFakeClock()
+		fs := makeLockFS(t, store, lease)
+		keyvalue.SetLockClock(fs, clock)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		_, err := fs.WithContext(ctx).Lock("foo", hackpadfs.LockExclusive)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		clock.advance(lease / 2)
+		clock.tick(t)
+		clock.tick(t)
+		clock.advance(lease / 2)
+		_, err = fs.Lock("foo", hackpadfs.LockExclusive)
+		assert.ErrorIs(t, hackpadfs.ErrWouldBlock, err) // still renewed
+
+		cancel() // simulate a stopped owner
+		clock.advance(lease)
+		lock, err := fs.Lock("foo", hackpadfs.LockExclusive)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.NoError(t, lock.Unlock())
+	})
+
+	t.Run("renewal retries after store errors", func(t *testing.T) {
+		t.Parallel()
+		const lease = time.Minute
+		store := newMapLockStore()
+		clock := newFakeClock()
+		fs := makeLockFS(t, store, lease)
+		keyvalue.SetLockClock(fs, clock)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+
+		lock, err := fs.Lock("foo", hackpadfs.LockExclusive)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		clock.advance(lease / 2)
+		atomic.StoreInt32(&store.fail, 1)
+		clock.tick(t)
+		clock.tick(t)
+		atomic.StoreInt32(&store.fail, 0)
+		clock.tick(t)
+		clock.tick(t)
+
+		clock.advance(lease / 2)
+		_, err = fs.Lock("foo", hackpadfs.LockExclusive)
+		assert.ErrorIs(t, hackpadfs.ErrWouldBlock, err) // renewed despite the failures
+		assert.NoError(t, lock.Unlock())
+	})
+
+	t.Run("file does not exist", func(t *testing.T) {
+		t.Parallel()
+		fs := makeLockFS(t, newMapLockStore(), time.Minute)
+		_, err := fs.Lock("foo", hackpadfs.LockExclusive)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("decorated store", func(t *testing.T) {
+		t.Parallel()
+		store := newMapLockStore()
+		cache, err := keyvalue.NewCacheStore(store, 10)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		fs1 := makeLockFS(t, cache, time.Minute)
+		fs2 := makeLockFS(t, store, time.Minute)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs1, "foo", nil, 0600))
+
+		lock, err := fs1.Lock("foo", hackpadfs.LockExclusive)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = fs2.Lock("foo", hackpadfs.LockExclusive)
+		assert.ErrorIs(t, hackpadfs.ErrWouldBlock, err)
+		assert.NoError(t, lock.Unlock())
+	})
+
+	t.Run("store does not support locks", func(t *testing.T) {
+		t.Parallel()
+		fs := makeLockFS(t, newMapStore(), time.Minute)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+		_, err := fs.Lock("foo", hackpadfs.LockExclusive)
+		assert.Equal(t, true, errors.Is(err, hackpadfs.ErrNotImplemented))
+
+		cache, err := keyvalue.NewCacheStore(newMapStore(), 10)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		fs = makeLockFS(t, cache, time.Minute)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+		_, err = fs.Lock("foo", hackpadfs.LockExclusive)
+		assert.Equal(t, true, errors.Is(err, hackpadfs.ErrNotImplemented))
+	})
+}
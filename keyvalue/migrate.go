@@ -0,0 +1,119 @@
+package keyvalue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/fserrors"
+)
+
+// SchemaStore is a Store that records the version of its record layout, so existing data can be upgraded in place by a MigrationRegistry.
+type SchemaStore interface {
+	Store
+	// SchemaVersion returns the layout version of the records currently held in the store.
+	// Stores which have never recorded a version, including new and empty stores, must return 0.
+	// A MigrationRegistry stamps new stores with its latest version, so only stores created before any version was recorded run every migration.
+	SchemaVersion(ctx context.Context) (int, error)
+	// SetSchemaVersion records 'version' as the store's current layout version. Called after each successful migration.
+	SetSchemaVersion(ctx context.Context, version int) error
+}
+
+// Migration upgrades a Store's records from one layout version to the next.
+type Migration struct {
+	// Version is the schema version this migration upgrades to. Runs against stores at version Version-1.
+	Version int
+	// Migrate rewrites the records in 'store' in place.
+	// Since migrations may be interrupted, Migrate should be safe to run again on a partially migrated store.
+	Migrate func(ctx context.Context, store Store) error
+}
+
+// MigrationRegistry holds the Migrations required to upgrade a SchemaStore to the latest schema version.
+// Register every layout change with a new Migration, then pass the registry to NewFSWithOptions() to upgrade stores on open.
+type MigrationRegistry struct {
+	migrations map[int]Migration
+}
+
+// NewMigrationRegistry returns a new, empty MigrationRegistry.
+func NewMigrationRegistry() *MigrationRegistry {
+	return &MigrationRegistry{
+		migrations: make(map[int]Migration),
+	}
+}
+
+// Register adds 'migration' to the registry. Fails if a migration for the same version is already registered.
+func (r *MigrationRegistry) Register(migration Migration) error {
+	if migration.Version <= 0 || migration.Migrate == nil {
+		return fmt.Errorf("invalid migration for schema version %d: %w", migration.Version, hackpadfs.ErrInvalid)
+	}
+	if _, exists := r.migrations[migration.Version]; exists {
+		return fmt.Errorf("migration for schema version %d: %w", migration.Version, hackpadfs.ErrExist)
+	}
+	r.migrations[migration.Version] = migration
+	return nil
+}
+
+// LatestVersion returns the highest schema version reachable with the registered migrations.
+func (r *MigrationRegistry) LatestVersion() int {
+	latest := 0
+	for version := range r.migrations {
+		if version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
+
+// Migrate upgrades 'store' to LatestVersion() by running each registered migration newer than the store's current version, in order.
+// New stores, which have no version or root directory yet, already use the latest layout, so they are stamped with LatestVersion() instead.
+// Fails if the store's version is newer than LatestVersion() or if a migration is missing between the two versions.
+func (r *MigrationRegistry) Migrate(ctx context.Context, store SchemaStore) error {
+	err := r.migrate(ctx, store)
+	return fserrors.WithMessage(err, "migrate")
+}
+
+func (r *MigrationRegistry) migrate(ctx context.Context, store SchemaStore) error {
+	currentVersion, err := store.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	latestVersion := r.LatestVersion()
+	if currentVersion > latestVersion {
+		return fmt.Errorf("store schema version %d is newer than latest supported version %d", currentVersion, latestVersion)
+	}
+	if currentVersion == 0 {
+		_, err := store.Get(ctx, ".")
+		switch {
+		case errors.Is(err, hackpadfs.ErrNotExist):
+			// a new store has no records to upgrade
+			return store.SetSchemaVersion(ctx, latestVersion)
+		case err != nil:
+			return err
+		}
+	}
+
+	var versions []int
+	for version := range r.migrations {
+		if version > currentVersion {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	for i, version := range versions {
+		if expectedVersion := currentVersion + i + 1; version != expectedVersion {
+			return fmt.Errorf("missing migration for schema version %d", expectedVersion)
+		}
+	}
+
+	for _, version := range versions {
+		if err := r.migrations[version].Migrate(ctx, store); err != nil {
+			return fmt.Errorf("schema version %d: %w", version, err)
+		}
+		if err := store.SetSchemaVersion(ctx, version); err != nil {
+			return fmt.Errorf("schema version %d: %w", version, err)
+		}
+	}
+	return nil
+}
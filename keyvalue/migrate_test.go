@@ -0,0 +1,179 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+type mapSchemaStore struct {
+	*mapStore
+	version int
+}
+
+func (s *mapSchemaStore) SchemaVersion(_ context.Context) (int, error) {
+	return s.version, nil
+}
+
+func (s *mapSchemaStore) SetSchemaVersion(_ context.Context, version int) error {
+	s.version = version
+	return nil
+}
+
+// newExistingSchemaStore returns a mapSchemaStore holding a file system created before it recorded any schema version
+func newExistingSchemaStore(t *testing.T) *mapSchemaStore {
+	t.Helper()
+	store := &mapSchemaStore{mapStore: newMapStore()}
+	_, err := keyvalue.NewFS(store)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return store
+}
+
+func TestMigrationRegistryRegister(t *testing.T) {
+	t.Parallel()
+	noop := func(context.Context, keyvalue.Store) error { return nil }
+	registry := keyvalue.NewMigrationRegistry()
+	assert.Equal(t, 0, registry.LatestVersion())
+
+	assert.NoError(t, registry.Register(keyvalue.Migration{Version: 1, Migrate: noop}))
+	assert.NoError(t, registry.Register(keyvalue.Migration{Version: 2, Migrate: noop}))
+	assert.Equal(t, 2, registry.LatestVersion())
+
+	err := registry.Register(keyvalue.Migration{Version: 2, Migrate: noop})
+	assert.Equal(t, true, errors.Is(err, hackpadfs.ErrExist))
+	err = registry.Register(keyvalue.Migration{Version: 0, Migrate: noop})
+	assert.Equal(t, true, errors.Is(err, hackpadfs.ErrInvalid))
+	err = registry.Register(keyvalue.Migration{Version: 3})
+	assert.Equal(t, true, errors.Is(err, hackpadfs.ErrInvalid))
+}
+
+func TestMigrationRegistryMigrate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("runs migrations in order", func(t *testing.T) {
+		t.Parallel()
+		var ran []int
+		registry := keyvalue.NewMigrationRegistry()
+		for _, version := range []int{3, 1, 2} {
+			version := version
+			assert.NoError(t, registry.Register(keyvalue.Migration{
+				Version: version,
+				Migrate: func(context.Context, keyvalue.Store) error {
+					ran = append(ran, version)
+					return nil
+				},
+			}))
+		}
+		store := &mapSchemaStore{mapStore: newMapStore(), version: 1}
+		assert.NoError(t, registry.Migrate(ctx, store))
+		assert.Equal(t, []int{2, 3}, ran)
+		assert.Equal(t, 3, store.version)
+
+		ran = nil
+		assert.NoError(t, registry.Migrate(ctx, store))
+		assert.Equal(t, []int(nil), ran)
+	})
+
+	t.Run("store newer than registry", func(t *testing.T) {
+		t.Parallel()
+		store := &mapSchemaStore{mapStore: newMapStore(), version: 1}
+		assert.Error(t, keyvalue.NewMigrationRegistry().Migrate(ctx, store))
+	})
+
+	t.Run("missing migration", func(t *testing.T) {
+		t.Parallel()
+		registry := keyvalue.NewMigrationRegistry()
+		assert.NoError(t, registry.Register(keyvalue.Migration{
+			Version: 2,
+			Migrate: func(context.Context, keyvalue.Store) error { return nil },
+		}))
+		store := newExistingSchemaStore(t)
+		assert.Error(t, registry.Migrate(ctx, store))
+		assert.Equal(t, 0, store.version)
+	})
+
+	t.Run("failed migration keeps last successful version", func(t *testing.T) {
+		t.Parallel()
+		someErr := errors.New("some error")
+		registry := keyvalue.NewMigrationRegistry()
+		assert.NoError(t, registry.Register(keyvalue.Migration{
+			Version: 1,
+			Migrate: func(context.Context, keyvalue.Store) error { return nil },
+		}))
+		assert.NoError(t, registry.Register(keyvalue.Migration{
+			Version: 2,
+			Migrate: func(context.Context, keyvalue.Store) error { return someErr },
+		}))
+		store := newExistingSchemaStore(t)
+		err := registry.Migrate(ctx, store)
+		assert.Equal(t, true, errors.Is(err, someErr))
+		assert.Equal(t, 1, store.version)
+	})
+
+	t.Run("stamps new stores", func(t *testing.T) {
+		t.Parallel()
+		registry := keyvalue.NewMigrationRegistry()
+		for _, version := range []int{1, 2} {
+			assert.NoError(t, registry.Register(keyvalue.Migration{
+				Version: version,
+				Migrate: func(context.Context, keyvalue.Store) error {
+					return errors.New("new stores should not be migrated")
+				},
+			}))
+		}
+		store := &mapSchemaStore{mapStore: newMapStore()}
+		assert.NoError(t, registry.Migrate(ctx, store))
+		assert.Equal(t, 2, store.version)
+	})
+}
+
+func TestNewFSWithMigrations(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("upgrades existing records", func(t *testing.T) {
+		t.Parallel()
+		store := newExistingSchemaStore(t)
+		assert.NoError(t, store.Set(ctx, "foo", keyvalue.NewBaseFileRecord(0, time.Now(), 0600, nil, func() (blob.Blob, error) {
+			return blob.NewBytes(nil), nil
+		}, nil)))
+
+		registry := keyvalue.NewMigrationRegistry()
+		assert.NoError(t, registry.Register(keyvalue.Migration{
+			Version: 1,
+			Migrate: func(ctx context.Context, store keyvalue.Store) error {
+				record, err := store.Get(ctx, "foo")
+				if err != nil {
+					return err
+				}
+				return store.Set(ctx, "foo", keyvalue.NewBaseFileRecord(0, record.ModTime(), 0644, nil, func() (blob.Blob, error) {
+					return blob.NewBytes(nil), nil
+				}, nil))
+			},
+		}))
+		fs, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{Migrations: registry})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, store.version)
+		info, err := fs.Stat("foo")
+		if assert.NoError(t, err) {
+			assert.Equal(t, hackpadfs.FileMode(0644), info.Mode())
+		}
+	})
+
+	t.Run("store does not support schema versions", func(t *testing.T) {
+		t.Parallel()
+		_, err := keyvalue.NewFSWithOptions(newMapStore(), keyvalue.FSOptions{
+			Migrations: keyvalue.NewMigrationRegistry(),
+		})
+		assert.Equal(t, true, errors.Is(err, hackpadfs.ErrNotImplemented))
+	})
+}
@@ -0,0 +1,28 @@
+package keyvalue
+
+import "context"
+
+// MoveStore is a Store that can move a record to a new path without copying its contents.
+// When implemented, FS.Rename() only rewrites keys and metadata, regardless of file size.
+type MoveStore interface {
+	Store
+	// Move atomically moves the record at 'oldPath' to 'newPath', replacing any record at 'newPath'.
+	// If 'oldPath' does not exist, the error must satisfy errors.Is(err, hackpadfs.ErrNotExist).
+	Move(ctx context.Context, oldPath, newPath string) error
+}
+
+func (fs *FS) moveFile(store MoveStore, oldFile *file, oldname, newname string) error {
+	oldVersion, newVersion := fs.flusher.version(oldname), fs.flusher.version(newname)
+	if fs.flusher != nil {
+		// the store must hold the latest contents before moving them
+		if err := fs.flusher.flush(oldFile.fileData); err != nil {
+			return err
+		}
+	}
+	err := store.Move(fs.store.ctx, oldname, newname)
+	if err == nil {
+		fs.flusher.forget(oldname, oldVersion)
+		fs.flusher.forget(newname, newVersion)
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package keyvalue_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+type mapMoveStore struct {
+	*mapStore
+	moves int64
+}
+
+func (s *mapMoveStore) Move(_ context.Context, oldPath, newPath string) error {
+	atomic.AddInt64(&s.moves, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[oldPath]
+	if !ok {
+		return hackpadfs.ErrNotExist
+	}
+	s.records[newPath] = record
+	delete(s.records, oldPath)
+	return nil
+}
+
+func TestRenameMoveStore(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		newStore    func(*mapMoveStore) (keyvalue.Store, error)
+	}{
+		{
+			description: "store",
+			newStore: func(store *mapMoveStore) (keyvalue.Store, error) {
+				return store, nil
+			},
+		},
+		{
+			description: "cached store",
+			newStore: func(store *mapMoveStore) (keyvalue.Store, error) {
+				return keyvalue.NewCacheStore(store, 10)
+			},
+		},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			store := &mapMoveStore{mapStore: newMapStore()}
+			fsStore, err := tc.newStore(store)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			fs, err := keyvalue.NewFS(fsStore)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/baz", []byte("baz"), 0600))
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/biff", []byte("biff"), 0600))
+			_, err = hackpadfs.ReadFile(fs, "foo/biff")
+			assert.NoError(t, err)
+
+			assert.NoError(t, fs.Rename("foo/biff", "foo/boo"))
+			assert.NoError(t, fs.Rename("foo", "moved"))
+			assert.Equal(t, int64(3), atomic.LoadInt64(&store.moves))
+
+			for name, expected := range map[string]string{
+				"moved/bar/baz": "baz",
+				"moved/boo":     "biff",
+			} {
+				contents, err := hackpadfs.ReadFile(fs, name)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, string(contents))
+			}
+			_, err = fs.Stat("foo")
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+			_, err = fs.Stat("foo/biff")
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		})
+	}
+}
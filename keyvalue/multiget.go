@@ -0,0 +1,12 @@
+package keyvalue
+
+import "context"
+
+// MultiGetStore is a Store that can retrieve many records in a single request.
+// FS uses it to fetch every entry of a directory at once, so ReadDir and WalkDir issue one request per directory instead of one per entry.
+type MultiGetStore interface {
+	Store
+	// GetMany retrieves the file records for all 'paths'. Returns one OpResult per path, in the same order.
+	// Each result's Err follows the same rules as Get(). Returns an error if the request as a whole failed.
+	GetMany(ctx context.Context, paths []string) ([]OpResult, error)
+}
@@ -0,0 +1,103 @@
+package keyvalue_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+type mapMultiGetStore struct {
+	*mapStore
+	gets     int64
+	getManys int64
+}
+
+func (s *mapMultiGetStore) Get(ctx context.Context, path string) (keyvalue.FileRecord, error) {
+	atomic.AddInt64(&s.gets, 1)
+	return s.mapStore.Get(ctx, path)
+}
+
+func (s *mapMultiGetStore) GetMany(ctx context.Context, paths []string) ([]keyvalue.OpResult, error) {
+	atomic.AddInt64(&s.getManys, 1)
+	results := make([]keyvalue.OpResult, len(paths))
+	for i, path := range paths {
+		record, err := s.mapStore.Get(ctx, path)
+		results[i] = keyvalue.OpResult{Op: keyvalue.OpID(i), Record: record, Err: err}
+	}
+	return results, nil
+}
+
+func TestReadDirMultiGetStore(t *testing.T) {
+	t.Parallel()
+	store := &mapMultiGetStore{mapStore: newMapStore()}
+	fs, err := keyvalue.NewFS(store)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	names := []string{"a", "b", "c", "d", "e"}
+	for _, name := range names {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/"+name, []byte(name), 0600))
+	}
+
+	atomic.StoreInt64(&store.gets, 0)
+	atomic.StoreInt64(&store.getManys, 0)
+	entries, err := hackpadfs.ReadDir(fs, "foo")
+	assert.NoError(t, err)
+	var entryNames []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), info.Size())
+		entryNames = append(entryNames, entry.Name())
+	}
+	assert.Equal(t, names, entryNames)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&store.gets))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&store.getManys)) // open the dir, then fetch all entries
+}
+
+func TestReadDirMultiGetDecoratedStore(t *testing.T) {
+	t.Parallel()
+	store := &mapMultiGetStore{mapStore: newMapStore()}
+	var observedGets int64
+	observed := keyvalue.NewObservedStore(store, func(event keyvalue.OpEvent) {
+		if event.Op == keyvalue.OpGet {
+			atomic.AddInt64(&observedGets, 1)
+		}
+	})
+	cache, err := keyvalue.NewCacheStore(observed, 10)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fs, err := keyvalue.NewFS(cache)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, fs.Mkdir("foo", 0700))
+	names := []string{"a", "b", "c"}
+	for _, name := range names {
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/"+name, []byte(name), 0600))
+	}
+	for _, name := range names {
+		cache.Evict("foo/" + name)
+	}
+
+	atomic.StoreInt64(&store.gets, 0)
+	atomic.StoreInt64(&store.getManys, 0)
+	atomic.StoreInt64(&observedGets, 0)
+	entries, err := hackpadfs.ReadDir(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, len(names), len(entries))
+	assert.Equal(t, int64(0), atomic.LoadInt64(&store.gets))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&store.getManys)) // the dir is cached, so only its entries are fetched
+	assert.Equal(t, int64(len(names)), atomic.LoadInt64(&observedGets))
+
+	entries, err = hackpadfs.ReadDir(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, len(names), len(entries))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&store.getManys)) // all entries are cached now
+}
@@ -0,0 +1,360 @@
+package keyvalue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+var (
+	_ interface {
+		TransactionStore
+		KeysStore
+		SchemaStore
+		AsyncStore
+		LockStore
+		JournalStore
+		SnapshotStore
+		MoveStore
+		MultiGetStore
+		ExpiringStore
+		BlobStore
+		XattrStore
+	} = &ObservedStore{}
+	_ storeDecorator = &ObservedStore{}
+)
+
+// Store operation names reported in OpEvent.Op
+const (
+	OpGet = "get"
+	OpSet = "set"
+)
+
+// OpEvent describes a completed Store operation
+type OpEvent struct {
+	// Op is the operation name, i.e. OpGet or OpSet
+	Op string
+	// Path is the operation's key
+	Path string
+	// Size is the byte size of the record retrieved or assigned. Zero for failed Gets and deletes.
+	Size int64
+	// Duration is the time from issuing the operation to its completion.
+	// Operations inside a transaction complete when their result is handled during Commit().
+	Duration time.Duration
+	// Err is the operation's error, if any
+	Err error
+}
+
+// ObservedStore is a Store middleware which reports every operation to an observer func.
+// Use it to see where FS time is spent inside a store backend, e.g. by logging slow operations or recording them as tracing spans.
+//
+// Like CacheStore, ObservedStore forwards every optional Store interface to the underlying store, returning hackpadfs.ErrNotImplemented if it is not supported.
+// Only Get and Set operations, including their asynchronous forms, are reported.
+type ObservedStore struct {
+	store   Store
+	observe func(OpEvent)
+}
+
+// NewObservedStore returns an ObservedStore wrapping 'store', which calls 'observe' after each operation.
+// 'observe' may be called concurrently and should return quickly.
+func NewObservedStore(store Store, observe func(OpEvent)) *ObservedStore {
+	return &ObservedStore{
+		store:   store,
+		observe: observe,
+	}
+}
+
+// Get implements keyvalue.Store
+func (o *ObservedStore) Get(ctx context.Context, path string) (FileRecord, error) {
+	start := time.Now()
+	record, err := o.store.Get(ctx, path)
+	return o.observeGet(path, start, record, err), err
+}
+
+// Set implements keyvalue.Store
+func (o *ObservedStore) Set(ctx context.Context, path string, src FileRecord) error {
+	start := time.Now()
+	src = wrapRecordOnce(src)
+	err := o.store.Set(ctx, path, src)
+	o.observeSet(path, start, src, err)
+	return err
+}
+
+// GetMany implements keyvalue.MultiGetStore. Each path is observed as a Get.
+func (o *ObservedStore) GetMany(ctx context.Context, paths []string) ([]OpResult, error) {
+	start := time.Now()
+	store, ok := o.store.(MultiGetStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	results, err := store.GetMany(ctx, paths)
+	if err != nil {
+		for _, path := range paths {
+			o.observeGet(path, start, nil, err)
+		}
+		return nil, err
+	}
+	for i := range results {
+		results[i].Record = o.observeGet(paths[i], start, results[i].Record, results[i].Err)
+	}
+	return results, nil
+}
+
+// Transaction implements keyvalue.TransactionStore
+func (o *ObservedStore) Transaction(options TransactionOptions) (Transaction, error) {
+	store, ok := o.store.(TransactionStore)
+	if !ok {
+		return newSerialTransaction(context.Background(), o), nil
+	}
+	txn, err := store.Transaction(options)
+	if err != nil {
+		return nil, err
+	}
+	return &observedTransaction{
+		Transaction: txn,
+		store:       o,
+		records:     make(map[OpID]FileRecord),
+	}, nil
+}
+
+// Keys implements keyvalue.KeysStore
+func (o *ObservedStore) Keys(ctx context.Context) ([]string, error) {
+	store, ok := o.store.(KeysStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.Keys(ctx)
+}
+
+// SchemaVersion implements keyvalue.SchemaStore
+func (o *ObservedStore) SchemaVersion(ctx context.Context) (int, error) {
+	store, ok := o.store.(SchemaStore)
+	if !ok {
+		return 0, hackpadfs.ErrNotImplemented
+	}
+	return store.SchemaVersion(ctx)
+}
+
+// SetSchemaVersion implements keyvalue.SchemaStore
+func (o *ObservedStore) SetSchemaVersion(ctx context.Context, version int) error {
+	store, ok := o.store.(SchemaStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.SetSchemaVersion(ctx, version)
+}
+
+// GetAsync implements keyvalue.AsyncStore
+func (o *ObservedStore) GetAsync(ctx context.Context, path string) *Future {
+	start := time.Now()
+	future, resolve := NewFuture()
+	store, ok := o.store.(AsyncStore)
+	if !ok {
+		resolve(nil, hackpadfs.ErrNotImplemented)
+		return future
+	}
+	storeFuture := store.GetAsync(ctx, path)
+	go func() {
+		record, err := storeFuture.Await(context.Background())
+		resolve(o.observeGet(path, start, record, err), err)
+	}()
+	return future
+}
+
+// SetAsync implements keyvalue.AsyncStore
+func (o *ObservedStore) SetAsync(ctx context.Context, path string, src FileRecord) *Future {
+	start := time.Now()
+	future, resolve := NewFuture()
+	store, ok := o.store.(AsyncStore)
+	if !ok {
+		resolve(nil, hackpadfs.ErrNotImplemented)
+		return future
+	}
+	src = wrapRecordOnce(src)
+	storeFuture := store.SetAsync(ctx, path, src)
+	go func() {
+		_, err := storeFuture.Await(context.Background())
+		o.observeSet(path, start, src, err)
+		resolve(nil, err)
+	}()
+	return future
+}
+
+// GetLock implements keyvalue.LockStore
+func (o *ObservedStore) GetLock(ctx context.Context, path string) (LockRecord, error) {
+	store, ok := o.store.(LockStore)
+	if !ok {
+		return LockRecord{}, hackpadfs.ErrNotImplemented
+	}
+	return store.GetLock(ctx, path)
+}
+
+// CompareAndSwapLock implements keyvalue.LockStore
+func (o *ObservedStore) CompareAndSwapLock(ctx context.Context, path string, revision uint64, record LockRecord) (bool, error) {
+	store, ok := o.store.(LockStore)
+	if !ok {
+		return false, hackpadfs.ErrNotImplemented
+	}
+	return store.CompareAndSwapLock(ctx, path, revision, record)
+}
+
+// JournalEntries implements keyvalue.JournalStore
+func (o *ObservedStore) JournalEntries(ctx context.Context) (map[string][]byte, error) {
+	store, ok := o.store.(JournalStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.JournalEntries(ctx)
+}
+
+// SetJournalEntry implements keyvalue.JournalStore
+func (o *ObservedStore) SetJournalEntry(ctx context.Context, id string, entry []byte) error {
+	store, ok := o.store.(JournalStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.SetJournalEntry(ctx, id, entry)
+}
+
+// Snapshot implements keyvalue.SnapshotStore
+func (o *ObservedStore) Snapshot(ctx context.Context) (Store, error) {
+	store, ok := o.store.(SnapshotStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.Snapshot(ctx)
+}
+
+// Move implements keyvalue.MoveStore
+func (o *ObservedStore) Move(ctx context.Context, oldPath, newPath string) error {
+	store, ok := o.store.(MoveStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.Move(ctx, oldPath, newPath)
+}
+
+// SetExpiration implements keyvalue.ExpiringStore
+func (o *ObservedStore) SetExpiration(ctx context.Context, path string, expiresAt time.Time) error {
+	store, ok := o.store.(ExpiringStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.SetExpiration(ctx, path, expiresAt)
+}
+
+// NewBlob implements keyvalue.BlobStore. Returns an empty Bytes if the underlying store does not implement BlobStore.
+func (o *ObservedStore) NewBlob() blob.Blob {
+	store, ok := o.store.(BlobStore)
+	if !ok {
+		return blob.NewBytes(nil)
+	}
+	return store.NewBlob()
+}
+
+// GetXattrs implements keyvalue.XattrStore
+func (o *ObservedStore) GetXattrs(ctx context.Context, path string) (map[string][]byte, error) {
+	store, ok := o.store.(XattrStore)
+	if !ok {
+		return nil, hackpadfs.ErrNotImplemented
+	}
+	return store.GetXattrs(ctx, path)
+}
+
+// SetXattrs implements keyvalue.XattrStore
+func (o *ObservedStore) SetXattrs(ctx context.Context, path string, attrs map[string][]byte) error {
+	store, ok := o.store.(XattrStore)
+	if !ok {
+		return hackpadfs.ErrNotImplemented
+	}
+	return store.SetXattrs(ctx, path, attrs)
+}
+
+func (o *ObservedStore) decoratedStore() Store {
+	return o.store
+}
+
+// wrapRecordOnce guards a record's receivers from being called more than once by both the observer and the store.
+// Optional FileRecord interfaces implemented by 'record' are kept, so the FS can still find them.
+func wrapRecordOnce(record FileRecord) FileRecord {
+	if record == nil {
+		return nil
+	}
+	once := &runOnceFileRecord{record: record}
+	if expiringRecord, ok := record.(ExpiringFileRecord); ok {
+		return &runOnceExpiringFileRecord{runOnceFileRecord: once, expiringRecord: expiringRecord}
+	}
+	return once
+}
+
+func (o *ObservedStore) observeGet(path string, start time.Time, record FileRecord, err error) FileRecord {
+	event := OpEvent{Op: OpGet, Path: path, Duration: time.Since(start), Err: err}
+	if err == nil {
+		record = wrapRecordOnce(record)
+		if record != nil && !record.Mode().IsDir() {
+			event.Size = record.Size()
+		}
+	}
+	o.observe(event)
+	return record
+}
+
+func (o *ObservedStore) observeSet(path string, start time.Time, src FileRecord, err error) {
+	event := OpEvent{Op: OpSet, Path: path, Duration: time.Since(start), Err: err}
+	if src != nil && !src.Mode().IsDir() {
+		event.Size = src.Size()
+	}
+	o.observe(event)
+}
+
+// observedTransaction reports each operation of the underlying store's Transaction once its result is handled
+type observedTransaction struct {
+	Transaction
+	store *ObservedStore
+
+	recordsMu sync.Mutex
+	records   map[OpID]FileRecord // wrapped Get records, which replace the originals in Commit()
+}
+
+func (t *observedTransaction) Get(path string) OpID {
+	return t.GetHandler(path, OpHandlerFunc(func(Transaction, OpResult) error { return nil }))
+}
+
+func (t *observedTransaction) GetHandler(path string, handler OpHandler) OpID {
+	start := time.Now()
+	return t.Transaction.GetHandler(path, OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		result.Record = t.store.observeGet(path, start, result.Record, result.Err)
+		t.recordsMu.Lock()
+		t.records[result.Op] = result.Record
+		t.recordsMu.Unlock()
+		return handler.Handle(t, result)
+	}))
+}
+
+func (t *observedTransaction) Set(path string, src FileRecord, contents blob.Blob) OpID {
+	return t.SetHandler(path, src, contents, OpHandlerFunc(func(Transaction, OpResult) error { return nil }))
+}
+
+func (t *observedTransaction) SetHandler(path string, src FileRecord, contents blob.Blob, handler OpHandler) OpID {
+	start := time.Now()
+	src = wrapRecordOnce(src)
+	return t.Transaction.SetHandler(path, src, contents, OpHandlerFunc(func(_ Transaction, result OpResult) error {
+		t.store.observeSet(path, start, src, result.Err)
+		return handler.Handle(t, result)
+	}))
+}
+
+func (t *observedTransaction) Commit(ctx context.Context) ([]OpResult, error) {
+	results, err := t.Transaction.Commit(ctx)
+	t.recordsMu.Lock()
+	defer t.recordsMu.Unlock()
+	for i := range results {
+		if record, ok := t.records[results[i].Op]; ok {
+			results[i].Record = record
+		}
+	}
+	return results, err
+}
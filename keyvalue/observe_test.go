@@ -0,0 +1,94 @@
+package keyvalue_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+func TestObservedStore(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		store       keyvalue.Store
+	}{
+		{description: "store", store: newMapStore()},
+		{description: "transaction store", store: txnMapStore{newMapStore()}},
+		{description: "async store", store: &mapAsyncStore{mapStore: newMapStore()}},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			var mu sync.Mutex
+			var events []keyvalue.OpEvent
+			fs, err := keyvalue.NewFS(keyvalue.NewObservedStore(tc.store, func(event keyvalue.OpEvent) {
+				mu.Lock()
+				events = append(events, event)
+				mu.Unlock()
+			}))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+			mu.Lock()
+			events = nil
+			mu.Unlock()
+
+			info, err := fs.Stat("foo")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(3), info.Size())
+			_, err = fs.Stat("bar")
+			assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if assert.Equal(t, 2, len(events)) {
+				assert.Equal(t, keyvalue.OpGet, events[0].Op)
+				assert.Equal(t, "foo", events[0].Path)
+				assert.Equal(t, int64(3), events[0].Size)
+				assert.NoError(t, events[0].Err)
+				assert.Equal(t, "bar", events[1].Path)
+				assert.ErrorIs(t, hackpadfs.ErrNotExist, events[1].Err)
+			}
+		})
+	}
+}
+
+func TestObservedStoreOptionalInterfaces(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	noopObserve := func(keyvalue.OpEvent) {}
+
+	t.Run("forwards to supported interfaces", func(t *testing.T) {
+		t.Parallel()
+		store := keyvalue.NewObservedStore(mapKeysStore{newMapStore()}, noopObserve)
+		fs, err := keyvalue.NewFS(store)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		keys, err := store.Keys(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"."}, keys)
+		_, err = fs.GC(ctx, keyvalue.GCOptions{DryRun: true})
+		assert.NoError(t, err)
+	})
+
+	t.Run("unsupported interfaces are disabled", func(t *testing.T) {
+		t.Parallel()
+		store := keyvalue.NewObservedStore(newMapStore(), noopObserve)
+		fs, err := keyvalue.NewFS(store)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = store.Keys(ctx)
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+		_, err = fs.GC(ctx, keyvalue.GCOptions{DryRun: true})
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+		_, err = store.GetAsync(ctx, ".").Await(ctx)
+		assert.ErrorIs(t, hackpadfs.ErrNotImplemented, err)
+	})
+}
@@ -0,0 +1,81 @@
+package keyvalue
+
+import (
+	"context"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// SnapshotStore is a Store that can capture its records at a point in time, enabling consistent backups while the FS is in use.
+type SnapshotStore interface {
+	Store
+	// Snapshot returns a Store holding this store's records at the time of the call.
+	// Later changes to this store must not be visible in the snapshot. The snapshot is only read from.
+	Snapshot(ctx context.Context) (Store, error)
+}
+
+// Snapshot flushes any pending writes, then returns a read-only FS of the file system at this point in time.
+// Later changes to fs are not visible in the snapshot, and attempts to modify the snapshot fail with a permission error.
+//
+// Requires the FS's Store to implement SnapshotStore, fails with a not implemented error otherwise.
+func (fs *FS) Snapshot(ctx context.Context) (*FS, error) {
+	store, ok := storeAs[SnapshotStore](fs.store.store)
+	if !ok {
+		return nil, &hackpadfs.PathError{Op: "snapshot", Path: ".", Err: hackpadfs.ErrNotImplemented}
+	}
+	if err := fs.Flush(); err != nil {
+		return nil, err
+	}
+	snapshot, err := store.Snapshot(ctx)
+	if err != nil {
+		return nil, fs.wrapperErr("snapshot", ".", err)
+	}
+	if _, ok := storeAs[XattrStore](snapshot); ok {
+		return NewFS(readOnlyXattrStore{readOnlyStore{snapshot}})
+	}
+	return NewFS(readOnlyStore{snapshot})
+}
+
+// readOnlyStore rejects all changes to its Store
+type readOnlyStore struct {
+	Store
+}
+
+func (r readOnlyStore) Get(ctx context.Context, path string) (FileRecord, error) {
+	record, err := r.Store.Get(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return readOnlyRecord{record}, nil
+}
+
+func (r readOnlyStore) Set(context.Context, string, FileRecord) error {
+	return hackpadfs.ErrPermission
+}
+
+// readOnlyXattrStore is a readOnlyStore which can read extended attributes
+type readOnlyXattrStore struct {
+	readOnlyStore
+}
+
+func (r readOnlyXattrStore) GetXattrs(ctx context.Context, path string) (map[string][]byte, error) {
+	return r.Store.(XattrStore).GetXattrs(ctx, path)
+}
+
+func (r readOnlyXattrStore) SetXattrs(context.Context, string, map[string][]byte) error {
+	return hackpadfs.ErrPermission
+}
+
+// readOnlyRecord prevents in-place writes to the record's data, which would otherwise modify the snapshot before Set() is rejected
+type readOnlyRecord struct {
+	FileRecord
+}
+
+func (r readOnlyRecord) Data() (blob.Blob, error) {
+	data, err := r.FileRecord.Data()
+	if err != nil {
+		return nil, err
+	}
+	return blob.NewReadOnly(data), nil
+}
@@ -0,0 +1,21 @@
+package keyvalue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+func TestSnapshotNotImplemented(t *testing.T) {
+	t.Parallel()
+	fs, err := keyvalue.NewFS(newMapStore())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = fs.Snapshot(context.Background())
+	assert.Equal(t, true, errors.Is(err, hackpadfs.ErrNotImplemented))
+}
@@ -1,6 +1,10 @@
 package keyvalue
 
-import "context"
+import (
+	"context"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
 
 // Store holds arbitrary file data at the given 'path' location. Can be wrapped as a file system with keyvalue.NewFS().
 type Store interface {
@@ -11,3 +15,35 @@ type Store interface {
 	// Set assigns 'src' to the given 'path'. Returns an error if the data could not be set.
 	Set(ctx context.Context, path string, src FileRecord) error
 }
+
+// storeDecorator is a Store which wraps another Store, like CacheStore.
+// Decorators implement every optional Store interface and forward them to the wrapped store, returning hackpadfs.ErrNotImplemented if it does not support one.
+type storeDecorator interface {
+	decoratedStore() Store
+}
+
+// storeAs returns 'store' as a T if it supports T.
+// Decorators only support T if every store they wrap also implements T.
+func storeAs[T any](store Store) (T, bool) {
+	t, ok := store.(T)
+	for s := store; ok; {
+		decorator, isDecorator := s.(storeDecorator)
+		if !isDecorator {
+			break
+		}
+		s = decorator.decoratedStore()
+		_, ok = s.(T)
+	}
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return t, true
+}
+
+// BlobStore is a Store which chooses the Blob implementation for new files' contents, like a compressed Blob.
+type BlobStore interface {
+	Store
+	// NewBlob returns an empty Blob to hold a new file's contents.
+	NewBlob() blob.Blob
+}
@@ -0,0 +1,83 @@
+package keyvalue_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+// mapStore is a minimal keyvalue.Store. Directory entries are found by scanning for keys with the directory's prefix.
+type mapStore struct {
+	mu      sync.Mutex
+	records map[string]keyvalue.FileRecord
+}
+
+// skipMapStoreSnapshots is an fstest.FSOptions.ShouldSkip func for mapStore FSs.
+// mapStore records are snapshots, so open files don't see truncates by other files.
+func skipMapStoreSnapshots(facets fstest.Facets) bool {
+	return strings.HasSuffix(facets.Name, "/fs.OpenFile/truncate_with_open_reader") ||
+		strings.HasSuffix(facets.Name, "/file.Truncate/visible_to_other_open_files")
+}
+
+func newMapStore() *mapStore {
+	return &mapStore{records: make(map[string]keyvalue.FileRecord)}
+}
+
+func (s *mapStore) Get(_ context.Context, path string) (keyvalue.FileRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[path]
+	if !ok {
+		return nil, hackpadfs.ErrNotExist
+	}
+	return record, nil
+}
+
+func (s *mapStore) Set(_ context.Context, path string, src keyvalue.FileRecord) error {
+	if src == nil {
+		s.mu.Lock()
+		delete(s.records, path)
+		s.mu.Unlock()
+		return nil
+	}
+	var getData func() (blob.Blob, error)
+	var getDirNames func() ([]string, error)
+	if src.Mode().IsDir() {
+		getDirNames = func() ([]string, error) { return s.dirNames(path), nil }
+	} else {
+		data, err := src.Data()
+		if err != nil {
+			return err
+		}
+		buf := data.Bytes()
+		getData = func() (blob.Blob, error) {
+			return blob.NewBytes(append([]byte(nil), buf...)), nil // copy, so each Get's blob is independent
+		}
+	}
+	s.mu.Lock()
+	s.records[path] = keyvalue.NewBaseFileRecord(src.Size(), src.ModTime(), src.Mode(), nil, getData, getDirNames)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *mapStore) dirNames(dir string) []string {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for key := range s.records {
+		name := strings.TrimPrefix(key, prefix)
+		if key != "." && strings.HasPrefix(key, prefix) && !strings.ContainsRune(name, '/') {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,188 @@
+package keyvalue
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+)
+
+var (
+	_ interface {
+		hackpadfs.SymlinkFS
+		hackpadfs.LstatFS
+		hackpadfs.ReadlinkFS
+	} = &FS{}
+)
+
+const maxSymlinkHops = 40 // same limit as Linux's MAXSYMLINKS
+
+var errTooManyLinks = syscall.ELOOP
+
+// Symlink implements hackpadfs.SymlinkFS
+//
+// Symlinks are stored as records with hackpadfs.ModeSymlink set and their target as the record's data.
+func (fs *FS) Symlink(oldname, newname string) error {
+	linkErr := func(err error) error {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	if !hackpadfs.ValidPath(newname) {
+		return linkErr(hackpadfs.ErrInvalid)
+	}
+	linkPath, _, err := fs.resolve(newname, false)
+	switch {
+	case err == nil:
+		return linkErr(hackpadfs.ErrExist)
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return linkErr(err)
+	}
+	parent, err := fs.getFile(path.Dir(linkPath))
+	if err != nil {
+		return linkErr(err)
+	}
+	if !parent.Mode().IsDir() {
+		return linkErr(hackpadfs.ErrNotDir)
+	}
+	link := fs.newFile(linkPath, 0, hackpadfs.ModeSymlink|hackpadfs.ModePerm)
+	link.runOnceFileRecord.record = NewBaseFileRecord(int64(len(oldname)), time.Now(), link.Mode(), nil,
+		func() (blob.Blob, error) {
+			return blob.NewBytes([]byte(oldname)), nil
+		},
+		nil,
+	)
+	if err := link.save(); err != nil {
+		return linkErr(err)
+	}
+	return nil
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	_, file, err := fs.resolve(name, false)
+	if err != nil {
+		return "", fs.wrapperErr("readlink", name, err)
+	}
+	if !isSymlink(file.Mode()) {
+		return "", fs.wrapperErr("readlink", name, hackpadfs.ErrInvalid)
+	}
+	target, err := readLinkTarget(file)
+	return target, fs.wrapperErr("readlink", name, err)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	_, file, err := fs.resolve(name, false)
+	if err != nil {
+		return nil, fs.wrapperErr("lstat", name, err)
+	}
+	return fileInfo{Record: file.fileData, Path: name}, nil
+}
+
+func isSymlink(mode hackpadfs.FileMode) bool {
+	return mode&hackpadfs.ModeSymlink != 0
+}
+
+func readLinkTarget(record FileRecord) (string, error) {
+	data, err := record.Data()
+	if err != nil {
+		return "", err
+	}
+	return string(data.Bytes()), nil
+}
+
+// linkTargetPath returns the FS path for 'target' of a symlink inside 'linkDir'.
+// Absolute targets are relative to the FS root. Returns false if the target is outside the FS.
+func linkTargetPath(linkDir, target string) (string, bool) {
+	var targetPath string
+	if path.IsAbs(target) {
+		targetPath = strings.TrimPrefix(path.Clean(target), "/")
+		if targetPath == "" {
+			targetPath = "."
+		}
+	} else {
+		targetPath = path.Join(linkDir, target)
+	}
+	return targetPath, hackpadfs.ValidPath(targetPath)
+}
+
+// resolve follows symlinks in 'name' and returns the resolved path of its record, along with the file if it exists.
+// Symlinks in parent directories are always followed. The final path element is only followed if 'followLast' is set.
+// If the file does not exist, returns the resolved path and an error satisfying errors.Is(err, hackpadfs.ErrNotExist).
+//
+// Paths without symlinks only require a single Get, parent directories are only checked for symlinks if 'name' does not exist.
+func (fs *FS) resolve(name string, followLast bool) (string, *file, error) {
+	for hops := 0; hops <= maxSymlinkHops; hops++ {
+		file, err := fs.getFile(name)
+		switch {
+		case err == nil && followLast && isSymlink(file.Mode()):
+			target, err := readLinkTarget(file)
+			if err != nil {
+				return name, nil, err
+			}
+			targetPath, ok := linkTargetPath(path.Dir(name), target)
+			if !ok {
+				return name, nil, hackpadfs.ErrNotExist
+			}
+			name = targetPath
+		case err == nil:
+			return name, file, nil
+		case errors.Is(err, hackpadfs.ErrNotExist):
+			resolvedName, found, parentErr := fs.resolveParentLink(name)
+			if parentErr != nil {
+				return name, nil, parentErr
+			}
+			if !found {
+				return name, nil, err
+			}
+			name = resolvedName
+		default:
+			return name, nil, err
+		}
+	}
+	return name, nil, errTooManyLinks
+}
+
+// resolveParentLink replaces the first symlink in the parent directories of 'name' with its target.
+// Returns false if no parent directory is a symlink.
+func (fs *FS) resolveParentLink(name string) (string, bool, error) {
+	var parents []string // parents are in reverse order
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		parents = append(parents, dir)
+	}
+	if len(parents) == 0 {
+		return name, false, nil
+	}
+	results, err := getFileRecords(fs.store, parents)
+	if err != nil {
+		return name, false, err
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		parent, result := parents[i], results[i]
+		switch {
+		case errors.Is(result.Err, hackpadfs.ErrNotExist):
+			return name, false, nil
+		case result.Err != nil:
+			return name, false, result.Err
+		}
+		mode := result.Record.Mode()
+		switch {
+		case isSymlink(mode):
+			target, err := readLinkTarget(result.Record)
+			if err != nil {
+				return name, false, err
+			}
+			targetPath, ok := linkTargetPath(path.Dir(parent), target)
+			if !ok {
+				return name, false, nil
+			}
+			return path.Join(targetPath, strings.TrimPrefix(name, parent+"/")), true, nil
+		case !mode.IsDir():
+			return name, false, nil
+		}
+	}
+	return name, false, nil
+}
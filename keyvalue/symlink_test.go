@@ -0,0 +1,144 @@
+package keyvalue_test
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+)
+
+func makeSymlinkFS(t *testing.T) *keyvalue.FS {
+	t.Helper()
+	fs, err := keyvalue.NewFS(newMapStore())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, fs.MkdirAll("dir/sub", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/file", []byte("hello"), 0600))
+	return fs
+}
+
+func TestSymlink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file link", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		assert.NoError(t, fs.Symlink("dir/file", "link"))
+
+		contents, err := hackpadfs.ReadFile(fs, "link")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(contents))
+		info, err := fs.Stat("link")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "link", info.Name())
+			assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+		}
+		info, err = fs.Lstat("link")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "link", info.Name())
+			assert.Equal(t, hackpadfs.ModeSymlink, info.Mode().Type())
+		}
+		target, err := fs.Readlink("link")
+		assert.NoError(t, err)
+		assert.Equal(t, "dir/file", target)
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "link", []byte("world"), 0600))
+		contents, err = hackpadfs.ReadFile(fs, "dir/file")
+		assert.NoError(t, err)
+		assert.Equal(t, "world", string(contents))
+	})
+
+	t.Run("dir link", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		assert.NoError(t, fs.Symlink("/dir", "dir/sub/up"))
+
+		contents, err := hackpadfs.ReadFile(fs, "dir/sub/up/file")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(contents))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/sub/up/new", []byte("new"), 0600))
+		assert.NoError(t, fs.Mkdir("dir/sub/up/newdir", 0700))
+		assert.NoError(t, fs.MkdirAll("dir/sub/up/a/b", 0700))
+		for _, name := range []string{"dir/new", "dir/newdir", "dir/a/b"} {
+			_, err := fs.Lstat(name)
+			assert.NoError(t, err)
+		}
+
+		entries, err := hackpadfs.ReadDir(fs, "dir/sub")
+		if assert.NoError(t, err) && assert.Equal(t, 1, len(entries)) {
+			assert.Equal(t, "up", entries[0].Name())
+			assert.Equal(t, hackpadfs.ModeSymlink, entries[0].Type())
+		}
+	})
+
+	t.Run("relative link", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		assert.NoError(t, fs.Symlink("../file", "dir/sub/link"))
+		contents, err := hackpadfs.ReadFile(fs, "dir/sub/link")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(contents))
+	})
+
+	t.Run("remove and rename link", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		assert.NoError(t, fs.Symlink("dir", "link"))
+		assert.NoError(t, fs.Rename("link", "dir/sub/link"))
+		target, err := fs.Readlink("dir/sub/link")
+		assert.NoError(t, err)
+		assert.Equal(t, "dir", target)
+
+		assert.NoError(t, fs.Remove("dir/sub/link"))
+		_, err = fs.Lstat("dir/sub/link")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		_, err = fs.Stat("dir")
+		assert.NoError(t, err)
+	})
+
+	t.Run("dangling link", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		assert.NoError(t, fs.Symlink("missing", "link"))
+		_, err := fs.Stat("link")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		_, err = fs.Lstat("link")
+		assert.NoError(t, err)
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "link", []byte("created"), 0600))
+		contents, err := hackpadfs.ReadFile(fs, "missing")
+		assert.NoError(t, err)
+		assert.Equal(t, "created", string(contents))
+	})
+
+	t.Run("link loop", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		assert.NoError(t, fs.Symlink("b", "a"))
+		assert.NoError(t, fs.Symlink("a", "b"))
+		_, err := fs.Stat("a")
+		assert.Equal(t, true, errors.Is(err, syscall.ELOOP))
+		_, err = fs.Stat("a/file")
+		assert.Equal(t, true, errors.Is(err, syscall.ELOOP))
+	})
+
+	t.Run("link exists", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		err := fs.Symlink("dir", "dir/file")
+		assert.ErrorIs(t, hackpadfs.ErrExist, err)
+		err = fs.Symlink("dir", "missing/link")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("readlink not a link", func(t *testing.T) {
+		t.Parallel()
+		fs := makeSymlinkFS(t)
+		_, err := fs.Readlink("dir/file")
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	})
+}
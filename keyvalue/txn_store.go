@@ -26,6 +26,9 @@ const (
 // TransactionOptions contain options used to construct a Transaction from a Store
 type TransactionOptions struct {
 	Mode TransactionMode
+	// Durable asks the store to persist changes to stable storage before Commit returns, if the store otherwise relaxes durability for speed.
+	// Set when writing files opened with hackpadfs.FlagSync.
+	Durable bool
 }
 
 // OpID is a unique ID within the transaction that generated it. It's used to correlate which Get/Set operation produced which result.
@@ -71,24 +74,36 @@ type unsafeSerialTransaction struct {
 	store     Store
 	resultsMu sync.Mutex
 	results   map[OpID]OpResult
+	lastOps   map[string]<-chan struct{} // done channels for the latest AsyncStore operation on each path
+	pending   sync.WaitGroup
 }
 
 // TransactionOrSerial attempts to produce a Transaction from 'store'.
 // If unsupported, returns an unsafe transaction instead, which runs each action serially without transactional safety.
+// If 'store' is an AsyncStore, the unsafe transaction instead starts each action concurrently and waits for them in Commit().
 //
 // This is used in FS to attempt transactions whenever possible.
 // Since some Stores don't need transactions, they aren't required to implement TransactionStore.
 func TransactionOrSerial(store Store, options TransactionOptions) (Transaction, error) {
+	return transactionOrSerial(context.Background(), store, options)
+}
+
+func transactionOrSerial(ctx context.Context, store Store, options TransactionOptions) (Transaction, error) {
 	if store, ok := store.(TransactionStore); ok {
 		return store.Transaction(options)
 	}
-	ctx, cancel := context.WithCancel(context.Background())
+	return newSerialTransaction(ctx, store), nil
+}
+
+func newSerialTransaction(ctx context.Context, store Store) *unsafeSerialTransaction {
+	ctx, cancel := context.WithCancel(ctx)
 	return &unsafeSerialTransaction{
 		ctx:     ctx,
 		abort:   cancel,
 		store:   store,
 		results: make(map[OpID]OpResult),
-	}, nil
+		lastOps: make(map[string]<-chan struct{}),
+	}
 }
 
 func (u *unsafeSerialTransaction) newOp() OpID {
@@ -102,6 +117,65 @@ func (u *unsafeSerialTransaction) setResult(op OpID, result OpResult) {
 	u.resultsMu.Unlock()
 }
 
+func (u *unsafeSerialTransaction) handleResult(handler OpHandler, result OpResult) {
+	err := handler.Handle(u, result)
+	if result.Err == nil && err != nil {
+		result.Err = err
+	}
+	u.setResult(result.Op, result)
+}
+
+// runAsync starts an AsyncStore operation once earlier operations on the same path complete, then handles its result.
+func (u *unsafeSerialTransaction) runAsync(op OpID, path string, handler OpHandler, start func() *Future) {
+	done := make(chan struct{})
+	u.resultsMu.Lock()
+	prevDone := u.lastOps[path]
+	u.lastOps[path] = done
+	u.resultsMu.Unlock()
+
+	u.pending.Add(1)
+	go func() {
+		defer u.pending.Done()
+		defer close(done)
+		if prevDone != nil {
+			select {
+			case <-prevDone:
+			case <-u.ctx.Done():
+				u.setResult(op, OpResult{Op: op, Err: u.ctx.Err()})
+				return
+			}
+		}
+		// the store cancels the operation with u.ctx, so wait for it to stop instead of leaving it running after Commit()
+		record, err := start().Await(context.Background())
+		u.handleResult(handler, OpResult{Op: op, Record: record, Err: err})
+	}()
+}
+
+// awaitPending blocks until all AsyncStore operations complete.
+// If either context is canceled first, cancels the remaining operations and waits for them to stop.
+func (u *unsafeSerialTransaction) awaitPending(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	done := make(chan struct{})
+	go func() {
+		u.pending.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-u.ctx.Done():
+		err = u.ctx.Err()
+	}
+	u.abort()
+	<-done
+	return err
+}
+
 func abortErr(ctx, extraCtx context.Context) error {
 	if extraCtx == nil {
 		extraCtx = context.Background()
@@ -129,13 +203,14 @@ func (u *unsafeSerialTransaction) GetHandler(path string, handler OpHandler) OpI
 		return op
 	}
 
-	record, err := u.store.Get(u.ctx, path)
-	result := OpResult{Op: op, Record: record, Err: err}
-	err = handler.Handle(u, result)
-	if result.Err == nil && err != nil {
-		result.Err = err
+	if store, ok := storeAs[AsyncStore](u.store); ok {
+		u.runAsync(op, path, handler, func() *Future {
+			return store.GetAsync(u.ctx, path)
+		})
+		return op
 	}
-	u.setResult(op, result)
+	record, err := u.store.Get(u.ctx, path)
+	u.handleResult(handler, OpResult{Op: op, Record: record, Err: err})
 	return op
 }
 
@@ -152,21 +227,26 @@ func (u *unsafeSerialTransaction) SetHandler(path string, src FileRecord, _ blob
 		return op
 	}
 
-	err := u.store.Set(u.ctx, path, src)
-	result := OpResult{Op: op, Err: err}
-	err = handler.Handle(u, result)
-	if result.Err == nil && err != nil {
-		result.Err = err
+	if store, ok := storeAs[AsyncStore](u.store); ok {
+		u.runAsync(op, path, handler, func() *Future {
+			return store.SetAsync(u.ctx, path, src)
+		})
+		return op
 	}
-	u.setResult(op, result)
+	err := u.store.Set(u.ctx, path, src)
+	u.handleResult(handler, OpResult{Op: op, Err: err})
 	return op
 }
 
 func (u *unsafeSerialTransaction) Commit(ctx context.Context) ([]OpResult, error) {
-	if err := abortErr(u.ctx, ctx); err != nil {
-		return nil, err
+	err := u.awaitPending(ctx)
+	if err == nil {
+		err = abortErr(u.ctx, ctx)
 	}
 	u.abort()
+	if err != nil {
+		return nil, err
+	}
 	opCount := atomic.LoadInt64((*int64)(&u.nextOp))
 	results := make([]OpResult, opCount)
 	u.resultsMu.Lock()
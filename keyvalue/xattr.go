@@ -0,0 +1,136 @@
+package keyvalue
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var _ hackpadfs.XattrFS = &FS{}
+
+// XattrStore is a Store that can hold extended attributes for its records. Enables FS's implementation of hackpadfs.XattrFS.
+// Attributes belong to the record at their path: Set must keep them when replacing the record, and removing the record removes them.
+// If the store is also a MoveStore, Move must move them along with the record.
+type XattrStore interface {
+	Store
+	// GetXattrs returns all extended attributes for the record at 'path'. Returns an empty map if none are set.
+	// If the path was not found, the error must satisfy errors.Is(err, hackpadfs.ErrNotExist).
+	GetXattrs(ctx context.Context, path string) (map[string][]byte, error)
+	// SetXattrs replaces all extended attributes for the record at 'path' with 'attrs'.
+	// If the path was not found, the error must satisfy errors.Is(err, hackpadfs.ErrNotExist).
+	SetXattrs(ctx context.Context, path string, attrs map[string][]byte) error
+}
+
+// GetXattr implements hackpadfs.XattrFS
+func (fs *FS) GetXattr(name, attr string) ([]byte, error) {
+	attrs, err := fs.getXattrs("getxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := attrs[attr]
+	if !ok {
+		return nil, &hackpadfs.PathError{Op: "getxattr", Path: name, Err: hackpadfs.ErrNoAttr}
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// SetXattr implements hackpadfs.XattrFS
+func (fs *FS) SetXattr(name, attr string, value []byte) error {
+	if len(value) > hackpadfs.XattrSizeMax {
+		return &hackpadfs.PathError{Op: "setxattr", Path: name, Err: hackpadfs.ErrTooLarge}
+	}
+	return fs.updateXattrs("setxattr", name, attr, func(attrs map[string][]byte) error {
+		attrs[attr] = append([]byte(nil), value...)
+		return nil
+	})
+}
+
+// ListXattr implements hackpadfs.XattrFS
+func (fs *FS) ListXattr(name string) ([]string, error) {
+	attrs, err := fs.getXattrs("listxattr", name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(attrs))
+	for attr := range attrs {
+		names = append(names, attr)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RemoveXattr implements hackpadfs.XattrFS
+func (fs *FS) RemoveXattr(name, attr string) error {
+	return fs.updateXattrs("removexattr", name, attr, func(attrs map[string][]byte) error {
+		if _, ok := attrs[attr]; !ok {
+			return hackpadfs.ErrNoAttr
+		}
+		delete(attrs, attr)
+		return nil
+	})
+}
+
+// xattrStore returns the FS's store as an XattrStore, or a not implemented error for 'op'
+func (fs *FS) xattrStore(op, name string) (XattrStore, error) {
+	store, ok := storeAs[XattrStore](fs.store.store)
+	if !ok {
+		return nil, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	return store, nil
+}
+
+// getXattrs returns all attributes on 'name', following symlinks
+func (fs *FS) getXattrs(op, name string) (map[string][]byte, error) {
+	store, err := fs.xattrStore(op, name)
+	if err != nil {
+		return nil, err
+	}
+	resolvedName, _, err := fs.resolve(name, true)
+	if err != nil {
+		return nil, fs.wrapperErr(op, name, err)
+	}
+	attrs, err := store.GetXattrs(fs.store.ctx, resolvedName)
+	return attrs, fs.wrapperErr(op, name, err)
+}
+
+// updateXattrs applies 'fn' to the attributes on 'name', following symlinks, then stores the result
+func (fs *FS) updateXattrs(op, name, attr string, fn func(attrs map[string][]byte) error) error {
+	store, err := fs.xattrStore(op, name)
+	if err != nil {
+		return err
+	}
+	if attr == "" {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	resolvedName, _, err := fs.resolve(name, true)
+	if err != nil {
+		return fs.wrapperErr(op, name, err)
+	}
+	fs.xattrMu.Lock()
+	defer fs.xattrMu.Unlock()
+	attrs, err := store.GetXattrs(fs.store.ctx, resolvedName)
+	if err == nil {
+		err = fn(attrs)
+	}
+	if err == nil {
+		err = store.SetXattrs(fs.store.ctx, resolvedName, attrs)
+	}
+	return fs.wrapperErr(op, name, err)
+}
+
+// renameXattrs reads the attributes on 'oldname' and returns a func which assigns them to 'newname'.
+// Call the func after the record moves, since assigning them also removes attributes of any file 'newname' replaced.
+func (fs *FS) renameXattrs(oldname, newname string) (func() error, error) {
+	store, ok := storeAs[XattrStore](fs.store.store)
+	if !ok {
+		return func() error { return nil }, nil
+	}
+	attrs, err := store.GetXattrs(fs.store.ctx, oldname)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		return store.SetXattrs(fs.store.ctx, newname, attrs)
+	}, nil
+}
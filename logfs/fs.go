@@ -0,0 +1,450 @@
+//go:build go1.21
+// +build go1.21
+
+// Package logfs contains a file system wrapper which logs every operation with log/slog.
+package logfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+// logMessage is the message of every logged operation. Operation details are logged as attributes.
+const logMessage = "hackpadfs"
+
+// Options configures logging for an FS
+type Options struct {
+	// Logger receives a record for every operation. Defaults to slog.Default().
+	Logger *slog.Logger
+	// Level is the level of successful operations. Defaults to slog.LevelDebug.
+	Level slog.Leveler
+	// ErrorLevel is the level of failed operations. Defaults to Level.
+	ErrorLevel slog.Leveler
+	// RedactPath rewrites each path before it is logged, like hiding user names. Paths inside PathError and LinkError errors are rewritten too. Paths are logged unchanged if nil.
+	RedactPath func(path string) string
+}
+
+// FS wraps a file system to log every operation, including operations on open files.
+// Each record contains the operation ("op"), its path ("path", or "old" and "new" for renames and links), how long it took ("duration"), and the error if it failed ("error").
+// OpenFile also logs its flags ("flag").
+type FS struct {
+	fs      hackpadfs.FS
+	options Options
+}
+
+// NewFS returns an FS which logs every operation on 'fs'
+func NewFS(fs hackpadfs.FS, options Options) *FS {
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelDebug
+	}
+	if options.ErrorLevel == nil {
+		options.ErrorLevel = options.Level
+	}
+	return &FS{
+		fs:      fs,
+		options: options,
+	}
+}
+
+// log records operation 'op' started at 'start', which failed if 'err' is not nil. Reaching io.EOF is not a failure.
+func (fs *FS) log(op string, start time.Time, err error, attrs ...slog.Attr) {
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	level := fs.options.Level.Level()
+	if err != nil {
+		level = fs.options.ErrorLevel.Level()
+	}
+	ctx := context.Background()
+	if !fs.options.Logger.Enabled(ctx, level) {
+		return
+	}
+	attrs = append([]slog.Attr{slog.String("op", op)}, attrs...)
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", fs.redactErr(err)))
+	}
+	fs.options.Logger.LogAttrs(ctx, level, logMessage, attrs...)
+}
+
+func (fs *FS) redactPath(name string) string {
+	if fs.options.RedactPath == nil {
+		return name
+	}
+	return fs.options.RedactPath(name)
+}
+
+func (fs *FS) pathAttr(key, name string) slog.Attr {
+	return slog.String(key, fs.redactPath(name))
+}
+
+func (fs *FS) redactErr(err error) error {
+	if fs.options.RedactPath == nil {
+		return err
+	}
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		return &hackpadfs.PathError{Op: pathErr.Op, Path: fs.redactPath(pathErr.Path), Err: pathErr.Err}
+	}
+	var linkErr *hackpadfs.LinkError
+	if errors.As(err, &linkErr) {
+		return &hackpadfs.LinkError{Op: linkErr.Op, Old: fs.redactPath(linkErr.Old), New: fs.redactPath(linkErr.New), Err: linkErr.Err}
+	}
+	return err
+}
+
+// flagString formats OpenFile flags like "O_RDWR|O_CREATE"
+func flagString(flag int) string {
+	var names []string
+	switch {
+	case flag&hackpadfs.FlagReadWrite != 0:
+		names = append(names, "O_RDWR")
+	case flag&hackpadfs.FlagWriteOnly != 0:
+		names = append(names, "O_WRONLY")
+	default:
+		names = append(names, "O_RDONLY")
+	}
+	for _, f := range []struct {
+		flag int
+		name string
+	}{
+		{hackpadfs.FlagAppend, "O_APPEND"},
+		{hackpadfs.FlagCreate, "O_CREATE"},
+		{hackpadfs.FlagExclusive, "O_EXCL"},
+		{hackpadfs.FlagSync, "O_SYNC"},
+		{hackpadfs.FlagTruncate, "O_TRUNC"},
+	} {
+		if flag&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	start := time.Now()
+	f, err := fs.fs.Open(name)
+	fs.log("open", start, err, fs.pathAttr("path", name))
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, name: name, fs: fs}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	start := time.Now()
+	f, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	fs.log("openfile", start, err, fs.pathAttr("path", name), slog.String("flag", flagString(flag)))
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, name: name, fs: fs}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.Mkdir(fs.fs, name, perm)
+	fs.log("mkdir", start, err, fs.pathAttr("path", name))
+	return err
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.MkdirAll(fs.fs, path, perm)
+	fs.log("mkdirall", start, err, fs.pathAttr("path", path))
+	return err
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	start := time.Now()
+	err := hackpadfs.Remove(fs.fs, name)
+	fs.log("remove", start, err, fs.pathAttr("path", name))
+	return err
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	start := time.Now()
+	err := hackpadfs.RemoveAll(fs.fs, path)
+	fs.log("removeall", start, err, fs.pathAttr("path", path))
+	return err
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	start := time.Now()
+	err := hackpadfs.Rename(fs.fs, oldname, newname)
+	fs.log("rename", start, err, fs.pathAttr("old", oldname), fs.pathAttr("new", newname))
+	return err
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	start := time.Now()
+	info, err := hackpadfs.Stat(fs.fs, name)
+	fs.log("stat", start, err, fs.pathAttr("path", name))
+	return info, err
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	start := time.Now()
+	info, err := hackpadfs.Lstat(fs.fs, name)
+	fs.log("lstat", start, err, fs.pathAttr("path", name))
+	return info, err
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.Chmod(fs.fs, name, mode)
+	fs.log("chmod", start, err, fs.pathAttr("path", name))
+	return err
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	start := time.Now()
+	err := hackpadfs.Chown(fs.fs, name, uid, gid)
+	fs.log("chown", start, err, fs.pathAttr("path", name))
+	return err
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	start := time.Now()
+	err := hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+	fs.log("chtimes", start, err, fs.pathAttr("path", name))
+	return err
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	start := time.Now()
+	entries, err := hackpadfs.ReadDir(fs.fs, name)
+	fs.log("readdir", start, err, fs.pathAttr("path", name))
+	return entries, err
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	start := time.Now()
+	data, err := hackpadfs.ReadFile(fs.fs, name)
+	fs.log("readfile", start, err, fs.pathAttr("path", name))
+	return data, err
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := hackpadfs.Symlink(fs.fs, oldname, newname)
+	fs.log("symlink", start, err, fs.pathAttr("old", oldname), fs.pathAttr("new", newname))
+	return err
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	start := time.Now()
+	err := hackpadfs.Link(fs.fs, oldname, newname)
+	fs.log("link", start, err, fs.pathAttr("old", oldname), fs.pathAttr("new", newname))
+	return err
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	start := time.Now()
+	target, err := hackpadfs.Readlink(fs.fs, name)
+	fs.log("readlink", start, err, fs.pathAttr("path", name))
+	return target, err
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	start := time.Now()
+	unlocker, err := hackpadfs.Lock(fs.fs, name, mode)
+	fs.log("lock", start, err, fs.pathAttr("path", name))
+	return unlocker, err
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	start := time.Now()
+	usage, err := hackpadfs.Statfs(fs.fs, name)
+	fs.log("statfs", start, err, fs.pathAttr("path", name))
+	return usage, err
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	start := time.Now()
+	watcher, err := hackpadfs.Watch(fs.fs, name)
+	fs.log("watch", start, err, fs.pathAttr("path", name))
+	return watcher, err
+}
+
+// file logs every operation on an open file
+type file struct {
+	hackpadfs.File
+	name string
+	fs   *FS
+}
+
+func (f *file) log(op string, start time.Time, err error) {
+	f.fs.log(op, start, err, f.fs.pathAttr("path", f.name))
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(p)
+	f.log("read", start, err)
+	return n, err
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := hackpadfs.WriteFile(f.File, p)
+	f.log("write", start, err)
+	return n, err
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := hackpadfs.ReadAtFile(f.File, p, off)
+	f.log("readat", start, err)
+	return n, err
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := hackpadfs.WriteAtFile(f.File, p, off)
+	f.log("writeat", start, err)
+	return n, err
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	start := time.Now()
+	entries, err := hackpadfs.ReadDirFile(f.File, n)
+	f.log("readdir", start, err)
+	return entries, err
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	start := time.Now()
+	n, err := hackpadfs.SeekFile(f.File, offset, whence)
+	f.log("seek", start, err)
+	return n, err
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	start := time.Now()
+	err := hackpadfs.SyncFile(f.File)
+	f.log("sync", start, err)
+	return err
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	start := time.Now()
+	err := hackpadfs.TruncateFile(f.File, size)
+	f.log("truncate", start, err)
+	return err
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.ChmodFile(f.File, mode)
+	f.log("chmod", start, err)
+	return err
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	start := time.Now()
+	err := hackpadfs.ChownFile(f.File, uid, gid)
+	f.log("chown", start, err)
+	return err
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	start := time.Now()
+	err := hackpadfs.ChtimesFile(f.File, atime, mtime)
+	f.log("chtimes", start, err)
+	return err
+}
+
+// Stat implements hackpadfs.File
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	start := time.Now()
+	info, err := f.File.Stat()
+	f.log("stat", start, err)
+	return info, err
+}
+
+// Close implements hackpadfs.File
+func (f *file) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	f.log("close", start, err)
+	return err
+}
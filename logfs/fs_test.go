@@ -0,0 +1,149 @@
+//go:build go1.21
+// +build go1.21
+
+package logfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "logfs",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			memFS, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			return NewFS(memFS, Options{Logger: logger})
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+type record struct {
+	Level string
+	Op    string
+	Path  string
+	Old   string
+	New   string
+	Flag  string
+	Error string
+}
+
+func newLoggedFS(t *testing.T, options Options) (*FS, func() []record) {
+	t.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	options.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return NewFS(memFS, options), func() []record {
+		var records []record
+		decoder := json.NewDecoder(&buf)
+		for decoder.More() {
+			var r record
+			assert.NoError(t, decoder.Decode(&r))
+			records = append(records, r)
+		}
+		return records
+	}
+}
+
+func TestLog(t *testing.T) {
+	t.Parallel()
+	fs, records := newLoggedFS(t, Options{ErrorLevel: slog.LevelWarn})
+
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0600)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("foo"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.NoError(t, hackpadfs.Rename(fs, "foo", "bar"))
+	_, err = hackpadfs.Stat(fs, "foo")
+	assert.Error(t, err)
+
+	assert.Equal(t, []record{
+		{Level: "DEBUG", Op: "openfile", Path: "foo", Flag: "O_WRONLY|O_CREATE|O_TRUNC"},
+		{Level: "DEBUG", Op: "write", Path: "foo"},
+		{Level: "DEBUG", Op: "close", Path: "foo"},
+		{Level: "DEBUG", Op: "rename", Old: "foo", New: "bar"},
+		{Level: "WARN", Op: "stat", Path: "foo", Error: "stat foo: file does not exist"},
+	}, records())
+}
+
+func TestLogReadEOF(t *testing.T) {
+	t.Parallel()
+	fs, records := newLoggedFS(t, Options{ErrorLevel: slog.LevelWarn})
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	_ = records()
+
+	_, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	_, err = io.ReadAll(f)
+	assert.NoError(t, err)
+	_, err = f.Read(make([]byte, 10))
+	assert.ErrorIs(t, io.EOF, err)
+	assert.NoError(t, f.Close())
+
+	for _, r := range records() {
+		assert.Equal(t, "DEBUG", r.Level)
+		assert.Equal(t, "", r.Error)
+	}
+}
+
+func TestLogRedactPath(t *testing.T) {
+	t.Parallel()
+	fs, records := newLoggedFS(t, Options{
+		RedactPath: func(path string) string {
+			return strings.Replace(path, "secret", "xxx", 1)
+		},
+	})
+
+	_, err := hackpadfs.Stat(fs, "secret/foo")
+	assert.Error(t, err)
+	err = hackpadfs.Symlink(fs, "secret/foo", "secret/bar")
+	assert.Error(t, err)
+
+	logs := records()
+	if assert.Equal(t, 2, len(logs)) {
+		assert.Equal(t, "xxx/foo", logs[0].Path)
+		assert.Equal(t, "stat xxx/foo: file does not exist", logs[0].Error)
+		assert.Equal(t, "xxx/foo", logs[1].Old)
+		assert.Equal(t, "xxx/bar", logs[1].New)
+		assert.NotContains(t, logs[1].Error, "secret")
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	fs := NewFS(memFS, Options{Logger: logger})
+
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	assert.Equal(t, "", buf.String())
+	assert.Error(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	assert.Equal(t, "", buf.String())
+
+	fs = NewFS(memFS, Options{Logger: logger, Level: slog.LevelInfo})
+	assert.NoError(t, hackpadfs.Mkdir(fs, "bar", 0700))
+	assert.Contains(t, buf.String(), "op=mkdir path=bar")
+}
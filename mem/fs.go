@@ -2,6 +2,7 @@
 package mem
 
 import (
+	"context"
 	"time"
 
 	"github.com/hack-pad/hackpadfs"
@@ -13,9 +14,21 @@ type FS struct {
 	kv *keyvalue.FS
 }
 
+// Options provides configuration options for a new FS.
+type Options struct {
+	// Compress holds file contents compressed in memory, trading CPU time for lower memory use with compressible files.
+	// Reads and writes only decompress the parts of a file they touch.
+	Compress bool
+}
+
 // NewFS returns a new FS.
 func NewFS() (*FS, error) {
-	kv, err := keyvalue.NewFS(newStore())
+	return NewFSWithOptions(Options{})
+}
+
+// NewFSWithOptions returns a new FS configured with 'options'.
+func NewFSWithOptions(options Options) (*FS, error) {
+	kv, err := keyvalue.NewFS(newStore(options))
 	return &FS{kv}, err
 }
 
@@ -44,6 +57,11 @@ func (fs *FS) Remove(name string) error {
 	return fs.kv.Remove(name)
 }
 
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	return fs.kv.RemoveAll(name)
+}
+
 // Rename implements hackpadfs.RenameFS
 func (fs *FS) Rename(oldname, newname string) error {
 	return fs.kv.Rename(oldname, newname)
@@ -63,3 +81,63 @@ func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
 func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
 	return fs.kv.Chtimes(name, atime, mtime)
 }
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return fs.kv.Symlink(oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return fs.kv.Readlink(name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return fs.kv.Lstat(name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return fs.kv.Lock(name, mode)
+}
+
+// SetExpiration sets the time file 'name' expires, after which it no longer exists. A zero 'expiresAt' removes the expiration.
+func (fs *FS) SetExpiration(name string, expiresAt time.Time) error {
+	return fs.kv.SetExpiration(name, expiresAt)
+}
+
+// PurgeExpired removes all expired files and returns their paths. Expired files are otherwise removed when next accessed.
+func (fs *FS) PurgeExpired(ctx context.Context) ([]string, error) {
+	return fs.kv.PurgeExpired(ctx)
+}
+
+// Snapshot returns a read-only copy of the file system at this point in time.
+// Later changes to fs are not visible in the snapshot.
+func (fs *FS) Snapshot(ctx context.Context) (*FS, error) {
+	kv, err := fs.kv.Snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &FS{kv}, nil
+}
+
+// GetXattr implements hackpadfs.XattrFS
+func (fs *FS) GetXattr(name, attr string) ([]byte, error) {
+	return fs.kv.GetXattr(name, attr)
+}
+
+// SetXattr implements hackpadfs.XattrFS
+func (fs *FS) SetXattr(name, attr string, value []byte) error {
+	return fs.kv.SetXattr(name, attr, value)
+}
+
+// ListXattr implements hackpadfs.XattrFS
+func (fs *FS) ListXattr(name string) ([]string, error) {
+	return fs.kv.ListXattr(name)
+}
+
+// RemoveXattr implements hackpadfs.XattrFS
+func (fs *FS) RemoveXattr(name, attr string) error {
+	return fs.kv.RemoveXattr(name, attr)
+}
@@ -1,14 +1,28 @@
 package mem
 
 import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/keyvalue"
 )
 
+//go:embed testdata
+var testdata embed.FS
+
 func TestFS(t *testing.T) {
 	t.Parallel()
+	exportFS, err := NewFS()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
 	options := fstest.FSOptions{
 		Name: "mem",
 		TestFS: func(tb testing.TB) fstest.SetupFS {
@@ -18,7 +32,186 @@ func TestFS(t *testing.T) {
 			}
 			return fs
 		},
+		Export:      fstest.ExportOptions{FS: exportFS},
+		Timeout:     time.Minute,
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	data := fstest.FS(t, options)
+	fileData := fstest.File(t, options)
+	fstest.AssertGoldenSkips(t, data, fstest.GoldenSkipsOptions{FS: testdata, Path: "testdata/mem_FS_skips.json"})
+	fstest.AssertGoldenSkips(t, fileData, fstest.GoldenSkipsOptions{FS: testdata, Path: "testdata/mem_File_skips.json"})
+
+	contents, err := hackpadfs.ReadFile(exportFS, "mem_FS.json")
+	assert.NoError(t, err)
+	var exported fstest.TestData
+	assert.NoError(t, json.Unmarshal(contents, &exported))
+	assert.Equal(t, data, exported)
+	assert.NotZero(t, len(exported.Results))
+	_, err = hackpadfs.Stat(exportFS, "mem_File.json")
+	assert.NoError(t, err)
+}
+
+func TestMigrations(t *testing.T) {
+	t.Parallel()
+	var migrated []string
+	registry := keyvalue.NewMigrationRegistry()
+	assert.NoError(t, registry.Register(keyvalue.Migration{
+		Version: 1,
+		Migrate: func(context.Context, keyvalue.Store) error {
+			migrated = append(migrated, "v1")
+			return nil
+		},
+	}))
+
+	store := newStore(Options{})
+	_, err := keyvalue.NewFSWithOptions(store, keyvalue.FSOptions{Migrations: registry})
+	assert.NoError(t, err)
+	assert.Equal(t, []string(nil), migrated)
+	version, err := store.SchemaVersion(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	oldStore := newStore(Options{})
+	fs, err := keyvalue.NewFS(oldStore)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	_, err = keyvalue.NewFSWithOptions(oldStore, keyvalue.FSOptions{Migrations: registry})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, migrated)
+	version, err = oldStore.SchemaVersion(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, version)
+}
+
+func TestFSCompressed(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "mem compressed",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := NewFSWithOptions(Options{Compress: true})
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
 }
+
+// annotatedFS wraps Stat errors, like a network FS adding request details
+type annotatedFS struct {
+	*FS
+}
+
+func (fs *annotatedFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	info, err := fs.FS.Stat(name)
+	if err != nil {
+		err = fmt.Errorf("annotated: %w", err)
+	}
+	return info, err
+}
+
+func TestFSMatchErr(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "mem annotated",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return &annotatedFS{fs}
+		},
+		Constraints: fstest.Constraints{
+			AllowUnenforcedPermissions: true,
+			MatchErr:                   fstest.MatchErrorIs,
+		},
+	}
+	fstest.FS(t, options)
+}
+
+func BenchmarkFS(b *testing.B) {
+	fstest.Benchmark(b, fstest.FSOptions{
+		Name: "mem",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return fs
+		},
+	}, fstest.BenchmarkOptions{})
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFS()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, fs.MkdirAll("foo/bar", 0700))
+	f, err := hackpadfs.OpenFile(fs, "foo/baz", hackpadfs.FlagReadWrite|hackpadfs.FlagCreate, 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = hackpadfs.WriteFile(f, []byte("before"))
+	assert.NoError(t, err)
+	assert.NoError(t, fs.SetXattr("foo/baz", "user.foo", []byte("before")))
+
+	snapshot, err := fs.Snapshot(context.Background())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = hackpadfs.WriteAtFile(f, []byte("AFTER!"), 0)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+	assert.NoError(t, fs.SetXattr("foo/baz", "user.foo", []byte("AFTER!")))
+	assert.NoError(t, fs.Remove("foo/bar"))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "new", nil, 0600))
+
+	contents, err := hackpadfs.ReadFile(snapshot, "foo/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "before", string(contents))
+	entries, err := hackpadfs.ReadDir(snapshot, "foo")
+	if assert.NoError(t, err) && assert.Equal(t, 2, len(entries)) {
+		assert.Equal(t, "bar", entries[0].Name())
+		assert.Equal(t, "baz", entries[1].Name())
+	}
+	_, err = snapshot.Stat("new")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	value, err := snapshot.GetXattr("foo/baz", "user.foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "before", string(value))
+
+	err = hackpadfs.WriteFullFile(snapshot, "foo/baz", []byte("change"), 0600)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = snapshot.SetXattr("foo/baz", "user.foo", []byte("change"))
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	contents, err = hackpadfs.ReadFile(snapshot, "foo/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "before", string(contents))
+	contents, err = hackpadfs.ReadFile(fs, "foo/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "AFTER!", string(contents))
+}
+
+func TestExpiration(t *testing.T) {
+	t.Parallel()
+	fs, err := NewFS()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+	assert.NoError(t, fs.SetExpiration("foo", time.Now().Add(-time.Second)))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar", []byte("bar"), 0600))
+	assert.NoError(t, fs.SetExpiration("bar", time.Now().Add(-time.Second)))
+
+	_, err = fs.Stat("foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	purged, err := fs.PurgeExpired(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bar"}, purged)
+}
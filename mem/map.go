@@ -0,0 +1,113 @@
+package mem
+
+import (
+	"path"
+	"sort"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const (
+	defaultMapFilePerm = 0666
+	defaultMapDirPerm  = 0777
+)
+
+// MapFile describes a file, directory, or symlink for NewMapFS, like testing/fstest.MapFile.
+type MapFile struct {
+	// Data holds a file's contents, or a symlink's target
+	Data []byte
+	// Mode is the file's mode. Set hackpadfs.ModeDir or hackpadfs.ModeSymlink to create a directory or symlink.
+	// If Mode has no permission bits, files default to 0666 and directories to 0777.
+	Mode hackpadfs.FileMode
+	// ModTime is the file's modification time. If zero, it's the time the file was created.
+	ModTime time.Time
+}
+
+// NewMapFS returns a new writable FS containing 'files', keyed by path. A nil MapFile creates an empty file.
+// Parent directories are created automatically, like they are implied by testing/fstest.MapFS.
+// A "." key sets the root directory's mode and modification time.
+func NewMapFS(files map[string]*MapFile) (*FS, error) {
+	fs, err := NewFS()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := createMapFile(fs, name, files[name]); err != nil {
+			return nil, err
+		}
+	}
+	// set modes and times once all files exist, so creating children does not change their directories
+	for i := len(names) - 1; i >= 0; i-- {
+		name := names[i]
+		if err := setMapFileAttrs(fs, name, files[name]); err != nil {
+			return nil, err
+		}
+	}
+	return fs, nil
+}
+
+// NewStringMapFS returns a new writable FS containing files with the contents of 'files', keyed by path.
+// Files have mode 0666, and parent directories are created automatically.
+func NewStringMapFS(files map[string]string) (*FS, error) {
+	mapFiles := make(map[string]*MapFile, len(files))
+	for name, contents := range files {
+		mapFiles[name] = &MapFile{Data: []byte(contents)}
+	}
+	return NewMapFS(mapFiles)
+}
+
+func createMapFile(fs *FS, name string, file *MapFile) error {
+	if file == nil {
+		file = &MapFile{}
+	}
+	mode := file.Mode
+	switch {
+	case !hackpadfs.ValidPath(name),
+		mode.Type()&^(hackpadfs.ModeDir|hackpadfs.ModeSymlink) != 0,
+		name == "." && !mode.IsDir():
+		return &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	case mode.IsDir():
+		return fs.MkdirAll(name, defaultMapDirPerm)
+	}
+	if err := fs.MkdirAll(path.Dir(name), defaultMapDirPerm); err != nil {
+		return err
+	}
+	if mode&hackpadfs.ModeSymlink != 0 {
+		return fs.Symlink(string(file.Data), name)
+	}
+	f, err := fs.OpenFile(name, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, defaultMapFilePerm)
+	if err != nil {
+		return err
+	}
+	_, err = hackpadfs.WriteFile(f, file.Data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func setMapFileAttrs(fs *FS, name string, file *MapFile) error {
+	if file == nil {
+		return nil
+	}
+	if file.Mode&hackpadfs.ModeSymlink != 0 {
+		// Chmod and Chtimes would change the symlink's target
+		return nil
+	}
+	if perm := file.Mode &^ hackpadfs.ModeType; perm != 0 {
+		if err := fs.Chmod(name, perm); err != nil {
+			return err
+		}
+	}
+	if !file.ModTime.IsZero() {
+		return fs.Chtimes(name, file.ModTime, file.ModTime)
+	}
+	return nil
+}
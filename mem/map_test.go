@@ -0,0 +1,130 @@
+package mem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+func TestNewMapFS(t *testing.T) {
+	t.Parallel()
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fs, err := NewMapFS(map[string]*MapFile{
+		".":           {Mode: hackpadfs.ModeDir | 0700, ModTime: modTime},
+		"foo/bar":     {Data: []byte("bar"), Mode: 0600, ModTime: modTime},
+		"foo/baz":     nil,
+		"foo":         {Mode: hackpadfs.ModeDir | 0750, ModTime: modTime},
+		"empty/dir":   {Mode: hackpadfs.ModeDir},
+		"link":        {Data: []byte("foo/bar"), Mode: hackpadfs.ModeSymlink},
+		"default/fil": {Data: []byte("defaults")},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for _, tc := range []struct {
+		name       string
+		expectMode hackpadfs.FileMode
+		expectTime time.Time
+	}{
+		{name: ".", expectMode: hackpadfs.ModeDir | 0700, expectTime: modTime},
+		{name: "foo", expectMode: hackpadfs.ModeDir | 0750, expectTime: modTime},
+		{name: "foo/bar", expectMode: 0600, expectTime: modTime},
+		{name: "foo/baz", expectMode: 0666},
+		{name: "empty", expectMode: hackpadfs.ModeDir | 0777},
+		{name: "empty/dir", expectMode: hackpadfs.ModeDir | 0777},
+		{name: "default/fil", expectMode: 0666},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			info, err := hackpadfs.Stat(fs, tc.name)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectMode, info.Mode())
+			if !tc.expectTime.IsZero() {
+				assert.Equal(t, tc.expectTime, info.ModTime().UTC())
+			}
+		})
+	}
+
+	data, err := hackpadfs.ReadFile(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+	data, err = hackpadfs.ReadFile(fs, "foo/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(data))
+	target, err := hackpadfs.Readlink(fs, "link")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo/bar", target)
+}
+
+func TestNewMapFSInvalid(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		description string
+		files       map[string]*MapFile
+		expectErr   error
+	}{
+		{
+			description: "invalid path",
+			files:       map[string]*MapFile{"/foo": {}},
+			expectErr:   &hackpadfs.PathError{Op: "open", Path: "/foo", Err: hackpadfs.ErrInvalid},
+		},
+		{
+			description: "root is not a directory",
+			files:       map[string]*MapFile{".": {}},
+			expectErr:   &hackpadfs.PathError{Op: "open", Path: ".", Err: hackpadfs.ErrInvalid},
+		},
+		{
+			description: "unsupported file type",
+			files:       map[string]*MapFile{"foo": {Mode: hackpadfs.ModeNamedPipe}},
+			expectErr:   &hackpadfs.PathError{Op: "open", Path: "foo", Err: hackpadfs.ErrInvalid},
+		},
+	} {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewMapFS(tc.files)
+			assert.Equal(t, tc.expectErr, err)
+		})
+	}
+
+	_, err := NewMapFS(map[string]*MapFile{
+		"foo":     {},
+		"foo/bar": {},
+	})
+	assert.ErrorIs(t, hackpadfs.ErrNotDir, err)
+}
+
+func TestNewStringMapFS(t *testing.T) {
+	t.Parallel()
+	fs, err := NewStringMapFS(map[string]string{
+		"foo/bar": "bar",
+		"baz":     "baz",
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	data, err := hackpadfs.ReadFile(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", string(data))
+	info, err := hackpadfs.Stat(fs, "baz")
+	if assert.NoError(t, err) {
+		assert.Equal(t, hackpadfs.FileMode(0666), info.Mode())
+	}
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	// fixtures are fully writable
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("baz"), 0600))
+	data, err = hackpadfs.ReadFile(fs, "foo/bar")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(data))
+	assert.NoError(t, hackpadfs.Remove(fs, "baz"))
+	assert.NoError(t, hackpadfs.Rename(fs, "foo", "qux"))
+}
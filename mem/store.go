@@ -2,7 +2,6 @@ package mem
 
 import (
 	"context"
-	"strings"
 	"sync"
 	"time"
 
@@ -11,23 +10,48 @@ import (
 	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
 
-var _ keyvalue.TransactionStore = &store{}
+var (
+	_ keyvalue.TransactionStore = &store{}
+	_ keyvalue.KeysStore        = &store{}
+	_ keyvalue.SchemaStore      = &store{}
+	_ keyvalue.LockStore        = &store{}
+	_ keyvalue.SnapshotStore    = &store{}
+	_ keyvalue.MoveStore        = &store{}
+	_ keyvalue.MultiGetStore    = &store{}
+	_ keyvalue.ExpiringStore    = &store{}
+	_ keyvalue.BlobStore        = &store{}
+	_ keyvalue.XattrStore       = &store{}
+)
 
 type store struct {
-	mu      sync.Mutex
-	records sync.Map
+	mu       sync.Mutex
+	records  sync.Map
+	dirIndex *keyvalue.DirIndex
+	compress bool
+
+	schemaMu      sync.Mutex
+	schemaVersion int
+
+	locksMu sync.Mutex
+	locks   map[string]keyvalue.LockRecord
 }
 
-func newStore() *store {
-	return &store{}
+func newStore(options Options) *store {
+	return &store{
+		dirIndex: keyvalue.NewDirIndex(),
+		compress: options.Compress,
+		locks:    make(map[string]keyvalue.LockRecord),
+	}
 }
 
 type fileRecord struct {
-	store   *store
-	path    string
-	data    blob.Blob
-	mode    hackpadfs.FileMode
-	modTime time.Time
+	store     *store
+	path      string
+	data      blob.Blob
+	mode      hackpadfs.FileMode
+	modTime   time.Time
+	expiresAt time.Time
+	xattrs    map[string][]byte // replaced on every change, so records and snapshots can share it
 }
 
 func (f fileRecord) Data() (blob.Blob, error) {
@@ -38,29 +62,13 @@ func (f fileRecord) Size() int64              { return int64(f.data.Len()) }
 func (f fileRecord) Mode() hackpadfs.FileMode { return f.mode }
 func (f fileRecord) ModTime() time.Time       { return f.modTime }
 func (f fileRecord) Sys() interface{}         { return nil }
+func (f fileRecord) ExpiresAt() time.Time     { return f.expiresAt }
 
 func (f fileRecord) ReadDirNames() ([]string, error) {
 	if !f.mode.IsDir() {
 		return nil, hackpadfs.ErrNotDir
 	}
-	var names []string
-	prefix := f.path + "/"
-	isRoot := f.path == "."
-	if isRoot {
-		prefix = ""
-	}
-
-	f.store.records.Range(func(key, _ interface{}) bool {
-		p := key.(string)
-		if strings.HasPrefix(p, prefix) {
-			p = strings.TrimPrefix(p, prefix)
-			if !strings.ContainsRune(p, '/') && !(isRoot && p == ".") {
-				names = append(names, p)
-			}
-		}
-		return true
-	})
-	return names, nil
+	return f.store.dirIndex.Names(f.path), nil
 }
 
 func (s *store) Get(_ context.Context, path string) (keyvalue.FileRecord, error) {
@@ -72,6 +80,27 @@ func (s *store) Get(_ context.Context, path string) (keyvalue.FileRecord, error)
 	return record, nil
 }
 
+// GetMany reads all paths while holding the same lock as writes and transactions, so the results come from one consistent state.
+func (s *store) GetMany(ctx context.Context, paths []string) ([]keyvalue.OpResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := make([]keyvalue.OpResult, len(paths))
+	for i, path := range paths {
+		record, err := s.Get(ctx, path)
+		results[i] = keyvalue.OpResult{Op: keyvalue.OpID(i), Record: record, Err: err}
+	}
+	return results, nil
+}
+
+func (s *store) Keys(_ context.Context) ([]string, error) {
+	var keys []string
+	s.records.Range(func(key, _ interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	return keys, nil
+}
+
 func (s *store) Set(_ context.Context, path string, src keyvalue.FileRecord) error {
 	var contents blob.Blob
 	if src != nil {
@@ -81,17 +110,26 @@ func (s *store) Set(_ context.Context, path string, src keyvalue.FileRecord) err
 			return err
 		}
 	}
+	s.mu.Lock() // serialize with transactions, so records and the directory index change together
+	defer s.mu.Unlock()
 	return s.set(path, src, contents)
 }
 
+// set assigns 'src' to 'path'. Must be called with s.mu held.
+// Index entries are added after the record is stored and removed before it is deleted, so indexed names always resolve.
 func (s *store) set(path string, src keyvalue.FileRecord, _ blob.Blob) error {
 	if src == nil {
+		s.dirIndex.Remove(path)
 		s.records.Delete(path)
 	} else {
 		data, err := src.Data()
 		if err != nil {
 			return err
 		}
+		data, err = s.storedBlob(data)
+		if err != nil {
+			return err
+		}
 		record := fileRecord{
 			store:   s,
 			path:    path,
@@ -99,11 +137,141 @@ func (s *store) set(path string, src keyvalue.FileRecord, _ blob.Blob) error {
 			mode:    src.Mode(),
 			modTime: src.ModTime(),
 		}
+		if value, ok := s.records.Load(path); ok {
+			record.expiresAt = value.(fileRecord).expiresAt
+			record.xattrs = value.(fileRecord).xattrs
+		}
 		s.records.Store(path, record)
+		s.dirIndex.Add(path)
 	}
 	return nil
 }
 
+func (s *store) SchemaVersion(_ context.Context) (int, error) {
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+	return s.schemaVersion, nil
+}
+
+func (s *store) SetSchemaVersion(_ context.Context, version int) error {
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+	s.schemaVersion = version
+	return nil
+}
+
+func (s *store) SetExpiration(_ context.Context, path string, expiresAt time.Time) error {
+	s.mu.Lock() // wait for any running transaction
+	defer s.mu.Unlock()
+	value, ok := s.records.Load(path)
+	if !ok {
+		return hackpadfs.ErrNotExist
+	}
+	record := value.(fileRecord)
+	record.expiresAt = expiresAt
+	s.records.Store(path, record)
+	return nil
+}
+
+func (s *store) GetXattrs(_ context.Context, path string) (map[string][]byte, error) {
+	value, ok := s.records.Load(path)
+	if !ok {
+		return nil, hackpadfs.ErrNotExist
+	}
+	attrs := make(map[string][]byte, len(value.(fileRecord).xattrs))
+	for attr, attrValue := range value.(fileRecord).xattrs {
+		attrs[attr] = attrValue
+	}
+	return attrs, nil
+}
+
+func (s *store) SetXattrs(_ context.Context, path string, attrs map[string][]byte) error {
+	s.mu.Lock() // wait for any running transaction
+	defer s.mu.Unlock()
+	value, ok := s.records.Load(path)
+	if !ok {
+		return hackpadfs.ErrNotExist
+	}
+	record := value.(fileRecord)
+	record.xattrs = make(map[string][]byte, len(attrs))
+	for attr, attrValue := range attrs {
+		record.xattrs[attr] = append([]byte(nil), attrValue...)
+	}
+	s.records.Store(path, record)
+	return nil
+}
+
+func (s *store) NewBlob() blob.Blob {
+	if s.compress {
+		compressed, _ := blob.NewCompressed(blob.NewBytes(nil), 0) // an empty blob can not fail to compress
+		return compressed
+	}
+	return blob.NewBytes(nil)
+}
+
+// storedBlob returns the Blob to store for 'data'. Compresses 'data' if enabled and not already compressed.
+func (s *store) storedBlob(data blob.Blob) (blob.Blob, error) {
+	if !s.compress || data == nil {
+		return data, nil
+	}
+	if _, ok := data.(*blob.Compressed); ok {
+		return data, nil
+	}
+	return blob.NewCompressed(data, 0)
+}
+
+func (s *store) Move(_ context.Context, oldPath, newPath string) error {
+	s.mu.Lock() // wait for any running transaction
+	defer s.mu.Unlock()
+	value, ok := s.records.Load(oldPath)
+	if !ok {
+		return hackpadfs.ErrNotExist
+	}
+	record := value.(fileRecord)
+	record.path = newPath
+	s.records.Store(newPath, record)
+	s.dirIndex.Add(newPath)
+	s.records.Delete(oldPath)
+	s.dirIndex.Remove(oldPath)
+	return nil
+}
+
+// Snapshot copies all records into a new store.
+// Contents are cloned rather than shared, since open files modify their data in place. A clone copies its data on the first write to either side.
+func (s *store) Snapshot(_ context.Context) (keyvalue.Store, error) {
+	s.mu.Lock() // wait for any running transaction
+	defer s.mu.Unlock()
+	snapshot := newStore(Options{Compress: s.compress})
+	s.records.Range(func(key, value interface{}) bool {
+		path, record := key.(string), value.(fileRecord)
+		record.store = snapshot
+		if record.data != nil {
+			record.data, _ = snapshot.storedBlob(blob.Clone(record.data)) // compressing in-memory bytes can not fail
+		}
+		snapshot.records.Store(path, record)
+		snapshot.dirIndex.Add(path)
+		return true
+	})
+	return snapshot, nil
+}
+
+func (s *store) GetLock(_ context.Context, path string) (keyvalue.LockRecord, error) {
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	return s.locks[path], nil
+}
+
+func (s *store) CompareAndSwapLock(_ context.Context, path string, revision uint64, record keyvalue.LockRecord) (bool, error) {
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	if s.locks[path].Revision != revision {
+		return false, nil
+	}
+	record.Revision = revision + 1
+	s.locks[path] = record
+	return true, nil
+}
+
 type transaction struct {
 	ctx     context.Context
 	abort   context.CancelFunc
@@ -0,0 +1,72 @@
+package mem
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+const benchmarkDirEntries = 100_000
+
+func makeLargeDir(b *testing.B) *FS {
+	b.Helper()
+	fs, err := NewFS()
+	if !assert.NoError(b, err) {
+		b.FailNow()
+	}
+	assert.NoError(b, fs.Mkdir("dir", 0700))
+	for i := 0; i < benchmarkDirEntries; i++ {
+		f, err := hackpadfs.Create(fs, fmt.Sprintf("dir/%d", i))
+		if !assert.NoError(b, err) {
+			b.FailNow()
+		}
+		assert.NoError(b, f.Close())
+	}
+	return fs
+}
+
+func BenchmarkReadDirLargeDir(b *testing.B) {
+	fs := makeLargeDir(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		entries, err := hackpadfs.ReadDir(fs, "dir")
+		if err != nil || len(entries) != benchmarkDirEntries {
+			b.Fatal("unexpected ReadDir result:", len(entries), err)
+		}
+	}
+}
+
+func BenchmarkCreateLargeDir(b *testing.B) {
+	fs := makeLargeDir(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f, err := hackpadfs.Create(fs, fmt.Sprintf("dir/new-%d", i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = f.Close()
+	}
+}
+
+func BenchmarkRenameLargeFile(b *testing.B) {
+	const fileSize = 1 << 30
+	fs, err := NewFS()
+	if !assert.NoError(b, err) {
+		b.FailNow()
+	}
+	f, err := hackpadfs.Create(fs, "file-0")
+	if !assert.NoError(b, err) {
+		b.FailNow()
+	}
+	assert.NoError(b, hackpadfs.TruncateFile(f, fileSize))
+	assert.NoError(b, f.Close())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := fs.Rename(fmt.Sprintf("file-%d", i), fmt.Sprintf("file-%d", i+1))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
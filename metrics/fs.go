@@ -0,0 +1,341 @@
+// Package metrics contains a file system wrapper which records operation counts, errors, bytes transferred, and latency.
+package metrics
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+// FS wraps a file system to record Metrics for every operation, including operations on open files
+type FS struct {
+	fs      hackpadfs.FS
+	metrics *Metrics
+}
+
+// NewFS returns an FS which records operations on 'fs' into 'metrics'. Metrics may be shared by multiple FS's.
+func NewFS(fs hackpadfs.FS, metrics *Metrics) *FS {
+	return &FS{
+		fs:      fs,
+		metrics: metrics,
+	}
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	start := time.Now()
+	f, err := fs.fs.Open(name)
+	fs.metrics.record("open", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, metrics: fs.metrics}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	start := time.Now()
+	f, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	fs.metrics.record("openfile", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, metrics: fs.metrics}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.Mkdir(fs.fs, name, perm)
+	fs.metrics.record("mkdir", start, err)
+	return err
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.MkdirAll(fs.fs, path, perm)
+	fs.metrics.record("mkdirall", start, err)
+	return err
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	start := time.Now()
+	err := hackpadfs.Remove(fs.fs, name)
+	fs.metrics.record("remove", start, err)
+	return err
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	start := time.Now()
+	err := hackpadfs.RemoveAll(fs.fs, path)
+	fs.metrics.record("removeall", start, err)
+	return err
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	start := time.Now()
+	err := hackpadfs.Rename(fs.fs, oldname, newname)
+	fs.metrics.record("rename", start, err)
+	return err
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	start := time.Now()
+	info, err := hackpadfs.Stat(fs.fs, name)
+	fs.metrics.record("stat", start, err)
+	return info, err
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	start := time.Now()
+	info, err := hackpadfs.Lstat(fs.fs, name)
+	fs.metrics.record("lstat", start, err)
+	return info, err
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.Chmod(fs.fs, name, mode)
+	fs.metrics.record("chmod", start, err)
+	return err
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	start := time.Now()
+	err := hackpadfs.Chown(fs.fs, name, uid, gid)
+	fs.metrics.record("chown", start, err)
+	return err
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	start := time.Now()
+	err := hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+	fs.metrics.record("chtimes", start, err)
+	return err
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	start := time.Now()
+	entries, err := hackpadfs.ReadDir(fs.fs, name)
+	fs.metrics.record("readdir", start, err)
+	return entries, err
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	start := time.Now()
+	data, err := hackpadfs.ReadFile(fs.fs, name)
+	fs.metrics.record("readfile", start, err)
+	fs.metrics.addBytesRead(len(data))
+	return data, err
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	start := time.Now()
+	err := hackpadfs.Symlink(fs.fs, oldname, newname)
+	fs.metrics.record("symlink", start, err)
+	return err
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	start := time.Now()
+	err := hackpadfs.Link(fs.fs, oldname, newname)
+	fs.metrics.record("link", start, err)
+	return err
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	start := time.Now()
+	target, err := hackpadfs.Readlink(fs.fs, name)
+	fs.metrics.record("readlink", start, err)
+	return target, err
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	start := time.Now()
+	unlocker, err := hackpadfs.Lock(fs.fs, name, mode)
+	fs.metrics.record("lock", start, err)
+	return unlocker, err
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	start := time.Now()
+	usage, err := hackpadfs.Statfs(fs.fs, name)
+	fs.metrics.record("statfs", start, err)
+	return usage, err
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	start := time.Now()
+	watcher, err := hackpadfs.Watch(fs.fs, name)
+	fs.metrics.record("watch", start, err)
+	return watcher, err
+}
+
+// file records metrics for every operation on an open file
+type file struct {
+	hackpadfs.File
+	metrics *Metrics
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := f.File.Read(p)
+	f.metrics.record("read", start, err)
+	f.metrics.addBytesRead(n)
+	return n, err
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := hackpadfs.WriteFile(f.File, p)
+	f.metrics.record("write", start, err)
+	f.metrics.addBytesWritten(n)
+	return n, err
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := hackpadfs.ReadAtFile(f.File, p, off)
+	f.metrics.record("readat", start, err)
+	f.metrics.addBytesRead(n)
+	return n, err
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	start := time.Now()
+	n, err := hackpadfs.WriteAtFile(f.File, p, off)
+	f.metrics.record("writeat", start, err)
+	f.metrics.addBytesWritten(n)
+	return n, err
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	start := time.Now()
+	entries, err := hackpadfs.ReadDirFile(f.File, n)
+	f.metrics.record("readdir", start, err)
+	return entries, err
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	start := time.Now()
+	n, err := hackpadfs.SeekFile(f.File, offset, whence)
+	f.metrics.record("seek", start, err)
+	return n, err
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	start := time.Now()
+	err := hackpadfs.SyncFile(f.File)
+	f.metrics.record("sync", start, err)
+	return err
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	start := time.Now()
+	err := hackpadfs.TruncateFile(f.File, size)
+	f.metrics.record("truncate", start, err)
+	return err
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	start := time.Now()
+	err := hackpadfs.ChmodFile(f.File, mode)
+	f.metrics.record("chmod", start, err)
+	return err
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	start := time.Now()
+	err := hackpadfs.ChownFile(f.File, uid, gid)
+	f.metrics.record("chown", start, err)
+	return err
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	start := time.Now()
+	err := hackpadfs.ChtimesFile(f.File, atime, mtime)
+	f.metrics.record("chtimes", start, err)
+	return err
+}
+
+// Stat implements hackpadfs.File
+func (f *file) Stat() (hackpadfs.FileInfo, error) {
+	start := time.Now()
+	info, err := f.File.Stat()
+	f.metrics.record("stat", start, err)
+	return info, err
+}
+
+// Close implements hackpadfs.File
+func (f *file) Close() error {
+	start := time.Now()
+	err := f.File.Close()
+	f.metrics.record("close", start, err)
+	return err
+}
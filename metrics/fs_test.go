@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+var _ expvar.Var = &Metrics{}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "metrics",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			memFS, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return NewFS(memFS, NewMetrics())
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func newFS(t *testing.T) (*FS, *Metrics) {
+	t.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	metrics := NewMetrics()
+	return NewFS(memFS, metrics), metrics
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	fs, metrics := newFS(t)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("hello"), 0600))
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	b := make([]byte, 5)
+	_, err = io.ReadFull(f, b)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(b))
+	_, err = f.Read(b)
+	assert.ErrorIs(t, io.EOF, err)
+	assert.NoError(t, f.Close())
+	_, err = hackpadfs.Stat(fs, "bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	err = hackpadfs.Mkdir(fs, "foo", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrExist, err)
+
+	snapshot := metrics.Snapshot()
+	assert.Equal(t, int64(5), snapshot.BytesRead)
+	assert.Equal(t, int64(5), snapshot.BytesWritten)
+	assert.Equal(t, map[string]int64{
+		"exist":     1,
+		"not_exist": 1,
+	}, snapshot.Errors)
+
+	counts := make(map[string]int64)
+	errCounts := make(map[string]int64)
+	for op, stats := range snapshot.Ops {
+		counts[op] = stats.Count
+		errCounts[op] = stats.Errors
+	}
+	assert.Equal(t, map[string]int64{
+		"openfile": 1,
+		"open":     1,
+		"read":     2, // the last read reaches EOF, which is not an error
+		"write":    1,
+		"close":    2,
+		"stat":     1,
+		"mkdir":    1,
+	}, counts)
+	assert.Equal(t, map[string]int64{
+		"openfile": 0,
+		"open":     0,
+		"read":     0,
+		"write":    0,
+		"close":    0,
+		"stat":     1,
+		"mkdir":    1,
+	}, errCounts)
+}
+
+func TestMetricsHistogram(t *testing.T) {
+	t.Parallel()
+	fs, metrics := newFS(t)
+	for i := 0; i < 3; i++ {
+		_, err := hackpadfs.Stat(fs, ".")
+		assert.NoError(t, err)
+	}
+
+	latency := metrics.Snapshot().Ops["stat"].Latency
+	assert.Equal(t, int64(3), latency.Count)
+	assert.Equal(t, len(latencyBuckets)+1, len(latency.Buckets))
+	last := latency.Buckets[len(latency.Buckets)-1]
+	assert.Equal(t, Bucket{UpperBound: 0, Count: 3}, last)
+	var prev int64
+	for i, bucket := range latency.Buckets[:len(latencyBuckets)] {
+		assert.Equal(t, latencyBuckets[i], bucket.UpperBound)
+		if bucket.Count < prev {
+			t.Errorf("Bucket counts must be cumulative: %v", latency.Buckets)
+		}
+		prev = bucket.Count
+	}
+}
+
+func TestMetricsString(t *testing.T) {
+	t.Parallel()
+	fs, metrics := newFS(t)
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("hello"), 0600))
+
+	var snapshot Snapshot
+	assert.NoError(t, json.Unmarshal([]byte(metrics.String()), &snapshot))
+	assert.Equal(t, metrics.Snapshot(), snapshot)
+}
+
+func TestErrorClass(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		err   error
+		class string
+	}{
+		{&hackpadfs.PathError{Op: "open", Path: "foo", Err: hackpadfs.ErrNotExist}, "not_exist"},
+		{hackpadfs.ErrPermission, "permission"},
+		{hackpadfs.ErrNoSpace, "no_space"},
+		{io.ErrUnexpectedEOF, "other"},
+	} {
+		assert.Equal(t, tc.class, ErrorClass(tc.err))
+	}
+}
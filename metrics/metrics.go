@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// latencyBuckets are the upper bounds of latency histogram buckets
+var latencyBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// errorClasses maps errors to their class in Snapshot.Errors, checked in order
+var errorClasses = []struct {
+	err   error
+	class string
+}{
+	{hackpadfs.ErrNotExist, "not_exist"},
+	{hackpadfs.ErrExist, "exist"},
+	{hackpadfs.ErrPermission, "permission"},
+	{hackpadfs.ErrNotDir, "not_dir"},
+	{hackpadfs.ErrIsDir, "is_dir"},
+	{hackpadfs.ErrNotEmpty, "not_empty"},
+	{hackpadfs.ErrInvalid, "invalid"},
+	{hackpadfs.ErrClosed, "closed"},
+	{hackpadfs.ErrNotImplemented, "not_implemented"},
+	{hackpadfs.ErrWouldBlock, "would_block"},
+	{hackpadfs.ErrTooLarge, "too_large"},
+	{hackpadfs.ErrNoSpace, "no_space"},
+	{hackpadfs.ErrCrossDevice, "cross_device"},
+}
+
+// otherErrorClass is the class of errors not in errorClasses
+const otherErrorClass = "other"
+
+// ErrorClass returns the class 'err' is counted under in Snapshot.Errors, like "not_exist" for hackpadfs.ErrNotExist.
+// Errors which do not match a hackpadfs error are classed as "other".
+func ErrorClass(err error) string {
+	for _, c := range errorClasses {
+		if errors.Is(err, c.err) {
+			return c.class
+		}
+	}
+	return otherErrorClass
+}
+
+// Metrics counts operations on one or more FS's. Safe for concurrent use.
+//
+// Metrics implements expvar.Var, so it can be published directly with expvar.Publish().
+// To export to another monitoring system, like Prometheus, collect from Snapshot().
+type Metrics struct {
+	mu           sync.Mutex
+	ops          map[string]*opStats
+	errors       map[string]int64
+	bytesRead    int64
+	bytesWritten int64
+}
+
+type opStats struct {
+	count   int64
+	errors  int64
+	buckets []int64 // non-cumulative count per latencyBuckets bound, plus one for larger latencies
+	sum     time.Duration
+}
+
+// NewMetrics returns empty Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		ops:    make(map[string]*opStats),
+		errors: make(map[string]int64),
+	}
+}
+
+// record counts operation 'op' started at 'start', which failed if 'err' is not nil. Reaching io.EOF is not a failure.
+func (m *Metrics) record(op string, start time.Time, err error) {
+	latency := time.Since(start)
+	failed := err != nil && !errors.Is(err, io.EOF)
+	var class string
+	if failed {
+		class = ErrorClass(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.ops[op]
+	if !ok {
+		stats = &opStats{buckets: make([]int64, len(latencyBuckets)+1)}
+		m.ops[op] = stats
+	}
+	stats.count++
+	stats.sum += latency
+	bucket := len(latencyBuckets)
+	for i, bound := range latencyBuckets {
+		if latency <= bound {
+			bucket = i
+			break
+		}
+	}
+	stats.buckets[bucket]++
+	if failed {
+		stats.errors++
+		m.errors[class]++
+	}
+}
+
+func (m *Metrics) addBytesRead(n int) {
+	m.mu.Lock()
+	m.bytesRead += int64(n)
+	m.mu.Unlock()
+}
+
+func (m *Metrics) addBytesWritten(n int) {
+	m.mu.Lock()
+	m.bytesWritten += int64(n)
+	m.mu.Unlock()
+}
+
+// Snapshot is a point-in-time copy of Metrics
+type Snapshot struct {
+	// Ops contains stats for each operation type, like "open" or "write"
+	Ops map[string]OpSnapshot `json:"ops"`
+	// Errors counts failed operations by error class. See ErrorClass().
+	Errors map[string]int64 `json:"errors"`
+	// BytesRead is the total bytes read from files
+	BytesRead int64 `json:"bytes_read"`
+	// BytesWritten is the total bytes written to files
+	BytesWritten int64 `json:"bytes_written"`
+}
+
+// OpSnapshot contains stats for one operation type
+type OpSnapshot struct {
+	// Count is the number of operations, including failures
+	Count int64 `json:"count"`
+	// Errors is the number of failed operations
+	Errors int64 `json:"errors"`
+	// Latency is a histogram of operation durations
+	Latency Histogram `json:"latency"`
+}
+
+// Histogram is a latency distribution in the style of a Prometheus histogram
+type Histogram struct {
+	// Buckets are in increasing order of UpperBound. Counts are cumulative, so the last bucket's count equals Count.
+	Buckets []Bucket `json:"buckets"`
+	// Sum is the total duration of all observations
+	Sum time.Duration `json:"sum"`
+	// Count is the number of observations
+	Count int64 `json:"count"`
+}
+
+// Bucket counts observations at or below UpperBound. The last bucket has no bound, signified by a zero UpperBound.
+type Bucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      int64         `json:"count"`
+}
+
+// Snapshot returns a copy of the current metrics
+func (m *Metrics) Snapshot() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := Snapshot{
+		Ops:          make(map[string]OpSnapshot, len(m.ops)),
+		Errors:       make(map[string]int64, len(m.errors)),
+		BytesRead:    m.bytesRead,
+		BytesWritten: m.bytesWritten,
+	}
+	for op, stats := range m.ops {
+		buckets := make([]Bucket, len(stats.buckets))
+		var cumulative int64
+		for i, count := range stats.buckets {
+			cumulative += count
+			buckets[i].Count = cumulative
+			if i < len(latencyBuckets) {
+				buckets[i].UpperBound = latencyBuckets[i]
+			}
+		}
+		snapshot.Ops[op] = OpSnapshot{
+			Count:  stats.count,
+			Errors: stats.errors,
+			Latency: Histogram{
+				Buckets: buckets,
+				Sum:     stats.sum,
+				Count:   stats.count,
+			},
+		}
+	}
+	for class, count := range m.errors {
+		snapshot.Errors[class] = count
+	}
+	return snapshot
+}
+
+// String implements expvar.Var. Returns Snapshot() encoded as JSON.
+func (m *Metrics) String() string {
+	b, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
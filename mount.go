@@ -1,27 +1,106 @@
 package hackpadfs
 
-import "strings"
+import (
+	"path"
+	"strings"
+	"sync"
+)
 
 func stripErrPathPrefix(err error, name, mountSubPath string) error {
 	if err == nil {
 		return err
 	}
-	prefix := strings.TrimSuffix(mountSubPath, name)
 	switch err := err.(type) {
 	case *PathError:
 		return &PathError{
 			Op:   err.Op,
-			Path: strings.TrimPrefix(err.Path, prefix),
+			Path: rewriteMountPath(err.Path, name, mountSubPath),
 			Err:  err.Err,
 		}
 	case *LinkError:
 		return &LinkError{
 			Op:  err.Op,
-			Old: strings.TrimPrefix(err.Old, prefix),
-			New: strings.TrimPrefix(err.New, prefix),
+			Old: rewriteMountPath(err.Old, name, mountSubPath),
+			New: rewriteMountPath(err.New, name, mountSubPath),
 			Err: err.Err,
 		}
 	default:
 		return err
 	}
 }
+
+// rewriteMountPath converts 'p', a path relative to the mounted FS, into a path relative to the mounting FS.
+// 'name' is the path in the mounting FS that resolved to 'mountSubPath' in the mounted FS.
+func rewriteMountPath(p, name, mountSubPath string) string {
+	// trim the path elements 'name' and 'mountSubPath' share, leaving the prefix each FS adds
+	outer, inner := name, mountSubPath
+	for outer != "." && inner != "." && path.Base(outer) == path.Base(inner) {
+		outer, inner = path.Dir(outer), path.Dir(inner)
+	}
+	if outer == "." && inner == "." {
+		return p
+	}
+	var rest string
+	switch {
+	case inner == ".":
+		rest = p
+	case p == inner:
+		rest = "."
+	case strings.HasPrefix(p, inner+"/"):
+		rest = p[len(inner)+1:]
+	default:
+		return p
+	}
+	// concatenate without cleaning, so invalid paths are reported as given
+	switch {
+	case outer == ".":
+		return rest
+	case rest == ".":
+		return outer
+	default:
+		return outer + "/" + rest
+	}
+}
+
+// mountWatcher restores event names from a mounted FS's Watcher to the mounting FS's paths
+type mountWatcher struct {
+	watcher   Watcher
+	events    chan WatchEvent
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newMountWatcher(watcher Watcher, name, mountSubPath string) *mountWatcher {
+	mountPoint := name
+	if mountSubPath != "." {
+		mountPoint = strings.TrimSuffix(strings.TrimSuffix(name, mountSubPath), "/")
+	}
+	w := &mountWatcher{
+		watcher: watcher,
+		events:  make(chan WatchEvent),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(w.events)
+		for event := range watcher.Events() {
+			event.Name = path.Join(mountPoint, event.Name)
+			select {
+			case w.events <- event:
+			case <-w.done:
+				return
+			}
+		}
+	}()
+	return w
+}
+
+func (w *mountWatcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+func (w *mountWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+	return w.watcher.Close()
+}
@@ -0,0 +1,45 @@
+package mount
+
+import (
+	"fmt"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// EventType is the kind of change or use of a mount point. See Options.OnEvent.
+type EventType int
+
+// Event types
+const (
+	// EventMount occurs after a file system is mounted
+	EventMount EventType = iota + 1
+	// EventUnmount occurs after a file system is unmounted
+	EventUnmount
+	// EventFirstAccess occurs on the first operation to resolve a path inside a mount, including the mount point itself
+	EventFirstAccess
+	// EventSwap occurs after a mounted file system is replaced by SwapMount(). The first access to the new file system emits another EventFirstAccess.
+	EventSwap
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventMount:
+		return "mount"
+	case EventUnmount:
+		return "unmount"
+	case EventFirstAccess:
+		return "first access"
+	case EventSwap:
+		return "swap"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes a change to or use of a mount point
+type Event struct {
+	Type  EventType
+	Point Point
+	// FS is the mounted file system, or the new file system for EventSwap. For lazy mounts, FS creates the file system on first use.
+	FS hackpadfs.FS
+}
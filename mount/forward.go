@@ -0,0 +1,168 @@
+package mount
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.OpenFileFS
+		hackpadfs.CreateFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.WriteFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+)
+
+// resolver only implements hackpadfs.MountFS, so hackpadfs's helper functions forward operations to the resolved mount and correct error paths
+type resolver struct {
+	fs *FS
+}
+
+func (r resolver) Open(name string) (hackpadfs.File, error) {
+	return r.fs.Open(name)
+}
+
+func (r resolver) Mount(name string) (hackpadfs.FS, string) {
+	return r.fs.Mount(name)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	file, err := hackpadfs.OpenFile(resolver{fs}, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrapDir(name, file), nil
+}
+
+// Create implements hackpadfs.CreateFS
+func (fs *FS) Create(name string) (hackpadfs.File, error) {
+	return hackpadfs.Create(resolver{fs}, name)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return hackpadfs.Mkdir(resolver{fs}, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return hackpadfs.MkdirAll(resolver{fs}, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return hackpadfs.Remove(resolver{fs}, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	return hackpadfs.RemoveAll(resolver{fs}, path)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.Stat(resolver{fs}, name)
+	return statName(name, info), err
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.Lstat(resolver{fs}, name)
+	return statName(name, info), err
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return hackpadfs.Chmod(resolver{fs}, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return hackpadfs.Chown(resolver{fs}, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return hackpadfs.Chtimes(resolver{fs}, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	entries, err := hackpadfs.ReadDir(resolver{fs}, name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.stitchDir(name, entries), nil
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	return hackpadfs.ReadFile(resolver{fs}, name)
+}
+
+// WriteFile implements hackpadfs.WriteFileFS
+func (fs *FS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error {
+	return hackpadfs.WriteFullFile(resolver{fs}, name, data, perm)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return hackpadfs.Symlink(resolver{fs}, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+//
+// Hard links can't span mount points, so fails with hackpadfs.ErrCrossDevice if 'oldname' and 'newname' are in different mounts.
+func (fs *FS) Link(oldname, newname string) error {
+	oldMount, oldPoint, oldSubPath := fs.mountPoint(oldname)
+	_, newPoint, newSubPath := fs.mountPoint(newname)
+	if oldPoint != newPoint {
+		return &hackpadfs.LinkError{Op: "link", Old: oldname, New: newname, Err: hackpadfs.ErrCrossDevice}
+	}
+	err := hackpadfs.Link(oldMount, oldSubPath, newSubPath)
+	var linkErr *hackpadfs.LinkError
+	if errors.As(err, &linkErr) {
+		err = &hackpadfs.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	return err
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(resolver{fs}, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return hackpadfs.Lock(resolver{fs}, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.Statfs(resolver{fs}, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	return hackpadfs.Watch(resolver{fs}, name)
+}
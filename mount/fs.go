@@ -2,10 +2,13 @@
 package mount
 
 import (
+	"errors"
 	"io"
 	"path"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/hack-pad/hackpadfs"
 )
@@ -23,38 +26,93 @@ var (
 //
 // For ease of use, call the standard operations via hackpadfs.OpenFile(fs, ...), hackpadfs.Mkdir(fs, ...), etc.
 type FS struct {
-	rootFS  hackpadfs.FS
-	mountMu sync.Mutex
-	mounts  sync.Map // map[string]hackpadfs.FS
+	rootFS   hackpadfs.FS
+	options  Options
+	mountMu  sync.Mutex // serializes mounts and unmounts
+	mountsMu sync.RWMutex
+	mounts   mountTrie
+}
+
+type mountEntry struct {
+	path     string
+	fs       hackpadfs.FS
+	options  MountOptions
+	accessed uint32 // set to 1 on first access
+}
+
+func (m *mountEntry) point() Point {
+	return Point{Path: m.path, Options: m.options}
+}
+
+// Options provides configuration options for a new FS.
+type Options struct {
+	// RenameByCopy renames directories across mount points by recursively copying them, then removing the originals.
+	// Also falls back to copying when a mounted file system fails a rename with hackpadfs.ErrCrossDevice.
+	// Otherwise, these renames fail with hackpadfs.ErrCrossDevice. Files are always copied across mount points.
+	//
+	// The copy is not atomic: if it fails partway, the partial copy is removed and the originals are left in place.
+	RenameByCopy bool
+	// OnEvent is called when a file system is mounted or unmounted, and on the first access to each mount.
+	// Runs synchronously in the goroutine performing the mount, unmount, or access, so it should return quickly.
+	OnEvent func(Event)
 }
 
 // NewFS returns a new FS.
 func NewFS(rootFS hackpadfs.FS) (*FS, error) {
+	return NewFSWithOptions(rootFS, Options{})
+}
+
+// NewFSWithOptions returns a new FS configured with 'options'.
+func NewFSWithOptions(rootFS hackpadfs.FS, options Options) (*FS, error) {
 	return &FS{
-		rootFS: rootFS,
+		rootFS:  rootFS,
+		options: options,
 	}, nil
 }
 
 // AddMount mounts 'mount' at 'path'. The mount point must already exist as a directory.
+// Mount points may be inside other mounted file systems, including other FS's, and are listed in their parent directory's ReadDir results.
 func (fs *FS) AddMount(path string, mount hackpadfs.FS) error {
-	err := fs.addMount(path, mount)
+	return fs.AddMountWithOptions(path, mount, MountOptions{})
+}
+
+// AddMountWithOptions mounts 'mount' at 'path', restricting its use with 'options'. The mount point must already exist as a directory.
+func (fs *FS) AddMountWithOptions(path string, mount hackpadfs.FS, options MountOptions) error {
+	var err error
+	if options.Dir != "" && !hackpadfs.ValidPath(options.Dir) {
+		err = hackpadfs.ErrInvalid
+	}
+	if options.restricts() {
+		mount = &flaggedFS{fs: mount, options: options}
+	}
+	entry := &mountEntry{path: path, fs: mount, options: options}
+	if err == nil {
+		err = fs.addMount(entry)
+	}
 	if err != nil {
 		return &hackpadfs.PathError{Op: "mount", Path: path, Err: err}
 	}
+	fs.emit(EventMount, entry)
 	return nil
 }
 
-func (fs *FS) addMount(p string, mountFS hackpadfs.FS) error {
+func (fs *FS) emit(eventType EventType, entry *mountEntry) {
+	if fs.options.OnEvent != nil {
+		fs.options.OnEvent(Event{Type: eventType, Point: entry.point(), FS: entry.fs})
+	}
+}
+
+func (fs *FS) addMount(entry *mountEntry) error {
+	p := entry.path
 	if !hackpadfs.ValidPath(p) || p == "." {
 		return hackpadfs.ErrInvalid
 	}
-	_, loaded := fs.mounts.Load(p)
-	if loaded {
+	fs.mountMu.Lock()
+	defer fs.mountMu.Unlock()
+	if fs.mountAt(p) != nil {
 		// cannot mount at same point as existing mount
 		return hackpadfs.ErrExist
 	}
-	fs.mountMu.Lock()
-	defer fs.mountMu.Unlock()
 
 	dir, base := path.Split(p)
 	parentFS, subPath := fs.Mount(dir) // get this mount point's parent mount, verify dir exists
@@ -72,14 +130,89 @@ func (fs *FS) addMount(p string, mountFS hackpadfs.FS) error {
 	}
 	// TODO Handle data race when directory is removed or becomes a file between the Stat and the mount.
 
-	_, loaded = fs.mounts.LoadOrStore(p, mountFS)
-	if loaded {
+	fs.mountsMu.Lock()
+	defer fs.mountsMu.Unlock()
+	if !fs.mounts.insert(entry) {
 		// cannot mount at same point as existing mount
 		return hackpadfs.ErrExist
 	}
 	return nil
 }
 
+// mountAt returns the entry mounted exactly at 'path', or nil
+func (fs *FS) mountAt(path string) *mountEntry {
+	fs.mountsMu.RLock()
+	defer fs.mountsMu.RUnlock()
+	node := fs.mounts.get(path)
+	if node == nil {
+		return nil
+	}
+	return node.entry
+}
+
+// SwapMount atomically replaces the file system mounted at 'path' with 'mount', keeping the mount point's options.
+// Operations already running on the old file system, including open files, complete on it. Later operations use 'mount'.
+func (fs *FS) SwapMount(path string, mount hackpadfs.FS) error {
+	entry, err := fs.swapMount(path, mount)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "mount", Path: path, Err: err}
+	}
+	fs.emit(EventSwap, entry)
+	return nil
+}
+
+func (fs *FS) swapMount(p string, mountFS hackpadfs.FS) (*mountEntry, error) {
+	if !hackpadfs.ValidPath(p) || p == "." {
+		return nil, hackpadfs.ErrInvalid
+	}
+	fs.mountMu.Lock()
+	defer fs.mountMu.Unlock()
+	fs.mountsMu.Lock()
+	defer fs.mountsMu.Unlock()
+
+	node := fs.mounts.get(p)
+	if node == nil || node.entry == nil {
+		return nil, hackpadfs.ErrNotExist
+	}
+	options := node.entry.options
+	if options.restricts() {
+		mountFS = &flaggedFS{fs: mountFS, options: options}
+	}
+	node.entry = &mountEntry{path: p, fs: mountFS, options: options}
+	return node.entry, nil
+}
+
+// Unmount removes the file system mounted at 'path'. Files already opened through the mount are unaffected.
+// Fails if another file system is mounted inside it, so mounts must be removed innermost first.
+func (fs *FS) Unmount(path string) error {
+	entry, err := fs.unmount(path)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "unmount", Path: path, Err: err}
+	}
+	fs.emit(EventUnmount, entry)
+	return nil
+}
+
+func (fs *FS) unmount(p string) (*mountEntry, error) {
+	if !hackpadfs.ValidPath(p) || p == "." {
+		return nil, hackpadfs.ErrInvalid
+	}
+	fs.mountMu.Lock()
+	defer fs.mountMu.Unlock()
+
+	fs.mountsMu.Lock()
+	defer fs.mountsMu.Unlock()
+	node := fs.mounts.get(p)
+	if node == nil || node.entry == nil {
+		return nil, hackpadfs.ErrNotExist
+	}
+	if len(node.children) > 0 {
+		// nodes without mounts are pruned, so any children contain nested mounts
+		return nil, hackpadfs.ErrNotEmpty
+	}
+	return fs.mounts.remove(p), nil
+}
+
 // Mount implements hackpadfs.MountFS
 func (fs *FS) Mount(path string) (mount hackpadfs.FS, subPath string) {
 	mount, mountPath, subPath := fs.mountPoint(path)
@@ -89,56 +222,56 @@ func (fs *FS) Mount(path string) (mount hackpadfs.FS, subPath string) {
 	return mount, subPath
 }
 
-func (fs *FS) mountPoint(path string) (_ hackpadfs.FS, mountPoint, subPath string) {
-	var resultPath string
-	resultFS := fs.rootFS
-	fs.mounts.Range(func(key, value interface{}) bool {
-		mountPath, mountFS := key.(string), value.(hackpadfs.FS)
-		switch {
-		case strings.HasPrefix(path, mountPath+"/"):
-			if len(mountPath) > len(resultPath) {
-				resultPath, resultFS = mountPath, mountFS
-			}
-			return true
-		case mountPath == path:
-			// exact match
-			resultPath, resultFS = mountPath, mountFS
-			return false
-		default:
-			return true
+func (fs *FS) mountPoint(name string) (_ hackpadfs.FS, mountPoint, subPath string) {
+	fs.mountsMu.RLock()
+	result := fs.mounts.lookup(name)
+	fs.mountsMu.RUnlock()
+	if result == nil {
+		if name == "" {
+			name = "."
 		}
-	})
-	subPath = path
-	subPath = strings.TrimPrefix(subPath, resultPath)
-	subPath = strings.TrimPrefix(subPath, "/")
-
-	if resultPath == "" {
-		resultPath = "."
+		return fs.rootFS, ".", name
+	}
+	if atomic.CompareAndSwapUint32(&result.accessed, 0, 1) {
+		fs.emit(EventFirstAccess, result)
+	}
+	subPath = strings.TrimPrefix(name[len(result.path):], "/")
+	if result.options.Dir != "" {
+		subPath = path.Join(result.options.Dir, subPath)
 	}
 	if subPath == "" {
 		subPath = "."
 	}
-	return resultFS, resultPath, subPath
+	return result.fs, result.path, subPath
 }
 
 // Open implements hackpadfs.FS
 func (fs *FS) Open(name string) (hackpadfs.File, error) {
 	mountFS, subPath := fs.Mount(name)
-	return mountFS.Open(subPath)
+	file, err := mountFS.Open(subPath)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrapDir(name, file), nil
 }
 
 // Point represents a mount point, including any relevant metadata
 type Point struct {
-	Path string
+	Path    string
+	Options MountOptions
 }
 
-// MountPoints returns a slice of mount points every mounted file system.
+// MountPoints returns a slice of mount points every mounted file system, sorted by path.
 func (fs *FS) MountPoints() []Point {
+	fs.mountsMu.RLock()
+	entries := fs.mounts.entries()
+	fs.mountsMu.RUnlock()
 	var points []Point
-	fs.mounts.Range(func(key, _ interface{}) bool {
-		path := key.(string)
-		points = append(points, Point{path})
-		return true
+	for _, entry := range entries {
+		points = append(points, entry.point())
+	}
+	sort.Slice(points, func(a, b int) bool {
+		return points[a].Path < points[b].Path
 	})
 	return points
 }
@@ -159,31 +292,125 @@ func (fs *FS) Rename(oldname, newname string) error {
 	}
 
 	if oldPoint == newPoint {
-		return hackpadfs.Rename(oldMount, oldSubPath, newSubPath)
+		err := hackpadfs.Rename(oldMount, oldSubPath, newSubPath)
+		if !fs.options.RenameByCopy || !errors.Is(err, hackpadfs.ErrCrossDevice) {
+			return err
+		}
+	}
+	if isReadOnly(oldMount) || isReadOnly(newMount) || (oldPoint != "." && oldname == oldPoint) {
+		// mount points can't be moved
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrPermission}
+	}
+	if oldInfo.IsDir() && !fs.options.RenameByCopy {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrCrossDevice}
+	}
+	newInfo, err := hackpadfs.LstatOrStat(newMount, newSubPath)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+	case err != nil:
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	case oldInfo.IsDir():
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrExist}
+	case newInfo.IsDir():
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrIsDir}
+	}
+
+	err = copyAll(oldMount, oldSubPath, newMount, newSubPath)
+	if err != nil {
+		if oldInfo.IsDir() {
+			// newname did not exist, so everything under it was created by copyAll
+			_ = hackpadfs.RemoveAll(newMount, newSubPath)
+		} else {
+			_ = hackpadfs.Remove(newMount, newSubPath)
+		}
+		return err
+	}
+	return hackpadfs.RemoveAll(oldMount, oldSubPath)
+}
+
+// copyAll copies 'oldname' in 'src' to 'newname' in 'dest'. Directories are copied recursively.
+func copyAll(src hackpadfs.FS, oldname string, dest hackpadfs.FS, newname string) error {
+	info, err := hackpadfs.LstatOrStat(src, oldname)
+	if err != nil {
+		return err
 	}
-	if oldInfo.IsDir() {
-		// TODO support renaming directories
-		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrNotImplemented}
+	switch {
+	case info.Mode()&hackpadfs.ModeSymlink != 0:
+		target, err := hackpadfs.Readlink(src, oldname)
+		if err != nil {
+			return err
+		}
+		return hackpadfs.Symlink(dest, target, newname)
+	case info.IsDir():
+		if err := hackpadfs.Mkdir(dest, newname, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err := copyXattrs(src, oldname, dest, newname); err != nil {
+			return err
+		}
+		entries, err := hackpadfs.ReadDir(src, oldname)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			err := copyAll(src, path.Join(oldname, entry.Name()), dest, path.Join(newname, entry.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if err := copyFile(src, oldname, dest, newname, info.Mode()); err != nil {
+			return err
+		}
+		return copyXattrs(src, oldname, dest, newname)
 	}
+}
 
-	oldFile, err := oldMount.Open(oldSubPath)
+// copyXattrs copies all extended attributes from 'oldname' in 'src' to 'newname' in 'dest'. Does nothing if 'src' does not support them.
+func copyXattrs(src hackpadfs.FS, oldname string, dest hackpadfs.FS, newname string) error {
+	attrs, err := hackpadfs.ListXattr(src, oldname)
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, attr := range attrs {
+		value, err := hackpadfs.GetXattr(src, oldname, attr)
+		if err == nil {
+			err = hackpadfs.SetXattr(dest, newname, attr, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src hackpadfs.FS, oldname string, dest hackpadfs.FS, newname string, mode hackpadfs.FileMode) error {
+	oldFile, err := src.Open(oldname)
 	if err != nil {
 		return err
 	}
 	defer func() { _ = oldFile.Close() }()
-	newFile, err := hackpadfs.OpenFile(newMount, newSubPath, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, oldInfo.Mode())
+	newFile, err := hackpadfs.OpenFile(dest, newname, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, mode)
 	if err != nil {
 		return err
 	}
+	defer func() { _ = newFile.Close() }()
 	newFileWriter, ok := newFile.(io.Writer)
 	if !ok {
-		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrPermission}
+		return &hackpadfs.PathError{Op: "write", Path: newname, Err: hackpadfs.ErrPermission}
 	}
-	defer func() { _ = newFile.Close() }()
 	_, err = io.Copy(newFileWriter, oldFile)
 	if err != nil {
-		_ = hackpadfs.Remove(newMount, newSubPath)
 		return err
 	}
-	return hackpadfs.Remove(oldMount, oldSubPath)
+	return newFile.Close()
+}
+
+func isReadOnly(fs hackpadfs.FS) bool {
+	mountFS, ok := fs.(*flaggedFS)
+	return ok && mountFS.options.ReadOnly
 }
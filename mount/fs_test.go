@@ -2,13 +2,16 @@ package mount_test
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
 	"sync"
 	"testing"
 
 	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
-	"github.com/hack-pad/hackpadfs/internal/mounttest"
 	"github.com/hack-pad/hackpadfs/mem"
 	"github.com/hack-pad/hackpadfs/mount"
 )
@@ -29,8 +32,9 @@ func TestFS(t *testing.T) {
 			requireNoError(tb, err)
 			fs, err := mount.NewFS(mem)
 			requireNoError(tb, err)
-			return mounttest.NewFS(fs)
+			return fs
 		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
@@ -46,11 +50,109 @@ func TestFS(t *testing.T) {
 			fs, err := mount.NewFS(memRoot)
 			requireNoError(tb, err)
 			requireNoError(tb, fs.AddMount("unused", memUnused))
-			return mounttest.NewFS(fs)
+			return fs
 		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
+
+	options = fstest.FSOptions{
+		Name: "mount with max file size",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			memFS, err := mem.NewFS()
+			requireNoError(tb, err)
+			return memFS, func() hackpadfs.FS {
+				memRoot, err := mem.NewFS()
+				requireNoError(tb, err)
+				requireNoError(tb, memRoot.Mkdir("limited", 0700))
+				fs, err := mount.NewFS(memRoot)
+				requireNoError(tb, err)
+				requireNoError(tb, fs.AddMountWithOptions("limited", memFS, mount.MountOptions{MaxFileSize: 1024}))
+				subFS, err := hackpadfs.Sub(fs, "limited")
+				requireNoError(tb, err)
+				return subFS
+			}
+		}),
+		Quota: fstest.QuotaOptions{
+			Size: 1024,
+			Err:  hackpadfs.ErrTooLarge,
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+
+	options = fstest.FSOptions{
+		Name: "mount of mem",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			memMount, err := mem.NewFS()
+			requireNoError(tb, err)
+			return memMount, func() hackpadfs.FS {
+				memRoot, err := mem.NewFS()
+				requireNoError(tb, err)
+				requireNoError(tb, memRoot.Mkdir("mnt", 0700))
+				fs, err := mount.NewFS(memRoot)
+				requireNoError(tb, err)
+				requireNoError(tb, fs.AddMount("mnt", memMount))
+				return &dirFS{fs: fs, dir: "mnt"}
+			}
+		}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+	fstest.Diff(t, options, fstest.DiffOptions{
+		Reference: func(tb testing.TB) hackpadfs.FS {
+			fs, err := mem.NewFS()
+			requireNoError(tb, err)
+			return fs
+		},
+	})
+}
+
+// dirFS runs all operations inside 'dir' of a mount.FS, so calls go through mount.FS's own methods before reaching the mounted FS
+type dirFS struct {
+	fs  *mount.FS
+	dir string
+}
+
+func (fs *dirFS) Open(name string) (hackpadfs.File, error) {
+	file, err := fs.fs.Open(fs.join(name))
+	return file, restorePath(err, name)
+}
+
+func (fs *dirFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	file, err := fs.fs.OpenFile(fs.join(name), flag, perm)
+	return file, restorePath(err, name)
+}
+
+func (fs *dirFS) Mount(name string) (hackpadfs.FS, string) {
+	return fs.fs, fs.join(name)
+}
+
+func (fs *dirFS) Rename(oldname, newname string) error {
+	err := fs.fs.Rename(fs.join(oldname), fs.join(newname))
+	var linkErr *hackpadfs.LinkError
+	if errors.As(err, &linkErr) {
+		err = &hackpadfs.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	return err
+}
+
+func restorePath(err error, name string) error {
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		err = &hackpadfs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+// join prefixes 'name' with 'dir' without cleaning, so invalid paths remain invalid
+func (fs *dirFS) join(name string) string {
+	if name == "." {
+		return fs.dir
+	}
+	return fs.dir + "/" + name
 }
 
 func TestAddMount(t *testing.T) {
@@ -170,3 +272,693 @@ func TestMount(t *testing.T) {
 		assert.Equal(t, hackpadfs.FileMode(hackpadfs.ModeDir|0700), info.Mode())
 	}
 }
+
+func TestUnmount(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T) *mount.FS {
+		memRoot, err := mem.NewFS()
+		assert.NoError(t, err)
+		fs, err := mount.NewFS(memRoot)
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+		memFoo, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("foo", memFoo))
+		return fs
+	}
+
+	t.Run("unmount", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo/bar", 0700))
+
+		assert.NoError(t, fs.Unmount("foo"))
+		assert.Equal(t, 0, len(fs.MountPoints()))
+		_, err := hackpadfs.Stat(fs, "foo/bar")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.NoError(t, err)
+	})
+
+	t.Run("not mounted", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		err := fs.Unmount("bar")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		err = fs.Unmount(".")
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	})
+
+	t.Run("nested mount", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo/bar", 0700))
+		memBar, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("foo/bar", memBar))
+
+		err = fs.Unmount("foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotEmpty, err)
+		assert.Equal(t, []mount.Point{
+			{Path: "foo"},
+			{Path: "foo/bar"},
+		}, fs.MountPoints())
+
+		assert.NoError(t, fs.Unmount("foo/bar"))
+		assert.NoError(t, fs.Unmount("foo"))
+		assert.Equal(t, 0, len(fs.MountPoints()))
+	})
+
+	t.Run("remount", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		assert.NoError(t, fs.Unmount("foo"))
+		memFoo, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("foo", memFoo))
+		assert.Equal(t, []mount.Point{
+			{Path: "foo"},
+		}, fs.MountPoints())
+	})
+}
+
+func TestAddMountWithOptions(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T, options mount.MountOptions) *mount.FS {
+		memRoot, err := mem.NewFS()
+		assert.NoError(t, err)
+		fs, err := mount.NewFS(memRoot)
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+
+		memFoo, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.WriteFullFile(memFoo, "bar", []byte("bar"), 0600))
+		assert.NoError(t, hackpadfs.Mkdir(memFoo, "baz", 0700))
+		assert.NoError(t, fs.AddMountWithOptions("foo", memFoo, options))
+		return fs
+	}
+
+	t.Run("mount points", func(t *testing.T) {
+		t.Parallel()
+		options := mount.MountOptions{ReadOnly: true, MaxFileSize: 10}
+		fs := newFS(t, options)
+		assert.Equal(t, []mount.Point{
+			{Path: "foo", Options: options},
+		}, fs.MountPoints())
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.MountOptions{ReadOnly: true})
+
+		b, err := hackpadfs.ReadFile(fs, "foo/bar")
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", string(b))
+		_, err = hackpadfs.Stat(fs, "foo/baz")
+		assert.NoError(t, err)
+
+		_, err = hackpadfs.OpenFile(fs, "foo/bar", hackpadfs.FlagWriteOnly, 0)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.WriteFullFile(fs, "foo/new", []byte("new"), 0600)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.Mkdir(fs, "foo/new", 0700)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.MkdirAll(fs, "foo/new/dir", 0700)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		assert.NoError(t, hackpadfs.MkdirAll(fs, "foo/baz", 0700))
+		err = hackpadfs.Remove(fs, "foo/bar")
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.RemoveAll(fs, "foo/baz")
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.Chmod(fs, "foo/bar", 0700)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.Rename(fs, "foo/bar", "foo/baz/bar")
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "outside", []byte("outside"), 0600))
+		err = hackpadfs.Rename(fs, "outside", "foo/outside")
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		_, err = hackpadfs.Stat(fs, "outside")
+		assert.NoError(t, err)
+
+		f, err := fs.Open("foo/bar")
+		assert.NoError(t, err)
+		err = hackpadfs.ChmodFile(f, 0700)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("no create", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.MountOptions{NoCreate: true})
+
+		err := hackpadfs.WriteFullFile(fs, "foo/new", []byte("new"), 0600)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.Mkdir(fs, "foo/new", 0700)
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+		err = hackpadfs.Mkdir(fs, "foo/baz", 0700)
+		assert.ErrorIs(t, hackpadfs.ErrExist, err)
+		_, err = hackpadfs.OpenFile(fs, "foo/bar", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, 0600)
+		assert.ErrorIs(t, hackpadfs.ErrExist, err)
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar", []byte("baz"), 0600))
+		b, err := hackpadfs.ReadFile(fs, "foo/bar")
+		assert.NoError(t, err)
+		assert.Equal(t, "baz", string(b))
+		assert.NoError(t, hackpadfs.Remove(fs, "foo/bar"))
+	})
+
+	t.Run("max file size", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.MountOptions{MaxFileSize: 5})
+
+		f, err := hackpadfs.OpenFile(fs, "foo/bar", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+		assert.NoError(t, err)
+		n, err := hackpadfs.WriteFile(f, []byte("baz"))
+		assert.Equal(t, 2, n)
+		assert.ErrorIs(t, hackpadfs.ErrTooLarge, err)
+		err = hackpadfs.TruncateFile(f, 6)
+		assert.ErrorIs(t, hackpadfs.ErrTooLarge, err)
+		assert.NoError(t, hackpadfs.TruncateFile(f, 1))
+		assert.NoError(t, f.Close())
+
+		b, err := hackpadfs.ReadFile(fs, "foo/bar")
+		assert.NoError(t, err)
+		assert.Equal(t, "b", string(b))
+
+		err = hackpadfs.WriteFullFile(fs, "foo/new", []byte("123456"), 0600)
+		assert.ErrorIs(t, hackpadfs.ErrTooLarge, err)
+		b, err = hackpadfs.ReadFile(fs, "foo/new")
+		assert.NoError(t, err)
+		assert.Equal(t, "12345", string(b))
+	})
+}
+
+type crossDeviceFS struct {
+	*mem.FS
+}
+
+func (fs *crossDeviceFS) Rename(oldname, newname string) error {
+	return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrCrossDevice}
+}
+
+func TestRenameAcrossMounts(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T, options mount.Options) *mount.FS {
+		memRoot, err := mem.NewFS()
+		assert.NoError(t, err)
+		fs, err := mount.NewFSWithOptions(memRoot, options)
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+		memFoo, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("foo", &crossDeviceFS{memFoo}))
+
+		assert.NoError(t, hackpadfs.MkdirAll(fs, "bar/baz", 0750))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar/baz/biff", []byte("biff"), 0640))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "bar/boo", []byte("boo"), 0600))
+		return fs
+	}
+
+	t.Run("file", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		assert.NoError(t, hackpadfs.Rename(fs, "bar/boo", "foo/boo"))
+		b, err := hackpadfs.ReadFile(fs, "foo/boo")
+		assert.NoError(t, err)
+		assert.Equal(t, "boo", string(b))
+		_, err = hackpadfs.Stat(fs, "bar/boo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		err := hackpadfs.Rename(fs, "bar", "foo/bar")
+		assert.ErrorIs(t, hackpadfs.ErrCrossDevice, err)
+		_, err = hackpadfs.Stat(fs, "bar/baz/biff")
+		assert.NoError(t, err)
+	})
+
+	t.Run("directory by copy", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{RenameByCopy: true})
+		assert.NoError(t, hackpadfs.Rename(fs, "bar", "foo/bar"))
+
+		_, err := hackpadfs.Stat(fs, "bar")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		b, err := hackpadfs.ReadFile(fs, "foo/bar/baz/biff")
+		assert.NoError(t, err)
+		assert.Equal(t, "biff", string(b))
+		info, err := hackpadfs.Stat(fs, "foo/bar/baz/biff")
+		assert.NoError(t, err)
+		assert.Equal(t, hackpadfs.FileMode(0640), info.Mode())
+		info, err = hackpadfs.Stat(fs, "foo/bar/baz")
+		assert.NoError(t, err)
+		assert.Equal(t, hackpadfs.ModeDir|0750, info.Mode())
+	})
+
+	t.Run("directory exists", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{RenameByCopy: true})
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo/bar", 0700))
+		err := hackpadfs.Rename(fs, "bar", "foo/bar")
+		assert.ErrorIs(t, hackpadfs.ErrExist, err)
+		_, err = hackpadfs.Stat(fs, "bar/boo")
+		assert.NoError(t, err)
+	})
+
+	t.Run("extended attributes", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{RenameByCopy: true})
+		assert.NoError(t, hackpadfs.SetXattr(fs, "bar", "user.bar", []byte("bar")))
+		assert.NoError(t, hackpadfs.SetXattr(fs, "bar/baz/biff", "user.biff", []byte("biff")))
+		assert.NoError(t, hackpadfs.Rename(fs, "bar", "foo/bar"))
+
+		value, err := hackpadfs.GetXattr(fs, "foo/bar", "user.bar")
+		assert.NoError(t, err)
+		assert.Equal(t, "bar", string(value))
+		value, err = hackpadfs.GetXattr(fs, "foo/bar/baz/biff", "user.biff")
+		assert.NoError(t, err)
+		assert.Equal(t, "biff", string(value))
+	})
+
+	t.Run("file onto directory", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		assert.NoError(t, hackpadfs.MkdirAll(fs, "foo/boo/keep", 0700))
+		err := hackpadfs.Rename(fs, "bar/boo", "foo/boo")
+		assert.ErrorIs(t, hackpadfs.ErrIsDir, err)
+		_, err = hackpadfs.Stat(fs, "foo/boo/keep")
+		assert.NoError(t, err)
+		b, err := hackpadfs.ReadFile(fs, "bar/boo")
+		assert.NoError(t, err)
+		assert.Equal(t, "boo", string(b))
+	})
+
+	t.Run("cross device error", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{})
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/boo", []byte("boo"), 0600))
+		err := hackpadfs.Rename(fs, "foo/boo", "foo/biff")
+		assert.ErrorIs(t, hackpadfs.ErrCrossDevice, err)
+
+		fs = newFS(t, mount.Options{RenameByCopy: true})
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/boo", []byte("boo"), 0600))
+		assert.NoError(t, hackpadfs.Rename(fs, "foo/boo", "foo/biff"))
+		b, err := hackpadfs.ReadFile(fs, "foo/biff")
+		assert.NoError(t, err)
+		assert.Equal(t, "boo", string(b))
+		_, err = hackpadfs.Stat(fs, "foo/boo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("mount point", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t, mount.Options{RenameByCopy: true})
+		err := hackpadfs.Rename(fs, "foo", "bar/foo")
+		assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	})
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+	var events []mount.Event
+	var eventsMu sync.Mutex
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFSWithOptions(memRoot, mount.Options{
+		OnEvent: func(event mount.Event) {
+			eventsMu.Lock()
+			events = append(events, event)
+			eventsMu.Unlock()
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	assert.Equal(t, 0, len(events))
+
+	memFoo, err := mem.NewFS()
+	assert.NoError(t, err)
+	options := mount.MountOptions{NoCreate: true}
+	assert.NoError(t, fs.AddMountWithOptions("foo", memFoo, options))
+	assert.Error(t, fs.AddMount("foo", memFoo))
+	_, err = hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	_, err = hackpadfs.Stat(fs, "foo/bar")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	assert.NoError(t, fs.Unmount("foo"))
+
+	point := mount.Point{Path: "foo", Options: options}
+	if assert.Equal(t, 3, len(events)) {
+		assert.Equal(t, mount.EventMount, events[0].Type)
+		assert.Equal(t, point, events[0].Point)
+		assert.Equal(t, mount.EventFirstAccess, events[1].Type)
+		assert.Equal(t, point, events[1].Point)
+		assert.Equal(t, mount.EventUnmount, events[2].Type)
+		assert.Equal(t, point, events[2].Point)
+		_, err = hackpadfs.Stat(events[2].FS, "bar")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	}
+	assert.Equal(t, "first access", mount.EventFirstAccess.String())
+}
+
+func newBenchmarkFS(tb testing.TB, mountPaths []string) *mount.FS {
+	memRoot, err := mem.NewFS()
+	assert.NoError(tb, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(tb, err)
+	for _, p := range mountPaths {
+		assert.NoError(tb, hackpadfs.MkdirAll(fs, p, 0700))
+		memMount, err := mem.NewFS()
+		assert.NoError(tb, err)
+		assert.NoError(tb, fs.AddMount(p, memMount))
+	}
+	return fs
+}
+
+func siblingMountPaths(count int) []string {
+	var paths []string
+	for i := 0; i < count; i++ {
+		paths = append(paths, fmt.Sprintf("mnt/%d", i))
+	}
+	return paths
+}
+
+func nestedMountPaths(depth int) []string {
+	var paths []string
+	p := "nest"
+	for i := 0; i < depth; i++ {
+		p = path.Join(p, strconv.Itoa(i))
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func TestMountResolution(t *testing.T) {
+	t.Parallel()
+	fs := newBenchmarkFS(t, append(siblingMountPaths(10), nestedMountPaths(5)...))
+
+	for _, tc := range []struct {
+		path        string
+		expectPoint string
+		expectSub   string
+	}{
+		{path: ".", expectPoint: ".", expectSub: "."},
+		{path: "mnt", expectPoint: ".", expectSub: "mnt"},
+		{path: "mnt/1", expectPoint: "mnt/1", expectSub: "."},
+		{path: "mnt/10", expectPoint: ".", expectSub: "mnt/10"},
+		{path: "mnt/1/foo/bar", expectPoint: "mnt/1", expectSub: "foo/bar"},
+		{path: "nest/0/1/2", expectPoint: "nest/0/1/2", expectSub: "."},
+		{path: "nest/0/1/2/5/foo", expectPoint: "nest/0/1/2", expectSub: "5/foo"},
+		{path: "nest/0/1/2/3/4/foo", expectPoint: "nest/0/1/2/3/4", expectSub: "foo"},
+	} {
+		tc := tc
+		t.Run(tc.path, func(t *testing.T) {
+			mountFS, subPath := fs.Mount(tc.path)
+			assert.Equal(t, tc.expectSub, subPath)
+			if tc.expectPoint == "." {
+				return
+			}
+			_, err := hackpadfs.Stat(mountFS, ".")
+			assert.NoError(t, err)
+			expectFS, _ := fs.Mount(tc.expectPoint)
+			assert.Equal(t, true, expectFS == mountFS)
+		})
+	}
+}
+
+func TestMountResolutionAllocs(t *testing.T) {
+	// AllocsPerRun can't run in parallel
+	fs := newBenchmarkFS(t, nestedMountPaths(5))
+	_, _ = fs.Mount("nest/0/1/2/3/4") // emit first access event
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = fs.Mount("nest/0/1/2/3/4/foo/bar")
+	})
+	assert.Equal(t, 0.0, allocs)
+}
+
+func BenchmarkMount(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		mounts []string
+		path   string
+	}{
+		{name: "no mounts", path: "foo/bar/baz"},
+		{name: "1000 siblings", mounts: siblingMountPaths(1000), path: "mnt/999/foo/bar"},
+		{name: "1000 siblings miss", mounts: siblingMountPaths(1000), path: "other/foo/bar"},
+		{name: "depth 50", mounts: nestedMountPaths(50), path: path.Join(nestedMountPaths(50)[49], "foo/bar")},
+	} {
+		bc := bc
+		b.Run(bc.name, func(b *testing.B) {
+			fs := newBenchmarkFS(b, bc.mounts)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _ = fs.Mount(bc.path)
+			}
+		})
+	}
+}
+
+func TestMountDir(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "assets", 0700))
+
+	memStatic, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.MkdirAll(memStatic, "static/v2", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(memStatic, "static/v2/logo", []byte("logo"), 0600))
+
+	err = fs.AddMountWithOptions("assets", memStatic, mount.MountOptions{Dir: "/static"})
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	options := mount.MountOptions{Dir: "static/v2"}
+	assert.NoError(t, fs.AddMountWithOptions("assets", memStatic, options))
+	assert.Equal(t, []mount.Point{{Path: "assets", Options: options}}, fs.MountPoints())
+
+	b, err := hackpadfs.ReadFile(fs, "assets/logo")
+	assert.NoError(t, err)
+	assert.Equal(t, "logo", string(b))
+	entries, err := hackpadfs.ReadDir(fs, "assets")
+	if assert.NoError(t, err) && assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "logo", entries[0].Name())
+	}
+
+	_, err = hackpadfs.Stat(fs, "assets/missing")
+	assert.Equal(t, &hackpadfs.PathError{Op: "stat", Path: "assets/missing", Err: hackpadfs.ErrNotExist}, err)
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "assets/new", []byte("new"), 0600))
+	_, err = hackpadfs.Stat(memStatic, "static/v2/new")
+	assert.NoError(t, err)
+}
+
+func TestSwapMount(t *testing.T) {
+	t.Parallel()
+	newMemFS := func(t *testing.T, contents string) *mem.FS {
+		fs, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "version", []byte(contents), 0600))
+		return fs
+	}
+	var events []mount.EventType
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFSWithOptions(memRoot, mount.Options{
+		OnEvent: func(event mount.Event) {
+			events = append(events, event.Type)
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+
+	err = fs.SwapMount("foo", newMemFS(t, "1"))
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+	options := mount.MountOptions{ReadOnly: true}
+	assert.NoError(t, fs.AddMountWithOptions("foo", newMemFS(t, "1"), options))
+	oldFile, err := fs.Open("foo/version")
+	assert.NoError(t, err)
+
+	assert.NoError(t, fs.SwapMount("foo", newMemFS(t, "2")))
+	b, err := io.ReadAll(oldFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(b))
+	assert.NoError(t, oldFile.Close())
+
+	b, err = hackpadfs.ReadFile(fs, "foo/version")
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(b))
+	assert.Equal(t, []mount.Point{{Path: "foo", Options: options}}, fs.MountPoints())
+	err = hackpadfs.WriteFullFile(fs, "foo/version", []byte("3"), 0600)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	assert.Equal(t, []mount.EventType{
+		mount.EventMount,
+		mount.EventFirstAccess,
+		mount.EventSwap,
+		mount.EventFirstAccess,
+	}, events)
+}
+
+func TestSwapMountConcurrent(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+	newMemFS := func(version int) hackpadfs.FS {
+		memFS, err := mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.WriteFullFile(memFS, "version", []byte(strconv.Itoa(version)), 0600))
+		return memFS
+	}
+	assert.NoError(t, fs.AddMount("foo", newMemFS(0)))
+
+	const swaps = 20
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= swaps; i++ {
+			assert.NoError(t, fs.SwapMount("foo", newMemFS(i)))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		last := 0
+		for last < swaps {
+			b, err := hackpadfs.ReadFile(fs, "foo/version")
+			assert.NoError(t, err)
+			version, err := strconv.Atoi(string(b))
+			assert.NoError(t, err)
+			assert.Equal(t, true, version >= last)
+			last = version
+		}
+	}()
+	wg.Wait()
+}
+
+func TestNestedMounts(t *testing.T) {
+	t.Parallel()
+	newMem := func(t *testing.T) *mem.FS {
+		t.Helper()
+		fs, err := mem.NewFS()
+		assert.NoError(t, err)
+		return fs
+	}
+
+	t.Run("mount inside a mount", func(t *testing.T) {
+		t.Parallel()
+		memRoot, memA, memB := newMem(t), newMem(t), newMem(t)
+		assert.NoError(t, memRoot.Mkdir("a", 0700))
+		assert.NoError(t, memA.Mkdir("b", 0700))
+		assert.NoError(t, memB.Chmod(".", 0750))
+		fs, err := mount.NewFS(memRoot)
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("a", memA))
+		assert.NoError(t, fs.AddMount("a/b", memB))
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "a/b/foo", []byte("foo"), 0600))
+		_, err = hackpadfs.Stat(memB, "foo")
+		assert.NoError(t, err)
+
+		info, err := hackpadfs.Stat(fs, "a/b")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "b", info.Name())
+			assert.Equal(t, hackpadfs.ModeDir|0750, info.Mode())
+		}
+		entries, err := hackpadfs.ReadDir(fs, "a")
+		if assert.NoError(t, err) && assert.Equal(t, 1, len(entries)) {
+			assert.Equal(t, "b", entries[0].Name())
+			info, err := entries[0].Info()
+			assert.NoError(t, err)
+			assert.Equal(t, hackpadfs.ModeDir|0750, info.Mode())
+		}
+		assert.ErrorIs(t, hackpadfs.ErrNotEmpty, fs.Unmount("a"))
+	})
+
+	t.Run("mount table inside a mount table", func(t *testing.T) {
+		t.Parallel()
+		memRoot, memInner, memLeaf := newMem(t), newMem(t), newMem(t)
+		assert.NoError(t, memRoot.Mkdir("outer", 0700))
+		assert.NoError(t, memInner.Mkdir("inner", 0700))
+		inner, err := mount.NewFS(memInner)
+		assert.NoError(t, err)
+		assert.NoError(t, inner.AddMount("inner", memLeaf))
+		fs, err := mount.NewFS(memRoot)
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("outer", inner))
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "outer/inner/foo", []byte("foo"), 0600))
+		b, err := hackpadfs.ReadFile(memLeaf, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", string(b))
+
+		_, err = hackpadfs.Stat(fs, "outer/inner/bar")
+		assert.Equal(t, &hackpadfs.PathError{Op: "stat", Path: "outer/inner/bar", Err: hackpadfs.ErrNotExist}, err)
+
+		entries, err := hackpadfs.ReadDir(fs, "outer/inner")
+		if assert.NoError(t, err) && assert.Equal(t, 1, len(entries)) {
+			assert.Equal(t, "foo", entries[0].Name())
+		}
+	})
+
+	t.Run("stitch missing mount point", func(t *testing.T) {
+		t.Parallel()
+		memRoot, memMount := newMem(t), newMem(t)
+		assert.NoError(t, memRoot.Mkdir("mnt", 0700))
+		assert.NoError(t, memRoot.Mkdir("other", 0700))
+		fs, err := mount.NewFS(memRoot)
+		assert.NoError(t, err)
+		assert.NoError(t, fs.AddMount("mnt", memMount))
+		assert.NoError(t, memRoot.Remove("mnt"))
+
+		entries, err := hackpadfs.ReadDir(fs, ".")
+		assert.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"mnt", "other"}, names)
+
+		dir, err := fs.Open(".")
+		assert.NoError(t, err)
+		defer func() { assert.NoError(t, dir.Close()) }()
+		names = nil
+		for {
+			entries, err := hackpadfs.ReadDirFile(dir, 1)
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err) || !assert.Equal(t, 1, len(entries)) {
+				break
+			}
+			names = append(names, entries[0].Name())
+		}
+		assert.Equal(t, []string{"mnt", "other"}, names)
+	})
+}
+
+func TestLinkAcrossMounts(t *testing.T) {
+	t.Parallel()
+	memRoot, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.Mkdir(memRoot, "foo", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(memRoot, "bar", []byte("bar"), 0600))
+	memFoo, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(memFoo, "baz", []byte("baz"), 0600))
+	fs, err := mount.NewFS(memRoot)
+	assert.NoError(t, err)
+	assert.NoError(t, fs.AddMount("foo", memFoo))
+
+	err = hackpadfs.Link(fs, "bar", "foo/bar")
+	assert.Equal(t, &hackpadfs.LinkError{Op: "link", Old: "bar", New: "foo/bar", Err: hackpadfs.ErrCrossDevice}, err)
+	err = hackpadfs.Link(fs, "foo/baz", "foo/biff")
+	assert.Equal(t, &hackpadfs.LinkError{Op: "link", Old: "foo/baz", New: "foo/biff", Err: hackpadfs.ErrNotImplemented}, err)
+}
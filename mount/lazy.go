@@ -0,0 +1,226 @@
+package mount
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.MountFS
+		hackpadfs.RenameFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+	} = &lazyFS{}
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.WriteFileFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &errFS{}
+)
+
+// Factory creates a file system for a lazy mount. See FS.AddLazyMount().
+type Factory func() (hackpadfs.FS, error)
+
+// LazyMountOptions configures how a lazy mount handles errors from its Factory.
+type LazyMountOptions struct {
+	// RetryAfter is how long a Factory error is returned to callers before the Factory is called again. Errors are cached forever if zero.
+	RetryAfter time.Duration
+	// MaxAttempts stops calling the Factory after this many errors, caching the last error forever. Unlimited if zero.
+	MaxAttempts int
+}
+
+// AddLazyMount mounts the file system returned by 'factory' at 'path'. The mount point must already exist as a directory.
+// The factory is called on first access to 'path', so expensive file systems are only created if they're used.
+func (fs *FS) AddLazyMount(path string, factory Factory, options LazyMountOptions) error {
+	return fs.AddMount(path, &lazyFS{factory: factory, options: options})
+}
+
+// lazyFS creates its file system on first use, then forwards all operations to it
+type lazyFS struct {
+	factory Factory
+	options LazyMountOptions
+
+	mu        sync.Mutex
+	fs        hackpadfs.FS
+	err       error
+	attempts  int
+	retryTime time.Time
+}
+
+func (fs *lazyFS) get() (hackpadfs.FS, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.fs != nil {
+		return fs.fs, nil
+	}
+	if fs.err != nil && !fs.canRetry() {
+		return nil, fs.err
+	}
+	mountFS, err := fs.factory()
+	if err == nil && mountFS == nil {
+		err = hackpadfs.ErrNotExist
+	}
+	if err != nil {
+		fs.err = err
+		fs.attempts++
+		fs.retryTime = time.Now().Add(fs.options.RetryAfter)
+		return nil, err
+	}
+	fs.fs, fs.err = mountFS, nil
+	return mountFS, nil
+}
+
+func (fs *lazyFS) canRetry() bool {
+	if fs.options.RetryAfter == 0 {
+		return false
+	}
+	if fs.options.MaxAttempts > 0 && fs.attempts >= fs.options.MaxAttempts {
+		return false
+	}
+	return !time.Now().Before(fs.retryTime)
+}
+
+// Open implements hackpadfs.FS
+func (fs *lazyFS) Open(name string) (hackpadfs.File, error) {
+	mountFS, err := fs.get()
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return mountFS.Open(name)
+}
+
+// Mount implements hackpadfs.MountFS
+func (fs *lazyFS) Mount(name string) (hackpadfs.FS, string) {
+	mountFS, err := fs.get()
+	if err != nil {
+		return &errFS{err: err}, name
+	}
+	return mountFS, name
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *lazyFS) Rename(oldname, newname string) error {
+	mountFS, err := fs.get()
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return hackpadfs.Rename(mountFS, oldname, newname)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *lazyFS) Symlink(oldname, newname string) error {
+	mountFS, err := fs.get()
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return hackpadfs.Symlink(mountFS, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *lazyFS) Link(oldname, newname string) error {
+	mountFS, err := fs.get()
+	if err != nil {
+		return &hackpadfs.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	return hackpadfs.Link(mountFS, oldname, newname)
+}
+
+// errFS fails all operations with the error from a lazy mount's Factory
+type errFS struct {
+	err error
+}
+
+func (fs *errFS) pathErr(op, name string) error {
+	return &hackpadfs.PathError{Op: op, Path: name, Err: fs.err}
+}
+
+func (fs *errFS) Open(name string) (hackpadfs.File, error) {
+	return nil, fs.pathErr("open", name)
+}
+
+func (fs *errFS) OpenFile(name string, _ int, _ hackpadfs.FileMode) (hackpadfs.File, error) {
+	return nil, fs.pathErr("open", name)
+}
+
+func (fs *errFS) Mkdir(name string, _ hackpadfs.FileMode) error {
+	return fs.pathErr("mkdir", name)
+}
+
+func (fs *errFS) MkdirAll(path string, _ hackpadfs.FileMode) error {
+	return fs.pathErr("mkdirall", path)
+}
+
+func (fs *errFS) Remove(name string) error {
+	return fs.pathErr("remove", name)
+}
+
+func (fs *errFS) RemoveAll(path string) error {
+	return fs.pathErr("removeall", path)
+}
+
+func (fs *errFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return nil, fs.pathErr("stat", name)
+}
+
+func (fs *errFS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return nil, fs.pathErr("lstat", name)
+}
+
+func (fs *errFS) Chmod(name string, _ hackpadfs.FileMode) error {
+	return fs.pathErr("chmod", name)
+}
+
+func (fs *errFS) Chown(name string, _, _ int) error {
+	return fs.pathErr("chown", name)
+}
+
+func (fs *errFS) Chtimes(name string, _, _ time.Time) error {
+	return fs.pathErr("chtimes", name)
+}
+
+func (fs *errFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return nil, fs.pathErr("open", name)
+}
+
+func (fs *errFS) ReadFile(name string) ([]byte, error) {
+	return nil, fs.pathErr("open", name)
+}
+
+func (fs *errFS) WriteFile(name string, _ []byte, _ hackpadfs.FileMode) error {
+	return fs.pathErr("open", name)
+}
+
+func (fs *errFS) Readlink(name string) (string, error) {
+	return "", fs.pathErr("readlink", name)
+}
+
+func (fs *errFS) Lock(name string, _ hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return nil, fs.pathErr("lock", name)
+}
+
+func (fs *errFS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.FSUsage{}, fs.pathErr("statfs", name)
+}
+
+func (fs *errFS) Watch(name string) (hackpadfs.Watcher, error) {
+	return nil, fs.pathErr("watch", name)
+}
@@ -0,0 +1,117 @@
+package mount_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"github.com/hack-pad/hackpadfs/mount"
+)
+
+func TestAddLazyMount(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T) *mount.FS {
+		memRoot, err := mem.NewFS()
+		assert.NoError(t, err)
+		fs, err := mount.NewFS(memRoot)
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.Mkdir(fs, "foo", 0700))
+		return fs
+	}
+
+	t.Run("created on first use", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		var calls int
+		var callsMu sync.Mutex
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			callsMu.Lock()
+			calls++
+			callsMu.Unlock()
+			return mem.NewFS()
+		}, mount.LazyMountOptions{}))
+		assert.Equal(t, []mount.Point{{Path: "foo"}}, fs.MountPoints())
+		assert.Equal(t, 0, calls)
+
+		var wg sync.WaitGroup
+		const writers = 5
+		wg.Add(writers)
+		for i := 0; i < writers; i++ {
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, hackpadfs.MkdirAll(fs, "foo/bar", 0700))
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, calls)
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/baz", []byte("baz"), 0600))
+		b, err := hackpadfs.ReadFile(fs, "foo/bar/baz")
+		assert.NoError(t, err)
+		assert.Equal(t, "baz", string(b))
+		assert.NoError(t, hackpadfs.Rename(fs, "foo/bar/baz", "foo/baz"))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("errors are cached", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		factoryErr := errors.New("some error")
+		calls := 0
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			calls++
+			return nil, factoryErr
+		}, mount.LazyMountOptions{}))
+
+		_, err := fs.Open("foo/bar")
+		assert.ErrorIs(t, factoryErr, err)
+		err = hackpadfs.Mkdir(fs, "foo/bar", 0700)
+		assert.ErrorIs(t, factoryErr, err)
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(t, factoryErr, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retry after error", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		factoryErr := errors.New("some error")
+		calls := 0
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			calls++
+			if calls == 1 {
+				return nil, factoryErr
+			}
+			return mem.NewFS()
+		}, mount.LazyMountOptions{RetryAfter: time.Millisecond}))
+
+		_, err := hackpadfs.Stat(fs, "foo")
+		assert.ErrorIs(t, factoryErr, err)
+		time.Sleep(2 * time.Millisecond)
+		_, err = hackpadfs.Stat(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		factoryErr := errors.New("some error")
+		calls := 0
+		assert.NoError(t, fs.AddLazyMount("foo", func() (hackpadfs.FS, error) {
+			calls++
+			return nil, factoryErr
+		}, mount.LazyMountOptions{RetryAfter: time.Nanosecond, MaxAttempts: 2}))
+
+		for i := 0; i < 4; i++ {
+			time.Sleep(time.Millisecond)
+			_, err := hackpadfs.Stat(fs, "foo")
+			assert.ErrorIs(t, factoryErr, err)
+		}
+		assert.Equal(t, 2, calls)
+	})
+}
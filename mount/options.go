@@ -0,0 +1,426 @@
+package mount
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+		hackpadfs.XattrFS
+	} = &flaggedFS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &flaggedFile{}
+)
+
+// MountOptions configures a mount point. Restrictions are enforced before operations reach the mounted file system.
+type MountOptions struct {
+	// Dir mounts this directory inside the file system, instead of its root. For example, mounting "assets" with Dir "static/v2" resolves "assets/logo.png" to "static/v2/logo.png".
+	// Errors report paths as seen through the mount point.
+	Dir string
+
+	// ReadOnly fails all operations that modify files or directories with hackpadfs.ErrPermission.
+	ReadOnly bool
+	// NoCreate fails creating new files, directories, and symlinks with hackpadfs.ErrPermission. Existing files may still be modified, renamed, or removed.
+	NoCreate bool
+	// MaxFileSize fails writes and truncates that would grow a file beyond this many bytes with hackpadfs.ErrTooLarge. Writes are applied up to the limit. Disabled if zero.
+	MaxFileSize int64
+}
+
+func (o MountOptions) restricts() bool {
+	return o.ReadOnly || o.NoCreate || o.MaxFileSize != 0
+}
+
+// flaggedFS enforces MountOptions on a mounted file system
+type flaggedFS struct {
+	fs      hackpadfs.FS
+	options MountOptions
+}
+
+func (fs *flaggedFS) checkWritable(op, name string) error {
+	if fs.options.ReadOnly {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrPermission}
+	}
+	return nil
+}
+
+// checkCreate returns nil if 'name' may be created, ErrExist if it already exists, or ErrPermission if creating is not allowed
+func (fs *flaggedFS) checkCreate(op, name string) error {
+	if err := fs.checkWritable(op, name); err != nil {
+		return err
+	}
+	if !fs.options.NoCreate {
+		return nil
+	}
+	_, err := hackpadfs.LstatOrStat(fs.fs, name)
+	switch {
+	case err == nil:
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrExist}
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrPermission}
+	default:
+		return err
+	}
+}
+
+// Open implements hackpadfs.FS
+func (fs *flaggedFS) Open(name string) (hackpadfs.File, error) {
+	file, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &flaggedFile{File: file, name: name, options: fs.options}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *flaggedFS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	const writeFlags = hackpadfs.FlagWriteOnly | hackpadfs.FlagReadWrite | hackpadfs.FlagAppend | hackpadfs.FlagCreate | hackpadfs.FlagTruncate
+	if flag&writeFlags != 0 {
+		if err := fs.checkWritable("open", name); err != nil {
+			return nil, err
+		}
+	}
+	if flag&hackpadfs.FlagCreate != 0 && fs.options.NoCreate {
+		err := fs.checkCreate("open", name)
+		if !errors.Is(err, hackpadfs.ErrExist) {
+			return nil, err
+		}
+		if flag&hackpadfs.FlagExclusive != 0 {
+			return nil, err
+		}
+		// the file exists, so open it without permission to create it
+		flag &^= hackpadfs.FlagCreate | hackpadfs.FlagExclusive
+	}
+	file, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &flaggedFile{File: file, name: name, flag: flag, options: fs.options}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *flaggedFS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if err := fs.checkCreate("mkdir", name); err != nil {
+		return err
+	}
+	return hackpadfs.Mkdir(fs.fs, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *flaggedFS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if fs.options.ReadOnly || fs.options.NoCreate {
+		// succeed only if there is nothing to create
+		info, err := hackpadfs.Stat(fs.fs, path)
+		if err == nil && info.IsDir() {
+			return nil
+		}
+		if err == nil || errors.Is(err, hackpadfs.ErrNotExist) {
+			return &hackpadfs.PathError{Op: "mkdirall", Path: path, Err: hackpadfs.ErrPermission}
+		}
+		return err
+	}
+	return hackpadfs.MkdirAll(fs.fs, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *flaggedFS) Remove(name string) error {
+	if err := fs.checkWritable("remove", name); err != nil {
+		return err
+	}
+	return hackpadfs.Remove(fs.fs, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *flaggedFS) RemoveAll(path string) error {
+	if err := fs.checkWritable("removeall", path); err != nil {
+		return err
+	}
+	return hackpadfs.RemoveAll(fs.fs, path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *flaggedFS) Rename(oldname, newname string) error {
+	if fs.options.ReadOnly {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrPermission}
+	}
+	return hackpadfs.Rename(fs.fs, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *flaggedFS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.fs, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *flaggedFS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Lstat(fs.fs, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *flaggedFS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if err := fs.checkWritable("chmod", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chmod(fs.fs, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *flaggedFS) Chown(name string, uid, gid int) error {
+	if err := fs.checkWritable("chown", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chown(fs.fs, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *flaggedFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := fs.checkWritable("chtimes", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *flaggedFS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.fs, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *flaggedFS) ReadFile(name string) ([]byte, error) {
+	return hackpadfs.ReadFile(fs.fs, name)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *flaggedFS) Symlink(oldname, newname string) error {
+	if err := fs.checkCreate("symlink", newname); err != nil {
+		var pathErr *hackpadfs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	return hackpadfs.Symlink(fs.fs, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *flaggedFS) Link(oldname, newname string) error {
+	if err := fs.checkCreate("link", newname); err != nil {
+		var pathErr *hackpadfs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return &hackpadfs.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	return hackpadfs.Link(fs.fs, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *flaggedFS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *flaggedFS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *flaggedFS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.Statfs(fs.fs, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *flaggedFS) Watch(name string) (hackpadfs.Watcher, error) {
+	return hackpadfs.Watch(fs.fs, name)
+}
+
+// GetXattr implements hackpadfs.XattrFS
+func (fs *flaggedFS) GetXattr(name, attr string) ([]byte, error) {
+	return hackpadfs.GetXattr(fs.fs, name, attr)
+}
+
+// SetXattr implements hackpadfs.XattrFS
+func (fs *flaggedFS) SetXattr(name, attr string, value []byte) error {
+	if err := fs.checkWritable("setxattr", name); err != nil {
+		return err
+	}
+	return hackpadfs.SetXattr(fs.fs, name, attr, value)
+}
+
+// ListXattr implements hackpadfs.XattrFS
+func (fs *flaggedFS) ListXattr(name string) ([]string, error) {
+	return hackpadfs.ListXattr(fs.fs, name)
+}
+
+// RemoveXattr implements hackpadfs.XattrFS
+func (fs *flaggedFS) RemoveXattr(name, attr string) error {
+	if err := fs.checkWritable("removexattr", name); err != nil {
+		return err
+	}
+	return hackpadfs.RemoveXattr(fs.fs, name, attr)
+}
+
+// flaggedFile enforces MountOptions on an open file
+type flaggedFile struct {
+	hackpadfs.File
+	name    string
+	flag    int
+	options MountOptions
+}
+
+func (f *flaggedFile) checkWritable(op string) error {
+	if f.options.ReadOnly {
+		return &hackpadfs.PathError{Op: op, Path: f.name, Err: hackpadfs.ErrPermission}
+	}
+	return nil
+}
+
+// limitWrite returns the prefix of p that fits below MaxFileSize when written at 'offset', and whether p was cut short
+func (f *flaggedFile) limitWrite(p []byte, offset int64) ([]byte, bool) {
+	maxSize := f.options.MaxFileSize
+	if maxSize <= 0 || offset+int64(len(p)) <= maxSize {
+		return p, false
+	}
+	if offset >= maxSize {
+		return nil, true
+	}
+	return p[:maxSize-offset], true
+}
+
+func (f *flaggedFile) writeOffset() (int64, error) {
+	if f.flag&hackpadfs.FlagAppend != 0 {
+		info, err := f.File.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	return hackpadfs.SeekFile(f.File, 0, io.SeekCurrent)
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *flaggedFile) Read(p []byte) (int, error) {
+	return f.File.Read(p)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *flaggedFile) Write(p []byte) (int, error) {
+	if err := f.checkWritable("write"); err != nil {
+		return 0, err
+	}
+	tooLarge := false
+	if f.options.MaxFileSize > 0 {
+		offset, err := f.writeOffset()
+		if err != nil {
+			return 0, err
+		}
+		p, tooLarge = f.limitWrite(p, offset)
+	}
+	n, err := hackpadfs.WriteFile(f.File, p)
+	if err == nil && tooLarge {
+		err = &hackpadfs.PathError{Op: "write", Path: f.name, Err: hackpadfs.ErrTooLarge}
+	}
+	return n, err
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *flaggedFile) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *flaggedFile) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.checkWritable("write"); err != nil {
+		return 0, err
+	}
+	p, tooLarge := f.limitWrite(p, off)
+	n, err := hackpadfs.WriteAtFile(f.File, p, off)
+	if err == nil && tooLarge {
+		err = &hackpadfs.PathError{Op: "write", Path: f.name, Err: hackpadfs.ErrTooLarge}
+	}
+	return n, err
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *flaggedFile) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *flaggedFile) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *flaggedFile) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *flaggedFile) Truncate(size int64) error {
+	if err := f.checkWritable("truncate"); err != nil {
+		return err
+	}
+	if f.options.MaxFileSize > 0 && size > f.options.MaxFileSize {
+		return &hackpadfs.PathError{Op: "truncate", Path: f.name, Err: hackpadfs.ErrTooLarge}
+	}
+	return hackpadfs.TruncateFile(f.File, size)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *flaggedFile) Chmod(mode hackpadfs.FileMode) error {
+	if err := f.checkWritable("chmod"); err != nil {
+		return err
+	}
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *flaggedFile) Chown(uid, gid int) error {
+	if err := f.checkWritable("chown"); err != nil {
+		return err
+	}
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *flaggedFile) Chtimes(atime time.Time, mtime time.Time) error {
+	if err := f.checkWritable("chtimes"); err != nil {
+		return err
+	}
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
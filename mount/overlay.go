@@ -0,0 +1,16 @@
+package mount
+
+import (
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/overlay"
+)
+
+// OverlayFS layers a writable 'upper' FS over a read-only 'lower' FS. See overlay.FS for details.
+//
+// Mount an OverlayFS with FS.AddMount() to layer patches over an embedded or tar-backed base image.
+type OverlayFS = overlay.FS
+
+// NewOverlayFS returns a new OverlayFS
+func NewOverlayFS(upper, lower hackpadfs.FS) (*OverlayFS, error) {
+	return overlay.NewFS(upper, lower)
+}
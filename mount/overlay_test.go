@@ -0,0 +1,175 @@
+package mount_test
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"github.com/hack-pad/hackpadfs/mount"
+)
+
+func TestOverlayFS(t *testing.T) {
+	t.Parallel()
+	newMemFS := func(tb testing.TB) *mem.FS {
+		fs, err := mem.NewFS()
+		if !assert.NoError(tb, err) {
+			tb.FailNow()
+		}
+		return fs
+	}
+
+	options := fstest.FSOptions{
+		Name: "overlay lower",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			lower := newMemFS(tb)
+			return lower, func() hackpadfs.FS {
+				fs, err := mount.NewOverlayFS(newMemFS(tb), lower)
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				return fs
+			}
+		}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+		ShouldSkip: func(facets fstest.Facets) bool {
+			// files copied up to the upper FS are not visible to files already opened in the lower FS
+			return strings.HasSuffix(facets.Name, "/fs.OpenFile/truncate_with_open_reader") ||
+				strings.HasSuffix(facets.Name, "/file.Truncate/visible_to_other_open_files")
+		},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+
+	options = fstest.FSOptions{
+		Name: "overlay upper",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := mount.NewOverlayFS(newMemFS(tb), newMemFS(tb))
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestOverlayFSLayers(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T) (fs *mount.OverlayFS, upper, lower *mem.FS) {
+		upper, err := mem.NewFS()
+		assert.NoError(t, err)
+		lower, err = mem.NewFS()
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.Mkdir(lower, "dir", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(lower, "dir/foo", []byte("foo"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(lower, "dir/bar", []byte("bar"), 0600))
+		fs, err = mount.NewOverlayFS(upper, lower)
+		assert.NoError(t, err)
+		return fs, upper, lower
+	}
+	entryNames := func(t *testing.T, fs hackpadfs.FS, name string) []string {
+		t.Helper()
+		entries, err := hackpadfs.ReadDir(fs, name)
+		assert.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	t.Run("copy up on write", func(t *testing.T) {
+		t.Parallel()
+		fs, upper, lower := newFS(t)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/foo", []byte("baz"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/new", []byte("new"), 0600))
+
+		b, err := hackpadfs.ReadFile(fs, "dir/foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "baz", string(b))
+		b, err = hackpadfs.ReadFile(lower, "dir/foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", string(b))
+		assert.Equal(t, []string{"foo", "new"}, entryNames(t, upper, "dir"))
+		assert.Equal(t, []string{"bar", "foo", "new"}, entryNames(t, fs, "dir"))
+	})
+
+	t.Run("whiteout on remove", func(t *testing.T) {
+		t.Parallel()
+		fs, _, lower := newFS(t)
+		assert.NoError(t, hackpadfs.Remove(fs, "dir/foo"))
+
+		_, err := hackpadfs.Stat(fs, "dir/foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		_, err = hackpadfs.Stat(fs, "dir/.wh.foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		assert.Equal(t, []string{"bar"}, entryNames(t, fs, "dir"))
+		assert.Equal(t, []string{"bar", "foo"}, entryNames(t, lower, "dir"))
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "dir/foo", []byte("baz"), 0600))
+		b, err := hackpadfs.ReadFile(fs, "dir/foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "baz", string(b))
+	})
+
+	t.Run("opaque directory", func(t *testing.T) {
+		t.Parallel()
+		fs, _, _ := newFS(t)
+		assert.NoError(t, hackpadfs.RemoveAll(fs, "dir"))
+		_, err := hackpadfs.Stat(fs, "dir")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+		assert.NoError(t, hackpadfs.Mkdir(fs, "dir", 0700))
+		assert.Equal(t, []string(nil), entryNames(t, fs, "dir"))
+		_, err = hackpadfs.Stat(fs, "dir/foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("rename lower directory", func(t *testing.T) {
+		t.Parallel()
+		fs, _, lower := newFS(t)
+		assert.NoError(t, hackpadfs.Rename(fs, "dir", "renamed"))
+
+		_, err := hackpadfs.Stat(fs, "dir")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		assert.Equal(t, []string{"bar", "foo"}, entryNames(t, fs, "renamed"))
+		assert.Equal(t, []string{"dir"}, entryNames(t, lower, "."))
+	})
+
+	t.Run("whiteout names are reserved", func(t *testing.T) {
+		t.Parallel()
+		fs, _, _ := newFS(t)
+		err := hackpadfs.WriteFullFile(fs, "dir/.wh.foo", nil, 0600)
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	})
+	t.Run("symlink across layers", func(t *testing.T) {
+		t.Parallel()
+		fs, upper, _ := newFS(t)
+		assert.NoError(t, hackpadfs.Symlink(upper, "dir", "link"))
+
+		b, err := hackpadfs.ReadFile(fs, "link/foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "foo", string(b))
+		assert.Equal(t, []string{"bar", "foo"}, entryNames(t, fs, "link"))
+		info, err := hackpadfs.Stat(fs, "link")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "link", info.Name())
+			assert.Equal(t, true, info.IsDir())
+		}
+
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "link/baz", []byte("baz"), 0600))
+		assert.Equal(t, []string{"bar", "baz", "foo"}, entryNames(t, fs, "dir"))
+		assert.NoError(t, hackpadfs.Remove(fs, "link/bar"))
+		assert.Equal(t, []string{"baz", "foo"}, entryNames(t, fs, "dir"))
+
+		assert.NoError(t, hackpadfs.Symlink(fs, "loop", "loop"))
+		_, err = hackpadfs.Stat(fs, "loop")
+		assert.Equal(t, &hackpadfs.PathError{Op: "stat", Path: "loop", Err: syscall.ELOOP}, err)
+	})
+}
@@ -0,0 +1,143 @@
+package mount
+
+import (
+	"io"
+	gofs "io/fs"
+	"path"
+	"sort"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// hasMounts returns true if a file system is mounted at or beneath 'name'
+func (fs *FS) hasMounts(name string) bool {
+	if name == "." {
+		name = ""
+	}
+	fs.mountsMu.RLock()
+	defer fs.mountsMu.RUnlock()
+	node := fs.mounts.get(name)
+	return node != nil && (node.entry != nil || len(node.children) > 0)
+}
+
+// childMounts returns the entries mounted directly inside directory 'name'
+func (fs *FS) childMounts(name string) []*mountEntry {
+	if name == "." {
+		name = ""
+	}
+	fs.mountsMu.RLock()
+	defer fs.mountsMu.RUnlock()
+	node := fs.mounts.get(name)
+	if node == nil {
+		return nil
+	}
+	var entries []*mountEntry
+	for _, child := range node.children {
+		if child.entry != nil {
+			entries = append(entries, child.entry)
+		}
+	}
+	return entries
+}
+
+// stitchDir replaces the mount points in 'entries', the contents of directory 'name', with the roots of their mounted file systems.
+// Mount points missing from 'entries' are added. Mount points with inaccessible roots are left as-is.
+func (fs *FS) stitchDir(name string, entries []hackpadfs.DirEntry) []hackpadfs.DirEntry {
+	mounts := fs.childMounts(name)
+	if len(mounts) == 0 {
+		return entries
+	}
+	stitched := make(map[string]hackpadfs.DirEntry, len(mounts))
+	for _, entry := range mounts {
+		info, err := fs.Stat(entry.path)
+		if err != nil {
+			continue
+		}
+		stitched[info.Name()] = gofs.FileInfoToDirEntry(info)
+	}
+	entries = append([]hackpadfs.DirEntry(nil), entries...)
+	for i, entry := range entries {
+		if mountEntry, ok := stitched[entry.Name()]; ok {
+			entries[i] = mountEntry
+			delete(stitched, entry.Name())
+		}
+	}
+	if len(stitched) == 0 {
+		return entries
+	}
+	for _, entry := range stitched {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+	return entries
+}
+
+// statName corrects the name of a mounted file system's root, which is reported as "." by the mounted file system
+func statName(name string, info hackpadfs.FileInfo) hackpadfs.FileInfo {
+	if info == nil || name == "." {
+		return info
+	}
+	if base := path.Base(name); info.Name() != base {
+		return &namedFileInfo{FileInfo: info, name: base}
+	}
+	return info
+}
+
+type namedFileInfo struct {
+	hackpadfs.FileInfo
+	name string
+}
+
+func (i *namedFileInfo) Name() string {
+	return i.name
+}
+
+// wrapDir stitches mount points into 'file' if it's a mount point or a directory containing mount points
+func (fs *FS) wrapDir(name string, file hackpadfs.File) hackpadfs.File {
+	if !fs.hasMounts(name) {
+		return file
+	}
+	return &mountDir{File: file, fs: fs, name: name}
+}
+
+// mountDir lists a directory's entries with mount points stitched in
+type mountDir struct {
+	hackpadfs.File
+	fs      *FS
+	name    string
+	entries []hackpadfs.DirEntry // nil until first ReadDir
+	offset  int
+}
+
+// Stat implements hackpadfs.File
+func (d *mountDir) Stat() (hackpadfs.FileInfo, error) {
+	info, err := d.File.Stat()
+	return statName(d.name, info), err
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (d *mountDir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	if d.entries == nil {
+		entries, err := hackpadfs.ReadDirFile(d.File, -1)
+		if err != nil {
+			return nil, err
+		}
+		entries = d.fs.stitchDir(d.name, entries)
+		d.entries = append(make([]hackpadfs.DirEntry, 0, len(entries)), entries...)
+	}
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
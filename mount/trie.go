@@ -0,0 +1,115 @@
+package mount
+
+import "strings"
+
+// mountTrie indexes mount entries by path segment, so resolving a path costs O(path segments) regardless of the number of mounts.
+// Not safe for concurrent use.
+type mountTrie struct {
+	root mountNode
+}
+
+type mountNode struct {
+	children map[string]*mountNode
+	entry    *mountEntry // nil if no file system is mounted at this node
+}
+
+// nextSegment returns the path segment starting at 'start' and the start of the following segment
+func nextSegment(path string, start int) (segment string, next int) {
+	end := strings.IndexByte(path[start:], '/')
+	if end < 0 {
+		return path[start:], len(path) + 1
+	}
+	return path[start : start+end], start + end + 1
+}
+
+// lookup returns the deepest entry mounted at or above 'path'. Does not allocate.
+func (t *mountTrie) lookup(path string) *mountEntry {
+	var result *mountEntry
+	node := &t.root
+	for start := 0; start < len(path); {
+		var segment string
+		segment, start = nextSegment(path, start)
+		node = node.children[segment]
+		if node == nil {
+			break
+		}
+		if node.entry != nil {
+			result = node.entry
+		}
+	}
+	return result
+}
+
+// get returns the node at 'path', or nil if it does not exist
+func (t *mountTrie) get(path string) *mountNode {
+	node := &t.root
+	for start := 0; start < len(path) && node != nil; {
+		var segment string
+		segment, start = nextSegment(path, start)
+		node = node.children[segment]
+	}
+	return node
+}
+
+// insert adds 'entry' at entry.path. Returns false if an entry already exists there.
+func (t *mountTrie) insert(entry *mountEntry) bool {
+	node := &t.root
+	path := entry.path
+	for start := 0; start < len(path); {
+		var segment string
+		segment, start = nextSegment(path, start)
+		child := node.children[segment]
+		if child == nil {
+			child = &mountNode{}
+			if node.children == nil {
+				node.children = make(map[string]*mountNode)
+			}
+			node.children[segment] = child
+		}
+		node = child
+	}
+	if node.entry != nil {
+		return false
+	}
+	node.entry = entry
+	return true
+}
+
+// remove deletes the entry at 'path' and prunes nodes left empty. Returns the removed entry, or nil if there was none.
+func (t *mountTrie) remove(path string) *mountEntry {
+	return t.root.remove(path, 0)
+}
+
+func (n *mountNode) remove(path string, start int) *mountEntry {
+	if start >= len(path) {
+		entry := n.entry
+		n.entry = nil
+		return entry
+	}
+	segment, next := nextSegment(path, start)
+	child := n.children[segment]
+	if child == nil {
+		return nil
+	}
+	entry := child.remove(path, next)
+	if child.entry == nil && len(child.children) == 0 {
+		delete(n.children, segment)
+	}
+	return entry
+}
+
+// entries returns all entries in the trie, in no particular order
+func (t *mountTrie) entries() []*mountEntry {
+	var entries []*mountEntry
+	var walk func(*mountNode)
+	walk = func(n *mountNode) {
+		if n.entry != nil {
+			entries = append(entries, n.entry)
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(&t.root)
+	return entries
+}
@@ -69,6 +69,64 @@ func TestStripErrPathPrefix(t *testing.T) {
 				Err: someError,
 			},
 		},
+		{
+			err: &PathError{
+				Op:   "foo",
+				Path: "bar",
+				Err:  someError,
+			},
+			name:         "mnt/bar",
+			mountSubPath: "bar",
+			expectErr: &PathError{
+				Op:   "foo",
+				Path: "mnt/bar",
+				Err:  someError,
+			},
+		},
+		{
+			err: &PathError{
+				Op:   "foo",
+				Path: ".",
+				Err:  someError,
+			},
+			name:         "mnt",
+			mountSubPath: ".",
+			expectErr: &PathError{
+				Op:   "foo",
+				Path: "mnt",
+				Err:  someError,
+			},
+		},
+		{
+			err: &LinkError{
+				Op:  "foo",
+				Old: "static/v2/baz/bar",
+				New: "static/v2/bat",
+				Err: someError,
+			},
+			name:         "assets/baz/bar",
+			mountSubPath: "static/v2/baz/bar",
+			expectErr: &LinkError{
+				Op:  "foo",
+				Old: "assets/baz/bar",
+				New: "assets/bat",
+				Err: someError,
+			},
+		},
+		{
+			err: &PathError{
+				Op:   "foo",
+				Path: "other",
+				Err:  someError,
+			},
+			name:         "assets/bar",
+			mountSubPath: "static/bar",
+			expectErr: &PathError{
+				Op:   "foo",
+				Path: "other",
+				Err:  someError,
+			},
+		},
 	} {
 		tc := tc // enable parallel sub-tests
 		t.Run(fmt.Sprint(tc.err, tc.name, tc.mountSubPath), func(t *testing.T) {
@@ -78,3 +136,44 @@ func TestStripErrPathPrefix(t *testing.T) {
 		})
 	}
 }
+
+type chanWatcher struct {
+	events chan WatchEvent
+}
+
+func (w *chanWatcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+func (w *chanWatcher) Close() error {
+	close(w.events)
+	return nil
+}
+
+func TestMountWatcher(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name         string
+		mountSubPath string
+		eventName    string
+		expectName   string
+	}{
+		{name: ".", mountSubPath: ".", eventName: "foo", expectName: "foo"},
+		{name: "mnt", mountSubPath: ".", eventName: "foo", expectName: "mnt/foo"},
+		{name: "mnt/dir", mountSubPath: "dir", eventName: "dir/foo", expectName: "mnt/dir/foo"},
+		{name: "mnt/dir", mountSubPath: "dir", eventName: ".", expectName: "mnt"},
+	} {
+		tc := tc // enable parallel sub-tests
+		t.Run(fmt.Sprintf("%s in %s", tc.eventName, tc.name), func(t *testing.T) {
+			t.Parallel()
+			inner := &chanWatcher{events: make(chan WatchEvent, 1)}
+			watcher := newMountWatcher(inner, tc.name, tc.mountSubPath)
+			inner.events <- WatchEvent{Name: tc.eventName, Op: WatchWrite}
+			assert.Equal(t, WatchEvent{Name: tc.expectName, Op: WatchWrite}, <-watcher.Events())
+
+			assert.NoError(t, watcher.Close())
+			_, open := <-watcher.Events()
+			assert.Equal(t, false, open)
+		})
+	}
+}
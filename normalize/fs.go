@@ -0,0 +1,265 @@
+// Package normalize contains a file system wrapper which normalizes path separators and Unicode forms.
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.SubFS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+)
+
+// Options configures an FS
+type Options struct {
+	// Form is the Unicode normalization form of paths. Defaults to norm.NFC, the form produced by most keyboards and file systems.
+	// macOS clients often send paths in norm.NFD.
+	Form norm.Form
+	// PreserveUnicode disables Unicode normalization
+	PreserveUnicode bool
+	// PreserveBackslashes disables converting backslashes to slashes, for file systems which allow backslashes in file names
+	PreserveBackslashes bool
+}
+
+// FS wraps a file system to normalize paths before each operation, so equivalent paths from different clients resolve to the same file.
+//
+// Paths are normalized by converting backslashes to slashes, converting to one Unicode form, then removing empty and "." path elements.
+// For example, `\dir\\.\café` becomes "dir/café" in NFC. Leading slashes are also removed, so "/" is the FS root.
+// ".." elements are left in place, and are rejected by the wrapped FS as invalid paths.
+//
+// Only paths passed to FS methods are normalized, so names returned by ReadDir and Readlink are unchanged.
+// Files written without this wrapper may need normalizing first, to be found by normalized paths.
+type FS struct {
+	fs      hackpadfs.FS
+	options Options
+}
+
+// NewFS returns an FS which normalizes paths with 'options' before passing them to 'fs'
+func NewFS(fs hackpadfs.FS, options Options) *FS {
+	return &FS{
+		fs:      fs,
+		options: options,
+	}
+}
+
+// Clean returns the normalized form of 'name'
+func (fs *FS) Clean(name string) string {
+	name = fs.cleanTarget(name)
+	name = strings.TrimLeft(name, "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// cleanTarget returns the normalized form of symlink target 'name', which keeps its leading slash
+func (fs *FS) cleanTarget(name string) string {
+	if !fs.options.PreserveBackslashes {
+		name = strings.ReplaceAll(name, `\`, "/")
+	}
+	if !fs.options.PreserveUnicode {
+		name = fs.options.Form.String(name)
+	}
+	elems := strings.Split(name, "/")
+	cleanElems := elems[:1] // keep leading slashes as an empty first element
+	for _, elem := range elems[1:] {
+		if elem != "" && elem != "." {
+			cleanElems = append(cleanElems, elem)
+		}
+	}
+	switch {
+	case cleanElems[0] == "." && len(cleanElems) > 1:
+		cleanElems = cleanElems[1:]
+	case cleanElems[0] == "" && len(cleanElems) == 1 && name != "":
+		return "/"
+	}
+	return strings.Join(cleanElems, "/")
+}
+
+// withPath restores 'name' in errors for its normalized path, 'cleanName'
+func withPath(err error, name, cleanName string) error {
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) && pathErr.Path == cleanName {
+		return &hackpadfs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+	return err
+}
+
+// withLinkPaths restores 'oldname' and 'newname' in errors for their normalized paths
+func withLinkPaths(err error, oldname, cleanOld, newname, cleanNew string) error {
+	var linkErr *hackpadfs.LinkError
+	if errors.As(err, &linkErr) && linkErr.Old == cleanOld && linkErr.New == cleanNew {
+		return &hackpadfs.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) && pathErr.Path == cleanNew {
+		return withPath(err, newname, cleanNew)
+	}
+	return withPath(err, oldname, cleanOld)
+}
+
+// Sub implements hackpadfs.SubFS
+func (fs *FS) Sub(dir string) (hackpadfs.FS, error) {
+	cleanDir := fs.Clean(dir)
+	subFS, err := hackpadfs.Sub(fs.fs, cleanDir)
+	if err != nil {
+		return nil, withPath(err, dir, cleanDir)
+	}
+	return NewFS(subFS, fs.options), nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	cleanName := fs.Clean(name)
+	f, err := fs.fs.Open(cleanName)
+	return f, withPath(err, name, cleanName)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	cleanName := fs.Clean(name)
+	f, err := hackpadfs.OpenFile(fs.fs, cleanName, flag, perm)
+	return f, withPath(err, name, cleanName)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	cleanName := fs.Clean(name)
+	return withPath(hackpadfs.Mkdir(fs.fs, cleanName, perm), name, cleanName)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	cleanPath := fs.Clean(path)
+	return withPath(hackpadfs.MkdirAll(fs.fs, cleanPath, perm), path, cleanPath)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	cleanName := fs.Clean(name)
+	return withPath(hackpadfs.Remove(fs.fs, cleanName), name, cleanName)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	cleanPath := fs.Clean(path)
+	return withPath(hackpadfs.RemoveAll(fs.fs, cleanPath), path, cleanPath)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	cleanOld, cleanNew := fs.Clean(oldname), fs.Clean(newname)
+	return withLinkPaths(hackpadfs.Rename(fs.fs, cleanOld, cleanNew), oldname, cleanOld, newname, cleanNew)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	cleanName := fs.Clean(name)
+	info, err := hackpadfs.Stat(fs.fs, cleanName)
+	return info, withPath(err, name, cleanName)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	cleanName := fs.Clean(name)
+	info, err := hackpadfs.Lstat(fs.fs, cleanName)
+	return info, withPath(err, name, cleanName)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	cleanName := fs.Clean(name)
+	return withPath(hackpadfs.Chmod(fs.fs, cleanName, mode), name, cleanName)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	cleanName := fs.Clean(name)
+	return withPath(hackpadfs.Chown(fs.fs, cleanName, uid, gid), name, cleanName)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	cleanName := fs.Clean(name)
+	return withPath(hackpadfs.Chtimes(fs.fs, cleanName, atime, mtime), name, cleanName)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	cleanName := fs.Clean(name)
+	entries, err := hackpadfs.ReadDir(fs.fs, cleanName)
+	return entries, withPath(err, name, cleanName)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	cleanName := fs.Clean(name)
+	b, err := hackpadfs.ReadFile(fs.fs, cleanName)
+	return b, withPath(err, name, cleanName)
+}
+
+// Symlink implements hackpadfs.SymlinkFS. Targets are normalized too, but keep their leading slash and ".." elements.
+func (fs *FS) Symlink(oldname, newname string) error {
+	cleanOld, cleanNew := fs.cleanTarget(oldname), fs.Clean(newname)
+	return withLinkPaths(hackpadfs.Symlink(fs.fs, cleanOld, cleanNew), oldname, cleanOld, newname, cleanNew)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	cleanOld, cleanNew := fs.Clean(oldname), fs.Clean(newname)
+	return withLinkPaths(hackpadfs.Link(fs.fs, cleanOld, cleanNew), oldname, cleanOld, newname, cleanNew)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	cleanName := fs.Clean(name)
+	target, err := hackpadfs.Readlink(fs.fs, cleanName)
+	return target, withPath(err, name, cleanName)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	cleanName := fs.Clean(name)
+	unlocker, err := hackpadfs.Lock(fs.fs, cleanName, mode)
+	return unlocker, withPath(err, name, cleanName)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	cleanName := fs.Clean(name)
+	usage, err := hackpadfs.Statfs(fs.fs, cleanName)
+	return usage, withPath(err, name, cleanName)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	cleanName := fs.Clean(name)
+	watcher, err := hackpadfs.Watch(fs.fs, cleanName)
+	return watcher, withPath(err, name, cleanName)
+}
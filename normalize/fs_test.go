@@ -0,0 +1,121 @@
+package normalize
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	cafeNFC = "caf\u00e9"
+	cafeNFD = "cafe\u0301"
+)
+
+func newFS(tb testing.TB, options Options) *FS {
+	tb.Helper()
+	memFS, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return NewFS(memFS, options)
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "normalize",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return newFS(tb, Options{})
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestClean(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		options  Options
+		expected string
+	}{
+		{name: "", expected: "."},
+		{name: ".", expected: "."},
+		{name: "/", expected: "."},
+		{name: `\`, expected: "."},
+		{name: "./", expected: "."},
+		{name: "foo", expected: "foo"},
+		{name: "/foo/", expected: "foo"},
+		{name: "foo//bar///baz", expected: "foo/bar/baz"},
+		{name: "./foo/./bar/.", expected: "foo/bar"},
+		{name: `foo\bar\\baz`, expected: "foo/bar/baz"},
+		{name: `\foo\.\bar`, expected: "foo/bar"},
+		{name: "foo/../bar", expected: "foo/../bar"},
+		{name: `foo\bar`, options: Options{PreserveBackslashes: true}, expected: `foo\bar`},
+		{name: cafeNFD, expected: cafeNFC},
+		{name: cafeNFC, expected: cafeNFC},
+		{name: cafeNFC, options: Options{Form: norm.NFD}, expected: cafeNFD},
+		{name: cafeNFD, options: Options{PreserveUnicode: true}, expected: cafeNFD},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			fs := NewFS(nil, tc.options)
+			assert.Equal(t, tc.expected, fs.Clean(tc.name))
+		})
+	}
+}
+
+func TestCleanTarget(t *testing.T) {
+	t.Parallel()
+	fs := NewFS(nil, Options{})
+	for name, expected := range map[string]string{
+		"/":             "/",
+		"//foo//bar/":   "/foo/bar",
+		`..\foo`:        "../foo",
+		"./foo":         "foo",
+		"." + cafeNFD:   "." + cafeNFC,
+		"../" + cafeNFD: "../" + cafeNFC,
+	} {
+		assert.Equal(t, expected, fs.cleanTarget(name))
+	}
+}
+
+func TestEquivalentPaths(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{})
+	assert.NoError(t, hackpadfs.MkdirAll(fs, `docs\`+cafeNFD, 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "/docs//"+cafeNFD+"/menu.txt", []byte("menu"), 0600))
+
+	b, err := hackpadfs.ReadFile(fs, "docs/"+cafeNFC+"/menu.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "menu", string(b))
+	b, err = hackpadfs.ReadFile(fs.fs, "docs/"+cafeNFC+"/menu.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "menu", string(b))
+
+	entries, err := hackpadfs.ReadDir(fs, `docs\`)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, cafeNFC, entries[0].Name())
+	}
+
+	assert.NoError(t, hackpadfs.Symlink(fs, `..\docs\`+cafeNFD, "docs/link"))
+	target, err := hackpadfs.Readlink(fs, "docs/link")
+	assert.NoError(t, err)
+	assert.Equal(t, "../docs/"+cafeNFC, target)
+}
+
+func TestErrorPaths(t *testing.T) {
+	t.Parallel()
+	fs := newFS(t, Options{})
+	_, err := fs.Stat(`missing\` + cafeNFD)
+	assert.Equal(t, &hackpadfs.PathError{Op: "stat", Path: `missing\` + cafeNFD, Err: hackpadfs.ErrNotExist}, err)
+	_, err = fs.Open("../foo")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
@@ -0,0 +1,145 @@
+//go:build wasm
+// +build wasm
+
+// Package opfs contains a WebAssembly compatible file system. Uses the browser's Origin Private File System (OPFS) under the hood.
+//
+// File contents are stored as regular OPFS files, so they can be read and written by other OPFS clients.
+// OPFS does not track file modes, so each directory holds a hidden metadata file with its entries' modes and modification times.
+// Inside dedicated workers, file contents are read and written through synchronous access handles, which are much faster than the asynchronous APIs available elsewhere.
+//
+// Metadata is cached in memory, so concurrent use of the same directories from multiple tabs or workers is not supported.
+package opfs
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/safejs"
+)
+
+// FS is a browser-based file system, storing files inside the Origin Private File System.
+type FS struct {
+	kv *keyvalue.FS
+}
+
+// Options provides configuration options for a new FS.
+type Options struct {
+	// Root is the slash-separated path of the OPFS directory to use as this FS's root, created if it doesn't exist. Defaults to the OPFS root directory.
+	Root string
+}
+
+// NewFS returns a new FS.
+// Returns an error wrapping hackpadfs.ErrNotImplemented if the browser does not support OPFS.
+func NewFS(ctx context.Context, options Options) (*FS, error) {
+	root, err := rootHandle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if options.Root != "" && options.Root != "." {
+		if !hackpadfs.ValidPath(options.Root) {
+			return nil, &hackpadfs.PathError{Op: "open", Path: options.Root, Err: hackpadfs.ErrInvalid}
+		}
+		for _, name := range strings.Split(path.Clean(options.Root), "/") {
+			root, err = getHandle(ctx, root, "getDirectoryHandle", name, true)
+			if err != nil {
+				return nil, &hackpadfs.PathError{Op: "open", Path: options.Root, Err: err}
+			}
+		}
+	}
+	kv, err := keyvalue.NewFS(newStore(root))
+	return &FS{kv: kv}, err
+}
+
+func rootHandle(ctx context.Context) (safejs.Value, error) {
+	navigator, err := safejs.Global().Get("navigator")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if navigator.IsUndefined() {
+		return safejs.Value{}, &hackpadfs.PathError{Op: "open", Path: ".", Err: hackpadfs.ErrNotImplemented}
+	}
+	storage, err := navigator.Get("storage")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if storage.IsUndefined() {
+		return safejs.Value{}, &hackpadfs.PathError{Op: "open", Path: ".", Err: hackpadfs.ErrNotImplemented}
+	}
+	getDirectory, err := storage.Get("getDirectory")
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	if getDirectory.Type() != safejs.TypeFunction {
+		return safejs.Value{}, &hackpadfs.PathError{Op: "open", Path: ".", Err: hackpadfs.ErrNotImplemented}
+	}
+	return callAwait(ctx, storage, "getDirectory")
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.kv.Open(name)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	return fs.kv.OpenFile(name, flag, perm)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return fs.kv.Mkdir(name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return fs.kv.MkdirAll(path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return fs.kv.Remove(name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	return fs.kv.RemoveAll(name)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	return fs.kv.Rename(oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return fs.kv.Stat(name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.kv.Chmod(name, mode)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.kv.Chtimes(name, atime, mtime)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return fs.kv.Symlink(oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return fs.kv.Readlink(name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return fs.kv.Lstat(name)
+}
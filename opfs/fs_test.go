@@ -0,0 +1,69 @@
+//go:build wasm
+// +build wasm
+
+package opfs
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+)
+
+const (
+	testDirPrefix = "hackpadfs-test-"
+)
+
+func makeFS(tb testing.TB) *FS {
+	root, err := rootHandle(context.Background())
+	if err != nil {
+		tb.Skip("OPFS is not supported:", err)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(1000))
+	assert.NoError(tb, err)
+	name := fmt.Sprintf("%s%d", testDirPrefix, n.Int64())
+
+	fs, err := NewFS(context.Background(), Options{Root: name})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		assert.NoError(tb, removeEntry(context.Background(), root, name))
+	})
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "opfs",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			return makeFS(tb)
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestMetadataFileHidden(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t)
+	assert.NoError(t, fs.Mkdir("dir", 0700))
+
+	entries, err := hackpadfs.ReadDir(fs, ".")
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"dir"}, names)
+
+	_, err = fs.Stat(metadataFileName)
+	assert.Error(t, err)
+}
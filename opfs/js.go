@@ -0,0 +1,72 @@
+//go:build wasm
+// +build wasm
+
+package opfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/jspromise"
+	"github.com/hack-pad/safejs"
+)
+
+// domError is a rejected DOMException, like a NotFoundError
+type domError struct {
+	name    string
+	message string
+}
+
+func (e *domError) Error() string {
+	return fmt.Sprintf("%s: %s", e.name, e.message)
+}
+
+func (e *domError) Is(target error) bool {
+	switch e.name {
+	case "NotFoundError":
+		return target == hackpadfs.ErrNotExist
+	case "InvalidModificationError":
+		return target == hackpadfs.ErrNotEmpty
+	case "NotAllowedError", "NoModificationAllowedError":
+		return target == hackpadfs.ErrPermission
+	default:
+		return false
+	}
+}
+
+func isTypeMismatch(err error) bool {
+	var domErr *domError
+	return errors.As(err, &domErr) && domErr.name == "TypeMismatchError"
+}
+
+func newDOMError(value safejs.Value) error {
+	jsName, err := value.Get("name")
+	if err != nil {
+		return err
+	}
+	name, err := jsName.String()
+	if err != nil {
+		return err
+	}
+	jsMessage, err := value.Get("message")
+	if err != nil {
+		return err
+	}
+	message, err := jsMessage.String()
+	if err != nil {
+		return err
+	}
+	return &domError{name: name, message: message}
+}
+
+// callAwait calls method 'm' on 'value' and awaits the returned promise. Rejections are returned as a *domError.
+func callAwait(ctx context.Context, value safejs.Value, m string, args ...any) (safejs.Value, error) {
+	result, err := jspromise.Call(ctx, value, m, args...)
+	var rejected *jspromise.RejectedError
+	if errors.As(err, &rejected) {
+		err = newDOMError(rejected.Value)
+	}
+	return result, err
+}
@@ -0,0 +1,420 @@
+//go:build wasm
+// +build wasm
+
+package opfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/indexeddb/idbblob"
+	"github.com/hack-pad/hackpadfs/keyvalue"
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
+	"github.com/hack-pad/safejs"
+)
+
+const (
+	// metadataFileName is the file in each directory holding its entries' modes and modification times, which OPFS does not track
+	metadataFileName = ".hackpadfs-metadata"
+
+	defaultDirMode  = hackpadfs.ModeDir | 0755
+	defaultFileMode = 0644
+)
+
+type metadata struct {
+	Mode    hackpadfs.FileMode `json:"mode"`
+	ModTime int64              `json:"modTime"` // Unix nanoseconds
+}
+
+var _ keyvalue.Store = &store{}
+
+type store struct {
+	root safejs.Value // FileSystemDirectoryHandle
+
+	mu       sync.Mutex
+	metadata map[string]map[string]metadata // dir path -> entry name -> metadata
+}
+
+func newStore(root safejs.Value) *store {
+	return &store{
+		root:     root,
+		metadata: make(map[string]map[string]metadata),
+	}
+}
+
+func (s *store) Get(ctx context.Context, p string) (keyvalue.FileRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path.Base(p) == metadataFileName {
+		return nil, hackpadfs.ErrNotExist
+	}
+	if p == "." {
+		meta, err := s.getMetadata(ctx, s.root, ".", ".")
+		if err != nil {
+			return nil, err
+		}
+		return s.newDirRecord(ctx, s.root, meta), nil
+	}
+
+	dir, base := path.Dir(p), path.Base(p)
+	dirHandle, err := s.dirHandle(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := s.getMetadata(ctx, dirHandle, dir, base)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := getHandle(ctx, dirHandle, "getFileHandle", base, false)
+	if isTypeMismatch(err) {
+		handle, err = getHandle(ctx, dirHandle, "getDirectoryHandle", base, false)
+		if err != nil {
+			return nil, err
+		}
+		return s.newDirRecord(ctx, handle, meta), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := callAwait(ctx, handle, "getFile")
+	if err != nil {
+		return nil, err
+	}
+	size, err := getInt(file, "size")
+	if err != nil {
+		return nil, err
+	}
+	if meta.Mode == 0 {
+		lastModified, err := getInt(file, "lastModified")
+		if err != nil {
+			return nil, err
+		}
+		meta = metadata{Mode: defaultFileMode, ModTime: time.UnixMilli(int64(lastModified)).UnixNano()}
+	}
+	getData := func() (blob.Blob, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return readFile(ctx, handle)
+	}
+	return keyvalue.NewBaseFileRecord(int64(size), time.Unix(0, meta.ModTime), meta.Mode, nil, getData, nil), nil
+}
+
+func (s *store) newDirRecord(ctx context.Context, handle safejs.Value, meta metadata) keyvalue.FileRecord {
+	if meta.Mode == 0 {
+		meta.Mode = defaultDirMode
+	}
+	getDirNames := func() ([]string, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return readDirNames(ctx, handle)
+	}
+	return keyvalue.NewBaseFileRecord(0, time.Unix(0, meta.ModTime), meta.Mode, nil, nil, getDirNames)
+}
+
+func (s *store) Set(ctx context.Context, p string, src keyvalue.FileRecord) error {
+	var data blob.Blob
+	if src != nil && !src.Mode().IsDir() {
+		var err error
+		data, err = src.Data() // read before locking, since data may load from this store
+		if err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if path.Base(p) == metadataFileName {
+		return hackpadfs.ErrPermission
+	}
+	if p == "." {
+		if src == nil {
+			return hackpadfs.ErrPermission
+		}
+		return s.setMetadata(ctx, s.root, ".", ".", src)
+	}
+
+	dir, base := path.Dir(p), path.Base(p)
+	dirHandle, err := s.dirHandle(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		err := removeEntry(ctx, dirHandle, base)
+		if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+			return err
+		}
+		s.forgetMetadata(p)
+		return s.setMetadata(ctx, dirHandle, dir, base, nil)
+	}
+
+	if src.Mode().IsDir() {
+		_, err = createHandle(ctx, dirHandle, "getDirectoryHandle", base)
+	} else {
+		var handle safejs.Value
+		handle, err = createHandle(ctx, dirHandle, "getFileHandle", base)
+		if err == nil {
+			err = writeFile(ctx, handle, data)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return s.setMetadata(ctx, dirHandle, dir, base, src)
+}
+
+// dirHandle walks from the root to the directory handle for 'dir'
+func (s *store) dirHandle(ctx context.Context, dir string) (safejs.Value, error) {
+	handle := s.root
+	if dir == "." {
+		return handle, nil
+	}
+	for _, name := range strings.Split(dir, "/") {
+		var err error
+		handle, err = getHandle(ctx, handle, "getDirectoryHandle", name, false)
+		if isTypeMismatch(err) {
+			return safejs.Value{}, hackpadfs.ErrNotDir
+		}
+		if err != nil {
+			return safejs.Value{}, err
+		}
+	}
+	return handle, nil
+}
+
+// getMetadata returns the metadata for entry 'name' in 'dir'. Returns a zero metadata if there is none, like for files created outside this FS.
+func (s *store) getMetadata(ctx context.Context, dirHandle safejs.Value, dir, name string) (metadata, error) {
+	entries, err := s.dirMetadata(ctx, dirHandle, dir)
+	return entries[name], err
+}
+
+func (s *store) dirMetadata(ctx context.Context, dirHandle safejs.Value, dir string) (map[string]metadata, error) {
+	if entries, ok := s.metadata[dir]; ok {
+		return entries, nil
+	}
+	entries := make(map[string]metadata)
+	handle, err := getHandle(ctx, dirHandle, "getFileHandle", metadataFileName, false)
+	if err == nil {
+		var data blob.Blob
+		data, err = readFile(ctx, handle)
+		if err == nil && data.Len() > 0 {
+			err = json.Unmarshal(data.Bytes(), &entries)
+		}
+	}
+	if err != nil && !errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, err
+	}
+	s.metadata[dir] = entries
+	return entries, nil
+}
+
+// setMetadata records 'src's mode and modification time for entry 'name' in 'dir'. Removes the entry if 'src' is nil.
+func (s *store) setMetadata(ctx context.Context, dirHandle safejs.Value, dir, name string, src keyvalue.FileRecord) error {
+	entries, err := s.dirMetadata(ctx, dirHandle, dir)
+	if err != nil {
+		return err
+	}
+	newEntries := make(map[string]metadata, len(entries)+1)
+	for entryName, meta := range entries {
+		newEntries[entryName] = meta
+	}
+	if src == nil {
+		delete(newEntries, name)
+	} else {
+		newEntries[name] = metadata{Mode: src.Mode(), ModTime: src.ModTime().UnixNano()}
+	}
+	data, err := json.Marshal(newEntries)
+	if err != nil {
+		return err
+	}
+	handle, err := createHandle(ctx, dirHandle, "getFileHandle", metadataFileName)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(ctx, handle, blob.NewBytes(data)); err != nil {
+		return err
+	}
+	s.metadata[dir] = newEntries
+	return nil
+}
+
+// forgetMetadata drops cached metadata for directory 'p' and its descendants
+func (s *store) forgetMetadata(p string) {
+	for dir := range s.metadata {
+		if dir == p || strings.HasPrefix(dir, p+"/") {
+			delete(s.metadata, dir)
+		}
+	}
+}
+
+func getHandle(ctx context.Context, dirHandle safejs.Value, method, name string, create bool) (safejs.Value, error) {
+	options, err := safejs.ValueOf(map[string]any{"create": create})
+	if err != nil {
+		return safejs.Value{}, err
+	}
+	return callAwait(ctx, dirHandle, method, name, options)
+}
+
+// createHandle gets or creates the handle for 'name', replacing any existing entry of a different kind
+func createHandle(ctx context.Context, dirHandle safejs.Value, method, name string) (safejs.Value, error) {
+	handle, err := getHandle(ctx, dirHandle, method, name, true)
+	if !isTypeMismatch(err) {
+		return handle, err
+	}
+	if err := removeEntry(ctx, dirHandle, name); err != nil {
+		return safejs.Value{}, err
+	}
+	return getHandle(ctx, dirHandle, method, name, true)
+}
+
+func removeEntry(ctx context.Context, dirHandle safejs.Value, name string) error {
+	options, err := safejs.ValueOf(map[string]any{"recursive": true})
+	if err != nil {
+		return err
+	}
+	_, err = callAwait(ctx, dirHandle, "removeEntry", name, options)
+	return err
+}
+
+func readDirNames(ctx context.Context, dirHandle safejs.Value) ([]string, error) {
+	iter, err := dirHandle.Call("keys")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		next, err := callAwait(ctx, iter, "next")
+		if err != nil {
+			return nil, err
+		}
+		jsDone, err := next.Get("done")
+		if err != nil {
+			return nil, err
+		}
+		done, err := jsDone.Bool()
+		if err != nil || done {
+			return names, err
+		}
+		jsName, err := next.Get("value")
+		if err != nil {
+			return nil, err
+		}
+		name, err := jsName.String()
+		if err != nil {
+			return nil, err
+		}
+		if name != metadataFileName {
+			names = append(names, name)
+		}
+	}
+}
+
+// supportsSyncAccess returns true if 'fileHandle' can create synchronous access handles, which are only available in dedicated workers
+func supportsSyncAccess(fileHandle safejs.Value) bool {
+	fn, err := fileHandle.Get("createSyncAccessHandle")
+	return err == nil && fn.Type() == safejs.TypeFunction
+}
+
+func readFile(ctx context.Context, fileHandle safejs.Value) (blob.Blob, error) {
+	if !supportsSyncAccess(fileHandle) {
+		file, err := callAwait(ctx, fileHandle, "getFile")
+		if err != nil {
+			return nil, err
+		}
+		buf, err := callAwait(ctx, file, "arrayBuffer")
+		if err != nil {
+			return nil, err
+		}
+		data, err := uint8Array.New(buf)
+		if err != nil {
+			return nil, err
+		}
+		return idbblob.New(safejs.Unsafe(data))
+	}
+
+	access, err := callAwait(ctx, fileHandle, "createSyncAccessHandle")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _, _ = access.Call("close") }()
+	jsSize, err := access.Call("getSize")
+	if err != nil {
+		return nil, err
+	}
+	size, err := jsSize.Int()
+	if err != nil {
+		return nil, err
+	}
+	data, err := uint8Array.New(size)
+	if err != nil {
+		return nil, err
+	}
+	options, err := safejs.ValueOf(map[string]any{"at": 0})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := access.Call("read", data, options); err != nil {
+		return nil, err
+	}
+	return idbblob.New(safejs.Unsafe(data))
+}
+
+func writeFile(ctx context.Context, fileHandle safejs.Value, data blob.Blob) error {
+	if data == nil {
+		data = blob.NewBytes(nil)
+	}
+	jsData, err := idbblob.ToJS(data)
+	if err != nil {
+		return err
+	}
+	if !supportsSyncAccess(fileHandle) {
+		writable, err := callAwait(ctx, fileHandle, "createWritable")
+		if err != nil {
+			return err
+		}
+		if _, err := callAwait(ctx, writable, "write", jsData); err != nil {
+			_, _ = writable.Call("abort")
+			return err
+		}
+		_, err = callAwait(ctx, writable, "close")
+		return err
+	}
+
+	access, err := callAwait(ctx, fileHandle, "createSyncAccessHandle")
+	if err != nil {
+		return err
+	}
+	defer func() { _, _ = access.Call("close") }()
+	options, err := safejs.ValueOf(map[string]any{"at": 0})
+	if err != nil {
+		return err
+	}
+	if _, err := access.Call("write", jsData, options); err != nil {
+		return err
+	}
+	if _, err := access.Call("truncate", data.Len()); err != nil {
+		return err
+	}
+	_, err = access.Call("flush")
+	return err
+}
+
+func getInt(value safejs.Value, property string) (int, error) {
+	jsValue, err := value.Get(property)
+	if err != nil {
+		return 0, err
+	}
+	return jsValue.Int()
+}
+
+var uint8Array = func() safejs.Value {
+	value, err := safejs.Global().Get("Uint8Array")
+	if err != nil {
+		panic(err)
+	}
+	return value
+}()
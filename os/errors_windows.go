@@ -40,6 +40,8 @@ func (fs *FS) mapNonStandardError(err error) error {
 		ERROR_DIR_NOT_EMPTY = syscall.Errno(0x91)
 	)
 	switch errno {
+	case syscall.EWINDOWS:
+		return &mappedErr{hackpadfs.ErrNotImplemented, errno}
 	case ERROR_NEGATIVE_SEEK:
 		return &mappedErr{hackpadfs.ErrInvalid, errno}
 	case ERROR_DIR_NOT_EMPTY:
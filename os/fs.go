@@ -204,12 +204,17 @@ func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
 }
 
 // Chown implements hackpadfs.ChownFS
+//
+// A uid or gid of -1 leaves that value unchanged. Other negative IDs fail with hackpadfs.ErrInvalid.
 func (fs *FS) Chown(name string, uid, gid int) error {
-	name, err := fs.rootedPath("chown", name)
+	if uid < -1 || gid < -1 {
+		return &hackpadfs.PathError{Op: "chown", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	rootedName, err := fs.rootedPath("chown", name)
 	if err != nil {
 		return err
 	}
-	return fs.wrapErr(os.Chown(name, uid, gid))
+	return fs.wrapErr(os.Chown(rootedName, uid, gid))
 }
 
 // Chtimes implements hackpadfs.ChtimesFS
@@ -252,14 +257,67 @@ func (fs *FS) WriteFile(name string, data []byte, perm hackpadfs.FileMode) error
 }
 
 // Symlink implements hackpadfs.SymlinkFS
+//
+// Like os.Symlink(), relative targets are resolved against the directory containing the link.
+// Absolute targets are relative to the FS's root.
 func (fs *FS) Symlink(oldname, newname string) error {
-	oldname, pathErr := fs.rootedPath("symlink", oldname)
+	target := filepath.FromSlash(oldname)
+	if path.IsAbs(oldname) {
+		targetPath := strings.TrimPrefix(path.Clean(oldname), "/")
+		if targetPath == "" {
+			targetPath = "."
+		}
+		var pathErr *hackpadfs.PathError
+		target, pathErr = fs.rootedPath("symlink", targetPath)
+		if pathErr != nil {
+			return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: pathErr.Err}
+		}
+	}
+	linkName, pathErr := fs.rootedPath("symlink", newname)
 	if pathErr != nil {
 		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: pathErr.Err}
 	}
-	newname, pathErr = fs.rootedPath("symlink", newname)
+	return fs.wrapErr(os.Symlink(target, linkName))
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	rootedOld, pathErr := fs.rootedPath("link", oldname)
 	if pathErr != nil {
-		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: pathErr.Err}
+		return &hackpadfs.LinkError{Op: "link", Old: oldname, New: newname, Err: pathErr.Err}
+	}
+	rootedNew, pathErr := fs.rootedPath("link", newname)
+	if pathErr != nil {
+		return &hackpadfs.LinkError{Op: "link", Old: oldname, New: newname, Err: pathErr.Err}
+	}
+	return fs.wrapErr(os.Link(rootedOld, rootedNew))
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	name, pathErr := fs.rootedPath("readlink", name)
+	if pathErr != nil {
+		return "", pathErr
+	}
+	target, err := os.Readlink(name)
+	if err != nil {
+		return "", fs.wrapErr(err)
+	}
+	if !filepath.IsAbs(target) {
+		return filepath.ToSlash(target), nil
+	}
+	// absolute targets created by Symlink are rooted, so restore them to absolute FS paths
+	rootedPath, pathErr := fs.rootedPath("readlink", ".")
+	if pathErr != nil {
+		return "", pathErr
+	}
+	rootedPath = strings.TrimSuffix(rootedPath, string(filepath.Separator))
+	switch {
+	case target == rootedPath:
+		return "/", nil
+	case strings.HasPrefix(target, rootedPath+string(filepath.Separator)):
+		return filepath.ToSlash(strings.TrimPrefix(target, rootedPath)), nil
+	default:
+		return filepath.ToSlash(target), nil
 	}
-	return fs.wrapErr(os.Symlink(oldname, newname))
 }
@@ -4,11 +4,13 @@
 package os
 
 import (
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
+	"github.com/hack-pad/hackpadfs"
 	"github.com/hack-pad/hackpadfs/fstest"
 	"github.com/hack-pad/hackpadfs/internal/assert"
 )
@@ -42,6 +44,18 @@ func TestFSTest(t *testing.T) {
 			}
 			return subFS.(*FS)
 		},
+		Reopen: func(tb testing.TB, fs hackpadfs.FS) hackpadfs.FS {
+			osFS := fs.(*FS)
+			return &FS{root: osFS.root, volumeName: osFS.volumeName}
+		},
+		Owner:      fileOwner,
+		LargeFiles: true,
+	}
+	if runtime.GOOS == goosWindows || os.Geteuid() == 0 {
+		options.Constraints.AllowUnenforcedPermissions = true // Windows does not deny reads by permission bits, and the root user is not denied at all.
+	}
+	if runtime.GOOS == goosWindows || runtime.GOOS == "darwin" {
+		options.Constraints.CaseInsensitive = true // Windows and macOS file systems are case-insensitive by default.
 	}
 	var skipFacets []fstest.Facets
 	if runtime.GOOS == goosWindows {
@@ -51,6 +65,18 @@ func TestFSTest(t *testing.T) {
 			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/same_directory"},                       // Windows does not return an error for renaming a directory to itself.
 			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/newpath_is_directory"},                 // Windows returns an access denied error when renaming a file to an existing directory.
 			{Name: "TestFSTest/osfs.FS_FS/fs.Chmod/change_symlink_target_permission_bits"}, // Windows requires elevated permissions to create symlinks (sometimes).
+			{Name: "TestFSTest/osfs.FS_FS/fs.Symlink/relative_target_in_nested_directory"},
+			{Name: "TestFSTest/osfs.FS_FS/fs.Symlink/relative_target_in_parent_directory"},
+			{Name: "TestFSTest/osfs.FS_FS/fs.Chown/invalid_IDs"}, // Windows does not support Chown.
+			{Name: "TestFSTest/osfs.FS_FS/fs.Chown/change_owner"},
+			{Name: "TestFSTest/osfs.FS_FS/fs.Chown/keep_owner"},
+
+			// Windows returns access denied errors for invalid directory renames, instead of the POSIX errors.
+			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/file_onto_directory"},
+			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/non-empty_directory_onto_empty_directory"},
+			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/directory_onto_file"},
+			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/directory_into_itself"},
+			{Name: "TestFSTest/osfs.FS_FS/fs.Rename/open_file"}, // Windows does not allow renaming open files.
 		}
 	}
 	options.ShouldSkip = func(facets fstest.Facets) bool {
@@ -62,8 +88,80 @@ func TestFSTest(t *testing.T) {
 		return false
 	}
 
+	notImplementedFacets := []fstest.Facets{
+		{Name: "TestFSTest/osfs.FS_FS/fs.Lock"},  // os.FS does not implement advisory locks
+		{Name: "TestFSTest/osfs.FS_FS/fs.Watch"}, // os.FS does not implement watches
+		{Name: "TestFSTest/osfs.FS_FS/fs.Xattr"}, // os.FS does not implement extended attributes
+	}
+
 	data := fstest.FS(t, options)
-	assert.Subset(t, data.Skips, skipFacets)
+	assert.Subset(t, data.Skips, append(skipFacets, notImplementedFacets...))
+	assertFullCoverage(t, data)
 	data = fstest.File(t, options)
 	assert.Subset(t, data.Skips, skipFacets)
+	assertFullCoverage(t, data)
+}
+
+// notImplementedInterfaces are interfaces os.FS does not implement
+var notImplementedInterfaces = map[string]bool{
+	"LockFS":  true,
+	"WatchFS": true,
+	"XattrFS": true,
+}
+
+// assertFullCoverage asserts every interface covered by 'data' is implemented directly and exercised, other than notImplementedInterfaces
+func assertFullCoverage(tb testing.TB, data fstest.TestData) {
+	tb.Helper()
+	assert.NotZero(tb, len(data.Interfaces))
+	for _, iface := range data.Interfaces {
+		if notImplementedInterfaces[iface.Name] {
+			assert.Equal(tb, fstest.InterfaceCoverage{
+				Name:           iface.Name,
+				NotImplemented: true,
+			}, iface)
+			continue
+		}
+		assert.Equal(tb, fstest.InterfaceCoverage{
+			Name:        iface.Name,
+			Detected:    true,
+			Exercised:   true,
+			Constrained: iface.Constrained, // Windows requires constraints
+		}, iface)
+	}
+}
+
+func TestReadlink(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == goosWindows {
+		t.Skip("Windows requires elevated permissions to create symlinks (sometimes).")
+	}
+	dir := t.TempDir()
+	rootDir := filepath.Join(dir, "root")
+	siblingDir := filepath.Join(dir, "root2")
+	for _, d := range []string{rootDir, siblingDir} {
+		if !assert.NoError(t, os.Mkdir(d, 0700)) {
+			t.FailNow()
+		}
+	}
+	fs, err := NewFS().Sub(strings.TrimPrefix(rootDir, "/"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	rootFS := fs.(*FS)
+
+	assert.NoError(t, rootFS.Symlink("/foo", "absolute"))
+	target, err := rootFS.Readlink("absolute")
+	assert.NoError(t, err)
+	assert.Equal(t, "/foo", target)
+
+	assert.NoError(t, rootFS.Symlink("/", "root"))
+	target, err = rootFS.Readlink("root")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", target)
+
+	siblingTarget := filepath.Join(siblingDir, "foo")
+	assert.NoError(t, os.Symlink(siblingTarget, filepath.Join(rootDir, "sibling")))
+	target, err = rootFS.Readlink("sibling")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.ToSlash(siblingTarget), target)
 }
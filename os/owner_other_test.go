@@ -0,0 +1,8 @@
+//go:build plan9 || windows
+// +build plan9 windows
+
+package os
+
+import "github.com/hack-pad/hackpadfs"
+
+var fileOwner func(info hackpadfs.FileInfo) (uid, gid int)
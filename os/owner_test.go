@@ -0,0 +1,15 @@
+//go:build !plan9 && !windows && !wasm
+// +build !plan9,!windows,!wasm
+
+package os
+
+import (
+	"syscall"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var fileOwner = func(info hackpadfs.FileInfo) (uid, gid int) {
+	stat := info.Sys().(*syscall.Stat_t)
+	return int(stat.Uid), int(stat.Gid)
+}
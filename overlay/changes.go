@@ -0,0 +1,161 @@
+package overlay
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// ChangeKind is the kind of change in a Change
+type ChangeKind int
+
+// Change kinds
+const (
+	// ChangeAdd is a path which only exists in the upper FS
+	ChangeAdd ChangeKind = iota + 1
+	// ChangeModify is a path in the upper FS which replaces a lower path, or a directory merged with a lower directory
+	ChangeModify
+	// ChangeRemove is a lower path hidden by a whiteout or opaque directory
+	ChangeRemove
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdd:
+		return "add"
+	case ChangeModify:
+		return "modify"
+	case ChangeRemove:
+		return "remove"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+// Change is a path changed by the upper FS
+type Change struct {
+	Path string
+	Kind ChangeKind
+}
+
+// Changes returns every path the upper FS changes in the lower FSs, sorted by path.
+// Read the FS at each added or modified path to export its contents, like copying a sandbox's changes to a real disk.
+//
+// Like `docker diff`, directories merged with a lower directory are reported as modified, even if only their contents changed.
+// Removing a lower directory only reports the directory itself as removed.
+func (fs *FS) Changes() ([]Change, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	changes, err := fs.changes(".", fs.lowers, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(a, b int) bool {
+		return changes[a].Path < changes[b].Path
+	})
+	return changes, nil
+}
+
+// changes appends changes in upper directory 'dir' to 'changes'. 'layers' are the lower FSs with directories merged at 'dir', ignoring the upper FS.
+// If 'opaque' is set, all lower entries in 'dir' are hidden.
+func (fs *FS) changes(dir string, layers []hackpadfs.FS, opaque bool, changes []Change) ([]Change, error) {
+	entries, err := hackpadfs.ReadDir(fs.upper, dir)
+	if err != nil {
+		return nil, err
+	}
+	inUpper := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		switch name := entry.Name(); {
+		case name == opaqueName && dir != ".":
+			opaque = true
+		case !isWhiteoutName(name):
+			inUpper[name] = true
+		}
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case name == opaqueName:
+			continue
+		case isWhiteoutName(name):
+			if !opaque {
+				changes = append(changes, Change{Path: path.Join(dir, strings.TrimPrefix(name, whiteoutPrefix)), Kind: ChangeRemove})
+			}
+			continue
+		}
+		child := path.Join(dir, name)
+		childLayers, inLower, err := childDirLayers(layers, child)
+		if err != nil {
+			return nil, err
+		}
+		kind := ChangeAdd
+		if inLower {
+			kind = ChangeModify
+		}
+		changes = append(changes, Change{Path: child, Kind: kind})
+		if entry.IsDir() {
+			changes, err = fs.changes(child, childLayers, opaque, changes)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if opaque {
+		removed := make(map[string]bool)
+		for _, layer := range layers {
+			lowerEntries, err := hackpadfs.ReadDir(layer, dir)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range lowerEntries {
+				name := entry.Name()
+				if !inUpper[name] && !removed[name] && !isWhiteoutName(name) {
+					removed[name] = true
+					changes = append(changes, Change{Path: path.Join(dir, name), Kind: ChangeRemove})
+				}
+			}
+		}
+	}
+	return changes, nil
+}
+
+// childDirLayers returns the FSs in 'layers' with directories merged at 'name', and true if any of 'layers' contain 'name'
+func childDirLayers(layers []hackpadfs.FS, name string) ([]hackpadfs.FS, bool, error) {
+	var dirLayers []hackpadfs.FS
+	for _, layer := range layers {
+		info, err := hackpadfs.LstatOrStat(layer, name)
+		switch {
+		case errors.Is(err, hackpadfs.ErrNotExist) || errors.Is(err, hackpadfs.ErrNotDir):
+			continue
+		case err != nil:
+			return nil, false, err
+		case !info.IsDir():
+			// a file hides any directories below it
+			return dirLayers, true, nil
+		}
+		dirLayers = append(dirLayers, layer)
+	}
+	return dirLayers, len(dirLayers) > 0, nil
+}
+
+// Reset discards all changes in the upper FS, restoring the view of the lower FSs
+func (fs *FS) Reset() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	entries, err := hackpadfs.ReadDir(fs.upper, ".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := hackpadfs.RemoveAll(fs.upper, entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
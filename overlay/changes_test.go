@@ -0,0 +1,96 @@
+package overlay_test
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/overlay"
+)
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+	newFS := func(t *testing.T) *overlay.FS {
+		t.Helper()
+		base := newMemFS(t)
+		assert.NoError(t, hackpadfs.MkdirAll(base, "src/lib", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(base, "src/main.go", []byte("main"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(base, "src/lib/lib.go", []byte("lib"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(base, "README", []byte("readme"), 0600))
+		fs, err := overlay.NewFS(newMemFS(t), base)
+		assert.NoError(t, err)
+		return fs
+	}
+
+	t.Run("no changes", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		changes, err := fs.Changes()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(changes))
+	})
+
+	t.Run("add modify remove", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "src/main.go", []byte("changed"), 0600))
+		assert.NoError(t, hackpadfs.Mkdir(fs, "build", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "build/out", []byte("out"), 0600))
+		assert.NoError(t, hackpadfs.Remove(fs, "README"))
+		assert.NoError(t, hackpadfs.RemoveAll(fs, "src/lib"))
+
+		changes, err := fs.Changes()
+		assert.NoError(t, err)
+		assert.Equal(t, []overlay.Change{
+			{Path: "README", Kind: overlay.ChangeRemove},
+			{Path: "build", Kind: overlay.ChangeAdd},
+			{Path: "build/out", Kind: overlay.ChangeAdd},
+			{Path: "src", Kind: overlay.ChangeModify},
+			{Path: "src/lib", Kind: overlay.ChangeRemove},
+			{Path: "src/main.go", Kind: overlay.ChangeModify},
+		}, changes)
+	})
+
+	t.Run("replaced directory", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		assert.NoError(t, hackpadfs.RemoveAll(fs, "src"))
+		assert.NoError(t, hackpadfs.MkdirAll(fs, "src/lib", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "src/new.go", nil, 0600))
+
+		changes, err := fs.Changes()
+		assert.NoError(t, err)
+		assert.Equal(t, []overlay.Change{
+			{Path: "src", Kind: overlay.ChangeModify},
+			{Path: "src/lib", Kind: overlay.ChangeModify},
+			{Path: "src/lib/lib.go", Kind: overlay.ChangeRemove},
+			{Path: "src/main.go", Kind: overlay.ChangeRemove},
+			{Path: "src/new.go", Kind: overlay.ChangeAdd},
+		}, changes)
+	})
+
+	t.Run("kind names", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, "add", overlay.ChangeAdd.String())
+		assert.Equal(t, "modify", overlay.ChangeModify.String())
+		assert.Equal(t, "remove", overlay.ChangeRemove.String())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		t.Parallel()
+		fs := newFS(t)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "src/main.go", []byte("changed"), 0600))
+		assert.NoError(t, hackpadfs.RemoveAll(fs, "src/lib"))
+		assert.NoError(t, fs.Reset())
+
+		changes, err := fs.Changes()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(changes))
+		b, err := hackpadfs.ReadFile(fs, "src/main.go")
+		assert.NoError(t, err)
+		assert.Equal(t, "main", string(b))
+		b, err = hackpadfs.ReadFile(fs, "src/lib/lib.go")
+		assert.NoError(t, err)
+		assert.Equal(t, "lib", string(b))
+	})
+}
@@ -0,0 +1,908 @@
+// Package overlay contains a union file system, which layers a writable file system over read-only ones.
+package overlay
+
+import (
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	opaqueName     = whiteoutPrefix + whiteoutPrefix + ".opq"
+	maxSymlinkHops = 40 // same limit as Linux's MAXSYMLINKS
+)
+
+var errTooManyLinks = syscall.ELOOP
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.SymlinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.StatfsFS
+	} = &FS{}
+)
+
+// FS layers a writable 'upper' FS over an ordered list of read-only 'lower' FSs, like Linux's overlayfs.
+// Directories in all layers are merged. Files in a layer hide files with the same path in the layers below it, including directories.
+//
+// Lower FSs are never modified. Writing to a lower file first copies it up into the upper FS.
+// Removing a lower file records a whiteout file named ".wh.<name>" in the upper FS, and replacing a removed lower directory marks the new directory opaque with a ".wh..wh..opq" file.
+// Whiteout files are hidden, so names starting with ".wh." cannot be created.
+// Symlinks are resolved by the FS, so a symlink in one layer may point to files in another.
+//
+// For example, layer a user's config directory over an embed.FS of defaults, or mount an FS with mount.FS.AddMount() to layer patches over a tar-backed base image.
+// For copy-on-write sandboxes, like build or preview environments, list the upper FS's changes with Changes() and discard them with Reset().
+type FS struct {
+	upper  hackpadfs.FS
+	lowers []hackpadfs.FS
+	mu     sync.Mutex // serializes changes to the upper FS's layout
+}
+
+// NewFS returns a new FS with a writable 'upper' FS, layered over 'lowers' in order. The first lower FS is the top-most.
+func NewFS(upper hackpadfs.FS, lowers ...hackpadfs.FS) (*FS, error) {
+	return &FS{
+		upper:  upper,
+		lowers: append([]hackpadfs.FS(nil), lowers...),
+	}, nil
+}
+
+func whiteoutPath(name string) string {
+	return path.Join(path.Dir(name), whiteoutPrefix+path.Base(name))
+}
+
+func isWhiteoutName(name string) bool {
+	return strings.HasPrefix(path.Base(name), whiteoutPrefix)
+}
+
+func exists(fs hackpadfs.FS, name string) (bool, error) {
+	_, err := hackpadfs.LstatOrStat(fs, name)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, hackpadfs.ErrNotExist) || errors.Is(err, hackpadfs.ErrNotDir):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// lowerVisible returns true if 'name' in the lower FSs is not hidden by whiteouts, opaque directories, or non-directories in the upper FS
+func (fs *FS) lowerVisible(name string) (bool, error) {
+	if name == "." {
+		return true, nil
+	}
+	dir := "."
+	for _, elem := range strings.Split(name, "/") {
+		if dir != "." {
+			info, err := hackpadfs.LstatOrStat(fs.upper, dir)
+			switch {
+			case err == nil && !info.IsDir():
+				return false, nil
+			case err == nil:
+				if opaque, err := exists(fs.upper, path.Join(dir, opaqueName)); err != nil || opaque {
+					return false, err
+				}
+			case !errors.Is(err, hackpadfs.ErrNotExist):
+				return false, err
+			}
+		}
+		if whiteout, err := exists(fs.upper, path.Join(dir, whiteoutPrefix+elem)); err != nil || whiteout {
+			return false, err
+		}
+		dir = path.Join(dir, elem)
+	}
+	return true, nil
+}
+
+// dirLayers returns the lower FSs with visible directories merged at 'dir', top-most first.
+// Does not check if the upper FS hides 'dir'.
+func (fs *FS) dirLayers(dir string) ([]hackpadfs.FS, error) {
+	if dir == "." {
+		return fs.lowers, nil
+	}
+	parentLayers, err := fs.dirLayers(path.Dir(dir))
+	if err != nil {
+		return nil, err
+	}
+	var layers []hackpadfs.FS
+	for _, layer := range parentLayers {
+		info, err := hackpadfs.LstatOrStat(layer, dir)
+		switch {
+		case errors.Is(err, hackpadfs.ErrNotExist) || errors.Is(err, hackpadfs.ErrNotDir):
+			continue
+		case err != nil:
+			return nil, err
+		case !info.IsDir():
+			// a file hides any directories below it
+			return layers, nil
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// lowerLayer returns the top-most lower FS containing 'name' and its info, not following symlinks. Returns a nil FS if none contain 'name'.
+// Does not check if the upper FS hides 'name'.
+func (fs *FS) lowerLayer(name string) (hackpadfs.FS, hackpadfs.FileInfo, error) {
+	if name == "." {
+		if len(fs.lowers) == 0 {
+			return nil, nil, nil
+		}
+		info, err := hackpadfs.Stat(fs.lowers[0], name)
+		return fs.lowers[0], info, err
+	}
+	layers, err := fs.dirLayers(path.Dir(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, layer := range layers {
+		info, err := hackpadfs.LstatOrStat(layer, name)
+		switch {
+		case err == nil:
+			return layer, info, nil
+		case !errors.Is(err, hackpadfs.ErrNotExist):
+			return nil, nil, err
+		}
+	}
+	return nil, nil, nil
+}
+
+// lowerHas returns true if 'name' exists in a lower FS and is visible
+func (fs *FS) lowerHas(name string) (bool, error) {
+	visible, err := fs.lowerVisible(name)
+	if err != nil || !visible {
+		return false, err
+	}
+	layer, _, err := fs.lowerLayer(name)
+	return layer != nil, err
+}
+
+// layer returns the FS containing 'name' and its info, not following symlinks
+func (fs *FS) layer(op, name string) (hackpadfs.FS, hackpadfs.FileInfo, error) {
+	layer, info, err := fs.findLayer(name)
+	if err != nil {
+		var pathErr *hackpadfs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return nil, nil, &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	return layer, info, nil
+}
+
+func (fs *FS) findLayer(name string) (hackpadfs.FS, hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, nil, hackpadfs.ErrInvalid
+	}
+	if isWhiteoutName(name) {
+		return nil, nil, hackpadfs.ErrNotExist
+	}
+	info, err := hackpadfs.LstatOrStat(fs.upper, name)
+	if err == nil {
+		return fs.upper, info, nil
+	}
+	if !errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, nil, err
+	}
+	visible, err := fs.lowerVisible(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !visible {
+		return nil, nil, hackpadfs.ErrNotExist
+	}
+	layer, info, err := fs.lowerLayer(name)
+	switch {
+	case err != nil:
+		return nil, nil, err
+	case layer == nil:
+		return nil, nil, hackpadfs.ErrNotExist
+	}
+	return layer, info, nil
+}
+
+// resolve replaces symlinks in 'name' with their targets, so symlinks in one layer may point into another.
+// Symlinks in parent directories are always followed. The final path element is only followed if 'followLast' is set.
+// Relative targets are relative to the symlink's directory and absolute targets are relative to the FS's root.
+//
+// Paths which don't resolve are returned as-is, leaving the caller's operation to report the error.
+func (fs *FS) resolve(op, name string, followLast bool) (string, error) {
+	resolved := name
+	for hops := 0; hops <= maxSymlinkHops; hops++ {
+		layer, info, err := fs.findLayer(resolved)
+		var linkPath string
+		switch {
+		case err == nil && followLast && isSymlink(info):
+			linkPath = resolved
+		case err == nil || !errors.Is(err, hackpadfs.ErrNotExist):
+			return resolved, nil
+		default:
+			layer, linkPath, err = fs.parentLink(resolved)
+			if err != nil || linkPath == "" {
+				return resolved, nil
+			}
+		}
+		target, err := hackpadfs.Readlink(layer, linkPath)
+		if err != nil {
+			return resolved, nil
+		}
+		targetPath, ok := linkTargetPath(path.Dir(linkPath), target)
+		if !ok {
+			return resolved, nil
+		}
+		if linkPath != resolved {
+			targetPath = path.Join(targetPath, strings.TrimPrefix(resolved, linkPath+"/"))
+		}
+		resolved = targetPath
+	}
+	return "", &hackpadfs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+}
+
+// parentLink returns the first parent directory of 'name' which is a symlink, or "" if there are none
+func (fs *FS) parentLink(name string) (hackpadfs.FS, string, error) {
+	for i := 0; i < len(name); i++ {
+		if name[i] != '/' {
+			continue
+		}
+		dir := name[:i]
+		layer, info, err := fs.findLayer(dir)
+		switch {
+		case err != nil:
+			return nil, "", err
+		case isSymlink(info):
+			return layer, dir, nil
+		case !info.IsDir():
+			return nil, "", nil
+		}
+	}
+	return nil, "", nil
+}
+
+func isSymlink(info hackpadfs.FileInfo) bool {
+	return info.Mode()&hackpadfs.ModeSymlink != 0
+}
+
+// linkTargetPath returns the FS path for 'target' of a symlink inside 'linkDir'. Returns false if the target is outside the FS.
+func linkTargetPath(linkDir, target string) (string, bool) {
+	var targetPath string
+	if path.IsAbs(target) {
+		targetPath = strings.TrimPrefix(path.Clean(target), "/")
+		if targetPath == "" {
+			targetPath = "."
+		}
+	} else {
+		targetPath = path.Join(linkDir, target)
+	}
+	return targetPath, hackpadfs.ValidPath(targetPath)
+}
+
+// restorePath reports errors for operations on 'resolved' with the caller's 'name'
+func restorePath(err error, name, resolved string) error {
+	var pathErr *hackpadfs.PathError
+	if name == resolved || !errors.As(err, &pathErr) {
+		return err
+	}
+	return &hackpadfs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+}
+
+// mergedLayers returns the lower FSs with entries listed in the upper FS's directory 'dir', top-most first
+func (fs *FS) mergedLayers(dir string) ([]hackpadfs.FS, error) {
+	if dir != "." {
+		if opaque, err := exists(fs.upper, path.Join(dir, opaqueName)); err != nil || opaque {
+			return nil, err
+		}
+	}
+	visible, err := fs.lowerVisible(dir)
+	if err != nil || !visible {
+		return nil, err
+	}
+	return fs.dirLayers(dir)
+}
+
+// copyUp copies 'name' and its parent directories from the lower FSs into the upper FS, if not already present
+func (fs *FS) copyUp(name string) error {
+	if name == "." {
+		return nil
+	}
+	inUpper, err := exists(fs.upper, name)
+	if err != nil || inUpper {
+		return err
+	}
+	if err := fs.copyUp(path.Dir(name)); err != nil {
+		return err
+	}
+	layer, info, err := fs.lowerLayer(name)
+	switch {
+	case err != nil:
+		return err
+	case layer == nil:
+		return &hackpadfs.PathError{Op: "copyup", Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	switch {
+	case info.Mode()&hackpadfs.ModeSymlink != 0:
+		target, err := hackpadfs.Readlink(layer, name)
+		if err != nil {
+			return err
+		}
+		return hackpadfs.Symlink(fs.upper, target, name)
+	case info.IsDir():
+		err = hackpadfs.Mkdir(fs.upper, name, info.Mode().Perm())
+	default:
+		err = fs.copyUpFile(layer, name, info)
+	}
+	if err != nil {
+		return err
+	}
+	err = hackpadfs.Chtimes(fs.upper, name, info.ModTime(), info.ModTime())
+	if errors.Is(err, hackpadfs.ErrNotImplemented) {
+		err = nil
+	}
+	return err
+}
+
+func (fs *FS) copyUpFile(lower hackpadfs.FS, name string, info hackpadfs.FileInfo) error {
+	src, err := lower.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	dest, err := hackpadfs.OpenFile(fs.upper, name, hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagExclusive, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	destWriter, ok := dest.(io.Writer)
+	if !ok {
+		_ = dest.Close()
+		return &hackpadfs.PathError{Op: "copyup", Path: name, Err: hackpadfs.ErrNotImplemented}
+	}
+	_, err = io.Copy(destWriter, src)
+	closeErr := dest.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyUpAll copies 'name' into the upper FS. If 'name' is a directory, copies up all of its contents too.
+func (fs *FS) copyUpAll(name string) error {
+	if err := fs.copyUp(name); err != nil {
+		return err
+	}
+	info, err := hackpadfs.LstatOrStat(fs.upper, name)
+	if err != nil || !info.IsDir() {
+		return err
+	}
+	entries, err := fs.ReadDir(name)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := fs.copyUpAll(path.Join(name, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareCreate copies up the parent directory of 'name' and clears any whiteout at 'name'. Returns true if a whiteout was removed.
+func (fs *FS) prepareCreate(op, name string) (bool, error) {
+	if isWhiteoutName(name) {
+		return false, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	dir := path.Dir(name)
+	if _, info, err := fs.layer(op, dir); err != nil {
+		return false, &hackpadfs.PathError{Op: op, Path: name, Err: errors.Unwrap(err)}
+	} else if !info.IsDir() {
+		return false, &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotDir}
+	}
+	if err := fs.copyUp(dir); err != nil {
+		return false, err
+	}
+	err := hackpadfs.Remove(fs.upper, whiteoutPath(name))
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (fs *FS) whiteout(name string) error {
+	if err := fs.copyUp(path.Dir(name)); err != nil {
+		return err
+	}
+	return hackpadfs.WriteFullFile(fs.upper, whiteoutPath(name), nil, 0600)
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	resolved, err := fs.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	layer, info, err := fs.layer("open", resolved)
+	if err != nil {
+		return nil, restorePath(err, name, resolved)
+	}
+	file, err := layer.Open(resolved)
+	if err != nil || !info.IsDir() {
+		return file, restorePath(err, name, resolved)
+	}
+	return &overlayDir{File: file, fs: fs, name: resolved}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if flag == hackpadfs.FlagReadOnly {
+		return fs.Open(name)
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	resolved, err := fs.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	file, err := fs.openFile(resolved, flag, perm)
+	return file, restorePath(err, name, resolved)
+}
+
+func (fs *FS) openFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	_, _, err := fs.layer("open", name)
+	switch {
+	case err == nil:
+		if flag&hackpadfs.FlagCreate != 0 && flag&hackpadfs.FlagExclusive != 0 {
+			return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrExist}
+		}
+		err = fs.copyUp(name)
+	case errors.Is(err, hackpadfs.ErrNotExist) && flag&hackpadfs.FlagCreate != 0:
+		_, err = fs.prepareCreate("open", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hackpadfs.OpenFile(fs.upper, name, flag, perm)
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	resolved, err := fs.resolve("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+	return restorePath(fs.mkdir(resolved, perm), name, resolved)
+}
+
+func (fs *FS) mkdir(name string, perm hackpadfs.FileMode) error {
+	_, _, err := fs.layer("mkdir", name)
+	switch {
+	case err == nil:
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrExist}
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return err
+	}
+	replacesLower, err := fs.prepareCreate("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if err := hackpadfs.Mkdir(fs.upper, name, perm); err != nil {
+		return err
+	}
+	if replacesLower {
+		return hackpadfs.WriteFullFile(fs.upper, path.Join(name, opaqueName), nil, 0600)
+	}
+	return nil
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(path) {
+		return &hackpadfs.PathError{Op: "mkdirall", Path: path, Err: hackpadfs.ErrInvalid}
+	}
+	for i := 0; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' {
+			continue
+		}
+		err := fs.Mkdir(path[:i], perm)
+		if err == nil || !errors.Is(err, hackpadfs.ErrExist) {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		info, err := fs.Stat(path[:i])
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return &hackpadfs.PathError{Op: "mkdir", Path: path[:i], Err: hackpadfs.ErrNotDir}
+		}
+	}
+	return nil
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	resolved, err := fs.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+	return restorePath(fs.remove(resolved), name, resolved)
+}
+
+func (fs *FS) remove(name string) error {
+	if name == "." {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	layer, info, err := fs.layer("remove", name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := fs.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
+		}
+	}
+	lowerHas, err := fs.lowerHas(name)
+	if err != nil {
+		return err
+	}
+	if layer == fs.upper {
+		if info.IsDir() {
+			// directory may still contain whiteouts
+			err = hackpadfs.RemoveAll(fs.upper, name)
+		} else {
+			err = hackpadfs.Remove(fs.upper, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if lowerHas {
+		return fs.whiteout(name)
+	}
+	return nil
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	resolvedOld, err := fs.resolve("rename", oldname, false)
+	if err == nil {
+		var resolvedNew string
+		resolvedNew, err = fs.resolve("rename", newname, false)
+		if err == nil {
+			err = fs.rename(resolvedOld, resolvedNew)
+		}
+	}
+	if err != nil {
+		var pathErr *hackpadfs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+func (fs *FS) rename(oldname, newname string) error {
+	_, oldInfo, err := fs.layer("rename", oldname)
+	if err != nil {
+		return err
+	}
+	if oldname == newname {
+		if oldInfo.IsDir() {
+			return hackpadfs.ErrExist
+		}
+		return nil
+	}
+	_, newInfo, err := fs.layer("rename", newname)
+	switch {
+	case err == nil && oldInfo.IsDir() && newInfo.IsDir():
+		return hackpadfs.ErrExist
+	case err == nil && oldInfo.IsDir():
+		return hackpadfs.ErrNotDir
+	case err == nil && newInfo.IsDir():
+		// let the upper FS decide whether a file may replace a directory
+		if err := fs.copyUp(newname); err != nil {
+			return err
+		}
+	case err != nil && !errors.Is(err, hackpadfs.ErrNotExist):
+		return err
+	}
+
+	lowerHasOld, err := fs.lowerHas(oldname)
+	if err != nil {
+		return err
+	}
+	if err := fs.copyUpAll(oldname); err != nil {
+		return err
+	}
+	if _, err := fs.prepareCreate("rename", newname); err != nil {
+		return err
+	}
+	if err := hackpadfs.Rename(fs.upper, oldname, newname); err != nil {
+		return err
+	}
+	if lowerHasOld {
+		if err := fs.whiteout(oldname); err != nil {
+			return err
+		}
+	}
+	if oldInfo.IsDir() {
+		lowerHasNew, err := fs.lowerHas(newname)
+		if err != nil {
+			return err
+		}
+		if lowerHasNew {
+			// the renamed directory's contents were copied up in full, so hide the lower directory's contents
+			return hackpadfs.WriteFullFile(fs.upper, path.Join(newname, opaqueName), nil, 0600)
+		}
+	}
+	return nil
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	resolved, err := fs.resolve("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	layer, _, err := fs.layer("stat", resolved)
+	if err != nil {
+		return nil, restorePath(err, name, resolved)
+	}
+	info, err := hackpadfs.Stat(layer, resolved)
+	return statName(name, info), restorePath(err, name, resolved)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	resolved, err := fs.resolve("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	layer, _, err := fs.layer("lstat", resolved)
+	if err != nil {
+		return nil, restorePath(err, name, resolved)
+	}
+	info, err := hackpadfs.Lstat(layer, resolved)
+	return statName(name, info), restorePath(err, name, resolved)
+}
+
+// modify copies up the file at resolved path 'name', then runs fn against the upper FS
+func (fs *FS) modify(op, name string, fn func(upper hackpadfs.FS, resolved string) error) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	resolved, err := fs.resolve(op, name, true)
+	if err != nil {
+		return err
+	}
+	if _, _, err := fs.layer(op, resolved); err != nil {
+		return restorePath(err, name, resolved)
+	}
+	if err := fs.copyUp(resolved); err != nil {
+		return err
+	}
+	return restorePath(fn(fs.upper, resolved), name, resolved)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return fs.modify("chmod", name, func(upper hackpadfs.FS, resolved string) error {
+		return hackpadfs.Chmod(upper, resolved, mode)
+	})
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return fs.modify("chown", name, func(upper hackpadfs.FS, resolved string) error {
+		return hackpadfs.Chown(upper, resolved, uid, gid)
+	})
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return fs.modify("chtimes", name, func(upper hackpadfs.FS, resolved string) error {
+		return hackpadfs.Chtimes(upper, resolved, atime, mtime)
+	})
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	resolved, err := fs.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.readDir(resolved)
+	return entries, restorePath(err, name, resolved)
+}
+
+func (fs *FS) readDir(name string) ([]hackpadfs.DirEntry, error) {
+	layer, info, err := fs.layer("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+
+	var entries []hackpadfs.DirEntry
+	hidden := make(map[string]bool)
+	var lowers []hackpadfs.FS
+	if layer == fs.upper {
+		var upperEntries []hackpadfs.DirEntry
+		upperEntries, err = hackpadfs.ReadDir(fs.upper, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range upperEntries {
+			entryName := entry.Name()
+			if strings.HasPrefix(entryName, whiteoutPrefix) {
+				hidden[strings.TrimPrefix(entryName, whiteoutPrefix)] = true
+				continue
+			}
+			hidden[entryName] = true
+			entries = append(entries, entry)
+		}
+		lowers, err = fs.mergedLayers(name)
+	} else {
+		lowers, err = fs.dirLayers(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, lower := range lowers {
+		lowerEntries, err := hackpadfs.ReadDir(lower, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range lowerEntries {
+			entryName := entry.Name()
+			if !hidden[entryName] && !strings.HasPrefix(entryName, whiteoutPrefix) {
+				hidden[entryName] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(lowers) > 0 {
+		sort.Slice(entries, func(a, b int) bool {
+			return entries[a].Name() < entries[b].Name()
+		})
+	}
+	return entries, nil
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	resolved, err := fs.resolve("symlink", newname, false)
+	if err != nil {
+		return err
+	}
+	err = fs.symlink(oldname, resolved)
+	var linkErr *hackpadfs.LinkError
+	if resolved != newname && errors.As(err, &linkErr) {
+		err = &hackpadfs.LinkError{Op: linkErr.Op, Old: oldname, New: newname, Err: linkErr.Err}
+	}
+	return restorePath(err, newname, resolved)
+}
+
+func (fs *FS) symlink(oldname, newname string) error {
+	_, _, err := fs.layer("symlink", newname)
+	switch {
+	case err == nil:
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: hackpadfs.ErrExist}
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return err
+	}
+	if _, err := fs.prepareCreate("symlink", newname); err != nil {
+		return err
+	}
+	return hackpadfs.Symlink(fs.upper, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	resolved, err := fs.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	layer, _, err := fs.layer("readlink", resolved)
+	if err != nil {
+		return "", restorePath(err, name, resolved)
+	}
+	target, err := hackpadfs.Readlink(layer, resolved)
+	return target, restorePath(err, name, resolved)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.Statfs(fs.upper, ".")
+}
+
+// overlayDir lists a directory's merged entries from all layers
+type overlayDir struct {
+	hackpadfs.File
+	fs      *FS
+	name    string
+	entries []hackpadfs.DirEntry // nil until first ReadDir
+	offset  int
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (d *overlayDir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	if d.entries == nil {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = append(make([]hackpadfs.DirEntry, 0, len(entries)), entries...)
+	}
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
+
+func statName(name string, info hackpadfs.FileInfo) hackpadfs.FileInfo {
+	if info == nil || name == "." {
+		return info
+	}
+	if base := path.Base(name); info.Name() != base {
+		return &namedFileInfo{FileInfo: info, name: base}
+	}
+	return info
+}
+
+type namedFileInfo struct {
+	hackpadfs.FileInfo
+	name string
+}
+
+func (i *namedFileInfo) Name() string {
+	return i.name
+}
@@ -0,0 +1,192 @@
+package overlay_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+	"github.com/hack-pad/hackpadfs/overlay"
+)
+
+func newMemFS(tb testing.TB) *mem.FS {
+	tb.Helper()
+	fs, err := mem.NewFS()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	// files copied up to the upper FS are not visible to files already opened in a lower FS
+	skipCopyUpVisibility := func(facets fstest.Facets) bool {
+		return strings.HasSuffix(facets.Name, "/fs.OpenFile/truncate_with_open_reader") ||
+			strings.HasSuffix(facets.Name, "/file.Truncate/visible_to_other_open_files")
+	}
+	for _, tc := range []struct {
+		name       string
+		lowerIndex int // the lower FS written by test setup
+		lowerCount int
+	}{
+		{name: "overlay top lower", lowerIndex: 0, lowerCount: 3},
+		{name: "overlay bottom lower", lowerIndex: 2, lowerCount: 3},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+				lowers := make([]hackpadfs.FS, tc.lowerCount)
+				for i := range lowers {
+					lowers[i] = newMemFS(tb)
+				}
+				return lowers[tc.lowerIndex].(*mem.FS), func() hackpadfs.FS {
+					fs, err := overlay.NewFS(newMemFS(tb), lowers...)
+					if !assert.NoError(tb, err) {
+						tb.FailNow()
+					}
+					return fs
+				}
+			}),
+			Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+			ShouldSkip:  skipCopyUpVisibility,
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+
+	options := fstest.FSOptions{
+		Name: "overlay upper",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			fs, err := overlay.NewFS(newMemFS(tb), newMemFS(tb), newMemFS(tb))
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestFSLowers(t *testing.T) {
+	t.Parallel()
+	// layers, top-most first: user overrides over site config over embedded defaults
+	newFS := func(t *testing.T) (fs *overlay.FS, upper, site, defaults *mem.FS) {
+		t.Helper()
+		upper, site, defaults = newMemFS(t), newMemFS(t), newMemFS(t)
+		assert.NoError(t, hackpadfs.MkdirAll(defaults, "conf/themes", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(defaults, "conf/app.toml", []byte("defaults"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(defaults, "conf/log.toml", []byte("defaults"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(defaults, "conf/themes/dark", []byte("defaults"), 0600))
+		assert.NoError(t, hackpadfs.Mkdir(site, "conf", 0700))
+		assert.NoError(t, hackpadfs.WriteFullFile(site, "conf/app.toml", []byte("site"), 0600))
+		assert.NoError(t, hackpadfs.WriteFullFile(site, "conf/site.toml", []byte("site"), 0600))
+		var err error
+		fs, err = overlay.NewFS(upper, site, defaults)
+		assert.NoError(t, err)
+		return fs, upper, site, defaults
+	}
+	entryNames := func(t *testing.T, fs hackpadfs.FS, name string) []string {
+		t.Helper()
+		entries, err := hackpadfs.ReadDir(fs, name)
+		assert.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+	assertContents := func(t *testing.T, fs hackpadfs.FS, name, expected string) {
+		t.Helper()
+		b, err := hackpadfs.ReadFile(fs, name)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(b))
+	}
+
+	t.Run("top-most lower wins", func(t *testing.T) {
+		t.Parallel()
+		fs, _, _, _ := newFS(t)
+		assertContents(t, fs, "conf/app.toml", "site")
+		assertContents(t, fs, "conf/log.toml", "defaults")
+		assertContents(t, fs, "conf/site.toml", "site")
+		assert.Equal(t, []string{"app.toml", "log.toml", "site.toml", "themes"}, entryNames(t, fs, "conf"))
+	})
+
+	t.Run("open directory merges lowers", func(t *testing.T) {
+		t.Parallel()
+		fs, _, _, _ := newFS(t)
+		f, err := fs.Open("conf")
+		assert.NoError(t, err)
+		entries, err := hackpadfs.ReadDirFile(f, -1)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(entries))
+		assert.NoError(t, f.Close())
+	})
+
+	t.Run("file hides lower directory", func(t *testing.T) {
+		t.Parallel()
+		fs, _, site, _ := newFS(t)
+		assert.NoError(t, hackpadfs.WriteFullFile(site, "conf/themes", []byte("site"), 0600))
+		assertContents(t, fs, "conf/themes", "site")
+		_, err := hackpadfs.Stat(fs, "conf/themes/dark")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("copy up from bottom lower", func(t *testing.T) {
+		t.Parallel()
+		fs, upper, site, defaults := newFS(t)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "conf/themes/dark", []byte("user"), 0600))
+		assertContents(t, fs, "conf/themes/dark", "user")
+		assertContents(t, upper, "conf/themes/dark", "user")
+		assertContents(t, defaults, "conf/themes/dark", "defaults")
+		_, err := hackpadfs.Stat(site, "conf/themes")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		assert.Equal(t, []string{"app.toml", "log.toml", "site.toml", "themes"}, entryNames(t, fs, "conf"))
+	})
+
+	t.Run("whiteout hides all lowers", func(t *testing.T) {
+		t.Parallel()
+		fs, _, site, defaults := newFS(t)
+		assert.NoError(t, hackpadfs.Remove(fs, "conf/app.toml"))
+		_, err := hackpadfs.Stat(fs, "conf/app.toml")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		assert.Equal(t, []string{"log.toml", "site.toml", "themes"}, entryNames(t, fs, "conf"))
+		assertContents(t, site, "conf/app.toml", "site")
+		assertContents(t, defaults, "conf/app.toml", "defaults")
+	})
+
+	t.Run("opaque directory hides all lowers", func(t *testing.T) {
+		t.Parallel()
+		fs, _, _, _ := newFS(t)
+		assert.NoError(t, hackpadfs.RemoveAll(fs, "conf"))
+		assert.NoError(t, hackpadfs.Mkdir(fs, "conf", 0700))
+		assert.Equal(t, []string(nil), entryNames(t, fs, "conf"))
+		_, err := hackpadfs.Stat(fs, "conf/log.toml")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("rename merged directory", func(t *testing.T) {
+		t.Parallel()
+		fs, _, _, _ := newFS(t)
+		assert.NoError(t, hackpadfs.Rename(fs, "conf", "renamed"))
+		_, err := hackpadfs.Stat(fs, "conf")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		assert.Equal(t, []string{"app.toml", "log.toml", "site.toml", "themes"}, entryNames(t, fs, "renamed"))
+		assertContents(t, fs, "renamed/app.toml", "site")
+	})
+
+	t.Run("no lowers", func(t *testing.T) {
+		t.Parallel()
+		upper := newMemFS(t)
+		fs, err := overlay.NewFS(upper)
+		assert.NoError(t, err)
+		assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+		assertContents(t, upper, "foo", "foo")
+		assert.Equal(t, []string{"foo"}, entryNames(t, fs, "."))
+	})
+}
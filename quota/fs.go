@@ -0,0 +1,528 @@
+// Package quota contains a file system wrapper which limits total file sizes and file counts.
+package quota
+
+import (
+	"errors"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+// Options configures the limits of an FS
+type Options struct {
+	// MaxBytes limits the total size of all regular files. Unlimited if zero.
+	MaxBytes int64
+	// MaxFiles limits the number of files, directories, and symlinks, excluding the root directory. Unlimited if zero.
+	MaxFiles int64
+}
+
+// Usage is the space counted against an FS's limits
+type Usage struct {
+	// Bytes is the total size of all regular files. Each hard link counts a file's size again.
+	Bytes int64
+	// Files is the number of files, directories, and symlinks, excluding the root directory
+	Files int64
+}
+
+// FS wraps a writable file system to enforce Options limits on everything beneath its root.
+// Writes and new files beyond a limit fail with hackpadfs.ErrNoSpace. Writes which partially fit write as much as possible before failing.
+//
+// Usage is counted by scanning the wrapped FS in NewFS, then tracked as changes are made through FS.
+// Modifications are serialized to keep usage exact. Changes made directly to the wrapped FS are not counted until Rescan is called.
+type FS struct {
+	fs      hackpadfs.FS
+	options Options
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+// NewFS returns an FS which enforces 'options' limits on 'fs'. Scans all of 'fs' to count its current usage.
+func NewFS(fs hackpadfs.FS, options Options) (*FS, error) {
+	if options.MaxBytes < 0 || options.MaxFiles < 0 {
+		return nil, errors.New("MaxBytes and MaxFiles must not be negative")
+	}
+	quotaFS := &FS{
+		fs:      fs,
+		options: options,
+	}
+	return quotaFS, quotaFS.Rescan()
+}
+
+// Usage returns the current usage counted against the limits
+func (fs *FS) Usage() Usage {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.usage
+}
+
+// Rescan recounts usage by scanning the wrapped FS, correcting for changes made outside of this FS
+func (fs *FS) Rescan() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	usage, err := scanUsage(fs.fs, ".")
+	if err != nil {
+		return err
+	}
+	fs.usage = usage
+	return nil
+}
+
+// scanUsage returns the usage of everything beneath 'root', excluding 'root' itself
+func scanUsage(fs hackpadfs.FS, root string) (Usage, error) {
+	var usage Usage
+	err := hackpadfs.WalkDir(fs, root, func(name string, dir hackpadfs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root {
+			return nil
+		}
+		usage.Files++
+		if dir.Type().IsRegular() {
+			info, err := dir.Info()
+			if err != nil {
+				return err
+			}
+			usage.Bytes += info.Size()
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// entryUsage returns the usage of 'name' alone, or zero usage if it does not exist
+func (fs *FS) entryUsage(name string) (Usage, error) {
+	info, err := hackpadfs.LstatOrStat(fs.fs, name)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		return Usage{}, nil
+	}
+	if err != nil {
+		return Usage{}, err
+	}
+	usage := Usage{Files: 1}
+	if info.Mode().IsRegular() {
+		usage.Bytes = info.Size()
+	}
+	return usage, nil
+}
+
+// availableBytes returns the bytes left before reaching MaxBytes, or -1 if unlimited. Requires fs.mu to be held.
+func (fs *FS) availableBytes() int64 {
+	if fs.options.MaxBytes == 0 {
+		return -1
+	}
+	if fs.usage.Bytes >= fs.options.MaxBytes {
+		return 0
+	}
+	return fs.options.MaxBytes - fs.usage.Bytes
+}
+
+// checkFiles returns ErrNoSpace if 'count' more files would exceed MaxFiles. Requires fs.mu to be held.
+func (fs *FS) checkFiles(op, name string, count int64) error {
+	if fs.options.MaxFiles != 0 && fs.usage.Files+count > fs.options.MaxFiles {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNoSpace}
+	}
+	return nil
+}
+
+// checkBytes returns ErrNoSpace if 'count' more bytes would exceed MaxBytes. Requires fs.mu to be held.
+func (fs *FS) checkBytes(op, name string, count int64) error {
+	if available := fs.availableBytes(); available >= 0 && count > available {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNoSpace}
+	}
+	return nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, name: name, fs: fs}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	const writeFlags = hackpadfs.FlagWriteOnly | hackpadfs.FlagReadWrite | hackpadfs.FlagAppend | hackpadfs.FlagCreate | hackpadfs.FlagTruncate
+	if flag&writeFlags == 0 {
+		return fs.Open(name)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	before, err := fs.entryUsage(name)
+	if err != nil {
+		return nil, err
+	}
+	if before.Files == 0 && flag&hackpadfs.FlagCreate != 0 {
+		if err := fs.checkFiles("open", name, 1); err != nil {
+			return nil, err
+		}
+	}
+	f, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	after, err := fs.entryUsage(name)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	fs.usage.Files += after.Files - before.Files
+	fs.usage.Bytes += after.Bytes - before.Bytes
+	return &file{File: f, name: name, flag: flag, fs: fs}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.checkFiles("mkdir", name, 1); err != nil {
+		return err
+	}
+	err := hackpadfs.Mkdir(fs.fs, name, perm)
+	if err == nil {
+		fs.usage.Files++
+	}
+	return err
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(name string, perm hackpadfs.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	// count the directories to create
+	var missing int64
+	for dir := name; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		_, err := hackpadfs.Stat(fs.fs, dir)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, hackpadfs.ErrNotExist) {
+			return err
+		}
+		missing++
+	}
+	if err := fs.checkFiles("mkdirall", name, missing); err != nil {
+		return err
+	}
+	err := hackpadfs.MkdirAll(fs.fs, name, perm)
+	if err == nil {
+		fs.usage.Files += missing
+	}
+	return err
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	before, err := fs.entryUsage(name)
+	if err != nil {
+		return err
+	}
+	err = hackpadfs.Remove(fs.fs, name)
+	if err == nil {
+		fs.usage.Files -= before.Files
+		fs.usage.Bytes -= before.Bytes
+	}
+	return err
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	err := hackpadfs.RemoveAll(fs.fs, name)
+	// anything may have been removed, even on failure
+	usage, scanErr := scanUsage(fs.fs, ".")
+	if scanErr == nil {
+		fs.usage = usage
+	}
+	if err != nil {
+		return err
+	}
+	return scanErr
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	replaced, err := fs.entryUsage(newname)
+	if err != nil {
+		return err
+	}
+	err = hackpadfs.Rename(fs.fs, oldname, newname)
+	if err == nil && oldname != newname {
+		fs.usage.Files -= replaced.Files
+		fs.usage.Bytes -= replaced.Bytes
+	}
+	return err
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.fs, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Lstat(fs.fs, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return hackpadfs.Chmod(fs.fs, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return hackpadfs.Chown(fs.fs, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDir(fs.fs, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	return hackpadfs.ReadFile(fs.fs, name)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.checkFiles("symlink", newname, 1) != nil {
+		return &hackpadfs.LinkError{Op: "symlink", Old: oldname, New: newname, Err: hackpadfs.ErrNoSpace}
+	}
+	err := hackpadfs.Symlink(fs.fs, oldname, newname)
+	if err == nil {
+		fs.usage.Files++
+	}
+	return err
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	linked, err := fs.entryUsage(oldname)
+	if err != nil {
+		return err
+	}
+	if fs.checkFiles("link", newname, 1) != nil || fs.checkBytes("link", newname, linked.Bytes) != nil {
+		return &hackpadfs.LinkError{Op: "link", Old: oldname, New: newname, Err: hackpadfs.ErrNoSpace}
+	}
+	err = hackpadfs.Link(fs.fs, oldname, newname)
+	if err == nil {
+		fs.usage.Files++
+		fs.usage.Bytes += linked.Bytes
+	}
+	return err
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS. Reports usage against MaxBytes if set, otherwise the wrapped FS's usage.
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	if fs.options.MaxBytes == 0 {
+		return hackpadfs.Statfs(fs.fs, name)
+	}
+	if _, err := hackpadfs.Stat(fs.fs, name); err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return hackpadfs.FSUsage{
+		Total:     fs.options.MaxBytes,
+		Used:      fs.usage.Bytes,
+		Available: fs.availableBytes(),
+	}, nil
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	return hackpadfs.Watch(fs.fs, name)
+}
+
+// file enforces its FS's limits on writes and truncates
+type file struct {
+	hackpadfs.File
+	name string
+	flag int
+	fs   *FS
+}
+
+func (f *file) size() (int64, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// write calls 'writeFn' with the prefix of 'p' which fits in the quota when written at 'offset', then counts the file's growth. Requires f.fs.mu to be held.
+func (f *file) write(p []byte, offset int64, writeFn func(p []byte) (int, error)) (int, error) {
+	before, err := f.size()
+	if err != nil {
+		return 0, err
+	}
+	tooLarge := false
+	if available := f.fs.availableBytes(); available >= 0 {
+		fits := before + available - offset
+		if fits < 0 {
+			fits = 0
+		}
+		if int64(len(p)) > fits {
+			p, tooLarge = p[:fits], true
+		}
+	}
+	n, err := writeFn(p)
+	if after, sizeErr := f.size(); sizeErr == nil {
+		f.fs.usage.Bytes += after - before
+	}
+	if err == nil && tooLarge {
+		err = &hackpadfs.PathError{Op: "write", Path: f.name, Err: hackpadfs.ErrNoSpace}
+	}
+	return n, err
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	var offset int64
+	var err error
+	if f.flag&hackpadfs.FlagAppend != 0 {
+		offset, err = f.size()
+	} else {
+		offset, err = hackpadfs.SeekFile(f.File, 0, io.SeekCurrent)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return f.write(p, offset, func(p []byte) (int, error) {
+		return hackpadfs.WriteFile(f.File, p)
+	})
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.write(p, off, func(p []byte) (int, error) {
+		return hackpadfs.WriteAtFile(f.File, p, off)
+	})
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	before, err := f.size()
+	if err != nil {
+		return err
+	}
+	if err := f.fs.checkBytes("truncate", f.name, size-before); err != nil {
+		return err
+	}
+	err = hackpadfs.TruncateFile(f.File, size)
+	if after, sizeErr := f.size(); sizeErr == nil {
+		f.fs.usage.Bytes += after - before
+	}
+	return err
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
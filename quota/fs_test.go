@@ -0,0 +1,150 @@
+package quota
+
+import (
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	newSetup := func(options Options) fstest.TestSetup {
+		return fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			memFS, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return memFS, func() hackpadfs.FS {
+				fs, err := NewFS(memFS, options)
+				if !assert.NoError(tb, err) {
+					tb.FailNow()
+				}
+				return fs
+			}
+		})
+	}
+
+	options := fstest.FSOptions{
+		Name:        "quota",
+		Setup:       newSetup(Options{MaxBytes: 1 << 30, MaxFiles: 1 << 20}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+
+	options = fstest.FSOptions{
+		Name:        "quota with small size",
+		Setup:       newSetup(Options{MaxBytes: 1024}),
+		Quota:       fstest.QuotaOptions{Size: 1024},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+}
+
+func newFS(t *testing.T, options Options) (*mem.FS, *FS) {
+	t.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.MkdirAll(memFS, "foo/bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(memFS, "foo/bar/baz", []byte("hello"), 0600))
+	assert.NoError(t, hackpadfs.Symlink(memFS, "foo/bar/baz", "link"))
+	fs, err := NewFS(memFS, options)
+	assert.NoError(t, err)
+	return memFS, fs
+}
+
+func TestNewFSScansUsage(t *testing.T) {
+	t.Parallel()
+	_, fs := newFS(t, Options{})
+	assert.Equal(t, Usage{Bytes: 5, Files: 4}, fs.Usage())
+
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	_, err = NewFS(memFS, Options{MaxBytes: -1})
+	assert.Error(t, err)
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+	_, fs := newFS(t, Options{})
+
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/new", []byte("new"), 0600))
+	assert.Equal(t, Usage{Bytes: 8, Files: 5}, fs.Usage())
+
+	f, err := hackpadfs.OpenFile(fs, "foo/new", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("er"))
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.TruncateFile(f, 1))
+	assert.NoError(t, f.Close())
+	assert.Equal(t, Usage{Bytes: 6, Files: 5}, fs.Usage())
+
+	assert.NoError(t, hackpadfs.Rename(fs, "foo/new", "foo/bar/baz"))
+	assert.Equal(t, Usage{Bytes: 1, Files: 4}, fs.Usage())
+
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "a/b/c", 0700))
+	assert.Equal(t, Usage{Bytes: 1, Files: 7}, fs.Usage())
+
+	assert.NoError(t, hackpadfs.Remove(fs, "link"))
+	assert.NoError(t, hackpadfs.RemoveAll(fs, "foo"))
+	assert.Equal(t, Usage{Bytes: 0, Files: 3}, fs.Usage())
+}
+
+func TestMaxFiles(t *testing.T) {
+	t.Parallel()
+	_, fs := newFS(t, Options{MaxFiles: 5})
+
+	assert.NoError(t, hackpadfs.Mkdir(fs, "dir", 0700))
+	err := hackpadfs.WriteFullFile(fs, "new", nil, 0600)
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+	err = hackpadfs.Mkdir(fs, "new", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+	err = hackpadfs.MkdirAll(fs, "dir/new", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+	err = hackpadfs.Symlink(fs, "dir", "new")
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+
+	// existing files may still be written
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "foo/bar/baz", []byte("world"), 0600))
+	assert.NoError(t, hackpadfs.Remove(fs, "dir"))
+	assert.NoError(t, hackpadfs.WriteFullFile(fs, "new", nil, 0600))
+}
+
+func TestMaxBytes(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{MaxBytes: 10})
+
+	f, err := hackpadfs.OpenFile(fs, "foo/bar/baz", hackpadfs.FlagWriteOnly, 0)
+	assert.NoError(t, err)
+	// overwriting existing bytes is free
+	_, err = hackpadfs.WriteFile(f, []byte("HELLO"))
+	assert.NoError(t, err)
+	n, err := hackpadfs.WriteFile(f, []byte(" world"))
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+	assert.Equal(t, 5, n)
+	err = hackpadfs.TruncateFile(f, 11)
+	assert.ErrorIs(t, hackpadfs.ErrNoSpace, err)
+	assert.NoError(t, f.Close())
+
+	b, err := hackpadfs.ReadFile(memFS, "foo/bar/baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "HELLO worl", string(b))
+	assert.Equal(t, Usage{Bytes: 10, Files: 4}, fs.Usage())
+
+	usage, err := hackpadfs.Statfs(fs, ".")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.FSUsage{Total: 10, Used: 10, Available: 0}, usage)
+}
+
+func TestRescan(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{})
+	assert.NoError(t, hackpadfs.WriteFullFile(memFS, "outside", []byte("outside"), 0600))
+	assert.Equal(t, Usage{Bytes: 5, Files: 4}, fs.Usage())
+
+	assert.NoError(t, fs.Rescan())
+	assert.Equal(t, Usage{Bytes: 12, Files: 5}, fs.Usage())
+}
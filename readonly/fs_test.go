@@ -0,0 +1,118 @@
+package readonly
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func newFS(t *testing.T) (*mem.FS, *FS) {
+	t.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(memFS, "foo", []byte("foo"), 0600))
+	assert.NoError(t, hackpadfs.Mkdir(memFS, "bar", 0700))
+	assert.NoError(t, hackpadfs.WriteFullFile(memFS, "bar/baz", []byte("baz"), 0600))
+	assert.NoError(t, hackpadfs.Symlink(memFS, "foo", "link"))
+	return memFS, NewFS(memFS)
+}
+
+func TestFSReads(t *testing.T) {
+	t.Parallel()
+	_, fs := newFS(t)
+
+	b, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+
+	info, err := hackpadfs.Stat(fs, "bar")
+	assert.NoError(t, err)
+	assert.Equal(t, true, info.IsDir())
+
+	entries, err := hackpadfs.ReadDir(fs, "bar")
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, "baz", entries[0].Name())
+	}
+
+	info, err = hackpadfs.Lstat(fs, "link")
+	assert.NoError(t, err)
+	assert.Equal(t, hackpadfs.ModeSymlink, info.Mode().Type())
+	target, err := hackpadfs.Readlink(fs, "link")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", target)
+
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadOnly, 0)
+	assert.NoError(t, err)
+	buf := make([]byte, 1)
+	n, err := hackpadfs.ReadAtFile(f, buf, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "o", string(buf[:n]))
+	_, err = hackpadfs.SeekFile(f, 1, io.SeekStart)
+	assert.NoError(t, err)
+	b, err = io.ReadAll(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "oo", string(b))
+	assert.NoError(t, f.Close())
+
+	subFS, err := hackpadfs.Sub(fs, "bar")
+	assert.NoError(t, err)
+	b, err = hackpadfs.ReadFile(subFS, "baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "baz", string(b))
+	err = hackpadfs.WriteFullFile(subFS, "baz", []byte("new"), 0600)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+}
+
+func TestFSWrites(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t)
+
+	_, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly, 0)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	_, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagReadOnly|hackpadfs.FlagTruncate, 0)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	_, err = hackpadfs.Create(fs, "new")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.WriteFullFile(fs, "new", []byte("new"), 0600)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.Mkdir(fs, "new", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.MkdirAll(fs, "new/dir", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	assert.NoError(t, hackpadfs.MkdirAll(fs, "bar", 0700))
+	err = hackpadfs.Remove(fs, "foo")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.RemoveAll(fs, "bar")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.Rename(fs, "foo", "bar/foo")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.Chmod(fs, "foo", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.Symlink(fs, "foo", "new")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.Link(fs, "foo", "new")
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("new"))
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	_, err = hackpadfs.WriteAtFile(f, []byte("new"), 0)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.TruncateFile(f, 0)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	err = hackpadfs.ChmodFile(f, 0700)
+	assert.ErrorIs(t, hackpadfs.ErrPermission, err)
+	assert.NoError(t, f.Close())
+
+	// the wrapped FS is unchanged
+	b, err := hackpadfs.ReadFile(memFS, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", string(b))
+	_, err = hackpadfs.Stat(memFS, "new")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
@@ -0,0 +1,151 @@
+package s3fs
+
+import (
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.DirReaderFile
+	} = &dir{}
+)
+
+// dir lists a directory one ListObjectsV2 page at a time
+type dir struct {
+	fs     *FS
+	name   string
+	info   *fileInfo
+	prefix string
+
+	mu     sync.Mutex
+	page   []hackpadfs.DirEntry
+	after  string
+	done   bool
+	seen   map[string]bool
+	closed bool
+}
+
+func (fs *FS) newDir(name string, info *fileInfo) *dir {
+	return &dir{
+		fs:     fs,
+		name:   name,
+		info:   info,
+		prefix: fs.dirKey(name),
+		seen:   make(map[string]bool),
+	}
+}
+
+func (d *dir) Stat() (hackpadfs.FileInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	return d.info, nil
+}
+
+func (d *dir) Read(p []byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: d.name, Err: hackpadfs.ErrIsDir}
+}
+
+func (d *dir) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return &hackpadfs.PathError{Op: "close", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+	d.closed = true
+	d.page = nil
+	return nil
+}
+
+// ReadDir implements hackpadfs.DirReaderFile. Each page of entries is listed in order, but pages are only fetched as they are read.
+func (d *dir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: d.name, Err: hackpadfs.ErrClosed}
+	}
+
+	var entries []hackpadfs.DirEntry
+	for n <= 0 || len(entries) < n {
+		if len(d.page) == 0 {
+			if d.done {
+				break
+			}
+			if err := d.fetch(); err != nil {
+				return entries, &hackpadfs.PathError{Op: "readdir", Path: d.name, Err: err}
+			}
+			continue
+		}
+		count := len(d.page)
+		if n > 0 && count > n-len(entries) {
+			count = n - len(entries)
+		}
+		entries = append(entries, d.page[:count]...)
+		d.page = d.page[count:]
+	}
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+// fetch lists the next page of entries
+func (d *dir) fetch() error {
+	result, next, err := d.fs.listPage(d.prefix, d.after, "/")
+	if err != nil {
+		return err
+	}
+	for _, prefix := range result.CommonPrefixes {
+		d.add(strings.TrimSuffix(strings.TrimPrefix(prefix.Prefix, d.prefix), "/"), true)
+	}
+	for _, object := range result.Contents {
+		d.add(strings.TrimPrefix(object.Key, d.prefix), false)
+	}
+	sort.Slice(d.page, func(a, b int) bool {
+		return d.page[a].Name() < d.page[b].Name()
+	})
+	d.after = next
+	d.done = next == ""
+	return nil
+}
+
+func (d *dir) add(name string, isDir bool) {
+	// skip the directory's own marker, and keys which aren't valid file names, like "dir//file"
+	if name == "" || strings.Contains(name, "/") || d.seen[name] {
+		return
+	}
+	d.seen[name] = true
+	d.page = append(d.page, &dirEntry{
+		fs:    d.fs,
+		path:  path.Join(d.name, name),
+		isDir: isDir,
+	})
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if !info.IsDir() {
+		return nil, &hackpadfs.PathError{Op: "readdir", Path: name, Err: hackpadfs.ErrNotDir}
+	}
+	entries, err := fs.newDir(name, info).ReadDir(-1)
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name() < entries[b].Name()
+	})
+	return entries, err
+}
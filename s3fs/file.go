@@ -0,0 +1,535 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReaderAtFile
+		hackpadfs.SeekerFile
+	} = &readFile{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+	} = &writeFile{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+	} = &bufferFile{}
+)
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	f, err := fs.openFile(name, flag, perm)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return f, nil
+}
+
+func (fs *FS) openFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	writable := flag&(hackpadfs.FlagWriteOnly|hackpadfs.FlagReadWrite) != 0
+	info, err := fs.stat(name)
+	switch {
+	case err == nil:
+		if flag&hackpadfs.FlagCreate != 0 && flag&hackpadfs.FlagExclusive != 0 {
+			return nil, hackpadfs.ErrExist
+		}
+		if info.IsDir() {
+			if writable {
+				return nil, hackpadfs.ErrIsDir
+			}
+			return fs.newDir(name, info), nil
+		}
+	case errors.Is(err, hackpadfs.ErrNotExist) && flag&hackpadfs.FlagCreate != 0:
+		if err := fs.checkParentDir(name); err != nil {
+			return nil, err
+		}
+		info = nil
+	default:
+		return nil, err
+	}
+
+	mode := perm.Perm()
+	if info != nil {
+		mode = info.mode
+	}
+	truncate := info == nil || (writable && flag&hackpadfs.FlagTruncate != 0)
+	if truncate {
+		// create or truncate now, so the change is visible before Close
+		if err := fs.putObject(name, bytes.NewReader(nil), 0, mode); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case !writable:
+		if info == nil {
+			info = &fileInfo{name: path.Base(name), mode: mode}
+		}
+		return &readFile{fs: fs, name: name, info: info}, nil
+	case flag&hackpadfs.FlagWriteOnly != 0 && truncate:
+		return &writeFile{fs: fs, name: name, mode: mode}, nil
+	case flag&hackpadfs.FlagWriteOnly != 0 && flag&hackpadfs.FlagAppend != 0:
+		obj, err := fs.client.GetObject(context.Background(), fs.options.BucketName, fs.key(name), minio.GetObjectOptions{})
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		return &writeFile{fs: fs, name: name, mode: mode, base: obj, size: info.size}, nil
+	default:
+		f := &bufferFile{fs: fs, name: name, flag: flag, mode: mode, modTime: time.Now()}
+		if !truncate {
+			f.modTime = info.modTime
+			data, err := fs.readAll(name)
+			if err != nil {
+				return nil, err
+			}
+			f.data = data
+		}
+		return f, nil
+	}
+}
+
+func (fs *FS) readAll(name string) ([]byte, error) {
+	obj, err := fs.client.GetObject(context.Background(), fs.options.BucketName, fs.key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	defer func() { _ = obj.Close() }()
+	data, err := io.ReadAll(obj)
+	return data, wrapErr(err)
+}
+
+// readFile reads an object's current contents with ranged GETs, streaming sequential reads from one response
+type readFile struct {
+	fs   *FS
+	name string
+	info *fileInfo
+
+	mu         sync.Mutex
+	offset     int64
+	body       io.ReadCloser
+	bodyOffset int64
+	closed     bool
+}
+
+// getRange returns a reader for 'length' bytes of 'name' starting at 'off', or the rest of the file if 'length' is 0.
+// Returns io.EOF if 'off' is past the end of the file.
+func (fs *FS) getRange(name string, off, length int64) (io.ReadCloser, error) {
+	var options minio.GetObjectOptions
+	switch {
+	case length > 0:
+		if err := options.SetRange(off, off+length-1); err != nil {
+			return nil, err
+		}
+	case off > 0:
+		if err := options.SetRange(off, 0); err != nil {
+			return nil, err
+		}
+	}
+	body, _, _, err := fs.core.GetObject(context.Background(), fs.options.BucketName, fs.key(name), options)
+	if minio.ToErrorResponse(err).Code == "InvalidRange" {
+		return nil, io.EOF
+	}
+	return body, wrapErr(err)
+}
+
+func (f *readFile) Stat() (hackpadfs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	return f.info, nil
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "read", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if f.body == nil || f.bodyOffset != f.offset {
+		f.closeBody()
+		body, err := f.fs.getRange(f.name, f.offset, 0)
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, &hackpadfs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.body, f.bodyOffset = body, f.offset
+	}
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+	f.bodyOffset = f.offset
+	switch {
+	case err == io.EOF:
+		f.closeBody()
+		if n > 0 {
+			err = nil
+		}
+	case err != nil:
+		f.closeBody()
+		err = &hackpadfs.PathError{Op: "read", Path: f.name, Err: wrapErr(err)}
+	}
+	return n, err
+}
+
+func (f *readFile) closeBody() {
+	if f.body != nil {
+		_ = f.body.Close()
+		f.body = nil
+	}
+}
+
+func (f *readFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: f.name, Err: errors.New("negative offset")}
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	body, err := f.fs.getRange(f.name, off, int64(len(p)))
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, &hackpadfs.PathError{Op: "readat", Path: f.name, Err: err}
+	}
+	defer func() { _ = body.Close() }()
+	n, err := io.ReadFull(body, p)
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		err = io.EOF
+	default:
+		err = &hackpadfs.PathError{Op: "readat", Path: f.name, Err: wrapErr(err)}
+	}
+	return n, err
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	newOffset := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		newOffset += f.offset
+	case io.SeekEnd:
+		newOffset += f.info.size
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if newOffset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.offset = newOffset
+	return newOffset, nil
+}
+
+func (f *readFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &hackpadfs.PathError{Op: "close", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	f.closed = true
+	f.closeBody()
+	return nil
+}
+
+// writeFile streams writes into a multipart upload, completed on Close
+type writeFile struct {
+	fs   *FS
+	name string
+	mode hackpadfs.FileMode
+	base io.ReadCloser // existing contents to append to, if any
+
+	mu     sync.Mutex
+	size   int64
+	pipe   *io.PipeWriter
+	done   chan error
+	closed bool
+}
+
+func (f *writeFile) Stat() (hackpadfs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	return &fileInfo{
+		name:    path.Base(f.name),
+		size:    f.size,
+		mode:    f.mode,
+		modTime: time.Now(),
+	}, nil
+}
+
+func (f *writeFile) Read(p []byte) (int, error) {
+	return 0, &hackpadfs.PathError{Op: "read", Path: f.name, Err: hackpadfs.ErrNotImplemented}
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "write", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	if f.pipe == nil {
+		f.start()
+	}
+	n, err := f.pipe.Write(p)
+	f.size += int64(n)
+	if err != nil {
+		return n, &hackpadfs.PathError{Op: "write", Path: f.name, Err: err}
+	}
+	return n, nil
+}
+
+// start begins uploading everything written to the pipe, after any existing contents
+func (f *writeFile) start() {
+	reader, writer := io.Pipe()
+	var body io.Reader = reader
+	if f.base != nil {
+		body = io.MultiReader(f.base, reader)
+	}
+	f.pipe = writer
+	f.done = make(chan error, 1)
+	go func() {
+		err := f.fs.putObject(f.name, body, -1, f.mode)
+		// stop any pending writes if the upload failed
+		reader.CloseWithError(err)
+		f.done <- err
+	}()
+}
+
+func (f *writeFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &hackpadfs.PathError{Op: "close", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	f.closed = true
+	var err error
+	if f.pipe != nil {
+		_ = f.pipe.Close()
+		err = <-f.done
+	}
+	if f.base != nil {
+		_ = f.base.Close()
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "close", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+// bufferFile holds an object's contents in memory, uploading them on Sync or Close
+type bufferFile struct {
+	fs   *FS
+	name string
+	flag int
+	mode hackpadfs.FileMode
+
+	mu      sync.Mutex
+	data    []byte
+	offset  int64
+	modTime time.Time
+	dirty   bool
+	closed  bool
+}
+
+func (f *bufferFile) Stat() (hackpadfs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	return &fileInfo{
+		name:    path.Base(f.name),
+		size:    int64(len(f.data)),
+		mode:    f.mode,
+		modTime: f.modTime,
+	}, nil
+}
+
+func (f *bufferFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.readAt("read", p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *bufferFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.readAt("readat", p, off)
+}
+
+func (f *bufferFile) readAt(op string, p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: op, Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: op, Path: f.name, Err: errors.New("negative offset")}
+	}
+	if off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *bufferFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.flag&hackpadfs.FlagAppend != 0 {
+		f.offset = int64(len(f.data))
+	}
+	n, err := f.writeAt("write", p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *bufferFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writeAt("writeat", p, off)
+}
+
+func (f *bufferFile) writeAt(op string, p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: op, Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	if off < 0 {
+		return 0, &hackpadfs.PathError{Op: op, Path: f.name, Err: errors.New("negative offset")}
+	}
+	if end := off + int64(len(p)); end > int64(len(f.data)) {
+		f.data = append(f.data, make([]byte, end-int64(len(f.data)))...)
+	}
+	n := copy(f.data[off:], p)
+	if n > 0 {
+		f.modTime = time.Now()
+		f.dirty = true
+	}
+	return n, nil
+}
+
+func (f *bufferFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	newOffset := offset
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		newOffset += f.offset
+	case io.SeekEnd:
+		newOffset += int64(len(f.data))
+	default:
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if newOffset < 0 {
+		return 0, &hackpadfs.PathError{Op: "seek", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	f.offset = newOffset
+	return newOffset, nil
+}
+
+func (f *bufferFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &hackpadfs.PathError{Op: "truncate", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	if size < 0 {
+		return &hackpadfs.PathError{Op: "truncate", Path: f.name, Err: hackpadfs.ErrInvalid}
+	}
+	if size <= int64(len(f.data)) {
+		f.data = f.data[:size]
+	} else {
+		f.data = append(f.data, make([]byte, size-int64(len(f.data)))...)
+	}
+	f.modTime = time.Now()
+	f.dirty = true
+	return nil
+}
+
+func (f *bufferFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &hackpadfs.PathError{Op: "sync", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	return f.sync("sync")
+}
+
+func (f *bufferFile) sync(op string) error {
+	if !f.dirty {
+		return nil
+	}
+	if err := f.fs.putObject(f.name, bytes.NewReader(f.data), int64(len(f.data)), f.mode); err != nil {
+		return &hackpadfs.PathError{Op: op, Path: f.name, Err: err}
+	}
+	f.dirty = false
+	return nil
+}
+
+func (f *bufferFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &hackpadfs.PathError{Op: "close", Path: f.name, Err: hackpadfs.ErrClosed}
+	}
+	f.closed = true
+	err := f.sync("close")
+	f.data = nil
+	return err
+}
@@ -0,0 +1,470 @@
+// Package s3fs contains an FS backed by S3-compatible object storage.
+//
+// Unlike the keyvalue-based S3 example, files are stored as plain objects and directories as key prefixes, so existing buckets can be read as-is.
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChtimesFS
+	} = &FS{}
+)
+
+const (
+	// for some reason these keys must be Header-cased
+	modeMetadataKey    = "Mode"
+	modTimeMetadataKey = "Modtime"
+	modTimeFormat      = time.RFC3339Nano
+
+	octalSize = 8 // formats file mode, for convenient human-readable metadata
+
+	defaultFileMode = 0644
+	defaultDirMode  = 0755
+	dirContentType  = "application/x-directory"
+
+	minPartSize         = 5 << 20  // S3 rejects multipart uploads with smaller parts, except for the last one
+	defaultPartSize     = 16 << 20 // large enough to keep part counts low, small enough to upload in parallel
+	maxCopySize         = 5 << 30  // S3 copies larger objects in parts
+	defaultListPageSize = 1000     // the most keys S3 returns in one list request
+)
+
+// Options provides configuration options for a new FS.
+type Options struct {
+	Endpoint        string
+	BucketName      string
+	AccessKeyID     string
+	SecretAccessKey string
+	Insecure        bool
+
+	// Prefix is prepended to all object keys, rooting the FS at a directory inside the bucket. Defaults to the bucket root.
+	Prefix string
+	// PartSize is the size in bytes of each part in a multipart upload. Files at least this large are uploaded in parts.
+	// Defaults to 16 MiB. Must be at least 5 MiB, the minimum part size allowed by S3.
+	PartSize uint64
+	// UploadThreads is the number of parts uploaded concurrently in a multipart upload of a known size. Defaults to 4.
+	UploadThreads uint
+	// ListPageSize is the maximum number of keys requested in each ListObjectsV2 call. Defaults to 1000.
+	ListPageSize int
+}
+
+// FS is a file system backed by an S3-compatible object storage bucket.
+//
+// Files are objects named by their path. Directories are key prefixes ending in "/", with an empty "marker" object to store their metadata.
+// Directories without a marker, like those created by other S3 clients, exist as long as they contain any objects.
+// File modes and modified times set with Chmod and Chtimes are stored in object metadata.
+//
+// Opening a file write-only with FlagTruncate or FlagAppend, or creating a new one, streams writes to a multipart upload, completed on Close.
+// Other writable files are read into memory and uploaded on Sync or Close.
+//
+// Rename copies objects on the server, but is not atomic. A renamed directory's contents are all copied before any are removed.
+type FS struct {
+	options Options
+	client  *minio.Client
+	core    minio.Core
+}
+
+// NewFS returns a new FS for the bucket described in 'options'
+func NewFS(options Options) (*FS, error) {
+	if options.PartSize == 0 {
+		options.PartSize = defaultPartSize
+	}
+	if options.PartSize < minPartSize {
+		return nil, fmt.Errorf("part size must be at least %d bytes: %d", minPartSize, options.PartSize)
+	}
+	if options.ListPageSize == 0 {
+		options.ListPageSize = defaultListPageSize
+	}
+	if options.ListPageSize < 0 {
+		return nil, fmt.Errorf("list page size must be positive: %d", options.ListPageSize)
+	}
+	options.Prefix = strings.Trim(options.Prefix, "/")
+	if options.Prefix != "" {
+		options.Prefix += "/"
+	}
+	client, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(options.AccessKeyID, options.SecretAccessKey, ""),
+		Secure: !options.Insecure,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &FS{
+		options: options,
+		client:  client,
+		core:    minio.Core{Client: client},
+	}, nil
+}
+
+// key returns the object key for file 'name'
+func (fs *FS) key(name string) string {
+	return fs.options.Prefix + name
+}
+
+// dirKey returns the key prefix of directory 'name', which is also the key of its marker object
+func (fs *FS) dirKey(name string) string {
+	if name == "." {
+		return fs.options.Prefix
+	}
+	return fs.options.Prefix + name + "/"
+}
+
+func wrapErr(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return hackpadfs.ErrNotExist
+	case "AccessDenied":
+		return hackpadfs.ErrPermission
+	default:
+		return err
+	}
+}
+
+// stat returns info for 'name', checking for a file, then a directory marker, then any objects inside a directory prefix
+func (fs *FS) stat(name string) (*fileInfo, error) {
+	if name == "." {
+		return &fileInfo{name: name, mode: hackpadfs.ModeDir | defaultDirMode}, nil
+	}
+	ctx := context.Background()
+	info, err := fs.client.StatObject(ctx, fs.options.BucketName, fs.key(name), minio.StatObjectOptions{})
+	if err == nil {
+		return newFileInfo(name, info, false)
+	}
+	if err := wrapErr(err); !errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, err
+	}
+
+	info, err = fs.client.StatObject(ctx, fs.options.BucketName, fs.dirKey(name), minio.StatObjectOptions{})
+	if err == nil {
+		return newFileInfo(name, info, true)
+	}
+	if err := wrapErr(err); !errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, err
+	}
+
+	result, err := fs.core.ListObjectsV2(fs.options.BucketName, fs.dirKey(name), "", "", "/", 1)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	if len(result.Contents) == 0 && len(result.CommonPrefixes) == 0 {
+		return nil, hackpadfs.ErrNotExist
+	}
+	return &fileInfo{name: path.Base(name), mode: hackpadfs.ModeDir | defaultDirMode}, nil
+}
+
+// checkParentDir returns an error if the parent of 'name' is not an existing directory
+func (fs *FS) checkParentDir(name string) error {
+	parent := path.Dir(name)
+	if parent == "." {
+		return nil
+	}
+	info, err := fs.stat(parent)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return hackpadfs.ErrNotDir
+	}
+	return nil
+}
+
+func metadata(mode hackpadfs.FileMode, modTime time.Time) map[string]string {
+	m := map[string]string{
+		modeMetadataKey: strconv.FormatUint(uint64(mode), octalSize),
+	}
+	if !modTime.IsZero() {
+		m[modTimeMetadataKey] = modTime.UTC().Format(modTimeFormat)
+	}
+	return m
+}
+
+// putObject uploads file 'name' from 'r'. Pass a size of -1 to stream an unknown size as a multipart upload.
+func (fs *FS) putObject(name string, r io.Reader, size int64, mode hackpadfs.FileMode) error {
+	_, err := fs.client.PutObject(context.Background(), fs.options.BucketName, fs.key(name), r, size, minio.PutObjectOptions{
+		UserMetadata: metadata(mode.Perm(), time.Time{}),
+		PartSize:     fs.options.PartSize,
+		NumThreads:   fs.options.UploadThreads,
+	})
+	return wrapErr(err)
+}
+
+// putDir writes directory 'name's marker object
+func (fs *FS) putDir(name string, mode hackpadfs.FileMode, modTime time.Time) error {
+	_, err := fs.client.PutObject(context.Background(), fs.options.BucketName, fs.dirKey(name), bytes.NewReader(nil), 0, minio.PutObjectOptions{
+		ContentType:  dirContentType,
+		UserMetadata: metadata(hackpadfs.ModeDir|mode.Perm(), modTime),
+	})
+	return wrapErr(err)
+}
+
+// copyObject copies 'src' to 'dst' on the server if 'src' still matches 'etag'. A nil 'meta' keeps the source's metadata.
+func (fs *FS) copyObject(src, dst string, size int64, etag string, meta map[string]string) error {
+	dstOptions := minio.CopyDestOptions{
+		Bucket:          fs.options.BucketName,
+		Object:          dst,
+		UserMetadata:    meta,
+		ReplaceMetadata: meta != nil,
+	}
+	srcOptions := minio.CopySrcOptions{
+		Bucket:    fs.options.BucketName,
+		Object:    src,
+		MatchETag: etag,
+	}
+	var err error
+	if size > maxCopySize {
+		_, err = fs.client.ComposeObject(context.Background(), dstOptions, srcOptions)
+	} else {
+		_, err = fs.client.CopyObject(context.Background(), dstOptions, srcOptions)
+	}
+	return wrapErr(err)
+}
+
+// listPage lists one page of keys after 'startAfter', returning the key to list the next page after or "" on the last page.
+// Pages continue from StartAfter instead of continuation tokens, since not every S3 implementation resumes tokens reliably.
+func (fs *FS) listPage(prefix, startAfter, delimiter string) (minio.ListBucketV2Result, string, error) {
+	result, err := fs.core.ListObjectsV2(fs.options.BucketName, prefix, startAfter, "", delimiter, fs.options.ListPageSize)
+	if err != nil {
+		return result, "", wrapErr(err)
+	}
+	// some implementations report a full page as the last one, so only a short page is trusted to be the end
+	if !result.IsTruncated && len(result.Contents)+len(result.CommonPrefixes) < fs.options.ListPageSize {
+		return result, "", nil
+	}
+	next := startAfter
+	for _, object := range result.Contents {
+		if object.Key > next {
+			next = object.Key
+		}
+	}
+	for _, commonPrefix := range result.CommonPrefixes {
+		// skip past every key inside the common prefix
+		if after := commonPrefix.Prefix + string(utf8.MaxRune); after > next {
+			next = after
+		}
+	}
+	if next == startAfter {
+		return result, "", nil
+	}
+	return result, next, nil
+}
+
+// walkPrefix calls fn with each page of objects with keys starting with 'prefix'
+func (fs *FS) walkPrefix(prefix string, fn func(objects []minio.ObjectInfo) error) error {
+	startAfter := ""
+	for {
+		result, next, err := fs.listPage(prefix, startAfter, "")
+		if err != nil {
+			return err
+		}
+		if err := fn(result.Contents); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		startAfter = next
+	}
+}
+
+// removePrefix removes all objects with keys starting with 'prefix'
+func (fs *FS) removePrefix(prefix string) error {
+	return fs.walkPrefix(prefix, func(objects []minio.ObjectInfo) error {
+		objectsCh := make(chan minio.ObjectInfo, len(objects))
+		for _, object := range objects {
+			objectsCh <- object
+		}
+		close(objectsCh)
+		var removeErr error
+		for result := range fs.client.RemoveObjects(context.Background(), fs.options.BucketName, objectsCh, minio.RemoveObjectsOptions{}) {
+			if removeErr == nil {
+				removeErr = result.Err
+			}
+		}
+		return wrapErr(removeErr)
+	})
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if !hackpadfs.ValidPath(name) {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err != nil {
+		return nil, &hackpadfs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	_, err := fs.stat(name)
+	switch {
+	case err == nil:
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: hackpadfs.ErrExist}
+	case !errors.Is(err, hackpadfs.ErrNotExist):
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if err := fs.checkParentDir(name); err != nil {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if err := fs.putDir(name, perm, time.Time{}); err != nil {
+		return &hackpadfs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(name string, perm hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "mkdirall", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	// find the missing directories, deepest first
+	var missing []string
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		info, err := fs.stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return &hackpadfs.PathError{Op: "mkdir", Path: dir, Err: hackpadfs.ErrNotDir}
+			}
+			break
+		}
+		if !errors.Is(err, hackpadfs.ErrNotExist) {
+			return &hackpadfs.PathError{Op: "mkdir", Path: dir, Err: err}
+		}
+		missing = append(missing, dir)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := fs.putDir(missing[i], perm, time.Time{}); err != nil {
+			return &hackpadfs.PathError{Op: "mkdir", Path: missing[i], Err: err}
+		}
+	}
+	return nil
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	if !hackpadfs.ValidPath(name) || name == "." {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	key := fs.key(name)
+	if info.IsDir() {
+		key = fs.dirKey(name)
+		// list past the directory's own marker, if any
+		result, err := fs.core.ListObjectsV2(fs.options.BucketName, key, "", "", "/", 2)
+		if err != nil {
+			return &hackpadfs.PathError{Op: "remove", Path: name, Err: wrapErr(err)}
+		}
+		for _, object := range result.Contents {
+			if object.Key != key {
+				return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
+			}
+		}
+		if len(result.CommonPrefixes) > 0 {
+			return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
+		}
+	}
+	err = fs.client.RemoveObject(context.Background(), fs.options.BucketName, key, minio.RemoveObjectOptions{})
+	if err != nil {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: wrapErr(err)}
+	}
+	return nil
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(name string) error {
+	if !hackpadfs.ValidPath(name) || name == "." {
+		return &hackpadfs.PathError{Op: "removeall", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return nil
+	case err != nil:
+		return &hackpadfs.PathError{Op: "removeall", Path: name, Err: err}
+	case info.IsDir():
+		err = fs.removePrefix(fs.dirKey(name))
+	default:
+		err = wrapErr(fs.client.RemoveObject(context.Background(), fs.options.BucketName, fs.key(name), minio.RemoveObjectOptions{}))
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "chmod", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err == nil {
+		err = fs.setMetadata(name, info, mode, info.modTime)
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	return nil
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !hackpadfs.ValidPath(name) {
+		return &hackpadfs.PathError{Op: "chtimes", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	info, err := fs.stat(name)
+	if err == nil {
+		err = fs.setMetadata(name, info, info.mode, mtime)
+	}
+	if err != nil {
+		return &hackpadfs.PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	return nil
+}
+
+// setMetadata rewrites the stored mode and modified time of 'name'
+func (fs *FS) setMetadata(name string, info *fileInfo, mode hackpadfs.FileMode, modTime time.Time) error {
+	if name == "." {
+		// the root has no marker to store metadata in
+		return hackpadfs.ErrNotImplemented
+	}
+	if info.IsDir() {
+		return fs.putDir(name, mode, modTime)
+	}
+	key := fs.key(name)
+	// S3 only allows copying an object onto itself when replacing its metadata
+	return fs.copyObject(key, key, info.size, info.etag, metadata(mode.Perm(), modTime))
+}
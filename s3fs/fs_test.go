@@ -0,0 +1,287 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	minioServer "github.com/minio/minio/cmd"
+)
+
+const (
+	maxBucketPrefixLength = 50
+	testDBHost            = "localhost:9002"
+	testDBAccessKeyID     = "minioadmin"
+	testDBSecretKey       = "minioadmin"
+)
+
+var minioClient *minio.Client
+
+func init() {
+	path, err := os.MkdirTemp("", "")
+	if err != nil {
+		panic(err)
+	}
+	go func() {
+		defer func() {
+			_ = os.RemoveAll(path)
+		}()
+		minioServer.Main([]string{
+			"minio", "server", path,
+			"--address", ":9002",
+			"--console-address", ":9003",
+		})
+	}()
+
+	minioClient, err = minio.New(testDBHost, &minio.Options{
+		Creds:  credentials.NewStaticV4(testDBAccessKeyID, testDBSecretKey, ""),
+		Secure: false,
+	})
+	if err != nil {
+		panic(err)
+	}
+	time.Sleep(2 * time.Second)
+	_, err = minioClient.ListBuckets(context.Background())
+	if err != nil {
+		panic(err)
+	}
+}
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+func cleanTestName(tb testing.TB) string {
+	nameRunes := []rune(tb.Name())
+	for i, r := range nameRunes {
+		// Naming rules: https://docs.aws.amazon.com/AmazonS3/latest/userguide/bucketnamingrules.html
+		switch {
+		case unicode.IsLetter(r):
+			if !unicode.IsLower(r) {
+				nameRunes[i] = unicode.ToLower(r)
+			}
+		case unicode.IsNumber(r) || r == '-':
+		default:
+			nameRunes[i] = '-'
+		}
+	}
+	if nameRunes[0] == '-' {
+		nameRunes[0] = '0'
+	}
+	if lastRuneIx := len(nameRunes) - 1; nameRunes[lastRuneIx] == '-' {
+		nameRunes[lastRuneIx] = '0'
+	}
+	name := string(nameRunes)
+	if len(name) > maxBucketPrefixLength {
+		name = name[:maxBucketPrefixLength]
+	}
+	return name
+}
+
+var testNumber uint64
+
+// makeBucket creates a new bucket, removed when the test completes
+func makeBucket(tb testing.TB) string {
+	tb.Helper()
+	bucketName := fmt.Sprintf("%s-%d", cleanTestName(tb), atomic.AddUint64(&testNumber, 1))
+	ctx := context.Background()
+	requireNoError(tb, minioClient.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{}))
+	tb.Cleanup(func() {
+		assert.NoError(tb, minioClient.RemoveBucketWithOptions(ctx, bucketName, minio.RemoveBucketOptions{
+			ForceDelete: true,
+		}))
+	})
+	return bucketName
+}
+
+func makeFS(tb testing.TB, bucketName string, options Options) *FS {
+	tb.Helper()
+	options.Endpoint = testDBHost
+	options.BucketName = bucketName
+	options.Insecure = true
+	options.AccessKeyID = testDBAccessKeyID
+	options.SecretAccessKey = testDBSecretKey
+	fs, err := NewFS(options)
+	requireNoError(tb, err)
+	return fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		options Options
+	}{
+		{name: "s3fs", options: Options{}},
+		{name: "s3fs prefix", options: Options{Prefix: "/root/dir/"}},
+		{name: "s3fs small pages", options: Options{ListPageSize: 1}},
+	} {
+		tc := tc
+		options := fstest.FSOptions{
+			Name: tc.name,
+			TestFS: func(tb testing.TB) fstest.SetupFS {
+				return makeFS(tb, makeBucket(tb), tc.options)
+			},
+			Constraints: fstest.Constraints{
+				AllowUnenforcedPermissions: true,
+			},
+			ShouldSkip: func(facets fstest.Facets) bool {
+				// writable files are uploaded on Sync or Close, and open files read the current object at their path
+				return strings.HasSuffix(facets.Name, "/file.Truncate/visible_to_other_open_files") ||
+					strings.HasSuffix(facets.Name, "/file.WriteAt/large_offset_outside_file") ||
+					strings.HasSuffix(facets.Name, "/fs.Rename/open_file")
+			},
+		}
+		fstest.FS(t, options)
+		fstest.File(t, options)
+	}
+}
+
+func TestNewFS(t *testing.T) {
+	t.Parallel()
+	_, err := NewFS(Options{Endpoint: testDBHost, PartSize: minPartSize - 1})
+	assert.Error(t, err)
+	_, err = NewFS(Options{Endpoint: testDBHost, ListPageSize: -1})
+	assert.Error(t, err)
+	fs, err := NewFS(Options{Endpoint: testDBHost, Prefix: "/a/b/"})
+	requireNoError(t, err)
+	assert.Equal(t, "a/b/c", fs.key("c"))
+	assert.Equal(t, "a/b/c/", fs.dirKey("c"))
+	assert.Equal(t, "a/b/", fs.dirKey("."))
+}
+
+func TestStreamingUpload(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, makeBucket(t), Options{PartSize: minPartSize})
+
+	part := bytes.Repeat([]byte("hackpadfs"), minPartSize/len("hackpadfs"))
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagCreate|hackpadfs.FlagTruncate, 0600)
+	requireNoError(t, err)
+	for i := 0; i < 3; i++ { // spans 3 parts
+		_, err := hackpadfs.WriteFile(f, part)
+		requireNoError(t, err)
+	}
+	requireNoError(t, f.Close())
+
+	info, err := fs.Stat("foo")
+	requireNoError(t, err)
+	assert.Equal(t, int64(3*len(part)), info.Size())
+	assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+
+	f, err = hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly|hackpadfs.FlagAppend, 0)
+	requireNoError(t, err)
+	_, err = hackpadfs.WriteFile(f, []byte("bar"))
+	requireNoError(t, err)
+	requireNoError(t, f.Close())
+
+	data, err := hackpadfs.ReadFile(fs, "foo")
+	requireNoError(t, err)
+	assert.Equal(t, 3*len(part)+len("bar"), len(data))
+	assert.Equal(t, true, bytes.Equal(part, data[2*len(part):3*len(part)]))
+	assert.Equal(t, "bar", string(data[3*len(part):]))
+}
+
+func TestReadAt(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, makeBucket(t), Options{})
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("hello world"), 0600))
+
+	f, err := fs.Open("foo")
+	requireNoError(t, err)
+	buf := make([]byte, 5)
+	n, err := hackpadfs.ReadAtFile(f, buf, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, "world", string(buf[:n]))
+	n, err = hackpadfs.ReadAtFile(f, buf, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf[:n]))
+	_, err = hackpadfs.ReadAtFile(f, buf, 11)
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, f.Close())
+}
+
+func TestImplicitDirectories(t *testing.T) {
+	t.Parallel()
+	bucketName := makeBucket(t)
+	fs := makeFS(t, bucketName, Options{ListPageSize: 2})
+	ctx := context.Background()
+	for _, key := range []string{"a/b/c", "a/d", "a/e/", "a/f", "a/g", "i"} {
+		_, err := minioClient.PutObject(ctx, bucketName, key, strings.NewReader(key), int64(len(key)), minio.PutObjectOptions{})
+		requireNoError(t, err)
+	}
+
+	info, err := fs.Stat("a/b")
+	requireNoError(t, err)
+	assert.Equal(t, true, info.IsDir())
+	assert.Equal(t, hackpadfs.ModeDir|defaultDirMode, info.Mode())
+	info, err = fs.Stat("a/d")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(defaultFileMode), info.Mode())
+	assert.Equal(t, int64(len("a/d")), info.Size())
+
+	dir, err := fs.Open("a")
+	requireNoError(t, err)
+	var names []string
+	for {
+		entries, err := hackpadfs.ReadDirFile(dir, 2)
+		if err == io.EOF {
+			break
+		}
+		requireNoError(t, err)
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				name += "/"
+			}
+			names = append(names, name)
+		}
+	}
+	requireNoError(t, dir.Close())
+	assert.Equal(t, []string{"b/", "d", "e/", "f", "g"}, names)
+
+	assert.ErrorIs(t, hackpadfs.ErrNotEmpty, fs.Remove("a/b"))
+	requireNoError(t, fs.Remove("a/b/c"))
+	_, err = fs.Stat("a/b")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+}
+
+func TestRenameDirectory(t *testing.T) {
+	t.Parallel()
+	fs := makeFS(t, makeBucket(t), Options{ListPageSize: 1})
+	requireNoError(t, hackpadfs.MkdirAll(fs, "a/b", 0700))
+	for _, name := range []string{"a/foo", "a/b/bar", "a/b/baz"} {
+		requireNoError(t, hackpadfs.WriteFullFile(fs, name, []byte(name), 0600))
+	}
+	requireNoError(t, fs.Chtimes("a/foo", time.Time{}, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)))
+
+	requireNoError(t, fs.Rename("a", "c"))
+	_, err := fs.Stat("a")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	for _, name := range []string{"foo", "b/bar", "b/baz"} {
+		data, err := hackpadfs.ReadFile(fs, "c/"+name)
+		assert.NoError(t, err)
+		assert.Equal(t, "a/"+name, string(data))
+	}
+	info, err := fs.Stat("c/foo")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.FileMode(0600), info.Mode())
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC), info.ModTime())
+	info, err = fs.Stat("c/b")
+	requireNoError(t, err)
+	assert.Equal(t, hackpadfs.ModeDir|0700, info.Mode())
+}
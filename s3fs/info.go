@@ -0,0 +1,104 @@
+package s3fs
+
+import (
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/minio/minio-go/v7"
+)
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    hackpadfs.FileMode
+	modTime time.Time
+	etag    string
+}
+
+// newFileInfo returns info for object 'info', using modes and modified times stored in its metadata
+func newFileInfo(name string, info minio.ObjectInfo, isDir bool) (*fileInfo, error) {
+	mode := hackpadfs.FileMode(defaultFileMode)
+	if isDir {
+		mode = defaultDirMode
+	}
+	if modeStr, ok := info.UserMetadata[modeMetadataKey]; ok {
+		modeInt, err := strconv.ParseUint(modeStr, octalSize, 32)
+		if err != nil {
+			return nil, err
+		}
+		mode = hackpadfs.FileMode(modeInt).Perm()
+	}
+	size := info.Size
+	if isDir {
+		mode |= hackpadfs.ModeDir
+		size = 0
+	}
+
+	modTime := info.LastModified
+	if modTimeStr, ok := info.UserMetadata[modTimeMetadataKey]; ok {
+		var err error
+		modTime, err = time.Parse(modTimeFormat, modTimeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &fileInfo{
+		name:    path.Base(name),
+		size:    size,
+		mode:    mode,
+		modTime: modTime,
+		etag:    info.ETag,
+	}, nil
+}
+
+func (f *fileInfo) Name() string {
+	return f.name
+}
+
+func (f *fileInfo) Size() int64 {
+	return f.size
+}
+
+func (f *fileInfo) Mode() hackpadfs.FileMode {
+	return f.mode
+}
+
+func (f *fileInfo) ModTime() time.Time {
+	return f.modTime
+}
+
+func (f *fileInfo) IsDir() bool {
+	return f.mode.IsDir()
+}
+
+func (f *fileInfo) Sys() interface{} {
+	return nil
+}
+
+// dirEntry is a listed directory entry. Its info is only fetched when requested, since listings don't include metadata.
+type dirEntry struct {
+	fs    *FS
+	path  string
+	isDir bool
+}
+
+func (d *dirEntry) Name() string {
+	return path.Base(d.path)
+}
+
+func (d *dirEntry) IsDir() bool {
+	return d.isDir
+}
+
+func (d *dirEntry) Type() hackpadfs.FileMode {
+	if d.isDir {
+		return hackpadfs.ModeDir
+	}
+	return 0
+}
+
+func (d *dirEntry) Info() (hackpadfs.FileInfo, error) {
+	return d.fs.Stat(d.path)
+}
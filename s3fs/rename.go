@@ -0,0 +1,81 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/minio/minio-go/v7"
+)
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	if !hackpadfs.ValidPath(oldname) || !hackpadfs.ValidPath(newname) || oldname == "." || newname == "." {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	if err := fs.rename(oldname, newname); err != nil {
+		return &hackpadfs.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
+
+func (fs *FS) rename(oldname, newname string) error {
+	oldInfo, err := fs.stat(oldname)
+	if err != nil {
+		return err
+	}
+	if oldname == newname {
+		if oldInfo.IsDir() {
+			return hackpadfs.ErrExist
+		}
+		return nil
+	}
+	if oldInfo.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return hackpadfs.ErrInvalid
+	}
+
+	newInfo, err := fs.stat(newname)
+	switch {
+	case err == nil:
+		if newInfo.IsDir() {
+			return hackpadfs.ErrExist
+		}
+		if oldInfo.IsDir() {
+			return hackpadfs.ErrNotDir
+		}
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		if err := fs.checkParentDir(newname); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	if !oldInfo.IsDir() {
+		oldKey := fs.key(oldname)
+		if err := fs.copyObject(oldKey, fs.key(newname), oldInfo.size, oldInfo.etag, nil); err != nil {
+			return err
+		}
+		return wrapErr(fs.client.RemoveObject(context.Background(), fs.options.BucketName, oldKey, minio.RemoveObjectOptions{}))
+	}
+	return fs.renameDir(oldname, newname)
+}
+
+// renameDir copies every object inside directory 'oldname' to 'newname', then removes the originals
+func (fs *FS) renameDir(oldname, newname string) error {
+	oldPrefix, newPrefix := fs.dirKey(oldname), fs.dirKey(newname)
+	err := fs.walkPrefix(oldPrefix, func(objects []minio.ObjectInfo) error {
+		for _, object := range objects {
+			newKey := newPrefix + strings.TrimPrefix(object.Key, oldPrefix)
+			if err := fs.copyObject(object.Key, newKey, object.Size, object.ETag, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return fs.removePrefix(oldPrefix)
+}
@@ -2,6 +2,7 @@ package hackpadfs_test
 
 import (
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/hack-pad/hackpadfs"
@@ -13,17 +14,35 @@ import (
 func TestSub(t *testing.T) {
 	t.Parallel()
 	options := fstest.FSOptions{
-		Name:  "sub",
-		Setup: fstest.TestSetupFunc(setupSubFS),
+		Name:        "sub",
+		Setup:       fstest.TestSetupFunc(setupSubFS),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	data := fstest.FS(t, options)
-	assert.Zero(t, data.Skips)
+	// sub FSs don't implement Rename or Link, and mem.FS doesn't report file owners or support watches
+	assertSkipsOnly(t, data.Skips, "sub_FS/fs.Chown/", "sub_FS/fs.Link/", "sub_FS/fs.Properties/", "sub_FS/fs.Rename/", "sub_FS/fs.Symlink/rename_symlink", "sub_FS/fs.Watch", "sub_FS/fs.Xattr/rename")
 
 	options.Constraints = fstest.Constraints{
 		AllowErrPathPrefix: true,
 	}
 	data = fstest.File(t, options)
-	assert.Zero(t, data.Skips)
+	// mem.FS doesn't implement Sync, and large files are not enabled
+	assertSkipsOnly(t, data.Skips, "sub_File/file.Large/", "sub_File/file.Sync")
+}
+
+// assertSkipsOnly asserts every skipped test name contains one of the given test name patterns
+func assertSkipsOnly(tb testing.TB, skips []fstest.Facets, patterns ...string) {
+	tb.Helper()
+	for _, skip := range skips {
+		expected := false
+		for _, pattern := range patterns {
+			if strings.Contains(skip.Name, pattern) {
+				expected = true
+				break
+			}
+		}
+		assert.Equal(tb, true, expected, "Unexpected skip: "+skip.Name)
+	}
 }
 
 func setupSubFS(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
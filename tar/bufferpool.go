@@ -2,6 +2,8 @@ package tar
 
 import (
 	"sync/atomic"
+
+	"github.com/hack-pad/hackpadfs/keyvalue/blob"
 )
 
 // bufferPool maintains a collection of byte buffers with a maximum size.
@@ -40,7 +42,7 @@ func (p *bufferPool) addBuffer() {
 		}
 	}
 	buf := &buffer{
-		Data: make([]byte, p.size),
+		Data: blob.DefaultPool.Get(int(p.size)),
 		pool: p,
 	}
 	p.buffers <- buf
@@ -62,3 +64,16 @@ func (p *bufferPool) Wait() *buffer {
 func (b *buffer) Done() {
 	b.pool.buffers <- b
 }
+
+// release returns all buffers to blob.DefaultPool, so later archives can reuse them.
+// Must only be called once all buffers are Done.
+func (p *bufferPool) release() {
+	for {
+		select {
+		case buf := <-p.buffers:
+			blob.DefaultPool.Put(buf.Data)
+		default:
+			return
+		}
+	}
+}
@@ -160,6 +160,8 @@ func (fs *ReaderFS) readErr(r io.Reader) error {
 	case err := <-errs:
 		return err
 	case <-done:
+		smallPool.release()
+		bigPool.release()
 		return nil
 	}
 }
@@ -30,6 +30,7 @@ func TestFS(t *testing.T) {
 				return newTarFromFS(tb, setupFS)
 			}
 		}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
 	}
 	fstest.FS(t, options)
 	fstest.File(t, options)
@@ -66,6 +67,13 @@ func copyTarWalk(src hackpadfs.FS, archive *tar.Writer) hackpadfs.WalkDirFunc {
 		if err != nil {
 			return err
 		}
+		if info.Mode()&hackpadfs.ModeSymlink != 0 {
+			// ReaderFS does not support symlinks, so archive a copy of the target. Skip dangling and looping symlinks.
+			info, err = hackpadfs.Stat(src, path)
+			if err != nil {
+				return nil
+			}
+		}
 		header, err := tar.FileInfoHeader(info, "")
 		if err != nil {
 			return err
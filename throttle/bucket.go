@@ -0,0 +1,60 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket rate limiter
+type bucket struct {
+	rate  float64 // tokens added per second, unlimited if zero
+	burst float64 // max tokens
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// now and sleep are replaceable for tests
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newBucket(rate float64, burst time.Duration) *bucket {
+	b := &bucket{
+		rate:  rate,
+		burst: rate * burst.Seconds(),
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+	if b.burst < 1 {
+		b.burst = 1
+	}
+	b.tokens = b.burst
+	b.last = b.now()
+	return b
+}
+
+// wait takes 'n' tokens, sleeping until they are available.
+// Tokens may go negative to admit requests larger than the burst, which delays later requests instead.
+func (b *bucket) wait(n float64) {
+	if b.rate == 0 || n == 0 {
+		return
+	}
+	b.mu.Lock()
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= n
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+
+	if delay > 0 {
+		b.sleep(delay)
+	}
+}
@@ -0,0 +1,276 @@
+// Package throttle contains a file system wrapper which limits the rate of reads and writes.
+package throttle
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+		hackpadfs.WatchFS
+	} = &FS{}
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &file{}
+)
+
+// Options configures throttling for an FS. Limits are shared by all operations on the FS and its open files.
+type Options struct {
+	// IOPS limits read and write operations per second. Reading a directory counts as a read. Unlimited if zero.
+	IOPS float64
+	// BytesPerSecond limits bytes read and written per second. Unlimited if zero.
+	BytesPerSecond float64
+	// Burst is the period of unused limits which may accumulate while idle, then be spent at once. Defaults to 1 second.
+	Burst time.Duration
+}
+
+// FS wraps a file system to limit reads and writes with token buckets.
+// Operations which exceed a limit wait until enough time has passed to perform them. Other operations are not limited.
+//
+// Useful for protecting shared backends from a single busy client, or simulating slow disks in tests.
+type FS struct {
+	fs    hackpadfs.FS
+	ops   *bucket
+	bytes *bucket
+}
+
+// NewFS returns an FS which throttles reads and writes on 'fs'
+func NewFS(fs hackpadfs.FS, options Options) (*FS, error) {
+	if options.IOPS < 0 || options.BytesPerSecond < 0 || options.Burst < 0 {
+		return nil, errors.New("IOPS, BytesPerSecond, and Burst must not be negative")
+	}
+	if options.Burst == 0 {
+		options.Burst = time.Second
+	}
+	return &FS{
+		fs:    fs,
+		ops:   newBucket(options.IOPS, options.Burst),
+		bytes: newBucket(options.BytesPerSecond, options.Burst),
+	}, nil
+}
+
+// waitOp waits until another read or write is allowed
+func (fs *FS) waitOp() {
+	fs.ops.wait(1)
+}
+
+// waitBytes waits until 'n' more bytes may be transferred
+func (fs *FS) waitBytes(n int) {
+	fs.bytes.wait(float64(n))
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	f, err := fs.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fs: fs}, nil
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	f, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &file{File: f, fs: fs}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	return hackpadfs.Mkdir(fs.fs, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	return hackpadfs.MkdirAll(fs.fs, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+func (fs *FS) Remove(name string) error {
+	return hackpadfs.Remove(fs.fs, name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+func (fs *FS) RemoveAll(path string) error {
+	return hackpadfs.RemoveAll(fs.fs, path)
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	return hackpadfs.Rename(fs.fs, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Stat(fs.fs, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	return hackpadfs.Lstat(fs.fs, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	return hackpadfs.Chmod(fs.fs, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	return hackpadfs.Chown(fs.fs, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	fs.waitOp()
+	return hackpadfs.ReadDir(fs.fs, name)
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	fs.waitOp()
+	data, err := hackpadfs.ReadFile(fs.fs, name)
+	fs.waitBytes(len(data))
+	return data, err
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	return hackpadfs.Symlink(fs.fs, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	return hackpadfs.Link(fs.fs, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	return hackpadfs.Statfs(fs.fs, name)
+}
+
+// Watch implements hackpadfs.WatchFS
+func (fs *FS) Watch(name string) (hackpadfs.Watcher, error) {
+	return hackpadfs.Watch(fs.fs, name)
+}
+
+// file throttles reads and writes on an open file
+type file struct {
+	hackpadfs.File
+	fs *FS
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *file) Read(p []byte) (int, error) {
+	f.fs.waitOp()
+	n, err := f.File.Read(p)
+	f.fs.waitBytes(n)
+	return n, err
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *file) Write(p []byte) (int, error) {
+	f.fs.waitOp()
+	f.fs.waitBytes(len(p))
+	return hackpadfs.WriteFile(f.File, p)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.waitOp()
+	n, err := hackpadfs.ReadAtFile(f.File, p, off)
+	f.fs.waitBytes(n)
+	return n, err
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.waitOp()
+	f.fs.waitBytes(len(p))
+	return hackpadfs.WriteAtFile(f.File, p, off)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *file) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	f.fs.waitOp()
+	return hackpadfs.ReadDirFile(f.File, n)
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *file) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *file) Truncate(size int64) error {
+	return hackpadfs.TruncateFile(f.File, size)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *file) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *file) Chown(uid, gid int) error {
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *file) Chtimes(atime time.Time, mtime time.Time) error {
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
@@ -0,0 +1,147 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "throttle",
+		TestFS: func(tb testing.TB) fstest.SetupFS {
+			memFS, err := mem.NewFS()
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			fs, err := NewFS(memFS, Options{IOPS: 1e6, BytesPerSecond: 1e9})
+			if !assert.NoError(tb, err) {
+				tb.FailNow()
+			}
+			return fs
+		},
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestNewFSInvalidOptions(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	for _, options := range []Options{
+		{IOPS: -1},
+		{BytesPerSecond: -1},
+		{Burst: -time.Second},
+	} {
+		_, err := NewFS(memFS, options)
+		assert.Error(t, err)
+	}
+}
+
+// fakeClock advances only when slept
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.slept += d
+}
+
+func (c *fakeClock) Slept() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.slept
+}
+
+func newThrottledFS(t *testing.T, options Options) (*FS, *fakeClock) {
+	t.Helper()
+	memFS, err := mem.NewFS()
+	assert.NoError(t, err)
+	assert.NoError(t, hackpadfs.WriteFullFile(memFS, "foo", make([]byte, 100), 0600))
+	fs, err := NewFS(memFS, options)
+	assert.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	for _, b := range []*bucket{fs.ops, fs.bytes} {
+		b.now = clock.Now
+		b.sleep = clock.Sleep
+		b.last = clock.Now()
+	}
+	return fs, clock
+}
+
+func TestIOPS(t *testing.T) {
+	t.Parallel()
+	fs, clock := newThrottledFS(t, Options{IOPS: 10, Burst: 100 * time.Millisecond})
+	f, err := fs.Open("foo")
+	assert.NoError(t, err)
+	defer func() { assert.NoError(t, f.Close()) }()
+
+	// the first read spends the burst
+	_, err = hackpadfs.ReadAtFile(f, make([]byte, 1), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), clock.Slept())
+
+	for i := 0; i < 10; i++ {
+		_, err = hackpadfs.ReadAtFile(f, make([]byte, 1), 0)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, time.Second, clock.Slept())
+
+	// other operations are not limited
+	_, err = hackpadfs.Stat(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, clock.Slept())
+}
+
+func TestBytesPerSecond(t *testing.T) {
+	t.Parallel()
+	fs, clock := newThrottledFS(t, Options{BytesPerSecond: 100})
+
+	_, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), clock.Slept())
+
+	f, err := hackpadfs.OpenFile(fs, "foo", hackpadfs.FlagWriteOnly, 0)
+	assert.NoError(t, err)
+	_, err = hackpadfs.WriteFile(f, make([]byte, 50))
+	assert.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, clock.Slept())
+	// writes larger than the burst are allowed, but delay later operations
+	_, err = hackpadfs.WriteFile(f, make([]byte, 300))
+	assert.NoError(t, err)
+	assert.Equal(t, 3500*time.Millisecond, clock.Slept())
+	assert.NoError(t, f.Close())
+}
+
+func TestBurstRefills(t *testing.T) {
+	t.Parallel()
+	fs, clock := newThrottledFS(t, Options{BytesPerSecond: 100})
+
+	_, err := hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	clock.Sleep(10 * time.Second) // idle time beyond the burst is not saved
+	_, err = hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	_, err = hackpadfs.ReadFile(fs, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, 11*time.Second, clock.Slept())
+}
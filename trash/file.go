@@ -0,0 +1,91 @@
+package trash
+
+import (
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+var (
+	_ interface {
+		hackpadfs.File
+		hackpadfs.ReadWriterFile
+		hackpadfs.ReaderAtFile
+		hackpadfs.WriterAtFile
+		hackpadfs.DirReaderFile
+		hackpadfs.SeekerFile
+		hackpadfs.SyncerFile
+		hackpadfs.TruncaterFile
+		hackpadfs.ChmoderFile
+		hackpadfs.ChownerFile
+		hackpadfs.ChtimeserFile
+	} = &rootDir{}
+)
+
+// rootDir hides the trash directory when listing the root directory
+type rootDir struct {
+	hackpadfs.File
+	fs *FS
+}
+
+// Read implements hackpadfs.ReadWriterFile
+func (f *rootDir) Read(p []byte) (int, error) {
+	return f.File.Read(p)
+}
+
+// Write implements hackpadfs.ReadWriterFile
+func (f *rootDir) Write(p []byte) (int, error) {
+	return hackpadfs.WriteFile(f.File, p)
+}
+
+// ReadAt implements hackpadfs.ReaderAtFile
+func (f *rootDir) ReadAt(p []byte, off int64) (int, error) {
+	return hackpadfs.ReadAtFile(f.File, p, off)
+}
+
+// WriteAt implements hackpadfs.WriterAtFile
+func (f *rootDir) WriteAt(p []byte, off int64) (int, error) {
+	return hackpadfs.WriteAtFile(f.File, p, off)
+}
+
+// ReadDir implements hackpadfs.DirReaderFile
+func (f *rootDir) ReadDir(n int) ([]hackpadfs.DirEntry, error) {
+	for {
+		entries, err := hackpadfs.ReadDirFile(f.File, n)
+		entries = f.fs.hideTrash(entries)
+		if n <= 0 || len(entries) > 0 || err != nil {
+			return entries, err
+		}
+		// only read the trash directory, so read the next page
+	}
+}
+
+// Seek implements hackpadfs.SeekerFile
+func (f *rootDir) Seek(offset int64, whence int) (int64, error) {
+	return hackpadfs.SeekFile(f.File, offset, whence)
+}
+
+// Sync implements hackpadfs.SyncerFile
+func (f *rootDir) Sync() error {
+	return hackpadfs.SyncFile(f.File)
+}
+
+// Truncate implements hackpadfs.TruncaterFile
+func (f *rootDir) Truncate(size int64) error {
+	return hackpadfs.TruncateFile(f.File, size)
+}
+
+// Chmod implements hackpadfs.ChmoderFile
+func (f *rootDir) Chmod(mode hackpadfs.FileMode) error {
+	return hackpadfs.ChmodFile(f.File, mode)
+}
+
+// Chown implements hackpadfs.ChownerFile
+func (f *rootDir) Chown(uid, gid int) error {
+	return hackpadfs.ChownFile(f.File, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimeserFile
+func (f *rootDir) Chtimes(atime time.Time, mtime time.Time) error {
+	return hackpadfs.ChtimesFile(f.File, atime, mtime)
+}
@@ -0,0 +1,325 @@
+// Package trash contains a file system wrapper which moves removed files into a hidden trash directory, so they can be restored later.
+package trash
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+// DefaultDir is the default trash directory name
+const DefaultDir = ".trash"
+
+var (
+	_ interface {
+		hackpadfs.FS
+		hackpadfs.OpenFileFS
+		hackpadfs.MkdirFS
+		hackpadfs.MkdirAllFS
+		hackpadfs.RemoveFS
+		hackpadfs.RemoveAllFS
+		hackpadfs.RenameFS
+		hackpadfs.StatFS
+		hackpadfs.LstatFS
+		hackpadfs.ChmodFS
+		hackpadfs.ChownFS
+		hackpadfs.ChtimesFS
+		hackpadfs.ReadDirFS
+		hackpadfs.ReadFileFS
+		hackpadfs.SymlinkFS
+		hackpadfs.LinkFS
+		hackpadfs.ReadlinkFS
+		hackpadfs.LockFS
+		hackpadfs.StatfsFS
+	} = &FS{}
+)
+
+// Options configures an FS
+type Options struct {
+	// Dir is the name of the trash directory, at the root of the FS. Defaults to DefaultDir.
+	Dir string
+	// MaxAge is how long to keep removed files. Expired files are purged when files are removed, or by calling PurgeExpired(). Unlimited if zero.
+	MaxAge time.Duration
+}
+
+// FS wraps a file system to move removed files and directories into a hidden trash directory, instead of deleting them.
+// Use Items() to list removed files, then Restore() or Purge() them.
+//
+// The trash directory is hidden from the FS: reads inside it return ErrNotExist and changes return ErrInvalid.
+// Since removed files stay on the wrapped FS, the trash survives restarts, and removing files doesn't free any space until they're purged.
+// Only Remove and RemoveAll use the trash. Files replaced by Rename or truncated by OpenFile are still lost.
+type FS struct {
+	fs      hackpadfs.FS
+	options Options
+	now     func() time.Time
+	mu      sync.Mutex // guards trash item changes
+}
+
+// NewFS returns an FS which moves files removed from 'fs' into a trash directory, configured by 'options'
+func NewFS(fs hackpadfs.FS, options Options) (*FS, error) {
+	if options.Dir == "" {
+		options.Dir = DefaultDir
+	}
+	if !hackpadfs.ValidPath(options.Dir) || strings.Contains(options.Dir, "/") || options.Dir == "." {
+		return nil, &hackpadfs.PathError{Op: "trash", Path: options.Dir, Err: hackpadfs.ErrInvalid}
+	}
+	if options.MaxAge < 0 {
+		return nil, errors.New("MaxAge must not be negative")
+	}
+	return &FS{
+		fs:      fs,
+		options: options,
+		now:     time.Now,
+	}, nil
+}
+
+// inTrash returns true if 'name' is the trash directory or inside it
+func (fs *FS) inTrash(name string) bool {
+	return name == fs.options.Dir || strings.HasPrefix(name, fs.options.Dir+"/")
+}
+
+// checkRead returns an error if 'name' is hidden in the trash
+func (fs *FS) checkRead(op, name string) error {
+	if fs.inTrash(name) {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrNotExist}
+	}
+	return nil
+}
+
+// checkWrite returns an error if 'name' is hidden in the trash
+func (fs *FS) checkWrite(op, name string) error {
+	if fs.inTrash(name) {
+		return &hackpadfs.PathError{Op: op, Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	return nil
+}
+
+// Open implements hackpadfs.FS
+func (fs *FS) Open(name string) (hackpadfs.File, error) {
+	return fs.OpenFile(name, hackpadfs.FlagReadOnly, 0)
+}
+
+// OpenFile implements hackpadfs.OpenFileFS
+func (fs *FS) OpenFile(name string, flag int, perm hackpadfs.FileMode) (hackpadfs.File, error) {
+	if flag&(hackpadfs.FlagWriteOnly|hackpadfs.FlagReadWrite|hackpadfs.FlagCreate|hackpadfs.FlagTruncate) != 0 {
+		if err := fs.checkWrite("open", name); err != nil {
+			return nil, err
+		}
+	} else if err := fs.checkRead("open", name); err != nil {
+		return nil, err
+	}
+	f, err := hackpadfs.OpenFile(fs.fs, name, flag, perm)
+	if err != nil || name != "." {
+		return f, err
+	}
+	return &rootDir{File: f, fs: fs}, nil
+}
+
+// Mkdir implements hackpadfs.MkdirFS
+func (fs *FS) Mkdir(name string, perm hackpadfs.FileMode) error {
+	if err := fs.checkWrite("mkdir", name); err != nil {
+		return err
+	}
+	return hackpadfs.Mkdir(fs.fs, name, perm)
+}
+
+// MkdirAll implements hackpadfs.MkdirAllFS
+func (fs *FS) MkdirAll(path string, perm hackpadfs.FileMode) error {
+	if err := fs.checkWrite("mkdir", path); err != nil {
+		return err
+	}
+	return hackpadfs.MkdirAll(fs.fs, path, perm)
+}
+
+// Remove implements hackpadfs.RemoveFS
+//
+// Moves 'name' into the trash. Directories must be empty.
+func (fs *FS) Remove(name string) error {
+	if err := fs.checkWrite("remove", name); err != nil {
+		return err
+	}
+	info, err := fs.lstat(name)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if info.IsDir() {
+		if name == "." {
+			return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrInvalid}
+		}
+		entries, err := hackpadfs.ReadDir(fs.fs, name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &hackpadfs.PathError{Op: "remove", Path: name, Err: hackpadfs.ErrNotEmpty}
+		}
+	}
+	return fs.moveToTrash("remove", name)
+}
+
+// RemoveAll implements hackpadfs.RemoveAllFS
+//
+// Moves 'path' and everything inside it into the trash as a single item. Removing the root directory moves each of its entries into the trash.
+func (fs *FS) RemoveAll(path string) error {
+	if err := fs.checkWrite("removeall", path); err != nil {
+		return err
+	}
+	_, err := fs.lstat(path)
+	switch {
+	case errors.Is(err, hackpadfs.ErrNotExist):
+		return nil
+	case err != nil:
+		return &hackpadfs.PathError{Op: "removeall", Path: path, Err: err}
+	case path != ".":
+		return fs.moveToTrash("removeall", path)
+	}
+	entries, err := fs.ReadDir(".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := fs.moveToTrash("removeall", entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *FS) lstat(name string) (hackpadfs.FileInfo, error) {
+	info, err := hackpadfs.LstatOrStat(fs.fs, name)
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return info, err
+}
+
+// Rename implements hackpadfs.RenameFS
+func (fs *FS) Rename(oldname, newname string) error {
+	if err := fs.checkRead("rename", oldname); err != nil {
+		return err
+	}
+	if err := fs.checkWrite("rename", newname); err != nil {
+		return err
+	}
+	return hackpadfs.Rename(fs.fs, oldname, newname)
+}
+
+// Stat implements hackpadfs.StatFS
+func (fs *FS) Stat(name string) (hackpadfs.FileInfo, error) {
+	if err := fs.checkRead("stat", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.Stat(fs.fs, name)
+}
+
+// Lstat implements hackpadfs.LstatFS
+func (fs *FS) Lstat(name string) (hackpadfs.FileInfo, error) {
+	if err := fs.checkRead("lstat", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.Lstat(fs.fs, name)
+}
+
+// Chmod implements hackpadfs.ChmodFS
+func (fs *FS) Chmod(name string, mode hackpadfs.FileMode) error {
+	if err := fs.checkWrite("chmod", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chmod(fs.fs, name, mode)
+}
+
+// Chown implements hackpadfs.ChownFS
+func (fs *FS) Chown(name string, uid, gid int) error {
+	if err := fs.checkWrite("chown", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chown(fs.fs, name, uid, gid)
+}
+
+// Chtimes implements hackpadfs.ChtimesFS
+func (fs *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := fs.checkWrite("chtimes", name); err != nil {
+		return err
+	}
+	return hackpadfs.Chtimes(fs.fs, name, atime, mtime)
+}
+
+// ReadDir implements hackpadfs.ReadDirFS
+func (fs *FS) ReadDir(name string) ([]hackpadfs.DirEntry, error) {
+	if err := fs.checkRead("readdir", name); err != nil {
+		return nil, err
+	}
+	entries, err := hackpadfs.ReadDir(fs.fs, name)
+	if err != nil || name != "." {
+		return entries, err
+	}
+	return fs.hideTrash(entries), nil
+}
+
+// hideTrash removes the trash directory from root directory 'entries'
+func (fs *FS) hideTrash(entries []hackpadfs.DirEntry) []hackpadfs.DirEntry {
+	for i, entry := range entries {
+		if entry.Name() == fs.options.Dir {
+			return append(entries[:i:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
+
+// ReadFile implements hackpadfs.ReadFileFS
+func (fs *FS) ReadFile(name string) ([]byte, error) {
+	if err := fs.checkRead("readfile", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.ReadFile(fs.fs, name)
+}
+
+// Symlink implements hackpadfs.SymlinkFS
+func (fs *FS) Symlink(oldname, newname string) error {
+	if err := fs.checkWrite("symlink", newname); err != nil {
+		return err
+	}
+	return hackpadfs.Symlink(fs.fs, oldname, newname)
+}
+
+// Link implements hackpadfs.LinkFS
+func (fs *FS) Link(oldname, newname string) error {
+	if fs.inTrash(oldname) || fs.inTrash(newname) {
+		return &hackpadfs.LinkError{Op: "link", Old: oldname, New: newname, Err: hackpadfs.ErrInvalid}
+	}
+	return hackpadfs.Link(fs.fs, oldname, newname)
+}
+
+// Readlink implements hackpadfs.ReadlinkFS
+func (fs *FS) Readlink(name string) (string, error) {
+	if err := fs.checkRead("readlink", name); err != nil {
+		return "", err
+	}
+	return hackpadfs.Readlink(fs.fs, name)
+}
+
+// Lock implements hackpadfs.LockFS
+func (fs *FS) Lock(name string, mode hackpadfs.LockMode) (hackpadfs.Unlocker, error) {
+	if err := fs.checkRead("lock", name); err != nil {
+		return nil, err
+	}
+	return hackpadfs.Lock(fs.fs, name, mode)
+}
+
+// Statfs implements hackpadfs.StatfsFS
+func (fs *FS) Statfs(name string) (hackpadfs.FSUsage, error) {
+	if err := fs.checkRead("statfs", name); err != nil {
+		return hackpadfs.FSUsage{}, err
+	}
+	return hackpadfs.Statfs(fs.fs, name)
+}
+
+// trashPath returns the path of 'elems' inside the trash directory
+func (fs *FS) trashPath(elems ...string) string {
+	return path.Join(append([]string{fs.options.Dir}, elems...)...)
+}
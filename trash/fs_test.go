@@ -0,0 +1,230 @@
+package trash
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+	"github.com/hack-pad/hackpadfs/fstest"
+	"github.com/hack-pad/hackpadfs/internal/assert"
+	"github.com/hack-pad/hackpadfs/mem"
+)
+
+func requireNoError(tb testing.TB, err error) {
+	tb.Helper()
+	if !assert.NoError(tb, err) {
+		tb.FailNow()
+	}
+}
+
+func newFS(tb testing.TB, options Options) (*mem.FS, *FS) {
+	tb.Helper()
+	memFS, err := mem.NewFS()
+	requireNoError(tb, err)
+	fs, err := NewFS(memFS, options)
+	requireNoError(tb, err)
+	return memFS, fs
+}
+
+func TestFS(t *testing.T) {
+	t.Parallel()
+	options := fstest.FSOptions{
+		Name: "trash",
+		Setup: fstest.TestSetupFunc(func(tb testing.TB) (fstest.SetupFS, func() hackpadfs.FS) {
+			_, fs := newFS(tb, Options{})
+			return fs, func() hackpadfs.FS {
+				return fs
+			}
+		}),
+		Constraints: fstest.Constraints{AllowUnenforcedPermissions: true},
+	}
+	fstest.FS(t, options)
+	fstest.File(t, options)
+}
+
+func TestNewFS(t *testing.T) {
+	t.Parallel()
+	memFS, err := mem.NewFS()
+	requireNoError(t, err)
+	for _, dir := range []string{".", "a/b", "/a", "a/"} {
+		_, err := NewFS(memFS, Options{Dir: dir})
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	}
+	_, err = NewFS(memFS, Options{MaxAge: -time.Second})
+	assert.Error(t, err)
+}
+
+func itemPaths(tb testing.TB, fs *FS) []string {
+	tb.Helper()
+	items, err := fs.Items()
+	requireNoError(tb, err)
+	var paths []string
+	for _, item := range items {
+		paths = append(paths, item.Path)
+	}
+	return paths
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	t.Run("remove and restore", func(t *testing.T) {
+		t.Parallel()
+		_, fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.MkdirAll(fs, "dir/sub", 0700))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dir/sub/file", []byte("file"), 0600))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("foo"), 0600))
+
+		requireNoError(t, fs.Remove("foo"))
+		requireNoError(t, fs.RemoveAll("dir"))
+		_, err := fs.Stat("foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		_, err = fs.Stat("dir")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+		items, err := fs.Items()
+		requireNoError(t, err)
+		assert.Equal(t, []string{"foo", "dir"}, itemPaths(t, fs))
+		assert.Equal(t, false, items[0].IsDir)
+		assert.Equal(t, true, items[1].IsDir)
+
+		requireNoError(t, fs.Restore(items[1].ID))
+		contents, err := hackpadfs.ReadFile(fs, "dir/sub/file")
+		assert.NoError(t, err)
+		assert.Equal(t, "file", string(contents))
+		assert.Equal(t, []string{"foo"}, itemPaths(t, fs))
+	})
+
+	t.Run("restore missing parent", func(t *testing.T) {
+		t.Parallel()
+		_, fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.MkdirAll(fs, "dir", 0700))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "dir/file", []byte("file"), 0600))
+		requireNoError(t, fs.Remove("dir/file"))
+		requireNoError(t, fs.Remove("dir"))
+
+		items, err := fs.Items()
+		requireNoError(t, err)
+		requireNoError(t, fs.Restore(items[0].ID))
+		contents, err := hackpadfs.ReadFile(fs, "dir/file")
+		assert.NoError(t, err)
+		assert.Equal(t, "file", string(contents))
+	})
+
+	t.Run("restore conflict", func(t *testing.T) {
+		t.Parallel()
+		_, fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("old"), 0600))
+		requireNoError(t, fs.Remove("foo"))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", []byte("new"), 0600))
+
+		items, err := fs.Items()
+		requireNoError(t, err)
+		err = fs.Restore(items[0].ID)
+		assert.ErrorIs(t, hackpadfs.ErrExist, err)
+		err = fs.RestoreTo(items[0].ID, "")
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+		err = fs.RestoreTo(items[0].ID, DefaultDir+"/foo")
+		assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+
+		requireNoError(t, fs.RestoreTo(items[0].ID, "foo.old"))
+		contents, err := hackpadfs.ReadFile(fs, "foo.old")
+		assert.NoError(t, err)
+		assert.Equal(t, "old", string(contents))
+		contents, err = hackpadfs.ReadFile(fs, "foo")
+		assert.NoError(t, err)
+		assert.Equal(t, "new", string(contents))
+	})
+
+	t.Run("purge and empty", func(t *testing.T) {
+		t.Parallel()
+		memFS, fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "bar", nil, 0600))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "baz", nil, 0600))
+		requireNoError(t, fs.Remove("foo"))
+		requireNoError(t, fs.Remove("bar"))
+		requireNoError(t, fs.Remove("baz"))
+
+		items, err := fs.Items()
+		requireNoError(t, err)
+		requireNoError(t, fs.Purge(items[0].ID))
+		assert.Equal(t, []string{"bar", "baz"}, itemPaths(t, fs))
+		err = fs.Purge(items[0].ID)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		err = fs.Restore(items[0].ID)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+		err = fs.Purge("../foo")
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+
+		requireNoError(t, fs.Empty())
+		assert.Equal(t, []string(nil), itemPaths(t, fs))
+		_, err = hackpadfs.Stat(memFS, DefaultDir)
+		assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		t.Parallel()
+		_, fs := newFS(t, Options{MaxAge: time.Hour})
+		now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		fs.now = func() time.Time { return now }
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "bar", nil, 0600))
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "baz", nil, 0600))
+		requireNoError(t, fs.Remove("foo"))
+		now = now.Add(30 * time.Minute)
+		requireNoError(t, fs.Remove("bar"))
+
+		now = now.Add(45 * time.Minute)
+		requireNoError(t, fs.Remove("baz"))
+		assert.Equal(t, []string{"bar", "baz"}, itemPaths(t, fs))
+
+		now = now.Add(time.Hour)
+		requireNoError(t, fs.PurgeExpired())
+		assert.Equal(t, []string{"baz"}, itemPaths(t, fs))
+	})
+
+	t.Run("remove root", func(t *testing.T) {
+		t.Parallel()
+		_, fs := newFS(t, Options{})
+		requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+		requireNoError(t, hackpadfs.Mkdir(fs, "bar", 0700))
+		requireNoError(t, fs.RemoveAll("."))
+		entries, err := fs.ReadDir(".")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(entries))
+		assert.Equal(t, []string{"bar", "foo"}, itemPaths(t, fs))
+	})
+}
+
+func TestHiddenTrash(t *testing.T) {
+	t.Parallel()
+	memFS, fs := newFS(t, Options{Dir: "Trash"})
+	requireNoError(t, hackpadfs.WriteFullFile(fs, "foo", nil, 0600))
+	requireNoError(t, fs.Remove("foo"))
+	_, err := hackpadfs.Stat(memFS, "Trash")
+	requireNoError(t, err)
+
+	entries, err := fs.ReadDir(".")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(entries))
+	dir, err := fs.Open(".")
+	requireNoError(t, err)
+	entries, err = hackpadfs.ReadDirFile(dir, 1)
+	assert.Equal(t, 0, len(entries))
+	assert.Error(t, err)
+	assert.NoError(t, dir.Close())
+
+	_, err = fs.Stat("Trash")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = fs.ReadDir("Trash")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	err = fs.Mkdir("Trash/foo", 0700)
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	err = fs.RemoveAll("Trash")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+	err = fs.Rename("Trash", "foo")
+	assert.ErrorIs(t, hackpadfs.ErrNotExist, err)
+	_, err = hackpadfs.Create(fs, "Trash")
+	assert.ErrorIs(t, hackpadfs.ErrInvalid, err)
+}
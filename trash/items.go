@@ -0,0 +1,242 @@
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hack-pad/hackpadfs"
+)
+
+const (
+	itemInfoName  = "info"
+	itemEntryName = "entry"
+)
+
+// Item is a removed file or directory in the trash
+type Item struct {
+	// ID identifies the item for Restore() and Purge()
+	ID string
+	// Path is the item's path before it was removed
+	Path string
+	// Deleted is when the item was removed
+	Deleted time.Time
+	// IsDir is true if the item is a directory
+	IsDir bool
+}
+
+// itemInfo is the stored info for an item
+type itemInfo struct {
+	Path    string    `json:"path"`
+	Deleted time.Time `json:"deleted"`
+}
+
+// moveToTrash moves 'name' into a new trash item, first purging any expired items
+func (fs *FS) moveToTrash(op, name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.purgeExpiredLocked(); err != nil {
+		return err
+	}
+	if err := hackpadfs.MkdirAll(fs.fs, fs.options.Dir, 0700); err != nil {
+		return err
+	}
+
+	now := fs.now()
+	id, err := fs.newItemLocked(now)
+	if err != nil {
+		return err
+	}
+	info, err := json.Marshal(itemInfo{Path: name, Deleted: now})
+	if err == nil {
+		err = hackpadfs.WriteFullFile(fs.fs, fs.trashPath(id, itemInfoName), info, 0600)
+	}
+	if err == nil {
+		err = hackpadfs.Rename(fs.fs, name, fs.trashPath(id, itemEntryName))
+	}
+	if err != nil {
+		_ = hackpadfs.RemoveAll(fs.fs, fs.trashPath(id))
+		var linkErr *hackpadfs.LinkError
+		if errors.As(err, &linkErr) {
+			err = linkErr.Err
+		}
+		return &hackpadfs.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+// newItemLocked creates a new, empty item directory and returns its ID
+func (fs *FS) newItemLocked(now time.Time) (string, error) {
+	base := strconv.FormatInt(now.UnixNano(), 10)
+	for i := 0; ; i++ {
+		id := base
+		if i > 0 {
+			id += "-" + strconv.Itoa(i)
+		}
+		err := hackpadfs.Mkdir(fs.fs, fs.trashPath(id), 0700)
+		if !errors.Is(err, hackpadfs.ErrExist) {
+			return id, err
+		}
+	}
+}
+
+// Items returns the items in the trash, oldest first
+func (fs *FS) Items() ([]Item, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.itemsLocked()
+}
+
+func (fs *FS) itemsLocked() ([]Item, error) {
+	entries, err := hackpadfs.ReadDir(fs.fs, fs.options.Dir)
+	if errors.Is(err, hackpadfs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, entry := range entries {
+		item, err := fs.itemLocked(entry.Name())
+		if errors.Is(err, hackpadfs.ErrNotExist) {
+			// incomplete item, from a failed move
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(a, b int) bool {
+		if !items[a].Deleted.Equal(items[b].Deleted) {
+			return items[a].Deleted.Before(items[b].Deleted)
+		}
+		return items[a].ID < items[b].ID
+	})
+	return items, nil
+}
+
+func (fs *FS) itemLocked(id string) (Item, error) {
+	if !hackpadfs.ValidPath(id) || strings.Contains(id, "/") || id == "." {
+		return Item{}, hackpadfs.ErrNotExist
+	}
+	infoBytes, err := hackpadfs.ReadFile(fs.fs, fs.trashPath(id, itemInfoName))
+	if err != nil {
+		return Item{}, err
+	}
+	entryInfo, err := hackpadfs.LstatOrStat(fs.fs, fs.trashPath(id, itemEntryName))
+	if err != nil {
+		return Item{}, err
+	}
+	var info itemInfo
+	if err := json.Unmarshal(infoBytes, &info); err != nil {
+		return Item{}, err
+	}
+	return Item{
+		ID:      id,
+		Path:    info.Path,
+		Deleted: info.Deleted,
+		IsDir:   entryInfo.IsDir(),
+	}, nil
+}
+
+// Restore moves item 'id' back to its original path, recreating any missing parent directories.
+// Fails with ErrExist if something else now exists at that path. Use RestoreTo() to choose another path.
+func (fs *FS) Restore(id string) error {
+	return fs.restore(id, "")
+}
+
+// RestoreTo moves item 'id' out of the trash to 'name', recreating any missing parent directories.
+// Fails with ErrExist if 'name' already exists.
+func (fs *FS) RestoreTo(id, name string) error {
+	if name == "" {
+		return &hackpadfs.PathError{Op: "restore", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	return fs.restore(id, name)
+}
+
+// restore moves item 'id' to 'name', or its original path if 'name' is empty
+func (fs *FS) restore(id, name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	item, err := fs.itemLocked(id)
+	if err != nil {
+		return &hackpadfs.PathError{Op: "restore", Path: id, Err: itemErr(err)}
+	}
+	if name == "" {
+		name = item.Path
+	}
+	switch {
+	case !hackpadfs.ValidPath(name) || name == ".":
+		return &hackpadfs.PathError{Op: "restore", Path: name, Err: hackpadfs.ErrInvalid}
+	case fs.inTrash(name):
+		return &hackpadfs.PathError{Op: "restore", Path: name, Err: hackpadfs.ErrInvalid}
+	}
+	if _, err := hackpadfs.LstatOrStat(fs.fs, name); err == nil {
+		return &hackpadfs.PathError{Op: "restore", Path: name, Err: hackpadfs.ErrExist}
+	}
+	if err := hackpadfs.MkdirAll(fs.fs, path.Dir(name), 0700); err != nil {
+		return err
+	}
+	if err := hackpadfs.Rename(fs.fs, fs.trashPath(id, itemEntryName), name); err != nil {
+		return err
+	}
+	return hackpadfs.RemoveAll(fs.fs, fs.trashPath(id))
+}
+
+// itemErr unwraps errors from reading an item, since they contain trash directory paths
+func itemErr(err error) error {
+	var pathErr *hackpadfs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// Purge permanently deletes item 'id'
+func (fs *FS) Purge(id string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, err := fs.itemLocked(id); err != nil {
+		return &hackpadfs.PathError{Op: "purge", Path: id, Err: itemErr(err)}
+	}
+	return hackpadfs.RemoveAll(fs.fs, fs.trashPath(id))
+}
+
+// Empty permanently deletes every item in the trash
+func (fs *FS) Empty() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return hackpadfs.RemoveAll(fs.fs, fs.options.Dir)
+}
+
+// PurgeExpired permanently deletes items removed longer than Options.MaxAge ago
+func (fs *FS) PurgeExpired() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.purgeExpiredLocked()
+}
+
+func (fs *FS) purgeExpiredLocked() error {
+	if fs.options.MaxAge == 0 {
+		return nil
+	}
+	items, err := fs.itemsLocked()
+	if err != nil {
+		return err
+	}
+	now := fs.now()
+	for _, item := range items {
+		if now.Sub(item.Deleted) <= fs.options.MaxAge {
+			break
+		}
+		if err := hackpadfs.RemoveAll(fs.fs, fs.trashPath(item.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}